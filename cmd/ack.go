@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/hostevents"
+	"github.com/spf13/cobra"
+)
+
+var ackCmd = &cobra.Command{
+	Use:   "ack <job-id>... | ack --host <host>",
+	Short: "Acknowledge failed or flagged jobs, or a host's incidents",
+	Long: `Acknowledge a failed job, or a running job the idle-GPU watchdog has
+flagged, once you've looked into it.
+
+Acknowledged jobs no longer count toward the failures in the 'tray' title or
+list, and the TUI shows them dimmed and without the idle-GPU warning. If the
+job fails or is flagged again after it is acknowledged, it alerts again.
+The TUI's a key toggles a job's acknowledgment.
+
+With --host, acknowledge the incidents recorded on a host (Xid errors,
+thermal throttling, OOM kills; see 'host events'). The TUI's host details no
+longer warn about them, and only incidents recorded after the acknowledgment
+alert. In the hosts view, a toggles the highlighted host's acknowledgment.
+
+Examples:
+  remote-jobs ack 42
+  remote-jobs ack 42 43
+  remote-jobs ack --undo 42
+  remote-jobs ack --host cool30`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if ackHost != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: runAck,
+}
+
+var (
+	ackUndo bool
+	ackHost string
+)
+
+func init() {
+	rootCmd.AddCommand(ackCmd)
+	ackCmd.Flags().BoolVar(&ackUndo, "undo", false, "Remove the acknowledgment, so the job alerts again")
+	ackCmd.Flags().StringVar(&ackHost, "host", "", "Acknowledge the incidents recorded on a host instead of jobs")
+}
+
+func runAck(cmd *cobra.Command, args []string) error {
+	if ackHost != "" {
+		return ackHostEvents(ackHost, ackUndo)
+	}
+	if ackUndo {
+		return forEachJobID(args, unackJob)
+	}
+	return forEachJobID(args, ackJob)
+}
+
+func ackJob(database *sql.DB, jobID int64) error {
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("not found")
+	}
+	stats, err := db.LoadJobStats(database, []int64{jobID})
+	if err != nil {
+		return err
+	}
+	s := stats[jobID]
+	failed, idle := isFailedJob(job), s.IdleGPU()
+	switch {
+	case failed && s.Acked(job), !failed && !idle && s != nil && s.AckedAt > 0:
+		return fmt.Errorf("already acknowledged")
+	case !failed && !idle:
+		return fmt.Errorf("nothing to acknowledge (status: %s)", job.Status)
+	}
+
+	if err := db.AckJob(database, jobID, time.Now().Unix()); err != nil {
+		return fmt.Errorf("acknowledge: %w", err)
+	}
+	what := "failure"
+	if !failed {
+		what = "idle GPUs"
+	}
+	fmt.Printf("Acknowledged the %s of job %d on %s\n", what, job.ID, job.Host)
+	return nil
+}
+
+func unackJob(database *sql.DB, jobID int64) error {
+	removed, err := db.UnackJob(database, jobID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("not acknowledged")
+	}
+	fmt.Printf("Removed the acknowledgment of job %d\n", jobID)
+	return nil
+}
+
+// ackHostEvents acknowledges the incidents recorded on a host, or removes
+// the acknowledgment
+func ackHostEvents(host string, undo bool) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	if undo {
+		removed, err := db.UnackHost(database, host)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("%s: not acknowledged", host)
+		}
+		fmt.Printf("Removed the acknowledgment of the incidents on %s\n", host)
+		return nil
+	}
+
+	ackedAt, err := db.HostAckedAt(database, host)
+	if err != nil {
+		return err
+	}
+	events, err := db.ListHostEvents(database, host, ackedAt+1)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("%s: no incidents to acknowledge", host)
+	}
+	// Events are stamped by the host's clock, which may be ahead of ours
+	if err := db.AckHost(database, host, max(time.Now().Unix(), events[0].Time)); err != nil {
+		return fmt.Errorf("acknowledge: %w", err)
+	}
+	fmt.Printf("Acknowledged %s on %s\n", hostevents.Summary(events), host)
+	return nil
+}
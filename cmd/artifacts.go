@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/artifacts"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// saveJobArtifacts records the artifact globs for a newly created job
+func saveJobArtifacts(database *sql.DB, jobID int64, globs []string) {
+	if len(globs) == 0 {
+		return
+	}
+	if err := db.SetArtifactGlobs(database, jobID, globs); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save artifacts for job %d: %v\n", jobID, err)
+	}
+}
+
+// printArtifacts prints a job's artifact globs, and the files they resolved to
+// once the job has finished
+func printArtifacts(database *sql.DB, jobID int64) {
+	globs, resolved, err := db.GetArtifactGlobs(database, jobID)
+	if err != nil || globs == nil {
+		return
+	}
+	if !resolved {
+		fmt.Printf("Artifacts:    %s (recorded when the job finishes)\n", strings.Join(globs, ", "))
+		return
+	}
+	found, err := db.GetArtifacts(database, jobID)
+	if err != nil {
+		return
+	}
+	fmt.Printf("Artifacts:    %d file(s), %s (fetch with 'remote-jobs fetch %d --artifacts')\n",
+		len(found), humanfmt.Bytes(artifacts.Total(found)), jobID)
+	for _, a := range found {
+		fmt.Printf("  %s (%s)\n", a.Name, humanfmt.Bytes(a.Size))
+	}
+}
+
+// resolveArtifacts records the artifacts of jobs on host that have finished
+// since the last sync
+func resolveArtifacts(database *sql.DB, host string) error {
+	specs, err := db.ListUnresolvedArtifacts(database, host)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		job, err := db.GetJobByID(database, spec.JobID)
+		if err != nil || job == nil {
+			continue
+		}
+		if _, err := resolveJobArtifacts(database, job, spec.Globs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveJobArtifacts lists the files matching a job's artifact globs on its
+// host and records them
+func resolveJobArtifacts(database *sql.DB, job *db.Job, globs []string) ([]db.Artifact, error) {
+	stdout, stderr, err := ssh.Run(jobHost(job), artifacts.ListCommand(job.EffectiveWorkingDir(), globs))
+	if err != nil {
+		return nil, fmt.Errorf("list artifacts of job %d: %s", job.ID, stderr)
+	}
+	found := artifacts.ParseListing(stdout)
+	if err := db.RecordArtifacts(database, job.ID, found); err != nil {
+		return nil, fmt.Errorf("record artifacts of job %d: %w", job.ID, err)
+	}
+	return found, nil
+}
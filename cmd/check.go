@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
 	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
@@ -62,8 +63,9 @@ func runCheck(cmd *cobra.Command, args []string) error {
 		if len(runningJobs) > 0 {
 			fmt.Printf("\nWarning: %d jobs in database are marked as running but no sessions found.\n", len(runningJobs))
 			fmt.Println("These jobs may have died unexpectedly. Marking as dead...")
+			evidence := []db.Evidence{{Check: "tmux sessions on " + host, Result: "none"}}
 			for _, job := range runningJobs {
-				if err := db.MarkDeadByID(database, job.ID); err != nil {
+				if err := db.MarkDeadWithEvidence(database, job.ID, "check", evidence); err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to mark job %d as dead: %v\n", job.ID, err)
 				}
 			}
@@ -74,6 +76,9 @@ func runCheck(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Found %d session(s) on %s:\n\n", len(sessions), host)
 
 	for _, sessionName := range sessions {
+		if session.IsShellSession(sessionName) {
+			continue
+		}
 		fmt.Printf("=== %s ===\n", sessionName)
 
 		// Try to parse job ID from session name (rj-{id} pattern)
@@ -113,6 +118,8 @@ func runCheck(cmd *cobra.Command, args []string) error {
 			exitCode, _ := strconv.Atoi(strings.TrimSpace(statusContent))
 			if exitCode == 0 {
 				fmt.Printf("Status: FINISHED ✓\n")
+			} else if exitCode == db.ExitSkipped {
+				fmt.Printf("Status: SKIPPED (its --if condition was false)\n")
 			} else {
 				fmt.Printf("Status: FINISHED ✗ (exit code: %d)\n", exitCode)
 			}
@@ -129,7 +136,11 @@ func runCheck(cmd *cobra.Command, args []string) error {
 
 			// Mark as dead in database
 			if job != nil {
-				db.MarkDeadByID(database, job.ID)
+				db.MarkDeadWithEvidence(database, job.ID, "check", []db.Evidence{
+					{Check: "tmux session " + sessionName, Result: "exists"},
+					{Check: "processes in the session", Result: "none"},
+					{Check: "status file " + statusFile, Result: "missing"},
+				})
 			}
 		}
 
@@ -139,13 +150,12 @@ func runCheck(cmd *cobra.Command, args []string) error {
 				fmt.Printf("Description: %s\n", job.Description)
 			}
 			if job.StartTime > 0 {
-				startTime := time.Unix(job.StartTime, 0)
-				fmt.Printf("Started: %s\n", startTime.Format("2006-01-02 15:04:05"))
+				fmt.Printf("Started: %s\n", displayTimes.Full(job.StartTime, jobHostZone(database, job.ID)))
 			}
 
 			if job.Status == db.StatusRunning && job.StartTime > 0 {
 				duration := time.Now().Unix() - job.StartTime
-				fmt.Printf("Running for: %s\n", db.FormatDuration(duration))
+				fmt.Printf("Running for: %s\n", humanfmt.Duration(duration))
 			}
 		}
 
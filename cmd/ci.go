@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+// ExitCancelled is the exit code of ci run when it is interrupted, as for a
+// shell command killed by SIGINT
+const ExitCancelled = 130
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Run remote jobs from CI pipelines",
+	Long: `Commands for driving remote jobs from CI runners such as GitHub Actions.
+
+Available subcommands:
+  run       Start a job, stream its log, and exit with its exit code`,
+}
+
+var ciRunCmd = &cobra.Command{
+	Use:   "run [flags] <host> <command>",
+	Short: "Start a job, stream its log, and exit with its exit code",
+	Long: `Start a job, stream its log to stdout until it finishes, and exit with
+the job's exit code, so that a CI step passes or fails with the remote job.
+
+If ci run is interrupted or terminated (SIGINT, SIGTERM, or SIGHUP, as CI
+runners send when a workflow is cancelled), the job is killed before ci run
+exits with code 130, so that cancelled workflows don't leave jobs running.
+
+Exit codes:
+  The job's exit code, if it finished
+  1:   The job failed to start or died without an exit code
+  130: ci run was cancelled, and the job killed
+
+Examples:
+  remote-jobs ci run cool30 'python train.py --epochs 1'
+  remote-jobs ci run -C ~/code/project --timeout 2h cool30 'make test'
+
+In a GitHub Actions workflow:
+  - run: remote-jobs ci run -t "ci-$GITHUB_RUN_ID" cool30 'python eval.py'`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCIRun,
+}
+
+var (
+	ciRunDir         string
+	ciRunDescription string
+	ciRunEnvVars     []string
+	ciRunTimeout     string
+	ciRunTags        []string
+	ciRunSecrets     []string
+	ciRunGPUs        int
+	ciRunBackend     string
+	ciRunForce       bool
+)
+
+func init() {
+	rootCmd.AddCommand(ciCmd)
+	ciCmd.AddCommand(ciRunCmd)
+	ciRunCmd.Flags().StringVarP(&ciRunDir, "directory", "C", "", "Working directory (default: current directory path)")
+	ciRunCmd.Flags().StringVarP(&ciRunDescription, "description", "d", "", "Description of the job")
+	ciRunCmd.Flags().StringSliceVarP(&ciRunEnvVars, "env", "e", nil, "Environment variable (VAR=value), can be repeated")
+	ciRunCmd.Flags().StringVar(&ciRunTimeout, "timeout", "", "Kill job after duration (e.g., \"2h\", \"30m\")")
+	ciRunCmd.Flags().StringSliceVarP(&ciRunTags, "tag", "t", nil, "Tag the job, can be repeated")
+	ciRunCmd.Flags().StringArrayVar(&ciRunSecrets, "secret", nil, "Pass a secret from the keychain or environment as an environment variable, can be repeated")
+	ciRunCmd.Flags().IntVar(&ciRunGPUs, "gpus", 0, "Restrict the job to this many free GPUs")
+	ciRunCmd.Flags().StringVar(&ciRunBackend, "backend", session.BackendAuto, "What keeps the job running: auto, tmux, or nohup")
+	ciRunCmd.Flags().BoolVar(&ciRunForce, "force", false, "Start even if the GPUs the job would use are heavily used by other processes")
+}
+
+func runCIRun(cmd *cobra.Command, args []string) error {
+	host, command := args[0], args[1]
+	if err := session.ValidateBackend(ciRunBackend); err != nil {
+		return err
+	}
+	if err := validateGPUsFlag(ciRunGPUs, ciRunEnvVars); err != nil {
+		return err
+	}
+	if err := validateSecretsFlag(ciRunSecrets, ciRunEnvVars); err != nil {
+		return err
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+	defer waitForDeliveries()
+
+	// Catch cancellation from here on, so that a job started while the
+	// signal arrives is still killed
+	ctx, stop := notifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	onSuccess, onFailure := resolveJobHooks("", "")
+	result, err := startJob(database, startJobOptions{
+		Host:        host,
+		WorkingDir:  ciRunDir,
+		Command:     command,
+		Description: ciRunDescription,
+		EnvVars:     ciRunEnvVars,
+		Timeout:     ciRunTimeout,
+		OnSuccess:   onSuccess,
+		OnFailure:   onFailure,
+		Tags:        ciRunTags,
+		GPUs:        ciRunGPUs,
+		Secrets:     ciRunSecrets,
+		Backend:     ciRunBackend,
+		Force:       ciRunForce,
+	})
+	if err != nil {
+		return err
+	}
+	jobID := result.Info.JobID
+	fmt.Fprintf(os.Stderr, "Started job %d on %s\n", jobID, host)
+
+	// Stream the log until the job finishes or ci run is cancelled
+	tailCtx, stopTail := context.WithCancel(ctx)
+	defer stopTail()
+	waitAndTail := fmt.Sprintf("sh -c 'while [ ! -f %s ]; do sleep 1; done; tail -n +1 -F %s'", result.Info.LogFile, result.Info.LogFile)
+	tail := ssh.CommandContext(tailCtx, host, waitAndTail)
+	tail.Stdout = os.Stdout
+	tail.Stderr = os.Stderr
+	if err := tail.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to stream log: %v\n", err)
+	}
+
+	job, err := waitForCIJob(ctx, database, jobID)
+	if ctx.Err() != nil {
+		stopTail()
+		tail.Wait()
+		abortCIJob(database, jobID, "Cancelled")
+		exit(ExitCancelled)
+	}
+	if err != nil {
+		// Don't leave a job running that nothing is waiting for
+		stopTail()
+		tail.Wait()
+		abortCIJob(database, jobID, fmt.Sprintf("Can't check on job %d: %v", jobID, err))
+		return err
+	}
+
+	// Give tail a moment to print the end of the log
+	time.Sleep(2 * time.Second)
+	stopTail()
+	tail.Wait()
+
+	runCompletionHooks(database)
+	deliverWebhooks(database)
+
+	fmt.Fprintf(os.Stderr, "Job %d %s\n", jobID, classifyJobStatus(job))
+	code := ExitFailed
+	if job.Status == db.StatusCompleted && job.ExitCode != nil {
+		code = *job.ExitCode
+	}
+	exit(code)
+	return nil
+}
+
+// waitForCIJob polls a job until it finishes or ctx is cancelled. A poll
+// that takes longer than the host's sync timeout is waited for again on the
+// next tick rather than holding up cancellation, and no new poll starts
+// until it ends.
+func waitForCIJob(ctx context.Context, database *sql.DB, jobID int64) (*db.Job, error) {
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	var polling chan error // The result of the poll in progress, if any
+	for {
+		job, err := db.GetJobByID(database, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job == nil {
+			return nil, fmt.Errorf("job %d not found", jobID)
+		}
+		if job.Status.Terminal() {
+			return job, nil
+		}
+		if shouldAttemptSync(job.Status) {
+			if polling == nil {
+				polling = make(chan error, 1)
+				go func(job *db.Job, result chan<- error) {
+					_, err := syncJob(database, job)
+					result <- err
+				}(job, polling)
+			}
+			select {
+			case err := <-polling:
+				polling = nil
+				if err != nil && !ssh.IsConnectionError(err.Error()) {
+					return nil, err
+				}
+			case <-time.After(ssh.HostTimeouts(job.Host).Sync):
+			case <-ctx.Done():
+				return job, ctx.Err()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// abortCIJob kills a job that ci run started when ci run stops waiting for
+// it, saying why
+func abortCIJob(database *sql.DB, jobID int64, reason string) {
+	fmt.Fprintf(os.Stderr, "\n%s; killing job %d\n", reason, jobID)
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil || job == nil || job.Status.Terminal() {
+		return
+	}
+	if err := killJob(database, jobID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to kill job %d: %v\n", jobID, err)
+	}
+}
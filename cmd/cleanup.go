@@ -102,6 +102,11 @@ func cleanupFinishedSessions(host string) (int, error) {
 
 	var cleaned int
 	for _, sessionName := range sessions {
+		// Interactive shells are idle at their prompt, not finished
+		if session.IsShellSession(sessionName) {
+			continue
+		}
+
 		// Try to get job info from database
 		var job *db.Job
 		if strings.HasPrefix(sessionName, "rj-") {
@@ -0,0 +1,423 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/dbcrypt"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/secrets"
+	"github.com/osteele/remote-jobs/internal/xdg"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the local job database",
+	Long: `Back up, restore, check, and compact the local job database.
+
+Backups are kept in the backups directory beside the database
+(~/.config/remote-jobs/backups). After remote-jobs sync, the database is
+backed up once a day and garbage-collected once a week, keeping the 7 most
+recent backups. The schedule is set in config.yaml:
+
+  database:
+    backup_interval_hours: 24   # -1 turns automatic backups off
+    keep_backups: 7
+    gc_interval_days: 7         # -1 turns automatic gc off
+
+Examples:
+  remote-jobs db check                           # Size and integrity
+  remote-jobs db gc                              # Prune, VACUUM, and ANALYZE
+  remote-jobs db backup                          # Back up now
+  remote-jobs db backup --list                   # List backups
+  remote-jobs db restore jobs-20261017-041500.db # Restore a backup
+  remote-jobs db encrypt                         # Encrypt the database at rest`,
+}
+
+var dbGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Reclaim unused space and refresh query statistics",
+	Long: `Delete what is still recorded about deleted jobs, such as their tags
+and results, rebuild the database to reclaim the space they left (VACUUM),
+and refresh the statistics SQLite's query planner uses (ANALYZE). Reports the
+database's size before and after.`,
+	Args: cobra.NoArgs,
+	RunE: runDBGC,
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the database",
+	Long: `Write a copy of the database to the backups directory, then remove
+all but the most recent backups (database.keep_backups in config.yaml,
+7 by default).`,
+	Args: cobra.NoArgs,
+	RunE: runDBBackup,
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <backup>",
+	Short: "Replace the database with a backup",
+	Long: `Replace the database with a backup, given as a path or as the name of a
+file in the backups directory (see db backup --list).
+
+The backup must pass SQLite's integrity check. The current database is
+backed up first, so a restore can itself be undone. Quit the TUI and other
+remote-jobs commands before restoring.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDBRestore,
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report the database's size and check its integrity",
+	Args:  cobra.NoArgs,
+	RunE:  runDBCheck,
+}
+
+var dbEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the database at rest",
+	Long: `Encrypt the database with AES-256-GCM under a new random key, which is
+stored in the keychain as REMOTE_JOBS_DB_KEY (the login keychain on macOS,
+the Secret Service keyring through secret-tool on Linux). Where there is no
+keychain, export REMOTE_JOBS_DB_KEY yourself before encrypting, and keep it:
+without the key the database can't be read.
+
+While commands use the encrypted database, a decrypted copy is kept in the
+runtime directory ($XDG_RUNTIME_DIR/remote-jobs, or the temporary directory),
+and the encrypted database is rewritten from it as each command exits. Backups
+of an encrypted database are encrypted too. db lock removes the decrypted copy.
+
+Quit the TUI and other remote-jobs commands before encrypting.`,
+	Args: cobra.NoArgs,
+	RunE: runDBEncrypt,
+}
+
+var dbDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Store the database unencrypted again",
+	Long: `Replace the encrypted database with an unencrypted one. The key is left
+in the keychain, since encrypted backups need it; remove it with
+remote-jobs secret rm REMOTE_JOBS_DB_KEY once they are gone.
+
+Quit the TUI and other remote-jobs commands before decrypting.`,
+	Args: cobra.NoArgs,
+	RunE: runDBDecrypt,
+}
+
+var dbLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Remove the decrypted copy of an encrypted database",
+	Long: `Rewrite the encrypted database from its decrypted copy, then remove the
+copy, as before leaving a machine unattended. The next command decrypts it
+again. Quit the TUI and other remote-jobs commands first.`,
+	Args: cobra.NoArgs,
+	RunE: runDBLock,
+}
+
+var dbMigrateXDGCmd = &cobra.Command{
+	Use:   "migrate-xdg",
+	Short: "Move the config directory under $XDG_CONFIG_HOME",
+	Long: `Move ~/.config/remote-jobs, with the config file and job database, to
+$XDG_CONFIG_HOME/remote-jobs, and leave a symlink in its place so that
+programs run without XDG_CONFIG_HOME still find it. Until it is moved,
+remote-jobs keeps using ~/.config/remote-jobs even if XDG_CONFIG_HOME is set.
+
+Quit the TUI and other remote-jobs commands before moving it.`,
+	Args: cobra.NoArgs,
+	RunE: runDBMigrateXDG,
+}
+
+var (
+	dbBackupList bool
+	dbRestoreYes bool
+)
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbGCCmd)
+	dbCmd.AddCommand(dbBackupCmd)
+	dbCmd.AddCommand(dbRestoreCmd)
+	dbCmd.AddCommand(dbCheckCmd)
+	dbCmd.AddCommand(dbEncryptCmd)
+	dbCmd.AddCommand(dbDecryptCmd)
+	dbCmd.AddCommand(dbLockCmd)
+	dbCmd.AddCommand(dbMigrateXDGCmd)
+	dbBackupCmd.Flags().BoolVar(&dbBackupList, "list", false, "List backups instead of making one")
+	dbRestoreCmd.Flags().BoolVarP(&dbRestoreYes, "yes", "y", false, "Don't ask for confirmation")
+}
+
+func runDBGC(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	before, err := db.GetStats(database)
+	if err != nil {
+		return err
+	}
+	orphans, err := db.GC(database, time.Now())
+	if err != nil {
+		return err
+	}
+	if orphans > 0 {
+		fmt.Printf("Deleted %d row(s) about jobs no longer in the database\n", orphans)
+	}
+	after, err := db.GetStats(database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database: %s -> %s (%s reclaimed)\n",
+		humanfmt.Bytes(before.Size), humanfmt.Bytes(after.Size), humanfmt.Bytes(max(before.Size-after.Size, 0)))
+	return nil
+}
+
+func runDBBackup(cmd *cobra.Command, args []string) error {
+	if dbBackupList {
+		return listDBBackups()
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	path, removed, err := backupDatabase(database, cfg.Database.Keep())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up the database to %s\n", path)
+	if len(removed) > 0 {
+		fmt.Printf("Removed %d old backup(s)\n", len(removed))
+	}
+	return nil
+}
+
+// backupDatabase backs up the database and removes all but the newest keep
+// backups
+func backupDatabase(database *sql.DB, keep int) (string, []db.BackupFile, error) {
+	path, err := db.Backup(database, db.BackupDir(), time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	removed, err := db.RotateBackups(db.BackupDir(), keep)
+	if err != nil {
+		return path, removed, fmt.Errorf("remove old backups: %w", err)
+	}
+	return path, removed, nil
+}
+
+func listDBBackups() error {
+	backups, err := db.ListBackups(db.BackupDir())
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("No backups in %s\n", db.BackupDir())
+		return nil
+	}
+	fmt.Printf("Backups in %s:\n", db.BackupDir())
+	now := time.Now()
+	for _, b := range backups {
+		fmt.Printf("  %-26s %9s  %s\n", filepath.Base(b.Path), humanfmt.Bytes(b.Size), humanfmt.Relative(now.Sub(b.Time)))
+	}
+	return nil
+}
+
+func runDBRestore(cmd *cobra.Command, args []string) error {
+	backup := args[0]
+	if _, err := os.Stat(backup); err != nil {
+		inDir := filepath.Join(db.BackupDir(), backup)
+		if _, dirErr := os.Stat(inDir); dirErr != nil {
+			return fmt.Errorf("backup not found: %s", backup)
+		}
+		backup = inDir
+	}
+	if !dbRestoreYes && !confirm(fmt.Sprintf("Replace %s with %s?", db.Path(), backup)) {
+		return nil
+	}
+
+	saved, err := db.Restore(backup, db.BackupDir(), time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored the database from %s\n", backup)
+	fmt.Printf("The previous database was saved to %s\n", saved)
+	return nil
+}
+
+func runDBMigrateXDG(cmd *cobra.Command, args []string) error {
+	if os.Getenv("XDG_CONFIG_HOME") == "" {
+		return fmt.Errorf("XDG_CONFIG_HOME isn't set")
+	}
+	moved, err := xdg.MigrateConfigDir()
+	if moved != "" {
+		fmt.Printf("Moved %s to %s\n", moved, xdg.ConfigDir())
+	}
+	if err != nil {
+		return err
+	}
+	if moved == "" {
+		fmt.Printf("Nothing to move; the config directory is %s\n", xdg.ConfigDir())
+	}
+	return nil
+}
+
+func runDBCheck(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	stats, err := db.GetStats(database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database:  %s\n", db.Path())
+	if db.Encrypted() {
+		working, _ := db.WorkingPath(db.EncryptedPath())
+		fmt.Printf("Encrypted: %s (decrypted copy in %s)\n", db.EncryptedPath(), working)
+	}
+	fmt.Printf("Size:      %s, %d job(s)\n", humanfmt.Bytes(stats.Size), stats.Jobs)
+	fmt.Printf("Unused:    %s (reclaimed by db gc)\n", humanfmt.Bytes(stats.Reclaimable()))
+
+	now := time.Now()
+	lastGC := "never"
+	if t, err := db.LastGC(database); err == nil && !t.IsZero() {
+		lastGC = humanfmt.Relative(now.Sub(t))
+	}
+	fmt.Printf("Last gc:   %s\n", lastGC)
+	backups, err := db.ListBackups(db.BackupDir())
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Printf("Backups:   none\n")
+	} else {
+		fmt.Printf("Backups:   %d, newest %s\n", len(backups), humanfmt.Relative(now.Sub(backups[0].Time)))
+	}
+
+	problems, err := db.Check(database)
+	if err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if len(problems) > 0 {
+		fmt.Println("Integrity: FAILED")
+		for _, p := range problems {
+			fmt.Printf("  %s\n", p)
+		}
+		return fmt.Errorf("the database failed the integrity check; restore a backup with db restore")
+	}
+	fmt.Println("Integrity: ok")
+	return nil
+}
+
+func runDBEncrypt(cmd *cobra.Command, args []string) error {
+	if db.Encrypted() {
+		return fmt.Errorf("%s is already encrypted", db.EncryptedPath())
+	}
+	key, err := databaseKey()
+	if err != nil {
+		return err
+	}
+	if err := db.Encrypt(key); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted the database to %s\n", db.EncryptedPath())
+	if n, err := db.EncryptBackups(db.BackupDir(), key); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: backups made before encryption may still be unencrypted: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("Encrypted %d existing backup(s) in %s\n", n, db.BackupDir())
+	}
+	fmt.Printf("Its key is %s; without it, the database can't be read\n", dbcrypt.KeyName)
+	return nil
+}
+
+// databaseKey returns the key to encrypt the database with: one already in
+// the keychain or environment, or else a new one, which it stores in the
+// keychain
+func databaseKey() ([]byte, error) {
+	if stored, err := secrets.Lookup(dbcrypt.KeyName); err == nil {
+		return dbcrypt.ParseKey(stored)
+	}
+	encoded, err := dbcrypt.NewKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	if err := secrets.Set(dbcrypt.KeyName, encoded); err != nil {
+		return nil, fmt.Errorf("%w\nTo keep the key yourself, export %s=%s and run db encrypt again", err, dbcrypt.KeyName, encoded)
+	}
+	return dbcrypt.ParseKey(encoded)
+}
+
+func runDBDecrypt(cmd *cobra.Command, args []string) error {
+	if !db.Encrypted() {
+		return fmt.Errorf("the database isn't encrypted")
+	}
+	key, err := dbcrypt.Key()
+	if err != nil {
+		return err
+	}
+	if err := db.Decrypt(key); err != nil {
+		return err
+	}
+	fmt.Printf("Decrypted the database to %s\n", db.Path())
+	return nil
+}
+
+func runDBLock(cmd *cobra.Command, args []string) error {
+	if !db.Encrypted() {
+		return fmt.Errorf("the database isn't encrypted (see db encrypt)")
+	}
+	key, err := dbcrypt.Key()
+	if err != nil {
+		return err
+	}
+	if err := db.Lock(key); err != nil {
+		return err
+	}
+	fmt.Println("Removed the decrypted copy of the database")
+	return nil
+}
+
+// autoMaintainDatabase backs up and garbage-collects the database when the
+// schedule in config.yaml says they are due
+func autoMaintainDatabase(database *sql.DB) {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if interval := cfg.Database.BackupInterval(); interval > 0 {
+		backups, err := db.ListBackups(db.BackupDir())
+		if err == nil && (len(backups) == 0 || now.Sub(backups[0].Time) >= interval) {
+			_, _, err = backupDatabase(database, cfg.Database.Keep())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: database backup: %v\n", err)
+		}
+	}
+	if interval := cfg.Database.GCInterval(); interval > 0 {
+		last, err := db.LastGC(database)
+		if err == nil && now.Sub(last) >= interval {
+			_, err = db.GC(database, now)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: database gc: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// submitHeldJobs adds jobs held for a dependency on another host to their
+// own host's queue once the job they wait for has finished, or marks them
+// failed if it failed and they only run on success. Held jobs whose host is
+// unreachable stay held until the next sync, as do all held jobs in
+// read-only mode. Returns the number submitted.
+func submitHeldJobs(database *sql.DB) int {
+	if readOnly {
+		return 0
+	}
+	deps, err := db.ListPendingDependencies(database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to list held jobs: %v\n", err)
+		return 0
+	}
+
+	submitted := 0
+	for _, dep := range deps {
+		job, err := db.GetJobByID(database, dep.JobID)
+		if err != nil {
+			continue
+		}
+		if job == nil || job.Status != db.StatusPending {
+			// Removed, or started some other way
+			db.DeletePendingDependency(database, dep.JobID)
+			continue
+		}
+
+		upstream, err := db.GetJobByID(database, dep.AfterJobID)
+		if err != nil {
+			continue
+		}
+		if upstream == nil {
+			failHeldJob(database, job, fmt.Sprintf("dependency job %d is no longer in the database", dep.AfterJobID))
+			continue
+		}
+		run, skip := dep.Resolve(upstream)
+		switch {
+		case skip:
+			failHeldJob(database, job, fmt.Sprintf("dependency job %d on %s %s", upstream.ID, upstream.Host, upstreamFailure(upstream)))
+		case run:
+			if err := submitHeldJob(database, job, dep); err != nil {
+				if syncVerbose {
+					fmt.Fprintf(os.Stderr, "  Job %d: %v\n", job.ID, err)
+				}
+				continue
+			}
+			fmt.Printf("Job %d added to queue on %s (job %d on %s finished)\n", job.ID, job.Host, upstream.ID, upstream.Host)
+			submitted++
+		}
+	}
+	return submitted
+}
+
+// submitHeldJob adds a held job to its host's queue, without a dependency,
+// and starts the queue runner if needed. A job whose queue is at its
+// max_queue_depth stays held.
+func submitHeldJob(database *sql.DB, job *db.Job, dep *db.PendingDependency) error {
+	queueName := job.QueueName
+	if queueName == "" {
+		queueName = defaultQueueName
+	}
+	if depth, err := db.CountQueued(database, job.Host, queueName); err == nil {
+		if err := loadHostLimits(job.Host).CheckQueueDepth(job.Host, queueName, depth); err != nil {
+			return fmt.Errorf("%w, will retry on next sync", err)
+		}
+	}
+	guard, _ := db.GetJobGuard(database, job.ID)
+	settings, _ := db.GetJobQueueSettings(database, job.ID)
+	line := queueLine(job.ID, job.WorkingDir, job.Command, job.Description, dep.EnvVarsB64, "", guard, settings)
+	if _, stderr, err := ssh.Run(jobHost(job), session.AppendToQueueCommand(queueName, line)); err != nil {
+		if ssh.IsConnectionError(stderr) {
+			return fmt.Errorf("%s unreachable, will retry on next sync", job.Host)
+		}
+		return fmt.Errorf("append to queue: %s", stderr)
+	}
+	if err := db.UpdatePendingToQueued(database, job.ID); err != nil {
+		return fmt.Errorf("update job: %w", err)
+	}
+	if err := db.DeletePendingDependency(database, job.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to clear dependency for job %d: %v\n", job.ID, err)
+	}
+	if _, err := ensureQueueRunnerStarted(job.Host, queueName); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to start queue runner on %s: %v\n", job.Host, err)
+	}
+	return nil
+}
+
+// failHeldJob marks a held job failed without queueing it, as the queue
+// runner does for a job whose dependency failed
+func failHeldJob(database *sql.DB, job *db.Job, reason string) {
+	if err := db.MarkPendingFailed(database, job.ID, reason); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update job %d: %v\n", job.ID, err)
+		return
+	}
+	db.DeletePendingDependency(database, job.ID)
+	fmt.Printf("Job %d skipped: %s\n", job.ID, reason)
+}
+
+// upstreamFailure describes how a failed job ended, e.g. "failed with exit code 1"
+func upstreamFailure(job *db.Job) string {
+	if job.Skipped() {
+		return "was skipped"
+	}
+	if job.Status == db.StatusCompleted && job.ExitCode != nil {
+		return fmt.Sprintf("failed with exit code %d", *job.ExitCode)
+	}
+	return fmt.Sprintf("is %s", job.Status)
+}
+
+// printHeldNote tells the user when a newly queued job is held locally for a
+// job on another host
+func printHeldNote(database *sql.DB, jobID int64) {
+	dep, _ := db.GetPendingDependency(database, jobID)
+	if dep == nil {
+		return
+	}
+	upstream, _ := db.GetJobByID(database, dep.AfterJobID)
+	if upstream == nil {
+		return
+	}
+	fmt.Printf("  Held locally: job %d is on %s, so 'remote-jobs sync' adds this job to the queue when it finishes\n",
+		upstream.ID, upstream.Host)
+}
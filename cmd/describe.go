@@ -2,7 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"strconv"
+	"slices"
+	"strings"
 
 	"github.com/osteele/remote-jobs/internal/db"
 	"github.com/spf13/cobra"
@@ -10,39 +11,42 @@ import (
 
 var describeCmd = &cobra.Command{
 	Use:   "describe <job-id> [description]",
-	Short: "Set or update the description of a job",
-	Long: `Set or update the description of an existing job.
+	Short: "Set or update the description and tags of a job",
+	Long: `Set or update the description of an existing job, and add or remove
+its tags. With only a job ID, shows the job's description and tags.
 
 Examples:
   remote-jobs describe 42 "Training GPT-2 with lr=0.001"
-  remote-jobs describe 42 ""  # Clear description`,
+  remote-jobs describe 42 ""  # Clear description
+  remote-jobs describe 42 --tag sweep42 --untag scratch
+  remote-jobs describe 42`,
 	Args: cobra.RangeArgs(1, 2),
 	RunE: runDescribe,
 }
 
+var (
+	describeAddTags    []string
+	describeRemoveTags []string
+)
+
 func init() {
-	// Removed: Describe command is now only available as `job describe`
-	// rootCmd.AddCommand(describeCmd)
+	rootCmd.AddCommand(describeCmd)
+	describeCmd.Flags().StringSliceVarP(&describeAddTags, "tag", "t", nil, "Add a tag, can be repeated")
+	describeCmd.Flags().StringSliceVar(&describeRemoveTags, "untag", nil, "Remove a tag, can be repeated")
 }
 
 func runDescribe(cmd *cobra.Command, args []string) error {
-	jobID, err := strconv.ParseInt(args[0], 10, 64)
-	if err != nil {
-		return fmt.Errorf("invalid job ID: %s", args[0])
-	}
-
-	// Description is optional second argument
-	description := ""
-	if len(args) > 1 {
-		description = args[1]
-	}
-
 	database, err := db.Open()
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
 
+	jobID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
 	// Check job exists
 	job, err := db.GetJobByID(database, jobID)
 	if err != nil {
@@ -52,16 +56,59 @@ func runDescribe(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("job %d not found", jobID)
 	}
 
-	// Update description
-	if err := db.UpdateJobDescription(database, jobID, description); err != nil {
-		return fmt.Errorf("update description: %w", err)
+	tags, err := db.GetJobTags(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get tags: %w", err)
 	}
 
-	if description == "" {
-		fmt.Printf("Cleared description for job %d\n", jobID)
-	} else {
-		fmt.Printf("Updated description for job %d: %s\n", jobID, description)
+	editingTags := len(describeAddTags) > 0 || len(describeRemoveTags) > 0
+	if len(args) == 1 && !editingTags {
+		fmt.Printf("Job %d\n", jobID)
+		fmt.Printf("  Description: %s\n", valueOrNone(job.Description))
+		fmt.Printf("  Tags: %s\n", valueOrNone(strings.Join(tags, ", ")))
+		return nil
+	}
+
+	// Description is optional second argument
+	if len(args) > 1 {
+		description := args[1]
+		if err := db.UpdateJobDescription(database, jobID, description); err != nil {
+			return fmt.Errorf("update description: %w", err)
+		}
+		if description == "" {
+			fmt.Printf("Cleared description for job %d\n", jobID)
+		} else {
+			fmt.Printf("Updated description for job %d: %s\n", jobID, description)
+		}
+	}
+
+	if editingTags {
+		tags = editTags(tags, describeAddTags, describeRemoveTags)
+		if err := db.SetJobTags(database, jobID, tags); err != nil {
+			return fmt.Errorf("update tags: %w", err)
+		}
+		fmt.Printf("Tags for job %d: %s\n", jobID, valueOrNone(strings.Join(tags, ", ")))
 	}
 
 	return nil
 }
+
+// editTags returns tags with add appended and remove taken out, sorted
+func editTags(tags, add, remove []string) []string {
+	var result []string
+	for _, tag := range append(tags, add...) {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(remove, tag) && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
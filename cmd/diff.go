@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/jobdiff"
+	"github.com/osteele/remote-jobs/internal/secrets"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <job-id> <job-id>",
+	Short: "Compare two jobs side by side",
+	Long: `Compare two jobs: host, command, working directory, git commit,
+environment variables, status, duration, and exit code. Rows that differ are
+marked with *.
+
+The git commit is read from the working directory when a job starts, and is
+unknown for jobs started by a queue runner. Environment variables are those
+set with --env.
+
+With --log, also diffs the last lines of the two jobs' logs.
+
+Examples:
+  remote-jobs diff 41 42            # What changed between two runs?
+  remote-jobs diff 41 42 --log      # Also diff the last 20 log lines
+  remote-jobs diff 41 42 --log -n 50`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+var (
+	diffLog   bool
+	diffLines int
+)
+
+// diffColumnWidth is the widest a value is shown in the side-by-side table
+const diffColumnWidth = 40
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&diffLog, "log", false, "Also diff the tails of the jobs' logs")
+	diffCmd.Flags().IntVarP(&diffLines, "lines", "n", 20, "Number of log lines to diff (with --log)")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	selector := jobSelector{}.resolver(database)
+	var ids [2]int64
+	for i, arg := range args {
+		id, err := selector.resolve(arg)
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	var runs [2]jobdiff.Run
+	for i, id := range ids {
+		run, err := loadDiffRun(database, id)
+		if err != nil {
+			return err
+		}
+		runs[i] = run
+	}
+
+	printDiffTable(runs[0], runs[1])
+
+	if diffLog {
+		fmt.Println()
+		return printLogDiff(runs[0].Job, runs[1].Job, diffLines)
+	}
+	return nil
+}
+
+// loadDiffRun loads a job and what was recorded about what it ran
+func loadDiffRun(database *sql.DB, jobID int64) (jobdiff.Run, error) {
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return jobdiff.Run{}, fmt.Errorf("get job: %w", err)
+	}
+	if job == nil {
+		return jobdiff.Run{}, fmt.Errorf("job %d not found", jobID)
+	}
+	env, err := db.GetJobEnv(database, job)
+	if err != nil {
+		return jobdiff.Run{}, fmt.Errorf("get environment of job %d: %w", jobID, err)
+	}
+	names, err := db.GetJobSecrets(database, jobID)
+	if err != nil {
+		return jobdiff.Run{}, fmt.Errorf("get secrets of job %d: %w", jobID, err)
+	}
+	env = append(env, secrets.RedactEnv(names)...)
+	git, err := db.GetJobGit(database, jobID)
+	if err != nil {
+		return jobdiff.Run{}, fmt.Errorf("get git commit of job %d: %w", jobID, err)
+	}
+	return jobdiff.Run{Job: job, Env: env, Git: git}, nil
+}
+
+// printDiffTable prints the two jobs' properties in columns, marking the
+// rows that differ
+func printDiffTable(a, b jobdiff.Run) {
+	rows := jobdiff.Compare(a, b, time.Now().Unix())
+
+	nameWidth, aWidth := 0, len(fmt.Sprintf("Job %d", a.Job.ID))
+	for _, row := range rows {
+		nameWidth = max(nameWidth, len(row.Name))
+		aWidth = max(aWidth, utf8.RuneCountInString(truncate(row.A, diffColumnWidth)))
+	}
+
+	fmt.Printf("  %-*s  %-*s  Job %d\n", nameWidth, "", aWidth, fmt.Sprintf("Job %d", a.Job.ID), b.Job.ID)
+	differing := 0
+	for _, row := range rows {
+		marker := " "
+		if row.Differs() {
+			marker = "*"
+			differing++
+		}
+		fmt.Printf("%s %-*s  %-*s  %s\n", marker, nameWidth, row.Name,
+			aWidth, truncate(row.A, diffColumnWidth), truncate(row.B, diffColumnWidth))
+	}
+
+	if differing == 0 {
+		fmt.Println("\nNo differences")
+	}
+}
+
+// printLogDiff diffs the last n lines of two jobs' logs
+func printLogDiff(a, b *db.Job, n int) error {
+	var tails [2][]string
+	for i, job := range []*db.Job{a, b} {
+		stdout, stderr, err := ssh.Run(jobHost(job), logTailCommand(job, n))
+		if err != nil {
+			return fmt.Errorf("read log of job %d: %s", job.ID, ssh.FriendlyError(job.Host, stderr, err))
+		}
+		if out := strings.TrimRight(stdout, "\n"); out != "" {
+			tails[i] = strings.Split(out, "\n")
+		}
+	}
+
+	fmt.Printf("--- job %d (last %d lines)\n", a.ID, n)
+	fmt.Printf("+++ job %d (last %d lines)\n", b.ID, n)
+	if tails[0] == nil && tails[1] == nil {
+		fmt.Println("(neither job has a log)")
+		return nil
+	}
+	for _, line := range jobdiff.Lines(tails[0], tails[1]) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// logTailCommand returns a command that prints the last n lines of a job's
+// log, or nothing if it has none
+func logTailCommand(job *db.Job, n int) string {
+	return session.LogTailCommand(job.ID, job.SessionName, n)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show changes to job statuses and host reachability",
+	Long: `Show the changes to job statuses (queued → running → completed, dead, and
+so on) and to whether hosts answer SSH (online, offline), as sync, status,
+and the TUI find them.
+
+With --follow, events are printed as they are recorded until interrupted.
+Events are recorded by whatever syncs jobs — the TUI, sync, list, status —
+so add --sync to also sync job statuses at the TUI's sync interval. While
+following, events are also sent to the webhooks in config.yaml.
+
+With --json, each event is printed as a JSON object on its own line, with
+the fields id, time, kind ("job" or "host"), job_id (job events only), host,
+from, and to. A job's creation has an empty from.
+
+For incidents such as GPU Xid errors and OOM kills, see 'host events'.
+
+Examples:
+  remote-jobs events                       # Events in the last 24 hours
+  remote-jobs events --since 7d --host cool30
+  remote-jobs events --job 42
+  remote-jobs events --follow --json | jq 'select(.to == "dead")'`,
+	Args: cobra.NoArgs,
+	RunE: runEvents,
+}
+
+var (
+	eventsSince  string
+	eventsHost   string
+	eventsJob    int64
+	eventsLimit  int
+	eventsFollow bool
+	eventsJSON   bool
+	eventsSync   bool
+)
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+	eventsCmd.Flags().StringVar(&eventsSince, "since", "24h", "Show events within this duration (e.g. 1h, 7d)")
+	eventsCmd.Flags().StringVar(&eventsHost, "host", "", "Only show events on this host")
+	eventsCmd.Flags().Int64Var(&eventsJob, "job", 0, "Only show events of this job")
+	eventsCmd.Flags().IntVarP(&eventsLimit, "limit", "n", 100, "Show at most this many past events")
+	eventsCmd.Flags().BoolVarP(&eventsFollow, "follow", "f", false, "Keep printing events as they are recorded")
+	eventsCmd.Flags().BoolVar(&eventsJSON, "json", false, "Print each event as a line of JSON")
+	eventsCmd.Flags().BoolVar(&eventsSync, "sync", false, "With --follow, also sync job statuses periodically")
+}
+
+// eventJSON is an event as events --json prints it
+type eventJSON struct {
+	ID    int64  `json:"id"`
+	Time  string `json:"time"`
+	Kind  string `json:"kind"`
+	JobID int64  `json:"job_id,omitempty"`
+	Host  string `json:"host"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	window, err := parseDuration(eventsSince)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if eventsSync && !eventsFollow {
+		return fmt.Errorf("--sync requires --follow")
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+	defer waitForDeliveries()
+
+	filter := db.EventFilter{
+		Since: time.Now().Add(-window).Unix(),
+		Host:  eventsHost,
+		JobID: eventsJob,
+		Limit: eventsLimit,
+	}
+	events, err := db.ListEvents(database, filter)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	if len(events) == 0 && !eventsFollow && !eventsJSON {
+		fmt.Printf("No events in the last %s\n", eventsSince)
+		return nil
+	}
+	if err := printEvents(events); err != nil {
+		return err
+	}
+	if !eventsFollow {
+		return nil
+	}
+
+	filter.Since, filter.Limit = 0, 0
+	if len(events) > 0 {
+		filter.AfterID = events[len(events)-1].ID
+	} else if filter.AfterID, err = lastEventID(database); err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	return followEvents(database, filter)
+}
+
+// followEvents prints the events selected by filter as they are recorded,
+// syncing job statuses along the way with --sync
+func followEvents(database *sql.DB, filter db.EventFilter) error {
+	syncEvery := time.Duration(config.DefaultConfig().SyncInterval) * time.Second
+	if cfg, err := config.Load(); err == nil && cfg.SyncInterval > 0 {
+		syncEvery = time.Duration(cfg.SyncInterval) * time.Second
+	}
+	var lastSync time.Time
+	for {
+		if eventsSync && time.Since(lastSync) >= syncEvery {
+			performFastSync(database, false)
+			lastSync = time.Now()
+		}
+		deliverWebhooks(database)
+		events, err := db.ListEvents(database, filter)
+		if err != nil {
+			return fmt.Errorf("list events: %w", err)
+		}
+		if err := printEvents(events); err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			filter.AfterID = events[len(events)-1].ID
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// lastEventID returns the ID of the most recent event, or 0 if there are none
+func lastEventID(database *sql.DB) (int64, error) {
+	events, err := db.ListEvents(database, db.EventFilter{Limit: 1})
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	return events[0].ID, nil
+}
+
+// printEvents prints events as text or, with --json, as lines of JSON
+func printEvents(events []db.Event) error {
+	if eventsJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range events {
+			err := enc.Encode(eventJSON{
+				ID:    e.ID,
+				Time:  time.Unix(e.At, 0).Format(time.RFC3339),
+				Kind:  e.Kind,
+				JobID: e.JobID,
+				Host:  e.Host,
+				From:  e.From,
+				To:    e.To,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, e := range events {
+		fmt.Println(formatEvent(e))
+	}
+	return nil
+}
+
+// formatEvent describes an event on one line, such as
+// "2026-10-17 14:02:11 UTC  job 42 on cool30  running → completed"
+func formatEvent(e db.Event) string {
+	at := displayTimes.Full(e.At, nil)
+	if e.Kind == db.EventHost {
+		return fmt.Sprintf("%s  host %s  %s → %s", at, e.Host, e.From, e.To)
+	}
+	subject := "job " + strconv.FormatInt(e.JobID, 10) + " on " + e.Host
+	if e.From == "" {
+		return fmt.Sprintf("%s  %s  created %s", at, subject, e.To)
+	}
+	return fmt.Sprintf("%s  %s  %s → %s", at, subject, e.From, e.To)
+}
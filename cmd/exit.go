@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// exit ends the process with code. Deferred calls don't run, so it first
+// closes the database, which reseals an encrypted one.
+func exit(code int) {
+	db.CloseAll()
+	os.Exit(code)
+}
+
+// signalHandlers counts the commands, or parts of them, that handle
+// interrupts themselves, such as streaming a log until Ctrl-C
+var signalHandlers atomic.Int32
+
+// handlingSignals marks that the caller handles interrupts itself until it
+// calls the returned function, so resealOnSignal leaves the process running
+func handlingSignals() func() {
+	signalHandlers.Add(1)
+	var once sync.Once
+	return func() { once.Do(func() { signalHandlers.Add(-1) }) }
+}
+
+// notifyContext is signal.NotifyContext for code that handles interrupts
+// itself (see handlingSignals)
+func notifyContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	done := handlingSignals()
+	ctx, stop := signal.NotifyContext(parent, signals...)
+	return ctx, func() {
+		stop()
+		done()
+	}
+}
+
+// resealOnSignal keeps an interrupted command from leaving an encrypted
+// database's latest changes only in its decrypted copy. On an interrupt, it
+// reseals the database, and unless the command handles the interrupt
+// itself, closes it and ends the process as the signal would have.
+func resealOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			if signalHandlers.Load() > 0 {
+				db.Reseal()
+				continue
+			}
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			exit(code)
+		}
+	}()
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/spf13/cobra"
+)
+
+var experimentCmd = &cobra.Command{
+	Use:   "experiment",
+	Short: "Group jobs into named experiments",
+	Long: `Group jobs into named experiments, such as the runs of an ablation, and
+follow their progress together.
+
+Jobs join an experiment when they are submitted with --experiment, which
+creates the experiment if it is new; 'experiment add' adds existing jobs.
+'run --from' and 'migrate' keep a job in its experiment.
+
+The TUI can group its job list by experiment (press b).
+
+Examples:
+  remote-jobs experiment create lm2-ablation -d "Layer count ablation"
+  remote-jobs run cool30 --experiment lm2-ablation python train.py --layers 12
+  remote-jobs experiment status lm2-ablation
+  remote-jobs experiment list
+  remote-jobs experiment add lm2-ablation 41 42`,
+}
+
+var experimentCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an experiment",
+	Long: `Create an experiment, or change the description of an existing one.
+Experiments are also created when a job is submitted with --experiment.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExperimentCreate,
+}
+
+var experimentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List experiments",
+	Args:  cobra.NoArgs,
+	RunE:  runExperimentList,
+}
+
+var experimentStatusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Short: "Show the progress of an experiment's jobs",
+	Long: `Show how many of an experiment's jobs are waiting, running, succeeded, and
+failed, how long the experiment has run, and a line for each job.
+
+Job statuses are as of the last sync; run 'remote-jobs sync' first for
+current ones.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeExperimentArg,
+	RunE:              runExperimentStatus,
+}
+
+var experimentAddCmd = &cobra.Command{
+	Use:               "add <name> <job-id>...",
+	Short:             "Add jobs to an experiment",
+	Long:              `Add existing jobs to an experiment, moving them from any other one.`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeExperimentArg,
+	RunE:              runExperimentAdd,
+}
+
+var experimentDescription string
+
+func init() {
+	rootCmd.AddCommand(experimentCmd)
+	experimentCmd.AddCommand(experimentCreateCmd)
+	experimentCmd.AddCommand(experimentListCmd)
+	experimentCmd.AddCommand(experimentStatusCmd)
+	experimentCmd.AddCommand(experimentAddCmd)
+	experimentCreateCmd.Flags().StringVarP(&experimentDescription, "description", "d", "", "Description of the experiment")
+	runCmd.RegisterFlagCompletionFunc("experiment", completeExperiments)
+	queueAddCmd.RegisterFlagCompletionFunc("experiment", completeExperiments)
+}
+
+func runExperimentCreate(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	created, err := db.CreateExperiment(database, args[0], experimentDescription, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("create experiment: %w", err)
+	}
+	if created {
+		fmt.Printf("Created experiment %s\n", args[0])
+	} else if experimentDescription != "" {
+		fmt.Printf("Updated the description of experiment %s\n", args[0])
+	} else {
+		fmt.Printf("Experiment %s already exists\n", args[0])
+	}
+	return nil
+}
+
+func runExperimentList(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	experiments, err := db.ListExperiments(database)
+	if err != nil {
+		return fmt.Errorf("list experiments: %w", err)
+	}
+	if len(experiments) == 0 {
+		fmt.Println("No experiments (submit jobs with --experiment, or use 'remote-jobs experiment create')")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tJOBS\tCREATED\tDESCRIPTION")
+	for _, e := range experiments {
+		created := humanfmt.Relative(now.Sub(time.Unix(e.CreatedAt, 0)))
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.Name, e.Jobs, created, truncate(e.Description, 60))
+	}
+	return w.Flush()
+}
+
+func runExperimentStatus(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	experiment, err := db.GetExperiment(database, args[0])
+	if err != nil {
+		return fmt.Errorf("get experiment: %w", err)
+	}
+	if experiment == nil {
+		return fmt.Errorf("experiment %s not found", args[0])
+	}
+	jobs, err := db.ListExperimentJobs(database, experiment.Name)
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+
+	now := time.Now().Unix()
+	progress := db.Progress(jobs)
+	fmt.Printf("Experiment:   %s\n", experiment.Name)
+	if experiment.Description != "" {
+		fmt.Printf("Description:  %s\n", experiment.Description)
+	}
+	fmt.Printf("Progress:     %s\n", progress)
+	if progress.FirstStart > 0 {
+		if progress.Finished() == progress.Total {
+			fmt.Printf("Took:         %s\n", humanfmt.Duration(progress.LastEnd-progress.FirstStart))
+		} else {
+			fmt.Printf("Running for:  %s\n", humanfmt.Duration(now-progress.FirstStart))
+		}
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tHOST\tDURATION\tDESCRIPTION")
+	for _, job := range jobs {
+		status := string(job.Status)
+		if job.Status == db.StatusCompleted && job.ExitCode != nil {
+			if *job.ExitCode == 0 {
+				status = "completed ✓"
+			} else if job.Skipped() {
+				status = "skipped"
+			} else {
+				status = fmt.Sprintf("failed (%d)", *job.ExitCode)
+			}
+		}
+		display := job.Description
+		if display == "" {
+			display = displayCommand(job)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			job.ID, status, job.Host, humanfmt.DurationShort(job.Elapsed(now)), truncate(display, 60))
+	}
+	return w.Flush()
+}
+
+func runExperimentAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	selector := jobSelector{}.resolver(database)
+	var ids []int64
+	for _, arg := range args[1:] {
+		id, err := selector.resolve(arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	for _, id := range ids {
+		job, err := db.GetJobByID(database, id)
+		if err != nil {
+			return fmt.Errorf("get job %d: %w", id, err)
+		}
+		if job == nil {
+			return fmt.Errorf("job %d not found", id)
+		}
+	}
+	if _, err := db.CreateExperiment(database, name, "", time.Now().Unix()); err != nil {
+		return fmt.Errorf("create experiment: %w", err)
+	}
+	for _, id := range ids {
+		if err := db.SetJobExperiment(database, id, name); err != nil {
+			return fmt.Errorf("add job %d: %w", id, err)
+		}
+		fmt.Printf("Added job %d to %s\n", id, name)
+	}
+	return nil
+}
+
+// saveJobExperiment makes a newly created job a member of an experiment,
+// creating the experiment if it is new
+func saveJobExperiment(database *sql.DB, jobID int64, name string) {
+	if name == "" {
+		return
+	}
+	if _, err := db.CreateExperiment(database, name, "", time.Now().Unix()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to create experiment %s: %v\n", name, err)
+		return
+	}
+	if err := db.SetJobExperiment(database, jobID, name); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to add job %d to experiment %s: %v\n", jobID, name, err)
+	}
+}
+
+// completeExperimentArg completes the first argument with the names of
+// experiments
+func completeExperimentArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return completeExperiments(cmd, args, toComplete)
+}
+
+// completeExperiments completes a flag's value with the names of experiments
+func completeExperiments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	database, err := db.Open()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer database.Close()
+
+	experiments, err := db.ListExperiments(database)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, e := range experiments {
+		names = append(names, e.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ics"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export job history as a calendar",
+	Long: `Export long-running jobs as iCalendar (.ics) events, so experiments
+can be seen alongside meetings and deadlines in a calendar app.
+
+Each job that ran for at least --min-duration becomes an event from its
+start to end time, with the host as the location. Running jobs end at the
+time of export. Event UIDs are stable, so re-exporting to the same file
+updates events in calendars that subscribe to it.
+
+Examples:
+  remote-jobs export --ics > jobs.ics
+  remote-jobs export --ics -o ~/Calendars/jobs.ics --min-duration 30m
+  remote-jobs export --ics --since 7d`,
+	RunE: runExport,
+}
+
+var (
+	exportICS         bool
+	exportOutput      string
+	exportMinDuration string
+	exportSince       string
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().BoolVar(&exportICS, "ics", false, "Export as an iCalendar file")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+	exportCmd.Flags().StringVar(&exportMinDuration, "min-duration", "1h", "Only export jobs that ran at least this long (e.g., 30m, 2h)")
+	exportCmd.Flags().StringVar(&exportSince, "since", "90d", "Only export jobs started within this duration (e.g., 30d, 24h)")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if !exportICS {
+		return fmt.Errorf("specify an export format (--ics)")
+	}
+
+	minDuration, err := parseDuration(exportMinDuration)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w (examples: 30m, 2h)", exportMinDuration, err)
+	}
+	sinceDuration, err := parseDuration(exportSince)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w (examples: 30d, 24h)", exportSince, err)
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	jobs, err := db.ListJobsSince(database, now.Add(-sinceDuration))
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+
+	var events []ics.Event
+	for _, job := range jobs {
+		elapsed := job.Elapsed(now.Unix())
+		if elapsed < 0 || time.Duration(elapsed)*time.Second < minDuration {
+			continue
+		}
+		note, err := db.GetJobNote(database, job.ID)
+		if err != nil {
+			return fmt.Errorf("get note for job %d: %w", job.ID, err)
+		}
+		events = append(events, jobEvent(job, elapsed, note))
+	}
+
+	var w io.Writer = os.Stdout
+	if exportOutput != "" {
+		f, err := os.Create(exportOutput)
+		if err != nil {
+			return fmt.Errorf("create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := ics.Write(w, "remote-jobs", events, now); err != nil {
+		return err
+	}
+	if exportOutput != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d job(s) to %s\n", len(events), exportOutput)
+	}
+	return nil
+}
+
+// jobEvent converts a job that has run for elapsed seconds to a calendar
+// event, with its note, if any, at the end of the event's description
+func jobEvent(job *db.Job, elapsed int64, note *db.JobNote) ics.Event {
+	title := job.Description
+	if title == "" {
+		title = truncate(displayCommand(job), 60)
+	}
+
+	outcome := string(job.Status)
+	switch {
+	case job.Status == db.StatusCompleted && job.ExitCode != nil && *job.ExitCode == 0:
+		outcome = "succeeded"
+	case job.Skipped():
+		outcome = "skipped"
+	case job.Status == db.StatusCompleted && job.ExitCode != nil:
+		outcome = fmt.Sprintf("failed (exit %d)", *job.ExitCode)
+	}
+
+	details := []string{
+		fmt.Sprintf("Job %d on %s: %s", job.ID, job.Host, outcome),
+		"Command: " + displayCommand(job),
+	}
+	if dir := job.EffectiveWorkingDir(); dir != "" {
+		details = append(details, "Directory: "+dir)
+	}
+	if job.Description != "" {
+		details = append(details, "Description: "+job.Description)
+	}
+	if note != nil {
+		details = append(details, "", redactText(note.Text))
+	}
+
+	start := time.Unix(job.StartTime, 0)
+	return ics.Event{
+		UID:         fmt.Sprintf("job-%d-%d@remote-jobs", job.ID, job.StartTime),
+		Start:       start,
+		End:         start.Add(time.Duration(elapsed) * time.Second),
+		Summary:     fmt.Sprintf("[%s] %s", job.Host, title),
+		Location:    job.Host,
+		Description: strings.Join(details, "\n"),
+	}
+}
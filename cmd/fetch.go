@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/artifacts"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch <job-id>",
+	Short: "Download a job's output files",
+	Long: `Download output files of a finished job.
+
+With --artifacts, downloads the files matching the globs the job declared
+with --artifact. Files are saved under the output directory at their paths
+relative to the job's working directory; files outside it are saved by name.
+The output directory defaults to the current directory, or, if a path mapping
+covers the job's working directory, to its local counterpart.
+
+Artifacts are recorded by sync when the job finishes. If they haven't been
+recorded yet, fetch resolves the globs on the host first.
+
+Examples:
+  remote-jobs fetch 42 --artifacts
+  remote-jobs fetch 42 --artifacts -o results/run42`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFetch,
+}
+
+var (
+	fetchArtifacts bool
+	fetchOutput    string
+)
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+	fetchCmd.Flags().BoolVar(&fetchArtifacts, "artifacts", false, "Download the job's artifacts")
+	fetchCmd.Flags().StringVarP(&fetchOutput, "output", "o", "", "Directory to save files in (default: the current directory, or the mapped local directory)")
+}
+
+func runFetch(cmd *cobra.Command, args []string) error {
+	if !fetchArtifacts {
+		return fmt.Errorf("nothing to fetch (use --artifacts)")
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	jobID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get job: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %d not found", jobID)
+	}
+
+	globs, resolved, err := db.GetArtifactGlobs(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get artifacts: %w", err)
+	}
+	if globs == nil {
+		return fmt.Errorf("job %d has no artifacts (declare them with run --artifact)", jobID)
+	}
+
+	var found []db.Artifact
+	switch {
+	case resolved:
+		found, err = db.GetArtifacts(database, jobID)
+	case job.Status == db.StatusCompleted || job.Status == db.StatusDead:
+		found, err = resolveJobArtifacts(database, job, globs)
+	default:
+		return fmt.Errorf("job %d is %s; its artifacts are recorded when it finishes", jobID, job.Status)
+	}
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		fmt.Printf("No files matched job %d's artifacts: %s\n", jobID, strings.Join(globs, ", "))
+		return nil
+	}
+
+	// Without -o, a job in a mapped directory fetches into the local
+	// counterpart of its working directory
+	output := fetchOutput
+	if output == "" {
+		output = "."
+		if dir, ok := localDirOf(job.Host, job.EffectiveWorkingDir()); ok {
+			output = dir
+			fmt.Printf("Saving to %s (path mapping for %s)\n", dir, job.EffectiveWorkingDir())
+		}
+	}
+
+	var failed []string
+	for _, a := range found {
+		localPath := artifacts.LocalPath(output, a)
+		if err := fetchFile(job.Host, a.Path, localPath); err != nil {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", a.Name, err)
+			failed = append(failed, a.Name)
+			continue
+		}
+		fmt.Printf("  %s (%s)\n", localPath, humanfmt.Bytes(a.Size))
+	}
+
+	fmt.Printf("Fetched %d of %d artifact(s) of job %d (%s)\n",
+		len(found)-len(failed), len(found), jobID, humanfmt.Bytes(artifacts.Total(found)))
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to fetch: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// fetchFile downloads a remote file, streaming it over SSH so large files
+// aren't held in memory. A partial file is removed on failure.
+func fetchFile(host, remotePath, localPath string) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	err = ssh.RunStreaming(host, "cat "+shellquote.Quote(remotePath), f, &stderr)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/background"
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/hooks"
+	"github.com/osteele/remote-jobs/internal/notify"
+	"github.com/osteele/remote-jobs/internal/webhooks"
+)
+
+// resolveJobHooks fills in hooks from the config file when no flag was given
+func resolveJobHooks(onSuccess, onFailure string) (string, string) {
+	cfg, err := config.Load()
+	if err != nil {
+		return onSuccess, onFailure
+	}
+	if onSuccess == "" {
+		onSuccess = cfg.Hooks.OnSuccess
+	}
+	if onFailure == "" {
+		onFailure = cfg.Hooks.OnFailure
+	}
+	return onSuccess, onFailure
+}
+
+// saveJobHooks records the completion hooks for a newly created job
+func saveJobHooks(database *sql.DB, jobID int64, onSuccess, onFailure string) {
+	if onSuccess == "" && onFailure == "" {
+		return
+	}
+	if err := db.SetJobHooks(database, jobID, onSuccess, onFailure); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save hooks for job %d: %v\n", jobID, err)
+	}
+}
+
+// runCompletionHooks runs local hooks for any jobs that have finished since
+// the last sync
+func runCompletionHooks(database *sql.DB) {
+	if _, err := hooks.RunPending(database, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// deliveryGrace is how long a command waits, before it closes the database,
+// for the webhook and Slack deliveries it started
+const deliveryGrace = 5 * time.Second
+
+// deliverWebhooks starts sending, in the background, the job status changes
+// recorded since the last delivery to the configured webhooks, and the
+// finished jobs that are due to the Slack notifiers. Commands that call it
+// defer waitForDeliveries after closing the database.
+func deliverWebhooks(database *sql.DB) {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	background.Go("webhooks", func() {
+		if _, err := webhooks.DeliverPending(database, cfg.Webhooks, redactor(), os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	})
+	background.Go("slack", func() {
+		if _, err := notify.DeliverPending(database, cfg.SlackNotifiers(), redactor(), os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	})
+}
+
+// waitForDeliveries gives the deliveries started by deliverWebhooks up to
+// deliveryGrace to finish. Those it gives up on are sent the next time.
+func waitForDeliveries() {
+	background.Wait(deliveryGrace)
+}
+
+// resolveRemoteHooks fills in remote pre-start/post-finish hooks from the
+// host's config entry when none were given for the job
+func resolveRemoteHooks(host, preStart, postFinish string) (string, string) {
+	return loadPlacementConfig().RemoteHooks(host, preStart, postFinish)
+}
@@ -2,26 +2,41 @@ package cmd
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
 
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/hostevents"
+	"github.com/osteele/remote-jobs/internal/hosttools"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/scripts"
+	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/osteele/remote-jobs/internal/timefmt"
 	"github.com/spf13/cobra"
 )
 
 var hostCmd = &cobra.Command{
-	Use:   "host",
-	Short: "Show information about remote hosts",
+	Use:     "host",
+	Aliases: []string{"hosts"},
+	Short:   "Show information about remote hosts",
 	Long: `Show information about remote hosts including system info, active jobs, and load.
 
 Available subcommands:
   info      Show system information (CPU, memory, GPUs)
   jobs      List active jobs on host
-  load      Show current load and resource usage`,
+  load      Show current load and resource usage
+  events    Show GPU Xid errors, thermal throttling, and OOM kills
+  gpus      List the GPUs of all hosts as one pool
+  tags      List the capability tags of hosts
+  add       Add hosts to the host cache from an ssh config or inventory`,
 }
 
 var hostInfoCmd = &cobra.Command{
@@ -57,11 +72,90 @@ Example:
 	RunE: runHostLoad,
 }
 
+var hostEventsCmd = &cobra.Command{
+	Use:   "events [host]",
+	Short: "Show host-level incidents",
+	Long: `Show GPU Xid errors, thermal throttling, and OOM-killer events on a host.
+
+Jobs that die without an exit status are often the victims of host-level
+incidents. With a host argument, this reads the host's kernel log (via
+journalctl, or dmesg where the journal isn't readable) and nvidia-smi, records
+new events, and lists them. Without one, it lists the events already recorded
+for every host (the TUI records them when it refreshes host info).
+
+Examples:
+  remote-jobs host events cool30
+  remote-jobs host events cool30 --since 7d
+  remote-jobs host events                  # Recorded events on all hosts`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHostEvents,
+}
+
+var hostGPUsCmd = &cobra.Command{
+	Use:   "gpus",
+	Short: "List the GPUs of all hosts as one pool",
+	Long: `List every GPU on the hosts in the host cache, with its utilization,
+memory, the job using it, and whether it is free. GPUs with the most free
+memory are listed first.
+
+Each host is queried with nvidia-smi; hosts that can't be reached are shown
+from the cache (marked with *), as of the TUI's last refresh.
+
+States:
+  free      No job is using the GPU and it is idle
+  in use    A job's process has memory on the GPU
+  reserved  A running job was given the GPU with CUDA_VISIBLE_DEVICES
+            but isn't using it yet
+  busy      Processes that aren't remote-jobs jobs are using the GPU
+
+Examples:
+  remote-jobs host gpus
+  remote-jobs host gpus --sort host
+  remote-jobs host gpus --cached     # Don't contact the hosts`,
+	Args: cobra.NoArgs,
+	RunE: runHostGPUs,
+}
+
+var hostTagsCmd = &cobra.Command{
+	Use:   "tags [host]",
+	Short: "List the capability tags of hosts",
+	Long: `List the capability tags that 'run --require' and '--avoid' match, for
+one host or for every host in the host cache or config.
+
+Tags come from two places:
+  - the tags list of the host's entry in config.yaml (e.g. infiniband,
+    cuda12, slow-disk)
+  - the host's cached info, as the TUI last recorded it: the OS and
+    architecture (linux, x86_64), gpu if it has GPUs, and the model of each
+    NVIDIA GPU (a100, h100, rtx4090)
+
+Examples:
+  remote-jobs host tags
+  remote-jobs host tags cool30`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHostTags,
+}
+
+var (
+	hostEventsSince  string
+	hostEventsCached bool
+	hostGPUsSort     string
+	hostGPUsCached   bool
+)
+
 func init() {
 	rootCmd.AddCommand(hostCmd)
 	hostCmd.AddCommand(hostInfoCmd)
 	hostCmd.AddCommand(hostJobsCmd)
 	hostCmd.AddCommand(hostLoadCmd)
+	hostCmd.AddCommand(hostEventsCmd)
+	hostCmd.AddCommand(hostGPUsCmd)
+	hostCmd.AddCommand(hostTagsCmd)
+
+	hostEventsCmd.Flags().StringVar(&hostEventsSince, "since", "24h", "Show events within this duration (e.g. 1h, 7d)")
+	hostEventsCmd.Flags().BoolVar(&hostEventsCached, "cached", false, "Don't contact the host; show recorded events only")
+	hostGPUsCmd.Flags().StringVar(&hostGPUsSort, "sort", "free", "Sort order: free (most free memory first) or host")
+	hostGPUsCmd.Flags().BoolVar(&hostGPUsCached, "cached", false, "Don't contact the hosts; show cached GPU info only")
 }
 
 func runHostInfo(cmd *cobra.Command, args []string) error {
@@ -80,15 +174,21 @@ func runHostInfo(cmd *cobra.Command, args []string) error {
 	}
 
 	// Display cached info if available
-	if cachedInfo != nil {
+	if cachedInfo != nil && cachedInfo.Fetched() {
 		displayHostInfo(host, cachedInfo)
 		cacheAge := time.Now().Unix() - cachedInfo.LastUpdated
-		fmt.Printf("\n(cached %s ago)\n", db.FormatDuration(cacheAge))
+		fmt.Printf("\n(cached %s)\n", humanfmt.Relative(time.Duration(cacheAge)*time.Second))
 	} else {
 		fmt.Printf("No cached information for %s\n", host)
 		fmt.Printf("Run 'remote-jobs tui' to fetch and cache host information\n")
 	}
 
+	if l, err := db.GetHostLatency(database, host); err == nil && l != nil {
+		fmt.Printf("Latency: %s average over %d round trip(s), last %s (%s)\n",
+			humanfmt.Latency(l.Average), l.Samples, humanfmt.Latency(l.Last),
+			humanfmt.Relative(time.Since(time.Unix(l.MeasuredAt, 0))))
+	}
+
 	return nil
 }
 
@@ -117,6 +217,9 @@ func displayHostInfo(host string, info *db.CachedHostInfo) {
 	if info.MemTotal != "" {
 		fmt.Printf("Memory: %s\n", info.MemTotal)
 	}
+	if tools := hosttools.Decode(info.ToolsJSON); tools != nil {
+		fmt.Printf("Tools: %s\n", tools)
+	}
 
 	// Parse and display GPUs from JSON
 	if info.GPUsJSON != "" {
@@ -149,11 +252,11 @@ func runHostJobs(cmd *cobra.Command, args []string) error {
 	fmt.Fprintf(w, "ID\tSTATUS\tSTARTED\tCOMMAND / DESCRIPTION\n")
 
 	for _, job := range jobs {
-		started := time.Unix(job.StartTime, 0).Format("01/02 15:04")
+		started := displayTimes.WithDefaultStyle(timefmt.StyleAbsolute).Short(job.StartTime, jobHostZone(database, job.ID), time.Now())
 
 		display := job.Description
 		if display == "" {
-			display = job.EffectiveCommand()
+			display = displayCommand(job)
 		}
 		if len(display) > 50 {
 			display = display[:47] + "..."
@@ -209,7 +312,7 @@ func runHostLoad(cmd *cobra.Command, args []string) error {
 			if len(parts) >= 6 {
 				fmt.Printf("  GPU %s: %s\n", parts[0], parts[1])
 				fmt.Printf("    Utilization: %s%%\n", parts[2])
-				fmt.Printf("    Memory: %s MiB / %s MiB\n", parts[3], parts[4])
+				fmt.Printf("    Memory: %s / %s\n", humanfmt.MiB(humanfmt.ParseMiB(parts[3])), humanfmt.MiB(humanfmt.ParseMiB(parts[4])))
 				fmt.Printf("    Temperature: %s°C\n", parts[5])
 			}
 		}
@@ -217,3 +320,269 @@ func runHostLoad(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runHostEvents(cmd *cobra.Command, args []string) error {
+	window, err := parseDuration(hostEventsSince)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	since := now.Add(-window)
+
+	var host string
+	if len(args) > 0 {
+		host = args[0]
+	}
+	if host != "" && !hostEventsCached {
+		stdout, stderr, err := ssh.Run(host, hostevents.Command(since))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s; showing recorded events\n", ssh.FriendlyError(host, stderr, err))
+		} else if _, err := db.SaveHostEvents(database, hostevents.Parse(host, stdout, since, now)); err != nil {
+			return fmt.Errorf("save events: %w", err)
+		}
+	}
+
+	events, err := db.ListHostEvents(database, host, since.Unix())
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+	if len(events) == 0 {
+		if host != "" {
+			fmt.Printf("No events on %s in the last %s\n", host, hostEventsSince)
+		} else {
+			fmt.Printf("No recorded events in the last %s\n", hostEventsSince)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tHOST\tKIND\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			displayTimes.WithDefaultStyle(timefmt.StyleAbsolute).Short(e.Time, nil, time.Now()), e.Host, e.Kind, truncate(e.Message, 100))
+	}
+	w.Flush()
+	fmt.Printf("\n%s\n", hostevents.Summary(events))
+	if host != "" {
+		if ackedAt, err := db.HostAckedAt(database, host); err == nil && ackedAt >= events[len(events)-1].Time {
+			fmt.Printf("Incidents up to %s are acknowledged (see 'remote-jobs ack --host')\n",
+				displayTimes.WithDefaultStyle(timefmt.StyleAbsolute).Short(ackedAt, nil, time.Now()))
+		}
+	}
+	return nil
+}
+
+func runHostGPUs(cmd *cobra.Command, args []string) error {
+	if hostGPUsSort != "free" && hostGPUsSort != "host" {
+		return fmt.Errorf("invalid --sort %q (must be free or host)", hostGPUsSort)
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	hosts, err := db.LoadAllCachedHosts(database)
+	if err != nil {
+		return fmt.Errorf("load cached hosts: %w", err)
+	}
+
+	// Query the hosts concurrently; each result is kept in its host's slot
+	pools := make([][]gpupool.GPU, len(hosts))
+	usages := make([][]gpupool.Usage, len(hosts))
+	var wg sync.WaitGroup
+	for i, info := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pools[i], usages[i] = hostGPUPool(database, info, !hostGPUsCached)
+		}()
+	}
+	wg.Wait()
+
+	var gpus []gpupool.GPU
+	var allUsages []gpupool.Usage
+	for i := range hosts {
+		gpus = append(gpus, pools[i]...)
+		allUsages = append(allUsages, usages[i]...)
+	}
+	if len(gpus) == 0 {
+		fmt.Println("No GPUs on cached hosts (run 'remote-jobs tui' to fetch host information)")
+		return nil
+	}
+
+	gpupool.Assign(gpus, allUsages)
+	if hostGPUsSort == "host" {
+		gpupool.SortByHost(gpus)
+	} else {
+		gpupool.SortByFree(gpus)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tGPU\tNAME\tSTATE\tJOB\tUTIL\tMEM\tFREE")
+	anyCached := false
+	for _, g := range gpus {
+		host := g.Host
+		if g.Cached {
+			host += "*"
+			anyCached = true
+		}
+		job := "-"
+		if g.JobID != 0 {
+			job = fmt.Sprintf("%d", g.JobID)
+		}
+		util := "-"
+		if g.Utilization >= 0 {
+			util = fmt.Sprintf("%d%%", g.Utilization)
+		}
+		mem, free := "-", "-"
+		if g.MemTotalMiB > 0 {
+			mem = fmt.Sprintf("%s/%s", humanfmt.MiB(g.MemUsedMiB), humanfmt.MiB(g.MemTotalMiB))
+			free = humanfmt.MiB(g.FreeMiB())
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			host, g.Index, truncate(g.Name, 24), g.State, job, util, mem, free)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%s\n", gpupool.Summary(gpus))
+	if anyCached {
+		fmt.Println("* from the host cache")
+	}
+	return nil
+}
+
+// hostGPUPool returns a host's GPUs and the running jobs' claims on them.
+// With query set it reads the GPUs and their jobs from the host, falling
+// back to the cached info if the host can't be reached.
+func hostGPUPool(database *sql.DB, info *db.CachedHostInfo, query bool) ([]gpupool.GPU, []gpupool.Usage) {
+	host := info.Name
+	jobs, err := db.GetRunningJobsByHost(database, host)
+	if err != nil {
+		jobs = nil
+	}
+
+	if query {
+		if gpus, usages, err := queryGPUPool(database, host, jobs); err == nil && len(gpus) > 0 {
+			return gpus, usages
+		}
+	}
+
+	gpus, err := gpupool.FromCache(host, info.GPUsJSON)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	// The cache may name jobs that have since finished
+	running := make(map[int64]bool)
+	for _, job := range jobs {
+		running[job.ID] = true
+	}
+	for i := range gpus {
+		if !running[gpus[i].JobID] {
+			gpus[i].JobID = 0
+		}
+	}
+	return gpus, gpuReservations(database, host, jobs)
+}
+
+// queryGPUPool reads a host's GPUs with nvidia-smi, and the claims of its
+// running jobs on them: the GPUs each job's processes use, and those it
+// reserves
+func queryGPUPool(database *sql.DB, host string, jobs []*db.Job) ([]gpupool.GPU, []gpupool.Usage, error) {
+	stdout, stderr, err := ssh.RunWithTimeout(host, gpupool.Command, ssh.HostTimeouts(host).Probe)
+	if errors.Is(err, ssh.ErrTimeout) && ssh.CompleteLines(stdout) != "" {
+		// Keep the GPUs listed before the query timed out
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v; showing the GPUs listed by then\n", host, err)
+		stdout = ssh.CompleteLines(stdout)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("%s", ssh.FriendlyError(host, stderr, err))
+	}
+	gpus := gpupool.Parse(host, stdout)
+
+	usages := gpuReservations(database, host, jobs)
+	var pidInfos []ssh.JobPIDInfo
+	for _, job := range jobs {
+		pidInfos = append(pidInfos, ssh.JobPIDInfo{JobID: job.ID, PIDFile: session.JobPidFile(job.ID, job.StartTime)})
+	}
+	mappings, _ := ssh.GetJobGPUMappings(host, scripts.GPUJobMappingScript, pidInfos)
+	for _, m := range mappings {
+		usages = append(usages, gpupool.Usage{Host: host, Index: m.GPUIndex, JobID: m.JobID, Active: true})
+	}
+	return gpus, usages, nil
+}
+
+// gpuReservations returns the GPUs that jobs started with
+// CUDA_VISIBLE_DEVICES (set by hand or by run --gpus) reserve
+func gpuReservations(database *sql.DB, host string, jobs []*db.Job) []gpupool.Usage {
+	var usages []gpupool.Usage
+	for _, job := range jobs {
+		envVars, err := db.GetJobEnv(database, job)
+		if err != nil {
+			continue
+		}
+		for _, index := range gpupool.VisibleDevices(envVars) {
+			usages = append(usages, gpupool.Usage{Host: host, Index: index, JobID: job.ID})
+		}
+	}
+	return usages
+}
+
+func runHostTags(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	cached, err := db.LoadAllCachedHosts(database)
+	if err != nil {
+		return fmt.Errorf("load cached hosts: %w", err)
+	}
+	infos := make(map[string]*db.CachedHostInfo)
+	for _, info := range cached {
+		infos[info.Name] = info
+	}
+	cfg := loadPlacementConfig()
+
+	var hosts []string
+	if len(args) == 1 {
+		hosts = args
+	} else {
+		for name := range infos {
+			hosts = append(hosts, name)
+		}
+		for name, hc := range cfg.Hosts {
+			if len(hc.Tags) > 0 && infos[name] == nil {
+				hosts = append(hosts, name)
+			}
+		}
+		sort.Strings(hosts)
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No hosts in the host cache or config (run 'remote-jobs tui' to fetch host information)")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tTAGS")
+	for _, host := range hosts {
+		tags := inventoryFromCache(cfg, host, infos[host]).Tags
+		list := strings.Join(tags, ", ")
+		if list == "" {
+			list = "-"
+		}
+		if infos[host] == nil {
+			list += "  (not in host cache)"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", host, list)
+	}
+	return w.Flush()
+}
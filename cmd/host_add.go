@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/hostimport"
+	"github.com/spf13/cobra"
+)
+
+var hostAddCmd = &cobra.Command{
+	Use:   "add [host...]",
+	Short: "Add hosts to the host cache",
+	Long: `Add hosts to the host cache, so that the TUI's Hosts view, 'host gpus',
+'host tags', auto placement, and shell completion know them before any job
+has run on them. Their info is fetched the next time the TUI refreshes hosts.
+
+Hosts can be named as arguments, read from the Host lines of an ssh config
+(patterns such as "*" are skipped), or read from an inventory file:
+
+  hosts:
+    cool30:
+      tags: [a100, infiniband]
+    cool31:
+  groups:
+    lab: [cool30, cool31, studio]
+
+Each group's name becomes a tag of its hosts. Tags given here are kept with
+the host, alongside those in config.yaml and those detected from its info,
+and are matched by 'run --require' and '--avoid'. Hosts that are already
+cached keep their info and gain any new tags.
+
+Examples:
+  remote-jobs host add cool30 cool31 --tag lab
+  remote-jobs host add --from-ssh-config
+  remote-jobs host add --from-ssh-config --match 'cool*'
+  remote-jobs host add --from-file hosts.yaml`,
+	RunE: runHostAdd,
+}
+
+var (
+	hostAddSSHConfig string
+	hostAddFile      string
+	hostAddTags      []string
+	hostAddMatch     []string
+)
+
+func init() {
+	hostCmd.AddCommand(hostAddCmd)
+	hostAddCmd.Flags().StringVar(&hostAddSSHConfig, "from-ssh-config", "", "Add the hosts of an ssh config file (default ~/.ssh/config)")
+	hostAddCmd.Flags().Lookup("from-ssh-config").NoOptDefVal = "~/.ssh/config"
+	hostAddCmd.Flags().StringVar(&hostAddFile, "from-file", "", "Add the hosts of an inventory file (YAML with hosts: and groups:)")
+	hostAddCmd.Flags().StringSliceVarP(&hostAddTags, "tag", "t", nil, "Tag to give every added host, can be repeated")
+	hostAddCmd.Flags().StringSliceVar(&hostAddMatch, "match", nil, "Only add hosts whose names match this glob, can be repeated")
+
+	// Complete host arguments of other commands with the hosts known here
+	for _, c := range []*cobra.Command{
+		runCmd, jobRunCmd, checkCmd, cleanupCmd,
+		hostInfoCmd, hostJobsCmd, hostLoadCmd, hostEventsCmd, hostTagsCmd,
+		queueAddCmd, queueStartCmd, queueStopCmd, queueListCmd, queueStatusCmd, queueConfigCmd,
+		shellCmd, shellListCmd, shellKillCmd,
+	} {
+		c.ValidArgsFunction = completeHostArg(0)
+	}
+	jobMoveCmd.ValidArgsFunction = completeHostArg(1)
+	migrateCmd.ValidArgsFunction = completeHostArg(1)
+}
+
+func runHostAdd(cmd *cobra.Command, args []string) error {
+	var hosts []hostimport.Host
+	for _, name := range args {
+		hosts = append(hosts, hostimport.Host{Name: name})
+	}
+	if hostAddSSHConfig != "" {
+		configPath := hostAddSSHConfig
+		if strings.HasPrefix(configPath, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("find home directory: %w", err)
+			}
+			configPath = filepath.Join(home, configPath[2:])
+		}
+		names, err := hostimport.SSHConfigHosts(configPath)
+		if err != nil {
+			return fmt.Errorf("read ssh config: %w", err)
+		}
+		for _, name := range names {
+			hosts = append(hosts, hostimport.Host{Name: name})
+		}
+	}
+	if hostAddFile != "" {
+		data, err := os.ReadFile(hostAddFile)
+		if err != nil {
+			return fmt.Errorf("read inventory: %w", err)
+		}
+		inventory, err := hostimport.ParseInventory(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", hostAddFile, err)
+		}
+		hosts = append(hosts, inventory...)
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("no hosts to add (name hosts, or use --from-ssh-config or --from-file)")
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	var added, existing int
+	for _, h := range hosts {
+		if !hostNameMatches(h.Name, hostAddMatch) {
+			continue
+		}
+		isNew, err := db.AddHost(database, h.Name)
+		if err != nil {
+			return fmt.Errorf("add %s: %w", h.Name, err)
+		}
+		tags := slices.Clone(h.Tags)
+		for _, tag := range hostAddTags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		if err := db.AddHostTags(database, h.Name, tags); err != nil {
+			return fmt.Errorf("tag %s: %w", h.Name, err)
+		}
+
+		line := h.Name
+		if !isNew {
+			line += " (already cached)"
+			existing++
+		} else {
+			added++
+		}
+		if len(tags) > 0 {
+			line += "  tags: " + strings.Join(tags, ", ")
+		}
+		fmt.Println(line)
+	}
+
+	switch {
+	case added == 0 && existing == 0:
+		fmt.Printf("No hosts match %s\n", strings.Join(hostAddMatch, ", "))
+	case added == 0:
+		fmt.Printf("\nNo new hosts; %d already cached\n", existing)
+	default:
+		fmt.Printf("\nAdded %d host(s)", added)
+		if existing > 0 {
+			fmt.Printf("; %d already cached", existing)
+		}
+		fmt.Println("\nTheir info is fetched when the TUI next refreshes hosts ('remote-jobs tui', then h)")
+	}
+	return nil
+}
+
+// hostNameMatches reports whether a host name matches any of patterns, or
+// there are none
+func hostNameMatches(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// completeHostArg returns a completion function that completes the argument
+// at position pos with the hosts in the host cache and those jobs have run on
+func completeHostArg(pos int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != pos {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		database, err := db.Open()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		defer database.Close()
+
+		seen := make(map[string]bool)
+		var hosts []string
+		add := func(name string) {
+			if !seen[name] && strings.HasPrefix(name, toComplete) {
+				seen[name] = true
+				hosts = append(hosts, name)
+			}
+		}
+		if cached, err := db.LoadAllCachedHosts(database); err == nil {
+			for _, info := range cached {
+				add(info.Name)
+			}
+		}
+		if used, err := db.ListUniqueHosts(database); err == nil {
+			for _, name := range used {
+				add(name)
+			}
+		}
+		sort.Strings(hosts)
+		return hosts, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"fmt"
-	"strconv"
+	"os"
 	"strings"
 
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/preset"
+	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/osteele/remote-jobs/internal/taskrunner"
 	"github.com/spf13/cobra"
 )
 
@@ -23,7 +26,7 @@ Available subcommands:
   log       View job log output
   kill      Kill a running job
   status    Check status of one or more jobs
-  describe  Set or update job description
+  describe  Set or update job description and tags
   restart   Restart a job using saved metadata
   list      List and search job history
   move      Move a queued job to a different host`,
@@ -34,17 +37,17 @@ var jobRunCmd = &cobra.Command{
 	Use:   "run <host> <command>",
 	Short: "Start a new job on a remote host",
 	Long:  runCmd.Long,
-	Args:  cobra.MinimumNArgs(2),
+	Args:  validateRunArgs,
 	RunE:  runRun,
 }
 
 // Job log subcommand - delegates to main log command
 var jobLogCmd = &cobra.Command{
-	Use:     "log <job-id>",
+	Use:     "log [job-id]",
 	Aliases: []string{"logs"},
 	Short:   "View log output from a remote job",
 	Long:    logCmd.Long,
-	Args:    cobra.ExactArgs(1),
+	Args:    cobra.MaximumNArgs(1),
 	RunE:    runLog,
 }
 
@@ -57,28 +60,48 @@ var jobKillCmd = &cobra.Command{
 	RunE:  runKill,
 }
 
+var jobPauseCmd = &cobra.Command{
+	Use:   "pause <job-id>...",
+	Short: "Suspend one or more running jobs",
+	Long:  pauseCmd.Long,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runPause,
+}
+
+var jobResumeCmd = &cobra.Command{
+	Use:   "resume <job-id>...",
+	Short: "Resume one or more paused jobs",
+	Long:  resumeCmd.Long,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runResume,
+}
+
 // Job status subcommand - delegates to main status command
 var jobStatusCmd = &cobra.Command{
-	Use:   "status <job-id>...",
+	Use:   "status [job-id...]",
 	Short: "Check status of one or more jobs",
 	Long: `Check the status of one or more jobs by ID.
 
 Shows job metadata including command, host, status, exit code, and timing.
 Supports checking multiple jobs at once.
 
+` + selectorHelp + ` Without job IDs, --host and --running select all
+the jobs they match.
+
 Examples:
   remote-jobs job status 42          # Single job
-  remote-jobs job status 42 43 44    # Multiple jobs`,
-	Args: cobra.MinimumNArgs(1),
+  remote-jobs job status 42 43 44    # Multiple jobs
+  remote-jobs job status --wait 42 43  # Block until both finish
+  remote-jobs job status latest      # The most recent job`,
 	RunE: runStatus,
 }
 
 // Job describe subcommand
 var jobDescribeCmd = &cobra.Command{
-	Use:   "describe <job-id> <description>",
-	Short: "Set or update the description of a job",
+	Use:   "describe <job-id> [description]",
+	Short: "Set or update the description and tags of a job",
 	Long:  describeCmd.Long,
-	Args:  cobra.ExactArgs(2),
+	Args:  cobra.RangeArgs(1, 2),
 	RunE:  runDescribe,
 }
 
@@ -123,6 +146,8 @@ func init() {
 	jobCmd.AddCommand(jobRunCmd)
 	jobCmd.AddCommand(jobLogCmd)
 	jobCmd.AddCommand(jobKillCmd)
+	jobCmd.AddCommand(jobPauseCmd)
+	jobCmd.AddCommand(jobResumeCmd)
 	jobCmd.AddCommand(jobStatusCmd)
 	jobCmd.AddCommand(jobDescribeCmd)
 	jobCmd.AddCommand(jobRestartCmd)
@@ -132,10 +157,44 @@ func init() {
 	// Copy flags from run command to job run
 	jobRunCmd.Flags().StringVarP(&runDescription, "description", "d", "", "Job description")
 	jobRunCmd.Flags().StringVarP(&runDir, "directory", "C", "", "Working directory on remote host")
+	jobRunCmd.Flags().BoolVar(&runMkdir, "mkdir", false, "Create the working directory on the remote host if it doesn't exist")
+	jobRunCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Print the remote commands and wrapper script without running anything")
+	jobRunCmd.Flags().BoolVar(&runIgnoreLimits, "ignore-limits", false, "Start or queue even if the host's configured job limits are reached")
 	jobRunCmd.Flags().BoolVarP(&runFollow, "follow", "f", false, "Follow log output after starting")
 	jobRunCmd.Flags().BoolVar(&runQueue, "queue", false, "Queue job for later instead of running now")
-	jobRunCmd.Flags().Int64Var(&runFrom, "from", 0, "Copy settings from existing job ID (replaces retry)")
+	jobRunCmd.Flags().StringVar(&runFromArg, "from", "", "Copy settings from an existing job, by ID or selector (replaces retry)")
 	jobRunCmd.Flags().StringVar(&runTimeout, "timeout", "", "Kill job after duration (e.g., \"2h\", \"30m\", \"1h30m\")")
+	jobRunCmd.Flags().StringVar(&runOnSuccess, "on-success", "", "Local command to run when the job succeeds")
+	jobRunCmd.Flags().StringVar(&runOnFailure, "on-failure", "", "Local command to run when the job fails or dies")
+	jobRunCmd.Flags().StringVar(&runPreStart, "pre-start", "", "Remote hook to run before the job")
+	jobRunCmd.Flags().StringVar(&runPostFinish, "post-finish", "", "Remote hook to run after the job exits")
+	jobRunCmd.Flags().StringVar(&runScript, "script", "", "Upload a local script and run it (remaining argument is passed to the script)")
+	jobRunCmd.Flags().StringVar(&runMake, "make", "", "Run a target from the local Makefile, in its directory")
+	jobRunCmd.Flags().StringVar(&runJust, "just", "", "Run a recipe from the local justfile, in its directory")
+	jobRunCmd.Flags().StringSliceVarP(&runTags, "tag", "t", nil, "Tag the job (e.g., a sweep name), can be repeated")
+	jobRunCmd.Flags().StringArrayVar(&runArtifacts, "artifact", nil, "Glob for output files to record when the job finishes, can be repeated")
+	jobRunCmd.Flags().StringArrayVar(&runResults, "result", nil, "Regex whose named groups are metrics read from the log when the job finishes, can be repeated")
+	jobRunCmd.Flags().StringVar(&runResultsFile, "results-file", "", "JSON file of metrics to read when the job finishes")
+	jobRunCmd.Flags().StringVar(&runBackend, "backend", session.BackendAuto, "What keeps the job running: auto, tmux, or nohup")
+	jobRunCmd.Flags().IntVar(&runGPUs, "gpus", 0, "Restrict the job to this many free GPUs, chosen at launch (sets CUDA_VISIBLE_DEVICES)")
+	jobRunCmd.Flags().BoolVar(&runForce, "force", false, "Start even if the GPUs the job would use are heavily used by other processes")
+	jobRunCmd.Flags().BoolVar(&runNoPreset, "no-preset", false, "Ignore the project's "+preset.FileName)
+	jobRunCmd.Flags().StringArrayVar(&runSecrets, "secret", nil, "Pass a secret from the keychain as an environment variable, without recording its value, can be repeated")
+	jobRunCmd.RegisterFlagCompletionFunc("make", completeTargets(taskrunner.Make))
+	jobRunCmd.RegisterFlagCompletionFunc("just", completeTargets(taskrunner.Just))
+
+	// Copy flags from status command to job status
+	jobStatusCmd.Flags().BoolVar(&statusSync, "sync", false, "Perform full sync (default is fast sync with timeout)")
+	jobStatusCmd.Flags().BoolVar(&statusNoSync, "no-sync", false, "Skip syncing job statuses before checking")
+	jobStatusCmd.Flags().BoolVar(&statusWait, "wait", false, "Wait for the job(s) to complete before returning")
+	jobStatusCmd.Flags().BoolVar(&statusWaitAny, "any", false, "With --wait, return as soon as any job finishes")
+	jobStatusCmd.Flags().DurationVar(&statusWaitTimeout, "wait-timeout", 0, "Maximum time to wait for completion (0 = no limit)")
+	jobStatusCmd.Flags().BoolVar(&statusExplain, "explain", false, "Show the checks behind each change sync made to the job's status, such as marking it dead")
+	statusSelector.addFlags(jobStatusCmd)
+
+	// Copy flags from describe command to job describe
+	jobDescribeCmd.Flags().StringSliceVarP(&describeAddTags, "tag", "t", nil, "Add a tag, can be repeated")
+	jobDescribeCmd.Flags().StringSliceVar(&describeRemoveTags, "untag", nil, "Remove a tag, can be repeated")
 
 	// Copy flags from log command to job log
 	jobLogCmd.Flags().BoolVarP(&logFollow, "follow", "f", false, "Follow log in real-time")
@@ -143,13 +202,23 @@ func init() {
 	jobLogCmd.Flags().IntVar(&logFrom, "from", 0, "Show lines starting from line N")
 	jobLogCmd.Flags().IntVar(&logTo, "to", 0, "Show lines up to line N")
 	jobLogCmd.Flags().StringVar(&logGrep, "grep", "", "Filter lines matching pattern")
+	jobLogCmd.Flags().IntVar(&logSinceLine, "since-line", 0, "Show lines after line N (the number of lines already read)")
+	jobLogCmd.Flags().IntVar(&logHead, "head", 0, "Show only the first N lines selected (after --grep)")
+	jobLogCmd.Flags().BoolVar(&logUntilExit, "until-exit", false, "Follow the log until the job finishes, then exit with its exit code")
+	jobLogCmd.Flags().BoolVar(&logStderr, "stderr", false, "Show only the job's stderr (for jobs started with run --split-stderr)")
+	logSelector.addFlags(jobLogCmd)
+
+	// Copy flags from restart command to job restart
+	jobRestartCmd.Flags().BoolVar(&restartIgnoreLimits, "ignore-limits", false, "Restart even if the host's configured job limits are reached")
 
 	// Copy flags from list command to job list
 	jobListCmd.Flags().BoolVar(&listRunning, "running", false, "Show only running jobs")
 	jobListCmd.Flags().BoolVar(&listCompleted, "completed", false, "Show only completed jobs")
 	jobListCmd.Flags().BoolVar(&listDead, "dead", false, "Show only dead jobs")
 	jobListCmd.Flags().BoolVar(&listPending, "pending", false, "Show only pending jobs")
+	jobListCmd.Flags().BoolVar(&listPaused, "paused", false, "Show only paused jobs")
 	jobListCmd.Flags().StringVar(&listHost, "host", "", "Filter by host")
+	jobListCmd.Flags().StringVar(&listColumns, "columns", defaultListColumns, "Comma-separated columns to show")
 	jobListCmd.Flags().StringVar(&listSearch, "search", "", "Search by description or command")
 	jobListCmd.Flags().IntVar(&listLimit, "limit", 50, "Limit results")
 	jobListCmd.Flags().Int64Var(&listShow, "show", 0, "Show detailed info for a specific job ID")
@@ -158,10 +227,6 @@ func init() {
 }
 
 func runJobMove(cmd *cobra.Command, args []string) error {
-	jobID, err := strconv.ParseInt(args[0], 10, 64)
-	if err != nil {
-		return fmt.Errorf("invalid job ID: %s", args[0])
-	}
 	newHost := args[1]
 
 	database, err := db.Open()
@@ -170,6 +235,11 @@ func runJobMove(cmd *cobra.Command, args []string) error {
 	}
 	defer database.Close()
 
+	jobID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
 	// Get the job
 	job, err := db.GetJobByID(database, jobID)
 	if err != nil {
@@ -190,32 +260,45 @@ func runJobMove(cmd *cobra.Command, args []string) error {
 		queueName = "default"
 	}
 
-	// Update host in database first
+	// Remove from old host's queue file, unless its runner has taken it
+	stdout, stderr, err := ssh.Run(oldHost, session.RemoveFromQueueCommand(jobID, queueName))
+	deferRemoval := err != nil && ssh.IsConnectionError(stderr)
+	if err != nil && !deferRemoval {
+		return fmt.Errorf("remove from old host queue: %s", strings.TrimSpace(stderr))
+	}
+	if err == nil && strings.TrimSpace(stdout) == "missing" {
+		return fmt.Errorf("job %d is no longer in its queue on %s; it may have started (run 'remote-jobs sync %s')", jobID, oldHost, oldHost)
+	}
+
 	if err := db.UpdateJobHost(database, jobID, newHost); err != nil {
 		return fmt.Errorf("update database: %w", err)
 	}
-
-	// Remove from old host's queue file
-	oldQueueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
-	removeCmd := fmt.Sprintf("sed -i '/^%d\t/d' %s 2>/dev/null || true", jobID, oldQueueFile)
-	_, stderr, err := ssh.Run(oldHost, removeCmd)
-
-	if err != nil && ssh.IsConnectionError(stderr) {
+	saveJobRemoteUser(database, jobID, newHost)
+	if deferRemoval {
 		// Old host unreachable - defer removal
 		fmt.Printf("Old host %s unreachable, will remove on next sync\n", oldHost)
 		if err := db.AddDeferredOperation(database, oldHost, db.OpMoveFromQueue, jobID, queueName); err != nil {
 			return fmt.Errorf("add deferred operation for old host: %w", err)
 		}
-	} else if err != nil {
-		return fmt.Errorf("remove from old host queue: %s", strings.TrimSpace(stderr))
 	}
 
 	// Add to new host's queue file
-	newQueueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
-	queueLine := fmt.Sprintf("%d\t%s\t%s\t%s", jobID, job.WorkingDir, job.Command, job.Description)
-	addCmd := fmt.Sprintf("mkdir -p ~/.cache/remote-jobs/queue && echo '%s' >> %s",
-		ssh.EscapeForSingleQuotes(queueLine), newQueueFile)
-	_, stderr, err = ssh.Run(newHost, addCmd)
+	guard, _ := db.GetJobGuard(database, jobID)
+	settings, _ := db.GetJobQueueSettings(database, jobID)
+	if settings != nil {
+		// The hooks are the new host's
+		settings.PreStart, settings.PostFinish = resolveRemoteHooks(newHost, "", "")
+		db.SetJobQueueSettings(database, jobID, *settings)
+	}
+	// The old host's env defaults give way to the new host's, as in migrate
+	cfg := loadPlacementConfig()
+	envVars, _ := db.GetJobEnv(database, job)
+	envVars = cfg.HostEnv(newHost, cfg.WithoutHostEnv(oldHost, envVars))
+	if err := db.SetJobEnv(database, jobID, envVars); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save environment for job %d: %v\n", jobID, err)
+	}
+	line := queueLine(jobID, job.WorkingDir, job.Command, job.Description, encodeEnvVars(envVars), "", guard, settings)
+	_, stderr, err = ssh.Run(newHost, session.AppendToQueueCommand(queueName, line))
 
 	if err != nil && ssh.IsConnectionError(stderr) {
 		// New host unreachable - job will need to be manually re-queued
@@ -225,8 +308,13 @@ func runJobMove(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("add to new host queue: %s", strings.TrimSpace(stderr))
 	}
 
+	// The line added to the new host's queue has no --after dependency
+	if err := db.DeleteJobDependency(database, jobID); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to clear dependency for job %d: %v\n", jobID, err)
+	}
+
 	fmt.Printf("Moved job %d: %s → %s\n", jobID, oldHost, newHost)
-	fmt.Printf("Command: %s\n", job.Command)
+	fmt.Printf("Command: %s\n", displayCommand(job))
 	if job.Description != "" {
 		fmt.Printf("Description: %s\n", job.Description)
 	}
@@ -7,22 +7,50 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/osteele/remote-jobs/internal/clockskew"
+	"github.com/osteele/remote-jobs/internal/config"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/gitrev"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/notify"
+	"github.com/osteele/remote-jobs/internal/placement"
+	"github.com/osteele/remote-jobs/internal/secrets"
 	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 	"github.com/osteele/remote-jobs/internal/ssh"
 )
 
 // startJobOptions controls how a job is started immediately on the remote host.
 type startJobOptions struct {
-	Host        string
-	WorkingDir  string
-	Command     string
-	Description string
-	EnvVars     []string
-	Timeout     string
-	QueueOnFail bool
-	OnPrepared  func(info StartJobPreparedInfo)
+	Host         string
+	WorkingDir   string
+	Command      string
+	Description  string
+	EnvVars      []string
+	Timeout      string
+	QueueOnFail  bool
+	Mkdir        bool            // Create the working directory if it doesn't exist
+	OnSuccess    string          // Local command to run when the job succeeds
+	OnFailure    string          // Local command to run when the job fails
+	PreStart     string          // Remote hook run before the job (defaults to the host's pre_start)
+	PostFinish   string          // Remote hook run after the job (defaults to the host's post_finish)
+	IgnoreLimits bool            // Start even if the host is at its max_running limit
+	Script       *session.Script // Script to upload before starting; Command runs it
+	Tags         []string
+	Artifacts    []string             // Globs for output files to record when the job finishes
+	Results      db.ResultSpec        // Where to read result metrics from when the job finishes
+	Backend      string               // session.BackendAuto, BackendTmux, or BackendNohup; "" means auto
+	GPUs         int                  // Number of free GPUs to restrict the job to with CUDA_VISIBLE_DEVICES (0 for no restriction)
+	Force        bool                 // Start even if the GPUs the job asks for are heavily used
+	Secrets      []string             // Names of secrets to pass to the job without recording their values
+	Request      placement.Request    // Resources and host tags the job asks for
+	Resume       string               // Command that resumes the job from a checkpoint, for migrate
+	Experiment   string               // Experiment the job belongs to, created if new
+	SplitStderr  bool                 // Also write the job's stderr to a file of its own
+	Staged       []session.StagedFile // Local input files to copy to the host before the job starts
+	OnPrepared   func(info StartJobPreparedInfo)
 }
 
 // StartJobPreparedInfo exposes metadata about the job once it has an ID.
@@ -45,21 +73,56 @@ type startJobResult struct {
 	Info                      StartJobPreparedInfo
 	SlackEnabled              bool
 	QueuedOnConnectionFailure bool
+	GPUs                      []int // GPUs assigned with startJobOptions.GPUs
 }
 
 func startJob(database *sql.DB, opts startJobOptions) (*startJobResult, error) {
 	if opts.WorkingDir == "" {
 		var err error
-		opts.WorkingDir, err = session.DefaultWorkingDir()
+		opts.WorkingDir, err = defaultWorkingDir(opts.Host)
 		if err != nil {
-			return nil, fmt.Errorf("get working dir: %w", err)
+			return nil, err
+		}
+	}
+	opts.EnvVars = loadPlacementConfig().HostEnv(opts.Host, opts.EnvVars)
+
+	// Read secrets first, so a missing one doesn't leave a failed job
+	secretValues, err := secrets.LookupAll(opts.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.IgnoreLimits {
+		if err := checkRunLimit(database, opts.Host); err != nil {
+			return nil, err
+		}
+		if err := checkDiskLimit(opts.Host, opts.WorkingDir); err != nil {
+			return nil, err
 		}
 	}
+	// Jobs given GPUs with --gpus only get free ones
+	if opts.GPUs == 0 {
+		if err := checkGPUContention(opts.Host, opts.EnvVars, opts.Request.Needs.GPUs > 0, opts.Force); err != nil {
+			return nil, err
+		}
+	}
+	autoRelocate(database, opts.Host)
 
 	jobID, err := db.RecordJobStarting(database, opts.Host, opts.WorkingDir, opts.Command, opts.Description)
 	if err != nil {
 		return nil, fmt.Errorf("create job record: %w", err)
 	}
+	saveJobHooks(database, jobID, opts.OnSuccess, opts.OnFailure)
+	saveJobScript(database, jobID, opts.Script)
+	saveJobTags(database, jobID, opts.Tags)
+	saveJobArtifacts(database, jobID, opts.Artifacts)
+	saveJobRequest(database, jobID, opts.Request)
+	saveJobResume(database, jobID, opts.Resume)
+	saveJobExperiment(database, jobID, opts.Experiment)
+	saveJobResultSpec(database, jobID, opts.Results)
+	saveJobEnv(database, jobID, opts.EnvVars)
+	saveJobRemoteUser(database, jobID, opts.Host)
+	saveJobSecrets(database, jobID, opts.Secrets)
 
 	job, err := db.GetJobByID(database, jobID)
 	if err != nil || job == nil {
@@ -102,10 +165,13 @@ func startJob(database *sql.DB, opts startJobOptions) (*startJobResult, error) {
 		return nil, fmt.Errorf("session '%s' already exists on %s", info.TmuxSession, opts.Host)
 	}
 
-	// Create log directory on remote
-	logDir := session.LogDir
-	mkdirCmd := fmt.Sprintf("mkdir -p %s", logDir)
-	if _, stderr, err := ssh.RunWithRetry(opts.Host, mkdirCmd); err != nil {
+	// Create log directory on remote, reading the working directory's git
+	// revision, whether tmux is installed, and the host's clock on the way
+	mkdirCmd := fmt.Sprintf("mkdir -p %s && %s && %s && %s",
+		session.LogDir, gitrev.Command(opts.WorkingDir), session.TmuxProbeCommand, clockskew.Command)
+	before := time.Now()
+	stdout, stderr, err := ssh.RunWithRetry(opts.Host, mkdirCmd)
+	if err != nil {
 		if ssh.IsConnectionError(stderr) && opts.QueueOnFail {
 			if err := db.UpdateJobPending(database, jobID); err != nil {
 				return nil, fmt.Errorf("queue job: %w", err)
@@ -116,60 +182,87 @@ func startJob(database *sql.DB, opts startJobOptions) (*startJobResult, error) {
 		db.UpdateJobFailed(database, jobID, errMsg)
 		return nil, fmt.Errorf("%s", errMsg)
 	}
+	if skew, err := clockskew.Record(database, jobID, stdout, before, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to measure clock skew: %v\n", err)
+	} else if clockskew.Exceeds(skew) {
+		fmt.Fprintf(os.Stderr, "Warning: %s; job times use the host's clock\n", clockskew.Describe(opts.Host, skew))
+	}
+	if rev := gitrev.Parse(stdout); rev != nil {
+		if err := db.RecordJobGit(database, jobID, *rev); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save git revision for job %d: %v\n", jobID, err)
+		}
+	}
 
-	// Save metadata
-	metadata := session.FormatMetadata(jobID, info.WorkingDir, info.Command, info.Host, info.Description, job.StartTime)
-	metadataCmd := fmt.Sprintf("cat > %s << 'METADATA_EOF'\n%s\nMETADATA_EOF", info.MetadataFile, metadata)
-	if _, _, err := ssh.RunWithRetry(opts.Host, metadataCmd); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to save metadata: %v\n", err)
+	hasTmux := session.HasTmux(stdout)
+	if opts.Backend == session.BackendTmux && !hasTmux {
+		errMsg := "tmux is not installed on " + opts.Host
+		db.UpdateJobFailed(database, jobID, errMsg)
+		return nil, fmt.Errorf("%s (use --backend nohup to run without it)", errMsg)
+	}
+	backend := session.ChooseBackend(opts.Backend, hasTmux)
+	if backend == session.BackendNohup && opts.Backend != session.BackendNohup {
+		fmt.Fprintf(os.Stderr, "Note: tmux is not installed on %s; running the job with nohup\n", opts.Host)
+	}
+	opts.Backend = backend
+	saveJobBackend(database, jobID, backend)
+
+	var gpus []int
+	if opts.GPUs > 0 {
+		gpus, err = assignGPUs(database, opts.Host, opts.GPUs)
+		if err != nil {
+			db.UpdateJobFailed(database, jobID, err.Error())
+			return nil, err
+		}
+		opts.EnvVars = append(slices.Clone(opts.EnvVars), gpupool.VisibleDevicesVar(gpus))
+		saveJobEnv(database, jobID, opts.EnvVars)
+		saveJobGPUs(database, jobID, gpus)
 	}
 
-	result := &startJobResult{Info: info}
+	if opts.Script != nil {
+		if _, stderr, err := ssh.RunWithRetry(opts.Host, opts.Script.UploadCommand()); err != nil {
+			errMsg := "upload script: " + ssh.FriendlyError(opts.Host, stderr, err)
+			db.UpdateJobFailed(database, jobID, errMsg)
+			return nil, fmt.Errorf("%s", errMsg)
+		}
+	}
+
+	if len(opts.Staged) > 0 {
+		if err := stageFiles(opts); err != nil {
+			db.UpdateJobFailed(database, jobID, err.Error())
+			return nil, err
+		}
+	}
+
+	result := &startJobResult{Info: info, GPUs: gpus}
 
 	// Slack notification setup
 	notifyCmd := ""
 	slackWebhook := getSlackWebhook()
 	if slackWebhook != "" {
-		remoteNotifyScript := "/tmp/remote-jobs-notify-slack.sh"
-		writeCmd := fmt.Sprintf("cat > '%s' << 'SCRIPT_EOF'\n%s\nSCRIPT_EOF", remoteNotifyScript, string(notifySlackScript))
+		writeCmd := "mkdir -p " + shellquote.Path(session.ScriptDir) + " && " +
+			shellquote.WriteFile(remoteNotifyScript, string(notifySlackScript))
 		if _, stderr, err := ssh.RunWithRetry(opts.Host, writeCmd); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to write notify script: %s\n", stderr)
 		} else {
-			if _, stderr, err := ssh.Run(opts.Host, fmt.Sprintf("chmod +x '%s'", remoteNotifyScript)); err != nil {
+			if _, stderr, err := ssh.Run(opts.Host, "chmod +x "+shellquote.Path(remoteNotifyScript)); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to chmod notify script: %s\n", stderr)
 			} else {
-				envVars := fmt.Sprintf("REMOTE_JOBS_SLACK_WEBHOOK='%s'", slackWebhook)
-				if v := os.Getenv("REMOTE_JOBS_SLACK_VERBOSE"); v == "1" {
-					envVars += " REMOTE_JOBS_SLACK_VERBOSE=1"
-				}
-				if v := os.Getenv("REMOTE_JOBS_SLACK_NOTIFY"); v != "" {
-					envVars += fmt.Sprintf(" REMOTE_JOBS_SLACK_NOTIFY='%s'", v)
-				}
-				if v := os.Getenv("REMOTE_JOBS_SLACK_MIN_DURATION"); v != "" {
-					envVars += fmt.Sprintf(" REMOTE_JOBS_SLACK_MIN_DURATION='%s'", v)
-				}
-				notifyCmd = fmt.Sprintf("; %s '%s' 'rj-%d' $EXIT_CODE '%s' '%s'",
-					envVars, remoteNotifyScript, jobID, info.Host, info.MetadataFile)
+				notifyCmd = slackNotifyCmd(slackWebhook, jobID, info.Host, info.MetadataFile)
 				result.SlackEnabled = true
 			}
 		}
 	}
 
-	wrappedCommand := session.BuildWrapperCommand(session.WrapperCommandParams{
-		JobID:      jobID,
-		WorkingDir: info.WorkingDir,
-		Command:    info.Command,
-		LogFile:    info.LogFile,
-		StatusFile: info.StatusFile,
-		PidFile:    info.PidFile,
-		NotifyCmd:  notifyCmd,
-		Timeout:    opts.Timeout,
-		EnvVars:    opts.EnvVars,
-	})
-
-	escapedCommand := ssh.EscapeForSingleQuotes(wrappedCommand)
-	tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", info.TmuxSession, escapedCommand)
-	if _, stderr, err := ssh.Run(opts.Host, tmuxCmd); err != nil {
+	spec := launchSpec(opts, jobID, job.StartTime, notifyCmd)
+	spec.GPUs = gpus
+	plan := session.BuildLaunchPlan(spec)
+
+	// Save metadata
+	if _, _, err := ssh.RunWithRetry(opts.Host, plan.MetadataCommand); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save metadata: %v\n", err)
+	}
+
+	if _, stderr, err := runLaunch(opts.Host, plan.LaunchCommand, secretValues); err != nil {
 		if ssh.IsConnectionError(stderr) && opts.QueueOnFail {
 			if err := db.UpdateJobPending(database, jobID); err != nil {
 				return nil, fmt.Errorf("queue job: %w", err)
@@ -178,6 +271,9 @@ func startJob(database *sql.DB, opts startJobOptions) (*startJobResult, error) {
 		}
 		errMsg := ssh.FriendlyError(opts.Host, stderr, err)
 		db.UpdateJobFailed(database, jobID, errMsg)
+		if strings.HasPrefix(errMsg, "directory not found") {
+			return nil, fmt.Errorf("%s (use --mkdir to create it)", errMsg)
+		}
 		return nil, fmt.Errorf("%s", errMsg)
 	}
 
@@ -188,16 +284,163 @@ func startJob(database *sql.DB, opts startJobOptions) (*startJobResult, error) {
 	return result, nil
 }
 
+// assignGPUs chooses n free GPUs on a host for a new job, skipping those that
+// running jobs use or reserve, and those that jobs still starting were given
+func assignGPUs(database *sql.DB, host string, n int) ([]int, error) {
+	jobs, err := db.GetActiveJobsByHost(database, host)
+	if err != nil {
+		return nil, fmt.Errorf("list active jobs: %w", err)
+	}
+	gpus, usages, err := queryGPUPool(database, host, jobs)
+	if err != nil {
+		return nil, fmt.Errorf("query GPUs: %w", err)
+	}
+	gpupool.Assign(gpus, usages)
+	indices, err := gpupool.Pick(gpus, n)
+	if err != nil {
+		return nil, fmt.Errorf("assign GPUs on %s: %w", host, err)
+	}
+	return indices, nil
+}
+
+// remoteNotifyScript is where the Slack notification script is uploaded: in
+// the home directory rather than /tmp, so accounts sharing a host each have
+// their own
+const remoteNotifyScript = session.ScriptDir + "/notify-slack.sh"
+
+// slackNotifyCmd returns the wrapper suffix that runs the Slack notification
+// script with the job's exit code.
+func slackNotifyCmd(webhook string, jobID int64, host, metadataFile string) string {
+	envVars := shellquote.Assignment("REMOTE_JOBS_SLACK_WEBHOOK=" + webhook)
+	if v := os.Getenv("REMOTE_JOBS_SLACK_VERBOSE"); v == "1" {
+		envVars += " REMOTE_JOBS_SLACK_VERBOSE=1"
+	}
+	if v := os.Getenv("REMOTE_JOBS_SLACK_NOTIFY"); v != "" {
+		envVars += " " + shellquote.Assignment("REMOTE_JOBS_SLACK_NOTIFY="+v)
+	}
+	if v := os.Getenv("REMOTE_JOBS_SLACK_MIN_DURATION"); v != "" {
+		envVars += " " + shellquote.Assignment("REMOTE_JOBS_SLACK_MIN_DURATION="+v)
+	}
+	for _, assignment := range slackTemplateEnv() {
+		envVars += " " + assignment
+	}
+	return fmt.Sprintf("; %s %s %s $EXIT_CODE %s %s",
+		envVars, shellquote.Path(remoteNotifyScript), session.TmuxSessionName(jobID),
+		shellquote.Quote(host), shellquote.Path(metadataFile))
+}
+
+// slackTemplateEnv returns the assignments that give the notify script the
+// redaction patterns to apply to the job's command and log, and the messages
+// rendered from slack_template, if it is set. They are base64 encoded, since
+// they span lines.
+func slackTemplateEnv() []string {
+	env := []string{"REMOTE_JOBS_REDACT_B64=" + base64.StdEncoding.EncodeToString([]byte(strings.Join(redactor().Patterns(), "\n")))}
+	cfg, err := config.Load()
+	if err != nil || cfg.SlackTemplate == "" {
+		return env
+	}
+	succeeded, failed, err := notify.HostMessages(cfg.SlackTemplate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: slack_template: %v (using the default message)\n", err)
+		return env
+	}
+	return append(env,
+		"REMOTE_JOBS_SLACK_MESSAGE_OK_B64="+base64.StdEncoding.EncodeToString([]byte(succeeded)),
+		"REMOTE_JOBS_SLACK_MESSAGE_FAILED_B64="+base64.StdEncoding.EncodeToString([]byte(failed)),
+	)
+}
+
+// launchSpec converts start options into a session launch spec, resolving
+// the host's remote hooks.
+func launchSpec(opts startJobOptions, jobID, startTime int64, notifyCmd string) session.LaunchSpec {
+	preStart, postFinish := resolveRemoteHooks(opts.Host, opts.PreStart, opts.PostFinish)
+	return session.LaunchSpec{
+		JobID:       jobID,
+		StartTime:   startTime,
+		Host:        opts.Host,
+		WorkingDir:  opts.WorkingDir,
+		Command:     opts.Command,
+		Description: opts.Description,
+		EnvVars:     opts.EnvVars,
+		Timeout:     opts.Timeout,
+		NotifyCmd:   notifyCmd,
+		PreStart:    preStart,
+		PostFinish:  postFinish,
+		CreateDir:   opts.Mkdir,
+		Script:      opts.Script,
+		Backend:     opts.Backend,
+		Secrets:     len(opts.Secrets) > 0,
+		SplitStderr: opts.SplitStderr,
+		Staged:      opts.Staged,
+	}
+}
+
+// stageFiles copies a job's input files to its host, into directories
+// created under its working directory
+func stageFiles(opts startJobOptions) error {
+	dirsCmd := session.StageDirsCommand(opts.Staged, opts.WorkingDir, opts.Mkdir)
+	if _, stderr, err := ssh.RunWithRetry(opts.Host, dirsCmd); err != nil {
+		errMsg := ssh.FriendlyError(opts.Host, stderr, err)
+		if strings.HasPrefix(errMsg, "directory not found") {
+			return fmt.Errorf("%s (use --mkdir to create it)", errMsg)
+		}
+		return fmt.Errorf("stage files: %s", errMsg)
+	}
+	for _, f := range opts.Staged {
+		if err := ssh.CopyTo(f.Local, opts.Host, f.RemotePath(opts.WorkingDir)); err != nil {
+			return fmt.Errorf("stage %s: %w", f.Local, err)
+		}
+	}
+	return nil
+}
+
+// previewJob builds the launch plan startJob would use, without creating a
+// job record or contacting the host. The job ID is the predicted next ID.
+func previewJob(database *sql.DB, opts startJobOptions) (session.LaunchPlan, error) {
+	if opts.WorkingDir == "" {
+		var err error
+		opts.WorkingDir, err = defaultWorkingDir(opts.Host)
+		if err != nil {
+			return session.LaunchPlan{}, err
+		}
+	}
+	opts.EnvVars = loadPlacementConfig().HostEnv(opts.Host, opts.EnvVars)
+
+	jobID, err := db.NextJobID(database)
+	if err != nil {
+		return session.LaunchPlan{}, fmt.Errorf("predict job ID: %w", err)
+	}
+
+	startTime := time.Now().Unix()
+	notifyCmd := ""
+	if webhook := getSlackWebhook(); webhook != "" {
+		notifyCmd = slackNotifyCmd(webhook, jobID, opts.Host, session.MetadataFile(jobID, startTime))
+	}
+	return session.BuildLaunchPlan(launchSpec(opts, jobID, startTime, notifyCmd)), nil
+}
+
 // queueJobOptions controls adding a job to a remote queue.
 type queueJobOptions struct {
-	Host        string
-	WorkingDir  string
-	Command     string
-	Description string
-	EnvVars     []string
-	QueueName   string
-	AfterJobID  int64
-	AfterAny    bool
+	Host         string
+	WorkingDir   string
+	Command      string
+	Description  string
+	EnvVars      []string
+	QueueName    string
+	AfterJobID   int64
+	AfterAny     bool
+	OnSuccess    string
+	OnFailure    string
+	IgnoreLimits bool            // Queue even if the queue is at its max_queue_depth limit
+	Script       *session.Script // Script to upload before queueing; Command runs it
+	Tags         []string
+	Guard        *db.JobGuard // Condition the queue runner checks before starting the job
+	Timeout      string       // Kill the job after this long, once the runner starts it
+	Artifacts    []string     // Globs for output files to record when the job finishes
+	Results      db.ResultSpec
+	Request      placement.Request
+	Resume       string
+	Experiment   string
 }
 
 func queueJob(database *sql.DB, opts queueJobOptions) (int64, error) {
@@ -205,23 +448,99 @@ func queueJob(database *sql.DB, opts queueJobOptions) (int64, error) {
 	if queueName == "" {
 		queueName = defaultQueueName
 	}
+	opts.EnvVars = loadPlacementConfig().HostEnv(opts.Host, opts.EnvVars)
 
-	jobID, err := db.RecordQueued(database, opts.Host, opts.WorkingDir, opts.Command, opts.Description, queueName)
+	if err := db.ValidateTimeout(opts.Timeout); err != nil {
+		return 0, err
+	}
+	if !opts.IgnoreLimits {
+		if err := checkQueueLimit(database, opts.Host, queueName); err != nil {
+			return 0, err
+		}
+		// A disk too full to start a job on is too full to queue one for
+		if err := checkDiskLimit(opts.Host, opts.WorkingDir); err != nil {
+			return 0, err
+		}
+	}
+	autoRelocate(database, opts.Host)
+
+	// The queue runner can only check jobs on its own host, so a job that
+	// waits for a job on another host is held locally, and sync adds it to
+	// the queue once that job finishes
+	held := false
+	if opts.AfterJobID > 0 {
+		if upstream, _ := db.GetJobByID(database, opts.AfterJobID); upstream != nil && upstream.Host != opts.Host {
+			run, skip := (&db.JobDependency{AfterAny: opts.AfterAny}).Resolve(upstream)
+			switch {
+			case skip:
+				return 0, fmt.Errorf("job %d on %s has already failed; use --after-any to run regardless", upstream.ID, upstream.Host)
+			case run:
+				opts.AfterJobID = 0
+			default:
+				held = true
+			}
+		}
+	}
+
+	recordJob := db.RecordQueued
+	if held {
+		recordJob = db.RecordHeld
+	}
+	jobID, err := recordJob(database, opts.Host, opts.WorkingDir, opts.Command, opts.Description, queueName)
 	if err != nil {
 		return 0, fmt.Errorf("record job: %w", err)
 	}
+	saveJobHooks(database, jobID, opts.OnSuccess, opts.OnFailure)
+	saveJobScript(database, jobID, opts.Script)
+	saveJobTags(database, jobID, opts.Tags)
+	saveJobArtifacts(database, jobID, opts.Artifacts)
+	saveJobRequest(database, jobID, opts.Request)
+	saveJobResume(database, jobID, opts.Resume)
+	saveJobExperiment(database, jobID, opts.Experiment)
+	saveJobResultSpec(database, jobID, opts.Results)
+	saveJobEnv(database, jobID, opts.EnvVars)
+	saveJobRemoteUser(database, jobID, opts.Host)
+	if opts.Guard != nil {
+		if err := db.SetJobGuard(database, jobID, *opts.Guard); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save guard for job %d: %v\n", jobID, err)
+		}
+	}
+	settings := queueSettings(opts.Host, opts.Timeout)
+	if err := db.SetJobQueueSettings(database, jobID, settings); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save settings for job %d: %v\n", jobID, err)
+	}
+
+	envVarsB64 := encodeEnvVars(opts.EnvVars)
+	if held {
+		if err := db.AddPendingDependency(database, jobID, opts.AfterJobID, opts.AfterAny, envVarsB64); err != nil {
+			db.DeleteJob(database, jobID)
+			return 0, fmt.Errorf("record dependency: %w", err)
+		}
+	} else if opts.AfterJobID > 0 {
+		if err := db.SetJobDependency(database, jobID, opts.AfterJobID, opts.AfterAny); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save dependency for job %d: %v\n", jobID, err)
+		}
+	}
 
-	mkdirCmd := fmt.Sprintf("mkdir -p %s", queueDir)
+	// Install the host's availability windows with the queue directory, so
+	// that the runner sees config changes without being restarted
+	mkdirCmd := fmt.Sprintf("mkdir -p %s && %s", queueDir, hostAvailability(loadPlacementConfig(), opts.Host).InstallCommand())
 	if _, stderr, err := ssh.Run(opts.Host, mkdirCmd); err != nil {
 		db.DeleteJob(database, jobID)
 		return 0, fmt.Errorf("create queue directory: %s", stderr)
 	}
 
-	queueFile := fmt.Sprintf("%s/%s.queue", queueDir, queueName)
-	envVarsB64 := ""
-	if len(opts.EnvVars) > 0 {
-		envVarsB64 = base64.StdEncoding.EncodeToString([]byte(strings.Join(opts.EnvVars, "\n")))
+	if opts.Script != nil {
+		if _, stderr, err := ssh.Run(opts.Host, opts.Script.UploadCommand()); err != nil {
+			db.DeleteJob(database, jobID)
+			return 0, fmt.Errorf("upload script: %s", stderr)
+		}
+	}
+
+	if held {
+		return jobID, nil
 	}
+
 	afterJobStr := ""
 	if opts.AfterJobID > 0 {
 		afterJobStr = fmt.Sprintf("%d", opts.AfterJobID)
@@ -229,9 +548,8 @@ func queueJob(database *sql.DB, opts queueJobOptions) (int64, error) {
 			afterJobStr = fmt.Sprintf("%d:any", opts.AfterJobID)
 		}
 	}
-	jobLine := fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t%s", jobID, opts.WorkingDir, opts.Command, opts.Description, envVarsB64, afterJobStr)
-	appendCmd := fmt.Sprintf("echo '%s' >> %s", ssh.EscapeForSingleQuotes(jobLine), queueFile)
-	if _, stderr, err := ssh.Run(opts.Host, appendCmd); err != nil {
+	jobLine := queueLine(jobID, opts.WorkingDir, opts.Command, opts.Description, envVarsB64, afterJobStr, opts.Guard, &settings)
+	if _, stderr, err := ssh.Run(opts.Host, session.AppendToQueueCommand(queueName, jobLine)); err != nil {
 		db.DeleteJob(database, jobID)
 		return 0, fmt.Errorf("append to queue: %s", stderr)
 	}
@@ -239,6 +557,219 @@ func queueJob(database *sql.DB, opts queueJobOptions) (int64, error) {
 	return jobID, nil
 }
 
+// jobGuard returns the guard for --if and --if-false, or nil if there is no
+// condition
+func jobGuard(condition, ifFalse string) (*db.JobGuard, error) {
+	if condition == "" {
+		return nil, nil
+	}
+	if err := db.ValidateGuardPolicy(ifFalse); err != nil {
+		return nil, fmt.Errorf("--if-false: %w", err)
+	}
+	return &db.JobGuard{Command: condition, IfFalse: ifFalse}, nil
+}
+
+// queueSettings returns the settings a job queued now on host runs with: its
+// timeout, the Slack settings in effect, which the runner uses instead of its
+// own, and the host's pre_start and post_finish hooks
+func queueSettings(host, timeout string) db.QueueSettings {
+	preStart, postFinish := resolveRemoteHooks(host, "", "")
+	return db.QueueSettings{
+		Timeout:          timeout,
+		SlackNotify:      os.Getenv("REMOTE_JOBS_SLACK_NOTIFY"),
+		SlackMinDuration: os.Getenv("REMOTE_JOBS_SLACK_MIN_DURATION"),
+		SlackVerbose:     os.Getenv("REMOTE_JOBS_SLACK_VERBOSE") == "1",
+		PreStart:         preStart,
+		PostFinish:       postFinish,
+	}
+}
+
+// encodeEnvVars formats environment variables as the env_vars_b64 field of
+// a queue line: base64-encoded VAR=value lines, or "" if there are none
+func encodeEnvVars(envVars []string) string {
+	if len(envVars) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(envVars, "\n")))
+}
+
+// queueLine formats a line of a queue file. Its tab-separated fields are
+// job_id, working_dir, command, description, env_vars_b64, after_job_id,
+// guard_b64, guard_policy, and settings_b64. Without settings, as for jobs
+// queued before they were recorded, the runner uses its own.
+func queueLine(jobID int64, workingDir, command, description, envVarsB64, afterJob string, guard *db.JobGuard, settings *db.QueueSettings) string {
+	line := fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t%s", jobID, workingDir, command, description, envVarsB64, afterJob)
+	guardB64, guardPolicy := "", ""
+	if guard != nil {
+		guardB64, guardPolicy = base64.StdEncoding.EncodeToString([]byte(guard.Command)), guard.IfFalse
+	}
+	if guard != nil || settings != nil {
+		line += fmt.Sprintf("\t%s\t%s", guardB64, guardPolicy)
+	}
+	if settings != nil {
+		line += "\t" + settings.Encode()
+	}
+	return line
+}
+
+// saveJobEnv records a job's environment variables, so it can be compared
+// with other runs
+func saveJobEnv(database *sql.DB, jobID int64, envVars []string) {
+	if len(envVars) == 0 {
+		return
+	}
+	if err := db.SetJobEnv(database, jobID, envVars); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save environment for job %d: %v\n", jobID, err)
+	}
+}
+
+// saveJobRemoteUser records the account a job runs as on its host, so jobs
+// of different accounts on one machine can be told apart
+func saveJobRemoteUser(database *sql.DB, jobID int64, host string) {
+	if err := db.SetJobRemoteUser(database, jobID, ssh.User(host)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save remote user for job %d: %v\n", jobID, err)
+	}
+}
+
+// jobHost returns the host to run commands for a job on, as the account the
+// job was started as (see ssh.WithUser)
+func jobHost(job *db.Job) string {
+	return ssh.WithUser(job.Host, job.RemoteUser)
+}
+
+// jobRemoteUser returns the account a job runs as, for display: the recorded
+// one, unless the job's host already names it
+func jobRemoteUser(database *sql.DB, job *db.Job) string {
+	if strings.Contains(job.Host, "@") {
+		return ""
+	}
+	user, _ := db.JobRemoteUser(database, job.ID)
+	return user
+}
+
+// saveJobSecrets records the names of a job's secrets, so displays can mark
+// them and redact their values
+func saveJobSecrets(database *sql.DB, jobID int64, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	if err := db.SetJobSecrets(database, jobID, names); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save secret names for job %d: %v\n", jobID, err)
+	}
+}
+
+// lookupJobSecrets reads the secrets a job was started with from the
+// keychain again, for restarting or retrying it. It fails if one is no
+// longer there, rather than letting the job run without it.
+func lookupJobSecrets(database *sql.DB, jobID int64) (names []string, values map[string]string, err error) {
+	names, err = db.GetJobSecrets(database, jobID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get secrets of job %d: %w", jobID, err)
+	}
+	values, err = secrets.LookupAll(names)
+	if err != nil {
+		return nil, nil, fmt.Errorf("job %d was started with secrets: %w", jobID, err)
+	}
+	return names, values, nil
+}
+
+// savedJobOptions returns the options to start a job that hasn't started
+// yet with, from everything recorded about it: its environment, hooks,
+// script, tags, artifacts, result spec, placement request, resume command,
+// experiment, and secrets, and for a job queued with settings, its timeout
+// and remote hooks. It doesn't include the job's guard or dependency, which
+// only its queue runner checks.
+func savedJobOptions(database *sql.DB, job *db.Job) (startJobOptions, error) {
+	opts := startJobOptions{
+		Host:        job.Host,
+		WorkingDir:  job.WorkingDir,
+		Command:     job.Command,
+		Description: job.Description,
+		Request:     jobRequest(database, job.ID),
+	}
+	var err error
+	if opts.EnvVars, err = db.GetJobEnv(database, job); err != nil {
+		return opts, fmt.Errorf("get environment of job %d: %w", job.ID, err)
+	}
+	hooks, err := db.GetJobHooks(database, job.ID)
+	if err != nil {
+		return opts, fmt.Errorf("get hooks of job %d: %w", job.ID, err)
+	}
+	opts.OnSuccess, opts.OnFailure = hooks.OnSuccess, hooks.OnFailure
+	if opts.Script, err = savedScript(database, job.ID); err != nil {
+		return opts, fmt.Errorf("get script of job %d: %w", job.ID, err)
+	}
+	if opts.Tags, err = db.GetJobTags(database, job.ID); err != nil {
+		return opts, fmt.Errorf("get tags of job %d: %w", job.ID, err)
+	}
+	if opts.Artifacts, _, err = db.GetArtifactGlobs(database, job.ID); err != nil {
+		return opts, fmt.Errorf("get artifacts of job %d: %w", job.ID, err)
+	}
+	spec, _, err := db.GetResultSpec(database, job.ID)
+	if err != nil {
+		return opts, fmt.Errorf("get result spec of job %d: %w", job.ID, err)
+	}
+	if spec != nil {
+		opts.Results = *spec
+	}
+	if opts.Resume, err = db.GetResumeCommand(database, job.ID); err != nil {
+		return opts, fmt.Errorf("get resume command of job %d: %w", job.ID, err)
+	}
+	if opts.Experiment, err = db.GetJobExperiment(database, job.ID); err != nil {
+		return opts, fmt.Errorf("get experiment of job %d: %w", job.ID, err)
+	}
+	if opts.Secrets, err = db.GetJobSecrets(database, job.ID); err != nil {
+		return opts, fmt.Errorf("get secrets of job %d: %w", job.ID, err)
+	}
+	settings, err := db.GetJobQueueSettings(database, job.ID)
+	if err != nil {
+		return opts, fmt.Errorf("get settings of job %d: %w", job.ID, err)
+	}
+	if settings != nil {
+		opts.Timeout, opts.PreStart, opts.PostFinish = settings.Timeout, settings.PreStart, settings.PostFinish
+	}
+	return opts, nil
+}
+
+// runLaunch runs a job's launch command, passing its secrets, if any, on
+// stdin so their values never appear in a command line (see
+// session.SecretsLaunchCommand)
+func runLaunch(host, launchCommand string, secretValues map[string]string) (string, string, error) {
+	if len(secretValues) == 0 {
+		return ssh.Run(host, launchCommand)
+	}
+	return ssh.RunWithInput(host, launchCommand, secrets.EnvFile(secretValues))
+}
+
+// saveJobBackend records that a job runs without tmux, so that it is probed
+// and killed through its PID file. Jobs in tmux need no record.
+func saveJobBackend(database *sql.DB, jobID int64, backend string) {
+	if backend != session.BackendNohup {
+		return
+	}
+	if err := db.SetJobBackend(database, jobID, backend); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save backend for job %d: %v\n", jobID, err)
+	}
+}
+
+// saveJobGPUs records the GPUs assigned to a job with run --gpus
+func saveJobGPUs(database *sql.DB, jobID int64, gpus []int) {
+	if err := db.SetJobGPUs(database, jobID, gpus); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save GPUs for job %d: %v\n", jobID, err)
+	}
+}
+
+// nohupJobRunning reports whether a job started without tmux is still
+// running or paused, from its status and PID files
+func nohupJobRunning(host string, jobID int64) (bool, error) {
+	stdout, stderr, err := ssh.RunWithRetry(host, session.JobStateCommand(jobID))
+	if err != nil {
+		return false, fmt.Errorf("%s", ssh.FriendlyError(host, stderr, err))
+	}
+	state := strings.TrimSpace(stdout)
+	return state == "RUNNING" || state == "PAUSED", nil
+}
+
 func applyEnvMap(env map[string]string) []string {
 	if len(env) == 0 {
 		return nil
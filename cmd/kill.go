@@ -1,48 +1,133 @@
 package cmd
 
 import (
+	"bufio"
 	"database/sql"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/jobselect"
+	"github.com/osteele/remote-jobs/internal/jobstate"
 	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
 var killCmd = &cobra.Command{
-	Use:   "kill <job-id>...",
+	Use:   "kill [job-id...]",
 	Short: "Kill one or more running jobs",
-	Long: `Kill running jobs by their IDs.
+	Long: `Kill running jobs by their IDs, or select jobs in bulk.
+
+Selectors pick from unfinished (running, starting, paused, and queued) jobs
+and can be combined; each narrows the selection. Before killing jobs picked
+by selectors or matched by description, kill lists them and asks for
+confirmation unless --yes is given.
+
+Instead of a job ID, a job can be given as "latest" or @last, for the most
+recently submitted job; as @running, for the job that most recently started
+running (@running[1] for the one before, and so on); or as (part of) its
+description, such as 'gpt2 ablation'. If several runs have that
+description, the most recent is killed. With --host, these pick from the
+jobs on that host.
 
 Examples:
   remote-jobs kill 42
-  remote-jobs kill 42 43 44`,
-	Args: cobra.MinimumNArgs(1),
+  remote-jobs kill 42 43 44
+  remote-jobs kill @running
+  remote-jobs kill 'gpt2 ablation'
+  remote-jobs kill --host cool30 latest
+  remote-jobs kill --host cool30 --all-running     # Everything on cool30
+  remote-jobs kill --tag sweep42                   # Jobs started with run --tag sweep42
+  remote-jobs kill --older-than 24h --yes          # Jobs started over a day ago`,
 	RunE: runKill,
 }
 
+var (
+	killHost       string
+	killAllRunning bool
+	killTag        string
+	killOlderThan  string
+	killYes        bool
+)
+
 func init() {
 	rootCmd.AddCommand(killCmd)
+	killCmd.Flags().StringVar(&killHost, "host", "", "Only select jobs on this host")
+	killCmd.Flags().BoolVar(&killAllRunning, "all-running", false, "Select all running, starting, and paused jobs (with --host, on that host)")
+	killCmd.Flags().StringVar(&killTag, "tag", "", "Select jobs with this tag")
+	killCmd.Flags().StringVar(&killOlderThan, "older-than", "", "Select jobs started longer ago than this (e.g., 24h, 2d)")
+	killCmd.Flags().BoolVarP(&killYes, "yes", "y", false, "Don't ask for confirmation")
 }
 
 func runKill(cmd *cobra.Command, args []string) error {
+	selecting := killAllRunning || killTag != "" || killOlderThan != ""
+	if len(args) > 0 && selecting {
+		return fmt.Errorf("job IDs cannot be combined with --all-running, --tag, or --older-than")
+	}
+	if len(args) == 0 && !selecting {
+		if killHost != "" {
+			return fmt.Errorf("--host needs --all-running, --tag, or --older-than")
+		}
+		return fmt.Errorf("requires job IDs or a selector (--all-running, --tag, --older-than)")
+	}
+
 	database, err := db.Open()
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
 
+	if selecting {
+		jobs, err := selectJobsToKill(database)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No matching jobs")
+			return nil
+		}
+		printKillSelection(database, jobs)
+		if !killYes && !confirm(fmt.Sprintf("Kill %d job(s)?", len(jobs))) {
+			fmt.Println("Cancelled")
+			return nil
+		}
+		args = nil
+		for _, job := range jobs {
+			args = append(args, strconv.FormatInt(job.ID, 10))
+		}
+	}
+
+	selector := jobSelector{Host: killHost}.resolver(database)
 	var errors []string
+	var jobIDs []int64
+	var matched []*db.Job // Jobs given by description, which are matched loosely
 	for _, arg := range args {
-		jobID, err := strconv.ParseInt(arg, 10, 64)
+		jobID, err := selector.resolve(arg)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("invalid job ID %s", arg))
+			errors = append(errors, err.Error())
 			continue
 		}
+		jobIDs = append(jobIDs, jobID)
+		if jobselect.IsDescription(arg) {
+			if job, err := db.GetJobByID(database, jobID); err == nil && job != nil {
+				matched = append(matched, job)
+			}
+		}
+	}
 
+	if len(matched) > 0 && !killYes {
+		printKillSelection(database, matched)
+		if !confirm(fmt.Sprintf("Kill %d job(s) matched by description?", len(matched))) {
+			fmt.Println("Cancelled")
+			jobIDs = nil
+		}
+	}
+	for _, jobID := range jobIDs {
 		if err := killJob(database, jobID); err != nil {
 			errors = append(errors, fmt.Sprintf("job %d: %v", jobID, err))
 		}
@@ -54,6 +139,77 @@ func runKill(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// selectJobsToKill returns the unfinished jobs matching the kill selectors
+func selectJobsToKill(database *sql.DB) ([]*db.Job, error) {
+	var cutoff int64
+	if killOlderThan != "" {
+		d, err := parseDuration(killOlderThan)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --older-than %q: %w (examples: 24h, 2d)", killOlderThan, err)
+		}
+		cutoff = time.Now().Add(-d).Unix()
+	}
+
+	var tagged map[int64]bool
+	if killTag != "" {
+		var err error
+		tagged, err = db.JobIDsWithTag(database, killTag)
+		if err != nil {
+			return nil, fmt.Errorf("look up tag %s: %w", killTag, err)
+		}
+	}
+
+	jobs, err := db.ListUnfinished(database, killHost)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+
+	var selected []*db.Job
+	for _, job := range jobs {
+		if killAllRunning && job.Status == db.StatusQueued {
+			continue
+		}
+		if tagged != nil && !tagged[job.ID] {
+			continue
+		}
+		// Jobs that haven't started have no age
+		if cutoff > 0 && (job.StartTime <= 0 || job.StartTime > cutoff) {
+			continue
+		}
+		selected = append(selected, job)
+	}
+	return selected, nil
+}
+
+// printKillSelection lists the jobs kill is about to kill
+func printKillSelection(database *sql.DB, jobs []*db.Job) {
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tHOST\tSTATUS\tSTARTED\tCOMMAND / DESCRIPTION")
+	for _, job := range jobs {
+		display := job.Description
+		if display == "" {
+			display = displayCommand(job)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", job.ID, job.Host, job.Status,
+			displayTimes.Short(job.StartTime, jobHostZone(database, job.ID), now), truncate(display, 50))
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+// confirm asks a yes/no question on the terminal; anything but y or yes is no
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
 func killJob(database *sql.DB, jobID int64) error {
 	job, err := db.GetJobByID(database, jobID)
 	if err != nil {
@@ -73,31 +229,47 @@ func killJob(database *sql.DB, jobID int64) error {
 		return killRunningJob(database, job)
 	}
 
+	// Paused jobs can't act on SIGTERM or SIGHUP until they are continued
+	if job.Status == db.StatusPaused {
+		if err := jobstate.Signal(job, "CONT"); err != nil {
+			fmt.Printf("Warning: failed to resume job %d before killing it: %v\n", job.ID, err)
+		}
+		return killRunningJob(database, job)
+	}
+
 	// Job already terminated
 	return fmt.Errorf("job already %s", job.Status)
 }
 
+// removeQueuedJob removes a queued job from its host's queue and marks it
+// dead. A job that its queue runner has taken from the queue since it was
+// last synced is killed instead, and its Status set to running.
 func removeQueuedJob(database *sql.DB, job *db.Job) error {
 	queueName := job.QueueName
 	if queueName == "" {
 		queueName = "default"
 	}
 
-	queueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
 	fmt.Printf("Removing queued job %d from %s on %s...\n", job.ID, queueName, job.Host)
 
 	// Try to remove from queue file
-	removeCmd := fmt.Sprintf("sed -i '/^%d\t/d' %s 2>/dev/null || true", job.ID, queueFile)
-	_, stderr, err := ssh.Run(job.Host, removeCmd)
+	stdout, stderr, err := ssh.Run(jobHost(job), session.RemoveFromQueueCommand(job.ID, queueName))
 
 	if err != nil && ssh.IsConnectionError(stderr) {
 		// Host unreachable - add deferred operation
 		fmt.Printf("Host %s unreachable, will remove on next sync\n", job.Host)
-		if err := db.AddDeferredOperation(database, job.Host, db.OpRemoveQueued, job.ID, queueName); err != nil {
+		if err := db.AddDeferredOperation(database, jobHost(job), db.OpRemoveQueued, job.ID, queueName); err != nil {
 			return fmt.Errorf("add deferred operation: %w", err)
 		}
 	} else if err != nil {
 		return fmt.Errorf("remove from queue file: %s", strings.TrimSpace(stderr))
+	} else if strings.TrimSpace(stdout) == "missing" {
+		// The queue runner took it since the database was last synced
+		if err := db.UpdateQueuedToRunning(database, job.ID); err != nil {
+			return fmt.Errorf("update database: %w", err)
+		}
+		job.Status = db.StatusRunning
+		return killRunningJob(database, job)
 	}
 
 	// Mark job as dead in database
@@ -120,12 +292,12 @@ func killRunningJob(database *sql.DB, job *db.Job) error {
 
 	// Regular jobs have their own tmux sessions
 	tmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-	if err := ssh.TmuxKillSession(job.Host, tmuxSession); err != nil {
+	if err := ssh.TmuxKillSession(jobHost(job), tmuxSession); err != nil {
 		// Check if connection error
 		if ssh.IsConnectionError(err.Error()) {
 			// Host unreachable - add deferred operation
 			fmt.Printf("Host %s unreachable, will kill on next sync\n", job.Host)
-			if err := db.AddDeferredOperation(database, job.Host, db.OpKillJob, job.ID, ""); err != nil {
+			if err := db.AddDeferredOperation(database, jobHost(job), db.OpKillJob, job.ID, ""); err != nil {
 				return fmt.Errorf("add deferred operation: %w", err)
 			}
 			// Mark job as dead in database anyway
@@ -160,13 +332,17 @@ func killQueueRunnerJob(database *sql.DB, job *db.Job) error {
 			echo "not_running"
 		fi
 	`, pidPattern)
+	// Without tmux to end the whole session, kill the job's process tree
+	if jobstate.RunsWithoutTmux(database, job.ID) {
+		killCmd = session.SignalJobCommand(job.ID, "TERM")
+	}
 
-	stdout, stderr, err := ssh.Run(job.Host, killCmd)
+	stdout, stderr, err := ssh.Run(jobHost(job), killCmd)
 
 	if err != nil && ssh.IsConnectionError(stderr) {
 		// Host unreachable - add deferred operation
 		fmt.Printf("Host %s unreachable, will kill on next sync\n", job.Host)
-		if err := db.AddDeferredOperation(database, job.Host, db.OpKillJob, job.ID, ""); err != nil {
+		if err := db.AddDeferredOperation(database, jobHost(job), db.OpKillJob, job.ID, ""); err != nil {
 			return fmt.Errorf("add deferred operation: %w", err)
 		}
 		// Mark job as dead in database anyway
@@ -182,7 +358,7 @@ func killQueueRunnerJob(database *sql.DB, job *db.Job) error {
 	result := strings.TrimSpace(stdout)
 	if result == "not_running" {
 		fmt.Printf("Job %d is not running (already finished)\n", job.ID)
-	} else if result == "killed" {
+	} else if result == "killed" || result == "ok" {
 		fmt.Printf("Job %d killed\n", job.ID)
 	} else {
 		fmt.Printf("Warning: unexpected result: %s\n", result)
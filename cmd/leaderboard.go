@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/results"
+	"github.com/spf13/cobra"
+)
+
+var leaderboardCmd = &cobra.Command{
+	Use:   "leaderboard",
+	Short: "Rank completed jobs by a result metric",
+	Long: `Rank the jobs that completed successfully by one of the result metrics
+recorded with run --result or --results-file, with their commands and the git
+commits they ran, to compare experiments.
+
+The best job is listed first. Metrics whose names suggest lower is better
+(loss, error, perplexity, WER, time) are ranked smallest first, and others
+largest first; --order overrides this.
+
+The TUI shows the same ranking (press L).
+
+Examples:
+  remote-jobs leaderboard --metric accuracy
+  remote-jobs leaderboard --metric accuracy --tag exp42
+  remote-jobs leaderboard --metric eval.loss --host cool30 --limit 5
+  remote-jobs leaderboard --metric bleu --order asc    # Worst first`,
+	Args: cobra.NoArgs,
+	RunE: runLeaderboard,
+}
+
+var (
+	leaderboardMetric string
+	leaderboardTag    string
+	leaderboardHost   string
+	leaderboardOrder  string
+	leaderboardLimit  int
+)
+
+func init() {
+	rootCmd.AddCommand(leaderboardCmd)
+	leaderboardCmd.Flags().StringVarP(&leaderboardMetric, "metric", "m", "", "Result metric to rank by (required)")
+	leaderboardCmd.Flags().StringVarP(&leaderboardTag, "tag", "t", "", "Only rank jobs with this tag")
+	leaderboardCmd.Flags().StringVar(&leaderboardHost, "host", "", "Only rank jobs on this host")
+	leaderboardCmd.Flags().StringVar(&leaderboardOrder, "order", "auto", "Ranking order: auto, asc (smallest first), or desc (largest first)")
+	leaderboardCmd.Flags().IntVar(&leaderboardLimit, "limit", 20, "Show at most this many jobs (0 for all)")
+}
+
+func runLeaderboard(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	names, err := db.ListResultNames(database)
+	if err != nil {
+		return fmt.Errorf("list metrics: %w", err)
+	}
+	if leaderboardMetric == "" {
+		if len(names) == 0 {
+			return fmt.Errorf("--metric is required (no results recorded yet; see run --result)")
+		}
+		return fmt.Errorf("--metric is required (recorded: %s)", strings.Join(names, ", "))
+	}
+
+	var ascending bool
+	switch leaderboardOrder {
+	case "auto":
+		ascending = results.LowerIsBetter(leaderboardMetric)
+	case "asc":
+		ascending = true
+	case "desc":
+	default:
+		return fmt.Errorf("invalid --order %q (use auto, asc, or desc)", leaderboardOrder)
+	}
+
+	jobs, err := db.ListJobsWithResult(database, leaderboardMetric, leaderboardHost, leaderboardTag)
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		if len(names) > 0 && !slices.Contains(names, leaderboardMetric) {
+			return fmt.Errorf("no job recorded %q (recorded: %s)", leaderboardMetric, strings.Join(names, ", "))
+		}
+		fmt.Printf("No completed jobs with %s\n", leaderboardMetric)
+		return nil
+	}
+
+	ids := make([]int64, len(jobs))
+	byID := make(map[int64]*db.Job, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+		byID[job.ID] = job
+	}
+	metrics, err := db.LoadJobResults(database, ids)
+	if err != nil {
+		return fmt.Errorf("load results: %w", err)
+	}
+	entries := results.Rank(ids, metrics, leaderboardMetric, ascending)
+	if leaderboardLimit > 0 && len(entries) > leaderboardLimit {
+		entries = entries[:leaderboardLimit]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "RANK\tID\t%s\tHOST\tCOMMIT\tCOMMAND / DESCRIPTION\n", strings.ToUpper(leaderboardMetric))
+	dirty := false
+	for _, e := range entries {
+		job := byID[e.JobID]
+		commit := "—"
+		if rev, _ := db.GetJobGit(database, job.ID); rev != nil {
+			commit = rev.Short()
+			dirty = dirty || rev.Dirty
+		}
+		display := job.Description
+		if display == "" {
+			display = displayCommand(job)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\n",
+			e.Rank, job.ID, results.FormatValue(e.Value), job.Host, commit, truncate(display, 60))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Println("\n* uncommitted changes")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/diskspace"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// checkRunLimit returns an error if starting a job on host would exceed its
+// configured max_running
+func checkRunLimit(database *sql.DB, host string) error {
+	return checkRunLimitFreeing(database, host, 0)
+}
+
+// checkRestartLimit returns an error if restarting job would exceed its host's
+// configured max_running. A job that is still running gives up its own place.
+func checkRestartLimit(database *sql.DB, job *db.Job) error {
+	freed := 0
+	if job.Status == db.StatusRunning || job.Status == db.StatusStarting {
+		freed = 1
+	}
+	return checkRunLimitFreeing(database, job.Host, freed)
+}
+
+// checkRunLimitFreeing is checkRunLimit for when freed of the host's running
+// jobs are about to end
+func checkRunLimitFreeing(database *sql.DB, host string, freed int) error {
+	limits := loadHostLimits(host)
+	if limits.MaxRunning == 0 {
+		return nil
+	}
+	running, err := db.CountActiveJobs(database, host)
+	if err != nil {
+		return fmt.Errorf("count running jobs: %w", err)
+	}
+	if err := limits.CheckRunning(host, running-freed); err != nil {
+		return fmt.Errorf("%w; use --ignore-limits to start anyway", err)
+	}
+	return nil
+}
+
+// checkQueueLimit returns an error if adding a job to the queue would exceed
+// the host's configured max_queue_depth
+func checkQueueLimit(database *sql.DB, host, queueName string) error {
+	limits := loadHostLimits(host)
+	if limits.MaxQueueDepth == 0 {
+		return nil
+	}
+	depth, err := db.CountQueued(database, host, queueName)
+	if err != nil {
+		return fmt.Errorf("count queued jobs: %w", err)
+	}
+	if err := limits.CheckQueueDepth(host, queueName, depth); err != nil {
+		return fmt.Errorf("%w; use --ignore-limits to queue anyway", err)
+	}
+	return nil
+}
+
+// checkDiskLimit returns an error if the filesystem holding workingDir on host
+// has less free space or fewer free inodes than its configured minimum (see
+// diskspace.CheckHost)
+func checkDiskLimit(host, workingDir string) error {
+	limits := loadHostLimits(host)
+	if err := diskspace.CheckHost(host, workingDir, limits.MinFreeDiskGB, limits.MinFreeInodesPct); err != nil {
+		return fmt.Errorf("%w; use --ignore-limits to override", err)
+	}
+	return nil
+}
+
+// checkGPUContention returns an error if the GPUs a job asks for are already
+// heavily used. A job asks for the GPUs its CUDA_VISIBLE_DEVICES names, or
+// for all of them if it needs GPUs without naming any; a job that does
+// neither isn't checked. The check is skipped if the host can't be reached;
+// starting the job will report that. With force, it warns instead.
+func checkGPUContention(host string, envVars []string, needsGPUs, force bool) error {
+	devices := gpupool.VisibleDevices(envVars)
+	if devices == nil && !needsGPUs {
+		return nil
+	}
+	stdout, _, err := ssh.RunWithTimeout(host, gpupool.ProbeCommand, ssh.HostTimeouts(host).Probe)
+	if err != nil {
+		return nil
+	}
+	contended := gpupool.ContendedGPUs(gpupool.ParseProbe(host, stdout), devices)
+	if len(contended) == 0 {
+		return nil
+	}
+	msg := gpupool.DescribeContention(host, contended)
+	if force {
+		fmt.Fprintf(os.Stderr, "Warning: %s; starting anyway\n", msg)
+		return nil
+	}
+	return fmt.Errorf("%s; use --gpus N to choose free GPUs, or --force to start anyway", msg)
+}
+
+func loadHostLimits(host string) config.Limits {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load config, job limits not checked: %v\n", err)
+		return config.Limits{}
+	}
+	return cfg.HostLimits(host)
+}
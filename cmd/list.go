@@ -4,11 +4,18 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/reachability"
+	"github.com/osteele/remote-jobs/internal/results"
 	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/osteele/remote-jobs/internal/timefmt"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +31,20 @@ Examples:
   remote-jobs list --pending          # Pending jobs
   remote-jobs list --host cool30      # Jobs on cool30
   remote-jobs list --search training  # Search jobs
-  remote-jobs list --show 42          # Job details`,
+  remote-jobs list --show 42          # Job details
+  remote-jobs list --columns id,host,duration,mem,gpu,command
+  remote-jobs list --columns id,result.accuracy,command --sort-by result.accuracy
+
+Columns (for --columns): id, host, status, started, duration, wait, mem, gpu,
+queue, command, results, and result.NAME. "duration" is elapsed time for
+running jobs and total run time for finished ones; "wait" is time spent in a
+queue; "mem" and "gpu" are the last sampled usage of running jobs (sampled
+while the TUI shows a job). "results" shows all of a job's result metrics
+(see run --result), and result.NAME one of them.
+
+--sort-by orders the listed jobs by id, started, duration, or result.NAME,
+largest first; add ":asc" for smallest first. Jobs without the value are
+listed last.`,
 	RunE: runList,
 }
 
@@ -33,6 +53,7 @@ var (
 	listCompleted bool
 	listDead      bool
 	listPending   bool
+	listPaused    bool
 	listHost      string
 	listSearch    string
 	listLimit     int
@@ -40,8 +61,13 @@ var (
 	listCleanup   int
 	listSync      bool
 	listNoSync    bool
+	listColumns   string
+	listSortBy    string
 )
 
+// defaultListColumns are shown when --columns is not given
+const defaultListColumns = "id,host,status,started,duration,command"
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 
@@ -49,6 +75,7 @@ func init() {
 	listCmd.Flags().BoolVar(&listCompleted, "completed", false, "Show only completed jobs")
 	listCmd.Flags().BoolVar(&listDead, "dead", false, "Show only dead jobs")
 	listCmd.Flags().BoolVar(&listPending, "pending", false, "Show only pending jobs")
+	listCmd.Flags().BoolVar(&listPaused, "paused", false, "Show only paused jobs")
 	listCmd.Flags().StringVar(&listHost, "host", "", "Filter by host")
 	listCmd.Flags().StringVar(&listSearch, "search", "", "Search by description or command")
 	listCmd.Flags().IntVar(&listLimit, "limit", 50, "Limit results")
@@ -56,14 +83,26 @@ func init() {
 	listCmd.Flags().IntVar(&listCleanup, "cleanup", 0, "Delete jobs older than N days")
 	listCmd.Flags().BoolVar(&listSync, "sync", false, "Perform full sync (default is fast sync with timeout)")
 	listCmd.Flags().BoolVar(&listNoSync, "no-sync", false, "Skip syncing job statuses before listing")
+	listCmd.Flags().StringVar(&listColumns, "columns", defaultListColumns, "Comma-separated columns to show")
+	listCmd.Flags().StringVar(&listSortBy, "sort-by", "", "Order jobs by id, started, duration, or result.NAME (largest first; append :asc for smallest first)")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	columns, err := parseListColumns(listColumns)
+	if err != nil {
+		return err
+	}
+	sortBy, err := parseListSort(listSortBy)
+	if err != nil {
+		return err
+	}
+
 	database, err := db.Open()
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
+	defer waitForDeliveries()
 
 	// Sync logic: fast sync by default, full sync with --sync, skip with --no-sync
 	if !listNoSync {
@@ -105,11 +144,11 @@ func runList(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("search: %w", err)
 		}
-		return printJobs(jobs)
+		return printJobs(database, jobs, columns, sortBy)
 	}
 
 	// Determine status filter
-	var status string
+	var status db.Status
 	if listRunning {
 		status = db.StatusRunning
 	} else if listCompleted {
@@ -118,6 +157,8 @@ func runList(cmd *cobra.Command, args []string) error {
 		status = db.StatusDead
 	} else if listPending {
 		status = db.StatusPending
+	} else if listPaused {
+		status = db.StatusPaused
 	}
 
 	jobs, err := db.ListJobs(database, status, listHost, listLimit)
@@ -125,7 +166,7 @@ func runList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("list jobs: %w", err)
 	}
 
-	return printJobs(jobs)
+	return printJobs(database, jobs, columns, sortBy)
 }
 
 func showJob(database *sql.DB, id int64) error {
@@ -139,60 +180,318 @@ func showJob(database *sql.DB, id int64) error {
 
 	fmt.Printf("Job ID:       %d\n", job.ID)
 	fmt.Printf("Host:         %s\n", job.Host)
+	if user := jobRemoteUser(database, job); user != "" {
+		fmt.Printf("User:         %s\n", user)
+	}
 	fmt.Printf("Working Dir:  %s\n", job.EffectiveWorkingDir())
-	fmt.Printf("Command:      %s\n", job.EffectiveCommand())
+	fmt.Printf("Command:      %s\n", displayCommand(job))
+	if script, err := db.GetJobScript(database, job.ID); err == nil && script != nil {
+		fmt.Printf("Script:       %s (sha256 %s)\n", script.Name, script.SHA256[:12])
+	}
 	if job.Description != "" {
 		fmt.Printf("Description:  %s\n", job.Description)
 	}
+	if tags, err := db.GetJobTags(database, job.ID); err == nil && len(tags) > 0 {
+		fmt.Printf("Tags:         %s\n", strings.Join(tags, ", "))
+	}
+	if experiment, err := db.GetJobExperiment(database, job.ID); err == nil && experiment != "" {
+		fmt.Printf("Experiment:   %s\n", experiment)
+	}
+	request := jobRequest(database, job.ID)
+	if !request.Needs.IsZero() {
+		fmt.Printf("Needs:        %s\n", request.Needs.Describe())
+	}
+	if len(request.Require) > 0 {
+		fmt.Printf("Requires:     %s\n", strings.Join(request.Require, ", "))
+	}
+	if len(request.Avoid) > 0 {
+		fmt.Printf("Avoids:       %s\n", strings.Join(request.Avoid, ", "))
+	}
+	if gpus, err := db.GetJobGPUs(database, job.ID); err == nil && len(gpus) > 0 {
+		fmt.Printf("GPUs:         %s (assigned with --gpus)\n", gpupool.FormatIndices(gpus))
+	}
 	fmt.Printf("Status:       %s\n", job.Status)
-	fmt.Printf("Start Time:   %s\n", time.Unix(job.StartTime, 0).Format("2006-01-02 15:04:05"))
+	zone := jobHostZone(database, job.ID)
+	fmt.Printf("Start Time:   %s\n", displayTimes.Full(job.StartTime, zone))
 	if job.EndTime != nil {
-		fmt.Printf("End Time:     %s\n", time.Unix(*job.EndTime, 0).Format("2006-01-02 15:04:05"))
+		fmt.Printf("End Time:     %s\n", displayTimes.Full(*job.EndTime, zone))
 		duration := *job.EndTime - job.StartTime
-		fmt.Printf("Duration:     %s\n", db.FormatDuration(duration))
+		fmt.Printf("Duration:     %s\n", humanfmt.Duration(duration))
 	}
 	if job.ExitCode != nil {
 		fmt.Printf("Exit Code:    %d\n", *job.ExitCode)
 	}
+	if skew, ok, err := db.JobClockSkew(database, job.ID); err == nil && ok && skew != 0 {
+		fmt.Printf("Clock Skew:   %+ds (host clock minus local clock at start)\n", skew)
+	}
+	printLineage(database, job.ID)
+	printArtifacts(database, job.ID)
+	printResults(database, job.ID)
+	if changes, err := db.GetStatusLog(database, job.ID); err == nil && len(changes) > 0 {
+		fmt.Println("\nStatus History:")
+		for _, c := range changes {
+			if c.From == "" {
+				fmt.Printf("  %s  created %s\n", displayTimes.Full(c.At, zone), c.To)
+			} else {
+				fmt.Printf("  %s  %s → %s\n", displayTimes.Full(c.At, zone), c.From, c.To)
+			}
+		}
+	}
+	if note, err := db.GetJobNote(database, job.ID); err == nil && note != nil {
+		fmt.Printf("\nNotes (%s):\n", displayTimes.Full(note.UpdatedAt, zone))
+		for _, line := range strings.Split(note.Text, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
 
 	return nil
 }
 
-func printJobs(jobs []*db.Job) error {
-	if len(jobs) == 0 {
-		fmt.Println("No jobs found")
-		return nil
-	}
-
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tHOST\tSTATUS\tSTARTED\tCOMMAND / DESCRIPTION")
-
-	for _, job := range jobs {
-		started := "—"
-		if job.StartTime > 0 {
-			started = time.Unix(job.StartTime, 0).Format("01/02 15:04")
-		}
+// listColumn is a column that can be selected with list --columns
+type listColumn struct {
+	header string
+	value  func(job *db.Job, stats *db.JobStats, now int64) string
+}
 
-		status := job.Status
+var listColumnDefs = map[string]listColumn{
+	"id": {"ID", func(job *db.Job, _ *db.JobStats, _ int64) string {
+		return fmt.Sprintf("%d", job.ID)
+	}},
+	"host": {"HOST", func(job *db.Job, _ *db.JobStats, _ int64) string {
+		return job.Host
+	}},
+	"status": {"STATUS", func(job *db.Job, stats *db.JobStats, _ int64) string {
 		if job.Status == db.StatusCompleted && job.ExitCode != nil {
 			if *job.ExitCode == 0 {
-				status = "completed ✓"
-			} else {
-				status = fmt.Sprintf("failed (%d)", *job.ExitCode)
+				return "completed ✓"
+			}
+			if job.Skipped() {
+				return "skipped"
 			}
+			return fmt.Sprintf("failed (%d)", *job.ExitCode)
 		}
-
+		if job.Status == db.StatusRunning && stats.IdleGPU() {
+			return "running ⚠ GPU idle"
+		}
+		return string(job.Status)
+	}},
+	"started": {"STARTED", func(job *db.Job, stats *db.JobStats, now int64) string {
+		var zone *time.Location
+		if stats != nil {
+			zone = timefmt.HostZone(stats.HostUTCOffset)
+		}
+		return displayTimes.WithDefaultStyle(timefmt.StyleAbsolute).Short(job.StartTime, zone, time.Unix(now, 0))
+	}},
+	"duration": {"DURATION", func(job *db.Job, _ *db.JobStats, now int64) string {
+		return humanfmt.DurationShort(job.Elapsed(now))
+	}},
+	"wait": {"WAIT", func(job *db.Job, stats *db.JobStats, now int64) string {
+		return humanfmt.DurationShort(stats.QueueWait(job, now))
+	}},
+	"mem": {"MEM", func(job *db.Job, stats *db.JobStats, _ int64) string {
+		if job.Status != db.StatusRunning || stats == nil || stats.MemoryRSS == "" {
+			return "—"
+		}
+		return stats.MemoryRSS
+	}},
+	"gpu": {"GPU", func(job *db.Job, stats *db.JobStats, _ int64) string {
+		if job.Status != db.StatusRunning || stats == nil || stats.GPUMemory == "" {
+			return "—"
+		}
+		return stats.GPUMemory
+	}},
+	"queue": {"QUEUE", func(job *db.Job, _ *db.JobStats, _ int64) string {
+		if job.QueueName == "" {
+			return "—"
+		}
+		return job.QueueName
+	}},
+	"command": {"COMMAND / DESCRIPTION", func(job *db.Job, _ *db.JobStats, _ int64) string {
 		// Show description if available, otherwise truncated command
 		display := job.Description
 		if display == "" {
-			display = job.EffectiveCommand()
+			display = displayCommand(job)
 		}
 		if len(display) > 40 {
 			display = display[:39] + "…"
 		}
+		return display
+	}},
+}
+
+// resultColumnPrefix names a result metric as a list column or sort key,
+// e.g. "result.accuracy"
+const resultColumnPrefix = "result."
+
+// resultMetric returns the metric a "result.NAME" column or sort key names.
+// Metric names keep their case.
+func resultMetric(name string) (string, bool) {
+	if len(name) <= len(resultColumnPrefix) || !strings.EqualFold(name[:len(resultColumnPrefix)], resultColumnPrefix) {
+		return "", false
+	}
+	return name[len(resultColumnPrefix):], true
+}
+
+// resultColumnValue returns the value of the "results" or a "result.NAME"
+// column
+func resultColumnValue(name string, metrics map[string]float64) string {
+	if metric, ok := resultMetric(name); ok {
+		if value, ok := metrics[metric]; ok {
+			return results.FormatValue(value)
+		}
+		return "—"
+	}
+	if len(metrics) == 0 {
+		return "—"
+	}
+	return results.Format(metrics)
+}
+
+// parseListColumns validates a comma-separated --columns value
+func parseListColumns(spec string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if metric, ok := resultMetric(name); ok {
+			columns = append(columns, resultColumnPrefix+metric)
+			continue
+		}
+		name = strings.ToLower(name)
+		if name == "" {
+			continue
+		}
+		if name == "results" {
+			columns = append(columns, name)
+			continue
+		}
+		if name == "description" {
+			name = "command"
+		}
+		if _, ok := listColumnDefs[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q (available: id, host, status, started, duration, wait, mem, gpu, queue, command, results, result.NAME)", name)
+		}
+		columns = append(columns, name)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("--columns must name at least one column")
+	}
+	return columns, nil
+}
+
+// listSort is a parsed list --sort-by value
+type listSort struct {
+	key       string
+	ascending bool
+}
+
+// parseListSort validates a --sort-by value, returning nil if it's empty
+func parseListSort(spec string) (*listSort, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	s := &listSort{key: spec}
+	if key, order, ok := strings.Cut(spec, ":"); ok {
+		switch strings.ToLower(order) {
+		case "asc":
+			s.ascending = true
+		case "desc":
+		default:
+			return nil, fmt.Errorf("invalid sort order %q (use asc or desc)", order)
+		}
+		s.key = key
+	}
+	if metric, ok := resultMetric(s.key); ok {
+		s.key = resultColumnPrefix + metric
+		return s, nil
+	}
+	s.key = strings.ToLower(s.key)
+	switch s.key {
+	case "id", "started", "duration":
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown sort key %q (available: id, started, duration, result.NAME)", s.key)
+}
+
+// value returns a job's value for the sort key, and false if it has none
+func (s *listSort) value(job *db.Job, metrics map[string]float64, now int64) (float64, bool) {
+	if metric, ok := resultMetric(s.key); ok {
+		value, ok := metrics[metric]
+		return value, ok
+	}
+	switch s.key {
+	case "id":
+		return float64(job.ID), true
+	case "started":
+		return float64(job.StartTime), job.StartTime > 0
+	case "duration":
+		elapsed := job.Elapsed(now)
+		return float64(elapsed), elapsed >= 0
+	}
+	return 0, false
+}
+
+// sortJobs orders jobs by s, with jobs that have no value for it last
+func (s *listSort) sortJobs(jobs []*db.Job, metrics map[int64]map[string]float64, now int64) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		a, aok := s.value(jobs[i], metrics[jobs[i].ID], now)
+		b, bok := s.value(jobs[j], metrics[jobs[j].ID], now)
+		if aok != bok {
+			return aok
+		}
+		if s.ascending {
+			return a < b
+		}
+		return a > b
+	})
+}
+
+func printJobs(database *sql.DB, jobs []*db.Job, columns []string, sortBy *listSort) error {
+	if len(jobs) == 0 {
+		fmt.Println("No jobs found")
+		return nil
+	}
+
+	ids := make([]int64, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	stats, err := db.LoadJobStats(database, ids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load job stats: %v\n", err)
+		stats = map[int64]*db.JobStats{}
+	}
+	metrics, err := db.LoadJobResults(database, ids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load job results: %v\n", err)
+		metrics = map[int64]map[string]float64{}
+	}
+	now := time.Now().Unix()
+	if sortBy != nil {
+		sortBy.sortJobs(jobs, metrics, now)
+	}
 
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
-			job.ID, job.Host, status, started, display)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, name := range columns {
+		if def, ok := listColumnDefs[name]; ok {
+			headers[i] = def.header
+		} else {
+			headers[i] = strings.ToUpper(strings.TrimPrefix(name, resultColumnPrefix))
+		}
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+
+	for _, job := range jobs {
+		values := make([]string, len(columns))
+		for i, name := range columns {
+			if def, ok := listColumnDefs[name]; ok {
+				values[i] = def.value(job, stats[job.ID], now)
+			} else {
+				values[i] = resultColumnValue(name, metrics[job.ID])
+			}
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
 	}
 
 	return w.Flush()
@@ -209,9 +508,11 @@ func performListSync(database *sql.DB) error {
 		return nil
 	}
 
+	reachability.LoadLatencies(database)
 	var updated int
 	for _, host := range hosts {
 		hostUpdated, err := syncHost(database, host)
+		reachability.Record(database, host, err, time.Now())
 		if err != nil {
 			// Silently skip connection errors, warn on others
 			if !ssh.IsConnectionError(err.Error()) {
@@ -229,15 +530,23 @@ func performListSync(database *sql.DB) error {
 	return nil
 }
 
-// startQueueRunnersForQueuedHosts starts queue runners on hosts that have queued jobs
+// startQueueRunnersForQueuedHosts starts queue runners on hosts that have
+// queued jobs, except in read-only mode, which starts nothing on hosts
 func startQueueRunnersForQueuedHosts(database *sql.DB) {
+	if readOnly {
+		return
+	}
 	hosts, err := db.ListHostsWithQueuedJobs(database)
 	if err != nil {
 		return // Silently ignore errors
 	}
 
 	for _, host := range hosts {
+		if reachability.Skip(database, host, time.Now()) {
+			continue
+		}
 		started, err := ensureQueueRunnerStarted(host, defaultQueueName)
+		reachability.Record(database, host, err, time.Now())
 		if err != nil {
 			// Silently ignore - host might be unreachable
 			continue
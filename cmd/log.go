@@ -1,19 +1,25 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
-	"os/exec"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/osteele/remote-jobs/internal/clockskew"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/redact"
+	"github.com/osteele/remote-jobs/internal/secrets"
 	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
 var logCmd = &cobra.Command{
-	Use:     "log <job-id>",
+	Use:     "log [job-id]",
 	Aliases: []string{"logs"},
 	Short:   "View log output from a remote job",
 	Long: `View the log file for a specific remote job.
@@ -25,9 +31,24 @@ Examples:
   remote-jobs log 25 --from 50           # Lines from 50 onwards
   remote-jobs log 25 --from 50 --to 100  # Lines 50-100
   remote-jobs log 25 --to 100            # First 100 lines
+  remote-jobs log 25 --since-line 200    # Lines after line 200
   remote-jobs log 25 --grep error        # Lines containing "error"
-  remote-jobs log 25 -f --grep epoch     # Follow, filter for "epoch"`,
-	Args: cobra.ExactArgs(1),
+  remote-jobs log 25 --grep error --head 5  # First 5 lines containing "error"
+  remote-jobs log 25 -f --grep epoch     # Follow, filter for "epoch"
+  remote-jobs log 25 --until-exit        # Follow until the job finishes,
+                                         # then exit with its exit code
+  remote-jobs log 25 --stderr            # Only stderr (run --split-stderr)
+  remote-jobs log latest -f              # Follow the most recent job
+  remote-jobs log @failed                # The job that failed most recently
+  remote-jobs log 'gpt2 ablation'        # The job with this description
+  remote-jobs log --host cool30 --running  # The latest running job on cool30
+
+` + selectorHelp + ` Without a job ID, --host and --running select the
+latest job they match.
+
+Line selection, filtering, and following run on the remote host, so only the
+lines shown are transferred.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runLog,
 }
 
@@ -37,8 +58,18 @@ var (
 	logFrom   int
 	logTo     int
 	logGrep   string
+
+	logSinceLine int
+	logHead      int
+	logUntilExit bool
+	logStderr    bool
+	logSelector  jobSelector
 )
 
+// untilExitGrace is how many seconds log --until-exit waits for a job whose
+// process is gone to record its exit code
+const untilExitGrace = 30
+
 func init() {
 	rootCmd.AddCommand(logCmd)
 
@@ -47,21 +78,27 @@ func init() {
 	logCmd.Flags().IntVar(&logFrom, "from", 0, "Show lines starting from line N")
 	logCmd.Flags().IntVar(&logTo, "to", 0, "Show lines up to line N")
 	logCmd.Flags().StringVar(&logGrep, "grep", "", "Filter lines matching pattern")
+	logCmd.Flags().IntVar(&logSinceLine, "since-line", 0, "Show lines after line N (the number of lines already read)")
+	logCmd.Flags().IntVar(&logHead, "head", 0, "Show only the first N lines selected (after --grep)")
+	logCmd.Flags().BoolVar(&logUntilExit, "until-exit", false, "Follow the log until the job finishes, then exit with its exit code")
+	logCmd.Flags().BoolVar(&logStderr, "stderr", false, "Show only the job's stderr (for jobs started with run --split-stderr)")
+	logSelector.addFlags(logCmd)
 }
 
 func runLog(cmd *cobra.Command, args []string) error {
-	jobID, err := strconv.ParseInt(args[0], 10, 64)
-	if err != nil {
-		return fmt.Errorf("invalid job ID: %s", args[0])
-	}
-
 	// Validate flag combinations
-	hasLineRange := logFrom > 0 || logTo > 0
+	if logSinceLine > 0 && logFrom > 0 {
+		return fmt.Errorf("--since-line cannot be used with --from")
+	}
+	hasLineRange := logFrom > 0 || logTo > 0 || logSinceLine > 0
 	if hasLineRange && cmd.Flags().Changed("lines") {
-		return fmt.Errorf("--from/--to cannot be used with -n/--lines")
+		return fmt.Errorf("--from/--to/--since-line cannot be used with -n/--lines")
 	}
-	if logFollow && logTo > 0 {
-		return fmt.Errorf("--follow cannot be used with --to")
+	if logHead > 0 && cmd.Flags().Changed("lines") {
+		return fmt.Errorf("--head cannot be used with -n/--lines")
+	}
+	if (logFollow || logUntilExit) && logTo > 0 {
+		return fmt.Errorf("--follow and --until-exit cannot be used with --to")
 	}
 
 	database, err := db.Open()
@@ -70,6 +107,10 @@ func runLog(cmd *cobra.Command, args []string) error {
 	}
 	defer database.Close()
 
+	jobID, err := logSelector.resolveOne(database, args)
+	if err != nil {
+		return err
+	}
 	job, err := db.GetJobByID(database, jobID)
 	if err != nil {
 		return fmt.Errorf("get job: %w", err)
@@ -88,28 +129,57 @@ func runLog(cmd *cobra.Command, args []string) error {
 		logFile = session.LogFile(jobID, job.StartTime)
 	}
 
+	if logStderr {
+		if job.SessionName != "" {
+			return fmt.Errorf("job %d was started by an older version, which didn't capture stderr separately", jobID)
+		}
+		logFile = session.ErrFile(jobID, job.StartTime)
+	}
+
 	// Check if log file exists
-	exists, err := ssh.RemoteFileExists(job.Host, logFile)
+	exists, err := ssh.RemoteFileExists(jobHost(job), logFile)
 	if err != nil {
 		return fmt.Errorf("check log file: %w", err)
 	}
+	if !exists && logStderr {
+		return fmt.Errorf("job %d's stderr wasn't captured separately (start it with run --split-stderr for that)", jobID)
+	}
 	if !exists {
 		return fmt.Errorf("log file not found: %s:%s", job.Host, logFile)
 	}
 
 	// Build the remote command based on flags
 	remoteCmd := buildLogCommand(logFile)
+	if logUntilExit {
+		if job.SessionName != "" {
+			return fmt.Errorf("--until-exit isn't supported for job %d, which was started by an older version", jobID)
+		}
+		remoteCmd = untilExitCommand(remoteCmd, jobID)
+	}
+
+	// Values of the job's secrets, and anything the redact patterns match,
+	// are hidden if the job printed them
+	secretValues := jobSecretValues(database, jobID)
+	redactLog := func(s string) string {
+		return redactText(secrets.Redact(s, secretValues))
+	}
 
-	if logFollow {
+	if logFollow || logUntilExit {
 		// Follow mode - use interactive SSH
-		sshCmd := exec.Command("ssh", job.Host, remoteCmd)
-		sshCmd.Stdout = os.Stdout
+		out := redact.NewWriter(os.Stdout, redactLog)
+		sshCmd := ssh.Command(jobHost(job), remoteCmd)
+		sshCmd.Stdout = out
 		sshCmd.Stderr = os.Stderr
-		return sshCmd.Run()
+		err := sshCmd.Run()
+		out.Flush()
+		if err != nil || !logUntilExit {
+			return err
+		}
+		exit(finishedJobExitCode(database, job))
 	}
 
 	// Regular mode
-	stdout, stderr, err := ssh.Run(job.Host, remoteCmd)
+	stdout, stderr, err := ssh.Run(jobHost(job), remoteCmd)
 	if err != nil {
 		if stderr != "" {
 			return fmt.Errorf("read log: %s", stderr)
@@ -117,36 +187,49 @@ func runLog(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("read log: %w", err)
 	}
 
-	fmt.Print(stdout)
+	fmt.Print(redactLog(stdout))
 	return nil
 }
 
 // buildLogCommand constructs the remote command for reading log files
-// based on the provided flags (--from, --to, -n, --grep, -f)
+// based on the provided flags (--from, --to, --since-line, -n, --grep,
+// --head, -f)
 func buildLogCommand(logFile string) string {
 	var cmd string
+	follow := logFollow || logUntilExit
+	from := logFrom
+	if logSinceLine > 0 {
+		from = logSinceLine + 1
+	}
 
 	// Determine line selection strategy
-	// Priority: --from/--to > -n (default)
-	if logFrom > 0 && logTo > 0 {
+	// Priority: --from/--to > --head (from the start) > -n (default)
+	if from > 0 && logTo > 0 {
 		// Lines from N to M: tail -n +N | head -n (M-N+1)
-		count := logTo - logFrom + 1
+		count := logTo - from + 1
 		if count < 1 {
 			count = 1
 		}
-		cmd = fmt.Sprintf("tail -n +%d %s | head -n %d", logFrom, logFile, count)
-	} else if logFrom > 0 {
+		cmd = fmt.Sprintf("tail -n +%d %s | head -n %d", from, logFile, count)
+	} else if from > 0 {
 		// Lines from N onwards
-		if logFollow {
+		if follow {
 			// For follow mode with --from: get from line N then follow
-			cmd = fmt.Sprintf("tail -n +%d -f %s", logFrom, logFile)
+			cmd = fmt.Sprintf("tail -n +%d -f %s", from, logFile)
 		} else {
-			cmd = fmt.Sprintf("tail -n +%d %s", logFrom, logFile)
+			cmd = fmt.Sprintf("tail -n +%d %s", from, logFile)
 		}
 	} else if logTo > 0 {
 		// First N lines (up to line N)
 		cmd = fmt.Sprintf("head -n %d %s", logTo, logFile)
-	} else if logFollow {
+	} else if logHead > 0 {
+		// --head counts from the start of the log
+		if follow {
+			cmd = fmt.Sprintf("tail -n +1 -f %s", logFile)
+		} else {
+			cmd = fmt.Sprintf("cat %s", logFile)
+		}
+	} else if follow {
 		// Follow mode with default or -n lines
 		cmd = fmt.Sprintf("tail -n %d -f %s", logLines, logFile)
 	} else {
@@ -156,27 +239,52 @@ func buildLogCommand(logFile string) string {
 
 	// Add grep filter if specified
 	if logGrep != "" {
-		if logFollow {
+		if follow {
 			// Use --line-buffered for real-time grep output
-			cmd = fmt.Sprintf("%s | grep --line-buffered '%s'", cmd, escapeShellArg(logGrep))
+			cmd = fmt.Sprintf("%s | grep --line-buffered %s", cmd, shellquote.Quote(logGrep))
 		} else {
-			cmd = fmt.Sprintf("%s | grep '%s'", cmd, escapeShellArg(logGrep))
+			cmd = fmt.Sprintf("%s | grep %s", cmd, shellquote.Quote(logGrep))
 		}
 	}
 
+	// Limit the output last, so --head counts the lines that matched
+	if logHead > 0 {
+		cmd = fmt.Sprintf("%s | head -n %d", cmd, logHead)
+	}
+
 	return cmd
 }
 
-// escapeShellArg escapes a string for use in single quotes in shell
-func escapeShellArg(s string) string {
-	// Replace single quotes with '\'' (end quote, escaped quote, start quote)
-	result := ""
-	for _, c := range s {
-		if c == '\'' {
-			result += "'\\''"
-		} else {
-			result += string(c)
+// untilExitCommand runs logCmd, a command that follows a job's log, in the
+// background until the job finishes, then stops it. A short pause lets the
+// last lines through first. The command succeeds whatever logCmd exits with
+// (grep fails when nothing matched), so a failure is one of SSH's.
+func untilExitCommand(logCmd string, jobID int64) string {
+	return fmt.Sprintf(`{ %s; } & rj_log=$!; %s; sleep 2; `+
+		`pkill -P $rj_log 2>/dev/null; kill $rj_log 2>/dev/null; wait; true`,
+		logCmd, session.WaitForExitCommand(jobID, untilExitGrace))
+}
+
+// finishedJobExitCode returns the exit code a job recorded once it finished,
+// recording its completion if the database still has it running, or
+// ExitFailed if it recorded none
+func finishedJobExitCode(database *sql.DB, job *db.Job) int {
+	statusFile := session.JobStatusFile(job.ID, job.StartTime, job.SessionName)
+	content, mtime, err := ssh.ReadStatusFile(jobHost(job), statusFile, ssh.HostTimeouts(job.Host).Sync)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Job %d: read status file: %v\n", job.ID, err)
+		return ExitFailed
+	}
+	if content == "" {
+		fmt.Fprintf(os.Stderr, "Job %d stopped without recording an exit code\n", job.ID)
+		return ExitFailed
+	}
+	exitCode, _ := strconv.Atoi(strings.TrimSpace(content))
+	if job.Status == db.StatusRunning {
+		endTime := clockskew.EndTime(database, job, mtime, time.Now())
+		if err := db.RecordCompletionByID(database, job.ID, exitCode, endTime); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update database: %v\n", err)
 		}
 	}
-	return result
+	return exitCode
 }
@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/artifacts"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+// checkpointPlaceholder is replaced in a resume command by the checkpoint's path
+const checkpointPlaceholder = "{checkpoint}"
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <job-id> <new-host>",
+	Short: "Move a job to another host, resuming from its latest checkpoint",
+	Long: `Move a job to another host, for when its host must be vacated.
+
+The job must have been started with --resume-cmd and --artifact. migrate
+stops it with SIGTERM (so it can save a checkpoint), waiting up to --grace
+before killing it; copies the newest file matching its artifact globs to the
+same place on the new host; and starts the resume command there, with
+{checkpoint} replaced by the checkpoint's path relative to the working
+directory. The new job keeps the old one's settings, and records which job
+it continues ('job list --show' shows both ends).
+
+Examples:
+  remote-jobs run --artifact 'ckpt/*.pt' --resume-cmd 'python train.py --resume {checkpoint}' \
+      cool30 'python train.py'
+  remote-jobs migrate 42 cool31
+  remote-jobs migrate --grace 5m 42 cool31`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMigrate,
+}
+
+var (
+	migrateGrace time.Duration
+	migrateForce bool
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().DurationVar(&migrateGrace, "grace", 2*time.Minute, "How long the job has to exit after SIGTERM before it is killed")
+	migrateCmd.Flags().BoolVar(&migrateForce, "force", false, "Start the job even if the GPUs it would use on the new host are heavily used by other processes")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	newHost := args[1]
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	jobID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get job %d: %w", jobID, err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %d not found", jobID)
+	}
+	if job.Host == newHost {
+		return fmt.Errorf("job %d is already on %s", jobID, newHost)
+	}
+	if job.Status == db.StatusQueued || job.Status == db.StatusPending {
+		return fmt.Errorf("job %d hasn't started; use 'remote-jobs job move' to move it", jobID)
+	}
+
+	resume, err := db.GetResumeCommand(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get resume command: %w", err)
+	}
+	if resume == "" {
+		return fmt.Errorf("job %d has no resume command (start jobs with --resume-cmd to migrate them)", jobID)
+	}
+	globs, _, err := db.GetArtifactGlobs(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get artifacts: %w", err)
+	}
+	if len(globs) == 0 {
+		return fmt.Errorf("job %d has no artifacts to find its checkpoint in (start jobs with --artifact to migrate them)", jobID)
+	}
+	request := jobRequest(database, jobID)
+	if err := checkHostFit(database, newHost, request); err != nil {
+		return err
+	}
+	// The old host's env defaults give way to the new host's
+	envVars, _ := db.GetJobEnv(database, job)
+	envVars = loadPlacementConfig().WithoutHostEnv(job.Host, envVars)
+	// Check before the job is stopped; startJob checks again
+	if err := checkGPUContention(newHost, loadPlacementConfig().HostEnv(newHost, envVars), request.Needs.GPUs > 0, migrateForce); err != nil {
+		return err
+	}
+
+	if !job.Status.Terminal() {
+		if err := stopJobGracefully(database, job, migrateGrace); err != nil {
+			return err
+		}
+	}
+
+	// Find and copy the newest checkpoint
+	workingDir := job.EffectiveWorkingDir()
+	stdout, stderr, err := ssh.Run(jobHost(job), artifacts.LatestCommand(workingDir, globs))
+	if err != nil {
+		return fmt.Errorf("find checkpoint: %s", ssh.FriendlyError(job.Host, stderr, err))
+	}
+	found := artifacts.ParseListing(stdout)
+	if len(found) == 0 {
+		return fmt.Errorf("no checkpoint of job %d matches %s on %s; the job is stopped, and can be rerun with 'remote-jobs run --from %d %s'",
+			jobID, strings.Join(globs, ", "), job.Host, jobID, newHost)
+	}
+	checkpoint := found[0]
+	dest, ref := checkpointDestination(workingDir, globs, checkpoint)
+	fmt.Printf("Copying checkpoint %s (%s) from %s to %s...\n", checkpoint.Name, humanfmt.Bytes(checkpoint.Size), job.Host, newHost)
+	if err := copyBetweenHosts(job.Host, checkpoint.Path, newHost, dest); err != nil {
+		return fmt.Errorf("copy checkpoint: %w", err)
+	}
+
+	// Start the resume command with the old job's settings
+	secretNames, _ := db.GetJobSecrets(database, jobID)
+	tags, _ := db.GetJobTags(database, jobID)
+	experiment, _ := db.GetJobExperiment(database, jobID)
+	hooks, _ := db.GetJobHooks(database, jobID)
+	var results db.ResultSpec
+	if spec, _, _ := db.GetResultSpec(database, jobID); spec != nil {
+		results = *spec
+	}
+	command := strings.ReplaceAll(resume, checkpointPlaceholder, shellquote.Quote(ref))
+	result, err := startJob(database, startJobOptions{
+		Host:        newHost,
+		WorkingDir:  job.WorkingDir,
+		Command:     command,
+		Description: job.Description,
+		EnvVars:     envVars,
+		Mkdir:       true,
+		OnSuccess:   hooks.OnSuccess,
+		OnFailure:   hooks.OnFailure,
+		Tags:        tags,
+		Artifacts:   globs,
+		Results:     results,
+		Secrets:     secretNames,
+		Request:     request,
+		Resume:      resume,
+		Experiment:  experiment,
+		Force:       migrateForce,
+	})
+	if err != nil {
+		return err
+	}
+	if result.QueuedOnConnectionFailure {
+		return fmt.Errorf("could not connect to %s; job %d was recorded as pending (start it with 'remote-jobs retry %d')",
+			newHost, result.Info.JobID, result.Info.JobID)
+	}
+
+	newID := result.Info.JobID
+	err = db.RecordMigration(database, db.Migration{
+		JobID:      newID,
+		FromJobID:  jobID,
+		FromHost:   job.Host,
+		Checkpoint: ref,
+		MigratedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record that job %d continues job %d: %v\n", newID, jobID, err)
+	}
+
+	fmt.Printf("✓ Job %d migrated to %s as job %d\n", jobID, newHost, newID)
+	fmt.Printf("Command: %s\n", command)
+	fmt.Printf("\nView log:\n  remote-jobs log %d -f\n", newID)
+	return nil
+}
+
+// stopStatusPolls is how many more times stopJobGracefully checks for the
+// status file of a job whose process is gone before marking it dead
+const stopStatusPolls = 2
+
+// stopJobGracefully sends a job SIGTERM and waits up to grace for it to
+// exit, then kills it if it hasn't, and records how it ended
+func stopJobGracefully(database *sql.DB, job *db.Job, grace time.Duration) error {
+	if job.Status == db.StatusPaused {
+		// A stopped process can't act on SIGTERM until it is continued
+		if err := jobstate.Signal(job, "CONT"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to resume job %d before stopping it: %v\n", job.ID, err)
+		}
+	}
+
+	fmt.Printf("Stopping job %d on %s (up to %s for it to save a checkpoint)...\n", job.ID, job.Host, grace)
+	if err := jobstate.Signal(job, "TERM"); err != nil {
+		return fmt.Errorf("stop job %d: %w", job.ID, err)
+	}
+
+	deadline := time.Now().Add(grace)
+	deadPolls := 0
+	for {
+		stdout, _, err := ssh.Run(jobHost(job), session.JobStateCommand(job.ID))
+		state := strings.TrimSpace(stdout)
+		if pid, dead := session.DeadState(state); err == nil && dead {
+			// The wrapper writes the status file after the job's process
+			// exits, so read it again before deciding the job died
+			// without writing one
+			if deadPolls++; deadPolls <= stopStatusPolls {
+				time.Sleep(2 * time.Second)
+				continue
+			}
+			if err := db.MarkDeadWithEvidence(database, job.ID, "migrate", jobstate.StateDeadEvidence(job, pid)); err != nil {
+				return fmt.Errorf("mark job %d dead: %w", job.ID, err)
+			}
+			break
+		}
+		if exitCode, parseErr := strconv.Atoi(state); err == nil && parseErr == nil {
+			if err := db.RecordCompletionByID(database, job.ID, exitCode, time.Now().Unix()); err != nil {
+				return fmt.Errorf("record end of job %d: %w", job.ID, err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("Job %d is still running after %s; killing it\n", job.ID, grace)
+			return killJob(database, job.ID)
+		}
+		time.Sleep(2 * time.Second)
+	}
+	fmt.Printf("Job %d stopped\n", job.ID)
+	return nil
+}
+
+// checkpointDestination returns where a checkpoint goes on the new host, and
+// how the resume command refers to it: a checkpoint matched by an absolute
+// glob keeps its absolute path, and one inside the working directory keeps
+// its path relative to it
+func checkpointDestination(workingDir string, globs []string, checkpoint db.Artifact) (dest, ref string) {
+	for _, glob := range globs {
+		if matched, _ := path.Match(glob, checkpoint.Path); matched && path.IsAbs(glob) {
+			return checkpoint.Path, checkpoint.Path
+		}
+	}
+	return strings.TrimSuffix(workingDir, "/") + "/" + checkpoint.Name, checkpoint.Name
+}
+
+// copyBetweenHosts streams a file from one host to another through this
+// machine, so the hosts needn't be able to reach each other
+func copyBetweenHosts(srcHost, srcPath, dstHost, dstPath string) error {
+	src := ssh.Command(srcHost, "cat "+shellquote.Quote(srcPath))
+	dst := ssh.Command(dstHost, fmt.Sprintf("mkdir -p %s && cat > %s",
+		shellquote.Path(path.Dir(dstPath)), shellquote.Path(dstPath)))
+	var srcErr, dstErr strings.Builder
+	src.Stderr = &srcErr
+	dst.Stderr = &dstErr
+
+	pipe, err := src.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	dst.Stdin = pipe
+	if err := src.Start(); err != nil {
+		return err
+	}
+	if err := dst.Start(); err != nil {
+		src.Process.Kill()
+		src.Wait()
+		return err
+	}
+	srcWaitErr := src.Wait()
+	dstWaitErr := dst.Wait()
+	if srcWaitErr != nil {
+		return fmt.Errorf("read from %s: %s", srcHost, ssh.FriendlyError(srcHost, srcErr.String(), srcWaitErr))
+	}
+	if dstWaitErr != nil {
+		return fmt.Errorf("write to %s: %s", dstHost, ssh.FriendlyError(dstHost, dstErr.String(), dstWaitErr))
+	}
+	return nil
+}
+
+// saveJobResume records the resume command of a newly created job
+func saveJobResume(database *sql.DB, jobID int64, command string) {
+	if command == "" {
+		return
+	}
+	if err := db.SetResumeCommand(database, jobID, command); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save resume command for job %d: %v\n", jobID, err)
+	}
+}
+
+// printLineage prints the jobs a migrated job continues and was continued by
+func printLineage(database *sql.DB, jobID int64) {
+	if resume, err := db.GetResumeCommand(database, jobID); err == nil && resume != "" {
+		fmt.Printf("Resume:       %s\n", resume)
+	}
+	if m, err := db.GetMigrationTo(database, jobID); err == nil && m != nil {
+		fmt.Printf("Continues:    job %d on %s (from checkpoint %s)\n", m.FromJobID, m.FromHost, m.Checkpoint)
+	}
+	if m, err := db.GetMigrationFrom(database, jobID); err == nil && m != nil {
+		if next, err := db.GetJobByID(database, m.JobID); err == nil && next != nil {
+			fmt.Printf("Continued As: job %d on %s (migrated)\n", next.ID, next.Host)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/editor"
+	"github.com/spf13/cobra"
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note <job-id> [text]",
+	Short: "Attach notes to a job",
+	Long: `Attach free-form notes to a job, such as what you observed about a run.
+
+Without text, the job's note opens in your editor ($VISUAL, $EDITOR, or vi)
+as a Markdown file; saving an empty file removes the note. With text, the
+note is replaced, or added to with --append.
+
+Notes are shown by 'job list --show', in the TUI's Details tab (where N
+edits them), and in 'export' output.
+
+Examples:
+  remote-jobs note 42                                    # Edit in $EDITOR
+  remote-jobs note 42 'diverged at epoch 12, lr too high'
+  remote-jobs note 42 -a 'reran with lr 1e-4 as job 57'   # Add a line
+  remote-jobs note 42 --show
+  remote-jobs note 42 --clear`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runNote,
+}
+
+var (
+	noteAppend bool
+	noteShow   bool
+	noteClear  bool
+)
+
+func init() {
+	rootCmd.AddCommand(noteCmd)
+	noteCmd.Flags().BoolVarP(&noteAppend, "append", "a", false, "Add the text to the end of the note instead of replacing it")
+	noteCmd.Flags().BoolVar(&noteShow, "show", false, "Print the note")
+	noteCmd.Flags().BoolVar(&noteClear, "clear", false, "Remove the note")
+}
+
+func runNote(cmd *cobra.Command, args []string) error {
+	hasText := len(args) > 1
+	if noteShow && (hasText || noteClear || noteAppend) {
+		return fmt.Errorf("--show cannot be used with text, --append, or --clear")
+	}
+	if noteClear && (hasText || noteAppend) {
+		return fmt.Errorf("--clear cannot be used with text or --append")
+	}
+	if noteAppend && !hasText {
+		return fmt.Errorf("--append requires text")
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	jobID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get job: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %d not found", jobID)
+	}
+
+	note, err := db.GetJobNote(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get note: %w", err)
+	}
+	current := ""
+	if note != nil {
+		current = note.Text
+	}
+
+	if noteShow {
+		if current == "" {
+			fmt.Printf("Job %d has no note\n", jobID)
+		} else {
+			fmt.Println(current)
+		}
+		return nil
+	}
+
+	var text string
+	switch {
+	case noteClear:
+	case noteAppend && current != "":
+		text = current + "\n" + args[1]
+	case hasText:
+		text = args[1]
+	default:
+		text, err = editor.Edit(current, fmt.Sprintf("remote-jobs-note-%d-*.md", jobID))
+		if err != nil {
+			return err
+		}
+	}
+
+	text = strings.TrimSpace(text)
+	if text == current {
+		fmt.Printf("Note for job %d unchanged\n", jobID)
+		return nil
+	}
+	if err := db.SetJobNote(database, jobID, text); err != nil {
+		return fmt.Errorf("save note: %w", err)
+	}
+	if text == "" {
+		fmt.Printf("Removed note from job %d\n", jobID)
+	} else {
+		fmt.Printf("Saved note for job %d\n", jobID)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/opendir"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var openDirCmd = &cobra.Command{
+	Use:   "open-dir <job-id>",
+	Short: "Open a job's working directory in a local editor",
+	Long: `Open the remote working directory of a job in a local application, by
+building a URI for the job's host and directory and handing it to the
+system's URI handler (open on macOS, xdg-open on Linux).
+
+The URI comes from the open_dir setting in config.yaml, globally or per
+host, or from --template. It is a preset name or a template:
+
+  vscode  vscode://vscode-remote/ssh-remote+{host}{path}  (default)
+  cursor  cursor://vscode-remote/ssh-remote+{host}{path}
+  sftp    sftp://{host}{path}
+
+In a template, {host} is the job's host (user@host if it sets a user),
+{path} the absolute path of its working directory (found over SSH when the
+directory starts with ~), {dir} the directory as recorded, e.g.
+~/code/project, and {local} the local directory that path_mappings pair it
+with, such as an SSHFS mount.
+
+Examples:
+  remote-jobs open-dir 42                  # Open in VS Code Remote-SSH
+  remote-jobs open-dir 42 -t sftp          # Open in the SFTP file browser
+  remote-jobs open-dir 42 --print          # Print the URI without opening it
+  remote-jobs open-dir 42 -t 'vscode://file{local}'  # Open the SSHFS-mounted copy
+  code --folder-uri "$(remote-jobs open-dir 42 --print -t 'vscode-remote://ssh-remote+{host}{path}')"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runOpenDir,
+}
+
+var (
+	openDirTemplate string
+	openDirPrint    bool
+)
+
+func init() {
+	rootCmd.AddCommand(openDirCmd)
+	openDirCmd.Flags().StringVarP(&openDirTemplate, "template", "t", "", "Preset (vscode, cursor, sftp) or URI template; overrides open_dir")
+	openDirCmd.Flags().BoolVarP(&openDirPrint, "print", "p", false, "Print the URI instead of opening it")
+}
+
+func runOpenDir(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	jobID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get job: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %d not found", jobID)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	setting := openDirTemplate
+	if setting == "" {
+		setting = cfg.OpenDirTemplate(job.Host)
+	}
+	template, err := opendir.Template(setting)
+	if err != nil {
+		return err
+	}
+
+	uri, err := opendir.Build(template, ssh.Target(jobHost(job)), job.EffectiveWorkingDir(), cfg.HostPathMappings(job.Host))
+	if err != nil {
+		return err
+	}
+	if openDirPrint {
+		fmt.Println(uri)
+		return nil
+	}
+
+	fmt.Printf("Opening %s\n", uri)
+	return opendir.Open(uri)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/pathmap"
+	"github.com/osteele/remote-jobs/internal/session"
+)
+
+// remoteDirOn returns the directory on host for a local directory: through
+// the host's path mappings if one covers it, otherwise at the same path
+// relative to the home directory
+func remoteDirOn(host, dir string) (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", fmt.Errorf("load config: %w", err)
+	}
+	if remote, ok := pathmap.ToRemote(cfg.HostPathMappings(host), dir); ok {
+		return remote, nil
+	}
+	return session.RemoteDir(dir)
+}
+
+// defaultWorkingDir returns the directory on host for the current directory
+func defaultWorkingDir(host string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("get working dir: %w", err)
+	}
+	dir, err := remoteDirOn(host, cwd)
+	if err != nil {
+		return "", fmt.Errorf("get working dir: %w", err)
+	}
+	return dir, nil
+}
+
+// localDirOf returns the local directory for a directory on host, if one of
+// the host's path mappings covers it
+func localDirOf(host, dir string) (string, bool) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", false
+	}
+	return pathmap.ToLocal(cfg.HostPathMappings(host), dir)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/spf13/cobra"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <job-id>...",
+	Short: "Suspend one or more running jobs",
+	Long: `Suspend running jobs by sending SIGSTOP to their process trees.
+
+A paused job keeps its memory (and any GPU memory) but uses no CPU time.
+Use 'remote-jobs resume' to continue it.
+
+Examples:
+  remote-jobs pause 42
+  remote-jobs pause 42 43`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <job-id>...",
+	Short: "Resume one or more paused jobs",
+	Long: `Resume paused jobs by sending SIGCONT to their process trees.
+
+Examples:
+  remote-jobs resume 42
+  remote-jobs resume 42 43`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runResume,
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
+
+func runPause(cmd *cobra.Command, args []string) error {
+	return forEachJobID(args, pauseJob)
+}
+
+func runResume(cmd *cobra.Command, args []string) error {
+	return forEachJobID(args, resumeJob)
+}
+
+// forEachJobID opens the database and applies fn to the job each argument, a
+// job ID or selector, names, collecting errors so that one bad ID doesn't
+// stop the rest
+func forEachJobID(args []string, fn func(*sql.DB, int64) error) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	selector := jobSelector{}.resolver(database)
+	var errors []string
+	for _, arg := range args {
+		jobID, err := selector.resolve(arg)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		if err := fn(database, jobID); err != nil {
+			errors = append(errors, fmt.Sprintf("job %d: %v", jobID, err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+func pauseJob(database *sql.DB, jobID int64) error {
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("not found")
+	}
+	if job.Status == db.StatusPaused {
+		return fmt.Errorf("already paused")
+	}
+	if job.Status != db.StatusRunning {
+		return fmt.Errorf("not running (status: %s)", job.Status)
+	}
+
+	if err := jobstate.Signal(job, "STOP"); err != nil {
+		return err
+	}
+	if err := db.MarkPaused(database, job.ID, 0); err != nil {
+		// Don't leave the process stopped if we couldn't record it
+		if contErr := jobstate.Signal(job, "CONT"); contErr != nil {
+			fmt.Printf("Warning: failed to resume job %d: %v\n", job.ID, contErr)
+		}
+		return fmt.Errorf("mark paused: %w", err)
+	}
+
+	fmt.Printf("Paused job %d on %s\n", job.ID, job.Host)
+	return nil
+}
+
+func resumeJob(database *sql.DB, jobID int64) error {
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("not found")
+	}
+	if job.Status != db.StatusPaused {
+		return fmt.Errorf("not paused (status: %s)", job.Status)
+	}
+
+	if err := jobstate.Signal(job, "CONT"); err != nil {
+		return err
+	}
+	if err := db.MarkResumed(database, job.ID); err != nil {
+		return fmt.Errorf("mark resumed: %w", err)
+	}
+
+	fmt.Printf("Resumed job %d on %s\n", job.ID, job.Host)
+	return nil
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/availability"
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/hosttools"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/placement"
+	"github.com/osteele/remote-jobs/internal/reachability"
+	"github.com/osteele/remote-jobs/internal/scripts"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// loadPlacementConfig returns the config whose host tags placement uses,
+// or the default if it can't be read
+func loadPlacementConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.DefaultConfig()
+	}
+	return cfg
+}
+
+// inventoryFromCache returns what the host info cache records a host as
+// having, with its configured, added, and detected tags; info is nil for
+// hosts that have never been seen
+func inventoryFromCache(cfg *config.Config, host string, info *db.CachedHostInfo) placement.Inventory {
+	inv := placement.Inventory{Host: host, Tags: slices.Clone(cfg.Host(host).Tags)}
+	if info == nil {
+		return inv
+	}
+	for _, tag := range info.Tags {
+		if !slices.Contains(inv.Tags, tag) {
+			inv.Tags = append(inv.Tags, tag)
+		}
+	}
+	inv.Tools = hosttools.Decode(info.ToolsJSON)
+	if !info.Fetched() {
+		return inv
+	}
+	inv.Known = true
+	gpus, _ := gpupool.FromCache(host, info.GPUsJSON)
+	var gpuNames []string
+	for _, g := range gpus {
+		inv.GPUMemoryMiB = append(inv.GPUMemoryMiB, g.MemTotalMiB)
+		gpuNames = append(gpuNames, g.Name)
+	}
+	for _, tag := range placement.DetectTags(info.Arch, gpuNames) {
+		if !slices.Contains(inv.Tags, tag) {
+			inv.Tags = append(inv.Tags, tag)
+		}
+	}
+	if size, ok := humanfmt.ParseSize(info.MemTotal, 1); ok {
+		inv.RAMBytes = size
+	}
+	inv.DiskAvailBytes = info.DiskAvailKB * 1024
+	return inv
+}
+
+// probeHostTools fills in the tool versions of a host that the cache has no
+// record of, by running the host-info script on it, if the request has tool
+// requirements to check them against. The versions are saved to the cache.
+// Hosts that can't be reached are left unprobed.
+func probeHostTools(database *sql.DB, inv *placement.Inventory, r placement.Request) {
+	if inv.Tools != nil || len(r.ToolRequirements()) == 0 {
+		return
+	}
+	stdout, _, err := ssh.RunScript(inv.Host, "host-info", scripts.HostInfoScript,
+		shellquote.Quote("default"), ssh.HostTimeouts(inv.Host).Probe)
+	if err != nil {
+		return
+	}
+	if inv.Tools = hosttools.FromHostInfo(stdout); inv.Tools != nil {
+		db.SaveCachedHostTools(database, inv.Host, inv.Tools.Encode())
+	}
+}
+
+// hostAvailability returns when a host may start jobs. Invalid windows are
+// reported and ignored, so the host counts as always available.
+func hostAvailability(cfg *config.Config, host string) availability.Schedule {
+	s, err := cfg.HostAvailability(host)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return availability.Schedule{}
+	}
+	return s
+}
+
+// hostOpensAt returns when a host's availability windows next let it start
+// jobs, or the zero time if they do now
+func hostOpensAt(cfg *config.Config, host string, now time.Time) time.Time {
+	s := hostAvailability(cfg, host)
+	if s.Open(now) {
+		return time.Time{}
+	}
+	return s.NextOpen(now)
+}
+
+// checkHostFit returns an error if a host breaks a request's tag
+// constraints or lacks a required tool version, and warns if the host info
+// cache shows that it falls short of the request's needs. Resources change,
+// so the cache's may be stale and only warn. Tool versions the cache doesn't
+// record are probed; those of a host that can't be reached warn.
+func checkHostFit(database *sql.DB, host string, r placement.Request) error {
+	if r.Needs.IsZero() && len(r.Require) == 0 && len(r.Avoid) == 0 {
+		return nil
+	}
+	info, err := db.LoadCachedHostInfo(database, host)
+	if err != nil {
+		return fmt.Errorf("load cached info: %w", err)
+	}
+	inv := inventoryFromCache(loadPlacementConfig(), host, info)
+	probeHostTools(database, &inv, r)
+	if v := r.Violations(inv.Tags); len(v) > 0 {
+		return fmt.Errorf("%s %s (see 'remote-jobs host tags')", host, strings.Join(v, " and "))
+	}
+	if short := r.ToolShortfalls(inv); len(short) > 0 {
+		if inv.Tools == nil {
+			fmt.Fprintf(os.Stderr, "Warning: can't check the job's tool requirements: %s\n", short[0])
+		} else {
+			return fmt.Errorf("%s %s (see 'remote-jobs host info %s')", host, strings.Join(short, " and "), host)
+		}
+	}
+	if short := r.Needs.Shortfalls(inv); len(short) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s may not satisfy the job's needs: %s\n", host, strings.Join(short, "; "))
+	}
+	return nil
+}
+
+// placeJob picks a host for a job run on the "auto" host, from the hosts in
+// the host info cache. If every host that fits is outside its availability
+// windows, it picks the one that opens first and returns when that is.
+func placeJob(database *sql.DB, r placement.Request) (string, time.Time, error) {
+	hosts, err := db.LoadAllCachedHosts(database)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("load cached hosts: %w", err)
+	}
+	cfg := loadPlacementConfig()
+	now := time.Now()
+	var candidates []placement.Candidate
+	for _, info := range hosts {
+		running, err := db.GetRunningJobsByHost(database, info.Name)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("list jobs on %s: %w", info.Name, err)
+		}
+		inv := inventoryFromCache(cfg, info.Name, info)
+		unreachable := reachability.Skip(database, info.Name, now)
+		if !unreachable {
+			probeHostTools(database, &inv, r)
+		}
+		candidates = append(candidates, placement.Candidate{
+			Inventory:   inv,
+			RunningJobs: len(running),
+			Unreachable: unreachable,
+			OpensAt:     hostOpensAt(cfg, info.Name, now),
+		})
+	}
+	host, err := placement.Choose(r, candidates)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	for _, c := range candidates {
+		if c.Host == host && !c.OpensAt.IsZero() {
+			fmt.Printf("Placing job on %s, which is next available %s\n", host, availability.FormatOpensAt(c.OpensAt, now))
+			return host, c.OpensAt, nil
+		}
+	}
+	fmt.Printf("Placing job on %s\n", host)
+	return host, time.Time{}, nil
+}
+
+// saveJobRequest records what a newly created job asks of its host
+func saveJobRequest(database *sql.DB, jobID int64, r placement.Request) {
+	if r.Needs.IsZero() && len(r.Require) == 0 && len(r.Avoid) == 0 {
+		return
+	}
+	err := db.SetJobRequest(database, jobID, db.JobRequest{Needs: r.Needs.String(), Require: r.Require, Avoid: r.Avoid})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save needs for job %d: %v\n", jobID, err)
+	}
+}
+
+// jobRequest returns what a job asked of its host
+func jobRequest(database *sql.DB, jobID int64) placement.Request {
+	saved, err := db.GetJobRequest(database, jobID)
+	if err != nil {
+		return placement.Request{}
+	}
+	needs, _ := placement.ParseNeeds(saved.Needs)
+	return placement.Request{Needs: needs, Require: saved.Require, Avoid: saved.Avoid}
+}
@@ -3,15 +3,20 @@ package cmd
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/osteele/remote-jobs/internal/db"
 	"github.com/osteele/remote-jobs/internal/plan"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
@@ -23,22 +28,58 @@ var planCmd = &cobra.Command{
 var planSubmitCmd = &cobra.Command{
 	Use:   "submit <file|- >",
 	Short: "Submit a YAML job execution plan",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runPlanSubmit,
+	Long: `Submit a YAML job execution plan (see docs/job-plans.md).
+
+The whole plan is checked before any job is started: keys that aren't part
+of the plan format, values of the wrong type, and jobs without a command or
+host are all reported, by line and column. Use plan validate to only check.
+
+If a job can't be started or queued partway through, as when its host is
+unreachable, the jobs submitted before it are listed and left alone. With
+--atomic, they are killed, removed from their queues, or (if waiting
+locally) deleted instead, so a failed submission leaves no half-submitted
+pipeline; queue runners are only started once every job is submitted. Jobs
+killed by the plan's kill list stay killed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPlanSubmit,
+}
+
+var planValidateCmd = &cobra.Command{
+	Use:   "validate <file|- >",
+	Short: "Check a plan file without submitting it",
+	Long: `Check a plan file as plan submit would, without starting, queueing, or
+killing any jobs, and report every problem by line and column.
+
+Examples:
+  remote-jobs plan validate plan.yaml
+  remote-jobs plan validate --host studio --var lr=0.1 plan.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPlanValidate,
 }
 
 var (
 	planWatchDuration time.Duration
 	planNoQueueStart  bool
 	planDefaultHost   string
+	planIgnoreLimits  bool
+	planForce         bool
+	planVars          []string
+	planAtomic        bool
 )
 
 func init() {
 	rootCmd.AddCommand(planCmd)
 	planCmd.AddCommand(planSubmitCmd)
+	planCmd.AddCommand(planValidateCmd)
 	planSubmitCmd.Flags().DurationVar(&planWatchDuration, "watch", 0, "Wait for up to this duration and report job outcomes")
 	planSubmitCmd.Flags().BoolVar(&planNoQueueStart, "no-queue-start", false, "Skip auto-starting queue runners for queued jobs")
-	planSubmitCmd.Flags().StringVarP(&planDefaultHost, "host", "H", "", "Default host for jobs that omit the host field")
+	planSubmitCmd.Flags().BoolVar(&planIgnoreLimits, "ignore-limits", false, "Start or queue jobs even if hosts' configured job limits are reached")
+	planSubmitCmd.Flags().BoolVar(&planAtomic, "atomic", false, "If a job can't be submitted, undo the jobs already submitted")
+	planSubmitCmd.Flags().BoolVar(&planForce, "force", false, "Start jobs even if the GPUs they would use are heavily used by other processes")
+	for _, c := range []*cobra.Command{planSubmitCmd, planValidateCmd} {
+		c.Flags().StringVarP(&planDefaultHost, "host", "H", "", "Default host for jobs that omit the host field")
+		c.Flags().StringArrayVar(&planVars, "var", nil, "Set a plan variable (name=value), overriding vars and matrix values, can be repeated")
+	}
 }
 
 type scheduledPlanJob struct {
@@ -50,28 +91,17 @@ type scheduledPlanJob struct {
 }
 
 func runPlanSubmit(cmd *cobra.Command, args []string) error {
-	path := args[0]
-	data, err := readPlanInput(path)
+	planFile, err := loadPlan(args[0])
 	if err != nil {
 		return err
 	}
 
-	planFile, err := plan.Decode(data)
-	if err != nil {
-		return fmt.Errorf("parse plan: %w", err)
-	}
-	if err := planFile.ApplyDefaults(plan.Defaults{Host: planDefaultHost}); err != nil {
-		return err
-	}
-	if err := planFile.Validate(); err != nil {
-		return err
-	}
-
 	database, err := db.Open()
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
+	defer waitForDeliveries()
 
 	if len(planFile.Kill) > 0 {
 		for _, id := range planFile.Kill {
@@ -91,13 +121,19 @@ func runPlanSubmit(cmd *cobra.Command, args []string) error {
 	for idx, entry := range planFile.Jobs {
 		label := fmt.Sprintf("jobs[%d]", idx)
 		subJobs, err := schedulePlanEntry(database, entry, startedQueues)
-		if err != nil {
-			return fmt.Errorf("%s: %w", label, err)
-		}
 		for _, sj := range subJobs {
 			scheduled = append(scheduled, sj)
 			commandMap[sj.Command] = append(commandMap[sj.Command], sj.JobID)
 		}
+		if err != nil {
+			return abandonPlan(database, scheduled, fmt.Errorf("%s: %w", label, err))
+		}
+	}
+	if planAtomic {
+		for _, key := range slices.Sorted(maps.Keys(startedQueues)) {
+			host, queue, _ := strings.Cut(key, "|")
+			startPlanQueueRunner(host, queue)
+		}
 	}
 
 	printCommandMap(commandMap)
@@ -112,6 +148,198 @@ func runPlanSubmit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// abandonPlan handles a job of the plan that couldn't be submitted: with
+// --atomic, it undoes the jobs already submitted; otherwise it lists them
+func abandonPlan(database *sql.DB, scheduled []scheduledPlanJob, err error) error {
+	if len(scheduled) == 0 {
+		return err
+	}
+	ids := make([]string, len(scheduled))
+	for i, job := range scheduled {
+		ids[i] = fmt.Sprint(job.JobID)
+	}
+	if !planAtomic {
+		fmt.Fprintf(os.Stderr, "\n%d job(s) were submitted before the failure: %s\n", len(scheduled), strings.Join(ids, " "))
+		fmt.Fprintf(os.Stderr, "To remove them: remote-jobs kill %s\n", strings.Join(ids, " "))
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "\nUndoing the %d job(s) submitted before the failure...\n", len(scheduled))
+	left, unconfirmed := rollbackPlanJobs(database, scheduled)
+	var problems []string
+	if len(left) > 0 {
+		problems = append(problems, "couldn't undo job(s) "+strings.Join(left, " "))
+	}
+	if len(unconfirmed) > 0 {
+		problems = append(problems, "couldn't confirm that job(s) "+strings.Join(unconfirmed, " ")+" stopped; check them with remote-jobs status")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w; %s", err, strings.Join(problems, "; "))
+	}
+	return fmt.Errorf("%w; undid the %d job(s) already submitted", err, len(scheduled))
+}
+
+// planStopWait is how long rollbackPlanJobs waits for a host to confirm that
+// a killed job has stopped
+const planStopWait = 10 * time.Second
+
+// errNotConfirmed is returned by stopPlanJob for a job that it undid, but
+// whose host didn't confirm that it stopped
+var errNotConfirmed = errors.New("not confirmed stopped")
+
+// rollbackPlanJobs kills, removes from their queues, or deletes the jobs of
+// a failed plan, newest first, so that no queued job outlives the job it
+// waits for. It returns the IDs of the jobs it couldn't undo, and of those
+// it undid but couldn't confirm have stopped.
+func rollbackPlanJobs(database *sql.DB, jobs []scheduledPlanJob) (left, unconfirmed []string) {
+	for i := len(jobs) - 1; i >= 0; i-- {
+		id := jobs[i].JobID
+		job, err := db.GetJobByID(database, id)
+		switch {
+		case err != nil:
+		case job == nil || jobTerminal(job):
+			continue // Nothing left to undo
+		case job.Status == db.StatusPending:
+			// Waiting locally, for a connection or another host's job; any
+			// dependency it was held for goes with it
+			err = db.DeletePending(database, id)
+		default:
+			err = stopPlanJob(database, job)
+		}
+		if errors.Is(err, errNotConfirmed) {
+			fmt.Fprintf(os.Stderr, "Warning: job %d may still be running on %s\n", id, job.Host)
+			unconfirmed = append(unconfirmed, fmt.Sprint(id))
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to undo job %d: %v\n", id, err)
+			left = append(left, fmt.Sprint(id))
+		}
+	}
+	return left, unconfirmed
+}
+
+// stopPlanJob kills a job of a failed plan, or removes it from its queue.
+// The job's status is synced first, since it may have finished, or its queue
+// runner may have started it, since it was submitted. It returns
+// errNotConfirmed if the job's host doesn't confirm that it has stopped.
+func stopPlanJob(database *sql.DB, job *db.Job) error {
+	// If the host doesn't answer, the job is undone as last recorded, which
+	// for an unreachable host only marks it to be undone on the next sync
+	_, syncErr := syncJob(database, job)
+	if syncErr == nil {
+		var err error
+		job, err = db.GetJobByID(database, job.ID)
+		if err != nil || job == nil || jobTerminal(job) {
+			return err
+		}
+	}
+
+	var err error
+	if job.Status == db.StatusQueued {
+		// This kills it instead, and sets its status to running, if the
+		// runner has taken it from the queue
+		err = removeQueuedJob(database, job)
+	} else {
+		err = killJob(database, job.ID)
+	}
+	switch {
+	case err != nil:
+		return err
+	case syncErr != nil:
+		return errNotConfirmed
+	case job.Status == db.StatusQueued:
+		return nil // Removed before it started
+	}
+	if _, _, err := ssh.RunWithTimeout(jobHost(job), session.WaitForExitCommand(job.ID, 0), planStopWait); err != nil {
+		return errNotConfirmed
+	}
+	return nil
+}
+
+func runPlanValidate(cmd *cobra.Command, args []string) error {
+	planFile, err := loadPlan(args[0])
+	if err != nil {
+		return err
+	}
+	started, queued := planFile.Count()
+	fmt.Printf("%s is valid: %d job(s) to start and %d to queue", planName(args[0]), started, queued)
+	if len(planFile.Kill) > 0 {
+		fmt.Printf(", after killing %d", len(planFile.Kill))
+	}
+	fmt.Println()
+	return nil
+}
+
+// loadPlan reads, expands, and checks a plan file, reporting every problem
+// found
+func loadPlan(path string) (*plan.File, error) {
+	data, err := readPlanInput(path)
+	if err != nil {
+		return nil, err
+	}
+	planFile, err := plan.Decode(data)
+	if err != nil {
+		return nil, planErrors(path, err)
+	}
+	vars, err := plan.ParseVars(planVars)
+	if err != nil {
+		return nil, fmt.Errorf("--var: %w", err)
+	}
+	if err := planFile.Expand(vars); err != nil {
+		return nil, planErrors(path, err)
+	}
+	defaultsErr := planFile.ApplyDefaults(plan.Defaults{Host: planDefaultHost})
+	if err := errors.Join(defaultsErr, planFile.Validate()); err != nil {
+		return nil, planErrors(path, err)
+	}
+	return planFile, nil
+}
+
+// planErrors lists a plan's problems, one per line, each prefixed with the
+// file name and, if known, its line and column
+func planErrors(path string, err error) error {
+	name := planName(path)
+	var lines []string
+	for _, e := range flattenErrors(err) {
+		line := name + ": " + e.Error()
+		var pe *plan.Error
+		if errors.As(e, &pe) && pe.Line > 0 {
+			msg := pe.Msg
+			if pe.Path != "" {
+				msg = pe.Path + ": " + msg
+			}
+			line = fmt.Sprintf("%s:%d:%d: %s", name, pe.Line, pe.Column, msg)
+		}
+		if !slices.Contains(lines, line) {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 1 {
+		return errors.New(lines[0])
+	}
+	return fmt.Errorf("%d problems in the plan:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// flattenErrors returns the errors joined in err, including those joined in
+// them
+func flattenErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(e)...)
+	}
+	return errs
+}
+
+func planName(path string) string {
+	if path == "-" {
+		return "<stdin>"
+	}
+	return path
+}
+
 func readPlanInput(path string) ([]byte, error) {
 	if path == "-" {
 		return io.ReadAll(os.Stdin)
@@ -143,7 +371,7 @@ func scheduleParallelBlock(database *sql.DB, block *plan.Parallel, startedQueues
 		resolved := applyJobDefaults(job, block.Dir, block.Env)
 		sj, err := scheduleSingleJob(database, resolved, startedQueues)
 		if err != nil {
-			return nil, err
+			return out, err
 		}
 		out = append(out, sj)
 	}
@@ -167,7 +395,7 @@ func scheduleSeriesBlock(database *sql.DB, block *plan.Series, startedQueues map
 		if detectedHost == "" {
 			detectedHost = resolved.Host
 		} else if resolved.Host != detectedHost {
-			return nil, fmt.Errorf("series block jobs must target the same host (found %s and %s)", detectedHost, resolved.Host)
+			return out, fmt.Errorf("series block jobs must target the same host (found %s and %s)", detectedHost, resolved.Host)
 		}
 		afterID := int64(0)
 		afterAny := false
@@ -176,17 +404,18 @@ func scheduleSeriesBlock(database *sql.DB, block *plan.Series, startedQueues map
 			afterAny = waitMode == "any"
 		}
 		jobID, err := queueJob(database, queueJobOptions{
-			Host:        resolved.Host,
-			WorkingDir:  resolved.Dir,
-			Command:     resolved.Command,
-			Description: resolved.Description,
-			EnvVars:     resolved.EnvVars,
-			QueueName:   queueName,
-			AfterJobID:  afterID,
-			AfterAny:    afterAny,
+			Host:         resolved.Host,
+			WorkingDir:   resolved.Dir,
+			Command:      resolved.Command,
+			Description:  resolved.Description,
+			EnvVars:      resolved.EnvVars,
+			QueueName:    queueName,
+			AfterJobID:   afterID,
+			AfterAny:     afterAny,
+			IgnoreLimits: planIgnoreLimits,
 		})
 		if err != nil {
-			return nil, err
+			return out, err
 		}
 		prevJobID = jobID
 		out = append(out, scheduledPlanJob{
@@ -235,12 +464,13 @@ func scheduleSingleJob(database *sql.DB, job resolvedPlanJob, startedQueues map[
 			queueName = defaultQueueName
 		}
 		jobID, err := queueJob(database, queueJobOptions{
-			Host:        job.Host,
-			WorkingDir:  job.Dir,
-			Command:     job.Command,
-			Description: job.Description,
-			EnvVars:     job.EnvVars,
-			QueueName:   queueName,
+			Host:         job.Host,
+			WorkingDir:   job.Dir,
+			Command:      job.Command,
+			Description:  job.Description,
+			EnvVars:      job.EnvVars,
+			QueueName:    queueName,
+			IgnoreLimits: planIgnoreLimits,
 		})
 		if err != nil {
 			return scheduledPlanJob{}, err
@@ -251,11 +481,13 @@ func scheduleSingleJob(database *sql.DB, job resolvedPlanJob, startedQueues map[
 	}
 
 	result, err := startJob(database, startJobOptions{
-		Host:        job.Host,
-		WorkingDir:  job.Dir,
-		Command:     job.Command,
-		Description: job.Description,
-		EnvVars:     job.EnvVars,
+		Host:         job.Host,
+		WorkingDir:   job.Dir,
+		Command:      job.Command,
+		Description:  job.Description,
+		EnvVars:      job.EnvVars,
+		IgnoreLimits: planIgnoreLimits,
+		Force:        planForce,
 		OnPrepared: func(info StartJobPreparedInfo) {
 			fmt.Printf("Starting %s as job %d on %s\n", label, info.JobID, job.Host)
 		},
@@ -287,6 +519,13 @@ func maybeStartQueueRunner(host, queue string, started map[string]bool) {
 		return
 	}
 	started[key] = true
+	if planAtomic {
+		return // Started once the whole plan is submitted
+	}
+	startPlanQueueRunner(host, queue)
+}
+
+func startPlanQueueRunner(host, queue string) {
 	startedRunner, err := ensureQueueRunnerStarted(host, queue)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to start queue runner on %s (%s): %v\n", host, queue, err)
@@ -412,6 +651,9 @@ func classifyJobStatus(job *db.Job) string {
 		if job.ExitCode != nil && *job.ExitCode == 0 {
 			return "succeeded"
 		}
+		if job.Skipped() {
+			return "skipped"
+		}
 		return "failed"
 	case db.StatusDead, db.StatusFailed:
 		return "failed"
@@ -420,6 +662,6 @@ func classifyJobStatus(job *db.Job) string {
 	case db.StatusRunning, db.StatusStarting:
 		return "running"
 	default:
-		return job.Status
+		return string(job.Status)
 	}
 }
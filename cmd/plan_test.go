@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestAbandonPlanUndoesJobs(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	ssh.SetSandbox(t.TempDir())
+	defer ssh.SetSandbox("")
+	planAtomic = true
+	defer func() { planAtomic = false }()
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var scheduled []scheduledPlanJob
+	submit := func(id int64, err error) int64 {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		scheduled = append(scheduled, scheduledPlanJob{Host: "cool30", JobID: id})
+		return id
+	}
+	writeHostFile := func(host, name, content string) {
+		t.Helper()
+		path := filepath.Join(ssh.SandboxHostDir(host), ".cache", "remote-jobs", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending := submit(db.RecordHeld(database, "cool30", "~/code", "make", "", "default"))
+	if err := db.AddPendingDependency(database, pending, 99, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	waiting := submit(db.RecordQueued(database, "cool30", "~/code", "make", "", "default"))
+	started := submit(db.RecordQueued(database, "cool30", "~/code", "make", "", "default"))
+	writeHostFile("cool30", "queue/default.queue", fmt.Sprintf("%d\t~/code\tmake\t\n", waiting))
+
+	// The queue runner has taken this one from the queue and started it
+	process := exec.Command("sleep", "60")
+	if err := process.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer process.Process.Kill()
+	exited := make(chan struct{})
+	go func() {
+		process.Wait()
+		close(exited)
+	}()
+	writeHostFile("cool30", fmt.Sprintf("logs/%d-1000.pid", started), fmt.Sprint(process.Process.Pid))
+
+	err = abandonPlan(database, scheduled, errors.New("job 4: host not found"))
+	if err == nil || !strings.Contains(err.Error(), "undid the 3 job(s)") {
+		t.Errorf("abandonPlan() = %v, want all 3 jobs undone", err)
+	}
+	if job, err := db.GetJobByID(database, pending); err != nil || job != nil {
+		t.Errorf("pending job = %+v, %v; want it deleted", job, err)
+	}
+	if dep, err := db.GetPendingDependency(database, pending); err != nil || dep != nil {
+		t.Errorf("held job's dependency = %+v, %v; want it deleted", dep, err)
+	}
+	for _, id := range []int64{waiting, started} {
+		if job, err := db.GetJobByID(database, id); err != nil || job == nil || job.Status != db.StatusDead {
+			t.Errorf("job %d = %+v, %v; want it dead", id, job, err)
+		}
+	}
+	queue, err := os.ReadFile(filepath.Join(ssh.SandboxHostDir("cool30"), ".cache", "remote-jobs", "queue", "default.queue"))
+	if err != nil || len(queue) != 0 {
+		t.Errorf("queue file = %q, %v; want it empty", queue, err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Error("the started job is still running")
+	}
+}
+
+func TestAbandonPlanReportsJobsItCantStop(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	ssh.SetSandbox(t.TempDir())
+	defer ssh.SetSandbox("")
+	planAtomic = true
+	defer func() { planAtomic = false }()
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	// Commands for a host whose home is a file fail, as for a host that
+	// doesn't answer
+	if err := os.MkdirAll(filepath.Dir(ssh.SandboxHostDir("gpu1")), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ssh.SandboxHostDir("gpu1"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.RecordQueued(database, "gpu1", "~/code", "make", "", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = abandonPlan(database, []scheduledPlanJob{{Host: "gpu1", JobID: id}}, errors.New("job 2: host not found"))
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprint("job(s) ", id)) || strings.Contains(err.Error(), "undid") {
+		t.Errorf("abandonPlan() = %v, want job %d reported", err, id)
+	}
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var preemptCmd = &cobra.Command{
+	Use:   "preempt <running-job-id> --for <job-id>",
+	Short: "Pause a running job to start an urgent one",
+	Long: `Pause a running job so an urgent queued or pending job can start on the
+same host right away, and resume the paused job when the urgent one finishes.
+
+The running job and its child processes are suspended with SIGSTOP, so it
+keeps its memory (including GPU memory) but stops using CPU and GPU time.
+The urgent job is taken out of its queue and started in its own session,
+with the settings it was queued with, if its --if condition holds;
+when it exits, it resumes the paused job with SIGCONT. If the urgent job is
+killed before it can do that, the next sync resumes the paused job.
+
+The urgent job gets a new job ID, as with 'run --from'.
+
+Examples:
+  remote-jobs preempt 42 --for 57    # Pause job 42, start queued job 57`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPreempt,
+}
+
+var preemptFor string
+
+func init() {
+	rootCmd.AddCommand(preemptCmd)
+	preemptCmd.Flags().StringVar(&preemptFor, "for", "", "Queued or pending job to start in its place, by ID or selector (required)")
+	preemptCmd.MarkFlagRequired("for")
+}
+
+func runPreempt(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	pausedID, err := jobSelector{}.resolve(database, args[0])
+	if err != nil {
+		return err
+	}
+
+	running, err := db.GetJobByID(database, pausedID)
+	if err != nil {
+		return fmt.Errorf("get job %d: %w", pausedID, err)
+	}
+	if running == nil {
+		return fmt.Errorf("job %d not found", pausedID)
+	}
+	if running.Status != db.StatusRunning {
+		return fmt.Errorf("job %d is %s, not running", pausedID, running.Status)
+	}
+
+	urgentID, err := jobSelector{}.resolveFlag(database, "--for", preemptFor)
+	if err != nil {
+		return err
+	}
+	urgent, err := db.GetJobByID(database, urgentID)
+	if err != nil {
+		return fmt.Errorf("get job %d: %w", urgentID, err)
+	}
+	if urgent == nil {
+		return fmt.Errorf("job %d not found", urgentID)
+	}
+	if urgent.Status != db.StatusQueued && urgent.Status != db.StatusPending {
+		return fmt.Errorf("job %d is %s; only queued or pending jobs can be started by preempt", urgentID, urgent.Status)
+	}
+	if urgent.Host != running.Host {
+		return fmt.Errorf("job %d is on %s but job %d is on %s; preempt only works on one host", urgentID, urgent.Host, pausedID, running.Host)
+	}
+
+	opts, err := savedJobOptions(database, urgent)
+	if err != nil {
+		return err
+	}
+	guard, err := db.GetJobGuard(database, urgent.ID)
+	if err != nil {
+		return fmt.Errorf("get guard of job %d: %w", urgent.ID, err)
+	}
+	if guard != nil {
+		if err := checkPreemptGuard(urgent, guard, opts.EnvVars); err != nil {
+			return err
+		}
+	}
+
+	// Take a queued job out of its queue before anything else, so that its
+	// queue runner can't start it too
+	var taken string
+	if urgent.Status == db.StatusQueued {
+		taken, err = takeFromQueue(urgent)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Pausing job %d on %s...\n", running.ID, running.Host)
+	if err := jobstate.Signal(running, "STOP"); err != nil {
+		requeuePreempted(urgent, taken)
+		return err
+	}
+
+	// The urgent job resumes the paused one when it exits, after its own post-finish hook
+	resume := fmt.Sprintf("echo 'Resuming preempted job %d'; %s", running.ID, session.SignalJobCommand(running.ID, "CONT"))
+	if _, postFinish := resolveRemoteHooks(urgent.Host, "", opts.PostFinish); postFinish != "" {
+		resume = postFinish + "; " + resume
+	}
+	opts.PostFinish = resume
+	opts.IgnoreLimits = true
+	opts.Force = true // The paused job keeps its GPU memory
+
+	result, err := startJob(database, opts)
+	if err == nil && result.QueuedOnConnectionFailure {
+		err = fmt.Errorf("connection to %s failed", urgent.Host)
+	}
+	if err != nil {
+		requeuePreempted(urgent, taken)
+		if resumeErr := jobstate.Signal(running, "CONT"); resumeErr != nil {
+			return fmt.Errorf("start job %d: %w (and failed to resume job %d: %v)", urgent.ID, err, running.ID, resumeErr)
+		}
+		return fmt.Errorf("start job %d: %w (job %d resumed)", urgent.ID, err, running.ID)
+	}
+
+	if err := db.MarkPaused(database, running.ID, result.Info.JobID); err != nil {
+		fmt.Printf("Warning: failed to record job %d as paused: %v\n", running.ID, err)
+	}
+	if err := removePreemptedEntry(database, urgent); err != nil {
+		fmt.Printf("Warning: failed to remove the original record of job %d: %v\n", urgent.ID, err)
+	}
+
+	fmt.Printf("✓ Job %d paused\n", running.ID)
+	fmt.Printf("✓ Job %d started as job %d\n", urgent.ID, result.Info.JobID)
+	fmt.Printf("\nJob %d will resume when job %d finishes.\n", running.ID, result.Info.JobID)
+	return nil
+}
+
+// checkPreemptGuard runs a job's --if condition in its working directory, as
+// its queue runner would before starting it, and returns an error if it is
+// false
+func checkPreemptGuard(job *db.Job, guard *db.JobGuard, envVars []string) error {
+	assignments := make([]string, len(envVars))
+	for i, v := range envVars {
+		assignments[i] = shellquote.Assignment(v)
+	}
+	check := fmt.Sprintf("cd %s && env %s bash -c %s",
+		shellquote.Path(job.WorkingDir), strings.Join(assignments, " "), shellquote.Quote(guard.Command))
+	_, stderr, err := ssh.Run(jobHost(job), check)
+	if err == nil {
+		return nil
+	}
+	if ssh.IsConnectionError(stderr) {
+		return fmt.Errorf("check condition of job %d: %s", job.ID, ssh.FriendlyError(job.Host, stderr, err))
+	}
+	return fmt.Errorf("job %d's condition is false on %s (%s); leaving it where it is", job.ID, job.Host, guard.Command)
+}
+
+// takeFromQueue removes a queued job's line from its remote queue under the
+// queue lock and returns it, or returns an error if the job's queue runner
+// has already taken it
+func takeFromQueue(job *db.Job) (string, error) {
+	read := fmt.Sprintf("grep '^%d\t' %s 2>/dev/null || true", job.ID, shellquote.Path(session.QueueFile(job.QueueName)))
+	stdout, stderr, err := ssh.Run(jobHost(job), read)
+	if err != nil {
+		return "", fmt.Errorf("read queue: %s", ssh.FriendlyError(job.Host, stderr, err))
+	}
+	line := strings.SplitN(strings.TrimSpace(stdout), "\n", 2)[0]
+	if line != "" {
+		stdout, stderr, err = ssh.Run(jobHost(job), session.RemoveFromQueueCommand(job.ID, job.QueueName))
+		if err != nil {
+			return "", fmt.Errorf("remove job %d from queue: %s", job.ID, ssh.FriendlyError(job.Host, stderr, err))
+		}
+	}
+	if line == "" || strings.TrimSpace(stdout) != "removed" {
+		return "", fmt.Errorf("job %d is no longer in its queue on %s; its queue runner may have started it", job.ID, job.Host)
+	}
+	return line, nil
+}
+
+// requeuePreempted puts a job that preempt took from its queue back at the
+// end of it, after it failed to start the job
+func requeuePreempted(job *db.Job, line string) {
+	if line == "" {
+		return
+	}
+	if _, stderr, err := ssh.Run(jobHost(job), session.AppendToQueueCommand(job.QueueName, line)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to put job %d back in its queue: %s\n", job.ID, ssh.FriendlyError(job.Host, stderr, err))
+	}
+}
+
+// removePreemptedEntry deletes the original record of a job that preempt
+// started under a new ID
+func removePreemptedEntry(database *sql.DB, job *db.Job) error {
+	if job.Status == db.StatusPending {
+		return db.DeletePending(database, job.ID)
+	}
+	return db.DeleteJob(database, job.ID)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/preset"
+)
+
+// loadProjectPreset returns the .remote-jobs.toml preset for the current
+// directory, or nil if there is none or skip is set
+func loadProjectPreset(skip bool) (*preset.Preset, error) {
+	if skip {
+		return nil, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("get working dir: %w", err)
+	}
+	p, err := preset.Find(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("read project preset: %w", err)
+	}
+	if p != nil {
+		fmt.Printf("Using project preset %s\n", p.Path)
+	}
+	return p, nil
+}
+
+// presetArgs prepends the preset's host to args if they are one short of
+// needed, so that a project with a host can omit it
+func presetArgs(p *preset.Preset, args []string, needed int) []string {
+	if p == nil || p.Host == "" || len(args) != needed-1 {
+		return args
+	}
+	return append([]string{p.Host}, args...)
+}
+
+// presetWorkingDir returns the remote directory the preset maps the current
+// directory to, or "" if it sets none
+func presetWorkingDir(p *preset.Preset) string {
+	if p == nil {
+		return ""
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return p.WorkingDir(cwd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/profile"
+	"github.com/osteele/remote-jobs/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileFlag string
+	dbPathFlag  string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "",
+		"Use the job database and config file of a named profile, e.g. work (or set REMOTE_JOBS_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&dbPathFlag, "db", "",
+		"Use the job database at this path (or set REMOTE_JOBS_DB)")
+	cobra.OnInitialize(applyProfile)
+}
+
+// applyProfile switches to the database and config file of the profile
+// selected by --profile or $REMOTE_JOBS_PROFILE, and to the database given
+// by --db or $REMOTE_JOBS_DB. The sandbox has its own, so they are ignored
+// in it. It runs before commands open the database or load the config.
+func applyProfile() {
+	if sandboxFlag || sandbox.FromEnv() {
+		return
+	}
+	if err := profile.Apply(profileFlag, dbPathFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/osteele/remote-jobs/internal/config"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/prunepolicy"
 	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
@@ -19,6 +23,15 @@ var pruneCmd = &cobra.Command{
 	Long: `Remove completed and dead jobs from the local database and their
 log files from remote hosts.
 
+Remote files are deleted with one SSH connection per host. Hosts that can't
+be reached are skipped, leaving their files behind; with --with-remote,
+their files are deleted when the host is next synced instead.
+
+--apply-policy removes the jobs selected by the prune policy in config.yaml
+(keep_last, succeeded_max_age_days, failed_max_age_days, keep_failed)
+instead of --older-than and --dead-only. With prune.auto set, the policy is
+also applied when the TUI starts and after each sync.
+
 By default, removes all completed and dead jobs. Use --older-than to
 filter by age.
 
@@ -28,32 +41,54 @@ Examples:
   remote-jobs prune --older-than 24h   # Only jobs older than 24 hours
   remote-jobs prune --dry-run          # Preview what would be deleted
   remote-jobs prune --dead-only        # Only remove dead jobs
-  remote-jobs prune --keep-files       # Don't delete remote files`,
+  remote-jobs prune --keep-files       # Don't delete remote files
+  remote-jobs prune --with-remote      # Also delete files on hosts that are down, once they're back
+  remote-jobs prune --with-remote --dry-run  # List the remote files that would be deleted
+  remote-jobs prune --apply-policy     # Apply the prune policy from config.yaml`,
 	RunE: runPrune,
 }
 
 var (
-	pruneOlderThan string
-	pruneDryRun    bool
-	pruneDeadOnly  bool
-	pruneKeepFiles bool
+	pruneOlderThan  string
+	pruneDryRun     bool
+	pruneDeadOnly   bool
+	pruneKeepFiles  bool
+	pruneWithRemote bool
+	pruneApply      bool
 )
 
+// pruneBatchSize is the most jobs whose files are deleted by one remote command
+const pruneBatchSize = 200
+
 func init() {
 	rootCmd.AddCommand(pruneCmd)
 	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Only remove jobs older than this duration (e.g., 7d, 24h, 30m)")
 	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Preview without actually deleting")
 	pruneCmd.Flags().BoolVar(&pruneDeadOnly, "dead-only", false, "Only remove dead jobs (not completed)")
 	pruneCmd.Flags().BoolVar(&pruneKeepFiles, "keep-files", false, "Don't delete remote log files")
+	pruneCmd.Flags().BoolVar(&pruneApply, "apply-policy", false, "Remove the jobs selected by the prune policy in config.yaml")
+	pruneCmd.Flags().BoolVar(&pruneWithRemote, "with-remote", false, "Defer deleting files on unreachable hosts until they're next synced")
 }
 
 func runPrune(cmd *cobra.Command, args []string) error {
+	if pruneWithRemote && pruneKeepFiles {
+		return fmt.Errorf("--with-remote and --keep-files cannot be used together")
+	}
+
+	if pruneApply && (pruneOlderThan != "" || pruneDeadOnly) {
+		return fmt.Errorf("--apply-policy cannot be combined with --older-than or --dead-only")
+	}
+
 	database, err := db.Open()
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
 
+	if pruneApply {
+		return applyPrunePolicy(database)
+	}
+
 	// Parse duration if specified
 	var olderThan *time.Time
 	if pruneOlderThan != "" {
@@ -80,37 +115,14 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	if pruneDryRun {
 		fmt.Printf("Would delete %d job(s):\n\n", len(jobs))
 		for _, job := range jobs {
-			startTime := time.Unix(job.StartTime, 0)
-			display := job.Description
-			if display == "" {
-				display = job.EffectiveCommand()
-				if len(display) > 30 {
-					display = display[:27] + "..."
-				}
-			}
-			fmt.Printf("  ID %d: %s (%s) - %s - %s\n",
-				job.ID, job.Host, job.Status,
-				startTime.Format("2006-01-02 15:04"), display)
-		}
-		if !pruneKeepFiles {
-			fmt.Println("\n(Would also delete associated log files on remote hosts)")
+			fmt.Println(pruneJobLine(job))
 		}
+		printPruneFilesPreview(jobs)
 		return nil
 	}
 
 	// Delete remote files first (before removing from DB)
-	if !pruneKeepFiles {
-		filesDeleted := 0
-		for _, job := range jobs {
-			deleted := deleteJobFiles(job)
-			if deleted {
-				filesDeleted++
-			}
-		}
-		if filesDeleted > 0 {
-			fmt.Printf("Deleted log files for %d job(s)\n", filesDeleted)
-		}
-	}
+	deletePrunedFiles(database, jobs)
 
 	// Actually prune from database
 	count, err := db.PruneJobs(database, pruneDeadOnly, olderThan)
@@ -127,39 +139,217 @@ func runPrune(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// deleteJobFiles deletes all files associated with a job on the remote host
-// Returns true if any files were deleted
-func deleteJobFiles(job *db.Job) bool {
-	// Determine file paths based on whether this is an old or new job
-	var logFile, statusFile, metadataFile string
+// applyPrunePolicy removes the jobs selected by the configured prune policy
+func applyPrunePolicy(database *sql.DB) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if !cfg.Prune.Enabled() {
+		return fmt.Errorf("no prune policy is configured (set prune.keep_last, prune.succeeded_max_age_days, or prune.failed_max_age_days in %s)", config.ConfigPath())
+	}
+
+	finished, err := db.ListFinishedJobs(database)
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	removals := prunepolicy.Select(cfg.Prune, finished, time.Now())
+	if len(removals) == 0 {
+		fmt.Println("No jobs to prune under the policy")
+		return nil
+	}
+
+	jobs := make([]*db.Job, len(removals))
+	for i, r := range removals {
+		jobs[i] = r.Job
+	}
+
+	if pruneDryRun {
+		fmt.Printf("Would delete %d job(s) (%s):\n\n", len(removals), prunepolicy.Summary(removals))
+		for _, r := range removals {
+			fmt.Printf("%s (%s)\n", pruneJobLine(r.Job), r.Reason)
+		}
+		printPruneFilesPreview(jobs)
+		return nil
+	}
+
+	deletePrunedFiles(database, jobs)
+
+	count, err := prunepolicy.Apply(database, removals, false)
+	if err != nil {
+		return fmt.Errorf("prune jobs: %w", err)
+	}
+	fmt.Printf("Pruned %d job(s) from database (%s)\n", count, prunepolicy.Summary(removals))
+	return nil
+}
+
+// autoPrune applies the prune policy if prune.auto is set, except in
+// read-only mode, deferring remote file deletes to each host's next sync,
+// and reports what it removed
+func autoPrune(database *sql.DB) {
+	cfg, err := config.Load()
+	if err != nil || !cfg.Prune.Auto || !cfg.Prune.Enabled() || readOnly {
+		return
+	}
+	finished, err := db.ListFinishedJobs(database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: auto-prune: %v\n", err)
+		return
+	}
+	removals := prunepolicy.Select(cfg.Prune, finished, time.Now())
+	if len(removals) == 0 {
+		return
+	}
+	count, err := prunepolicy.Apply(database, removals, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: auto-prune: %v\n", err)
+	}
+	if count > 0 {
+		fmt.Printf("Auto-pruned %d job(s) (%s)\n", count, prunepolicy.Summary(removals[:count]))
+	}
+}
+
+// pruneJobLine describes a job in a dry-run listing
+func pruneJobLine(job *db.Job) string {
+	startTime := time.Unix(job.StartTime, 0)
+	display := job.Description
+	if display == "" {
+		display = displayCommand(job)
+		if len(display) > 30 {
+			display = display[:27] + "..."
+		}
+	}
+	return fmt.Sprintf("  ID %d: %s (%s) - %s - %s",
+		job.ID, job.Host, job.Status,
+		startTime.Format("2006-01-02 15:04"), display)
+}
+
+// printPruneFilesPreview tells a dry run which remote files would be deleted
+func printPruneFilesPreview(jobs []*db.Job) {
+	if pruneWithRemote {
+		fmt.Println("\nWould delete remote files:")
+		for _, group := range groupJobsByHost(jobs) {
+			fmt.Printf("\n  %s:\n", group.host)
+			for _, job := range group.jobs {
+				for _, path := range jobFiles(job) {
+					fmt.Printf("    %s\n", path)
+				}
+			}
+		}
+	} else if !pruneKeepFiles {
+		fmt.Println("\n(Would also delete associated log files on remote hosts)")
+	}
+}
+
+// deletePrunedFiles deletes the remote files of jobs about to be pruned,
+// unless --keep-files is set
+func deletePrunedFiles(database *sql.DB, jobs []*db.Job) {
+	if pruneKeepFiles {
+		return
+	}
+	filesDeleted, deferred := deleteRemoteFiles(database, jobs, pruneWithRemote)
+	if filesDeleted > 0 {
+		fmt.Printf("Deleted log files for %d job(s)\n", filesDeleted)
+	}
+	if deferred > 0 {
+		fmt.Printf("Deferred deleting log files for %d job(s) on unreachable hosts until their next sync\n", deferred)
+	}
+}
+
+// hostJobs is the jobs on one host
+type hostJobs struct {
+	host string
+	jobs []*db.Job
+}
+
+// groupJobsByHost groups jobs by the host their files are on, as the
+// account they ran as (see jobHost), in order of each host's first job
+func groupJobsByHost(jobs []*db.Job) []hostJobs {
+	var groups []hostJobs
+	index := make(map[string]int)
+	for _, job := range jobs {
+		host := jobHost(job)
+		i, ok := index[host]
+		if !ok {
+			i = len(groups)
+			index[host] = i
+			groups = append(groups, hostJobs{host: host})
+		}
+		groups[i].jobs = append(groups[i].jobs, job)
+	}
+	return groups
+}
+
+// jobFiles returns the paths of a job's files on its host
+func jobFiles(job *db.Job) []string {
 	if job.SessionName != "" {
 		// Old job with session name - use legacy paths
-		logFile = session.LegacyLogFile(job.SessionName)
-		statusFile = session.LegacyStatusFile(job.SessionName)
-		metadataFile = session.LegacyMetadataFile(job.SessionName)
-	} else {
-		// New job - use ID-based paths
-		logFile = session.LogFile(job.ID, job.StartTime)
-		statusFile = session.StatusFile(job.ID, job.StartTime)
-		metadataFile = session.MetadataFile(job.ID, job.StartTime)
-	}
-
-	// Build delete command
-	// Note: paths not quoted to allow tilde expansion
-	deleteCmd := fmt.Sprintf("rm -f %s %s %s 2>/dev/null", logFile, statusFile, metadataFile)
-
-	// Try to delete - silently ignore connection errors
-	_, _, err := ssh.Run(job.Host, deleteCmd)
-	if err != nil {
-		// Check if it's a connection error (silently ignore)
-		if ssh.IsConnectionError(err.Error()) {
-			return false
+		return []string{
+			session.LegacyLogFile(job.SessionName),
+			session.LegacyStatusFile(job.SessionName),
+			session.LegacyMetadataFile(job.SessionName),
 		}
-		fmt.Fprintf(os.Stderr, "Warning: failed to delete files for job %d on %s: %v\n", job.ID, job.Host, err)
-		return false
 	}
+	return []string{
+		session.LogFile(job.ID, job.StartTime),
+		session.StatusFile(job.ID, job.StartTime),
+		session.MetadataFile(job.ID, job.StartTime),
+		session.PidFile(job.ID, job.StartTime),
+	}
+}
+
+// deleteRemoteFiles deletes the files of jobs on their hosts, batching the
+// jobs on each host into as few commands as possible. Unreachable hosts are
+// skipped; if deferUnreachable is set, a deferred operation is recorded for
+// each of their jobs so the files are deleted on the host's next sync.
+// Returns the number of jobs whose files were deleted and deferred.
+func deleteRemoteFiles(database *sql.DB, jobs []*db.Job, deferUnreachable bool) (deleted, deferred int) {
+	for _, group := range groupJobsByHost(jobs) {
+		for start := 0; start < len(group.jobs); start += pruneBatchSize {
+			batch := group.jobs[start:min(start+pruneBatchSize, len(group.jobs))]
+
+			// Note: paths not quoted to allow tilde expansion
+			var paths []string
+			for _, job := range batch {
+				paths = append(paths, jobFiles(job)...)
+			}
+			deleteCmd := fmt.Sprintf("rm -f %s 2>/dev/null", strings.Join(paths, " "))
 
-	return true
+			_, stderr, err := ssh.Run(group.host, deleteCmd)
+			if err == nil {
+				deleted += len(batch)
+				continue
+			}
+			if !ssh.IsConnectionError(stderr) {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete files on %s: %s\n", group.host, ssh.FriendlyError(group.host, stderr, err))
+				continue
+			}
+			if deferUnreachable {
+				deferred += deferFileDeletes(database, group.host, group.jobs[start:])
+			}
+			// Don't retry an unreachable host for the rest of its jobs
+			break
+		}
+	}
+	return deleted, deferred
+}
+
+// deferFileDeletes records deferred operations to delete the files of jobs on
+// host, and returns how many were recorded. Legacy jobs are skipped: their
+// file names can't be derived from the job ID once the job is deleted.
+func deferFileDeletes(database *sql.DB, host string, jobs []*db.Job) int {
+	count := 0
+	for _, job := range jobs {
+		if job.SessionName != "" {
+			continue
+		}
+		if err := db.AddDeferredOperation(database, host, db.OpDeleteFiles, job.ID, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to defer deleting files for job %d: %v\n", job.ID, err)
+			continue
+		}
+		count++
+	}
+	return count
 }
 
 // parseDuration parses a duration string, supporting "d" suffix for days
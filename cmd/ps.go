@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/overview"
+	"github.com/osteele/remote-jobs/internal/reachability"
+	"github.com/spf13/cobra"
+)
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "Show running jobs, queues, and hosts at a glance",
+	Long: `Show what is going on right now: a quick sync of running jobs, then
+the jobs that are running or paused and those that finished recently, and
+for each host involved, how many jobs are running and queued and whether
+it answered when last tried.
+
+Hosts that failed recently aren't waited on; their jobs are shown as last
+synced. Use --no-sync to only read the local database.
+
+Examples:
+  remote-jobs ps                  # Running jobs and those finished in the last hour
+  remote-jobs ps --since 1d       # ...and those finished in the last day
+  remote-jobs ps --host cool30    # Only cool30
+  remote-jobs ps --no-sync        # Don't contact hosts`,
+	Args: cobra.NoArgs,
+	RunE: runPs,
+}
+
+var (
+	psNoSync bool
+	psSince  string
+	psHost   string
+)
+
+// psColumns are the job table's columns (see list --columns)
+var psColumns = []string{"id", "host", "status", "duration", "command"}
+
+func init() {
+	rootCmd.AddCommand(psCmd)
+	psCmd.Flags().BoolVar(&psNoSync, "no-sync", false, "Don't sync with hosts first")
+	psCmd.Flags().StringVar(&psSince, "since", "1h", "Also show jobs that finished within this long (e.g. 30m, 1d)")
+	psCmd.Flags().StringVar(&psHost, "host", "", "Only show this host")
+}
+
+func runPs(cmd *cobra.Command, args []string) error {
+	since, err := parseDuration(psSince)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+	defer waitForDeliveries()
+
+	if !psNoSync {
+		// Just a status command: unlike list, it doesn't start queue runners
+		if !performFastSync(database, false) {
+			fmt.Println("(some hosts didn't answer in time; their jobs are shown as last synced)")
+		}
+	}
+
+	now := time.Now()
+	o, err := buildOverview(database, psHost, now.Add(-since))
+	if err != nil {
+		return err
+	}
+
+	if len(o.Jobs) == 0 {
+		fmt.Printf("No jobs running or finished in the last %s\n", psSince)
+	} else if err := printJobs(database, o.Jobs, psColumns, nil); err != nil {
+		return err
+	}
+	if len(o.Hosts) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tSTATUS\tRUNNING\tQUEUED\tPENDING")
+	for _, h := range o.Hosts {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\n", h.Name, h.Status(now), h.Active, h.QueueSummary(), h.Pending)
+	}
+	return w.Flush()
+}
+
+// buildOverview summarizes the unfinished and pending jobs on host (or every
+// host, if it is empty), and those that finished since a time. Jobs held
+// for a dependency on another host aren't counted as pending (see
+// db.ListPending), since they wait for that job rather than to be started.
+func buildOverview(database *sql.DB, host string, since time.Time) (overview.Overview, error) {
+	unfinished, err := db.ListUnfinished(database, host)
+	if err != nil {
+		return overview.Overview{}, err
+	}
+	pending, err := db.ListPending(database, host)
+	if err != nil {
+		return overview.Overview{}, err
+	}
+	finished, err := db.ListFinishedSince(database, since)
+	if err != nil {
+		return overview.Overview{}, err
+	}
+	jobs := append(unfinished, pending...)
+	for _, job := range finished {
+		if host == "" || job.Host == host {
+			jobs = append(jobs, job)
+		}
+	}
+	reach, err := db.ListHostReachability(database)
+	if err != nil {
+		return overview.Overview{}, err
+	}
+	return overview.Build(jobs, reach, reachability.LoadLatencies(database)), nil
+}
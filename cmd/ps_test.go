@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestBuildOverviewLeavesHeldJobsOutOfPending(t *testing.T) {
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	if _, err := db.RecordHeld(database, "cool30", "~/code", "make", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	held, err := db.RecordHeld(database, "cool30", "~/code", "make eval", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddPendingDependency(database, held, 99, false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := buildOverview(database, "", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Hosts) != 1 || o.Hosts[0].Pending != 1 {
+		t.Errorf("hosts = %+v, want cool30 with 1 pending job", o.Hosts)
+	}
+}
@@ -1,13 +1,20 @@
 package cmd
 
 import (
+	"database/sql"
+	"encoding/base64"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/osteele/remote-jobs/internal/availability"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/preset"
 	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
 )
@@ -16,6 +23,11 @@ const (
 	defaultQueueName = "default"
 	queueDir         = "~/.cache/remote-jobs/queue"
 	queueRunnerPath  = "~/.cache/remote-jobs/scripts/queue-runner.sh"
+	queueWeightsFile = "~/.cache/remote-jobs/queue/weights"
+
+	// The shared runner serves every queue without its own runner, interleaved by weight
+	sharedRunnerSession = "rj-queues"
+	sharedRunnerName    = ".shared"
 )
 
 var queueCmd = &cobra.Command{
@@ -30,10 +42,12 @@ the remote host.
 Subcommands:
   add     Add a job to the queue
   remove  Remove a queued job before it starts
+  release Drop a queued job's --after dependency
   start   Start the queue runner
   stop    Stop the queue runner after current job
   list    List jobs in the queue
-  status  Show queue runner status`,
+  status  Show queue runner status
+  config  Set queue weights for the shared runner`,
 }
 
 var queueAddCmd = &cobra.Command{
@@ -48,9 +62,20 @@ Examples:
   remote-jobs queue add cool30 'python train.py --epochs 100'
   remote-jobs queue add -d "Training run 1" cool30 'python train.py'
   remote-jobs queue add -e CUDA_VISIBLE_DEVICES=0 cool30 'python train.py'
+  remote-jobs queue add --timeout 2h cool30 'python train.py'
   remote-jobs queue add --after 42 cool30 'python eval.py'  # Run after job 42 completes
-  remote-jobs queue add --queue gpu cool30 'python train.py'`,
-	Args: cobra.ExactArgs(2),
+  remote-jobs queue add --if 'test -f ~/data/ready.flag' cool30 'python train.py'
+  remote-jobs queue add --queue gpu cool30 'python train.py'
+  remote-jobs queue add 'python train.py'  # Host from .remote-jobs.toml
+
+Environment variables, the timeout, and the Slack notification settings
+(REMOTE_JOBS_SLACK_NOTIFY, REMOTE_JOBS_SLACK_MIN_DURATION, and
+REMOTE_JOBS_SLACK_VERBOSE) are recorded with the job when it is added, and
+the queue runner applies them when it starts the job, as run does.
+
+In a project with a .remote-jobs.toml, its settings are used as defaults, as
+with run.`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: runQueueAdd,
 }
 
@@ -64,9 +89,15 @@ It runs in a tmux session and continues running even when you disconnect.
 
 This command is idempotent - safe to call multiple times.
 
+With --shared, starts one runner that serves every queue on the host that
+doesn't have its own runner. It interleaves jobs from the queues by weight
+(see 'queue config'), so a queue of long sweeps doesn't block a queue of
+quick evaluations.
+
 Examples:
   remote-jobs queue start cool30
-  remote-jobs queue start --queue gpu cool30`,
+  remote-jobs queue start --queue gpu cool30
+  remote-jobs queue start --shared cool30`,
 	Args: cobra.ExactArgs(1),
 	RunE: runQueueStart,
 }
@@ -81,7 +112,8 @@ job finishes. The runner will exit gracefully.
 
 Examples:
   remote-jobs queue stop cool30
-  remote-jobs queue stop --queue gpu cool30`,
+  remote-jobs queue stop --queue gpu cool30
+  remote-jobs queue stop --shared cool30`,
 	Args: cobra.ExactArgs(1),
 	RunE: runQueueStop,
 }
@@ -112,6 +144,25 @@ Examples:
 	RunE: runQueueStatus,
 }
 
+var queueConfigCmd = &cobra.Command{
+	Use:   "config <host>",
+	Short: "Set queue weights for the shared runner",
+	Long: `Show or set the weights the shared queue runner uses to interleave
+queues on a host. A queue with weight 3 gets three jobs started for each
+job from a queue with weight 1. Queues without a weight have weight 1.
+
+Weights are stored on the remote host and read before each job, so changes
+take effect without restarting the runner.
+
+Examples:
+  remote-jobs queue config cool30                       # Show weights
+  remote-jobs queue config cool30 --weight gpu=3        # Set one weight
+  remote-jobs queue config cool30 --weight gpu=3 --weight cpu=1
+  remote-jobs queue config cool30 --weight gpu=1        # Back to the default`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQueueConfig,
+}
+
 var queueRemoveCmd = &cobra.Command{
 	Use:   "remove <job-id>...",
 	Short: "Remove one or more queued jobs",
@@ -128,14 +179,41 @@ Examples:
 	RunE: runQueueRemove,
 }
 
+var queueReleaseCmd = &cobra.Command{
+	Use:   "release <job-id>...",
+	Short: "Drop the --after dependency of queued jobs",
+	Long: `Drop the --after dependency of queued jobs, so they no longer wait for
+another job. A released job keeps its place in the queue and runs when the
+queue runner reaches it; it isn't started immediately.
+
+If the host is unreachable, the dependency is dropped on the next sync.
+
+Examples:
+  remote-jobs queue release 123
+  remote-jobs queue release 123 124`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runQueueRelease,
+}
+
 var (
-	queueName        string
-	queueDir_        string
-	queueDescription string
-	queueEnvVars     []string
-	queueAfter       int64
-	queueAfterAny    int64
-	queueNoStart     bool
+	queueName         string
+	queueDir_         string
+	queueDescription  string
+	queueEnvVars      []string
+	queueTimeout      string
+	queueAfter        int64
+	queueAfterAny     int64
+	queueNoStart      bool
+	queueIgnoreLimits bool
+	queueShared       bool
+	queueWeights      []string
+	queueIf           string
+	queueIfFalse      string
+	queueArtifacts    []string
+	queueResults      []string
+	queueResultsFile  string
+	queueNoPreset     bool
+	queueExperiment   string
 )
 
 func init() {
@@ -146,6 +224,8 @@ func init() {
 	queueCmd.AddCommand(queueListCmd)
 	queueCmd.AddCommand(queueStatusCmd)
 	queueCmd.AddCommand(queueRemoveCmd)
+	queueCmd.AddCommand(queueReleaseCmd)
+	queueCmd.AddCommand(queueConfigCmd)
 
 	// Add flags to all subcommands
 	for _, cmd := range []*cobra.Command{queueAddCmd, queueStartCmd, queueStopCmd, queueListCmd, queueStatusCmd, queueRemoveCmd} {
@@ -155,22 +235,54 @@ func init() {
 	queueAddCmd.Flags().StringVarP(&queueDir_, "directory", "C", "", "Working directory (default: current directory path)")
 	queueAddCmd.Flags().StringVarP(&queueDescription, "description", "d", "", "Description of the job")
 	queueAddCmd.Flags().StringSliceVarP(&queueEnvVars, "env", "e", nil, "Environment variable (VAR=value), can be repeated")
+	queueAddCmd.Flags().StringVar(&queueTimeout, "timeout", "", "Kill job after duration, once it starts (e.g., \"2h\", \"30m\", \"1h30m\")")
 	queueAddCmd.Flags().Int64Var(&queueAfter, "after", 0, "Start job after another job succeeds (job ID)")
 	queueAddCmd.Flags().Int64Var(&queueAfterAny, "after-any", 0, "Start job after another job completes, success or failure (job ID)")
 	queueAddCmd.Flags().BoolVar(&queueNoStart, "no-start", false, "Don't auto-start the queue runner")
+	queueAddCmd.Flags().BoolVar(&queueIgnoreLimits, "ignore-limits", false, "Queue even if the queue is at its configured max_queue_depth")
+	queueAddCmd.Flags().StringArrayVar(&queueArtifacts, "artifact", nil, "Glob for output files to record when the job finishes (relative to the working directory), can be repeated")
+	queueAddCmd.Flags().StringArrayVar(&queueResults, "result", nil, "Regex whose named groups are metrics read from the end of the log when the job finishes, can be repeated")
+	queueAddCmd.Flags().StringVar(&queueResultsFile, "results-file", "", "JSON file of metrics to read when the job finishes (relative to the working directory)")
+	queueAddCmd.Flags().StringVar(&queueIf, "if", "", "Shell condition the queue runner checks just before starting the job")
+	queueAddCmd.Flags().BoolVar(&queueNoPreset, "no-preset", false, "Ignore the project's "+preset.FileName)
+	queueAddCmd.Flags().StringVar(&queueExperiment, "experiment", "", "Experiment the job belongs to, created if new (see 'remote-jobs experiment')")
+	queueAddCmd.Flags().StringVar(&queueIfFalse, "if-false", db.GuardRequeue, "What to do if the --if condition is false: requeue, skip, or fail")
+
+	queueStartCmd.Flags().BoolVar(&queueShared, "shared", false, "Start one runner for all queues, interleaved by weight")
+	queueStopCmd.Flags().BoolVar(&queueShared, "shared", false, "Stop the shared runner")
+	queueConfigCmd.Flags().StringArrayVar(&queueWeights, "weight", nil, "Queue weight (QUEUE=N), can be repeated")
 }
 
 func runQueueAdd(cmd *cobra.Command, args []string) error {
+	project, err := loadProjectPreset(queueNoPreset)
+	if err != nil {
+		return err
+	}
+	args = presetArgs(project, args, 2)
+	if len(args) < 2 {
+		return fmt.Errorf("requires host and command arguments (the host can be omitted if %s sets one)", preset.FileName)
+	}
 	host := args[0]
 	command := args[1]
 
-	// Set defaults
+	envVars := queueEnvVars
+	var tags []string
 	workingDir := queueDir_
+	if project != nil {
+		envVars = project.MergeEnv(envVars)
+		tags = project.MergeTags(nil)
+		command = project.WrapCommand(command)
+		if workingDir == "" {
+			workingDir = presetWorkingDir(project)
+		}
+	}
+
+	// Set defaults
 	if workingDir == "" {
 		var err error
-		workingDir, err = session.DefaultWorkingDir()
+		workingDir, err = defaultWorkingDir(host)
 		if err != nil {
-			return fmt.Errorf("get working dir: %w", err)
+			return err
 		}
 	}
 
@@ -189,15 +301,31 @@ func runQueueAdd(cmd *cobra.Command, args []string) error {
 		afterID = queueAfterAny
 	}
 
+	guard, err := jobGuard(queueIf, queueIfFalse)
+	if err != nil {
+		return err
+	}
+	resultSpec, err := resultSpecFlags(queueResults, queueResultsFile)
+	if err != nil {
+		return err
+	}
+
 	jobID, err := queueJob(database, queueJobOptions{
-		Host:        host,
-		WorkingDir:  workingDir,
-		Command:     command,
-		Description: queueDescription,
-		EnvVars:     queueEnvVars,
-		QueueName:   queueName,
-		AfterJobID:  afterID,
-		AfterAny:    queueAfterAny > 0,
+		Host:         host,
+		WorkingDir:   workingDir,
+		Command:      command,
+		Description:  queueDescription,
+		EnvVars:      envVars,
+		QueueName:    queueName,
+		AfterJobID:   afterID,
+		AfterAny:     queueAfterAny > 0,
+		IgnoreLimits: queueIgnoreLimits,
+		Guard:        guard,
+		Timeout:      queueTimeout,
+		Artifacts:    queueArtifacts,
+		Results:      resultSpec,
+		Tags:         tags,
+		Experiment:   queueExperiment,
 	})
 	if err != nil {
 		return err
@@ -209,8 +337,11 @@ func runQueueAdd(cmd *cobra.Command, args []string) error {
 	if queueDescription != "" {
 		fmt.Printf("  Description: %s\n", queueDescription)
 	}
-	if len(queueEnvVars) > 0 {
-		fmt.Printf("  Env vars: %s\n", strings.Join(queueEnvVars, ", "))
+	if len(envVars) > 0 {
+		fmt.Printf("  Env vars: %s\n", strings.Join(envVars, ", "))
+	}
+	if queueTimeout != "" {
+		fmt.Printf("  Timeout: %s\n", queueTimeout)
 	}
 	if queueAfter > 0 {
 		fmt.Printf("  After job: %d (will wait for success)\n", queueAfter)
@@ -218,6 +349,13 @@ func runQueueAdd(cmd *cobra.Command, args []string) error {
 	if queueAfterAny > 0 {
 		fmt.Printf("  After job: %d (will wait for completion)\n", queueAfterAny)
 	}
+	if guard != nil {
+		fmt.Printf("  If: %s\n", guard)
+	}
+	printHeldNote(database, jobID)
+	if opensAt := hostOpensAt(loadPlacementConfig(), host, time.Now()); !opensAt.IsZero() {
+		fmt.Printf("\n%s is outside its availability windows; the queue runner will start jobs %s\n", host, availability.FormatOpensAt(opensAt, time.Now()))
+	}
 
 	// Auto-start queue runner unless --no-start is specified
 	if !queueNoStart {
@@ -241,75 +379,120 @@ func runQueueAdd(cmd *cobra.Command, args []string) error {
 // ensureQueueRunnerStarted checks if the queue runner is running and starts it if not.
 // Returns (true, nil) if the runner was started, (false, nil) if already running,
 // or (false, error) if starting failed.
+// A queue served by the shared runner counts as running.
 func ensureQueueRunnerStarted(host, queue string) (bool, error) {
 	runnerSession := fmt.Sprintf("rj-queue-%s", queue)
-	exists, err := ssh.TmuxSessionExists(host, runnerSession)
+	for _, session := range []string{runnerSession, sharedRunnerSession} {
+		exists, err := ssh.TmuxSessionExists(host, session)
+		if err != nil {
+			return false, fmt.Errorf("check session: %w", err)
+		}
+		if exists {
+			return false, nil // Already running
+		}
+	}
+
+	if err := startQueueRunner(host, runnerSession, queue); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ensureSharedRunnerStarted starts the shared runner if it isn't running.
+// Returns whether it was started.
+func ensureSharedRunnerStarted(host string) (bool, error) {
+	exists, err := ssh.TmuxSessionExists(host, sharedRunnerSession)
 	if err != nil {
 		return false, fmt.Errorf("check session: %w", err)
 	}
-
 	if exists {
-		return false, nil // Already running
+		return false, nil
 	}
+	if err := startQueueRunner(host, sharedRunnerSession, "--shared"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
+// startQueueRunner deploys the queue runner script and starts it in a tmux
+// session, passing runnerArg (a queue name or --shared)
+func startQueueRunner(host, runnerSession, runnerArg string) error {
 	// Create directories on remote
 	scriptsDir := "~/.cache/remote-jobs/scripts"
-	mkdirCmd := fmt.Sprintf("mkdir -p %s %s", queueDir, scriptsDir)
+	mkdirCmd := fmt.Sprintf("mkdir -p %s %s && %s", queueDir, scriptsDir, hostAvailability(loadPlacementConfig(), host).InstallCommand())
 	if _, stderr, err := ssh.Run(host, mkdirCmd); err != nil {
-		return false, fmt.Errorf("create directories: %s", stderr)
+		return fmt.Errorf("create directories: %s", stderr)
 	}
 
 	// Deploy queue runner script
-	writeCmd := fmt.Sprintf("cat > %s << 'SCRIPT_EOF'\n%s\nSCRIPT_EOF", queueRunnerPath, string(queueRunnerScript))
+	writeCmd := shellquote.WriteFile(queueRunnerPath, string(queueRunnerScript))
 	if _, stderr, err := ssh.Run(host, writeCmd); err != nil {
-		return false, fmt.Errorf("write queue runner script: %s", stderr)
+		return fmt.Errorf("write queue runner script: %s", stderr)
 	}
 
 	// Make script executable
 	chmodCmd := fmt.Sprintf("chmod +x %s", queueRunnerPath)
 	if _, stderr, err := ssh.Run(host, chmodCmd); err != nil {
-		return false, fmt.Errorf("chmod script: %s", stderr)
+		return fmt.Errorf("chmod script: %s", stderr)
 	}
 
 	// Deploy notify script if Slack is configured
 	slackWebhook := getSlackWebhook()
 	if slackWebhook != "" {
-		notifyScript := "/tmp/remote-jobs-notify-slack.sh"
-		writeNotifyCmd := fmt.Sprintf("cat > '%s' << 'SCRIPT_EOF'\n%s\nSCRIPT_EOF", notifyScript, string(notifySlackScript))
+		writeNotifyCmd := shellquote.WriteFile(remoteNotifyScript, string(notifySlackScript))
 		if _, _, err := ssh.Run(host, writeNotifyCmd); err == nil {
-			ssh.Run(host, fmt.Sprintf("chmod +x '%s'", notifyScript))
+			ssh.Run(host, "chmod +x "+shellquote.Path(remoteNotifyScript))
 		}
 	}
 
 	// Build environment variables for the runner
 	envVars := ""
 	if slackWebhook != "" {
-		envVars = fmt.Sprintf("REMOTE_JOBS_SLACK_WEBHOOK='%s' ", slackWebhook)
+		envVars = shellquote.Assignment("REMOTE_JOBS_SLACK_WEBHOOK="+slackWebhook) + " "
 		if v := os.Getenv("REMOTE_JOBS_SLACK_VERBOSE"); v == "1" {
 			envVars += "REMOTE_JOBS_SLACK_VERBOSE=1 "
 		}
 		if v := os.Getenv("REMOTE_JOBS_SLACK_NOTIFY"); v != "" {
-			envVars += fmt.Sprintf("REMOTE_JOBS_SLACK_NOTIFY='%s' ", v)
+			envVars += shellquote.Assignment("REMOTE_JOBS_SLACK_NOTIFY="+v) + " "
 		}
 		if v := os.Getenv("REMOTE_JOBS_SLACK_MIN_DURATION"); v != "" {
-			envVars += fmt.Sprintf("REMOTE_JOBS_SLACK_MIN_DURATION='%s' ", v)
+			envVars += shellquote.Assignment("REMOTE_JOBS_SLACK_MIN_DURATION="+v) + " "
+		}
+		for _, assignment := range slackTemplateEnv() {
+			envVars += assignment + " "
 		}
 	}
 
 	// Start queue runner in tmux
-	runnerCmd := fmt.Sprintf("%s$HOME/.cache/remote-jobs/scripts/queue-runner.sh %s", envVars, queue)
-	tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", runnerSession, ssh.EscapeForSingleQuotes(runnerCmd))
+	runnerCmd := fmt.Sprintf("%s$HOME/.cache/remote-jobs/scripts/queue-runner.sh %s", envVars, shellquote.Quote(runnerArg))
+	tmuxCmd := fmt.Sprintf("tmux new-session -d -s %s bash -c %s", shellquote.Quote(runnerSession), shellquote.Quote(runnerCmd))
 
 	if _, stderr, err := ssh.Run(host, tmuxCmd); err != nil {
-		return false, fmt.Errorf("start queue runner: %s", stderr)
+		return fmt.Errorf("start queue runner: %s", stderr)
 	}
 
-	return true, nil
+	return nil
 }
 
 func runQueueStart(cmd *cobra.Command, args []string) error {
 	host := args[0]
 
+	if queueShared {
+		started, err := ensureSharedRunnerStarted(host)
+		if err != nil {
+			return err
+		}
+		if started {
+			fmt.Printf("Shared queue runner started on %s\n", host)
+			fmt.Printf("Session: %s\n", sharedRunnerSession)
+		} else {
+			fmt.Printf("Shared queue runner is already running on %s\n", host)
+		}
+		fmt.Println("\nIt serves every queue without its own runner, interleaved by weight.")
+		fmt.Printf("To set weights:\n  remote-jobs queue config %s --weight QUEUE=N\n", host)
+		return nil
+	}
+
 	started, err := ensureQueueRunnerStarted(host, queueName)
 	if err != nil {
 		return err
@@ -336,15 +519,24 @@ func runQueueStart(cmd *cobra.Command, args []string) error {
 func runQueueStop(cmd *cobra.Command, args []string) error {
 	host := args[0]
 
+	runnerName := queueName
+	if queueShared {
+		runnerName = sharedRunnerName
+	}
+
 	// Create stop signal file
-	stopFile := fmt.Sprintf("%s/%s.stop", queueDir, queueName)
+	stopFile := fmt.Sprintf("%s/%s.stop", queueDir, runnerName)
 	touchCmd := fmt.Sprintf("touch %s", stopFile)
 
 	if _, stderr, err := ssh.Run(host, touchCmd); err != nil {
 		return fmt.Errorf("create stop signal: %s", stderr)
 	}
 
-	fmt.Printf("Stop signal sent to queue '%s' on %s\n", queueName, host)
+	if queueShared {
+		fmt.Printf("Stop signal sent to the shared queue runner on %s\n", host)
+	} else {
+		fmt.Printf("Stop signal sent to queue '%s' on %s\n", queueName, host)
+	}
 	fmt.Println("The queue runner will exit after the current job completes.")
 
 	return nil
@@ -372,6 +564,12 @@ func runQueueList(cmd *cobra.Command, args []string) error {
 		fmt.Println()
 	}
 
+	// The database is only needed to show the state of dependencies
+	database, err := db.Open()
+	if err == nil {
+		defer database.Close()
+	}
+
 	lines := strings.Split(strings.TrimSpace(queueContents), "\n")
 	if len(lines) == 0 || (len(lines) == 1 && lines[0] == "") {
 		fmt.Println("Queue is empty")
@@ -381,7 +579,8 @@ func runQueueList(cmd *cobra.Command, args []string) error {
 			if line == "" {
 				continue
 			}
-			parts := strings.SplitN(line, "\t", 4)
+			// job_id, working_dir, command, description, env_vars_b64, after_job_id, ...
+			parts := strings.SplitN(line, "\t", 6)
 			if len(parts) >= 3 {
 				jobID := parts[0]
 				command := parseEffectiveCommand(parts[2])
@@ -390,17 +589,63 @@ func runQueueList(cmd *cobra.Command, args []string) error {
 					description = parts[3]
 				}
 				if description != "" {
-					fmt.Printf("  %d. [%s] %s - %s\n", i+1, jobID, description, truncate(command, 40))
+					fmt.Printf("  %d. [%s] %s - %s\n", i+1, jobID, description, truncate(redactText(command), 40))
 				} else {
-					fmt.Printf("  %d. [%s] %s\n", i+1, jobID, truncate(command, 60))
+					fmt.Printf("  %d. [%s] %s\n", i+1, jobID, truncate(redactText(command), 60))
+				}
+				if len(parts) == 6 {
+					fields := strings.Split(parts[5], "\t")
+					if dep := parseAfterField(fields[0]); dep != nil {
+						fmt.Printf("     waiting for %s\n", describeDependency(database, dep))
+					}
+					if guard := parseGuardFields(fields[1:]); guard != nil {
+						fmt.Printf("     if: %s\n", guard)
+					}
+					if len(fields) > 3 {
+						if settings := db.ParseQueueSettings(fields[3]); settings != nil && settings.Timeout != "" {
+							fmt.Printf("     timeout: %s\n", settings.Timeout)
+						}
+					}
 				}
 			}
 		}
 	}
 
+	if database != nil {
+		printHeldJobs(database, host, queueName)
+	}
+
 	return nil
 }
 
+// printHeldJobs lists jobs for a host's queue that are held locally until a
+// job on another host finishes
+func printHeldJobs(database *sql.DB, host, queue string) {
+	deps, err := db.ListPendingDependencies(database)
+	if err != nil {
+		return
+	}
+	var lines []string
+	for _, dep := range deps {
+		job, _ := db.GetJobByID(database, dep.JobID)
+		if job == nil || job.Host != host || job.QueueName != queue || job.Status != db.StatusPending {
+			continue
+		}
+		label := truncate(displayCommand(job), 60)
+		if job.Description != "" {
+			label = fmt.Sprintf("%s - %s", job.Description, truncate(displayCommand(job), 40))
+		}
+		lines = append(lines, fmt.Sprintf("  [%d] %s\n     waiting for %s", job.ID, label, describeDependency(database, &dep.JobDependency)))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Printf("\nHeld until jobs on other hosts finish (%d jobs):\n", len(lines))
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
 func runQueueStatus(cmd *cobra.Command, args []string) error {
 	host := args[0]
 
@@ -412,11 +657,20 @@ func runQueueStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("check session: %w", err)
 	}
 
+	sharedExists := false
+	if !exists {
+		sharedExists, _ = ssh.TmuxSessionExists(host, sharedRunnerSession)
+	}
+
 	fmt.Printf("Queue '%s' on %s:\n\n", queueName, host)
 
-	if exists {
+	switch {
+	case exists:
 		fmt.Println("Runner: ACTIVE")
-	} else {
+	case sharedExists:
+		weights, _ := readQueueWeights(host)
+		fmt.Printf("Runner: ACTIVE (shared, weight %d)\n", db.QueueWeight(weights, queueName))
+	default:
 		fmt.Println("Runner: STOPPED")
 	}
 
@@ -437,6 +691,15 @@ func runQueueStatus(cmd *cobra.Command, args []string) error {
 	countOutput = strings.TrimSpace(countOutput)
 	fmt.Printf("Jobs waiting: %s\n", countOutput)
 
+	if schedule := hostAvailability(loadPlacementConfig(), host); !schedule.IsZero() {
+		now := time.Now()
+		if schedule.Open(now) {
+			fmt.Printf("Availability: %s; open now\n", schedule)
+		} else {
+			fmt.Printf("Availability: %s; next open %s\n", schedule, availability.FormatOpensAt(schedule.NextOpen(now), now))
+		}
+	}
+
 	// Check for stop signal
 	stopFile := fmt.Sprintf("%s/%s.stop", queueDir, queueName)
 	stopExists, _, _ := ssh.Run(host, fmt.Sprintf("test -f %s && echo yes || echo no", stopFile))
@@ -447,6 +710,74 @@ func runQueueStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runQueueConfig(cmd *cobra.Command, args []string) error {
+	host := args[0]
+
+	weights, err := readQueueWeights(host)
+	if err != nil {
+		return err
+	}
+
+	if len(queueWeights) > 0 {
+		for _, spec := range queueWeights {
+			name, value, ok := strings.Cut(spec, "=")
+			weight, err := strconv.Atoi(value)
+			if !ok || name == "" || err != nil || weight < 1 {
+				return fmt.Errorf("invalid weight %q (expected QUEUE=N with N >= 1)", spec)
+			}
+			if weight == 1 {
+				delete(weights, name)
+			} else {
+				weights[name] = weight
+			}
+		}
+		if err := writeQueueWeights(host, weights); err != nil {
+			return err
+		}
+	}
+
+	if len(weights) == 0 {
+		fmt.Printf("All queues on %s have weight 1\n", host)
+		return nil
+	}
+	fmt.Printf("Queue weights on %s (others have weight 1):\n", host)
+	for _, name := range sortedKeys(weights) {
+		fmt.Printf("  %s=%d\n", name, weights[name])
+	}
+	return nil
+}
+
+// readQueueWeights reads the shared runner's queue weights from a host
+func readQueueWeights(host string) (map[string]int, error) {
+	stdout, stderr, err := ssh.Run(host, fmt.Sprintf("cat %s 2>/dev/null || true", queueWeightsFile))
+	if err != nil {
+		return nil, fmt.Errorf("read queue weights: %s", ssh.FriendlyError(host, stderr, err))
+	}
+	return db.ParseQueueWeights(stdout), nil
+}
+
+// writeQueueWeights replaces the queue weights file on a host
+func writeQueueWeights(host string, weights map[string]int) error {
+	var lines []string
+	for _, name := range sortedKeys(weights) {
+		lines = append(lines, fmt.Sprintf("%s=%d", name, weights[name]))
+	}
+	cmd := fmt.Sprintf("mkdir -p %s && %s", queueDir, shellquote.WriteFile(queueWeightsFile, strings.Join(lines, "\n")))
+	if _, stderr, err := ssh.Run(host, cmd); err != nil {
+		return fmt.Errorf("write queue weights: %s", ssh.FriendlyError(host, stderr, err))
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func runQueueRemove(cmd *cobra.Command, args []string) error {
 	// Open database
 	database, err := db.Open()
@@ -455,11 +786,12 @@ func runQueueRemove(cmd *cobra.Command, args []string) error {
 	}
 	defer database.Close()
 
+	selector := jobSelector{}.resolver(database)
 	var errors []string
 	for _, arg := range args {
-		jobID, err := strconv.ParseInt(arg, 10, 64)
+		jobID, err := selector.resolve(arg)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("invalid job ID %s", arg))
+			errors = append(errors, err.Error())
 			continue
 		}
 
@@ -470,6 +802,17 @@ func runQueueRemove(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
+		// A job held for a job on another host is only in the local database
+		if dep, _ := db.GetPendingDependency(database, jobID); dep != nil && job.Status == db.StatusPending {
+			if err := db.DeleteJob(database, jobID); err != nil {
+				errors = append(errors, fmt.Sprintf("job %d: delete failed: %v", jobID, err))
+				continue
+			}
+			db.DeletePendingDependency(database, jobID)
+			fmt.Printf("Job %d removed (it was held for job %d)\n", jobID, dep.AfterJobID)
+			continue
+		}
+
 		// Check if job is queued (not yet started)
 		if job.Status != db.StatusQueued {
 			errors = append(errors, fmt.Sprintf("job %d has status '%s', can only remove queued jobs", jobID, job.Status))
@@ -483,13 +826,7 @@ func runQueueRemove(cmd *cobra.Command, args []string) error {
 		}
 
 		// Remove from remote queue file
-		// The queue file format is: job_id\tworking_dir\tcommand\tdescription
-		// We filter out lines starting with this job ID
-		queueFile := fmt.Sprintf("%s/%s.queue", queueDir, jobQueueName)
-		removeCmd := fmt.Sprintf("grep -v '^%d\\t' %s > %s.tmp 2>/dev/null && mv %s.tmp %s || true",
-			jobID, queueFile, queueFile, queueFile, queueFile)
-
-		_, stderr, err := ssh.Run(job.Host, removeCmd)
+		stdout, stderr, err := ssh.Run(jobHost(job), session.RemoveFromQueueCommand(jobID, jobQueueName))
 		if err != nil {
 			if ssh.IsConnectionError(stderr) {
 				// Host unreachable - add deferred operation
@@ -511,6 +848,11 @@ func runQueueRemove(cmd *cobra.Command, args []string) error {
 			errors = append(errors, fmt.Sprintf("job %d: failed to remove from remote queue: %s", jobID, strings.TrimSpace(stderr)))
 			continue
 		}
+		if strings.TrimSpace(stdout) == "missing" {
+			// The queue runner took it since the database was last synced
+			errors = append(errors, fmt.Sprintf("job %d is no longer in the queue; it may have started", jobID))
+			continue
+		}
 
 		// Delete from local database only after successful remote removal
 		if err := db.DeleteJob(database, jobID); err != nil {
@@ -527,6 +869,138 @@ func runQueueRemove(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runQueueRelease(cmd *cobra.Command, args []string) error {
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	selector := jobSelector{}.resolver(database)
+	var errors []string
+	for _, arg := range args {
+		jobID, err := selector.resolve(arg)
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+
+		job, err := db.GetJobByID(database, jobID)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("job %d not found", jobID))
+			continue
+		}
+		// A job held for a job on another host isn't in the remote queue yet
+		if dep, _ := db.GetPendingDependency(database, jobID); dep != nil && job.Status == db.StatusPending {
+			if err := submitHeldJob(database, job, dep); err != nil {
+				errors = append(errors, fmt.Sprintf("job %d: %v", jobID, err))
+				continue
+			}
+			fmt.Printf("Job %d released and added to its queue on %s\n", jobID, job.Host)
+			continue
+		}
+		if job.Status != db.StatusQueued {
+			errors = append(errors, fmt.Sprintf("job %d has status '%s', can only release queued jobs", jobID, job.Status))
+			continue
+		}
+
+		jobQueueName := job.QueueName
+		if jobQueueName == "" {
+			jobQueueName = queueName
+		}
+
+		stdout, stderr, err := ssh.Run(jobHost(job), releaseDependencyCommand(jobQueueName, jobID))
+		if err != nil {
+			if !ssh.IsConnectionError(stderr) {
+				errors = append(errors, fmt.Sprintf("job %d: failed to update remote queue: %s", jobID, strings.TrimSpace(stderr)))
+				continue
+			}
+			fmt.Printf("Host %s unreachable, will release on next sync\n", job.Host)
+			if err := db.AddDeferredOperation(database, job.Host, db.OpReleaseDependency, jobID, jobQueueName); err != nil {
+				errors = append(errors, fmt.Sprintf("job %d: failed to add deferred operation: %v", jobID, err))
+				continue
+			}
+		} else {
+			switch strings.TrimSpace(stdout) {
+			case "released":
+			case "not_waiting":
+				errors = append(errors, fmt.Sprintf("job %d isn't waiting for another job in queue '%s' on %s", jobID, jobQueueName, job.Host))
+				db.DeleteJobDependency(database, jobID)
+				continue
+			default:
+				// A runner has it out of the queue, checking whether it can run
+				errors = append(errors, fmt.Sprintf("job %d isn't in queue '%s' on %s; it may be starting, so try again", jobID, jobQueueName, job.Host))
+				continue
+			}
+		}
+
+		if err := db.DeleteJobDependency(database, jobID); err != nil {
+			errors = append(errors, fmt.Sprintf("job %d: failed to clear dependency: %v", jobID, err))
+			continue
+		}
+		fmt.Printf("Job %d released; it will run when queue '%s' on %s reaches it\n", jobID, jobQueueName, job.Host)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// releaseDependencyCommand returns a command that clears the after_job_id
+// field of a job's line in a queue file, leaving the line in place, under
+// the queue's lock. It prints "released" if the job was waiting for another
+// job, "not_waiting" if it wasn't, and "missing" if the job isn't in the
+// queue, as while a runner is checking its dependency.
+func releaseDependencyCommand(queueName string, jobID int64) string {
+	queueFile := session.QueueFile(queueName)
+	file, tmp := shellquote.Path(queueFile), shellquote.Path(queueFile+".tmp")
+	script := fmt.Sprintf(`$1 == "%d" { seen = 1; if ($6 != "") { $6 = ""; found = 1 } } { print } `+
+		`END { exit found ? 0 : seen ? 3 : 1 }`, jobID)
+	release := fmt.Sprintf("awk -F'\\t' -v OFS='\\t' %s %s > %s; rc=$?; "+
+		`if [ $rc = 0 ]; then mv %s %s && echo released; else rm -f %s; if [ $rc = 3 ]; then echo not_waiting; else echo missing; fi; fi`,
+		shellquote.Quote(script), file, tmp, tmp, file, tmp)
+	return fmt.Sprintf("if [ -f %s ]; then %s; else echo missing; fi", file, session.LockedQueueCommand(queueName, release))
+}
+
+// parseAfterField parses the after_job_id field of a queue line: "ID" or
+// "ID:any". Returns nil if the field is empty or malformed.
+func parseAfterField(field string) *db.JobDependency {
+	idStr, mode, _ := strings.Cut(strings.TrimSpace(field), ":")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		return nil
+	}
+	return &db.JobDependency{AfterJobID: id, AfterAny: mode == "any"}
+}
+
+// parseGuardFields parses the guard_b64 and guard_policy fields of a queue
+// line. Returns nil if the job has no guard.
+func parseGuardFields(fields []string) *db.JobGuard {
+	if len(fields) == 0 || fields[0] == "" {
+		return nil
+	}
+	command, err := base64.StdEncoding.DecodeString(fields[0])
+	if err != nil {
+		return nil
+	}
+	policy := db.GuardRequeue
+	if len(fields) > 1 && fields[1] != "" {
+		policy = fields[1]
+	}
+	return &db.JobGuard{Command: string(command), IfFalse: policy}
+}
+
+// describeDependency describes what a queued job is waiting for, including
+// the upstream job's state if it's in the database
+func describeDependency(database *sql.DB, dep *db.JobDependency) string {
+	var upstream *db.Job
+	if database != nil {
+		upstream, _ = db.GetJobByID(database, dep.AfterJobID)
+	}
+	return dep.Describe(upstream)
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReleaseDependencyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	home := t.TempDir()
+	queueFile := filepath.Join(home, ".cache", "remote-jobs", "queue", "default.queue")
+	if err := os.MkdirAll(filepath.Dir(queueFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	queue := "1\t~/code\tmake\t\t\t7:any\n2\t~/code\tmake\t\t\t\n"
+	if err := os.WriteFile(queueFile, []byte(queue), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	release := func(jobID int64) string {
+		t.Helper()
+		cmd := exec.Command("sh", "-c", releaseDependencyCommand("default", jobID))
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("release %d: %v\n%s", jobID, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	tests := []struct {
+		jobID int64
+		want  string
+	}{
+		{1, "released"},
+		{1, "not_waiting"},
+		{2, "not_waiting"},
+		{3, "missing"},
+	}
+	for _, tt := range tests {
+		if got := release(tt.jobID); got != tt.want {
+			t.Errorf("release %d = %q, want %q", tt.jobID, got, tt.want)
+		}
+	}
+	want := "1\t~/code\tmake\t\t\t\n2\t~/code\tmake\t\t\t\n"
+	if got, err := os.ReadFile(queueFile); err != nil || string(got) != want {
+		t.Errorf("queue file = %q, %v; want %q", got, err, want)
+	}
+}
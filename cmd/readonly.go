@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	readOnlyFlag bool
+
+	// readOnly is set by --read-only or read_only in config.yaml
+	readOnly bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&readOnlyFlag, "read-only", false,
+		"Refuse commands that change jobs, queues, or hosts, such as run, kill, and remove (or set read_only in config.yaml)")
+}
+
+// readOnlyCommands are the commands that read-only mode allows, by their
+// path below remote-jobs. A command with a check is allowed only when the
+// check passes, so that flags that change jobs stay refused.
+var readOnlyCommands = map[string]func(cmd *cobra.Command, args []string) bool{
+	"version":           nil,
+	"help":              nil,
+	"completion":        nil,
+	"list":              withoutFlags("cleanup"),
+	"status":            nil,
+	"log":               nil,
+	"ps":                nil,
+	"sync":              nil,
+	"events":            withoutFlags("sync"),
+	"report":            nil,
+	"leaderboard":       nil,
+	"diff":              nil,
+	"export":            nil,
+	"fetch":             nil,
+	"tray":              nil,
+	"tui":               nil,
+	"note":              withFlags("show"),
+	"job list":          withoutFlags("cleanup"),
+	"job status":        nil,
+	"job log":           nil,
+	"queue list":        nil,
+	"queue status":      nil,
+	"host info":         nil,
+	"host jobs":         nil,
+	"host load":         nil,
+	"host events":       nil,
+	"host gpus":         nil,
+	"host tags":         nil,
+	"shell list":        nil,
+	"experiment list":   nil,
+	"experiment status": nil,
+	"plan validate":     nil,
+	"db check":          nil,
+	"db backup":         nil,
+	"__complete":        nil,
+	"__completeNoDesc":  nil,
+}
+
+// withoutFlags returns a check that passes unless one of the flags is set
+func withoutFlags(names ...string) func(*cobra.Command, []string) bool {
+	return func(cmd *cobra.Command, args []string) bool {
+		for _, name := range names {
+			if cmd.Flags().Changed(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// withFlags returns a check that passes if one of the flags is set
+func withFlags(names ...string) func(*cobra.Command, []string) bool {
+	return func(cmd *cobra.Command, args []string) bool {
+		return !withoutFlags(names...)(cmd, args)
+	}
+}
+
+// checkReadOnly sets readOnly from the flag and config, and returns an error
+// if read-only mode refuses the command
+func checkReadOnly(cmd *cobra.Command, args []string, cfg *config.Config) error {
+	readOnly = readOnlyFlag || cfg.ReadOnly
+	if !readOnly {
+		return nil
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+	if path == "" {
+		return nil
+	}
+	for p := path; p != ""; p = parentPath(p) {
+		if check, ok := readOnlyCommands[p]; ok && (p == path || check == nil) {
+			if check == nil || check(cmd, args) {
+				return nil
+			}
+			return fmt.Errorf("%s with these flags is not allowed in read-only mode (remove --read-only, or read_only from config.yaml)", cmd.CommandPath())
+		}
+	}
+	return fmt.Errorf("%s is not allowed in read-only mode (remove --read-only, or read_only from config.yaml)", cmd.CommandPath())
+}
+
+// parentPath returns the path of a command's parent, or "" for a top-level one
+func parentPath(path string) string {
+	i := strings.LastIndex(path, " ")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+// readOnlyWatchdog returns the watchdog settings to use: in read-only mode,
+// the kill action only warns
+func readOnlyWatchdog(wd config.Watchdog) config.Watchdog {
+	if readOnly && wd.Action == config.WatchdogKill {
+		wd.Action = config.WatchdogWarn
+	}
+	return wd
+}
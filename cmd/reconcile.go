@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// printReconciliations explains, for status --explain, the status changes
+// that checks of a job's host made and what those checks found
+func printReconciliations(database *sql.DB, job *db.Job) error {
+	recs, err := db.GetReconciliations(database, job.ID)
+	if err != nil {
+		return fmt.Errorf("get reconciliation log: %w", err)
+	}
+	if len(recs) == 0 {
+		fmt.Printf("\nNo checks of job %d's host have changed its status.\n", job.ID)
+		if job.Status == db.StatusDead {
+			fmt.Println("It was marked dead by a command such as kill, or before checks were recorded.")
+		}
+		return nil
+	}
+	zone := jobHostZone(database, job.ID)
+	for _, r := range recs {
+		fmt.Printf("\n%s: %s marked the job %s (was %s) after checking:\n",
+			displayTimes.Full(r.At, zone), r.Source, r.To, r.From)
+		for _, e := range r.Evidence {
+			fmt.Printf("  %s: %s\n", e.Check, e.Result)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/redact"
+)
+
+var (
+	redactorOnce sync.Once
+	// displayRedactor hides credentials in printed and exported text; it is
+	// built from the redact config on first use
+	displayRedactor *redact.Redactor
+)
+
+// redactor returns the redactor for printed and exported text. If the
+// config can't be loaded or has an invalid pattern, the builtin patterns
+// are used.
+func redactor() *redact.Redactor {
+	redactorOnce.Do(func() {
+		cfg, err := config.Load()
+		if err == nil {
+			displayRedactor, err = cfg.Redactor()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: redact: %v\n", err)
+			displayRedactor, _ = redact.New(nil, true)
+		}
+	})
+	return displayRedactor
+}
+
+// redactText returns s with credentials redacted
+func redactText(s string) string {
+	return redactor().String(s)
+}
+
+// displayCommand returns a job's command for display, with credentials
+// redacted
+func displayCommand(job *db.Job) string {
+	return redactText(job.EffectiveCommand())
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/scripts"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var hostRelocateCmd = &cobra.Command{
+	Use:   "relocate <host>",
+	Short: "Move a host's job logs and queues under its cache_home",
+	Long: `Move remote-jobs' files on a host (job logs, queues, and deployed
+scripts) to a remote-jobs directory under the host's cache_home in
+config.yaml (hosts.NAME.cache_home, or the global cache_home), and link
+~/.cache/remote-jobs to it, for hosts whose home directories are too small.
+Without a cache_home, the files move back to ~/.cache/remote-jobs, or under
+$XDG_CACHE_HOME if the host sets it.
+
+Starting or queueing a job does this too, when the host's cache_home has
+changed since its files were last moved. Either way, files are only moved
+while none of the host's jobs are running, paused, or queued.
+
+Examples:
+  remote-jobs host relocate cluster1`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHostRelocate,
+}
+
+func init() {
+	hostCmd.AddCommand(hostRelocateCmd)
+}
+
+func runHostRelocate(cmd *cobra.Command, args []string) error {
+	host := args[0]
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	dir, err := relocateHostFiles(database, host, cfg.HostCacheHome(host))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("remote-jobs' files on %s are in %s\n", host, dir)
+	return nil
+}
+
+// relocateHostFiles moves a host's files under cacheHome ("" for the
+// default), returning the directory they are in
+func relocateHostFiles(database *sql.DB, host, cacheHome string) (string, error) {
+	// A job that is running, or that a queue runner could start, might
+	// write to a file while it is being copied
+	active, err := db.ListUnfinished(database, host)
+	if err != nil {
+		return "", err
+	}
+	if len(active) > 0 {
+		return "", fmt.Errorf("%s has %d unfinished job(s); move its files once they finish", host, len(active))
+	}
+
+	script := "sh -c " + shellquote.Quote(string(scripts.RelocateScript)) + " relocate " + shellquote.Quote(cacheHome)
+	stdout, stderr, err := ssh.Run(host, script)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr); msg != "" {
+			return "", fmt.Errorf("move files on %s: %s", host, msg)
+		}
+		return "", fmt.Errorf("move files on %s: %w", host, err)
+	}
+	if err := db.SetHostCacheHome(database, host, cacheHome); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// autoRelocate moves a host's files before a job is started or queued on
+// it, if the host's cache_home has changed since they were last moved. If
+// they can't be moved, as while other jobs are running, it warns, and the
+// job uses the files where they are.
+func autoRelocate(database *sql.DB, host string) {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	want := cfg.HostCacheHome(host)
+	have, err := db.GetHostCacheHome(database, host)
+	if err != nil || want == have {
+		return
+	}
+	dir, err := relocateHostFiles(database, host, want)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cache_home for %s changed, but its files weren't moved: %v\n", host, err)
+		return
+	}
+	fmt.Printf("Moved remote-jobs' files on %s to %s\n", host, dir)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Summarize job usage statistics",
+	Long: `Summarize recent jobs from the local database: job counts, success
+rate, total run time, GPU-hours, and median duration, grouped by host or
+status, followed by median durations per command template. Grouped by tag,
+a job with several tags is counted under each of them, so the groups can add
+up to more than the total, and untagged jobs are counted as "(none)".
+
+Command templates group runs that differ only in numbers or quoted
+arguments, so "python train.py --lr 0.01" and "python train.py --lr 0.001"
+are counted together as "python train.py --lr N".
+
+GPU-hours are estimated from the number of GPUs a job was last seen using,
+which is sampled while the TUI shows a running job.
+
+Examples:
+  remote-jobs report                  # Last 30 days, grouped by host
+  remote-jobs report --since 7d       # Last week
+  remote-jobs report --by status      # Grouped by outcome
+  remote-jobs report --by tag         # Grouped by tag`,
+	RunE: runReport,
+}
+
+var (
+	reportSince string
+	reportBy    string
+)
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVar(&reportSince, "since", "30d", "Only include jobs started within this duration (e.g., 30d, 24h)")
+	reportCmd.Flags().StringVar(&reportBy, "by", "host", "Group by: host, status, command, or tag")
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	var key report.KeyFunc
+	switch reportBy {
+	case "host":
+		key = report.ByHost
+	case "status":
+		key = report.ByStatus
+	case "command":
+		key = report.ByCommand
+	case "tag":
+		// Set once the jobs' tags are loaded
+	default:
+		return fmt.Errorf("invalid --by value %q (use host, status, command, or tag)", reportBy)
+	}
+
+	duration, err := parseDuration(reportSince)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w (examples: 30d, 24h)", reportSince, err)
+	}
+	since := time.Now().Add(-duration)
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	jobs, err := db.ListJobsSince(database, since)
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		fmt.Printf("No jobs since %s\n", since.Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	ids := make([]int64, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	stats, err := db.LoadJobStats(database, ids)
+	if err != nil {
+		return fmt.Errorf("load job stats: %w", err)
+	}
+	if reportBy == "tag" {
+		tags, err := db.LoadJobTags(database, ids)
+		if err != nil {
+			return fmt.Errorf("load job tags: %w", err)
+		}
+		key = report.ByTag(tags)
+	}
+
+	now := time.Now().Unix()
+	fmt.Printf("Jobs since %s\n\n", since.Format("2006-01-02 15:04"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tJOBS\tOK\tFAILED\tSKIPPED\tACTIVE\tSUCCESS\tTOTAL TIME\tGPU-H\tMEDIAN\n", reportKeyHeader(reportBy))
+	for _, row := range report.Summarize(jobs, key, stats, now) {
+		printReportRow(w, row.Key, row)
+	}
+	printReportRow(w, "TOTAL", report.Total(jobs, stats, now))
+	w.Flush()
+
+	if reportBy == "command" {
+		return nil
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND TEMPLATE\tJOBS\tSUCCESS\tMEDIAN")
+	for _, row := range report.Summarize(jobs, report.ByCommand, stats, now) {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n",
+			truncate(row.Key, 60), row.Jobs, formatSuccessRate(row), humanfmt.DurationShort(row.MedianSeconds))
+	}
+	w.Flush()
+	return nil
+}
+
+func reportKeyHeader(by string) string {
+	switch by {
+	case "status":
+		return "STATUS"
+	case "command":
+		return "COMMAND TEMPLATE"
+	case "tag":
+		return "TAG"
+	default:
+		return "HOST"
+	}
+}
+
+func printReportRow(w *tabwriter.Writer, label string, row *report.Row) {
+	if reportBy == "command" {
+		label = truncate(label, 60)
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%.1f\t%s\n",
+		label, row.Jobs, row.Succeeded, row.Failed, row.Skipped, row.Running,
+		formatSuccessRate(row), humanfmt.DurationShort(row.TotalSeconds),
+		row.GPUHours(), humanfmt.DurationShort(row.MedianSeconds))
+}
+
+func formatSuccessRate(row *report.Row) string {
+	rate := row.SuccessRate()
+	if rate < 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
@@ -7,7 +7,9 @@ import (
 	"strings"
 
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/jobstate"
 	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
 )
@@ -22,14 +24,19 @@ with the same command and working directory. Creates a new job ID for each.
 
 Examples:
   remote-jobs restart 42
-  remote-jobs restart 42 43 44`,
+  remote-jobs restart 42 43 44
+  remote-jobs restart @failed     # The job that failed most recently`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runRestart,
 }
 
+var restartIgnoreLimits bool
+
 func init() {
 	// Removed: Restart command is now only available as `job restart`
 	// rootCmd.AddCommand(restartCmd)
+
+	restartCmd.Flags().BoolVar(&restartIgnoreLimits, "ignore-limits", false, "Restart even if the host's configured job limits are reached")
 }
 
 func runRestart(cmd *cobra.Command, args []string) error {
@@ -39,15 +46,16 @@ func runRestart(cmd *cobra.Command, args []string) error {
 	}
 	defer database.Close()
 
+	selector := jobSelector{}.resolver(database)
 	var errors []string
 	for i, arg := range args {
 		if i > 0 {
 			fmt.Println("---")
 		}
 
-		jobID, err := strconv.ParseInt(arg, 10, 64)
+		jobID, err := selector.resolve(arg)
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("invalid job ID %s", arg))
+			errors = append(errors, err.Error())
 			continue
 		}
 
@@ -75,7 +83,7 @@ func restartSingleJob(database *sql.DB, jobID int64) error {
 
 	// Read metadata from remote (for additional info)
 	metadataFile := session.JobMetadataFile(job.ID, job.StartTime, job.SessionName)
-	content, _ := ssh.ReadRemoteFile(job.Host, metadataFile)
+	content, _ := ssh.ReadRemoteFile(jobHost(job), metadataFile)
 
 	workingDir := job.WorkingDir
 	command := job.Command
@@ -97,6 +105,20 @@ func restartSingleJob(database *sql.DB, jobID int64) error {
 	if workingDir == "" || command == "" {
 		return fmt.Errorf("missing working directory or command")
 	}
+	// Read the job's secrets before stopping it, in case one is gone
+	secretNames, secretValues, err := lookupJobSecrets(database, job.ID)
+	if err != nil {
+		return err
+	}
+
+	if !restartIgnoreLimits {
+		if err := checkRestartLimit(database, job); err != nil {
+			return err
+		}
+		if err := checkDiskLimit(job.Host, workingDir); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("Restarting job %d on %s\n", jobID, job.Host)
 	fmt.Printf("Working directory: %s\n", workingDir)
@@ -106,12 +128,21 @@ func restartSingleJob(database *sql.DB, jobID int64) error {
 	}
 
 	// Kill existing session if running
-	oldTmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-	exists, _ := ssh.TmuxSessionExists(job.Host, oldTmuxSession)
-	if exists {
-		fmt.Printf("Killing existing session...\n")
-		if err := ssh.TmuxKillSession(job.Host, oldTmuxSession); err != nil {
-			return fmt.Errorf("kill session: %w", err)
+	if jobstate.RunsWithoutTmux(database, job.ID) {
+		if running, _ := nohupJobRunning(jobHost(job), job.ID); running {
+			fmt.Printf("Killing existing process...\n")
+			if _, stderr, err := ssh.Run(jobHost(job), session.SignalJobCommand(job.ID, "TERM")); err != nil {
+				return fmt.Errorf("kill process: %s", ssh.FriendlyError(job.Host, stderr, err))
+			}
+		}
+	} else {
+		oldTmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
+		exists, _ := ssh.TmuxSessionExists(jobHost(job), oldTmuxSession)
+		if exists {
+			fmt.Printf("Killing existing session...\n")
+			if err := ssh.TmuxKillSession(jobHost(job), oldTmuxSession); err != nil {
+				return fmt.Errorf("kill session: %w", err)
+			}
 		}
 	}
 
@@ -120,6 +151,8 @@ func restartSingleJob(database *sql.DB, jobID int64) error {
 	if err != nil {
 		return fmt.Errorf("create job record: %w", err)
 	}
+	saveJobRemoteUser(database, newJobID, jobHost(job))
+	saveJobSecrets(database, newJobID, secretNames)
 
 	// Get the new job to access start time
 	newJob, err := db.GetJobByID(database, newJobID)
@@ -133,37 +166,44 @@ func restartSingleJob(database *sql.DB, jobID int64) error {
 	statusFile := session.StatusFile(newJobID, newJob.StartTime)
 	newMetadataFile := session.MetadataFile(newJobID, newJob.StartTime)
 	pidFile := session.PidFile(newJobID, newJob.StartTime)
+	secretsFile := ""
+	if len(secretValues) > 0 {
+		secretsFile = session.SecretsFile(newJobID, newJob.StartTime)
+	}
 
-	// Create log directory on remote
-	mkdirCmd := fmt.Sprintf("mkdir -p %s", session.LogDir)
-	if _, stderr, err := ssh.RunWithRetry(job.Host, mkdirCmd); err != nil {
+	// Create log directory on remote, checking for tmux
+	mkdirCmd := fmt.Sprintf("mkdir -p %s && %s", session.LogDir, session.TmuxProbeCommand)
+	stdout, stderr, err := ssh.RunWithRetry(jobHost(job), mkdirCmd)
+	if err != nil {
 		errMsg := ssh.FriendlyError(job.Host, stderr, err)
 		db.UpdateJobFailed(database, newJobID, errMsg)
 		return fmt.Errorf("%s", errMsg)
 	}
+	backend := session.ChooseBackend(session.BackendAuto, session.HasTmux(stdout))
+	saveJobBackend(database, newJobID, backend)
 
 	// Save metadata
 	newMetadata := session.FormatMetadata(newJobID, workingDir, command, job.Host, description, newJob.StartTime)
-	// Don't quote path - it contains ~ which needs shell expansion
-	metadataCmd := fmt.Sprintf("cat > %s << 'METADATA_EOF'\n%s\nMETADATA_EOF", newMetadataFile, newMetadata)
-	ssh.RunWithRetry(job.Host, metadataCmd)
+	metadataCmd := shellquote.WriteFile(newMetadataFile, newMetadata)
+	ssh.RunWithRetry(jobHost(job), metadataCmd)
 
 	// Create the wrapped command using the common builder (tested for tilde expansion)
 	wrappedCommand := session.BuildWrapperCommand(session.WrapperCommandParams{
-		JobID:      newJobID,
-		WorkingDir: workingDir,
-		Command:    command,
-		LogFile:    logFile,
-		StatusFile: statusFile,
-		PidFile:    pidFile,
+		JobID:       newJobID,
+		WorkingDir:  workingDir,
+		Command:     command,
+		LogFile:     logFile,
+		StatusFile:  statusFile,
+		PidFile:     pidFile,
+		SecretsFile: secretsFile,
 	})
 
-	// Escape single quotes for embedding in single-quoted string
-	escapedCommand := ssh.EscapeForSingleQuotes(wrappedCommand)
-
-	// Start tmux session - use single quotes to prevent shell expansion
-	tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", newTmuxSession, escapedCommand)
-	if _, stderr, err := ssh.Run(job.Host, tmuxCmd); err != nil {
+	// Start the job (fails if the working directory is missing)
+	tmuxCmd := session.LaunchCommand(backend, newTmuxSession, workingDir, wrappedCommand, false)
+	if secretsFile != "" {
+		tmuxCmd = session.SecretsLaunchCommand(secretsFile, tmuxCmd)
+	}
+	if _, stderr, err := runLaunch(jobHost(job), tmuxCmd, secretValues); err != nil {
 		errMsg := ssh.FriendlyError(job.Host, stderr, err)
 		db.UpdateJobFailed(database, newJobID, errMsg)
 		return fmt.Errorf("%s", errMsg)
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/results"
+)
+
+// resultSpecFlags checks the --result and --results-file flags and returns
+// the spec they describe
+func resultSpecFlags(patterns []string, file string) (db.ResultSpec, error) {
+	for _, p := range patterns {
+		if err := results.ValidatePattern(p); err != nil {
+			return db.ResultSpec{}, err
+		}
+	}
+	return db.ResultSpec{Patterns: patterns, File: file}, nil
+}
+
+// saveJobResultSpec records where a newly created job's results are read from
+func saveJobResultSpec(database *sql.DB, jobID int64, spec db.ResultSpec) {
+	if spec.IsZero() {
+		return
+	}
+	if err := db.SetResultSpec(database, jobID, spec); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save results for job %d: %v\n", jobID, err)
+	}
+}
+
+// printResults prints a job's result metrics, or what they'll be read from
+// if the job hasn't finished
+func printResults(database *sql.DB, jobID int64) {
+	spec, resolved, err := db.GetResultSpec(database, jobID)
+	if err != nil || spec == nil {
+		return
+	}
+	if !resolved {
+		var sources []string
+		if spec.File != "" {
+			sources = append(sources, spec.File)
+		}
+		if len(spec.Patterns) > 0 {
+			sources = append(sources, "the log")
+		}
+		fmt.Printf("Results:      from %s (read when the job finishes)\n", strings.Join(sources, " and "))
+		return
+	}
+	metrics, err := db.GetJobResults(database, jobID)
+	if err != nil {
+		return
+	}
+	if len(metrics) == 0 {
+		fmt.Printf("Results:      (none found)\n")
+		return
+	}
+	fmt.Printf("Results:      %s\n", results.Format(metrics))
+}
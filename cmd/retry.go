@@ -4,11 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"strconv"
 
 	"github.com/osteele/remote-jobs/internal/db"
-	"github.com/osteele/remote-jobs/internal/session"
-	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
 )
 
@@ -32,6 +29,8 @@ var (
 	retryAll    bool
 	retryHost   string
 	retryDelete int64
+
+	retryIgnoreLimits bool
 )
 
 func init() {
@@ -42,6 +41,7 @@ func init() {
 	retryCmd.Flags().BoolVar(&retryAll, "all", false, "Retry all pending jobs")
 	retryCmd.Flags().StringVar(&retryHost, "host", "", "Filter by host or override host for retry")
 	retryCmd.Flags().Int64Var(&retryDelete, "delete", 0, "Delete a pending job")
+	retryCmd.Flags().BoolVar(&retryIgnoreLimits, "ignore-limits", false, "Start even if the host's configured job limits are reached")
 }
 
 func runRetry(cmd *cobra.Command, args []string) error {
@@ -71,9 +71,9 @@ func runRetry(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("job ID required (or use --list, --all, --delete)")
 	}
 
-	jobID, err := strconv.ParseInt(args[0], 10, 64)
+	jobID, err := jobSelector{}.resolve(database, args[0])
 	if err != nil {
-		return fmt.Errorf("invalid job ID: %s", args[0])
+		return err
 	}
 
 	return retrySingleJob(database, jobID, retryHost)
@@ -93,7 +93,7 @@ func listPendingJobs(database *sql.DB, host string) error {
 	fmt.Printf("Pending jobs:\n\n")
 	for _, job := range jobs {
 		fmt.Printf("ID %d on %s\n", job.ID, job.Host)
-		fmt.Printf("  Command: %s\n", job.EffectiveCommand())
+		fmt.Printf("  Command: %s\n", displayCommand(job))
 		fmt.Printf("  Directory: %s\n", job.EffectiveWorkingDir())
 		if job.Description != "" {
 			fmt.Printf("  Description: %s\n", job.Description)
@@ -160,88 +160,32 @@ func retrySingleJob(database *sql.DB, id int64, overrideHost string) error {
 }
 
 func startPendingJob(database *sql.DB, job *db.Job, overrideHost string) error {
-	host := job.Host
-	if overrideHost != "" {
-		host = overrideHost
-	}
-
-	// Delete the pending entry
-	if err := db.DeletePending(database, job.ID); err != nil {
-		return fmt.Errorf("delete pending: %w", err)
-	}
-
-	// Create new job record to get ID
-	newJobID, err := db.RecordJobStarting(database, host, job.WorkingDir, job.Command, job.Description)
-	if err != nil {
-		return fmt.Errorf("create job record: %w", err)
-	}
-
-	// Get the new job to access start time
-	newJob, err := db.GetJobByID(database, newJobID)
-	if err != nil || newJob == nil {
-		return fmt.Errorf("get new job: %w", err)
-	}
-
-	// Generate file paths from job ID
-	tmuxSession := session.TmuxSessionName(newJobID)
-	logFile := session.LogFile(newJobID, newJob.StartTime)
-	statusFile := session.StatusFile(newJobID, newJob.StartTime)
-	metadataFile := session.MetadataFile(newJobID, newJob.StartTime)
-	pidFile := session.PidFile(newJobID, newJob.StartTime)
-
-	// Check if session already exists (shouldn't with new unique IDs)
-	exists, err := ssh.TmuxSessionExists(host, tmuxSession)
+	opts, err := savedJobOptions(database, job)
 	if err != nil {
-		db.UpdateJobFailed(database, newJobID, err.Error())
-		return fmt.Errorf("check session: %w", err)
+		return err
 	}
-	if exists {
-		db.UpdateJobFailed(database, newJobID, "session already exists")
-		return fmt.Errorf("session '%s' already exists on %s", tmuxSession, host)
+	if overrideHost != "" && overrideHost != job.Host {
+		// The old host's env defaults give way to the new host's
+		opts.EnvVars = loadPlacementConfig().WithoutHostEnv(job.Host, opts.EnvVars)
+		opts.Host = overrideHost
 	}
+	opts.IgnoreLimits = retryIgnoreLimits
 
-	// Create log directory on remote
-	mkdirCmd := fmt.Sprintf("mkdir -p %s", session.LogDir)
-	if _, stderr, err := ssh.RunWithRetry(host, mkdirCmd); err != nil {
-		errMsg := ssh.FriendlyError(host, stderr, err)
-		db.UpdateJobFailed(database, newJobID, errMsg)
-		return fmt.Errorf("%s", errMsg)
+	// The pending entry gives way to the new job once it is recorded, so a
+	// job that can't be started yet stays pending
+	var deleteErr error
+	opts.OnPrepared = func(StartJobPreparedInfo) {
+		deleteErr = db.DeletePending(database, job.ID)
 	}
-
-	// Save metadata
-	metadata := session.FormatMetadata(newJobID, job.WorkingDir, job.Command, host, job.Description, newJob.StartTime)
-	// Don't quote path - it contains ~ which needs shell expansion
-	metadataCmd := fmt.Sprintf("cat > %s << 'METADATA_EOF'\n%s\nMETADATA_EOF", metadataFile, metadata)
-	ssh.RunWithRetry(host, metadataCmd)
-
-	// Create the wrapped command using the common builder (tested for tilde expansion)
-	wrappedCommand := session.BuildWrapperCommand(session.WrapperCommandParams{
-		JobID:      newJobID,
-		WorkingDir: job.WorkingDir,
-		Command:    job.Command,
-		LogFile:    logFile,
-		StatusFile: statusFile,
-		PidFile:    pidFile,
-	})
-
-	// Escape single quotes for embedding in single-quoted string
-	escapedCommand := ssh.EscapeForSingleQuotes(wrappedCommand)
-
-	// Start tmux session - use single quotes to prevent shell expansion
-	tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", tmuxSession, escapedCommand)
-	if _, stderr, err := ssh.Run(host, tmuxCmd); err != nil {
-		errMsg := ssh.FriendlyError(host, stderr, err)
-		db.UpdateJobFailed(database, newJobID, errMsg)
-		return fmt.Errorf("%s", errMsg)
+	result, err := startJob(database, opts)
+	if deleteErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to delete pending job %d: %v\n", job.ID, deleteErr)
 	}
-
-	// Mark job as running
-	if err := db.UpdateJobRunning(database, newJobID); err != nil {
-		return fmt.Errorf("update job status: %w", err)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("✓ Job started on %s\n", host)
-	fmt.Printf("Job ID: %d\n", newJobID)
-
+	fmt.Printf("✓ Job started on %s\n", opts.Host)
+	fmt.Printf("Job ID: %d\n", result.Info.JobID)
 	return nil
 }
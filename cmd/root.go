@@ -25,6 +25,8 @@ long-running processes on remote machines.`,
 func Execute() error {
 	// If no args provided, check config for default command
 	if len(os.Args) == 1 {
+		applyProfile()
+		enableSandbox()
 		cfg, _ := config.Load()
 		if cfg != nil && cfg.DefaultCommand != "" && cfg.DefaultCommand != "help" {
 			// Insert the default command as the first argument
@@ -4,14 +4,20 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/osteele/remote-jobs/internal/availability"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/placement"
+	"github.com/osteele/remote-jobs/internal/preset"
+	"github.com/osteele/remote-jobs/internal/secrets"
 	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/osteele/remote-jobs/internal/taskrunner"
 	"github.com/spf13/cobra"
 )
 
@@ -27,41 +33,132 @@ Examples:
   remote-jobs run cool30 'python train.py'
   remote-jobs run -d "Training GPT-2" cool30 'with-gpu python train.py'
   remote-jobs run -C /mnt/code/LM2 cool30 'python train.py'
+  remote-jobs run --mkdir -C ~/runs/exp1 cool30 'python ~/code/train.py'
   remote-jobs run -e CUDA_VISIBLE_DEVICES=0 -e BATCH_SIZE=32 cool30 'python train.py'
   remote-jobs run --after 42 cool30 'python eval.py'  # Run after job 42 completes
   remote-jobs run --queue cool30 'python train.py'
   remote-jobs run -f cool30 'python train.py'   # Start and follow log
-  remote-jobs run cool30 --kill 42              # Kill job 42`,
-	Args: func(cmd *cobra.Command, args []string) error {
-		// --kill mode only needs host
-		if runKillJobID > 0 {
-			if len(args) < 1 {
-				return fmt.Errorf("requires host argument")
-			}
-			return nil
+  remote-jobs run --dry-run cool30 'python train.py'  # Show what would run
+  remote-jobs run --ignore-limits cool30 'python train.py'  # Exceed max_running
+  remote-jobs run --on-success 'rsync -a cool30:out/ out/' cool30 'python train.py'
+  remote-jobs run -t sweep42 cool30 'python train.py --lr 0.01'  # Tag for kill --tag
+  remote-jobs run --script train.sh cool30       # Upload and run a script
+  remote-jobs run --script train.sh cool30 '--lr 3e-4'  # ...with arguments
+  remote-jobs run cool30 - < pipeline.sh         # Read a multi-line command from stdin
+  remote-jobs run --make train cool30 'EPOCHS=10'  # Run a Makefile target
+  remote-jobs run --just eval cool30             # Run a justfile recipe
+  remote-jobs run cool30 --kill 42              # Kill job 42
+  remote-jobs run --backend nohup cool30 'python train.py'  # Run without tmux
+  remote-jobs run --gpus 2 cool30 'torchrun --nproc-per-node 2 train.py'  # Use 2 free GPUs
+  remote-jobs run --secret WANDB_API_KEY cool30 'python train.py'  # Pass an API key
+
+With --script, or with "-" as the command, the script is uploaded to
+~/.cache/remote-jobs/scripts on the host and run from there; scripts without
+a #! line run with bash. Its name and SHA-256 are recorded in the job's
+metadata, and its content is kept in the local database.
+
+With --make or --just, the target runs in the directory of the nearest local
+Makefile or justfile (unless -C is given), and shell completion offers the
+targets it defines.
+
+Jobs run in a detached tmux session. On hosts without tmux, the job runs as a
+background process under nohup (and setsid, if available) instead; --backend
+chooses explicitly. Either way the job is tracked through its PID, status,
+and log files.
+
+With --gpus N, the host's GPUs are checked when the job starts, and the job
+is given the N free GPUs with the most free memory through
+CUDA_VISIBLE_DEVICES. GPUs that other jobs are using, or have been given, are
+skipped; the job fails to start if too few are free.
+
+Without --gpus, run refuses to start a job on GPUs that are already heavily
+used (half their memory or compute), to avoid double-booking them: all of the
+host's GPUs, or those named by -e CUDA_VISIBLE_DEVICES. --force starts the
+job anyway.
+
+With --secret NAME, the value of NAME is read from the keychain (stored with
+'remote-jobs secret set NAME') or else the local environment, and the job is
+started with it as an environment variable. Only the name is recorded: the
+value is sent over ssh's stdin to a file the job's wrapper reads and deletes,
+and it is shown as <secret> in job details and 'remote-jobs log'.
+
+In a project with a .remote-jobs.toml (in the current directory or a parent),
+its host, remote directory, environment variables, conda environment, and
+tags are used as defaults; the host argument can then be omitted. Flags
+override them, and --no-preset ignores the file.`,
+	Args: validateRunArgs,
+	RunE: runRun,
+}
+
+// validateRunArgs checks the positional arguments of run and job run
+func validateRunArgs(cmd *cobra.Command, args []string) error {
+	// --kill mode only needs host
+	if runKillJobID > 0 {
+		if len(args) < 1 {
+			return fmt.Errorf("requires host argument")
 		}
-		// Normal mode needs exactly host + command
-		if len(args) != 2 {
-			return fmt.Errorf("requires exactly host and command arguments")
+		return nil
+	}
+	// --script, --make, and --just take host and optional arguments. The
+	// host can come from the project preset, which runRun loads.
+	if runScript != "" || runMake != "" || runJust != "" {
+		if len(args) > 2 {
+			return fmt.Errorf("requires host and optional arguments")
 		}
 		return nil
-	},
-	RunE: runRun,
+	}
+	// Normal mode needs host + command, or just the command with a preset host
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("requires exactly host and command arguments")
+	}
+	return nil
 }
 
 var (
-	runDir         string
-	runDescription string
-	runQueue       bool
-	runQueueOnFail bool
-	runFollow      bool
-	runAllow       bool
-	runKillJobID   int64
-	runFrom        int64
-	runTimeout     string
-	runEnvVars     []string
-	runAfter       int64
-	runAfterAny    int64
+	runDir          string
+	runDescription  string
+	runQueue        bool
+	runQueueOnFail  bool
+	runFollow       bool
+	runMkdir        bool
+	runDryRun       bool
+	runIgnoreLimits bool
+	runAllow        bool
+	runKillJobID    int64
+	runFromArg      string
+	runFrom         int64
+	runTimeout      string
+	runEnvVars      []string
+	runAfterArg     string
+	runAfterAnyArg  string
+	runAfter        int64
+	runAfterAny     int64
+	runOnSuccess    string
+	runOnFailure    string
+	runPreStart     string
+	runPostFinish   string
+	runScript       string
+	runMake         string
+	runJust         string
+	runTags         []string
+	runIf           string
+	runIfFalse      string
+	runArtifacts    []string
+	runResults      []string
+	runResultsFile  string
+	runBackend      string
+	runGPUs         int
+	runForce        bool
+	runNoPreset     bool
+	runSecrets      []string
+	runNeeds        string
+	runRequire      []string
+	runAvoid        []string
+	runIgnoreAvail  bool
+	runResumeCmd    string
+	runExperiment   string
+	runSplitStderr  bool
+	runStages       []string
 )
 
 func init() {
@@ -71,14 +168,45 @@ func init() {
 	runCmd.Flags().StringVarP(&runDescription, "description", "d", "", "Description of the job")
 	runCmd.Flags().BoolVar(&runQueue, "queue", false, "Queue job for later instead of running now")
 	runCmd.Flags().BoolVar(&runQueueOnFail, "queue-on-fail", false, "Queue job if connection fails")
+	runCmd.Flags().BoolVar(&runMkdir, "mkdir", false, "Create the working directory on the remote host if it doesn't exist")
+	runCmd.Flags().BoolVar(&runDryRun, "dry-run", false, "Print the remote commands, wrapper script, and file paths without running anything")
+	runCmd.Flags().BoolVar(&runIgnoreLimits, "ignore-limits", false, "Start or queue even if the host's configured job limits are reached")
 	runCmd.Flags().BoolVarP(&runFollow, "follow", "f", false, "Follow log output after starting")
 	runCmd.Flags().BoolVar(&runAllow, "allow", false, "Stream the job log live and stay attached until interrupted")
 	runCmd.Flags().Int64Var(&runKillJobID, "kill", 0, "Kill a job by ID (synonym for 'remote-jobs kill')")
-	runCmd.Flags().Int64Var(&runFrom, "from", 0, "Copy settings from existing job ID (replaces retry)")
+	runCmd.Flags().StringVar(&runFromArg, "from", "", "Copy settings from an existing job, by ID or selector (replaces retry)")
 	runCmd.Flags().StringVar(&runTimeout, "timeout", "", "Kill job after duration (e.g., \"2h\", \"30m\", \"1h30m\")")
 	runCmd.Flags().StringSliceVarP(&runEnvVars, "env", "e", nil, "Environment variable (VAR=value), can be repeated")
-	runCmd.Flags().Int64Var(&runAfter, "after", 0, "Start job after another job succeeds (implies --queue)")
-	runCmd.Flags().Int64Var(&runAfterAny, "after-any", 0, "Start job after another job completes, success or failure (implies --queue)")
+	runCmd.Flags().StringVar(&runAfterArg, "after", "", "Start job after another job, by ID or selector, succeeds (implies --queue)")
+	runCmd.Flags().StringVar(&runAfterAnyArg, "after-any", "", "Start job after another job, by ID or selector, completes, success or failure (implies --queue)")
+	runCmd.Flags().StringVar(&runOnSuccess, "on-success", "", "Local command to run when the job succeeds (detected on sync/status --wait)")
+	runCmd.Flags().StringVar(&runOnFailure, "on-failure", "", "Local command to run when the job fails or dies")
+	runCmd.Flags().StringVar(&runPreStart, "pre-start", "", "Remote hook to run before the job (job is skipped if it fails)")
+	runCmd.Flags().StringVar(&runPostFinish, "post-finish", "", "Remote hook to run after the job exits")
+	runCmd.Flags().StringVar(&runScript, "script", "", "Upload a local script and run it (remaining argument is passed to the script)")
+	runCmd.Flags().StringVar(&runMake, "make", "", "Run a target from the local Makefile, in its directory")
+	runCmd.Flags().StringVar(&runJust, "just", "", "Run a recipe from the local justfile, in its directory")
+	runCmd.Flags().StringSliceVarP(&runTags, "tag", "t", nil, "Tag the job (e.g., a sweep name), can be repeated")
+	runCmd.Flags().StringArrayVar(&runArtifacts, "artifact", nil, "Glob for output files to record when the job finishes (relative to the working directory), can be repeated")
+	runCmd.Flags().StringArrayVar(&runResults, "result", nil, "Regex whose named groups are metrics read from the end of the log when the job finishes, e.g. 'acc=(?P<accuracy>[0-9.]+)', can be repeated")
+	runCmd.Flags().StringVar(&runResultsFile, "results-file", "", "JSON file of metrics to read when the job finishes (relative to the working directory)")
+	runCmd.Flags().StringVar(&runIf, "if", "", "Shell condition the queue runner checks just before starting the job (implies --queue)")
+	runCmd.Flags().StringVar(&runIfFalse, "if-false", db.GuardRequeue, "What to do if the --if condition is false: requeue, skip, or fail")
+	runCmd.Flags().StringVar(&runBackend, "backend", session.BackendAuto, "What keeps the job running: auto, tmux, or nohup (auto uses nohup if the host has no tmux)")
+	runCmd.Flags().IntVar(&runGPUs, "gpus", 0, "Restrict the job to this many free GPUs, chosen at launch (sets CUDA_VISIBLE_DEVICES)")
+	runCmd.Flags().BoolVar(&runForce, "force", false, "Start even if the GPUs the job would use are heavily used by other processes")
+	runCmd.Flags().BoolVar(&runNoPreset, "no-preset", false, "Ignore the project's "+preset.FileName)
+	runCmd.Flags().StringVar(&runNeeds, "needs", "", "Resources the job needs, e.g. 'gpu:1,vram:40GiB,ram:64GiB,disk:200GiB' (checked against the host, and used to pick one for the host auto)")
+	runCmd.Flags().StringSliceVar(&runRequire, "require", nil, "Host tag the host must have, e.g. a100 (see 'remote-jobs host tags'), or tool version, e.g. python>=3.10, can be repeated")
+	runCmd.Flags().StringSliceVar(&runAvoid, "avoid", nil, "Host tag the host must not have, e.g. slow-disk, can be repeated")
+	runCmd.Flags().StringVar(&runResumeCmd, "resume-cmd", "", "Command that resumes the job from a checkpoint ({checkpoint} is replaced by its path), used by 'remote-jobs migrate'")
+	runCmd.Flags().StringVar(&runExperiment, "experiment", "", "Experiment the job belongs to, created if new (see 'remote-jobs experiment')")
+	runCmd.Flags().StringArrayVar(&runStages, "stage", nil, "Copy a local input file to the host before the job starts, as local:remote (remote relative to the working directory; ending in / for a directory), can be repeated")
+	runCmd.Flags().BoolVar(&runSplitStderr, "split-stderr", false, "Also write the job's stderr to a file of its own, shown by 'remote-jobs log --stderr'")
+	runCmd.Flags().BoolVar(&runIgnoreAvail, "ignore-availability", false, "Start now even if the host is outside its availability windows")
+	runCmd.Flags().StringArrayVar(&runSecrets, "secret", nil, "Pass a secret from the keychain as an environment variable, without recording its value (see 'remote-jobs secret'), can be repeated")
+	runCmd.RegisterFlagCompletionFunc("make", completeTargets(taskrunner.Make))
+	runCmd.RegisterFlagCompletionFunc("just", completeTargets(taskrunner.Just))
 }
 
 func runRun(cmd *cobra.Command, args []string) error {
@@ -99,7 +227,51 @@ func runRun(cmd *cobra.Command, args []string) error {
 	}
 	defer database.Close()
 
+	var sel jobSelector
+	if runFrom, err = sel.resolveFlag(database, "--from", runFromArg); err != nil {
+		return err
+	}
+	if runAfter, err = sel.resolveFlag(database, "--after", runAfterArg); err != nil {
+		return err
+	}
+	if runAfterAny, err = sel.resolveFlag(database, "--after-any", runAfterAnyArg); err != nil {
+		return err
+	}
+
 	var host, command string
+	var script *session.Script
+
+	taskModes := 0
+	for _, s := range []string{runScript, runMake, runJust} {
+		if s != "" {
+			taskModes++
+		}
+	}
+	if taskModes > 1 {
+		return fmt.Errorf("only one of --script, --make, and --just can be used")
+	}
+	if taskModes > 0 && runFrom > 0 {
+		return fmt.Errorf("--script, --make, and --just cannot be used with --from")
+	}
+
+	// A job copied with --from keeps its own settings, rather than the
+	// project preset's
+	project, err := loadProjectPreset(runNoPreset || runFrom > 0)
+	if err != nil {
+		return err
+	}
+	if taskModes > 0 {
+		args = presetArgs(project, args, 1)
+		if len(args) == 0 {
+			return fmt.Errorf("requires a host argument, or a host in %s", preset.FileName)
+		}
+	} else if runFrom == 0 {
+		args = presetArgs(project, args, 2)
+	}
+	if project != nil {
+		runEnvVars = project.MergeEnv(runEnvVars)
+		runTags = project.MergeTags(runTags)
+	}
 
 	// Handle --from mode: copy settings from existing job
 	if runFrom > 0 {
@@ -120,6 +292,31 @@ func runRun(cmd *cobra.Command, args []string) error {
 		if runDescription == "" {
 			runDescription = fromJob.Description
 		}
+		if len(runArtifacts) == 0 {
+			runArtifacts, _, _ = db.GetArtifactGlobs(database, runFrom)
+		}
+		if len(runSecrets) == 0 {
+			runSecrets, _ = db.GetJobSecrets(database, runFrom)
+		}
+		if runResumeCmd == "" {
+			runResumeCmd, _ = db.GetResumeCommand(database, runFrom)
+		}
+		if runExperiment == "" {
+			runExperiment, _ = db.GetJobExperiment(database, runFrom)
+		}
+		if saved, err := db.GetJobRequest(database, runFrom); err == nil {
+			if runNeeds == "" {
+				runNeeds = saved.Needs
+			}
+			if len(runRequire) == 0 && len(runAvoid) == 0 {
+				runRequire, runAvoid = saved.Require, saved.Avoid
+			}
+		}
+		if len(runResults) == 0 && runResultsFile == "" {
+			if spec, _, _ := db.GetResultSpec(database, runFrom); spec != nil {
+				runResults, runResultsFile = spec.Patterns, spec.File
+			}
+		}
 
 		// Allow overriding host from command line
 		if len(args) > 0 {
@@ -128,16 +325,61 @@ func runRun(cmd *cobra.Command, args []string) error {
 		// Allow overriding command from command line
 		if len(args) > 1 {
 			command = args[1]
+		} else {
+			// Upload the script again in case it has been cleaned up
+			script, err = savedScript(database, runFrom)
+			if err != nil {
+				return fmt.Errorf("get script for job %d: %w", runFrom, err)
+			}
+		}
+	} else if runScript != "" {
+		script, err = readScript(runScript)
+		if err != nil {
+			return err
+		}
+		host = args[0]
+		scriptArgs := ""
+		if len(args) > 1 {
+			scriptArgs = args[1]
+		}
+		command = script.Command(scriptArgs)
+	} else if runMake != "" || runJust != "" {
+		runner, target := taskrunner.Make, runMake
+		if runJust != "" {
+			runner, target = taskrunner.Just, runJust
 		}
+		host = args[0]
+		taskArgs := ""
+		if len(args) > 1 {
+			taskArgs = args[1]
+		}
+		var taskDir string
+		command, taskDir, err = resolveTaskCommand(runner, host, target, taskArgs)
+		if err != nil {
+			return err
+		}
+		if runDir == "" {
+			runDir = taskDir
+		}
+		fmt.Printf("Resolved %s %s to: cd %s && %s\n\n", runner.Name, target, runDir, command)
 	} else {
 		// Normal mode: require host and command
 		if len(args) < 2 {
-			return fmt.Errorf("usage: remote-jobs run <host> <command>")
+			return fmt.Errorf("usage: remote-jobs run <host> <command> (the host can be omitted if %s sets one)", preset.FileName)
 		}
 		host = args[0]
 		command = args[1]
 	}
 
+	// A command of "-" is read from stdin and run as a script
+	if command == "-" && script == nil {
+		script, err = readScript("-")
+		if err != nil {
+			return err
+		}
+		command = script.Command("")
+	}
+
 	// Validate flag combinations
 	if runFollow && runQueue {
 		return fmt.Errorf("--follow cannot be used with --queue")
@@ -163,34 +405,120 @@ func runRun(cmd *cobra.Command, args []string) error {
 	if runAllow && runFollow {
 		return fmt.Errorf("--allow cannot be used with --follow")
 	}
+	if runDryRun && (runQueue || runAfter > 0 || runAfterAny > 0 || runIf != "") {
+		return fmt.Errorf("--dry-run cannot be used with --queue, --after, --after-any, or --if")
+	}
+	if (runFollow || runAllow) && runIf != "" {
+		return fmt.Errorf("--follow and --allow cannot be used with --if")
+	}
+	guard, err := jobGuard(runIf, runIfFalse)
+	if err != nil {
+		return err
+	}
+	if err := session.ValidateBackend(runBackend); err != nil {
+		return err
+	}
+	if err := validateGPUsFlag(runGPUs, runEnvVars); err != nil {
+		return err
+	}
+	if runGPUs > 0 && (runQueue || runAfter > 0 || runAfterAny > 0 || guard != nil) {
+		return fmt.Errorf("--gpus cannot be used with --queue, --after, --after-any, or --if (GPUs are chosen when the job starts)")
+	}
+	if runGPUs > 0 && runDryRun {
+		return fmt.Errorf("--gpus cannot be used with --dry-run (GPUs are chosen when the job starts)")
+	}
+	if err := validateSecretsFlag(runSecrets, runEnvVars); err != nil {
+		return err
+	}
+	resultSpec, err := resultSpecFlags(runResults, runResultsFile)
+	if err != nil {
+		return err
+	}
+	if len(runSecrets) > 0 && (runQueue || runAfter > 0 || runAfterAny > 0 || guard != nil || runQueueOnFail) {
+		return fmt.Errorf("--secret cannot be used with --queue, --after, --after-any, --if, or --queue-on-fail (secrets are only sent to jobs started now)")
+	}
+	staged, err := parseStageFlags(runStages)
+	if err != nil {
+		return err
+	}
+	if len(staged) > 0 && (runQueue || runAfter > 0 || runAfterAny > 0 || guard != nil || runQueueOnFail) {
+		return fmt.Errorf("--stage cannot be used with --queue, --after, --after-any, --if, or --queue-on-fail (files are only staged for jobs started now)")
+	}
+	if flags := startOnlyRunFlags(); len(flags) > 0 && (runQueue || runAfter > 0 || runAfterAny > 0 || guard != nil) {
+		return fmt.Errorf("%s cannot be used with --queue, --after, --after-any, or --if (they only apply to jobs started now)", strings.Join(flags, ", "))
+	}
+
+	needs, err := placement.ParseNeeds(runNeeds)
+	if err != nil {
+		return fmt.Errorf("invalid --needs: %w", err)
+	}
+	request := placement.Request{Needs: needs, Require: runRequire, Avoid: runAvoid}
+	if err := request.Validate(); err != nil {
+		return fmt.Errorf("invalid --require: %w", err)
+	}
+	var opensAt time.Time
+	if host == placement.AutoHost {
+		if host, opensAt, err = placeJob(database, request); err != nil {
+			return err
+		}
+	} else if err := checkHostFit(database, host, request); err != nil {
+		return err
+	} else {
+		opensAt = hostOpensAt(loadPlacementConfig(), host, time.Now())
+	}
 
-	// --after and --after-any imply queue mode (job added to remote queue for dependency handling)
-	if runAfter > 0 || runAfterAny > 0 {
+	// --after, --after-any, and --if imply queue mode (job added to the remote
+	// queue, whose runner checks dependencies and conditions)
+	if runAfter > 0 || runAfterAny > 0 || guard != nil {
 		runQueue = true
 	}
 
+	// A job for a host outside its availability windows is deferred to the
+	// host's queue, whose runner starts it when a window opens
+	deferred := false
+	if !opensAt.IsZero() && !runQueue && !runDryRun && !runIgnoreAvail {
+		if flags := undeferrableRunFlags(); len(flags) > 0 {
+			return fmt.Errorf("%s is unavailable until %s, and the job can't wait in its queue with %s (use --ignore-availability to start it now)",
+				host, availability.FormatOpensAt(opensAt, time.Now()), strings.Join(flags, ", "))
+		}
+		deferred = true
+		runQueue = true
+	}
+
+	onSuccess, onFailure := resolveJobHooks(runOnSuccess, runOnFailure)
+
 	// Parse "cd /path && command" pattern to extract working directory
 	// Only if -C/--directory wasn't explicitly provided
-	parsedDir, parsedCmd := parseCdPrefix(command)
-	if parsedDir != "" && runDir == "" {
-		command = parsedCmd
-		runDir = parsedDir
+	if script == nil {
+		parsedDir, parsedCmd := parseCdPrefix(command)
+		if parsedDir != "" && runDir == "" {
+			command = parsedCmd
+			runDir = parsedDir
+		}
+	}
+	if project != nil {
+		if runDir == "" {
+			runDir = presetWorkingDir(project)
+		}
+		command = project.WrapCommand(command)
 	}
 
 	// Set defaults
 	workingDir := runDir
 	if workingDir == "" {
 		var err error
-		workingDir, err = session.DefaultWorkingDir()
+		workingDir, err = defaultWorkingDir(host)
 		if err != nil {
-			return fmt.Errorf("get working dir: %w", err)
+			return err
 		}
 	}
 
 	// Queue-only mode (including when --after is used)
 	if runQueue {
-		// When --after or --after-any is specified, use the remote queue system for dependency handling
-		if runAfter > 0 || runAfterAny > 0 {
+		// When --after, --after-any, or --if is specified, or the job is
+		// deferred, use the remote queue system for dependency and condition
+		// handling
+		if runAfter > 0 || runAfterAny > 0 || guard != nil || deferred {
 			afterID := runAfter
 			afterAny := false
 			if runAfterAny > 0 {
@@ -198,14 +526,26 @@ func runRun(cmd *cobra.Command, args []string) error {
 				afterAny = true
 			}
 			jobID, err := queueJob(database, queueJobOptions{
-				Host:        host,
-				WorkingDir:  workingDir,
-				Command:     command,
-				Description: runDescription,
-				EnvVars:     runEnvVars,
-				QueueName:   defaultQueueName,
-				AfterJobID:  afterID,
-				AfterAny:    afterAny,
+				Host:         host,
+				WorkingDir:   workingDir,
+				Command:      command,
+				Description:  runDescription,
+				EnvVars:      runEnvVars,
+				QueueName:    defaultQueueName,
+				AfterJobID:   afterID,
+				AfterAny:     afterAny,
+				OnSuccess:    onSuccess,
+				OnFailure:    onFailure,
+				IgnoreLimits: runIgnoreLimits,
+				Script:       script,
+				Tags:         runTags,
+				Guard:        guard,
+				Timeout:      runTimeout,
+				Artifacts:    runArtifacts,
+				Results:      resultSpec,
+				Request:      request,
+				Resume:       runResumeCmd,
+				Experiment:   runExperiment,
 			})
 			if err != nil {
 				return fmt.Errorf("queue job: %w", err)
@@ -215,7 +555,14 @@ func runRun(cmd *cobra.Command, args []string) error {
 			if afterAny {
 				waitType = "completes"
 			}
-			fmt.Printf("Job %d added to queue on %s, will run after job %d %s\n\n", jobID, host, afterID, waitType)
+			switch {
+			case afterID > 0:
+				fmt.Printf("Job %d added to queue on %s, will run after job %d %s\n\n", jobID, host, afterID, waitType)
+			case deferred:
+				fmt.Printf("Job %d added to queue on %s, will run when it is next available: %s\n\n", jobID, host, availability.FormatOpensAt(opensAt, time.Now()))
+			default:
+				fmt.Printf("Job %d added to queue on %s\n\n", jobID, host)
+			}
 			fmt.Printf("  Working dir: %s\n", workingDir)
 			fmt.Printf("  Command: %s\n", command)
 			if runDescription != "" {
@@ -224,7 +571,23 @@ func runRun(cmd *cobra.Command, args []string) error {
 			if len(runEnvVars) > 0 {
 				fmt.Printf("  Env vars: %s\n", strings.Join(runEnvVars, ", "))
 			}
-			fmt.Printf("  After job: %d (%s)\n", afterID, waitType)
+			if runTimeout != "" {
+				fmt.Printf("  Timeout: %s\n", runTimeout)
+			}
+			if afterID > 0 {
+				fmt.Printf("  After job: %d (%s)\n", afterID, waitType)
+			}
+			if guard != nil {
+				fmt.Printf("  If: %s\n", guard)
+			}
+			printHeldNote(database, jobID)
+			if deferred {
+				if _, err := ensureQueueRunnerStarted(host, defaultQueueName); err != nil {
+					fmt.Fprintf(os.Stderr, "\nWarning: failed to start queue runner: %v\n", err)
+				} else {
+					return nil
+				}
+			}
 			fmt.Printf("\nTo start the queue runner (if not already running):\n")
 			fmt.Printf("  remote-jobs queue start %s\n", host)
 			return nil
@@ -235,6 +598,15 @@ func runRun(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("queue job: %w", err)
 		}
+		saveJobRemoteUser(database, jobID, host)
+		saveJobHooks(database, jobID, onSuccess, onFailure)
+		saveJobScript(database, jobID, script)
+		saveJobTags(database, jobID, runTags)
+		saveJobArtifacts(database, jobID, runArtifacts)
+		saveJobResultSpec(database, jobID, resultSpec)
+		saveJobRequest(database, jobID, request)
+		saveJobResume(database, jobID, runResumeCmd)
+		saveJobExperiment(database, jobID, runExperiment)
 
 		fmt.Printf("Job queued with ID: %d\n\n", jobID)
 		fmt.Printf("  Host: %s\n", host)
@@ -248,14 +620,58 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if runDryRun {
+		plan, err := previewJob(database, startJobOptions{
+			Host:        host,
+			WorkingDir:  workingDir,
+			Command:     command,
+			Description: runDescription,
+			EnvVars:     runEnvVars,
+			Timeout:     runTimeout,
+			Mkdir:       runMkdir,
+			PreStart:    runPreStart,
+			PostFinish:  runPostFinish,
+			Script:      script,
+			Backend:     runBackend,
+			Secrets:     runSecrets,
+			SplitStderr: runSplitStderr,
+			Staged:      staged,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Dry run: job %d (predicted ID) would be started on %s\n\n", plan.JobID, host)
+		fmt.Print(plan.Format())
+		return nil
+	}
+
 	result, err := startJob(database, startJobOptions{
-		Host:        host,
-		WorkingDir:  workingDir,
-		Command:     command,
-		Description: runDescription,
-		EnvVars:     runEnvVars,
-		Timeout:     runTimeout,
-		QueueOnFail: runQueueOnFail,
+		Host:         host,
+		WorkingDir:   workingDir,
+		Command:      command,
+		Description:  runDescription,
+		EnvVars:      runEnvVars,
+		Timeout:      runTimeout,
+		QueueOnFail:  runQueueOnFail,
+		Mkdir:        runMkdir,
+		OnSuccess:    onSuccess,
+		OnFailure:    onFailure,
+		PreStart:     runPreStart,
+		PostFinish:   runPostFinish,
+		IgnoreLimits: runIgnoreLimits,
+		Script:       script,
+		Tags:         runTags,
+		Artifacts:    runArtifacts,
+		Results:      resultSpec,
+		Backend:      runBackend,
+		GPUs:         runGPUs,
+		Force:        runForce,
+		Secrets:      runSecrets,
+		Request:      request,
+		Resume:       runResumeCmd,
+		Experiment:   runExperiment,
+		SplitStderr:  runSplitStderr,
+		Staged:       staged,
 		OnPrepared: func(info StartJobPreparedInfo) {
 			fmt.Printf("Starting job %d on %s\n", info.JobID, info.Host)
 			fmt.Printf("Working directory: %s\n", info.WorkingDir)
@@ -284,6 +700,9 @@ func runRun(cmd *cobra.Command, args []string) error {
 
 	fmt.Println("✓ Session started successfully")
 	fmt.Printf("Job ID: %d\n", result.Info.JobID)
+	if len(result.GPUs) > 0 {
+		fmt.Printf("GPUs: %s\n", gpupool.FormatIndices(result.GPUs))
+	}
 
 	if runAllow {
 		return streamJobLogAllow(host, result.Info.LogFile, result.Info.JobID)
@@ -292,7 +711,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 	if runFollow {
 		fmt.Printf("\nFollowing log output (Ctrl+C to stop)...\n\n")
 		tailCmd := fmt.Sprintf("tail -n 50 -f %s", result.Info.LogFile)
-		sshCmd := exec.Command("ssh", host, tailCmd)
+		sshCmd := ssh.Command(host, tailCmd)
 		sshCmd.Stdout = os.Stdout
 		sshCmd.Stderr = os.Stderr
 		return sshCmd.Run()
@@ -305,7 +724,104 @@ func runRun(cmd *cobra.Command, args []string) error {
 	fmt.Printf("\nView log:\n")
 	fmt.Printf("  remote-jobs log %d                      # View log\n", result.Info.JobID)
 	fmt.Printf("  remote-jobs log %d -f                   # Follow log\n", result.Info.JobID)
+	if runSplitStderr {
+		fmt.Printf("  remote-jobs log %d --stderr             # View stderr only\n", result.Info.JobID)
+	}
+
+	return nil
+}
+
+// startOnlyRunFlags returns the run flags that are set and only change how a
+// job is started now, which a queue runner would ignore
+func startOnlyRunFlags() []string {
+	return setFlags([]runFlag{
+		{"--mkdir", runMkdir},
+		{"--pre-start", runPreStart != ""},
+		{"--post-finish", runPostFinish != ""},
+		{"--backend", runBackend != session.BackendAuto},
+		{"--split-stderr", runSplitStderr},
+	})
+}
 
+// undeferrableRunFlags returns the run flags that are set and only apply to
+// jobs started now, which keep a job from being deferred to a queue
+func undeferrableRunFlags() []string {
+	return append(startOnlyRunFlags(), setFlags([]runFlag{
+		{"--gpus", runGPUs > 0},
+		{"--secret", len(runSecrets) > 0},
+		{"--stage", len(runStages) > 0},
+		{"--follow", runFollow},
+		{"--allow", runAllow},
+	})...)
+}
+
+// runFlag is a run flag and whether it is set
+type runFlag struct {
+	name string
+	set  bool
+}
+
+// setFlags returns the names of the flags that are set
+func setFlags(flags []runFlag) []string {
+	var names []string
+	for _, f := range flags {
+		if f.set {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// validateSecretsFlag checks that --secret names are environment variable
+// names that -e doesn't also set
+func validateSecretsFlag(names, envVars []string) error {
+	for _, name := range names {
+		if err := secrets.ValidateName(name); err != nil {
+			return err
+		}
+		for _, ev := range envVars {
+			if strings.HasPrefix(ev, name+"=") {
+				return fmt.Errorf("%s is set with both --secret and -e", name)
+			}
+		}
+	}
+	return nil
+}
+
+// parseStageFlags parses the --stage values, checking that each local path
+// is a file and making it absolute
+func parseStageFlags(specs []string) ([]session.StagedFile, error) {
+	var staged []session.StagedFile
+	for _, spec := range specs {
+		f, err := session.ParseStage(spec)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(f.Local)
+		if err != nil {
+			return nil, fmt.Errorf("--stage: %w", err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("--stage copies files, and %s is a directory", f.Local)
+		}
+		// The copy may not run in this directory, as on a local host
+		if f.Local, err = filepath.Abs(f.Local); err != nil {
+			return nil, fmt.Errorf("--stage: %w", err)
+		}
+		staged = append(staged, f)
+	}
+	return staged, nil
+}
+
+// validateGPUsFlag checks --gpus against the job's environment variables,
+// which can't also set CUDA_VISIBLE_DEVICES
+func validateGPUsFlag(n int, envVars []string) error {
+	if n < 0 {
+		return fmt.Errorf("--gpus must not be negative")
+	}
+	if n > 0 && gpupool.VisibleDevices(envVars) != nil {
+		return fmt.Errorf("--gpus cannot be used with -e CUDA_VISIBLE_DEVICES")
+	}
 	return nil
 }
 
@@ -402,12 +918,12 @@ func getSlackWebhook() string {
 }
 
 func streamJobLogAllow(host, logFile string, jobID int64) error {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, stop := notifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	fmt.Printf("\nFollowing live output (Ctrl+C to stop streaming; job keeps running)...\n\n")
 	waitAndTail := fmt.Sprintf("sh -c 'while [ ! -f %s ]; do sleep 1; done; tail -n +1 -F %s'", logFile, logFile)
-	sshCmd := exec.CommandContext(ctx, "ssh", host, waitAndTail)
+	sshCmd := ssh.CommandContext(ctx, host, waitAndTail)
 	sshCmd.Stdout = os.Stdout
 	sshCmd.Stderr = os.Stderr
 	sshCmd.Stdin = nil
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var sandboxFlag bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&sandboxFlag, "sandbox", false,
+		"Simulate hosts on this machine, with a separate job database, for demos and tests (or set REMOTE_JOBS_SANDBOX=1)")
+	cobra.OnInitialize(enableSandbox)
+}
+
+// enableSandbox routes every host to a simulated one if --sandbox or
+// $REMOTE_JOBS_SANDBOX asks for it. It runs before commands load the config.
+func enableSandbox() {
+	if !sandboxFlag && !sandbox.FromEnv() {
+		return
+	}
+	dir, err := sandbox.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: choose sandbox directory: %v\n", err)
+		os.Exit(1)
+	}
+	created, err := sandbox.Enable(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: set up sandbox in %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Sandbox: hosts are simulated in %s, and jobs really run on this machine, as you.\n", dir)
+		fmt.Fprintf(os.Stderr, "Try: remote-jobs --sandbox run gpu1 'for i in $(seq 30); do echo step $i; sleep 2; done'\n\n")
+	}
+}
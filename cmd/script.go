@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+)
+
+// readScript reads a job script from a local file, or from stdin if path is "-"
+func readScript(path string) (*session.Script, error) {
+	if path == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read stdin: %w", err)
+		}
+		if len(content) == 0 {
+			return nil, fmt.Errorf("no command on stdin")
+		}
+		return &session.Script{Name: "stdin", Content: string(content)}, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read script: %w", err)
+	}
+	return &session.Script{Name: filepath.Base(path), Content: string(content)}, nil
+}
+
+// savedScript returns the script a job ran, so that it can be uploaded again
+// when the job is rerun, or nil if the job ran a command line
+func savedScript(database *sql.DB, jobID int64) (*session.Script, error) {
+	s, err := db.GetJobScript(database, jobID)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &session.Script{Name: s.Name, Content: s.Content}, nil
+}
+
+// saveJobScript records the script a job runs, warning rather than failing
+// since the job itself doesn't depend on the record
+func saveJobScript(database *sql.DB, jobID int64, script *session.Script) {
+	if script == nil {
+		return
+	}
+	err := db.SaveJobScript(database, db.JobScript{
+		JobID:   jobID,
+		Name:    script.Name,
+		SHA256:  script.Hash(),
+		Content: script.Content,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save script for job %d: %v\n", jobID, err)
+	}
+}
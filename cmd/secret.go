@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage secrets passed to jobs with run --secret",
+	Long: `Store API keys and other secrets in the system keychain, for jobs to
+receive as environment variables with run --secret NAME.
+
+Secrets are kept in the macOS login keychain, or on Linux in the Secret
+Service keyring (GNOME Keyring, KWallet) through secret-tool. run --secret
+falls back to the local environment variable of the same name, so a secret
+exported in the local shell needn't be stored.
+
+A job records only the names of its secrets. Their values are sent over
+ssh's stdin to a file on the host that only the user can read, which the
+job's wrapper reads and deletes before the job starts.
+
+Examples:
+  remote-jobs secret set WANDB_API_KEY          # Prompt for the value
+  pass show wandb | remote-jobs secret set WANDB_API_KEY
+  remote-jobs secret rm WANDB_API_KEY`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret in the keychain",
+	Long: `Store a secret in the keychain. The value is read from the terminal
+without echoing it, or from stdin when it isn't a terminal.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSecretSet,
+}
+
+var secretRmCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove"},
+	Short:   "Remove a secret from the keychain",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runSecretRm,
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretRmCmd)
+}
+
+func runSecretSet(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := secrets.ValidateName(name); err != nil {
+		return err
+	}
+
+	var value string
+	if term.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+		input, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("read value: %w", err)
+		}
+		value = string(input)
+		if value == "" {
+			return fmt.Errorf("empty secret value")
+		}
+	} else {
+		var err error
+		value, err = secrets.ReadValue(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read value: %w", err)
+		}
+	}
+
+	if err := secrets.Set(name, value); err != nil {
+		return err
+	}
+	fmt.Printf("Stored %s in the keychain\n", name)
+	return nil
+}
+
+func runSecretRm(cmd *cobra.Command, args []string) error {
+	if err := secrets.Delete(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s from the keychain\n", args[0])
+	return nil
+}
+
+// jobSecretValues returns the values of a job's secrets that can still be
+// found, for redacting its output
+func jobSecretValues(database *sql.DB, jobID int64) []string {
+	names, err := db.GetJobSecrets(database, jobID)
+	if err != nil || len(names) == 0 {
+		return nil
+	}
+	return secrets.Values(names)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/jobselect"
+	"github.com/spf13/cobra"
+)
+
+// selectorHelp describes the selectors that commands accept in place of job
+// IDs, for their help
+const selectorHelp = `Instead of a job ID, a job can be given as "latest" or @last, for the most
+recently submitted job; as @failed or @running, for the job that most
+recently failed or started running (@failed[1] for the one before, and so
+on); or as (part of) its description, such as 'gpt2 ablation'. The words of
+a description can be given in any order. If several runs have that
+description, the most recent is used. --host and --running narrow the jobs
+these pick from.`
+
+// jobSelector narrows the jobs that selectors such as "latest" and
+// descriptions pick from, with --host and --running
+type jobSelector struct {
+	Host    string
+	Running bool
+}
+
+// addFlags adds --host and --running to a command
+func (s *jobSelector) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.Host, "host", "", "Only select jobs on this host (with \"latest\", a description, or no job ID)")
+	cmd.Flags().BoolVar(&s.Running, "running", false, "Only select running jobs (with \"latest\", a description, or no job ID)")
+}
+
+// isSet reports whether --host or --running was given
+func (s jobSelector) isSet() bool {
+	return s.Host != "" || s.Running
+}
+
+// candidates returns the jobs selectors pick from, newest first
+func (s jobSelector) candidates(database *sql.DB) ([]*db.Job, error) {
+	var status db.Status
+	if s.Running {
+		status = db.StatusRunning
+	}
+	jobs, err := db.ListJobs(database, status, s.Host, -1)
+	if err != nil {
+		return nil, fmt.Errorf("list jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// describe describes the jobs selectors pick from, for errors
+func (s jobSelector) describe() string {
+	jobs := "jobs"
+	if s.Running {
+		jobs = "running jobs"
+	}
+	if s.Host != "" {
+		jobs += " on " + s.Host
+	}
+	return jobs
+}
+
+// resolve returns the ID of the job that arg, a job ID or a selector, names
+func (s jobSelector) resolve(database *sql.DB, arg string) (int64, error) {
+	return s.resolver(database).resolve(arg)
+}
+
+// jobResolver resolves the arguments of a command that takes several,
+// listing the jobs selectors pick from only once
+type jobResolver struct {
+	jobSelector
+	database *sql.DB
+	jobs     []*db.Job // Listed when a selector first needs them
+	listed   bool
+}
+
+// resolver returns a resolver for a command's arguments
+func (s jobSelector) resolver(database *sql.DB) *jobResolver {
+	return &jobResolver{jobSelector: s, database: database}
+}
+
+// resolve returns the ID of the job that arg, a job ID or a selector, names
+func (r *jobResolver) resolve(arg string) (int64, error) {
+	if id, ok := jobselect.ParseID(arg); ok {
+		return id, nil
+	}
+	if !r.listed {
+		jobs, err := r.candidates(r.database)
+		if err != nil {
+			return 0, err
+		}
+		r.jobs, r.listed = jobs, true
+	}
+	job, err := jobselect.Select(r.jobs, arg)
+	switch {
+	case err != nil:
+		return 0, err
+	case job == nil && arg == jobselect.Latest:
+		return 0, fmt.Errorf("no %s", r.describe())
+	case job == nil && r.isSet():
+		return 0, fmt.Errorf("no job matches %q among %s", arg, r.describe())
+	case job == nil:
+		return 0, fmt.Errorf("no job matches %q", arg)
+	}
+	return job.ID, nil
+}
+
+// resolveFlag returns the ID of the job that a flag such as --after names,
+// by ID or selector, or 0 if the flag wasn't given
+func (s jobSelector) resolveFlag(database *sql.DB, flag, arg string) (int64, error) {
+	if arg == "" {
+		return 0, nil
+	}
+	id, err := s.resolve(database, arg)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", flag, err)
+	}
+	return id, nil
+}
+
+// resolveOne returns the ID of the job a single-job command's argument
+// names. Without one, --host or --running select their latest job.
+func (s jobSelector) resolveOne(database *sql.DB, args []string) (int64, error) {
+	switch {
+	case len(args) > 0:
+		return s.resolve(database, args[0])
+	case s.isSet():
+		return s.resolve(database, jobselect.Latest)
+	}
+	return 0, fmt.Errorf("requires a job ID or selector (latest, a description, --host, or --running)")
+}
+
+// selectAll returns the IDs of all the jobs --host and --running select,
+// for commands given no job IDs
+func (s jobSelector) selectAll(database *sql.DB) ([]int64, error) {
+	if !s.isSet() {
+		return nil, fmt.Errorf("requires job IDs or selectors (latest, a description, --host, or --running)")
+	}
+	jobs, err := s.candidates(database)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no %s", s.describe())
+	}
+	ids := make([]int64, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids, nil
+}
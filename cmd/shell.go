@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell <host>",
+	Short: "Open or reattach a named interactive shell on a host",
+	Long: `Open an interactive shell in a tmux session on a remote host, or reattach
+to it if it already exists. Detach with the tmux prefix key followed by d
+(Ctrl-b d by default); the shell keeps running, and the same command
+reattaches to it later, for example after a dropped connection.
+
+Shells are tracked separately from jobs: they don't appear in job lists,
+and cleanup never kills them. Use 'shell list' to see them.
+
+A new shell starts in the working directory (default: the current directory,
+relative to home). Each host can have several shells, told apart by --name.
+
+Examples:
+  remote-jobs shell cool30                 # Open or reattach the "main" shell
+  remote-jobs shell cool30 --name scratch  # A second shell
+  remote-jobs shell list                   # Shells on all hosts
+  remote-jobs shell kill cool30 --name scratch`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShell,
+}
+
+var shellListCmd = &cobra.Command{
+	Use:   "list [host]",
+	Short: "List interactive shells",
+	Long: `List the interactive shells opened with 'shell', checking each host to
+see which still exist and whether a client is attached.
+
+Statuses:
+  attached  The shell exists and a client is attached to it
+  detached  The shell exists and can be reattached
+  ended     The shell exited or its session was killed
+  unknown   The host couldn't be reached`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runShellList,
+}
+
+var shellKillCmd = &cobra.Command{
+	Use:   "kill <host>",
+	Short: "End an interactive shell",
+	Long: `Kill a shell's tmux session, ending the shell and anything running in it,
+and remove it from the shell list.
+
+Example:
+  remote-jobs shell kill cool30 --name scratch`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShellKill,
+}
+
+var (
+	shellName string
+	shellDir  string
+)
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+	shellCmd.AddCommand(shellListCmd)
+	shellCmd.AddCommand(shellKillCmd)
+
+	shellCmd.Flags().StringVarP(&shellName, "name", "n", session.DefaultShellName, "Name of the shell")
+	shellCmd.Flags().StringVarP(&shellDir, "directory", "C", "", "Working directory for a new shell")
+	shellKillCmd.Flags().StringVarP(&shellName, "name", "n", session.DefaultShellName, "Name of the shell")
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	host := args[0]
+	if err := session.ValidateShellName(shellName); err != nil {
+		return err
+	}
+
+	dir := shellDir
+	if dir == "" {
+		var err error
+		dir, err = defaultWorkingDir(host)
+		if err != nil {
+			return err
+		}
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	if err := db.RecordShellAttach(database, host, shellName, dir); err != nil {
+		return fmt.Errorf("record shell: %w", err)
+	}
+
+	// The session outlives the connection; the exit status only says how the
+	// connection ended, so check whether the session is still there
+	attachErr := ssh.RunInteractive(host, session.AttachShellCommand(shellName, dir))
+
+	exists, err := ssh.TmuxSessionExistsQuick(host, session.ShellSessionName(shellName))
+	switch {
+	case err != nil:
+		if attachErr != nil {
+			return fmt.Errorf("attach to shell: %w", attachErr)
+		}
+	case exists:
+		fmt.Printf("Detached from shell '%s' on %s. Reattach with: remote-jobs shell %s%s\n",
+			shellName, host, host, shellNameFlag(shellName))
+	default:
+		if err := db.MarkShellEnded(database, host, shellName); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to update shell: %v\n", err)
+		}
+		fmt.Printf("Shell '%s' on %s ended\n", shellName, host)
+	}
+	return nil
+}
+
+func runShellList(cmd *cobra.Command, args []string) error {
+	host := ""
+	if len(args) > 0 {
+		host = args[0]
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	shells, err := db.ListShellSessions(database, host)
+	if err != nil {
+		return fmt.Errorf("list shells: %w", err)
+	}
+	if len(shells) == 0 {
+		fmt.Println("No shells (open one with 'remote-jobs shell <host>')")
+		return nil
+	}
+
+	statuses := probeShells(database, shells)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HOST\tNAME\tSTATUS\tCREATED\tLAST ATTACHED\tDIR\n")
+	now := time.Now()
+	for _, s := range shells {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Host, s.Name, statuses[shellKey(s.Host, s.Name)],
+			displayTimes.Short(s.CreatedAt, nil, now), displayTimes.Short(s.LastAttachedAt, nil, now), s.WorkingDir)
+	}
+	w.Flush()
+	return nil
+}
+
+// probeShells checks each host with a recorded active shell for its tmux
+// sessions, recording shells that no longer exist as ended. Returns each
+// shell's status by host and name.
+func probeShells(database *sql.DB, shells []*db.ShellSession) map[string]string {
+	statuses := make(map[string]string)
+	hosts := make(map[string]bool)
+	for _, s := range shells {
+		statuses[shellKey(s.Host, s.Name)] = s.Status()
+		if s.Status() == db.ShellActive {
+			hosts[s.Host] = true
+		}
+	}
+
+	hostNames := make([]string, 0, len(hosts))
+	for host := range hosts {
+		hostNames = append(hostNames, host)
+	}
+	sort.Strings(hostNames)
+
+	for _, host := range hostNames {
+		stdout, _, err := ssh.Run(host, session.ListShellsCommand())
+		var live map[string]int
+		if err == nil {
+			live = session.ParseShellList(stdout)
+		}
+		for _, s := range shells {
+			if s.Host != host || s.Status() != db.ShellActive {
+				continue
+			}
+			key := shellKey(s.Host, s.Name)
+			attached, exists := live[s.Name]
+			switch {
+			case err != nil:
+				statuses[key] = "unknown"
+			case !exists:
+				statuses[key] = db.ShellEnded
+				if err := db.MarkShellEnded(database, s.Host, s.Name); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to update shell %s on %s: %v\n", s.Name, s.Host, err)
+				}
+			case attached > 0:
+				statuses[key] = "attached"
+			default:
+				statuses[key] = "detached"
+			}
+		}
+	}
+	return statuses
+}
+
+func runShellKill(cmd *cobra.Command, args []string) error {
+	host := args[0]
+	if err := session.ValidateShellName(shellName); err != nil {
+		return err
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	exists, err := ssh.TmuxSessionExists(host, session.ShellSessionName(shellName))
+	if err != nil {
+		return fmt.Errorf("check shell: %w", err)
+	}
+	if exists {
+		if err := ssh.TmuxKillSession(host, session.ShellSessionName(shellName)); err != nil {
+			return fmt.Errorf("kill shell: %w", err)
+		}
+		fmt.Printf("Killed shell '%s' on %s\n", shellName, host)
+	} else {
+		fmt.Printf("Shell '%s' on %s had already ended\n", shellName, host)
+	}
+	if err := db.DeleteShellSession(database, host, shellName); err != nil {
+		return fmt.Errorf("remove shell: %w", err)
+	}
+	return nil
+}
+
+func shellKey(host, name string) string {
+	return host + "\t" + name
+}
+
+// shellNameFlag returns the --name flag that selects a shell, or "" for the
+// default shell
+func shellNameFlag(name string) string {
+	if name == session.DefaultShellName {
+		return ""
+	}
+	return " --name " + name
+}
@@ -10,7 +10,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/osteele/remote-jobs/internal/clockskew"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/osteele/remote-jobs/internal/secrets"
 	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
@@ -28,24 +32,44 @@ var (
 	statusSync        bool
 	statusNoSync      bool
 	statusWait        bool
+	statusWaitAny     bool
 	statusWaitTimeout time.Duration
+	statusExplain     bool
+	statusSelector    jobSelector
 )
 
 var statusCmd = &cobra.Command{
-	Use:   "status <job-id>...",
+	Use:   "status [job-id...]",
 	Short: "Check the status of one or more jobs",
 	Long: `Check the status of one or more jobs.
 
-Exit codes (single job only):
-  0: Job completed successfully
-  1: Job failed or error
-  2: Job is still running
-  3: Job not found
+Exit codes (single job, or all jobs with --wait):
+  0: Job(s) completed successfully
+  1: At least one job failed, died, could not start, or was skipped
+  2: At least one job is still running (e.g. --wait-timeout expired)
+  3: At least one job was not found
+
+With --wait, a progress line is printed as each job finishes, followed by a
+summary. With --any, waiting stops as soon as the first job finishes and the
+exit code reflects that job only.
+
+With --explain, each change that a check of the job's host made to its
+status is listed with the evidence for it: for a job marked dead, whether
+its tmux session, status file, and process were found.
+
+` + selectorHelp + ` Without job IDs, --host and --running select all
+the jobs they match.
 
 Examples:
   remote-jobs status 42
-  remote-jobs status 42 43 44`,
-	Args: cobra.MinimumNArgs(1),
+  remote-jobs status 42 43 44
+  remote-jobs status latest
+  remote-jobs status 'gpt2 ablation'
+  remote-jobs status --host cool30 --running
+  remote-jobs status --wait 42 43 44
+  remote-jobs status --wait --any 42 43
+  remote-jobs status --wait --wait-timeout 2h 42 43
+  remote-jobs status --explain 42`,
 	RunE: runStatus,
 }
 
@@ -54,7 +78,10 @@ func init() {
 	statusCmd.Flags().BoolVar(&statusSync, "sync", false, "Perform full sync (default is fast sync with timeout)")
 	statusCmd.Flags().BoolVar(&statusNoSync, "no-sync", false, "Skip syncing job statuses before checking")
 	statusCmd.Flags().BoolVar(&statusWait, "wait", false, "Wait for the job(s) to complete before returning")
+	statusCmd.Flags().BoolVar(&statusWaitAny, "any", false, "With --wait, return as soon as any job finishes")
 	statusCmd.Flags().DurationVar(&statusWaitTimeout, "wait-timeout", 0, "Maximum time to wait for completion (0 = no limit)")
+	statusCmd.Flags().BoolVar(&statusExplain, "explain", false, "Show the checks behind each change sync made to the job's status, such as marking it dead")
+	statusSelector.addFlags(statusCmd)
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -63,7 +90,14 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
+	defer waitForDeliveries()
 
+	if statusWaitAny && !statusWait {
+		return fmt.Errorf("--any requires --wait")
+	}
+	if statusExplain && statusWait {
+		return fmt.Errorf("--explain can't be used with --wait")
+	}
 	if statusWait {
 		statusSync = true
 		statusNoSync = false
@@ -89,6 +123,16 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if len(args) == 0 {
+		ids, err := statusSelector.selectAll(database)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			args = append(args, strconv.FormatInt(id, 10))
+		}
+	}
+
 	waitRequests := make([]jobStatusRequest, 0, len(args))
 	waitInputInvalid := false
 	singleJob := len(args) == 1 && !statusWait
@@ -97,11 +141,11 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			fmt.Println("---")
 		}
 
-		jobID, err := strconv.ParseInt(arg, 10, 64)
+		jobID, err := statusSelector.resolve(database, arg)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid job ID: %s\n", arg)
+			fmt.Fprintln(os.Stderr, err)
 			if singleJob {
-				os.Exit(ExitNotFound)
+				exit(ExitNotFound)
 			}
 			if statusWait {
 				waitInputInvalid = true
@@ -113,7 +157,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Job %d: %v\n", jobID, err)
 			if singleJob {
-				os.Exit(ExitNotFound)
+				exit(ExitNotFound)
 			}
 			if statusWait {
 				waitInputInvalid = true
@@ -133,22 +177,23 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		if len(waitRequests) == 0 {
 			return fmt.Errorf("no valid job IDs to wait for")
 		}
-		results, err := waitForJobsCompletion(database, waitRequests, statusWaitTimeout)
-		if err != nil {
-			if errors.Is(err, errWaitTimeout) {
-				fmt.Fprintf(os.Stderr, "%v\n", err)
-			}
+		results, first, err := waitForJobsCompletion(database, waitRequests, statusWaitTimeout, statusWaitAny)
+		timedOut := errors.Is(err, errWaitTimeout)
+		if err != nil && !timedOut {
 			return err
 		}
-		allSucceeded := allJobsSucceeded(waitRequests, results)
-		if waitInputInvalid {
-			allSucceeded = false
+		if timedOut {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 		}
-		if allSucceeded {
-			os.Exit(ExitSuccess)
-		} else {
-			os.Exit(ExitFailed)
+		printWaitSummary(waitRequests, results)
+		if !statusWaitAny {
+			first = 0
+		}
+		code := waitExitCode(waitRequests, results, first)
+		if waitInputInvalid && code == ExitSuccess {
+			code = ExitNotFound
 		}
+		exit(code)
 	}
 
 	return nil
@@ -158,20 +203,27 @@ func printSingleJobStatus(database *sql.DB, jobID int64, job *db.Job, exitOnComp
 	if job == nil {
 		fmt.Printf("Job %d not found\n", jobID)
 		if exitOnComplete {
-			os.Exit(ExitNotFound)
+			exit(ExitNotFound)
 		}
 		return
 	}
 
 	// If job is already marked as completed or dead, use cached result
 	if job.Status == db.StatusCompleted || job.Status == db.StatusDead {
-		printJobStatus(job, exitOnComplete)
+		printJobStatus(database, job, exitOnComplete)
 		return
 	}
 
 	// Job is marked as running - verify actual status on remote
+	nohup := jobstate.RunsWithoutTmux(database, job.ID)
 	tmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-	exists, err := ssh.TmuxSessionExists(job.Host, tmuxSession)
+	var exists bool
+	var err error
+	if nohup {
+		exists, err = nohupJobRunning(job.Host, job.ID)
+	} else {
+		exists, err = ssh.TmuxSessionExists(jobHost(job), tmuxSession)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Job %d: check session: %v\n", jobID, err)
 		return
@@ -180,7 +232,7 @@ func printSingleJobStatus(database *sql.DB, jobID int64, job *db.Job, exitOnComp
 	if !exists {
 		// Session doesn't exist - check for status file
 		statusFile := session.JobStatusFile(job.ID, job.StartTime, job.SessionName)
-		content, err := ssh.ReadRemoteFile(job.Host, statusFile)
+		content, mtime, err := ssh.ReadStatusFile(jobHost(job), statusFile, ssh.HostTimeouts(job.Host).Sync)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Job %d: read status file: %v\n", jobID, err)
 			return
@@ -189,7 +241,7 @@ func printSingleJobStatus(database *sql.DB, jobID int64, job *db.Job, exitOnComp
 		if content != "" {
 			// Job completed, update database
 			exitCode, _ := strconv.Atoi(strings.TrimSpace(content))
-			endTime := time.Now().Unix()
+			endTime := clockskew.EndTime(database, job, mtime, time.Now())
 			if err := db.RecordCompletionByID(database, job.ID, exitCode, endTime); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to update database: %v\n", err)
 			}
@@ -198,21 +250,35 @@ func printSingleJobStatus(database *sql.DB, jobID int64, job *db.Job, exitOnComp
 			job.EndTime = &endTime
 		} else {
 			// No status file - job died unexpectedly
-			if err := db.MarkDeadByID(database, job.ID); err != nil {
+			evidence := jobstate.SessionDeadEvidence(tmuxSession, statusFile)
+			if nohup {
+				evidence[0] = db.Evidence{Check: "process (nohup)", Result: "not running"}
+			}
+			dead, err := jobstate.MarkDeadAfterGrace(database, job, "status", evidence)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to update database: %v\n", err)
 			}
-			job.Status = db.StatusDead
+			if dead {
+				job.Status = db.StatusDead
+			} else {
+				fmt.Fprintf(os.Stderr, "Note: job %d's session and status file are missing; it will be marked dead if they stay missing\n", job.ID)
+			}
 		}
 	} else if exitOnComplete {
 		// Session still running - show last few lines of output (only for single job)
-		output, _ := ssh.TmuxCapturePaneOutput(job.Host, tmuxSession, 5)
+		var output string
+		if nohup {
+			output, _, _ = ssh.Run(jobHost(job), logTailCommand(job, 5))
+		} else {
+			output, _ = ssh.TmuxCapturePaneOutput(jobHost(job), tmuxSession, 5)
+		}
 		if output != "" {
 			fmt.Println("Last output:")
-			fmt.Println(output)
+			fmt.Println(redactText(secrets.Redact(output, jobSecretValues(database, job.ID))))
 		}
 	}
 
-	printJobStatus(job, exitOnComplete)
+	printJobStatus(database, job, exitOnComplete)
 }
 
 var errWaitTimeout = errors.New("wait timeout")
@@ -238,7 +304,7 @@ func waitForJobCompletion(database *sql.DB, jobID int64, timeout time.Duration)
 		if job == nil {
 			return nil, fmt.Errorf("job %d not found", jobID)
 		}
-		if isTerminalStatus(job.Status) {
+		if job.Status.Terminal() {
 			return job, nil
 		}
 		if timeout > 0 && time.Now().After(deadline) {
@@ -255,29 +321,44 @@ func waitForJobCompletion(database *sql.DB, jobID int64, timeout time.Duration)
 	}
 }
 
-func waitForJobsCompletion(database *sql.DB, jobs []jobStatusRequest, timeout time.Duration) (map[int64]*db.Job, error) {
+// waitForJobsCompletion polls until every job reaches a terminal state (or, if
+// stopOnAny is set, until the first one does). A progress line is printed as each job
+// finishes. It also returns the ID of the first job seen to finish, or 0 if
+// none did. On timeout the partial results are returned with errWaitTimeout.
+func waitForJobsCompletion(database *sql.DB, jobs []jobStatusRequest, timeout time.Duration, stopOnAny bool) (map[int64]*db.Job, int64, error) {
 	final := make(map[int64]*db.Job, len(jobs))
 	pending := make(map[int64]struct{})
 	order := make([]int64, 0, len(jobs))
+	total := len(jobs)
+	done := 0
+	var first int64
 	for _, req := range jobs {
 		final[req.ID] = req.Job
 		if req.Job == nil {
-			fmt.Printf("Job %d not found\n", req.ID)
+			done++
+			fmt.Printf("[%d/%d] Job %d not found\n", done, total, req.ID)
 			continue
 		}
-		if isTerminalStatus(req.Job.Status) {
-			printJobStatus(req.Job, false)
+		if req.Job.Status.Terminal() {
+			done++
+			if first == 0 {
+				first = req.ID
+			}
+			printWaitProgress(req.Job, done, total)
 			continue
 		}
 		pending[req.ID] = struct{}{}
 		order = append(order, req.ID)
 	}
 
-	if len(pending) == 0 {
-		return final, nil
+	if len(pending) == 0 || (stopOnAny && first != 0) {
+		return final, first, nil
 	}
 
 	fmt.Printf("Waiting for %d job(s)", len(pending))
+	if stopOnAny {
+		fmt.Printf(" (until any finishes)")
+	}
 	if timeout > 0 {
 		fmt.Printf(" (timeout: %s)", timeout)
 	}
@@ -291,6 +372,15 @@ func waitForJobsCompletion(database *sql.DB, jobs []jobStatusRequest, timeout ti
 		deadline = time.Now().Add(timeout)
 	}
 
+	finished := func(job *db.Job) {
+		delete(pending, job.ID)
+		done++
+		if first == 0 {
+			first = job.ID
+		}
+		printWaitProgress(job, done, total)
+	}
+
 	for len(pending) > 0 {
 		for _, id := range order {
 			if _, ok := pending[id]; !ok {
@@ -298,38 +388,40 @@ func waitForJobsCompletion(database *sql.DB, jobs []jobStatusRequest, timeout ti
 			}
 			job, err := db.GetJobByID(database, id)
 			if err != nil {
-				return final, err
+				return final, first, err
 			}
 			final[id] = job
 			if job == nil {
-				fmt.Printf("Job %d not found\n", id)
 				delete(pending, id)
+				done++
+				fmt.Printf("[%d/%d] Job %d not found\n", done, total, id)
 				continue
 			}
-			if isTerminalStatus(job.Status) {
-				printJobStatus(job, false)
-				delete(pending, id)
+			if job.Status.Terminal() {
+				finished(job)
 				continue
 			}
 			if shouldAttemptSync(job.Status) {
 				if _, err := syncJob(database, job); err != nil {
 					if !ssh.IsConnectionError(err.Error()) {
-						return final, err
+						return final, first, err
 					}
 				}
 				job, err = db.GetJobByID(database, id)
 				if err != nil {
-					return final, err
+					return final, first, err
 				}
 				final[id] = job
-				if job != nil && isTerminalStatus(job.Status) {
-					printJobStatus(job, false)
-					delete(pending, id)
+				if job != nil && job.Status.Terminal() {
+					finished(job)
 				}
 			}
 		}
 
-		if len(pending) == 0 {
+		runCompletionHooks(database)
+		deliverWebhooks(database)
+
+		if len(pending) == 0 || (stopOnAny && first != 0) {
 			break
 		}
 
@@ -338,29 +430,92 @@ func waitForJobsCompletion(database *sql.DB, jobs []jobStatusRequest, timeout ti
 			for id := range pending {
 				ids = append(ids, id)
 			}
-			return final, fmt.Errorf("%w waiting for jobs: %s", errWaitTimeout, formatJobIDList(ids))
+			return final, first, fmt.Errorf("%w waiting for jobs: %s", errWaitTimeout, formatJobIDList(ids))
 		}
 
 		<-ticker.C
 	}
 
-	return final, nil
+	return final, first, nil
+}
+
+// printWaitProgress prints a one-line progress update for a job that finished
+func printWaitProgress(job *db.Job, done, total int) {
+	outcome := classifyJobStatus(job)
+	switch {
+	case job.Skipped():
+	case job.Status == db.StatusCompleted && job.ExitCode != nil && *job.ExitCode != 0:
+		outcome = fmt.Sprintf("%s (exit %d)", outcome, *job.ExitCode)
+	case job.Status == db.StatusDead:
+		outcome += " (dead)"
+	case job.Status == db.StatusFailed && job.ErrorMessage != "":
+		outcome = fmt.Sprintf("%s (%s)", outcome, job.ErrorMessage)
+	}
+	label := job.Description
+	if label == "" {
+		label = displayCommand(job)
+	}
+	fmt.Printf("[%d/%d] Job %d on %s %s: %s\n", done, total, job.ID, job.Host, outcome, truncate(label, 50))
 }
 
-func allJobsSucceeded(requests []jobStatusRequest, final map[int64]*db.Job) bool {
+// waitOutcome classifies a waited-on job for summaries and exit codes
+func waitOutcome(job *db.Job) string {
+	if job == nil {
+		return "not found"
+	}
+	if !job.Status.Terminal() {
+		return "unfinished"
+	}
+	return classifyJobStatus(job)
+}
+
+func printWaitSummary(requests []jobStatusRequest, final map[int64]*db.Job) {
+	counts := make(map[string]int)
 	for _, req := range requests {
-		job := final[req.ID]
-		if job == nil {
-			return false
+		counts[waitOutcome(final[req.ID])]++
+	}
+	var parts []string
+	for _, outcome := range []string{"succeeded", "failed", "skipped", "unfinished", "not found"} {
+		if counts[outcome] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[outcome], outcome))
 		}
-		if job.Status != db.StatusCompleted {
-			return false
+	}
+	fmt.Printf("Summary: %s\n", strings.Join(parts, ", "))
+}
+
+// waitExitCode summarizes the waited-on jobs as a single exit code.
+// Failures take precedence over missing jobs, which take precedence over
+// jobs that are still running. If first is set, as it is with --any once a
+// job finished, the code reflects that job only.
+func waitExitCode(requests []jobStatusRequest, final map[int64]*db.Job, first int64) int {
+	if first != 0 {
+		if waitOutcome(final[first]) == "succeeded" {
+			return ExitSuccess
 		}
-		if job.ExitCode == nil || *job.ExitCode != 0 {
-			return false
+		return ExitFailed
+	}
+	var failed, notFound, unfinished int
+	for _, req := range requests {
+		switch waitOutcome(final[req.ID]) {
+		case "succeeded":
+		case "failed", "skipped":
+			failed++
+		case "not found":
+			notFound++
+		default:
+			unfinished++
 		}
 	}
-	return true
+	switch {
+	case failed > 0:
+		return ExitFailed
+	case notFound > 0:
+		return ExitNotFound
+	case unfinished > 0:
+		return ExitRunning
+	default:
+		return ExitSuccess
+	}
 }
 
 func formatJobIDList(ids []int64) string {
@@ -372,69 +527,86 @@ func formatJobIDList(ids []int64) string {
 	return strings.Join(parts, ", ")
 }
 
-func isTerminalStatus(status string) bool {
-	switch status {
-	case db.StatusCompleted, db.StatusDead, db.StatusFailed:
-		return true
-	default:
-		return false
-	}
-}
-
-func shouldAttemptSync(status string) bool {
+func shouldAttemptSync(status db.Status) bool {
 	switch status {
-	case db.StatusRunning, db.StatusStarting, db.StatusQueued:
+	case db.StatusRunning, db.StatusStarting, db.StatusQueued, db.StatusPaused:
 		return true
 	default:
 		return false
 	}
 }
 
-func printJobStatus(job *db.Job, exitOnComplete bool) {
+func printJobStatus(database *sql.DB, job *db.Job, exitOnComplete bool) {
 	fmt.Printf("Job ID:   %d\n", job.ID)
 	fmt.Printf("Host:     %s\n", job.Host)
+	if user := jobRemoteUser(database, job); user != "" {
+		fmt.Printf("User:     %s\n", user)
+	}
 	fmt.Printf("Status:   %s\n", job.Status)
 
 	if job.Description != "" {
 		fmt.Printf("Desc:     %s\n", job.Description)
 	}
 
+	switch job.Status {
+	case db.StatusQueued:
+		if dep, _ := db.GetJobDependency(database, job.ID); dep != nil {
+			fmt.Printf("After:    %s\n", describeDependency(database, dep))
+		}
+	case db.StatusPending:
+		if dep, _ := db.GetPendingDependency(database, job.ID); dep != nil {
+			fmt.Printf("After:    %s (held locally until it finishes)\n", describeDependency(database, &dep.JobDependency))
+		}
+	}
+	if job.Status == db.StatusQueued || job.Status == db.StatusPending {
+		if guard, _ := db.GetJobGuard(database, job.ID); guard != nil {
+			fmt.Printf("If:       %s\n", guard)
+		}
+	}
+
+	zone := jobHostZone(database, job.ID)
 	if job.StartTime > 0 {
-		startTime := time.Unix(job.StartTime, 0)
-		fmt.Printf("Started:  %s\n", startTime.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Started:  %s\n", displayTimes.Full(job.StartTime, zone))
 	}
 
 	if job.EndTime != nil {
-		endTime := time.Unix(*job.EndTime, 0)
-		fmt.Printf("Ended:    %s\n", endTime.Format("2006-01-02 15:04:05"))
+		fmt.Printf("Ended:    %s\n", displayTimes.Full(*job.EndTime, zone))
 		if job.StartTime > 0 {
 			duration := *job.EndTime - job.StartTime
-			fmt.Printf("Duration: %s\n", db.FormatDuration(duration))
+			fmt.Printf("Duration: %s\n", humanfmt.Duration(duration))
 		}
-	} else if job.Status == db.StatusRunning && job.StartTime > 0 {
+	} else if (job.Status == db.StatusRunning || job.Status == db.StatusPaused) && job.StartTime > 0 {
 		duration := time.Now().Unix() - job.StartTime
-		fmt.Printf("Running:  %s\n", db.FormatDuration(duration))
+		fmt.Printf("Running:  %s\n", humanfmt.Duration(duration))
 	}
 
-	if job.ExitCode != nil {
+	if job.Skipped() {
+		fmt.Println("Exit:     skipped (its --if condition was false)")
+	} else if job.ExitCode != nil {
 		fmt.Printf("Exit:     %d\n", *job.ExitCode)
 	}
 
+	if statusExplain {
+		if err := printReconciliations(database, job); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
 	// Set exit code based on status (only for single job)
 	if exitOnComplete {
 		switch job.Status {
 		case db.StatusCompleted:
 			if job.ExitCode != nil && *job.ExitCode == 0 {
-				os.Exit(ExitSuccess)
+				exit(ExitSuccess)
 			} else {
-				os.Exit(ExitFailed)
+				exit(ExitFailed)
 			}
 		case db.StatusDead:
-			os.Exit(ExitFailed)
-		case db.StatusRunning:
-			os.Exit(ExitRunning)
+			exit(ExitFailed)
+		case db.StatusRunning, db.StatusPaused:
+			exit(ExitRunning)
 		default:
-			os.Exit(ExitNotFound)
+			exit(ExitNotFound)
 		}
 	}
 }
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/batch"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var submitCmd = &cobra.Command{
+	Use:   "submit --file <jobs.csv|jobs.jsonl|->",
+	Short: "Start or queue a batch of jobs from a CSV or JSONL file",
+	Long: `Start or queue one job per row of a CSV file, or per line of a JSONL
+file, such as a parameter sweep kept in a spreadsheet.
+
+A CSV file starts with a header row naming its columns:
+
+  host         Host to run on (default: --host)
+  command      Command to run (required)
+  dir          Working directory (default: --directory, or the current one)
+  description  Job description
+  queue        Queue to add the job to; empty starts it now (see --queue)
+  tags         Tags, separated by ";"
+  env          VAR=value pairs, separated by ";"
+  env.NAME     The value of environment variable NAME
+
+A JSONL file has an object per line with the same keys, where env is an
+object and tags a list. Lines starting with # are skipped.
+
+Every row is checked before any job is submitted. The job ID of each row is
+printed, and saved as CSV beside the file (NAME.jobs.csv), or to --map.
+
+Example jobs.csv:
+
+  host,command,env.LR,tags
+  cool30,python train.py,0.01,sweep7
+  cool30,python train.py,0.001,sweep7
+
+Examples:
+  remote-jobs submit --file jobs.csv
+  remote-jobs submit --file jobs.csv --queue     # Queue every row
+  remote-jobs submit --file jobs.jsonl -H cool30 # Default host
+  remote-jobs submit --file jobs.csv --dry-run   # Check the file`,
+	Args: cobra.NoArgs,
+	RunE: runSubmit,
+}
+
+var (
+	submitFile         string
+	submitFormat       string
+	submitHost         string
+	submitDir          string
+	submitQueue        bool
+	submitTags         []string
+	submitMap          string
+	submitDryRun       bool
+	submitIgnoreLimits bool
+	submitNoQueueStart bool
+	submitForce        bool
+)
+
+func init() {
+	rootCmd.AddCommand(submitCmd)
+	submitCmd.Flags().StringVarP(&submitFile, "file", "f", "", "CSV or JSONL file of jobs, or - for standard input")
+	submitCmd.Flags().StringVar(&submitFormat, "format", "", "csv or jsonl (default: from the file's extension)")
+	submitCmd.Flags().StringVarP(&submitHost, "host", "H", "", "Host for rows that don't name one")
+	submitCmd.Flags().StringVarP(&submitDir, "directory", "C", "", "Working directory for rows that don't name one")
+	submitCmd.Flags().BoolVar(&submitQueue, "queue", false, "Queue rows without a queue column in the default queue, instead of starting them")
+	submitCmd.Flags().StringSliceVarP(&submitTags, "tag", "t", nil, "Tag every job, can be repeated")
+	submitCmd.Flags().StringVar(&submitMap, "map", "", "Where to save the row-to-job-ID mapping (default: NAME.jobs.csv beside the file)")
+	submitCmd.Flags().BoolVar(&submitDryRun, "dry-run", false, "Check the file and show the jobs without submitting them")
+	submitCmd.Flags().BoolVar(&submitIgnoreLimits, "ignore-limits", false, "Start or queue jobs even if hosts' configured job limits are reached")
+	submitCmd.Flags().BoolVar(&submitForce, "force", false, "Start jobs even if the GPUs they would use are heavily used by other processes")
+	submitCmd.Flags().BoolVar(&submitNoQueueStart, "no-queue-start", false, "Don't start queue runners for queued jobs")
+	submitCmd.MarkFlagRequired("file")
+}
+
+func runSubmit(cmd *cobra.Command, args []string) error {
+	data, err := readPlanInput(submitFile)
+	if err != nil {
+		return err
+	}
+	format := submitFormat
+	if format == "" {
+		format = batch.Format(submitFile)
+	}
+	rows, err := batch.Parse(data, format)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", submitFile, err)
+	}
+	batch.ApplyDefaults(rows, batch.Defaults{Host: submitHost, Dir: submitDir})
+	for i := range rows {
+		rows[i].Tags = append(rows[i].Tags, submitTags...)
+		if submitQueue && rows[i].Queue == "" {
+			rows[i].Queue = defaultQueueName
+		}
+	}
+	if err := batch.Validate(rows); err != nil {
+		return fmt.Errorf("%s:\n%w", submitFile, err)
+	}
+
+	if submitDryRun {
+		for _, row := range rows {
+			fmt.Printf("line %d: %s\n", row.Line, describeBatchRow(row))
+		}
+		fmt.Printf("\n%d job(s) would be submitted\n", len(rows))
+		return nil
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+
+	results := make([]batch.Result, len(rows))
+	startedQueues := make(map[string]bool)
+	failed := 0
+	for i, row := range rows {
+		results[i].Row = row
+		jobID, outcome, err := submitBatchRow(database, row)
+		if err != nil {
+			results[i].Error = err.Error()
+			failed++
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", row.Line, err)
+			continue
+		}
+		results[i].JobID = jobID
+		fmt.Printf("line %d: job %d %s\n", row.Line, jobID, outcome)
+		if row.Queue != "" && !submitNoQueueStart {
+			maybeStartQueueRunner(row.Host, row.Queue, startedQueues)
+		}
+	}
+
+	mapPath := submitMap
+	if mapPath == "" && submitFile != "-" {
+		mapPath = batch.ResultsPath(submitFile)
+	}
+	if mapPath != "" {
+		var buf bytes.Buffer
+		if err := batch.WriteResults(&buf, results); err != nil {
+			return err
+		}
+		if err := os.WriteFile(mapPath, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("save job IDs: %w", err)
+		}
+		fmt.Printf("\nSaved the job IDs to %s\n", mapPath)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d job(s) weren't submitted", failed, len(rows))
+	}
+	return nil
+}
+
+// submitBatchRow starts or queues a row's job, returning its ID and what
+// became of it
+func submitBatchRow(database *sql.DB, row batch.Row) (int64, string, error) {
+	if row.Queue == "" {
+		result, err := startJob(database, startJobOptions{
+			Host:         row.Host,
+			WorkingDir:   row.Dir,
+			Command:      row.Command,
+			Description:  row.Description,
+			EnvVars:      row.Env,
+			Tags:         row.Tags,
+			IgnoreLimits: submitIgnoreLimits,
+			Force:        submitForce,
+		})
+		if err != nil {
+			return 0, "", err
+		}
+		if result.QueuedOnConnectionFailure {
+			return result.Info.JobID, fmt.Sprintf("queued locally for retry (connection to %s failed)", row.Host), nil
+		}
+		return result.Info.JobID, "started on " + row.Host, nil
+	}
+
+	dir := row.Dir
+	if dir == "" {
+		var err error
+		if dir, err = defaultWorkingDir(row.Host); err != nil {
+			return 0, "", err
+		}
+	}
+	jobID, err := queueJob(database, queueJobOptions{
+		Host:         row.Host,
+		WorkingDir:   dir,
+		Command:      row.Command,
+		Description:  row.Description,
+		EnvVars:      row.Env,
+		QueueName:    row.Queue,
+		Tags:         row.Tags,
+		IgnoreLimits: submitIgnoreLimits,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return jobID, fmt.Sprintf("queued on %s (queue %s)", row.Host, row.Queue), nil
+}
+
+func describeBatchRow(row batch.Row) string {
+	var b strings.Builder
+	if row.Queue != "" {
+		fmt.Fprintf(&b, "queue on %s (%s)", row.Host, row.Queue)
+	} else {
+		fmt.Fprintf(&b, "start on %s", row.Host)
+	}
+	fmt.Fprintf(&b, ": %s", row.Command)
+	if row.Dir != "" {
+		fmt.Fprintf(&b, " in %s", row.Dir)
+	}
+	if len(row.Env) > 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(row.Env, " "))
+	}
+	if len(row.Tags) > 0 {
+		fmt.Fprintf(&b, " tags %s", strings.Join(row.Tags, ","))
+	}
+	return b.String()
+}
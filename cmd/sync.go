@@ -4,11 +4,17 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/osteele/remote-jobs/internal/clockskew"
+	"github.com/osteele/remote-jobs/internal/config"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/osteele/remote-jobs/internal/reachability"
 	"github.com/osteele/remote-jobs/internal/session"
 	"github.com/osteele/remote-jobs/internal/ssh"
 	"github.com/spf13/cobra"
@@ -22,6 +28,17 @@ var syncCmd = &cobra.Command{
 Automatically finds hosts with running jobs and updates their status
 in the local database. Connection failures are silently ignored.
 
+If the idle-GPU watchdog is configured (watchdog.idle_gpu_minutes in
+config.yaml), sync also samples GPU utilization of running jobs and
+warns about, notifies on, or kills jobs whose GPUs have sat idle.
+
+Jobs queued with --after a job on another host are held locally; sync
+adds them to their host's queue once that job finishes.
+
+If prune.auto is set in config.yaml, sync then applies the prune policy
+(see prune --apply-policy). Finally it backs up and garbage-collects the
+local database when they are due (see db).
+
 Examples:
   remote-jobs sync              # Sync all hosts
   remote-jobs sync --verbose    # Show progress`,
@@ -31,8 +48,6 @@ Examples:
 var syncVerbose bool
 
 const (
-	// FastSyncTimeout is used for quick syncs in list/status commands
-	FastSyncTimeout = 2 * time.Second
 	// NormalSyncTimeout is used for explicit sync commands
 	NormalSyncTimeout = 30 * time.Second
 )
@@ -48,6 +63,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
+	defer waitForDeliveries()
 
 	// Get all unique hosts with running or queued jobs
 	hosts, err := db.ListUniqueActiveHosts(database)
@@ -55,19 +71,40 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("list hosts: %w", err)
 	}
 
+	// Also visit hosts with pending deferred operations, such as file deletes
+	// left by prune, even if they no longer have active jobs
+	deferredHosts, err := db.ListHostsWithDeferredOperations(database)
+	if err != nil {
+		return fmt.Errorf("list hosts: %w", err)
+	}
+	for _, host := range deferredHosts {
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
 	if len(hosts) == 0 {
 		fmt.Println("No active jobs to sync")
+		submitHeldJobs(database)
+		deliverWebhooks(database)
+		autoPrune(database)
+		autoMaintainDatabase(database)
 		return nil
 	}
 
 	var totalUpdated, hostsReached, hostsUnreachable int
+	wd := loadWatchdog()
+	reachability.LoadLatencies(database)
 
 	for _, host := range hosts {
 		if syncVerbose {
 			fmt.Printf("Checking %s...\n", host)
 		}
 
+		// An explicit sync tries every host, and refreshes the reachability
+		// cache that other commands and the TUI use to skip unreachable hosts
 		updated, err := syncHost(database, host)
+		reachability.Record(database, host, err, time.Now())
 		if err != nil {
 			// Check if it's a connection error
 			if ssh.IsConnectionError(err.Error()) {
@@ -87,6 +124,17 @@ func runSync(cmd *cobra.Command, args []string) error {
 		if syncVerbose && updated > 0 {
 			fmt.Printf("  %s: %d job(s) updated\n", host, updated)
 		}
+
+		// Time a round trip, for the timeouts of later commands on the host
+		if l, err := reachability.MeasureLatency(database, host, time.Now()); err == nil && syncVerbose {
+			fmt.Printf("  %s: %s round trip (average %s)\n", host, humanfmt.Latency(l.Last), humanfmt.Latency(l.Average))
+		}
+
+		if wd.Enabled() {
+			if err := runWatchdog(database, host, wd); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: idle-GPU watchdog on %s: %v\n", host, err)
+			}
+		}
 	}
 
 	// Print summary
@@ -97,6 +145,11 @@ func runSync(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Synced %d job(s) on %d host(s)\n", totalUpdated, hostsReached)
 	}
 
+	// Now that finished jobs are recorded, queue jobs that were waiting for them
+	submitHeldJobs(database)
+	deliverWebhooks(database)
+	autoPrune(database)
+	autoMaintainDatabase(database)
 	return nil
 }
 
@@ -107,7 +160,9 @@ func syncHost(database *sql.DB, host string) (int, error) {
 		return 0, err
 	}
 
+	started := time.Now().Unix()
 	var updated int
+	checked := make([]int64, 0, len(jobs))
 	for _, job := range jobs {
 		changed, err := syncJob(database, job)
 		if err != nil {
@@ -116,6 +171,11 @@ func syncHost(database *sql.DB, host string) (int, error) {
 		if changed {
 			updated++
 		}
+		checked = append(checked, job.ID)
+	}
+	// Jobs that looked dead before but not now are alive after all
+	if err := db.ClearDeadProbes(database, started, checked); err != nil {
+		return updated, err
 	}
 
 	// Execute any deferred operations for this host
@@ -126,11 +186,28 @@ func syncHost(database *sql.DB, host string) (int, error) {
 		}
 	}
 
+	// Record the output files of jobs that just finished
+	if err := resolveArtifacts(database, host); err != nil && syncVerbose {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record artifacts for %s: %v\n", host, err)
+	}
+	if err := jobstate.ResolveResults(database, host); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record results for %s: %v\n", host, err)
+	}
+
+	if updated > 0 {
+		runCompletionHooks(database)
+		deliverWebhooks(database)
+	}
+
 	return updated, nil
 }
 
 // syncJob checks and updates a single job's status, returning true if status changed
 func syncJob(database *sql.DB, job *db.Job) (bool, error) {
+	if job.Status == db.StatusPaused {
+		return jobstate.SyncPaused(database, job, "sync", ssh.HostTimeouts(job.Host).Sync)
+	}
+
 	// Jobs without a session name were started by the queue runner
 	// They don't have individual tmux sessions, so use pattern-based file lookup
 	if job.SessionName == "" {
@@ -139,7 +216,7 @@ func syncJob(database *sql.DB, job *db.Job) (bool, error) {
 
 	// Regular jobs have their own tmux sessions
 	tmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-	exists, err := ssh.TmuxSessionExistsQuick(job.Host, tmuxSession)
+	exists, err := ssh.TmuxSessionExistsQuick(jobHost(job), tmuxSession)
 	if err != nil {
 		return false, err
 	}
@@ -151,7 +228,7 @@ func syncJob(database *sql.DB, job *db.Job) (bool, error) {
 
 	// Session doesn't exist - check for status file (no retry for sync)
 	statusFile := session.JobStatusFile(job.ID, job.StartTime, job.SessionName)
-	content, err := ssh.ReadRemoteFileQuick(job.Host, statusFile)
+	content, mtime, err := ssh.ReadStatusFile(jobHost(job), statusFile, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		return false, err
 	}
@@ -159,18 +236,15 @@ func syncJob(database *sql.DB, job *db.Job) (bool, error) {
 	if content != "" {
 		// Job completed
 		exitCode, _ := strconv.Atoi(content)
-		endTime := time.Now().Unix()
+		endTime := clockskew.EndTime(database, job, mtime, time.Now())
 		if err := db.RecordCompletionByID(database, job.ID, exitCode, endTime); err != nil {
 			return false, err
 		}
 		return true, nil
 	}
 
-	// No status file - job died unexpectedly
-	if err := db.MarkDeadByID(database, job.ID); err != nil {
-		return false, err
-	}
-	return true, nil
+	// No status file - job died unexpectedly, or the host had a hiccup
+	return jobstate.MarkDeadAfterGrace(database, job, "sync", jobstate.SessionDeadEvidence(tmuxSession, statusFile))
 }
 
 // updateStartTimeFromMetadata reads the metadata file for a queued job and updates its start_time if not already set
@@ -180,10 +254,10 @@ func updateStartTimeFromMetadata(database *sql.DB, job *db.Job) {
 		return
 	}
 
-	const timeout = 5 * time.Second
+	timeout := ssh.HostTimeouts(job.Host).Sync
 	metadataPattern := session.MetadataFilePattern(job.ID)
 	cmd := fmt.Sprintf("cat %s 2>/dev/null", metadataPattern)
-	stdout, _, err := ssh.RunWithTimeout(job.Host, cmd, timeout)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), cmd, timeout)
 	if err != nil || strings.TrimSpace(stdout) == "" {
 		return // No metadata file or couldn't read it
 	}
@@ -202,13 +276,13 @@ func updateStartTimeFromMetadata(database *sql.DB, job *db.Job) {
 
 // syncQueueRunnerJob checks and updates a queue runner job's status using pattern-based file lookup
 func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
-	const timeout = 5 * time.Second
+	timeout := ssh.HostTimeouts(job.Host).Sync
 
 	// Check if status file exists (job completed) using glob pattern
 	// Queue runner creates files with its own timestamp, not the database start_time
 	statusPattern := session.StatusFilePattern(job.ID)
 	cmd := fmt.Sprintf("cat %s 2>/dev/null | head -1", statusPattern)
-	stdout, _, err := ssh.RunWithTimeout(job.Host, cmd, timeout)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), cmd, timeout)
 	if err != nil {
 		return false, err
 	}
@@ -235,7 +309,7 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 	currentFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.current", queueName)
 	// Use || true to avoid exit code 1 when file doesn't exist
 	currentCmd := fmt.Sprintf("cat %s 2>/dev/null || true", currentFile)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, currentCmd, timeout)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), currentCmd, timeout)
 	if err != nil {
 		return false, err
 	}
@@ -250,7 +324,7 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 	// Check if job is still in the queue file (waiting to run)
 	queueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
 	grepCmd := fmt.Sprintf("grep -q '^%d	' %s 2>/dev/null && echo yes || echo no", job.ID, queueFile)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, grepCmd, timeout)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), grepCmd, timeout)
 	if err != nil {
 		return false, err
 	}
@@ -261,26 +335,29 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 
 	// Check if the job's process is still running (via PID file)
 	pidPattern := session.PidFilePattern(job.ID)
-	pidCmd := fmt.Sprintf("pid=$(cat %s 2>/dev/null); [ -n \"$pid\" ] && ps -p $pid > /dev/null 2>&1 && echo running || echo not_running", pidPattern)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, pidCmd, timeout)
+	pidCmd := fmt.Sprintf("pid=$(cat %s 2>/dev/null); [ -n \"$pid\" ] && ps -p $pid > /dev/null 2>&1 && echo running || echo not_running $pid", pidPattern)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), pidCmd, timeout)
 	if err != nil {
 		return false, err
 	}
-	if strings.TrimSpace(stdout) == "running" {
+	pidState := strings.TrimSpace(stdout)
+	if pidState == "running" {
 		// Process is still running, don't mark as dead
 		return false, nil
 	}
 
 	// Job is not current, not in queue, process not running, and has no status file - it's dead
 	// (Either it died mid-execution, or was removed from queue)
-	if err := db.MarkDeadByID(database, job.ID); err != nil {
-		return false, err
-	}
-	return true, nil
+	pid := strings.TrimSpace(strings.TrimPrefix(pidState, "not_running"))
+	return jobstate.MarkDeadAfterGrace(database, job, "sync", jobstate.QueueRunnerDeadEvidence(job, queueName, pid))
 }
 
-// executeDeferredOperations executes pending operations for a host
+// executeDeferredOperations executes pending operations for a host, except
+// in read-only mode, which leaves them pending
 func executeDeferredOperations(database *sql.DB, host string) error {
+	if readOnly {
+		return nil
+	}
 	ops, err := db.GetDeferredOperations(database, host)
 	if err != nil {
 		return fmt.Errorf("get deferred operations: %w", err)
@@ -298,11 +375,15 @@ func executeDeferredOperations(database *sql.DB, host string) error {
 		var err error
 		switch op.Operation {
 		case db.OpKillJob:
-			err = executeDeferredKill(host, op)
+			err = executeDeferredKill(database, host, op)
 		case db.OpRemoveQueued:
 			err = executeDeferredRemoveQueued(host, op)
 		case db.OpMoveFromQueue:
 			err = executeDeferredMoveFrom(host, op)
+		case db.OpDeleteFiles:
+			err = executeDeferredDeleteFiles(host, op)
+		case db.OpReleaseDependency:
+			err = executeDeferredRelease(database, host, op)
 		default:
 			err = fmt.Errorf("unknown operation: %s", op.Operation)
 		}
@@ -330,34 +411,89 @@ func executeDeferredOperations(database *sql.DB, host string) error {
 	return nil
 }
 
-// executeDeferredKill kills a job's tmux session
-func executeDeferredKill(host string, op *db.DeferredOperation) error {
+// executeDeferredKill kills a job's tmux session, or its process tree if it
+// runs without tmux
+func executeDeferredKill(database *sql.DB, host string, op *db.DeferredOperation) error {
+	if jobstate.RunsWithoutTmux(database, op.JobID) {
+		_, _, err := ssh.Run(host, session.SignalJobCommand(op.JobID, "TERM"))
+		return err
+	}
 	tmuxSession := session.TmuxSessionName(op.JobID)
 	return ssh.TmuxKillSession(host, tmuxSession)
 }
 
 // executeDeferredRemoveQueued removes a job from the queue file
 func executeDeferredRemoveQueued(host string, op *db.DeferredOperation) error {
-	queueName := op.QueueName
-	if queueName == "" {
-		queueName = "default"
-	}
-	queueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
-	removeCmd := fmt.Sprintf("sed -i '/^%d\t/d' %s 2>/dev/null || true", op.JobID, queueFile)
-	_, _, err := ssh.Run(host, removeCmd)
+	_, _, err := ssh.Run(host, session.RemoveFromQueueCommand(op.JobID, op.QueueName))
 	return err
 }
 
 // executeDeferredMoveFrom removes a job from the old host's queue file (for job move)
 func executeDeferredMoveFrom(host string, op *db.DeferredOperation) error {
+	_, _, err := ssh.Run(host, session.RemoveFromQueueCommand(op.JobID, op.QueueName))
+	return err
+}
+
+// executeDeferredDeleteFiles deletes a pruned job's files. The job's start
+// time is gone with its database row, so its files are matched by job ID.
+func executeDeferredDeleteFiles(host string, op *db.DeferredOperation) error {
+	// Note: patterns not quoted to allow tilde and glob expansion
+	deleteCmd := fmt.Sprintf("rm -f %s %s %s %s 2>/dev/null",
+		session.LogFilePattern(op.JobID), session.StatusFilePattern(op.JobID),
+		session.MetadataFilePattern(op.JobID), session.PidFilePattern(op.JobID))
+	_, _, err := ssh.Run(host, deleteCmd)
+	return err
+}
+
+// executeDeferredRelease clears a queued job's dependency in the queue file.
+// The job may have started or left the queue since, so a missing line isn't
+// an error, unless the job is still queued, when a runner has it out of the
+// queue for the moment.
+func executeDeferredRelease(database *sql.DB, host string, op *db.DeferredOperation) error {
 	queueName := op.QueueName
 	if queueName == "" {
 		queueName = "default"
 	}
-	queueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
-	removeCmd := fmt.Sprintf("sed -i '/^%d\t/d' %s 2>/dev/null || true", op.JobID, queueFile)
-	_, _, err := ssh.Run(host, removeCmd)
-	return err
+	stdout, stderr, err := ssh.Run(host, releaseDependencyCommand(queueName, op.JobID))
+	if err != nil && ssh.IsConnectionError(stderr) {
+		return err
+	}
+	if err == nil && strings.TrimSpace(stdout) == "missing" {
+		if job, _ := db.GetJobByID(database, op.JobID); job != nil && job.Status == db.StatusQueued {
+			return fmt.Errorf("job %d isn't in queue '%s'; will try again on the next sync", op.JobID, queueName)
+		}
+	}
+	return nil
+}
+
+// configureTimeouts sets the timeouts of hosts' commands from the config
+func configureTimeouts(cfg *config.Config) {
+	hosts := make(map[string]ssh.Timeouts, len(cfg.Hosts))
+	for name, h := range cfg.Hosts {
+		hosts[name] = sshTimeouts(h.Timeouts)
+	}
+	ssh.SetTimeouts(sshTimeouts(cfg.Timeouts), hosts)
+}
+
+// configureUsers sets the accounts that hosts are logged in to as from the
+// config's per-host settings
+func configureUsers(cfg *config.Config) {
+	users := make(map[string]string, len(cfg.Hosts))
+	for name, h := range cfg.Hosts {
+		users[name] = h.User
+	}
+	ssh.SetUsers(users)
+}
+
+// sshTimeouts converts configured timeouts, in seconds, to durations
+func sshTimeouts(t config.Timeouts) ssh.Timeouts {
+	seconds := func(n int) time.Duration { return time.Duration(max(n, 0)) * time.Second }
+	return ssh.Timeouts{
+		Sync:     seconds(t.Sync),
+		FastSync: seconds(t.FastSync),
+		Probe:    seconds(t.Probe),
+		Connect:  seconds(t.Connect),
+	}
 }
 
 // performFastSync performs a quick sync with fast timeout for list/status commands
@@ -368,26 +504,36 @@ func performFastSync(database *sql.DB, verbose bool) bool {
 		return true
 	}
 
-	// Set fast timeout for SSH operations
-	// We'll use goroutines with a timeout context
+	// Set fast timeout for SSH operations, stretched for hosts whose round
+	// trips are slow. We'll use goroutines with a timeout context
+	reachability.LoadLatencies(database)
 	allCompleted := true
 	for _, host := range hosts {
+		// Don't wait on hosts that failed recently
+		if reachability.Skip(database, host, time.Now()) {
+			continue
+		}
+
 		// Try quick sync, but don't wait if it times out
 		done := make(chan bool, 1)
 		go func(h string) {
-			_, err := syncHostWithTimeout(database, h, FastSyncTimeout)
+			_, err := syncHostWithTimeout(database, h, ssh.HostTimeouts(h).FastSync)
+			reachability.Record(database, h, err, time.Now())
 			done <- (err == nil)
 		}(host)
 
 		select {
 		case <-done:
 			// Sync completed
-		case <-time.After(FastSyncTimeout):
+		case <-time.After(ssh.HostTimeouts(host).FastSync):
 			// Timed out
 			allCompleted = false
 		}
 	}
 
+	runCompletionHooks(database)
+	deliverWebhooks(database)
+
 	return allCompleted
 }
 
@@ -399,7 +545,9 @@ func syncHostWithTimeout(database *sql.DB, host string, timeout time.Duration) (
 		return 0, err
 	}
 
+	started := time.Now().Unix()
 	var updated int
+	checked := make([]int64, 0, len(jobs))
 	for _, job := range jobs {
 		// Use quick check with timeout
 		changed, err := syncJobQuick(database, job, timeout)
@@ -409,20 +557,26 @@ func syncHostWithTimeout(database *sql.DB, host string, timeout time.Duration) (
 		if changed {
 			updated++
 		}
+		checked = append(checked, job.ID)
 	}
 
-	return updated, nil
+	// Jobs that looked dead before but not now are alive after all
+	return updated, db.ClearDeadProbes(database, started, checked)
 }
 
 // syncJobQuick is a quick version of syncJob with timeout
 func syncJobQuick(database *sql.DB, job *db.Job, timeout time.Duration) (bool, error) {
+	if job.Status == db.StatusPaused {
+		return jobstate.SyncPaused(database, job, "sync", timeout)
+	}
+
 	if job.SessionName == "" {
 		// Queue runner job - use optimized check
 		return syncQueueRunnerJobQuick(database, job, timeout)
 	}
 
 	tmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-	exists, err := ssh.TmuxSessionExistsQuick(job.Host, tmuxSession)
+	exists, err := ssh.TmuxSessionExistsQuick(jobHost(job), tmuxSession)
 	if err != nil {
 		return false, err
 	}
@@ -433,25 +587,22 @@ func syncJobQuick(database *sql.DB, job *db.Job, timeout time.Duration) (bool, e
 
 	// Session doesn't exist - check for status file
 	statusFile := session.JobStatusFile(job.ID, job.StartTime, job.SessionName)
-	content, err := ssh.ReadRemoteFileQuick(job.Host, statusFile)
+	content, mtime, err := ssh.ReadStatusFile(jobHost(job), statusFile, timeout)
 	if err != nil {
 		return false, err
 	}
 
 	if content != "" {
 		exitCode, _ := strconv.Atoi(content)
-		endTime := time.Now().Unix()
+		endTime := clockskew.EndTime(database, job, mtime, time.Now())
 		if err := db.RecordCompletionByID(database, job.ID, exitCode, endTime); err != nil {
 			return false, err
 		}
 		return true, nil
 	}
 
-	// No status file - mark as dead
-	if err := db.MarkDeadByID(database, job.ID); err != nil {
-		return false, err
-	}
-	return true, nil
+	// No status file - mark as dead if it stays that way
+	return jobstate.MarkDeadAfterGrace(database, job, "sync", jobstate.SessionDeadEvidence(tmuxSession, statusFile))
 }
 
 // syncQueueRunnerJobQuick is an optimized version for queue runner jobs that combines
@@ -484,32 +635,30 @@ func syncQueueRunnerJobQuick(database *sql.DB, job *db.Job, timeout time.Duratio
 		elif pid=$(cat %s 2>/dev/null) && [ -n "$pid" ] && ps -p $pid > /dev/null 2>&1; then
 			echo RUNNING
 		else
-			echo DEAD
+			echo DEAD $pid
 		fi
 	`, statusPattern, statusPattern,
 		currentFile, currentFile, job.ID,
 		job.ID, queueFile,
 		pidPattern)
 
-	stdout, _, err := ssh.RunWithTimeout(job.Host, combinedCmd, timeout)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), combinedCmd, timeout)
 	if err != nil {
 		// Connection error - don't update status
-		return false, nil
+		return false, err
 	}
 
 	result := strings.TrimSpace(stdout)
+	if pid, dead := session.DeadState(result); dead {
+		// Job has died unexpectedly, or the host had a hiccup
+		return jobstate.MarkDeadAfterGrace(database, job, "sync", jobstate.QueueRunnerDeadEvidence(job, queueName, pid))
+	}
 
 	// Parse result and update database
 	switch result {
 	case "RUNNING", "QUEUED":
 		// Job is still active, no change needed
 		return false, nil
-	case "DEAD":
-		// Job has died unexpectedly
-		if err := db.MarkDeadByID(database, job.ID); err != nil {
-			return false, err
-		}
-		return true, nil
 	case "":
 		// Empty result (shouldn't happen with our logic, but handle gracefully)
 		return false, nil
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// saveJobTags records the tags for a newly created job
+func saveJobTags(database *sql.DB, jobID int64, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if err := db.SetJobTags(database, jobID, tags); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save tags for job %d: %v\n", jobID, err)
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/taskrunner"
+	"github.com/spf13/cobra"
+)
+
+// resolveTaskCommand finds the local Makefile or justfile for target and
+// returns the command that runs it and the directory on host to run it in
+func resolveTaskCommand(runner taskrunner.Runner, host, target, args string) (command, dir string, err error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+	path, err := runner.Find(cwd)
+	if err != nil {
+		return "", "", err
+	}
+	if targets, err := runner.Targets(path); err != nil {
+		return "", "", err
+	} else if !slices.Contains(targets, target) {
+		fmt.Fprintf(os.Stderr, "Warning: %s not found in %s (found: %s)\n", target, path, strings.Join(targets, ", "))
+	}
+	dir, err = remoteDirOn(host, filepath.Dir(path))
+	if err != nil {
+		return "", "", err
+	}
+	return runner.Command(target, args), dir, nil
+}
+
+// completeTargets returns a flag completion function that offers the targets
+// in the local Makefile or justfile
+func completeTargets(runner taskrunner.Runner) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		path, err := runner.Find(cwd)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		targets, err := runner.Targets(path)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var matches []string
+		for _, t := range targets {
+			if strings.HasPrefix(t, toComplete) {
+				matches = append(matches, t)
+			}
+		}
+		return matches, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/osteele/remote-jobs/internal/timefmt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	timeZoneFlag  string
+	timeStyleFlag string
+
+	// displayTimes is how times are shown, from the time_display config and
+	// the --time-zone and --time-style flags. An empty style means each view
+	// uses its own default.
+	displayTimes timefmt.Options
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&timeZoneFlag, "time-zone", "", "Show times in local, utc, or host (the job's host) time")
+	rootCmd.PersistentFlags().StringVar(&timeStyleFlag, "time-style", "", "Show times as auto, absolute, or relative")
+	rootCmd.PersistentPreRunE = loadSettings
+}
+
+// loadSettings sets displayTimes, how sizes and numbers are formatted, the
+// timeouts of hosts' commands, when jobs are marked dead, and read-only mode
+// before any command runs
+func loadSettings(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load config, using default time display: %v\n", err)
+		cfg = &config.Config{}
+	} else if err := cfg.TimeDisplay.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: time_display: %v\n", err)
+	} else {
+		displayTimes = cfg.TimeDisplay
+	}
+
+	if err := cfg.Formatting.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: formatting: %v\n", err)
+		cfg.Formatting = humanfmt.Options{Locale: cfg.Formatting.Locale}
+	}
+	humanfmt.Set(cfg.Formatting)
+	configureTimeouts(cfg)
+	configureUsers(cfg)
+	jobstate.SetConfig(cfg)
+	if db.Encrypted() {
+		resealOnSignal()
+	}
+	if err := checkReadOnly(cmd, args, cfg); err != nil {
+		return err
+	}
+
+	if timeZoneFlag != "" {
+		displayTimes.Zone = timeZoneFlag
+	}
+	if timeStyleFlag != "" {
+		displayTimes.Style = timeStyleFlag
+	}
+	return displayTimes.Validate()
+}
+
+// jobHostZone returns the time zone of a job's host as recorded when the job
+// started, or nil if it wasn't recorded
+func jobHostZone(database *sql.DB, jobID int64) *time.Location {
+	offset, err := db.JobUTCOffset(database, jobID)
+	if err != nil {
+		return nil
+	}
+	return timefmt.HostZone(offset)
+}
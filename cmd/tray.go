@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/spf13/cobra"
+)
+
+var trayCmd = &cobra.Command{
+	Use:   "tray",
+	Short: "Print job status for a menu-bar plugin",
+	Long: `Print a summary of running, queued, and recently failed jobs in the
+plugin format used by xbar and SwiftBar (macOS) and Argos (GNOME), so job
+status shows in the menu bar without a terminal.
+
+The menu-bar title shows counts of running and failed jobs. The menu lists
+each job; clicking one opens its log in a terminal. Failed jobs that have been
+acknowledged with 'remote-jobs ack' are listed in gray and not counted. It also has items to open
+the TUI and to refresh.
+
+To install, save a plugin script in the plugin folder that calls this
+command. The refresh interval goes in the file name, e.g.
+remote-jobs.1m.sh:
+
+  #!/bin/sh
+  exec remote-jobs tray --sync
+
+Examples:
+  remote-jobs tray               # Status from the local database
+  remote-jobs tray --sync        # Quick sync with remote hosts first
+  remote-jobs tray --since 6h    # Only show failures from the last 6 hours`,
+	RunE: runTray,
+}
+
+var (
+	traySync  bool
+	traySince string
+)
+
+func init() {
+	rootCmd.AddCommand(trayCmd)
+	trayCmd.Flags().BoolVar(&traySync, "sync", false, "Quickly sync job statuses from remote hosts first")
+	trayCmd.Flags().StringVar(&traySince, "since", "24h", "Show jobs that failed within this duration")
+}
+
+func runTray(cmd *cobra.Command, args []string) error {
+	since, err := parseDuration(traySince)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w (examples: 6h, 1d)", traySince, err)
+	}
+
+	database, err := db.Open()
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer database.Close()
+	defer waitForDeliveries()
+
+	if traySync {
+		performFastSync(database, false)
+	}
+
+	running, err := db.ListAllRunning(database)
+	if err != nil {
+		return fmt.Errorf("list running jobs: %w", err)
+	}
+	queued, err := db.ListAllQueued(database)
+	if err != nil {
+		return fmt.Errorf("list queued jobs: %w", err)
+	}
+	recent, err := db.ListJobsSince(database, time.Now().Add(-since))
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	var failed, acked []*db.Job
+	var failedIDs []int64
+	for _, job := range recent {
+		if isFailedJob(job) {
+			failedIDs = append(failedIDs, job.ID)
+		}
+	}
+	stats, err := db.LoadJobStats(database, failedIDs)
+	if err != nil {
+		return fmt.Errorf("load job stats: %w", err)
+	}
+	for _, job := range recent {
+		switch {
+		case !isFailedJob(job):
+		case stats[job.ID].Acked(job):
+			acked = append(acked, job)
+		default:
+			failed = append(failed, job)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "remote-jobs"
+	}
+
+	// Menu-bar title
+	title := fmt.Sprintf("▶ %d", len(running))
+	if len(failed) > 0 {
+		title += fmt.Sprintf(" ✗ %d", len(failed))
+	}
+	fmt.Println(title)
+	fmt.Println("---")
+
+	now := time.Now().Unix()
+	printTraySection(exe, "Running", running, "", func(job *db.Job) string {
+		return humanfmt.DurationShort(job.Elapsed(now))
+	})
+	printTraySection(exe, "Queued", queued, "", func(job *db.Job) string {
+		return job.QueueName
+	})
+	failure := func(job *db.Job) string {
+		if job.ExitCode != nil {
+			return fmt.Sprintf("exit %d", *job.ExitCode)
+		}
+		return string(job.Status)
+	}
+	printTraySection(exe, "Failed (last "+traySince+")", failed, "", failure)
+	printTraySection(exe, "Acknowledged", acked, "gray", failure)
+
+	fmt.Printf("Open TUI | %s\n", trayAction(exe, "tui"))
+	fmt.Println("Refresh | refresh=true")
+	return nil
+}
+
+// isFailedJob reports whether a job ended unsuccessfully
+func isFailedJob(job *db.Job) bool {
+	switch job.Status {
+	case db.StatusDead, db.StatusFailed:
+		return true
+	case db.StatusCompleted:
+		return job.ExitCode != nil && *job.ExitCode != 0 && !job.Skipped()
+	}
+	return false
+}
+
+// printTraySection prints a header and one clickable menu item per job, in
+// color if one is given
+func printTraySection(exe, header string, jobs []*db.Job, color string, detail func(*db.Job) string) {
+	if len(jobs) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d)\n", header, len(jobs))
+	for _, job := range jobs {
+		label := job.Description
+		if label == "" {
+			label = displayCommand(job)
+		}
+		item := fmt.Sprintf("#%d %s: %s", job.ID, job.Host, truncate(label, 50))
+		if d := detail(job); d != "" {
+			item += " (" + d + ")"
+		}
+		params := trayAction(exe, "log", fmt.Sprint(job.ID))
+		if color != "" {
+			params += " color=" + color
+		}
+		fmt.Printf("%s | %s\n", trayText(item), params)
+	}
+	fmt.Println("---")
+}
+
+// trayAction returns plugin parameters that run exe with args in a terminal
+func trayAction(exe string, args ...string) string {
+	params := []string{"bash=" + trayParam(exe)}
+	for i, arg := range args {
+		params = append(params, fmt.Sprintf("param%d=%s", i+1, trayParam(arg)))
+	}
+	return strings.Join(append(params, "terminal=true"), " ")
+}
+
+// trayText removes characters the plugin format uses as separators
+func trayText(s string) string {
+	return strings.NewReplacer("|", "¦", "\n", " ").Replace(s)
+}
+
+// trayParam quotes a parameter value that contains spaces
+func trayParam(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+	return s
+}
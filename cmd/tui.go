@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -28,16 +29,25 @@ Keyboard shortcuts:
   k/Delete   Kill highlighted job
   p          Prune completed/dead jobs
   Ctrl-C/q   Quit
-  Ctrl-Z     Suspend (resume with 'fg')`,
+  Ctrl-Z     Suspend (resume with 'fg')
+
+With --plain, the TUI prints lines instead of drawing panels, for screen
+readers or for logging a session: it lists recent jobs, announces each status
+change as a sentence when the background sync finds it, and reads commands
+(list, show, log, sync, help, quit) from standard input.`,
 	RunE: runTUI,
 }
 
 func init() {
 	rootCmd.AddCommand(tuiCmd)
 	tuiCmd.Flags().BoolVar(&tuiMouse, "mouse", false, "Enable mouse support (disables terminal selection)")
+	tuiCmd.Flags().BoolVar(&tuiPlain, "plain", false, "Print plain lines and announce status changes instead of drawing panels")
 }
 
-var tuiMouse bool
+var (
+	tuiMouse bool
+	tuiPlain bool
+)
 
 func runTUI(cmd *cobra.Command, args []string) error {
 	// Load config
@@ -51,6 +61,7 @@ func runTUI(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("open database: %w", err)
 	}
 	defer database.Close()
+	defer waitForDeliveries()
 
 	// Build TUI options from config
 	opts := tui.DefaultModelOptions()
@@ -64,6 +75,25 @@ func runTUI(cmd *cobra.Command, args []string) error {
 		opts.HostRefreshInterval = time.Duration(cfg.HostRefreshInterval) * time.Second
 	}
 
+	if tuiPlain {
+		return runPlainTUI(database, opts.SyncInterval)
+	}
+
+	opts.Watchdog = cfg.Watchdog
+	opts.TimeDisplay = displayTimes
+	opts.PrunePolicy = cfg.Prune
+	opts.OpenDir = cfg.OpenDirTemplate
+	opts.PathMappings = cfg.HostPathMappings
+	opts.Availability = cfg.HostAvailability
+	opts.Redactor = redactor()
+	opts.JobSnapshot = tui.DefaultJobSnapshotPath(db.Path())
+	if db.Encrypted() && opts.JobSnapshot != "" {
+		// A snapshot would be an unencrypted copy of the job list
+		os.Remove(opts.JobSnapshot)
+		opts.JobSnapshot = ""
+	}
+	opts.ReadOnly = readOnly
+
 	model := tui.NewModelWithOptions(database, opts)
 
 	useMouse := cfg.EnableMouse
@@ -78,6 +108,8 @@ func runTUI(cmd *cobra.Command, args []string) error {
 
 	p := tea.NewProgram(model, programOpts...)
 
+	// Bubble Tea handles interrupts, restoring the terminal before it quits
+	defer handlingSignals()()
 	_, err = p.Run()
 	if err != nil {
 		return fmt.Errorf("run TUI: %w", err)
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/secrets"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// plainJobLimit is how many recent jobs plain mode watches, as the TUI does
+const plainJobLimit = 100
+
+const plainHelp = `Commands:
+  list [N]      List the N most recent jobs (default 10)
+  show ID       Describe a job
+  log ID [N]    Print the last N lines of a job's log (default 20)
+  sync          Sync job statuses now
+  help          Show this help
+  quit          Exit`
+
+// runPlainTUI is the TUI's --plain mode, for screen readers and for logging
+// a session: it prints lines rather than drawing panels, announces each
+// change to a job's status as a sentence when a sync finds it, and reads
+// commands from standard input. If standard input is closed, it keeps
+// announcing changes until interrupted.
+func runPlainTUI(database *sql.DB, interval time.Duration) error {
+	jobs, err := db.ListJobs(database, "", "", plainJobLimit)
+	if err != nil {
+		return fmt.Errorf("list jobs: %w", err)
+	}
+	fmt.Println("remote-jobs plain mode. Status changes are announced as they are found. Type help for commands.")
+	printPlainJobs(jobs, heldJobIDs(database), 10)
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jobs = plainSync(database, jobs)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "quit", "exit", "q":
+				fmt.Println("Goodbye.")
+				return nil
+			case "sync":
+				fmt.Println("Syncing.")
+				jobs = plainSync(database, jobs)
+				fmt.Println("Sync finished.")
+			default:
+				if err := runPlainCommand(database, jobs, fields); err != nil {
+					fmt.Printf("Error: %v\n", err)
+				}
+			}
+		case <-ticker.C:
+			jobs = plainSync(database, jobs)
+		}
+	}
+}
+
+// runPlainCommand runs one of plain mode's commands that only reads
+func runPlainCommand(database *sql.DB, jobs []*db.Job, fields []string) error {
+	cmd, args := fields[0], fields[1:]
+	switch cmd {
+	case "help", "?":
+		fmt.Println(plainHelp)
+	case "list", "ls":
+		n := 10
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return fmt.Errorf("invalid count: %s", args[0])
+			}
+		}
+		printPlainJobs(jobs, heldJobIDs(database), n)
+	case "show", "log":
+		if len(args) == 0 {
+			return fmt.Errorf("%s needs a job ID", cmd)
+		}
+		jobID, err := jobSelector{}.resolve(database, strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			return err
+		}
+		if cmd == "show" {
+			return showJob(database, jobID)
+		}
+		n := 20
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid line count: %s", args[1])
+			}
+		}
+		return printPlainLog(database, jobID, n)
+	default:
+		return fmt.Errorf("unknown command %q; type help for commands", cmd)
+	}
+	return nil
+}
+
+// plainSync syncs job statuses, announces what changed since jobs was
+// loaded, and returns the jobs as they are now
+func plainSync(database *sql.DB, jobs []*db.Job) []*db.Job {
+	performFastSync(database, false)
+	current, err := db.ListJobs(database, "", "", plainJobLimit)
+	if err != nil {
+		fmt.Printf("Error: list jobs: %v\n", err)
+		return jobs
+	}
+	for _, line := range plainJobChanges(jobs, current, heldJobIDs(database), time.Now().Unix()) {
+		fmt.Println(line)
+	}
+	return current
+}
+
+// heldJobIDs returns the set of pending jobs that are held locally until a
+// job they depend on finishes, rather than waiting to be retried
+func heldJobIDs(database *sql.DB) map[int64]bool {
+	held := make(map[int64]bool)
+	deps, _ := db.ListPendingDependencies(database)
+	for _, dep := range deps {
+		held[dep.JobID] = true
+	}
+	return held
+}
+
+// plainJobChanges returns a sentence for each job in current that is new or
+// whose status has changed since prev, oldest job first. held is the set of
+// held jobs (see heldJobIDs).
+func plainJobChanges(prev, current []*db.Job, held map[int64]bool, now int64) []string {
+	before := make(map[int64]*db.Job, len(prev))
+	for _, job := range prev {
+		before[job.ID] = job
+	}
+	var changes []string
+	// Jobs are listed newest first
+	for i := len(current) - 1; i >= 0; i-- {
+		job := current[i]
+		old, ok := before[job.ID]
+		switch {
+		case !ok:
+			changes = append(changes, "New: "+plainJobLine(job, held[job.ID], now))
+		case old.Status != job.Status || !sameExitCode(old.ExitCode, job.ExitCode):
+			changes = append(changes, plainJobLine(job, held[job.ID], now))
+		}
+	}
+	return changes
+}
+
+func sameExitCode(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// printPlainJobs prints a summary of jobs and a line for each of the n most
+// recent. held is the set of held jobs (see heldJobIDs).
+func printPlainJobs(jobs []*db.Job, held map[int64]bool, n int) {
+	if len(jobs) == 0 {
+		fmt.Println("No jobs.")
+		return
+	}
+	counts := make(map[db.Status]int)
+	for _, job := range jobs {
+		counts[job.Status]++
+	}
+	var parts []string
+	for _, status := range []db.Status{db.StatusRunning, db.StatusStarting, db.StatusQueued, db.StatusPending, db.StatusPaused} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	summary := fmt.Sprintf("%d recent jobs", len(jobs))
+	if len(parts) > 0 {
+		summary += ": " + strings.Join(parts, ", ")
+	}
+	fmt.Println(summary + ".")
+
+	now := time.Now().Unix()
+	for i, job := range jobs {
+		if i == n {
+			fmt.Printf("%d more not listed.\n", len(jobs)-n)
+			break
+		}
+		fmt.Println(plainJobLine(job, held[job.ID], now))
+	}
+}
+
+// plainJobLine describes a job's status as a sentence, such as "Job 52 on
+// deepthought failed with exit code 1 after 2h 3m: python train.py". held is
+// whether a pending job is held until a job it depends on finishes.
+func plainJobLine(job *db.Job, held bool, now int64) string {
+	label := job.Description
+	if label == "" {
+		label = displayCommand(job)
+	}
+	return fmt.Sprintf("Job %d on %s %s: %s", job.ID, job.Host, plainStatus(job, held, now), truncate(label, 80))
+}
+
+// plainStatus describes a job's status as a verb phrase. held is whether a
+// pending job is held until a job it depends on finishes; otherwise it
+// couldn't start, and waits to be retried.
+func plainStatus(job *db.Job, held bool, now int64) string {
+	var status string
+	switch job.Status {
+	case db.StatusCompleted:
+		switch {
+		case job.ExitCode == nil:
+			status = "completed"
+		case *job.ExitCode == 0:
+			status = "completed successfully"
+		case job.Skipped():
+			status = "was skipped: its --if condition was false"
+		default:
+			status = fmt.Sprintf("failed with exit code %d", *job.ExitCode)
+		}
+	case db.StatusDead:
+		status = "died without recording an exit code"
+	case db.StatusFailed:
+		status = "failed to start"
+		if job.ErrorMessage != "" {
+			status += " (" + job.ErrorMessage + ")"
+		}
+	case db.StatusPending:
+		if held {
+			status = "is pending, held locally until a job it depends on finishes"
+		} else {
+			status = "is pending: it couldn't start, and waits for remote-jobs retry"
+		}
+	default:
+		status = "is " + string(job.Status)
+	}
+	if elapsed := job.Elapsed(now); elapsed >= 0 {
+		if job.Status.Terminal() {
+			status += " after " + humanfmt.Duration(elapsed)
+		} else {
+			status += " for " + humanfmt.Duration(elapsed)
+		}
+	}
+	return status
+}
+
+// printPlainLog prints the last n lines of a job's log between lines that
+// announce where it starts and ends
+func printPlainLog(database *sql.DB, jobID int64, n int) error {
+	job, err := db.GetJobByID(database, jobID)
+	if err != nil {
+		return fmt.Errorf("get job: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("job %d not found", jobID)
+	}
+	stdout, stderr, err := ssh.Run(jobHost(job), logTailCommand(job, n))
+	if err != nil {
+		if stderr != "" {
+			return fmt.Errorf("read log: %s", strings.TrimSpace(stderr))
+		}
+		return fmt.Errorf("read log: %w", err)
+	}
+	stdout = strings.TrimRight(redactText(secrets.Redact(stdout, jobSecretValues(database, jobID))), "\n")
+	if stdout == "" {
+		fmt.Printf("Job %d's log is empty.\n", jobID)
+		return nil
+	}
+	fmt.Printf("Last %d lines of job %d's log:\n%s\nEnd of log.\n", n, jobID, stdout)
+	return nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestPlainStatus(t *testing.T) {
+	exit1 := 1
+	end := int64(1000 + 90)
+	tests := []struct {
+		job  db.Job
+		held bool
+		want string
+	}{
+		{db.Job{Status: db.StatusPending}, true, "is pending, held locally until a job it depends on finishes"},
+		{db.Job{Status: db.StatusPending}, false, "is pending: it couldn't start, and waits for remote-jobs retry"},
+		{db.Job{Status: db.StatusRunning, StartTime: 1000}, false, "is running for "},
+		{db.Job{Status: db.StatusCompleted, StartTime: 1000, EndTime: &end, ExitCode: &exit1}, false, "failed with exit code 1 after "},
+	}
+	for _, tt := range tests {
+		if got := plainStatus(&tt.job, tt.held, 1060); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("plainStatus(%s, held=%v) = %q, want it to start with %q", tt.job.Status, tt.held, got, tt.want)
+		}
+	}
+}
+
+func TestHeldJobIDs(t *testing.T) {
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	held, err := db.RecordHeld(database, "cool30", "~/code", "make", "", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddPendingDependency(database, held, 99, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	retry, err := db.RecordJobStarting(database, "cool30", "~/code", "make", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateJobPending(database, retry); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := heldJobIDs(database)
+	if !ids[held] || ids[retry] || len(ids) != 1 {
+		t.Errorf("heldJobIDs() = %v, want only job %d", ids, held)
+	}
+}
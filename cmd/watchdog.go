@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/hooks"
+	"github.com/osteele/remote-jobs/internal/watchdog"
+)
+
+// loadWatchdog returns the idle-GPU watchdog settings, or a disabled watchdog
+// if the config can't be read. In read-only mode, its kill action only warns.
+func loadWatchdog() config.Watchdog {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load config, idle-GPU watchdog disabled: %v\n", err)
+		return config.Watchdog{}
+	}
+	return readOnlyWatchdog(cfg.Watchdog)
+}
+
+// runWatchdog samples GPU utilization on a host and applies the configured
+// action to jobs whose GPUs have just crossed the idle threshold
+func runWatchdog(database *sql.DB, host string, wd config.Watchdog) error {
+	action, err := wd.EffectiveAction()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	alerts, err := watchdog.Check(database, host, wd.IdleAfter(), now)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", alert.Message(now))
+		switch action {
+		case config.WatchdogNotify:
+			if wd.Command == "" {
+				fmt.Fprintln(os.Stderr, "Warning: watchdog action is notify but no watchdog command is configured")
+				continue
+			}
+			idle := "REMOTE_JOBS_IDLE_SECONDS=" + strconv.FormatInt(int64(alert.IdleFor(now).Seconds()), 10)
+			if err := hooks.Run(wd.Command, alert.Job, os.Stderr, idle); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: watchdog command for job %d failed: %v\n", alert.Job.ID, err)
+			}
+		case config.WatchdogKill:
+			if err := killJob(database, alert.Job.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to kill idle job %d: %v\n", alert.Job.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package artifacts resolves the output files a job declared with --artifact
+// into remote paths and sizes, so they can be listed and fetched after the
+// job finishes.
+package artifacts
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// ListCommand returns a command that prints each file matching globs, one
+// "size\tpath\tname" line per file: its size, its absolute path, and its name
+// (its path relative to dir, or its base name if it's outside dir). Relative
+// globs are matched in dir. Globs are left unquoted so the remote shell
+// expands them; patterns that match nothing are skipped.
+func ListCommand(dir string, globs []string) string {
+	return fmt.Sprintf(`cd %s 2>/dev/null || exit 0; for f in %s; do
+	[ -f "$f" ] || continue
+	case "$f" in /*) p="$f"; n="${f##*/}" ;; *) p="$PWD/$f"; n="$f" ;; esac
+	printf '%%s\t%%s\t%%s\n' "$(wc -c < "$f" | tr -d ' ')" "$p" "$n"
+done`, shellquote.Path(dir), strings.Join(globs, " "))
+}
+
+// LatestCommand returns a command that prints the most recently modified
+// file matching globs, such as a job's latest checkpoint, in the form
+// ListCommand prints, or nothing if none matches
+func LatestCommand(dir string, globs []string) string {
+	return fmt.Sprintf(`cd %s 2>/dev/null || exit 0; latest=; for f in %s; do
+	[ -f "$f" ] || continue
+	if [ -z "$latest" ] || [ "$f" -nt "$latest" ]; then latest="$f"; fi
+done
+[ -n "$latest" ] || exit 0
+f="$latest"
+case "$f" in /*) p="$f"; n="${f##*/}" ;; *) p="$PWD/$f"; n="$f" ;; esac
+printf '%%s\t%%s\t%%s\n' "$(wc -c < "$f" | tr -d ' ')" "$p" "$n"`, shellquote.Path(dir), strings.Join(globs, " "))
+}
+
+// ParseListing parses the output of ListCommand. Each path is listed once,
+// even if several globs matched it.
+func ParseListing(output string) []db.Artifact {
+	var artifacts []db.Artifact
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) != 3 || fields[1] == "" || seen[fields[1]] {
+			continue
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 64)
+		if err != nil {
+			continue
+		}
+		seen[fields[1]] = true
+		artifacts = append(artifacts, db.Artifact{Path: fields[1], Name: strings.TrimPrefix(fields[2], "./"), Size: size})
+	}
+	return artifacts
+}
+
+// Total returns the combined size of artifacts
+func Total(artifacts []db.Artifact) int64 {
+	var total int64
+	for _, a := range artifacts {
+		total += a.Size
+	}
+	return total
+}
+
+// LocalPath returns where fetching an artifact saves it under outDir: at its
+// name, or at its base name if its name would escape outDir
+func LocalPath(outDir string, a db.Artifact) string {
+	name := filepath.Clean(filepath.FromSlash(a.Name))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		name = path.Base(a.Path)
+	}
+	return filepath.Join(outDir, name)
+}
@@ -0,0 +1,102 @@
+package artifacts
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestListCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "checkpoints"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"checkpoints/a.pt": "12345",
+		"checkpoints/b.pt": "1",
+		"results.json":     "{}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := ListCommand(dir, []string{"checkpoints/*.pt", "results.json", "missing/*.pt", "checkpoints/a.pt"})
+	out, err := exec.Command("sh", "-c", cmd).Output()
+	if err != nil {
+		t.Fatalf("run list command: %v", err)
+	}
+	got := ParseListing(string(out))
+	want := []db.Artifact{
+		{Path: filepath.Join(dir, "checkpoints/a.pt"), Name: "checkpoints/a.pt", Size: 5},
+		{Path: filepath.Join(dir, "checkpoints/b.pt"), Name: "checkpoints/b.pt", Size: 1},
+		{Path: filepath.Join(dir, "results.json"), Name: "results.json", Size: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("artifacts = %+v, want %+v", got, want)
+	}
+}
+
+func TestLatestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "ckpt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i, name := range []string{"ckpt/epoch2.pt", "ckpt/epoch10.pt", "ckpt/epoch1.pt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("abc"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration([]int{2, 10, 1}[i]) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("sh", "-c", LatestCommand(dir, []string{"ckpt/*.pt", "missing/*.pt"})).Output()
+	if err != nil {
+		t.Fatalf("run latest command: %v", err)
+	}
+	got := ParseListing(string(out))
+	want := []db.Artifact{{Path: filepath.Join(dir, "ckpt/epoch10.pt"), Name: "ckpt/epoch10.pt", Size: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("latest = %+v, want %+v", got, want)
+	}
+
+	out, err = exec.Command("sh", "-c", LatestCommand(dir, []string{"missing/*.pt"})).Output()
+	if err != nil || len(out) != 0 {
+		t.Errorf("latest with no matches = %q, %v; want no output", out, err)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact db.Artifact
+		want     string
+	}{
+		{"relative", db.Artifact{Path: "/home/u/run/out/a.pt", Name: "out/a.pt"}, "dl/out/a.pt"},
+		{"outside", db.Artifact{Path: "/data/b.pt", Name: "b.pt"}, "dl/b.pt"},
+		{"escapes", db.Artifact{Path: "/home/u/c.pt", Name: "../c.pt"}, "dl/c.pt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LocalPath("dl", tt.artifact); got != tt.want {
+				t.Errorf("LocalPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
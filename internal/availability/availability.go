@@ -0,0 +1,285 @@
+// Package availability parses the windows during which a host may start
+// jobs, such as "20:00-08:00" for an office desktop that is only free
+// overnight, and says when a host next becomes available.
+//
+// A window is an optional list of days followed by an optional time range:
+//
+//	20:00-08:00          every day from 8pm to 8am the next morning
+//	mon-fri 20:00-08:00  weeknights (a window belongs to the day it starts)
+//	sat,sun              all weekend
+//	sat-sun 00:00-24:00  the same
+//
+// A host with no windows is always available.
+package availability
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// RunnerFilePath is where the queue runner script reads a host's windows
+const RunnerFilePath = "~/.cache/remote-jobs/queue/availability"
+
+// minutesPerDay is the end of a window that runs to midnight
+const minutesPerDay = 24 * 60
+
+// Window is a span of time, repeated on some days of the week, during which
+// a host may start jobs
+type Window struct {
+	Days  [7]bool // Days the window starts on, indexed by time.Weekday
+	Start int     // Minutes after midnight
+	End   int     // Minutes after midnight; at or before Start, the next day
+}
+
+// Schedule is the windows during which a host may start jobs, in a time zone
+type Schedule struct {
+	Windows  []Window
+	Location *time.Location
+	zone     string   // Location's name as configured, or "" for local time
+	specs    []string // Windows as configured
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// locations caches the time zones Parse has loaded, since schedules are
+// parsed each time the TUI draws the hosts view
+var locations sync.Map // Zone name to *time.Location
+
+// loadLocation returns the time zone with an IANA name, such as
+// "Europe/Paris"
+func loadLocation(zone string) (*time.Location, error) {
+	if loc, ok := locations.Load(zone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(zone, loc)
+	return loc, nil
+}
+
+// localZone returns the name of the local time zone, for the queue runner
+// to evaluate windows in local time the way this machine does: $TZ if it is
+// set, or else the IANA name of the zone /etc/localtime links to, or else a
+// POSIX zone with the current offset from UTC
+var localZone = sync.OnceValue(func() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !filepath.IsAbs(tz) {
+		return tz
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	_, offset := time.Now().Zone()
+	sign := "-" // POSIX offsets are west of UTC
+	if offset < 0 {
+		sign, offset = "+", -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/3600, offset/60%60)
+})
+
+// Parse parses windows in an IANA time zone such as "Europe/Paris", or in
+// local time if zone is empty
+func Parse(specs []string, zone string) (Schedule, error) {
+	s := Schedule{Location: time.Local, zone: zone}
+	if zone != "" {
+		loc, err := loadLocation(zone)
+		if err != nil {
+			return s, fmt.Errorf("invalid time zone %q: %w", zone, err)
+		}
+		s.Location = loc
+	}
+	for _, spec := range specs {
+		w, err := parseWindow(spec)
+		if err != nil {
+			return s, err
+		}
+		s.Windows = append(s.Windows, w)
+		s.specs = append(s.specs, strings.TrimSpace(spec))
+	}
+	return s, nil
+}
+
+func parseWindow(spec string) (Window, error) {
+	w := Window{Start: 0, End: minutesPerDay}
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("invalid availability window %q (expected [DAYS] [HH:MM-HH:MM])", spec)
+	}
+
+	times := fields[len(fields)-1]
+	if strings.Contains(times, ":") {
+		fields = fields[:len(fields)-1]
+		start, end, ok := strings.Cut(times, "-")
+		var err error
+		if !ok {
+			return w, fmt.Errorf("invalid time range %q in %q (expected HH:MM-HH:MM)", times, spec)
+		}
+		if w.Start, err = parseClock(start); err != nil {
+			return w, fmt.Errorf("%w in %q", err, spec)
+		}
+		if w.End, err = parseClock(end); err != nil {
+			return w, fmt.Errorf("%w in %q", err, spec)
+		}
+	}
+
+	if len(fields) == 0 {
+		for d := range w.Days {
+			w.Days[d] = true
+		}
+		return w, nil
+	}
+	for _, part := range strings.Split(fields[0], ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := dayNames[first]
+		if !ok {
+			return w, fmt.Errorf("unknown day %q in %q (expected mon, tue, ..., sun)", first, spec)
+		}
+		to := from
+		if isRange {
+			if to, ok = dayNames[last]; !ok {
+				return w, fmt.Errorf("unknown day %q in %q (expected mon, tue, ..., sun)", last, spec)
+			}
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM as minutes after midnight; 24:00 is midnight at
+// the end of the day
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hour, herr := strconv.Atoi(h)
+	minute, merr := strconv.Atoi(m)
+	if !ok || herr != nil || merr != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > minutesPerDay {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+// IsZero reports whether the schedule has no windows, so the host is always
+// available
+func (s Schedule) IsZero() bool {
+	return len(s.Windows) == 0
+}
+
+// String formats the schedule as configured, e.g. "mon-fri 20:00-08:00,
+// sat,sun (America/New_York)"
+func (s Schedule) String() string {
+	if s.IsZero() {
+		return "always"
+	}
+	str := strings.Join(s.specs, ", ")
+	if s.zone != "" {
+		str += " (" + s.zone + ")"
+	}
+	return str
+}
+
+// Open reports whether a host may start jobs at t
+func (s Schedule) Open(t time.Time) bool {
+	if s.IsZero() {
+		return true
+	}
+	local := t.In(s.Location)
+	now := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+	for _, w := range s.Windows {
+		if w.Start < w.End {
+			if w.Days[today] && now >= w.Start && now < w.End {
+				return true
+			}
+			continue
+		}
+		if (w.Days[today] && now >= w.Start) || (w.Days[yesterday] && now < w.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the first time at or after t at which a host may start
+// jobs, or the zero time if it never may
+func (s Schedule) NextOpen(t time.Time) time.Time {
+	if s.Open(t) {
+		return t
+	}
+	local := t.In(s.Location)
+	var next time.Time
+	for day := 0; day <= 7; day++ {
+		date := local.AddDate(0, 0, day)
+		for _, w := range s.Windows {
+			if !w.Days[date.Weekday()] {
+				continue
+			}
+			start := time.Date(date.Year(), date.Month(), date.Day(), w.Start/60, w.Start%60, 0, 0, s.Location)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return next
+}
+
+// FormatOpensAt formats when a host next becomes available, in local time,
+// e.g. "Mon 20:00 (in 5h12m)"
+func FormatOpensAt(opens, now time.Time) string {
+	return opens.Local().Format("Mon 15:04") + " (in " + humanfmt.DurationShort(int64(opens.Sub(now).Seconds())) + ")"
+}
+
+// RunnerFile formats the schedule for the queue runner script: a tz= line
+// with the schedule's time zone, or the local one if it has none, since the
+// host's may differ, then one line per window of the days it starts on as
+// `date +%w` digits and its start and end in minutes
+func (s Schedule) RunnerFile() string {
+	zone := s.zone
+	if zone == "" {
+		zone = localZone()
+	}
+	lines := []string{"tz=" + zone}
+	for _, w := range s.Windows {
+		var days strings.Builder
+		for d, on := range w.Days {
+			if on {
+				days.WriteString(strconv.Itoa(d))
+			}
+		}
+		if days.Len() == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", days.String(), w.Start, w.End))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// InstallCommand returns a remote command that writes the schedule where the
+// queue runner reads it, or removes it if the schedule has no windows
+func (s Schedule) InstallCommand() string {
+	if s.IsZero() {
+		return "rm -f " + shellquote.Path(RunnerFilePath)
+	}
+	return shellquote.WriteFile(RunnerFilePath, s.RunnerFile())
+}
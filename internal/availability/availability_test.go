@@ -0,0 +1,92 @@
+package availability
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string // RunnerFile of the window, after its local time zone
+		wantErr bool
+	}{
+		{"20:00-08:00", "0123456 1200 480", false},
+		{"mon-fri 20:00-08:00", "12345 1200 480", false},
+		{"sat,sun", "06 0 1440", false},
+		{"Sat-Sun 00:00-24:00", "06 0 1440", false},
+		{"fri-mon 18:30-23:59", "0156 1110 1439", false},
+		{"20:00", "", true},
+		{"25:00-08:00", "", true},
+		{"someday 20:00-08:00", "", true},
+		{"mon 20:00-08:00 extra", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse([]string{tt.spec}, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if want := "tz=" + localZone() + "\n" + tt.want; !tt.wantErr && s.RunnerFile() != want {
+				t.Errorf("Parse(%q).RunnerFile() = %q, want %q", tt.spec, s.RunnerFile(), want)
+			}
+		})
+	}
+
+	if _, err := Parse(nil, "Not/AZone"); err == nil {
+		t.Error("Parse() with an unknown time zone succeeded, want an error")
+	}
+	s, err := Parse([]string{"sat"}, "America/New_York")
+	if err != nil || s.RunnerFile() != "tz=America/New_York\n6 0 1440" {
+		t.Errorf("Parse() with a time zone = %q, %v", s.RunnerFile(), err)
+	}
+}
+
+func TestOpenAndNextOpen(t *testing.T) {
+	s, err := Parse([]string{"mon-fri 20:00-08:00", "sat,sun"}, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, clock string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", day+" "+clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	// 2026-10-12 is a Monday
+	tests := []struct {
+		name     string
+		t        time.Time
+		wantOpen bool
+		wantNext time.Time
+	}{
+		{"monday afternoon", at("2026-10-12", "14:00"), false, at("2026-10-12", "20:00")},
+		{"monday night", at("2026-10-12", "21:00"), true, at("2026-10-12", "21:00")},
+		{"tuesday early morning", at("2026-10-13", "07:59"), true, at("2026-10-13", "07:59")},
+		{"tuesday morning", at("2026-10-13", "08:00"), false, at("2026-10-13", "20:00")},
+		{"saturday noon", at("2026-10-17", "12:00"), true, at("2026-10-17", "12:00")},
+		{"monday early morning after weekend", at("2026-10-12", "03:00"), false, at("2026-10-12", "20:00")},
+		{"saturday morning after friday night", at("2026-10-17", "07:00"), true, at("2026-10-17", "07:00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Open(tt.t); got != tt.wantOpen {
+				t.Errorf("Open(%v) = %v, want %v", tt.t, got, tt.wantOpen)
+			}
+			if got := s.NextOpen(tt.t); !got.Equal(tt.wantNext) {
+				t.Errorf("NextOpen(%v) = %v, want %v", tt.t, got, tt.wantNext)
+			}
+		})
+	}
+
+	var always Schedule
+	now := time.Now()
+	if !always.Open(now) || !always.NextOpen(now).Equal(now) {
+		t.Error("a schedule with no windows should always be open")
+	}
+}
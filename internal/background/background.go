@@ -0,0 +1,57 @@
+// Package background runs work that waits on other services, such as
+// sending webhooks and Slack messages, without holding up the caller.
+package background
+
+import (
+	"sync"
+	"time"
+)
+
+// running holds the goroutines started by Go that are still running, by
+// name, each with a channel that is closed when it finishes
+var (
+	mu      sync.Mutex
+	running = map[string]chan struct{}{}
+)
+
+// Go runs f in a goroutine, unless the one last started under the same name
+// is still running, in which case f is dropped
+func Go(name string, f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := running[name]; ok {
+		return
+	}
+	done := make(chan struct{})
+	running[name] = done
+	go func() {
+		defer func() {
+			mu.Lock()
+			delete(running, name)
+			mu.Unlock()
+			close(done)
+		}()
+		f()
+	}()
+}
+
+// Wait waits up to timeout for the goroutines started by Go, and reports
+// whether they finished
+func Wait(timeout time.Duration) bool {
+	mu.Lock()
+	var pending []chan struct{}
+	for _, done := range running {
+		pending = append(pending, done)
+	}
+	mu.Unlock()
+
+	deadline := time.After(timeout)
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
+}
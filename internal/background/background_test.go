@@ -0,0 +1,33 @@
+package background
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGo(t *testing.T) {
+	release := make(chan struct{})
+	var runs int
+	Go("test", func() {
+		runs++
+		<-release
+	})
+	// Dropped while the first is running
+	Go("test", func() { runs++ })
+	if Wait(10 * time.Millisecond) {
+		t.Error("Wait() = true while a goroutine is blocked")
+	}
+	close(release)
+	if !Wait(time.Second) {
+		t.Fatal("Wait() = false after the goroutine was released")
+	}
+	if runs != 1 {
+		t.Errorf("ran %d times, want 1", runs)
+	}
+
+	Go("test", func() { runs++ })
+	Wait(time.Second)
+	if runs != 2 {
+		t.Errorf("ran %d times after the first finished, want 2", runs)
+	}
+}
@@ -0,0 +1,320 @@
+// Package batch reads the job files of `remote-jobs submit`: one job per
+// CSV row or JSON line, such as a parameter sweep kept in a spreadsheet.
+//
+// A CSV file starts with a header row naming its columns: host, command,
+// dir, description, queue, tags, and env, plus env.NAME for a column of one
+// variable's values. Within a cell, tags and VAR=value pairs are separated
+// by ";". A JSONL file has one object per line with the same keys, where
+// env is an object and tags a list (or a ";"-separated string).
+package batch
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Row is one job of a batch file
+type Row struct {
+	Line        int // Line of the file the row starts on
+	Host        string
+	Command     string
+	Dir         string
+	Description string
+	Queue       string   // Queue the job is added to, or "" to start it now
+	Env         []string // VAR=value, in column (or key) order
+	Tags        []string
+}
+
+// Defaults fill in the values that rows leave empty
+type Defaults struct {
+	Host string
+	Dir  string
+}
+
+const listSeparator = ";"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// columns are the CSV columns, and JSONL keys, other than env.NAME
+var columns = []string{"host", "command", "dir", "description", "queue", "tags", "env"}
+
+// Format returns "csv" or "jsonl" from a file's extension: .jsonl, .ndjson,
+// and .json are JSONL, and anything else is CSV
+func Format(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson", ".json":
+		return "jsonl"
+	default:
+		return "csv"
+	}
+}
+
+// Parse reads a batch file in format ("csv" or "jsonl")
+func Parse(data []byte, format string) ([]Row, error) {
+	switch format {
+	case "csv":
+		return parseCSV(data)
+	case "jsonl":
+		return parseJSONL(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q (use csv or jsonl)", format)
+	}
+}
+
+func parseCSV(data []byte) ([]Row, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range header {
+		// Spreadsheets may start the file with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if len(name) > 4 && strings.EqualFold(name[:4], "env.") {
+			// Keep the variable's case
+			env := name[4:]
+			if !envNamePattern.MatchString(env) {
+				return nil, fmt.Errorf("column %q: invalid environment variable name", name)
+			}
+			header[i] = "env." + env
+			continue
+		}
+		header[i] = strings.ToLower(name)
+		if !slices.Contains(columns, header[i]) {
+			return nil, fmt.Errorf("unknown column %q (use %s, or env.NAME)", name, strings.Join(columns, ", "))
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue // A blank spreadsheet row
+		}
+		line, _ := r.FieldPos(0)
+		row := Row{Line: line}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch name := header[i]; name {
+			case "host":
+				row.Host = value
+			case "command":
+				row.Command = value
+			case "dir":
+				row.Dir = value
+			case "description":
+				row.Description = value
+			case "queue":
+				row.Queue = value
+			case "tags":
+				row.Tags = splitList(value)
+			case "env":
+				row.Env = append(row.Env, splitList(value)...)
+			default:
+				if value != "" {
+					row.Env = append(row.Env, strings.TrimPrefix(name, "env.")+"="+value)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// jsonRow is a JSONL line. Env is decoded with its keys in file order.
+type jsonRow struct {
+	Host        string          `json:"host"`
+	Command     string          `json:"command"`
+	Dir         string          `json:"dir"`
+	Description string          `json:"description"`
+	Queue       string          `json:"queue"`
+	Tags        json.RawMessage `json:"tags"`
+	Env         json.RawMessage `json:"env"`
+}
+
+func parseJSONL(data []byte) ([]Row, error) {
+	var rows []Row
+	for i, text := range strings.Split(string(data), "\n") {
+		line := i + 1
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		var j jsonRow
+		if err := dec.Decode(&j); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row := Row{Line: line, Host: j.Host, Command: j.Command, Dir: j.Dir, Description: j.Description, Queue: j.Queue}
+		var err error
+		if row.Tags, err = decodeTags(j.Tags); err != nil {
+			return nil, fmt.Errorf("line %d: tags: %w", line, err)
+		}
+		if row.Env, err = decodeEnv(j.Env); err != nil {
+			return nil, fmt.Errorf("line %d: env: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func decodeTags(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return splitList(s), nil
+	}
+	var tags []string
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		return nil, fmt.Errorf("want a list of strings")
+	}
+	return tags, nil
+}
+
+// decodeEnv decodes an object of variables, keeping their order, or a
+// ";"-separated string of VAR=value pairs
+func decodeEnv(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return splitList(s), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("want an object of variables")
+	}
+	var env []string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		switch value.(type) {
+		case string, json.Number, bool:
+		default:
+			return nil, fmt.Errorf("%s: want a string, number, or boolean", key)
+		}
+		env = append(env, fmt.Sprintf("%s=%v", key, value))
+	}
+	return env, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ApplyDefaults fills in the host and directory of rows that leave them
+// empty
+func ApplyDefaults(rows []Row, defaults Defaults) {
+	for i := range rows {
+		if rows[i].Host == "" {
+			rows[i].Host = defaults.Host
+		}
+		if rows[i].Dir == "" {
+			rows[i].Dir = defaults.Dir
+		}
+	}
+}
+
+// Validate checks every row, so that the file can be fixed before any job
+// is submitted, and returns all the problems found
+func Validate(rows []Row) error {
+	if len(rows) == 0 {
+		return fmt.Errorf("no jobs in the file")
+	}
+	var errs []error
+	for _, row := range rows {
+		if err := row.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", row.Line, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r Row) validate() error {
+	if r.Command == "" {
+		return fmt.Errorf("missing command")
+	}
+	if r.Host == "" {
+		return fmt.Errorf("missing host (add a host column, or use --host)")
+	}
+	for _, v := range r.Env {
+		name, _, ok := strings.Cut(v, "=")
+		if !ok || !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable %q (want VAR=value)", v)
+		}
+	}
+	for _, tag := range r.Tags {
+		if strings.ContainsAny(tag, " \t,") {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	return nil
+}
+
+// Result is what became of a row
+type Result struct {
+	Row   Row
+	JobID int64  // 0 if it wasn't submitted
+	Error string // Why it wasn't
+}
+
+// WriteResults writes the row-to-job mapping as CSV
+func WriteResults(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"line", "job_id", "host", "queue", "command", "error"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		id := ""
+		if r.JobID > 0 {
+			id = fmt.Sprint(r.JobID)
+		}
+		if err := cw.Write([]string{fmt.Sprint(r.Row.Line), id, r.Row.Host, r.Row.Queue, r.Row.Command, r.Error}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ResultsPath returns where the mapping for a batch file is saved by
+// default: beside it, as NAME.jobs.csv
+func ResultsPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".jobs.csv"
+}
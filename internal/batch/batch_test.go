@@ -0,0 +1,115 @@
+package batch
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	data := "\ufeffHost,command,env.LR,env,tags,queue\n" +
+		"# a comment\n" +
+		"cool30,python train.py,0.01,SEED=1;WANDB=off,sweep7;lr,\n" +
+		",,,,,\n" +
+		"\"\",\"python train.py --note \"\"a, b\"\"\",0.001,,,gpu\n"
+	rows, err := Parse([]byte(data), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{Line: 3, Host: "cool30", Command: "python train.py", Env: []string{"LR=0.01", "SEED=1", "WANDB=off"}, Tags: []string{"sweep7", "lr"}},
+		{Line: 5, Command: `python train.py --note "a, b"`, Env: []string{"LR=0.001"}, Queue: "gpu"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", rows, want)
+	}
+
+	for _, bad := range []string{"", "host,cmd\n", "host,env.1X\n"} {
+		if _, err := Parse([]byte(bad), "csv"); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseJSONL(t *testing.T) {
+	data := `{"host": "cool30", "command": "python train.py", "env": {"LR": 0.01, "DEBUG": true, "NAME": "a"}, "tags": ["sweep7"]}
+
+# a comment
+{"command": "python eval.py", "tags": "x;y", "env": "A=1", "queue": "gpu"}
+`
+	rows, err := Parse([]byte(data), "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{Line: 1, Host: "cool30", Command: "python train.py", Env: []string{"LR=0.01", "DEBUG=true", "NAME=a"}, Tags: []string{"sweep7"}},
+		{Line: 4, Command: "python eval.py", Env: []string{"A=1"}, Tags: []string{"x", "y"}, Queue: "gpu"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Parse() =\n%+v\nwant\n%+v", rows, want)
+	}
+
+	for _, bad := range []string{`{"cmd": "x"}`, `{"command": "x", "env": ["A=1"]}`, `{"command": "x", "env": {"A": {}}}`, `not json`} {
+		if _, err := Parse([]byte(bad), "jsonl"); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	rows := []Row{
+		{Line: 2, Host: "cool30", Command: "python train.py", Env: []string{"LR=0.1"}},
+		{Line: 3, Host: "cool30"},
+		{Line: 4, Command: "python train.py"},
+		{Line: 5, Host: "cool30", Command: "x", Env: []string{"1LR=0.1"}},
+		{Line: 6, Host: "cool30", Command: "x", Tags: []string{"a b"}},
+	}
+	err := Validate(rows)
+	if err == nil {
+		t.Fatal("Validate() should fail")
+	}
+	for _, line := range []string{"line 3:", "line 4:", "line 5:", "line 6:"} {
+		if !strings.Contains(err.Error(), line) {
+			t.Errorf("Validate() = %q, want a problem on %s", err, line)
+		}
+	}
+	if strings.Contains(err.Error(), "line 2:") {
+		t.Errorf("Validate() = %q, line 2 is valid", err)
+	}
+
+	ApplyDefaults(rows[2:3], Defaults{Host: "cool31", Dir: "~/code"})
+	if rows[2].Host != "cool31" || rows[2].Dir != "~/code" {
+		t.Errorf("ApplyDefaults() = %+v", rows[2])
+	}
+	if err := Validate(rows[:1]); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := Validate(nil); err == nil {
+		t.Error("Validate(nil) should fail")
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteResults(&buf, []Result{
+		{Row: Row{Line: 2, Host: "cool30", Command: "python train.py, fast"}, JobID: 41},
+		{Row: Row{Line: 3, Host: "cool30", Command: "x", Queue: "gpu"}, Error: "queue full"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "line,job_id,host,queue,command,error\n2,41,cool30,,\"python train.py, fast\",\n3,,cool30,gpu,x,queue full\n"
+	if buf.String() != want {
+		t.Errorf("WriteResults() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestFormatAndResultsPath(t *testing.T) {
+	if Format("sweep.jsonl") != "jsonl" || Format("sweep.CSV") != "csv" || Format("-") != "csv" {
+		t.Error("Format() chose the wrong format")
+	}
+	if got := ResultsPath("runs/sweep.csv"); got != "runs/sweep.jobs.csv" {
+		t.Errorf("ResultsPath() = %q", got)
+	}
+}
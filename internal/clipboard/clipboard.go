@@ -0,0 +1,106 @@
+// Package clipboard copies text to the user's clipboard from a terminal
+// program.
+//
+// The local clipboard tools (pbcopy, wl-copy, xclip, xsel, clip.exe) only
+// work when the program runs on the user's own machine. Over SSH, or where
+// none is installed, the text is sent to the terminal as an OSC 52 escape
+// sequence, which most modern terminals (iTerm2, kitty, WezTerm, Windows
+// Terminal, and tmux with set-clipboard on) copy to the clipboard of the
+// machine the terminal runs on.
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Methods that Copy reports having used
+const (
+	MethodCommand = "command" // A local clipboard tool
+	MethodOSC52   = "osc52"   // The terminal's OSC 52 support
+)
+
+// Copy puts text on the clipboard and reports how: MethodCommand if a local
+// clipboard tool accepted it, or MethodOSC52 if it was written to out as an
+// escape sequence. OSC 52 can't report failure, so a terminal without it
+// silently ignores the sequence.
+func Copy(text string, out io.Writer) (string, error) {
+	if CopyWithTool(text) {
+		return MethodCommand, nil
+	}
+	if _, err := io.WriteString(out, Sequence(text)); err != nil {
+		return "", fmt.Errorf("writing to terminal: %w", err)
+	}
+	return MethodOSC52, nil
+}
+
+// CopyWithTool puts text on the clipboard with a local clipboard tool, and
+// reports whether one accepted it. It never uses one over SSH.
+func CopyWithTool(text string) bool {
+	if isRemoteSession() {
+		return false
+	}
+	for _, args := range commands(runtime.GOOS) {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Sequence returns the OSC 52 escape sequence that copies text, wrapped for
+// the multiplexer the program runs in, if any. A program that owns the
+// terminal, such as a TUI, writes it out itself along with its output.
+func Sequence(text string) string {
+	return OSC52(text, os.Getenv("TMUX") != "", os.Getenv("TERM"))
+}
+
+// OSC52 returns the escape sequence that asks the terminal to set its
+// clipboard to text. Inside tmux or GNU screen the sequence is wrapped so the
+// multiplexer passes it through to the outer terminal.
+func OSC52(text string, inTmux bool, term string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	switch {
+	case inTmux:
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(term, "screen"):
+		return "\x1bP" + seq + "\x1b\\"
+	}
+	return seq
+}
+
+// commands returns the clipboard tools to try on an OS, in order of
+// preference
+func commands(goos string) [][]string {
+	switch goos {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}}
+	}
+	var cmds [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append(cmds, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		cmds = append(cmds, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	// WSL: the Windows clipboard is reachable through clip.exe
+	return append(cmds, []string{"clip.exe"})
+}
+
+// isRemoteSession reports whether the program is running over SSH, where a
+// local clipboard tool would copy to the remote machine's clipboard
+func isRemoteSession() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
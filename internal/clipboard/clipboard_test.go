@@ -0,0 +1,45 @@
+package clipboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOSC52(t *testing.T) {
+	if got, want := OSC52("ssh cool30", false, "xterm-256color"), "\x1b]52;c;c3NoIGNvb2wzMA==\a"; got != want {
+		t.Errorf("OSC52() = %q, want %q", got, want)
+	}
+	if got, want := OSC52("42", true, "screen-256color"), "\x1bPtmux;\x1b\x1b]52;c;NDI=\a\x1b\\"; got != want {
+		t.Errorf("OSC52() in tmux = %q, want %q", got, want)
+	}
+	if got, want := OSC52("42", false, "screen"), "\x1bP\x1b]52;c;NDI=\a\x1b\\"; got != want {
+		t.Errorf("OSC52() in screen = %q, want %q", got, want)
+	}
+}
+
+func TestCopyOverSSHUsesOSC52(t *testing.T) {
+	t.Setenv("SSH_TTY", "/dev/pts/3")
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+	var out strings.Builder
+	method, err := Copy("42", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != MethodOSC52 || out.String() != OSC52("42", false, "xterm-256color") {
+		t.Errorf("Copy() = %q, wrote %q", method, out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	if got, want := commands("darwin"), [][]string{{"pbcopy"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("commands(darwin) = %v, want %v", got, want)
+	}
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+	got := commands("linux")
+	if len(got) != 3 || got[0][0] != "xclip" || got[2][0] != "clip.exe" {
+		t.Errorf("commands(linux) with X11 = %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Package clockskew measures the difference between the local clock and a
+// host's clock, so that times read from the host (status file mtimes, queue
+// runner start times) can be converted to local time. Without this, durations
+// are wrong on hosts whose clocks have drifted.
+package clockskew
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// Command prints the host's clock in seconds since the epoch and its time
+// zone's offset from UTC
+const Command = "date '+%s %z'"
+
+// WarnThreshold is the skew above which starting a job prints a warning
+const WarnThreshold = 30 * time.Second
+
+// Reading is a measurement of a host's clock
+type Reading struct {
+	Remote    int64 // Host's clock, in seconds since the epoch
+	Skew      int64 // Seconds the host's clock is ahead of the local clock
+	UTCOffset int   // Host's time zone offset from UTC, in seconds
+}
+
+// Measure parses the output of Command, given the local clock just before and
+// after the command ran. The last line of output is used, so Command can
+// follow other commands.
+func Measure(output string, before, after time.Time) (Reading, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) != 2 {
+		return Reading{}, fmt.Errorf("parse remote clock: unexpected output %q", lines[len(lines)-1])
+	}
+	remote, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Reading{}, fmt.Errorf("parse remote clock: %w", err)
+	}
+	zone, err := time.Parse("-0700", fields[1])
+	if err != nil {
+		return Reading{}, fmt.Errorf("parse remote time zone: %w", err)
+	}
+	_, offset := zone.Zone()
+	midpoint := before.Add(after.Sub(before) / 2).Unix()
+	return Reading{Remote: remote, Skew: remote - midpoint, UTCOffset: offset}, nil
+}
+
+// Record measures the skew from the output of Command, run between before
+// and after, and records it and the host's time zone for the job. It returns
+// the skew in seconds.
+func Record(database *sql.DB, jobID int64, output string, before, after time.Time) (int64, error) {
+	r, err := Measure(output, before, after)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.RecordJobClock(database, jobID, r.Remote-r.Skew, r.Remote, r.UTCOffset); err != nil {
+		return 0, err
+	}
+	return r.Skew, nil
+}
+
+// Exceeds reports whether skew (in seconds) is large enough to warn about
+func Exceeds(skew int64) bool {
+	if skew < 0 {
+		skew = -skew
+	}
+	return time.Duration(skew)*time.Second > WarnThreshold
+}
+
+// Describe describes a skew, e.g. "cool30's clock is 2m 5s ahead of the local clock"
+func Describe(host string, skew int64) string {
+	direction := "ahead of"
+	if skew < 0 {
+		direction, skew = "behind", -skew
+	}
+	return fmt.Sprintf("%s's clock is %s %s the local clock", host, humanfmt.Duration(skew), direction)
+}
+
+// ToLocal converts a time read from a job's host to local time, using the
+// skew measured when the job started, or else the host's latest measurement.
+// Times are returned unchanged if the skew was never measured.
+func ToLocal(database *sql.DB, job *db.Job, remote int64) int64 {
+	skew, ok, err := db.JobClockSkew(database, job.ID)
+	if err != nil || !ok {
+		skew, _, _ = db.HostClockSkew(database, job.Host)
+	}
+	return remote - skew
+}
+
+// EndTime returns a finished job's end time: the mtime of its status file,
+// which the host wrote when the job exited, converted to local time. If the
+// mtime is unknown it returns now.
+func EndTime(database *sql.DB, job *db.Job, statusMtime int64, now time.Time) int64 {
+	if statusMtime <= 0 {
+		return now.Unix()
+	}
+	end := ToLocal(database, job, statusMtime)
+	if end > now.Unix() || end < job.StartTime {
+		// The measurement is stale or the clock jumped; don't record an
+		// impossible end time
+		return now.Unix()
+	}
+	return end
+}
@@ -0,0 +1,39 @@
+package clockskew
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeasure(t *testing.T) {
+	before := time.Unix(1000, 0)
+	after := time.Unix(1004, 0)
+	got, err := Measure("created\n1062 -0530\n", before, after)
+	if err != nil {
+		t.Fatalf("Measure() error: %v", err)
+	}
+	want := Reading{Remote: 1062, Skew: 60, UTCOffset: -(5*3600 + 30*60)}
+	if got != want {
+		t.Errorf("Measure() = %+v, want %+v", got, want)
+	}
+
+	for _, output := range []string{"", "1062", "1062 EST"} {
+		if _, err := Measure(output, before, after); err == nil {
+			t.Errorf("Measure(%q) succeeded", output)
+		}
+	}
+}
+
+func TestExceeds(t *testing.T) {
+	for skew, want := range map[int64]bool{0: false, 30: false, 31: true, -90: true} {
+		if got := Exceeds(skew); got != want {
+			t.Errorf("Exceeds(%d) = %v, want %v", skew, got, want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	if got, want := Describe("cool30", -125), "cool30's clock is 2m 5s behind the local clock"; got != want {
+		t.Errorf("Describe() = %q, want %q", got, want)
+	}
+}
@@ -1,9 +1,20 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/osteele/remote-jobs/internal/availability"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/pathmap"
+	"github.com/osteele/remote-jobs/internal/redact"
+	"github.com/osteele/remote-jobs/internal/timefmt"
+	"github.com/osteele/remote-jobs/internal/xdg"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,6 +34,379 @@ type Config struct {
 
 	// EnableMouse toggles mouse support in the TUI (disables terminal selection when true)
 	EnableMouse bool `yaml:"enable_mouse"`
+
+	// ReadOnly refuses commands and TUI actions that change jobs, queues, or
+	// hosts, as does --read-only
+	ReadOnly bool `yaml:"read_only"`
+
+	// Hooks are local commands run when a job finishes; `run --on-success`
+	// and `run --on-failure` override them per job
+	Hooks HooksConfig `yaml:"hooks"`
+
+	// Limits apply to every host; a host's own limits override them
+	Limits Limits `yaml:"limits"`
+
+	// Watchdog flags running jobs whose GPUs have gone idle
+	Watchdog Watchdog `yaml:"watchdog"`
+
+	// TimeDisplay sets the time zone (local, utc, host) and style (auto,
+	// absolute, relative) of times in list, status, and the TUI
+	TimeDisplay timefmt.Options `yaml:"time_display"`
+
+	// Formatting sets the units of sizes (binary or decimal) and the locale
+	// whose decimal separator they use
+	Formatting humanfmt.Options `yaml:"formatting"`
+
+	// Prune is the policy applied by `prune --apply-policy`, and
+	// automatically if prune.auto is set
+	Prune PrunePolicy `yaml:"prune"`
+
+	// Database schedules backups and garbage collection of the local
+	// database, which `remote-jobs sync` runs when they are due
+	Database DatabaseMaintenance `yaml:"database"`
+
+	// CacheHome is the directory on hosts that remote-jobs keeps job logs,
+	// queues, and scripts under, in a remote-jobs directory, for hosts
+	// whose home directories are too small: e.g. /scratch/alice. Unset, it
+	// is ~/.cache. ~/.cache/remote-jobs links to the directory there.
+	CacheHome string `yaml:"cache_home"`
+
+	// OpenDir is how `open-dir` opens a job's directory: vscode (default),
+	// cursor, sftp, or a URI template (see the opendir package)
+	OpenDir string `yaml:"open_dir"`
+
+	// PathMappings pair local directories with remote ones for hosts whose
+	// layout differs from the local home directory's
+	PathMappings []pathmap.Mapping `yaml:"path_mappings"`
+
+	// Redact hides credentials in commands, logs, and exports (see the
+	// redact package)
+	Redact RedactConfig `yaml:"redact"`
+
+	// DeadJobs sets how long a job must look dead before sync marks it
+	// dead, so that a brief NFS or tmux server hiccup doesn't
+	DeadJobs DeadJobs `yaml:"dead_jobs"`
+
+	// Webhooks receive a JSON POST whenever a job changes status (see the
+	// webhooks package)
+	Webhooks []Webhook `yaml:"webhooks"`
+
+	// Notifiers post to Slack from this machine when sync finds jobs
+	// finished; unlike the notify script run on hosts, they can batch,
+	// rate-limit, and digest their messages (see the notify package)
+	Notifiers []Notifier `yaml:"notifiers"`
+
+	// SlackTemplate replaces the format of the Slack messages about finished
+	// jobs, both those of the notify script run on hosts and those of
+	// notifiers without a template of their own. It is a Go text/template
+	// given the fields of notify.Fields.
+	SlackTemplate string `yaml:"slack_template"`
+
+	// Timeouts are how long to wait on hosts' commands; a host's own
+	// override them
+	Timeouts Timeouts `yaml:"timeouts"`
+
+	// Hosts holds per-host settings, keyed by host name as passed to ssh
+	Hosts map[string]HostConfig `yaml:"hosts"`
+}
+
+// RedactConfig sets the patterns that are redacted from displayed and
+// exported text
+type RedactConfig struct {
+	// Patterns are regular expressions to redact in addition to the builtin
+	// ones; where one has a capturing group, only that group is replaced
+	Patterns []string `yaml:"patterns"`
+	// DisableBuiltin turns off the builtin patterns for tokens, keys, and
+	// URLs with credentials
+	DisableBuiltin bool `yaml:"disable_builtin"`
+}
+
+// HostConfig holds settings that apply to every job on one host
+type HostConfig struct {
+	// User is the account to log in to the host as, when it's named without
+	// one; a host named "user@host" uses the settings of "host" if it has none
+	// of its own
+	User string `yaml:"user"`
+	// PreStart is a remote shell snippet run in the job's directory before the job starts
+	PreStart string `yaml:"pre_start"`
+	// PostFinish is a remote shell snippet run after the job exits
+	PostFinish string `yaml:"post_finish"`
+	// OpenDir overrides the global open_dir for this host
+	OpenDir string `yaml:"open_dir"`
+	// CacheHome overrides the global cache_home for this host
+	CacheHome string `yaml:"cache_home"`
+	// PathMappings apply to this host before the global ones
+	PathMappings []pathmap.Mapping `yaml:"path_mappings"`
+	// Env holds environment variables set for every job started or queued
+	// on this host, such as HF_HOME; a job's own --env assignments override them
+	Env map[string]string `yaml:"env"`
+	// DeadJobs overrides the global dead_jobs settings for this host
+	DeadJobs DeadJobs `yaml:"dead_jobs"`
+	// Tags are capabilities that `run --require` and `--avoid` match, in
+	// addition to those detected from the host's cached info
+	Tags []string `yaml:"tags"`
+	// Availability limits when jobs may start on this host; queue runners
+	// and auto placement wait for its windows
+	Availability Availability `yaml:"availability"`
+	// Timeouts override the global timeouts for this host
+	Timeouts Timeouts `yaml:"timeouts"`
+	// Limits override the global limits for this host
+	Limits `yaml:",inline"`
+}
+
+// Availability is when a host may start jobs (see the availability package)
+type Availability struct {
+	// Windows are spans such as "20:00-08:00" or "mon-fri 20:00-08:00";
+	// none means always
+	Windows []string `yaml:"windows"`
+	// TimeZone is the IANA time zone of the windows, such as
+	// "America/New_York" (default: local time)
+	TimeZone string `yaml:"time_zone"`
+}
+
+// Timeouts are how long, in seconds, to wait on a host's commands before
+// treating the host as unreachable. Zero means the default. Each is
+// stretched on hosts whose measured round trips are slow.
+type Timeouts struct {
+	// Sync is each check of a job's status by sync and the TUI (default 5)
+	Sync int `yaml:"sync"`
+	// FastSync is the sync of each host before job list and job status
+	// (default 2)
+	FastSync int `yaml:"fast_sync"`
+	// Probe is each query of a host's info, load, GPUs, events, or disk
+	// space (default 10)
+	Probe int `yaml:"probe"`
+	// Connect is establishing an SSH connection (default 10)
+	Connect int `yaml:"connect"`
+}
+
+// Limits cap how many jobs can be submitted to a host. Zero means no limit.
+type Limits struct {
+	// MaxRunning is the most jobs that may run at once
+	MaxRunning int `yaml:"max_running"`
+	// MaxQueueDepth is the most jobs that may wait in any one queue
+	MaxQueueDepth int `yaml:"max_queue_depth"`
+	// MinFreeDiskGB is the least free space, in GB, that the filesystem
+	// holding a job's working directory must have for the job to start
+	MinFreeDiskGB int `yaml:"min_free_disk_gb"`
+	// MinFreeInodesPct is the least share of free inodes, in percent, on that filesystem
+	MinFreeInodesPct int `yaml:"min_free_inodes_pct"`
+}
+
+// CheckRunning returns an error if starting another job would exceed MaxRunning
+func (l Limits) CheckRunning(host string, running int) error {
+	if l.MaxRunning > 0 && running >= l.MaxRunning {
+		return fmt.Errorf("%s already has %d running job(s) (max_running: %d)", host, running, l.MaxRunning)
+	}
+	return nil
+}
+
+// CheckQueueDepth returns an error if queueing another job would exceed MaxQueueDepth
+func (l Limits) CheckQueueDepth(host, queue string, depth int) error {
+	if l.MaxQueueDepth > 0 && depth >= l.MaxQueueDepth {
+		return fmt.Errorf("queue '%s' on %s already has %d job(s) waiting (max_queue_depth: %d)", queue, host, depth, l.MaxQueueDepth)
+	}
+	return nil
+}
+
+// Defaults for DeadJobs
+const (
+	DefaultDeadGraceMinutes = 3
+	DefaultDeadProbes       = 2
+)
+
+// DeadJobs sets when a job whose session (or process) and status file are
+// missing is marked dead: once at least Probes consecutive checks over at
+// least GraceMinutes have found them missing. Unset fields take the defaults;
+// grace_minutes: 0 and probes: 1 mark a job dead on the first such check.
+type DeadJobs struct {
+	GraceMinutes *int `yaml:"grace_minutes"`
+	Probes       *int `yaml:"probes"`
+}
+
+// Watchdog actions, taken once when a job's GPUs have been idle too long
+const (
+	WatchdogWarn   = "warn"   // print a warning and badge the job
+	WatchdogNotify = "notify" // also run the watchdog command
+	WatchdogKill   = "kill"   // also kill the job
+)
+
+// Watchdog configures the idle-GPU watchdog. It is off unless IdleGPUMinutes is set.
+type Watchdog struct {
+	// IdleGPUMinutes is how long every GPU a job holds must sit at ~0%
+	// utilization before the job is flagged
+	IdleGPUMinutes int `yaml:"idle_gpu_minutes"`
+	// Action is warn (default), notify, or kill
+	Action string `yaml:"action"`
+	// Command is a local shell command run by the notify action, with the
+	// same REMOTE_JOBS_* environment as completion hooks
+	Command string `yaml:"command"`
+}
+
+// Enabled reports whether the watchdog should sample GPU utilization
+func (w Watchdog) Enabled() bool {
+	return w.IdleGPUMinutes > 0
+}
+
+// IdleAfter returns how long a job's GPUs must be idle before it is flagged
+func (w Watchdog) IdleAfter() time.Duration {
+	return time.Duration(w.IdleGPUMinutes) * time.Minute
+}
+
+// EffectiveAction returns the configured action, defaulting to warn
+func (w Watchdog) EffectiveAction() (string, error) {
+	switch w.Action {
+	case "":
+		return WatchdogWarn, nil
+	case WatchdogWarn, WatchdogNotify, WatchdogKill:
+		return w.Action, nil
+	default:
+		return "", fmt.Errorf("unknown watchdog action %q (expected warn, notify, or kill)", w.Action)
+	}
+}
+
+// PrunePolicy selects finished jobs to remove from the database. Each rule
+// is off when zero; a job is removed if any rule selects it.
+type PrunePolicy struct {
+	// Auto applies the policy when the TUI starts and after `remote-jobs sync`
+	Auto bool `yaml:"auto"`
+	// KeepLast is how many of the most recent finished jobs to keep; older
+	// ones are removed
+	KeepLast int `yaml:"keep_last"`
+	// SucceededMaxAgeDays removes jobs that succeeded longer ago than this
+	SucceededMaxAgeDays int `yaml:"succeeded_max_age_days"`
+	// FailedMaxAgeDays removes failed and dead jobs that ended longer ago than this
+	FailedMaxAgeDays int `yaml:"failed_max_age_days"`
+	// KeepFailed exempts failed and dead jobs from every rule
+	KeepFailed bool `yaml:"keep_failed"`
+}
+
+// Enabled reports whether any rule is set
+func (p PrunePolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.SucceededMaxAgeDays > 0 || p.FailedMaxAgeDays > 0
+}
+
+// Defaults for DatabaseMaintenance
+const (
+	DefaultBackupIntervalHours = 24
+	DefaultKeepBackups         = 7
+	DefaultGCIntervalDays      = 7
+)
+
+// DatabaseMaintenance sets how often the local database is backed up and
+// garbage-collected. A zero interval is the default; a negative one turns
+// the task off.
+type DatabaseMaintenance struct {
+	// BackupIntervalHours is how often the database is backed up
+	BackupIntervalHours int `yaml:"backup_interval_hours"`
+	// KeepBackups is how many backups to keep; older ones are removed
+	KeepBackups int `yaml:"keep_backups"`
+	// GCIntervalDays is how often VACUUM and ANALYZE run
+	GCIntervalDays int `yaml:"gc_interval_days"`
+}
+
+// BackupInterval returns how often to back up the database, or 0 if
+// automatic backups are off
+func (m DatabaseMaintenance) BackupInterval() time.Duration {
+	return maintenanceInterval(m.BackupIntervalHours, DefaultBackupIntervalHours, time.Hour)
+}
+
+// GCInterval returns how often to garbage-collect the database, or 0 if
+// automatic garbage collection is off
+func (m DatabaseMaintenance) GCInterval() time.Duration {
+	return maintenanceInterval(m.GCIntervalDays, DefaultGCIntervalDays, 24*time.Hour)
+}
+
+// Keep returns how many backups to keep
+func (m DatabaseMaintenance) Keep() int {
+	if m.KeepBackups > 0 {
+		return m.KeepBackups
+	}
+	return DefaultKeepBackups
+}
+
+func maintenanceInterval(n, def int, unit time.Duration) time.Duration {
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		n = def
+	}
+	return time.Duration(n) * unit
+}
+
+// HooksConfig holds default local completion hooks
+type HooksConfig struct {
+	OnSuccess string `yaml:"on_success"`
+	OnFailure string `yaml:"on_failure"`
+}
+
+// Webhook is a URL that is sent job status changes. Statuses, Tags, and
+// Hosts each narrow the changes it is sent; empty ones match every change.
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Secret, if set, signs each payload with HMAC-SHA256
+	Secret string `yaml:"secret"`
+	// Statuses are the statuses a job must change to
+	Statuses []string `yaml:"statuses"`
+	// Tags are tags of which a job must have at least one
+	Tags []string `yaml:"tags"`
+	// Hosts are the hosts a job must run on
+	Hosts []string `yaml:"hosts"`
+	// Attempts is how many times a delivery is tried before it is dropped
+	Attempts int `yaml:"attempts"`
+}
+
+// DefaultWebhookAttempts is how many times a delivery is tried when a
+// webhook doesn't set attempts
+const DefaultWebhookAttempts = 3
+
+// Notifier is a Slack incoming webhook that is sent messages about finished
+// jobs. Without batching, a rate limit, or a digest, each job gets a message
+// of its own.
+type Notifier struct {
+	// Slack is the incoming webhook's URL
+	Slack string `yaml:"slack"`
+	// Notify is all (default) or failures
+	Notify string `yaml:"notify"`
+	// BatchMinutes collects the jobs that finish within this many minutes
+	// of the first into one message
+	BatchMinutes int `yaml:"batch_minutes"`
+	// MaxPerHour is the most messages sent in any hour; jobs that finish
+	// once it is reached wait for the next message
+	MaxPerHour int `yaml:"max_per_hour"`
+	// DigestHours, if set, sends one summary of the jobs that finished every
+	// this many hours instead of messages as they finish
+	DigestHours int `yaml:"digest_hours"`
+	// Template formats each job in the notifier's messages (default:
+	// slack_template, or else the builtin format)
+	Template string `yaml:"template"`
+}
+
+// SlackNotifiers returns the notifiers, with slack_template as the template
+// of those without one
+func (c *Config) SlackNotifiers() []Notifier {
+	notifiers := make([]Notifier, len(c.Notifiers))
+	for i, n := range c.Notifiers {
+		if n.Template == "" {
+			n.Template = c.SlackTemplate
+		}
+		notifiers[i] = n
+	}
+	return notifiers
+}
+
+// NotifiesFailuresOnly reports whether the notifier only sends failed jobs,
+// or returns an error for an unknown notify setting
+func (n Notifier) NotifiesFailuresOnly() (bool, error) {
+	switch n.Notify {
+	case "", "all":
+		return false, nil
+	case "failures":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown notify setting %q (expected all or failures)", n.Notify)
+	}
 }
 
 // DefaultConfig returns the default configuration
@@ -36,30 +420,182 @@ func DefaultConfig() *Config {
 	}
 }
 
-var configPath string
+// Host returns the settings for a host, or zero values if it has none. A
+// "user@host" name without settings of its own has those of its host part.
+func (c *Config) Host(name string) HostConfig {
+	if h, ok := c.Hosts[name]; ok {
+		return h
+	}
+	if _, host, ok := strings.Cut(name, "@"); ok {
+		return c.Hosts[host]
+	}
+	return HostConfig{}
+}
+
+// OpenDirTemplate returns the open_dir setting for a host: its own if set,
+// otherwise the global one, which may be empty
+func (c *Config) OpenDirTemplate(host string) string {
+	if dir := c.Host(host).OpenDir; dir != "" {
+		return dir
+	}
+	return c.OpenDir
+}
+
+// HostCacheHome returns the cache_home setting for a host: its own if set,
+// otherwise the global one, which may be empty for the default
+func (c *Config) HostCacheHome(host string) string {
+	if dir := c.Host(host).CacheHome; dir != "" {
+		return dir
+	}
+	return c.CacheHome
+}
+
+// RemoteHooks returns the remote hooks of a job on a host: the pre-start and
+// post-finish hooks given for it, or where none is given, the host's
+// pre_start and post_finish
+func (c *Config) RemoteHooks(host, preStart, postFinish string) (string, string) {
+	h := c.Host(host)
+	if preStart == "" {
+		preStart = h.PreStart
+	}
+	if postFinish == "" {
+		postFinish = h.PostFinish
+	}
+	return preStart, postFinish
+}
+
+// HostEnv returns a job's environment variables on a host: the host's env
+// defaults, in name order, that the job doesn't assign itself, followed by
+// the job's own assignments
+func (c *Config) HostEnv(host string, envVars []string) []string {
+	defaults := c.Host(host).Env
+	if len(defaults) == 0 {
+		return envVars
+	}
+	assigned := map[string]bool{}
+	for _, v := range envVars {
+		name, _, _ := strings.Cut(v, "=")
+		assigned[name] = true
+	}
+	var merged []string
+	for _, name := range slices.Sorted(maps.Keys(defaults)) {
+		if !assigned[name] {
+			merged = append(merged, name+"="+defaults[name])
+		}
+	}
+	return append(merged, envVars...)
+}
+
+// WithoutHostEnv returns a job's environment variables less those that are
+// exactly a host's env defaults, so that a job moved to another host takes
+// that host's defaults instead
+func (c *Config) WithoutHostEnv(host string, envVars []string) []string {
+	defaults := c.Host(host).Env
+	if len(defaults) == 0 {
+		return envVars
+	}
+	var own []string
+	for _, v := range envVars {
+		name, value, _ := strings.Cut(v, "=")
+		if d, ok := defaults[name]; !ok || d != value {
+			own = append(own, v)
+		}
+	}
+	return own
+}
+
+// Redactor returns the redactor for the redact settings
+func (c *Config) Redactor() (*redact.Redactor, error) {
+	return redact.New(c.Redact.Patterns, !c.Redact.DisableBuiltin)
+}
 
-func init() {
-	home, err := os.UserHomeDir()
+// HostPathMappings returns the path mappings for a host, its own first, with
+// ~ in their local directories expanded
+func (c *Config) HostPathMappings(host string) []pathmap.Mapping {
+	mappings := append(append([]pathmap.Mapping{}, c.Host(host).PathMappings...), c.PathMappings...)
+	if home, err := os.UserHomeDir(); err == nil {
+		mappings = pathmap.ExpandHome(mappings, home)
+	}
+	return mappings
+}
+
+// HostAvailability returns when a host may start jobs
+func (c *Config) HostAvailability(host string) (availability.Schedule, error) {
+	a := c.Host(host).Availability
+	s, err := availability.Parse(a.Windows, a.TimeZone)
 	if err != nil {
-		return
+		return s, fmt.Errorf("availability of %s: %w", host, err)
+	}
+	return s, nil
+}
+
+// HostLimits returns the limits for a host: its own where set, otherwise the global ones
+func (c *Config) HostLimits(name string) Limits {
+	limits := c.Limits
+	host := c.Host(name)
+	if host.MaxRunning > 0 {
+		limits.MaxRunning = host.MaxRunning
+	}
+	if host.MaxQueueDepth > 0 {
+		limits.MaxQueueDepth = host.MaxQueueDepth
+	}
+	if host.MinFreeDiskGB > 0 {
+		limits.MinFreeDiskGB = host.MinFreeDiskGB
+	}
+	if host.MinFreeInodesPct > 0 {
+		limits.MinFreeInodesPct = host.MinFreeInodesPct
+	}
+	return limits
+}
+
+// HostDeadJobs returns how many consecutive checks, over how long, must find
+// a job on a host dead before it is marked dead: the host's settings where
+// set, otherwise the global ones, otherwise the defaults
+func (c *Config) HostDeadJobs(name string) (probes int, grace time.Duration) {
+	probes, minutes := DefaultDeadProbes, DefaultDeadGraceMinutes
+	for _, d := range []DeadJobs{c.DeadJobs, c.Host(name).DeadJobs} {
+		if d.Probes != nil {
+			probes = *d.Probes
+		}
+		if d.GraceMinutes != nil {
+			minutes = *d.GraceMinutes
+		}
 	}
-	configPath = filepath.Join(home, ".config", "remote-jobs", "config.yaml")
+	return probes, time.Duration(minutes) * time.Minute
+}
+
+// configPath is the config file Load reads, if set with SetPath
+var configPath string
+
+// SetPath makes Load read the config file at path instead of the one in the
+// user's config directory
+func SetPath(path string) {
+	configPath = path
 }
 
-// ConfigPath returns the path to the config file
+// ConfigPath returns the path to the config file: the one set with SetPath,
+// otherwise config.yaml in the user's config directory (see the xdg
+// package), or "" if there is no home directory
 func ConfigPath() string {
-	return configPath
+	if configPath != "" {
+		return configPath
+	}
+	if dir := xdg.ConfigDir(); dir != "" {
+		return filepath.Join(dir, "config.yaml")
+	}
+	return ""
 }
 
 // Load reads the config file, returning defaults if it doesn't exist
 func Load() (*Config, error) {
 	cfg := DefaultConfig()
 
-	if configPath == "" {
+	path := ConfigPath()
+	if path == "" {
 		return cfg, nil
 	}
 
-	data, err := os.ReadFile(configPath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return cfg, nil
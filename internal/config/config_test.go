@@ -0,0 +1,357 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHostLimits(t *testing.T) {
+	data := `
+limits:
+  max_running: 4
+  max_queue_depth: 50
+  min_free_disk_gb: 20
+hosts:
+  cool30:
+    max_running: 8
+    min_free_disk_gb: 100
+  cool100:
+    pre_start: source ~/venv/bin/activate
+    max_queue_depth: 10
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want Limits
+	}{
+		{"cool30", Limits{MaxRunning: 8, MaxQueueDepth: 50, MinFreeDiskGB: 100}},
+		{"cool100", Limits{MaxRunning: 4, MaxQueueDepth: 10, MinFreeDiskGB: 20}},
+		{"other", Limits{MaxRunning: 4, MaxQueueDepth: 50, MinFreeDiskGB: 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := cfg.HostLimits(tt.host); got != tt.want {
+				t.Errorf("HostLimits(%q) = %+v, want %+v", tt.host, got, tt.want)
+			}
+		})
+	}
+
+	if got := cfg.Host("cool100").PreStart; got != "source ~/venv/bin/activate" {
+		t.Errorf("cool100 pre_start = %q", got)
+	}
+}
+
+func TestHostDeadJobs(t *testing.T) {
+	data := `
+dead_jobs:
+  grace_minutes: 5
+hosts:
+  flaky:
+    dead_jobs:
+      grace_minutes: 15
+      probes: 4
+  local:
+    dead_jobs:
+      grace_minutes: 0
+      probes: 1
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host   string
+		probes int
+		grace  time.Duration
+	}{
+		{"flaky", 4, 15 * time.Minute},
+		{"local", 1, 0},
+		{"other", DefaultDeadProbes, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if probes, grace := cfg.HostDeadJobs(tt.host); probes != tt.probes || grace != tt.grace {
+			t.Errorf("HostDeadJobs(%q) = %d, %v; want %d, %v", tt.host, probes, grace, tt.probes, tt.grace)
+		}
+	}
+
+	if probes, grace := DefaultConfig().HostDeadJobs("any"); probes != DefaultDeadProbes || grace != DefaultDeadGraceMinutes*time.Minute {
+		t.Errorf("default HostDeadJobs = %d, %v", probes, grace)
+	}
+}
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{MaxRunning: 2, MaxQueueDepth: 3}
+
+	if err := limits.CheckRunning("cool30", 1); err != nil {
+		t.Errorf("CheckRunning(1) = %v, want nil", err)
+	}
+	if err := limits.CheckRunning("cool30", 2); err == nil {
+		t.Error("CheckRunning(2) = nil, want error")
+	}
+	if err := limits.CheckQueueDepth("cool30", "default", 2); err != nil {
+		t.Errorf("CheckQueueDepth(2) = %v, want nil", err)
+	}
+	if err := limits.CheckQueueDepth("cool30", "default", 3); err == nil {
+		t.Error("CheckQueueDepth(3) = nil, want error")
+	}
+
+	var none Limits
+	if err := none.CheckRunning("cool30", 1000); err != nil {
+		t.Errorf("zero limits CheckRunning = %v, want nil", err)
+	}
+}
+
+func TestWatchdogAction(t *testing.T) {
+	tests := []struct {
+		action  string
+		want    string
+		wantErr bool
+	}{
+		{"", WatchdogWarn, false},
+		{"notify", WatchdogNotify, false},
+		{"kill", WatchdogKill, false},
+		{"restart", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Watchdog{IdleGPUMinutes: 30, Action: tt.action}.EffectiveAction()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("EffectiveAction(%q) = %q, %v; want %q (error: %v)", tt.action, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNotifierFailuresOnly(t *testing.T) {
+	tests := []struct {
+		notify  string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"all", false, false},
+		{"failures", true, false},
+		{"none", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Notifier{Notify: tt.notify}.NotifiesFailuresOnly()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NotifiesFailuresOnly(%q) = %v, %v; want %v (error: %v)", tt.notify, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestOpenDirTemplate(t *testing.T) {
+	data := `
+open_dir: sftp
+hosts:
+  cool30:
+    open_dir: cursor
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.OpenDirTemplate("cool30"); got != "cursor" {
+		t.Errorf("OpenDirTemplate(cool30) = %q, want cursor", got)
+	}
+	if got := cfg.OpenDirTemplate("other"); got != "sftp" {
+		t.Errorf("OpenDirTemplate(other) = %q, want sftp", got)
+	}
+}
+
+func TestHostPathMappings(t *testing.T) {
+	data := `
+path_mappings:
+  - local: /Users/me/code
+    remote: /mnt/code
+hosts:
+  cool30:
+    path_mappings:
+      - local: /Users/me/code
+        remote: /scratch/me/code
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.HostPathMappings("cool30"); len(got) != 2 || got[0].Remote != "/scratch/me/code" {
+		t.Errorf("HostPathMappings(cool30) = %+v", got)
+	}
+	if got := cfg.HostPathMappings("other"); len(got) != 1 || got[0].Remote != "/mnt/code" {
+		t.Errorf("HostPathMappings(other) = %+v", got)
+	}
+}
+
+func TestHostAvailability(t *testing.T) {
+	data := `
+hosts:
+  office1:
+    availability:
+      windows: ["mon-fri 20:00-08:00", "sat,sun"]
+      time_zone: Europe/Paris
+  broken:
+    availability:
+      windows: ["20:00"]
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	s, err := cfg.HostAvailability("office1")
+	if err != nil || len(s.Windows) != 2 || s.Location.String() != "Europe/Paris" {
+		t.Errorf("HostAvailability(office1) = %+v, %v", s, err)
+	}
+	if s, err := cfg.HostAvailability("other"); err != nil || !s.IsZero() {
+		t.Errorf("HostAvailability(other) = %+v, %v; want always available", s, err)
+	}
+	if _, err := cfg.HostAvailability("broken"); err == nil {
+		t.Error("HostAvailability(broken) succeeded, want an error")
+	}
+}
+
+func TestDatabaseMaintenance(t *testing.T) {
+	var m DatabaseMaintenance
+	if got := m.BackupInterval(); got != 24*time.Hour {
+		t.Errorf("default BackupInterval() = %v, want 24h", got)
+	}
+	if got := m.GCInterval(); got != 7*24*time.Hour {
+		t.Errorf("default GCInterval() = %v, want 168h", got)
+	}
+	if got := m.Keep(); got != DefaultKeepBackups {
+		t.Errorf("default Keep() = %d, want %d", got, DefaultKeepBackups)
+	}
+
+	data := `
+database:
+  backup_interval_hours: 6
+  keep_backups: 3
+  gc_interval_days: -1
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	m = cfg.Database
+	if got := m.BackupInterval(); got != 6*time.Hour {
+		t.Errorf("BackupInterval() = %v, want 6h", got)
+	}
+	if got := m.GCInterval(); got != 0 {
+		t.Errorf("GCInterval() = %v, want 0 (off)", got)
+	}
+	if got := m.Keep(); got != 3 {
+		t.Errorf("Keep() = %d, want 3", got)
+	}
+}
+
+func TestHostCacheHome(t *testing.T) {
+	data := `
+cache_home: /scratch/alice
+hosts:
+  cool30:
+    cache_home: ~/big
+  cool100:
+    pre_start: source ~/venv/bin/activate
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	for host, want := range map[string]string{"cool30": "~/big", "cool100": "/scratch/alice", "other": "/scratch/alice"} {
+		if got := cfg.HostCacheHome(host); got != want {
+			t.Errorf("HostCacheHome(%q) = %q, want %q", host, got, want)
+		}
+	}
+	if got := DefaultConfig().HostCacheHome("cool30"); got != "" {
+		t.Errorf("default HostCacheHome() = %q, want the default", got)
+	}
+}
+
+func TestHostEnv(t *testing.T) {
+	data := `
+hosts:
+  gpu1:
+    env:
+      HF_HOME: /mnt/cache
+      CUDA_MODULE_LOADING: LAZY
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	got := cfg.HostEnv("gpu1", []string{"HF_HOME=/tmp/hf", "SEED=1"})
+	want := []string{"CUDA_MODULE_LOADING=LAZY", "HF_HOME=/tmp/hf", "SEED=1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("HostEnv(gpu1) = %v, want %v", got, want)
+	}
+	if got := cfg.HostEnv("other", []string{"SEED=1"}); !slices.Equal(got, []string{"SEED=1"}) {
+		t.Errorf("HostEnv(other) = %v", got)
+	}
+
+	own := cfg.WithoutHostEnv("gpu1", []string{"CUDA_MODULE_LOADING=LAZY", "HF_HOME=/tmp/hf", "SEED=1"})
+	if want := []string{"HF_HOME=/tmp/hf", "SEED=1"}; !slices.Equal(own, want) {
+		t.Errorf("WithoutHostEnv(gpu1) = %v, want %v", own, want)
+	}
+}
+
+func TestRemoteHooks(t *testing.T) {
+	data := `
+hosts:
+  gpu1:
+    pre_start: module load cuda
+    post_finish: rm -rf /tmp/scratch
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if pre, post := cfg.RemoteHooks("gpu1", "", ""); pre != "module load cuda" || post != "rm -rf /tmp/scratch" {
+		t.Errorf("RemoteHooks(gpu1) = %q, %q; want the host's", pre, post)
+	}
+	if pre, post := cfg.RemoteHooks("gpu1", "nvidia-smi", ""); pre != "nvidia-smi" || post != "rm -rf /tmp/scratch" {
+		t.Errorf("RemoteHooks(gpu1, nvidia-smi) = %q, %q; want the job's pre-start", pre, post)
+	}
+	if pre, post := cfg.RemoteHooks("other", "", ""); pre != "" || post != "" {
+		t.Errorf("RemoteHooks(other) = %q, %q; want none", pre, post)
+	}
+}
+
+func TestHostUser(t *testing.T) {
+	data := `
+hosts:
+  gpu1:
+    user: alice
+    cache_home: /scratch/alice
+  bob@gpu1:
+    cache_home: /scratch/bob
+`
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.Host("gpu1").User; got != "alice" {
+		t.Errorf("Host(gpu1).User = %q, want alice", got)
+	}
+	if got := cfg.HostCacheHome("bob@gpu1"); got != "/scratch/bob" {
+		t.Errorf("HostCacheHome(bob@gpu1) = %q, want its own", got)
+	}
+	// A user@host name without settings of its own has those of the host
+	if got := cfg.HostCacheHome("carol@gpu1"); got != "/scratch/alice" {
+		t.Errorf("HostCacheHome(carol@gpu1) = %q, want gpu1's", got)
+	}
+	if got := cfg.Host("carol@other"); got.User != "" || got.CacheHome != "" {
+		t.Errorf("Host(carol@other) = %+v, want zero values", got)
+	}
+}
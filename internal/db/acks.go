@@ -0,0 +1,51 @@
+package db
+
+import "database/sql"
+
+// AckJob records that the user has acknowledged a job's failure or idle-GPU
+// flag at now, so that it no longer counts as an alert. A failure or flag
+// that comes later alerts again.
+func AckJob(db *sql.DB, jobID int64, now int64) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO job_acks (job_id, acked_at) VALUES (?, ?)`, jobID, now)
+	return err
+}
+
+// UnackJob removes a job's acknowledgment. It returns false if the job
+// wasn't acknowledged.
+func UnackJob(db *sql.DB, jobID int64) (bool, error) {
+	result, err := db.Exec(`DELETE FROM job_acks WHERE job_id = ?`, jobID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AckHost records that the user has acknowledged a host's incidents (see
+// HostEvent) at now, so that those up to then no longer count as alerts
+func AckHost(db *sql.DB, host string, now int64) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO host_acks (host, acked_at) VALUES (?, ?)`, host, now)
+	return err
+}
+
+// UnackHost removes a host's acknowledgment. It returns false if the host
+// wasn't acknowledged.
+func UnackHost(db *sql.DB, host string) (bool, error) {
+	result, err := db.Exec(`DELETE FROM host_acks WHERE host = ?`, host)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// HostAckedAt returns when the user last acknowledged a host's incidents, or
+// 0 if never
+func HostAckedAt(db *sql.DB, host string) (int64, error) {
+	var ackedAt int64
+	err := db.QueryRow(`SELECT acked_at FROM host_acks WHERE host = ?`, host).Scan(&ackedAt)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return ackedAt, err
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Artifact is an output file of a finished job, resolved from the globs it
+// declared with --artifact
+type Artifact struct {
+	Path string // Absolute path on the job's host
+	Name string // Path relative to the job's working directory, or base name if outside it
+	Size int64  // Bytes
+}
+
+// ArtifactSpec is a finished job whose artifact globs haven't been resolved
+type ArtifactSpec struct {
+	JobID int64
+	Globs []string
+}
+
+// SetArtifactGlobs records the globs a job's artifacts are resolved from
+// when it finishes
+func SetArtifactGlobs(db *sql.DB, jobID int64, globs []string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_artifact_globs (job_id, globs, resolved_at) VALUES (?, ?, 0)`,
+		jobID, strings.Join(globs, "\n"),
+	)
+	return err
+}
+
+// GetArtifactGlobs returns a job's artifact globs, and whether they've been
+// resolved
+func GetArtifactGlobs(db *sql.DB, jobID int64) ([]string, bool, error) {
+	var globs string
+	var resolvedAt int64
+	err := db.QueryRow(
+		`SELECT globs, resolved_at FROM job_artifact_globs WHERE job_id = ?`, jobID,
+	).Scan(&globs, &resolvedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return strings.Split(globs, "\n"), resolvedAt > 0, nil
+}
+
+// ListUnresolvedArtifacts returns the finished jobs on a host whose artifact
+// globs haven't been resolved
+func ListUnresolvedArtifacts(db *sql.DB, host string) ([]ArtifactSpec, error) {
+	rows, err := db.Query(
+		`SELECT g.job_id, g.globs FROM job_artifact_globs g JOIN jobs j ON j.id = g.job_id
+		 WHERE g.resolved_at = 0 AND j.host = ? AND j.status IN (?, ?)
+		 ORDER BY g.job_id`,
+		host, StatusCompleted, StatusDead,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var specs []ArtifactSpec
+	for rows.Next() {
+		var spec ArtifactSpec
+		var globs string
+		if err := rows.Scan(&spec.JobID, &globs); err != nil {
+			return nil, err
+		}
+		spec.Globs = strings.Split(globs, "\n")
+		specs = append(specs, spec)
+	}
+	return specs, rows.Err()
+}
+
+// RecordArtifacts replaces a job's artifacts and marks its globs resolved
+func RecordArtifacts(db *sql.DB, jobID int64, artifacts []Artifact) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM job_artifacts WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+	for _, a := range artifacts {
+		if _, err := tx.Exec(
+			`INSERT OR REPLACE INTO job_artifacts (job_id, path, name, size) VALUES (?, ?, ?, ?)`,
+			jobID, a.Path, a.Name, a.Size,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		`UPDATE job_artifact_globs SET resolved_at = ? WHERE job_id = ?`, time.Now().Unix(), jobID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetArtifacts returns a job's recorded artifacts, ordered by name
+func GetArtifacts(db *sql.DB, jobID int64) ([]Artifact, error) {
+	rows, err := db.Query(`SELECT path, name, size FROM job_artifacts WHERE job_id = ? ORDER BY name`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artifacts []Artifact
+	for rows.Next() {
+		var a Artifact
+		if err := rows.Scan(&a.Path, &a.Name, &a.Size); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
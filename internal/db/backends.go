@@ -0,0 +1,26 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// SetJobBackend records what keeps a job running on its host ("tmux" or
+// "nohup")
+func SetJobBackend(db *sql.DB, jobID int64, backend string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_backends (job_id, backend) VALUES (?, ?)`,
+		jobID, backend,
+	)
+	return err
+}
+
+// GetJobBackend returns the backend recorded for a job, or "" if none was
+// recorded, which means the job runs in tmux
+func GetJobBackend(db *sql.DB, jobID int64) (string, error) {
+	var backend string
+	err := db.QueryRow(`SELECT backend FROM job_backends WHERE job_id = ?`, jobID).Scan(&backend)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return backend, err
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// SetHostCacheHome records the cache_home a host's files were moved under.
+// An empty cacheHome is the default.
+func SetHostCacheHome(db *sql.DB, host, cacheHome string) error {
+	if cacheHome == "" {
+		_, err := db.Exec(`DELETE FROM host_cache_homes WHERE host = ?`, host)
+		return err
+	}
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO host_cache_homes (host, cache_home) VALUES (?, ?)`, host, cacheHome,
+	)
+	return err
+}
+
+// GetHostCacheHome returns the cache_home a host's files were last moved
+// under, or "" for the default
+func GetHostCacheHome(db *sql.DB, host string) (string, error) {
+	var cacheHome string
+	err := db.QueryRow(`SELECT cache_home FROM host_cache_homes WHERE host = ?`, host).Scan(&cacheHome)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return cacheHome, err
+}
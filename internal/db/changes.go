@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"slices"
+)
+
+// JobChanges are the changes to the jobs table after some revision of it,
+// so that a list of jobs can be brought up to date without reloading it
+type JobChanges struct {
+	Changed  []*Job  // Jobs added or updated since, newest first
+	Removed  []int64 // IDs of the jobs deleted since
+	Revision int64   // Revision the changes bring a list up to
+}
+
+// JobsRevision returns the current revision of the jobs table. It increases
+// whenever a job is added, updated, or deleted, or its experiment changes.
+func JobsRevision(db *sql.DB) (int64, error) {
+	var revision int64
+	err := db.QueryRow(`SELECT COALESCE(MAX(revision), 0) FROM job_revisions`).Scan(&revision)
+	return revision, err
+}
+
+// ListJobChanges returns the changes to the jobs table after revision since
+func ListJobChanges(db *sql.DB, since int64) (*JobChanges, error) {
+	// Read the revision first: changes made while the jobs are read are
+	// reported again next time rather than missed
+	revision, err := JobsRevision(db)
+	if err != nil {
+		return nil, err
+	}
+	changes := &JobChanges{Revision: revision}
+	if revision <= since {
+		return changes, nil
+	}
+
+	changes.Changed, err = queryJobs(db,
+		`SELECT j.id, j.host, j.session_name, j.working_dir, j.command, j.description, j.start_time, j.end_time, j.exit_code, j.status, j.error_message, j.queue_name, j.remote_user
+		 FROM job_revisions r JOIN jobs j ON j.id = r.job_id
+		 WHERE r.revision > ? ORDER BY j.id DESC`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		`SELECT r.job_id FROM job_revisions r
+		 WHERE r.revision > ? AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.id = r.job_id)`,
+		since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		changes.Removed = append(changes.Removed, id)
+	}
+	return changes, rows.Err()
+}
+
+// Empty reports whether no job changed
+func (c *JobChanges) Empty() bool {
+	return len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+// IDs returns the IDs of the changed and removed jobs
+func (c *JobChanges) IDs() []int64 {
+	ids := make([]int64, 0, len(c.Changed)+len(c.Removed))
+	for _, job := range c.Changed {
+		ids = append(ids, job.ID)
+	}
+	return append(ids, c.Removed...)
+}
+
+// Apply returns jobs, a list of the most recent jobs newest first as
+// ListJobs returns them, with the changes made: changed jobs replace those
+// with their IDs, new ones are added in order, and removed ones are left
+// out. The list is cut to limit jobs, and jobs older than the oldest listed
+// aren't added to a full list, which ListJobs wouldn't have included. Nor
+// do older jobs take the places of removed ones in a full list, so a list
+// from which jobs were removed has fewer than ListJobs would return.
+// Jobs that are unchanged keep their pointers.
+func (c *JobChanges) Apply(jobs []*Job, limit int) []*Job {
+	changed := make(map[int64]*Job, len(c.Changed))
+	for _, job := range c.Changed {
+		changed[job.ID] = job
+	}
+	removed := make(map[int64]bool, len(c.Removed))
+	for _, id := range c.Removed {
+		removed[id] = true
+	}
+
+	result := make([]*Job, 0, len(jobs)+len(c.Changed))
+	listed := make(map[int64]bool, len(jobs))
+	for _, job := range jobs {
+		listed[job.ID] = true
+		if removed[job.ID] {
+			continue
+		}
+		if newer := changed[job.ID]; newer != nil {
+			job = newer
+		}
+		result = append(result, job)
+	}
+	full := len(jobs) >= limit
+	oldest := int64(0)
+	if len(jobs) > 0 {
+		oldest = jobs[len(jobs)-1].ID
+	}
+	added := false
+	for _, job := range c.Changed {
+		if listed[job.ID] || (full && job.ID < oldest) {
+			continue
+		}
+		result = append(result, job)
+		added = true
+	}
+	if added {
+		slices.SortStableFunc(result, func(a, b *Job) int {
+			switch {
+			case a.ID > b.ID:
+				return -1
+			case a.ID < b.ID:
+				return 1
+			}
+			return 0
+		})
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// RecordJobClock records the local and remote clocks when a job started, and
+// the host's time zone offset from UTC in seconds
+func RecordJobClock(db *sql.DB, jobID, localStart, remoteStart int64, utcOffset int) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_clocks (job_id, local_start, remote_start, remote_utc_offset) VALUES (?, ?, ?, ?)`,
+		jobID, localStart, remoteStart, utcOffset,
+	)
+	return err
+}
+
+// JobClockSkew returns how far the host's clock was ahead of the local clock
+// when the job started, in seconds. ok is false if it wasn't measured.
+func JobClockSkew(db *sql.DB, jobID int64) (skew int64, ok bool, err error) {
+	err = db.QueryRow(
+		`SELECT remote_start - local_start FROM job_clocks WHERE job_id = ?`, jobID,
+	).Scan(&skew)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return skew, err == nil, err
+}
+
+// HostClockSkew returns the clock skew measured at the most recent job start
+// on host, in seconds. ok is false if it was never measured.
+func HostClockSkew(db *sql.DB, host string) (skew int64, ok bool, err error) {
+	err = db.QueryRow(
+		`SELECT c.remote_start - c.local_start FROM job_clocks c
+		 JOIN jobs j ON j.id = c.job_id
+		 WHERE j.host = ? ORDER BY c.job_id DESC LIMIT 1`, host,
+	).Scan(&skew)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return skew, err == nil, err
+}
+
+// JobUTCOffset returns the host's time zone offset from UTC, in seconds, when
+// the job started, or nil if it wasn't recorded
+func JobUTCOffset(db *sql.DB, jobID int64) (*int, error) {
+	var offset sql.NullInt64
+	err := db.QueryRow(
+		`SELECT remote_utc_offset FROM job_clocks WHERE job_id = ?`, jobID,
+	).Scan(&offset)
+	if err == sql.ErrNoRows || (err == nil && !offset.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := int(offset.Int64)
+	return &result, nil
+}
@@ -0,0 +1,405 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/dbcrypt"
+	"github.com/osteele/remote-jobs/internal/xdg"
+)
+
+// EncryptedPath returns the path of the encrypted database, beside the one
+// Open opens
+func EncryptedPath() string {
+	return Path() + ".enc"
+}
+
+// Encrypted reports whether the database is encrypted at rest
+func Encrypted() bool {
+	_, err := os.Stat(EncryptedPath())
+	return err == nil
+}
+
+// WorkingPath returns where the decrypted copy of an encrypted database is
+// kept while it is in use: a file in the user's runtime directory named for
+// the encrypted database, so that databases chosen with --db don't share one
+func WorkingPath(sealed string) (string, error) {
+	dir, err := xdg.RuntimeDir()
+	if err != nil {
+		return "", fmt.Errorf("create runtime dir: %w", err)
+	}
+	sum := sha256.Sum256([]byte(sealed))
+	return filepath.Join(dir, "jobs-"+hex.EncodeToString(sum[:8])+".db"), nil
+}
+
+// openSealed holds the encrypted databases this process has open, for
+// Reseal and CloseAll
+var (
+	openSealedMu sync.Mutex
+	openSealed   = make(map[*sql.DB]*sealingConnector)
+)
+
+// OpenEncrypted opens the encrypted database at sealed through its
+// decrypted copy at working, which is made from it if it doesn't exist.
+// Closing the database rewrites the encrypted one from the copy, and the
+// last process to close it removes the copy.
+func OpenEncrypted(sealed, working string, key []byte) (*sql.DB, error) {
+	// Each process using the copy holds a shared lock on the file beside
+	// it, so the last one can tell that it is the last
+	lock, err := os.OpenFile(working+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("lock database: %w", err)
+	}
+	if err := lockShared(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("lock database: %w", err)
+	}
+	if err := unsealWorkingCopy(sealed, working, key); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	// Borrow the registered driver, which modernc.org/sqlite doesn't export
+	// by another name
+	probe, err := sql.Open("sqlite", working)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	drv := probe.Driver()
+	probe.Close()
+	connector := &sealingConnector{driver: drv, working: working, sealed: sealed, key: key, lock: lock, done: make(chan struct{})}
+	db, err := withSchema(sql.OpenDB(connector))
+	if err != nil {
+		return nil, err
+	}
+	openSealedMu.Lock()
+	openSealed[db] = connector
+	openSealedMu.Unlock()
+	connector.stamp = changeStamp(working)
+	go connector.resealPeriodically()
+	return db, nil
+}
+
+// Reseal rewrites the encrypted databases this process has open from their
+// decrypted copies, leaving them open
+func Reseal() {
+	openSealedMu.Lock()
+	defer openSealedMu.Unlock()
+	for _, c := range openSealed {
+		c.reseal(true)
+	}
+}
+
+// CloseAll closes the encrypted databases this process has open, which
+// reseals them, for use before the process exits without running deferred
+// calls
+func CloseAll() {
+	openSealedMu.Lock()
+	dbs := make([]*sql.DB, 0, len(openSealed))
+	for db := range openSealed {
+		dbs = append(dbs, db)
+	}
+	openSealedMu.Unlock()
+	for _, db := range dbs {
+		db.Close()
+	}
+}
+
+// unsealWorkingCopy decrypts sealed to working unless a copy is already
+// there. Another process decrypting it at the same time uses whichever copy
+// is linked into place first.
+func unsealWorkingCopy(sealed, working string, key []byte) error {
+	if _, err := os.Stat(working); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(sealed)
+	if err != nil {
+		return fmt.Errorf("read encrypted database: %w", err)
+	}
+	plain, err := dbcrypt.Unseal(data, key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(working), filepath.Base(working)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(plain); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(tmp.Name(), working); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// resealInterval is how often an open encrypted database is resealed if its
+// decrypted copy has changed. The copy is usually on a tmpfs, so a process
+// that runs for long, like the TUI, would otherwise lose its changes to a
+// power cut.
+const resealInterval = 5 * time.Minute
+
+// sealingConnector connects to the decrypted copy of an encrypted database,
+// and reseals the copy while the database is open and when it is closed
+type sealingConnector struct {
+	driver  driver.Driver
+	working string
+	sealed  string
+	key     []byte
+	lock    *os.File      // Holds the shared lock on the copy until Close
+	done    chan struct{} // Closed by Close to stop resealing
+
+	mu    sync.Mutex // Held while resealing
+	stamp string     // changeStamp of the copy when it was last resealed
+}
+
+func (c *sealingConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.working)
+}
+
+func (c *sealingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close is called by sql.DB.Close once its connections are closed. The
+// last process using the copy removes it once it is resealed.
+func (c *sealingConnector) Close() error {
+	openSealedMu.Lock()
+	for db, connector := range openSealed {
+		if connector == c {
+			delete(openSealed, db)
+		}
+	}
+	openSealedMu.Unlock()
+	close(c.done)
+	defer c.lock.Close()
+
+	last, _ := tryLockExclusive(c.lock)
+	if err := c.reseal(true); err != nil {
+		return err
+	}
+	if last {
+		return removeDBFiles(c.working)
+	}
+	return nil
+}
+
+// resealPeriodically reseals the database every resealInterval until it is
+// closed
+func (c *sealingConnector) resealPeriodically() {
+	ticker := time.NewTicker(resealInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.reseal(false)
+		}
+	}
+}
+
+// reseal rewrites the encrypted database from the decrypted copy, unless
+// force is false and the copy hasn't changed since it was last resealed
+func (c *sealingConnector) reseal(force bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stamp := changeStamp(c.working)
+	if !force && stamp == c.stamp {
+		return nil
+	}
+	if err := reseal(c.working, c.sealed, c.key); err != nil {
+		return err
+	}
+	c.stamp = stamp
+	return nil
+}
+
+// changeStamp returns a string that changes when the database at path or
+// its write-ahead log is written to
+func changeStamp(path string) string {
+	var stamp string
+	for _, p := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(p); err == nil {
+			stamp += fmt.Sprintf("%d:%d;", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return stamp
+}
+
+// reseal rewrites the encrypted database from a consistent snapshot of its
+// decrypted copy, which other processes may be writing to
+func reseal(working, sealed string, key []byte) error {
+	snapshot := working + ".snapshot"
+	tmp, err := os.CreateTemp(filepath.Dir(working), filepath.Base(snapshot)+"*")
+	if err != nil {
+		return err
+	}
+	snapshot = tmp.Name()
+	tmp.Close()
+	os.Remove(snapshot) // VACUUM INTO needs a path with no file
+	defer os.Remove(snapshot)
+
+	copyDB, err := sql.Open("sqlite", working)
+	if err != nil {
+		return err
+	}
+	_, err = copyDB.Exec(`VACUUM INTO ?`, snapshot)
+	copyDB.Close()
+	if err != nil {
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+	if err := dbcrypt.SealFile(snapshot, sealed, key); err != nil {
+		return fmt.Errorf("encrypt database: %w", err)
+	}
+	return nil
+}
+
+// Encrypt encrypts the database with key, replacing it with the encrypted
+// database and its decrypted copy. The database mustn't be open elsewhere.
+func Encrypt(key []byte) error {
+	if Encrypted() {
+		return fmt.Errorf("the database is already encrypted")
+	}
+	plain, err := Open()
+	if err != nil {
+		return err
+	}
+	plain.Close()
+	working, err := WorkingPath(EncryptedPath())
+	if err != nil {
+		return err
+	}
+	os.Remove(working)
+	if err := reseal(Path(), EncryptedPath(), key); err != nil {
+		return err
+	}
+	return removeDBFiles(Path())
+}
+
+// EncryptBackups encrypts the backups in dir that were made before the
+// database was encrypted, returning how many it encrypted
+func EncryptBackups(dir string, key []byte) (int, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, b := range backups {
+		if dbcrypt.IsSealedFile(b.Path) {
+			continue
+		}
+		if err := dbcrypt.SealFile(b.Path, b.Path, key); err != nil {
+			return n, fmt.Errorf("encrypt %s: %w", b.Path, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Decrypt replaces the encrypted database with a decrypted one. The database
+// mustn't be open elsewhere.
+func Decrypt(key []byte) error {
+	if !Encrypted() {
+		return fmt.Errorf("the database isn't encrypted")
+	}
+	working, err := WorkingPath(EncryptedPath())
+	if err != nil {
+		return err
+	}
+	lock, err := lockExclusive(working)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := unsealWorkingCopy(EncryptedPath(), working, key); err != nil {
+		return err
+	}
+	// Write the database from a snapshot of the copy, which may be newer
+	// than the encrypted database if a command was interrupted
+	snapshot := Path() + ".decrypt"
+	os.Remove(snapshot)
+	copyDB, err := sql.Open("sqlite", working)
+	if err != nil {
+		return err
+	}
+	_, err = copyDB.Exec(`VACUUM INTO ?`, snapshot)
+	copyDB.Close()
+	if err != nil {
+		os.Remove(snapshot)
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+	if err := os.Rename(snapshot, Path()); err != nil {
+		os.Remove(snapshot)
+		return err
+	}
+	if err := os.Remove(EncryptedPath()); err != nil {
+		return err
+	}
+	return removeDBFiles(working)
+}
+
+// Lock reseals an encrypted database and removes its decrypted copy, left
+// by a process that didn't close the database. It fails if the database is
+// open elsewhere.
+func Lock(key []byte) error {
+	working, err := WorkingPath(EncryptedPath())
+	if err != nil {
+		return err
+	}
+	lock, err := lockExclusive(working)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if _, err := os.Stat(working); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := reseal(working, EncryptedPath(), key); err != nil {
+		return err
+	}
+	return removeDBFiles(working)
+}
+
+// lockExclusive locks the decrypted copy at working against processes
+// opening it, failing if one has it open. Closing the file unlocks it.
+func lockExclusive(working string) (*os.File, error) {
+	lock, err := os.OpenFile(working+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("lock database: %w", err)
+	}
+	ok, err := tryLockExclusive(lock)
+	if err != nil || !ok {
+		lock.Close()
+		if err != nil {
+			return nil, fmt.Errorf("lock database: %w", err)
+		}
+		return nil, fmt.Errorf("the database is open in another remote-jobs process; close it first")
+	}
+	return lock, nil
+}
+
+// removeDBFiles removes a database file and SQLite's files beside it
+func removeDBFiles(path string) error {
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/osteele/remote-jobs/internal/dbcrypt"
+	"github.com/osteele/remote-jobs/internal/xdg"
 	_ "modernc.org/sqlite"
 )
 
@@ -21,61 +24,98 @@ type Job struct {
 	Description  string
 	ErrorMessage string
 	QueueName    string // Name of the queue this job belongs to (empty for non-queued jobs)
+	RemoteUser   string // Account the job runs as on its host; "" if SSH chose it or it wasn't recorded
 	StartTime    int64
 	EndTime      *int64
 	ExitCode     *int
-	Status       string
+	Status       Status
 }
 
-// StatusStarting indicates a job is being set up
-const StatusStarting = "starting"
-
-// StatusRunning indicates a job is currently running
-const StatusRunning = "running"
-
-// StatusCompleted indicates a job finished (check exit code)
-const StatusCompleted = "completed"
-
-// StatusDead indicates a job terminated unexpectedly
-const StatusDead = "dead"
-
-// StatusPending indicates a job queued but not started (for --queue flag)
-const StatusPending = "pending"
-
-// StatusQueued indicates a job queued for sequential execution
-const StatusQueued = "queued"
-
-// StatusFailed indicates a job failed to start
-const StatusFailed = "failed"
-
+// dbPath is the database Open opens, if set with SetPath
 var dbPath string
 
-func init() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
+// SetPath makes Open use the database at path instead of the one in the
+// user's config directory
+func SetPath(path string) {
+	dbPath = path
+}
+
+// Path returns the path of the database Open opens: the one set with
+// SetPath, otherwise jobs.db in the user's config directory (see the xdg
+// package). It is "" if there is neither, as when there is no home
+// directory.
+func Path() string {
+	if dbPath != "" {
+		return dbPath
+	}
+	if dir := xdg.ConfigDir(); dir != "" {
+		return filepath.Join(dir, "jobs.db")
 	}
-	dbPath = filepath.Join(home, ".config", "remote-jobs", "jobs.db")
+	return ""
 }
 
-// Open opens the database, creating it if necessary
+// Open opens the database, creating it if necessary, through its decrypted
+// copy if it is encrypted (see OpenEncrypted)
 func Open() (*sql.DB, error) {
+	path := Path()
+	if path == "" {
+		return nil, fmt.Errorf("open database: no home directory to keep it in (use --db)")
+	}
+	if Encrypted() {
+		key, err := dbcrypt.Key()
+		if err != nil {
+			return nil, err
+		}
+		working, err := WorkingPath(EncryptedPath())
+		if err != nil {
+			return nil, err
+		}
+		return OpenEncrypted(EncryptedPath(), working, key)
+	}
+	return OpenAt(path)
+}
+
+// OpenAt opens the database at path, creating it if necessary
+func OpenAt(path string) (*sql.DB, error) {
 	// Ensure directory exists
-	dir := filepath.Dir(dbPath)
+	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("create config dir: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
+	return withSchema(db)
+}
+
+// memoryDBs numbers the databases OpenMemory creates, so that each is new
+var memoryDBs atomic.Int64
 
+// OpenMemory opens a new, empty database that is kept in memory, for tests.
+// It lasts until it is closed.
+func OpenMemory() (*sql.DB, error) {
+	// Each connection to :memory: would have a database of its own, so
+	// the pool's connections share a named one
+	name := fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", memoryDBs.Add(1))
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	// The database is freed when its last connection closes, so keep one
+	// open however long the pool is idle
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+	return withSchema(db)
+}
+
+// withSchema creates or migrates the tables of a newly opened database
+func withSchema(db *sql.DB) (*sql.DB, error) {
 	if err := initSchema(db); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("init schema: %w", err)
 	}
-
 	return db, nil
 }
 
@@ -117,6 +157,20 @@ func initSchema(db *sql.DB) error {
 		return err
 	}
 
+	// Migration: add local completion hook columns
+	_, _ = db.Exec(`ALTER TABLE jobs ADD COLUMN on_success TEXT`)
+	_, _ = db.Exec(`ALTER TABLE jobs ADD COLUMN on_failure TEXT`)
+	_, _ = db.Exec(`ALTER TABLE jobs ADD COLUMN hooks_fired INTEGER NOT NULL DEFAULT 0`)
+	// Ignore errors - columns may already exist
+
+	// Migration: record when each job was created, for queue wait times
+	_, _ = db.Exec(`ALTER TABLE jobs ADD COLUMN created_at INTEGER`)
+	// Ignore error - column may already exist
+
+	// Migration: record the account each job runs as on its host
+	_, _ = db.Exec(`ALTER TABLE jobs ADD COLUMN remote_user TEXT`)
+	// Ignore error - column may already exist
+
 	// Create hosts table for caching static host information
 	hostsSchema := `
 	CREATE TABLE IF NOT EXISTS hosts (
@@ -135,6 +189,10 @@ func initSchema(db *sql.DB) error {
 	if _, err := db.Exec(hostsSchema); err != nil {
 		return err
 	}
+	// Add free disk space to tables created before it was recorded
+	_, _ = db.Exec(`ALTER TABLE hosts ADD COLUMN disk_avail_kb INTEGER`)
+	// Add tool versions to tables created before they were probed
+	_, _ = db.Exec(`ALTER TABLE hosts ADD COLUMN tools_json TEXT`)
 
 	// Create deferred_operations table for operations pending on unreachable hosts
 	deferredOpsSchema := `
@@ -153,6 +211,537 @@ func initSchema(db *sql.DB) error {
 		return err
 	}
 
+	// Create job_resources table for the last sampled memory/GPU usage of jobs
+	resourcesSchema := `
+	CREATE TABLE IF NOT EXISTS job_resources (
+		job_id INTEGER PRIMARY KEY,
+		memory_rss TEXT,
+		gpu_memory TEXT,
+		sampled_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(resourcesSchema); err != nil {
+		return err
+	}
+
+	// Create job_pauses table for suspended jobs and what preempted them
+	pausesSchema := `
+	CREATE TABLE IF NOT EXISTS job_pauses (
+		job_id INTEGER PRIMARY KEY,
+		paused_at INTEGER NOT NULL,
+		preempted_by INTEGER
+	);
+	`
+	if _, err := db.Exec(pausesSchema); err != nil {
+		return err
+	}
+
+	// Create job_gpu_idle table for running jobs whose GPUs are sitting idle
+	gpuIdleSchema := `
+	CREATE TABLE IF NOT EXISTS job_gpu_idle (
+		job_id INTEGER PRIMARY KEY,
+		idle_since INTEGER NOT NULL,
+		flagged_at INTEGER
+	);
+	`
+	if _, err := db.Exec(gpuIdleSchema); err != nil {
+		return err
+	}
+
+	// Create job_acks table for failed or flagged jobs the user has acknowledged
+	acksSchema := `
+	CREATE TABLE IF NOT EXISTS job_acks (
+		job_id INTEGER PRIMARY KEY,
+		acked_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(acksSchema); err != nil {
+		return err
+	}
+
+	// Create host_events table for host-level incidents (Xid errors, thermal throttling, OOM kills)
+	hostEventsSchema := `
+	CREATE TABLE IF NOT EXISTS host_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		host TEXT NOT NULL,
+		event_time INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		message TEXT NOT NULL,
+		UNIQUE(host, event_time, message)
+	);
+	CREATE INDEX IF NOT EXISTS idx_host_events_host ON host_events(host, event_time);
+	`
+	if _, err := db.Exec(hostEventsSchema); err != nil {
+		return err
+	}
+
+	// Create host_acks table for hosts whose incidents the user has acknowledged
+	hostAcksSchema := `
+	CREATE TABLE IF NOT EXISTS host_acks (
+		host TEXT PRIMARY KEY,
+		acked_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(hostAcksSchema); err != nil {
+		return err
+	}
+
+	// Create job_scripts table for the scripts run by `run --script` or from stdin
+	scriptsSchema := `
+	CREATE TABLE IF NOT EXISTS job_scripts (
+		job_id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		content TEXT NOT NULL
+	);
+	`
+	if _, err := db.Exec(scriptsSchema); err != nil {
+		return err
+	}
+
+	// Create job_clocks table for the local and remote clocks at job start
+	clocksSchema := `
+	CREATE TABLE IF NOT EXISTS job_clocks (
+		job_id INTEGER PRIMARY KEY,
+		local_start INTEGER NOT NULL,
+		remote_start INTEGER NOT NULL,
+		remote_utc_offset INTEGER
+	);
+	`
+	if _, err := db.Exec(clocksSchema); err != nil {
+		return err
+	}
+	// Add the host's time zone to tables created before it was recorded
+	_, _ = db.Exec(`ALTER TABLE job_clocks ADD COLUMN remote_utc_offset INTEGER`)
+
+	// Create job_tags table for labels set with `run --tag`
+	tagsSchema := `
+	CREATE TABLE IF NOT EXISTS job_tags (
+		job_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (job_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_tags_tag ON job_tags(tag);
+	`
+	if _, err := db.Exec(tagsSchema); err != nil {
+		return err
+	}
+
+	// Create job_needs table for the resources declared with `run --needs`
+	// and the host tags required and avoided with --require and --avoid
+	needsSchema := `
+	CREATE TABLE IF NOT EXISTS job_needs (
+		job_id INTEGER PRIMARY KEY,
+		needs TEXT NOT NULL,
+		require_tags TEXT NOT NULL DEFAULT '',
+		avoid_tags TEXT NOT NULL DEFAULT ''
+	);
+	`
+	if _, err := db.Exec(needsSchema); err != nil {
+		return err
+	}
+	// Add host tag constraints to tables created before they were recorded
+	_, _ = db.Exec(`ALTER TABLE job_needs ADD COLUMN require_tags TEXT NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE job_needs ADD COLUMN avoid_tags TEXT NOT NULL DEFAULT ''`)
+
+	// Create job_notes table for observations attached with `note`
+	notesSchema := `
+	CREATE TABLE IF NOT EXISTS job_notes (
+		job_id INTEGER PRIMARY KEY,
+		note TEXT NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(notesSchema); err != nil {
+		return err
+	}
+
+	// Create host_reachability table for the result of the last SSH attempt on each host
+	reachabilitySchema := `
+	CREATE TABLE IF NOT EXISTS host_reachability (
+		host TEXT PRIMARY KEY,
+		reachable INTEGER NOT NULL,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		checked_at INTEGER NOT NULL,
+		retry_at INTEGER NOT NULL DEFAULT 0
+	);
+	`
+	if _, err := db.Exec(reachabilitySchema); err != nil {
+		return err
+	}
+
+	// Create job_dependencies table for what jobs queued with --after wait for
+	dependenciesSchema := `
+	CREATE TABLE IF NOT EXISTS job_dependencies (
+		job_id INTEGER PRIMARY KEY,
+		after_job_id INTEGER NOT NULL,
+		after_any INTEGER NOT NULL DEFAULT 0
+	);
+	`
+	if _, err := db.Exec(dependenciesSchema); err != nil {
+		return err
+	}
+
+	// Create pending_dependencies table for jobs held locally until a job on
+	// another host finishes
+	pendingDependenciesSchema := `
+	CREATE TABLE IF NOT EXISTS pending_dependencies (
+		job_id INTEGER PRIMARY KEY,
+		after_job_id INTEGER NOT NULL,
+		after_any INTEGER NOT NULL DEFAULT 0,
+		env_vars_b64 TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(pendingDependenciesSchema); err != nil {
+		return err
+	}
+
+	// Create job_guards table for the conditions set with --if
+	guardsSchema := `
+	CREATE TABLE IF NOT EXISTS job_guards (
+		job_id INTEGER PRIMARY KEY,
+		command TEXT NOT NULL,
+		if_false TEXT NOT NULL
+	);
+	`
+	if _, err := db.Exec(guardsSchema); err != nil {
+		return err
+	}
+
+	// Create job_queue_settings table for the run settings of queued jobs,
+	// which the queue runner applies when it starts them
+	queueSettingsSchema := `
+	CREATE TABLE IF NOT EXISTS job_queue_settings (
+		job_id INTEGER PRIMARY KEY,
+		timeout TEXT NOT NULL DEFAULT '',
+		slack_notify TEXT NOT NULL DEFAULT '',
+		slack_min_duration TEXT NOT NULL DEFAULT '',
+		slack_verbose INTEGER NOT NULL DEFAULT 0,
+		pre_start TEXT NOT NULL DEFAULT '',
+		post_finish TEXT NOT NULL DEFAULT ''
+	);
+	`
+	if _, err := db.Exec(queueSettingsSchema); err != nil {
+		return err
+	}
+	// Add remote hooks to tables created before they were recorded
+	_, _ = db.Exec(`ALTER TABLE job_queue_settings ADD COLUMN pre_start TEXT NOT NULL DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE job_queue_settings ADD COLUMN post_finish TEXT NOT NULL DEFAULT ''`)
+
+	// Create tables for the output files declared with --artifact: the globs
+	// to resolve when the job finishes, and the files they resolved to
+	artifactsSchema := `
+	CREATE TABLE IF NOT EXISTS job_artifact_globs (
+		job_id INTEGER PRIMARY KEY,
+		globs TEXT NOT NULL,
+		resolved_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS job_artifacts (
+		job_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		name TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		PRIMARY KEY (job_id, path)
+	);
+	`
+	if _, err := db.Exec(artifactsSchema); err != nil {
+		return err
+	}
+
+	// Create tables for the metrics declared with --result and
+	// --results-file: where to read them from when the job finishes, and the
+	// values read
+	resultsSchema := `
+	CREATE TABLE IF NOT EXISTS job_result_specs (
+		job_id INTEGER PRIMARY KEY,
+		patterns TEXT NOT NULL,
+		file TEXT NOT NULL,
+		resolved_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS job_results (
+		job_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		value REAL NOT NULL,
+		PRIMARY KEY (job_id, name)
+	);
+	`
+	if _, err := db.Exec(resultsSchema); err != nil {
+		return err
+	}
+
+	// Create tables for what a job ran with, so runs can be compared: its
+	// environment variables, the names of the secrets it was given, and the
+	// git commit of its working directory
+	provenanceSchema := `
+	CREATE TABLE IF NOT EXISTS job_env (
+		job_id INTEGER PRIMARY KEY,
+		env_vars TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS job_secrets (
+		job_id INTEGER PRIMARY KEY,
+		names TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS job_git (
+		job_id INTEGER PRIMARY KEY,
+		git_commit TEXT NOT NULL,
+		dirty INTEGER NOT NULL DEFAULT 0
+	);
+	`
+	if _, err := db.Exec(provenanceSchema); err != nil {
+		return err
+	}
+
+	// Create shell_sessions table for interactive tmux sessions started with
+	// the shell command, which are tracked separately from jobs
+	shellSessionsSchema := `
+	CREATE TABLE IF NOT EXISTS shell_sessions (
+		host TEXT NOT NULL,
+		name TEXT NOT NULL,
+		working_dir TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		last_attached_at INTEGER NOT NULL,
+		ended_at INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (host, name)
+	);
+	`
+	if _, err := db.Exec(shellSessionsSchema); err != nil {
+		return err
+	}
+
+	// Create job_backends table for jobs run without tmux, which are probed
+	// and killed through their PID files
+	backendsSchema := `
+	CREATE TABLE IF NOT EXISTS job_backends (
+		job_id INTEGER PRIMARY KEY,
+		backend TEXT NOT NULL
+	);
+	`
+	if _, err := db.Exec(backendsSchema); err != nil {
+		return err
+	}
+
+	// Create events table for changes to jobs' statuses and hosts' reachability
+	eventsSchema := `
+	CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		at INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		job_id INTEGER,
+		host TEXT NOT NULL,
+		from_state TEXT NOT NULL,
+		to_state TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_events_job ON events(job_id);
+	CREATE INDEX IF NOT EXISTS idx_events_at ON events(at);
+	`
+	if _, err := db.Exec(eventsSchema); err != nil {
+		return err
+	}
+
+	// Create webhook_cursor table for the last event sent to webhooks
+	webhookCursorSchema := `
+	CREATE TABLE IF NOT EXISTS webhook_cursor (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		last_event_id INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(webhookCursorSchema); err != nil {
+		return err
+	}
+
+	// Create tables for Slack notifiers: notifier_cursor for the last event
+	// they have seen, notifications for the finished jobs each has yet to
+	// send, and notifier_sends for when each sent messages, to limit their rate
+	notifierSchema := `
+	CREATE TABLE IF NOT EXISTS notifier_cursor (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		last_event_id INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS notifications (
+		notifier TEXT NOT NULL,
+		job_id INTEGER NOT NULL,
+		queued_at INTEGER NOT NULL,
+		PRIMARY KEY (notifier, job_id)
+	);
+	CREATE TABLE IF NOT EXISTS notifier_sends (
+		notifier TEXT NOT NULL,
+		sent_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifier_sends ON notifier_sends(notifier, sent_at);
+	`
+	if _, err := db.Exec(notifierSchema); err != nil {
+		return err
+	}
+
+	// Create job_reconciliations table for the evidence behind status changes
+	// made by checking a job's host, such as marking it dead
+	reconciliationsSchema := `
+	CREATE TABLE IF NOT EXISTS job_reconciliations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_id INTEGER NOT NULL,
+		checked_at INTEGER NOT NULL,
+		source TEXT NOT NULL,
+		from_status TEXT NOT NULL,
+		to_status TEXT NOT NULL,
+		evidence TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_reconciliations_job ON job_reconciliations(job_id);
+	`
+	if _, err := db.Exec(reconciliationsSchema); err != nil {
+		return err
+	}
+
+	// Create job_dead_probes table for jobs that recent checks found dead but
+	// that haven't been marked dead yet
+	deadProbesSchema := `
+	CREATE TABLE IF NOT EXISTS job_dead_probes (
+		job_id INTEGER PRIMARY KEY,
+		first_at INTEGER NOT NULL,
+		last_at INTEGER NOT NULL,
+		probes INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(deadProbesSchema); err != nil {
+		return err
+	}
+
+	// Create job_gpus table for the GPUs assigned to jobs with `run --gpus`
+	gpusSchema := `
+	CREATE TABLE IF NOT EXISTS job_gpus (
+		job_id INTEGER PRIMARY KEY,
+		gpus TEXT NOT NULL
+	);
+	`
+	if _, err := db.Exec(gpusSchema); err != nil {
+		return err
+	}
+
+	// Create job_resume table for the commands declared with `run --resume-cmd`,
+	// and job_migrations for the jobs `migrate` started in place of others
+	lineageSchema := `
+	CREATE TABLE IF NOT EXISTS job_resume (
+		job_id INTEGER PRIMARY KEY,
+		command TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS job_migrations (
+		job_id INTEGER PRIMARY KEY,
+		from_job_id INTEGER NOT NULL,
+		from_host TEXT NOT NULL,
+		checkpoint TEXT NOT NULL,
+		migrated_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_migrations_from ON job_migrations(from_job_id);
+	`
+	if _, err := db.Exec(lineageSchema); err != nil {
+		return err
+	}
+
+	// Create host_tags table for the tags given to hosts by `host add`, kept
+	// apart from the hosts cache so that refreshing a host's info keeps them
+	hostTagsSchema := `
+	CREATE TABLE IF NOT EXISTS host_tags (
+		host TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (host, tag)
+	);
+	`
+	if _, err := db.Exec(hostTagsSchema); err != nil {
+		return err
+	}
+
+	// Create host_latency table for the rolling average SSH round-trip time
+	// of each host
+	latencySchema := `
+	CREATE TABLE IF NOT EXISTS host_latency (
+		host TEXT PRIMARY KEY,
+		avg_ms REAL NOT NULL,
+		last_ms REAL NOT NULL,
+		samples INTEGER NOT NULL,
+		measured_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(latencySchema); err != nil {
+		return err
+	}
+
+	// Create experiments table for named groups of jobs, and job_experiments
+	// for the experiment each job belongs to
+	experimentsSchema := `
+	CREATE TABLE IF NOT EXISTS experiments (
+		name TEXT PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS job_experiments (
+		job_id INTEGER PRIMARY KEY,
+		experiment TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_experiments_experiment ON job_experiments(experiment);
+	`
+	if _, err := db.Exec(experimentsSchema); err != nil {
+		return err
+	}
+
+	// Create job_revisions table for the revision at which each job was last
+	// added, updated, deleted, or moved to an experiment, kept by triggers so
+	// that every writer records its changes
+	revisionsSchema := `
+	CREATE TABLE IF NOT EXISTS job_revisions (
+		job_id INTEGER PRIMARY KEY,
+		revision INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_revisions_revision ON job_revisions(revision);
+	CREATE TRIGGER IF NOT EXISTS jobs_revise_insert AFTER INSERT ON jobs BEGIN
+		INSERT OR REPLACE INTO job_revisions (job_id, revision)
+		VALUES (NEW.id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM job_revisions));
+	END;
+	CREATE TRIGGER IF NOT EXISTS jobs_revise_update AFTER UPDATE ON jobs BEGIN
+		INSERT OR REPLACE INTO job_revisions (job_id, revision)
+		VALUES (NEW.id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM job_revisions));
+	END;
+	CREATE TRIGGER IF NOT EXISTS jobs_revise_delete AFTER DELETE ON jobs BEGIN
+		INSERT OR REPLACE INTO job_revisions (job_id, revision)
+		VALUES (OLD.id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM job_revisions));
+	END;
+	CREATE TRIGGER IF NOT EXISTS job_experiments_revise_insert AFTER INSERT ON job_experiments BEGIN
+		INSERT OR REPLACE INTO job_revisions (job_id, revision)
+		VALUES (NEW.job_id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM job_revisions));
+	END;
+	CREATE TRIGGER IF NOT EXISTS job_experiments_revise_delete AFTER DELETE ON job_experiments BEGIN
+		INSERT OR REPLACE INTO job_revisions (job_id, revision)
+		VALUES (OLD.job_id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM job_revisions));
+	END;
+	`
+	if _, err := db.Exec(revisionsSchema); err != nil {
+		return err
+	}
+
+	// Create host_cache_homes table for the cache_home each host's files
+	// were last moved under, so that a changed setting is noticed
+	cacheHomesSchema := `
+	CREATE TABLE IF NOT EXISTS host_cache_homes (
+		host TEXT PRIMARY KEY,
+		cache_home TEXT NOT NULL
+	);
+	`
+	if _, err := db.Exec(cacheHomesSchema); err != nil {
+		return err
+	}
+
+	// Create maintenance table for when each maintenance task, such as
+	// GC, last ran
+	maintenanceSchema := `
+	CREATE TABLE IF NOT EXISTS maintenance (
+		task TEXT PRIMARY KEY,
+		last_run INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(maintenanceSchema); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -213,7 +802,7 @@ func migrateStartTimeNullable(db *sql.DB) error {
 			queue_name TEXT
 		)`,
 		`INSERT INTO jobs_new SELECT id, host, session_name, working_dir, command, description,
-			start_time, end_time, exit_code, status, error_message, queue_name FROM jobs`,
+			start_time, end_time, exit_code, status, error_message, queue_name, remote_user FROM jobs`,
 		`DROP TABLE jobs`,
 		`ALTER TABLE jobs_new RENAME TO jobs`,
 		`CREATE INDEX idx_jobs_host ON jobs(host)`,
@@ -234,38 +823,35 @@ func migrateStartTimeNullable(db *sql.DB) error {
 // RecordStart records a new job start and returns its ID
 // Deprecated: Use RecordJobStarting + UpdateJobRunning for new jobs
 func RecordStart(db *sql.DB, host, sessionName, workingDir, command string, startTime int64, description string) (int64, error) {
-	result, err := db.Exec(
-		`INSERT INTO jobs (host, session_name, working_dir, command, description, start_time, status)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		host, sessionName, workingDir, command, description, startTime, StatusRunning,
+	return insertJob(db, StatusRunning,
+		`host, session_name, working_dir, command, description, start_time, created_at`,
+		host, sessionName, workingDir, command, description, startTime, time.Now().Unix(),
 	)
-	if err != nil {
-		return 0, err
-	}
-	return result.LastInsertId()
 }
 
 // RecordJobStarting creates a new job with status="starting" and returns its ID
 // This allows getting the job ID before starting the tmux session
 func RecordJobStarting(db *sql.DB, host, workingDir, command, description string) (int64, error) {
 	startTime := time.Now().Unix()
-	result, err := db.Exec(
-		`INSERT INTO jobs (host, session_name, working_dir, command, description, start_time, status)
-		 VALUES (?, NULL, ?, ?, ?, ?, ?)`,
-		host, workingDir, command, description, startTime, StatusStarting,
+	return insertJob(db, StatusStarting,
+		`host, working_dir, command, description, start_time, created_at`,
+		host, workingDir, command, description, startTime, startTime,
 	)
-	if err != nil {
-		return 0, err
-	}
-	return result.LastInsertId()
+}
+
+// NextJobID returns the ID the next inserted job is expected to get.
+// Used for dry runs, which show file paths without creating a job record.
+func NextJobID(db *sql.DB) (int64, error) {
+	var id int64
+	err := db.QueryRow(
+		`SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'jobs'), 0) + 1`,
+	).Scan(&id)
+	return id, err
 }
 
 // UpdateJobRunning transitions a starting job to running
 func UpdateJobRunning(db *sql.DB, id int64) error {
-	_, err := db.Exec(
-		`UPDATE jobs SET status = ? WHERE id = ? AND status = ?`,
-		StatusRunning, id, StatusStarting,
-	)
+	_, err := transitionJob(db, id, []Status{StatusStarting}, StatusRunning, "")
 	return err
 }
 
@@ -273,19 +859,14 @@ func UpdateJobRunning(db *sql.DB, id int64) error {
 func UpdateJobFailed(db *sql.DB, id int64, errorMsg string) error {
 	endTime := time.Now().Unix()
 	// Store error in error_message column (not description) for debugging
-	_, err := db.Exec(
-		`UPDATE jobs SET status = ?, end_time = ?, error_message = ? WHERE id = ? AND status = ?`,
-		StatusFailed, endTime, errorMsg, id, StatusStarting,
-	)
+	_, err := transitionJob(db, id, []Status{StatusStarting}, StatusFailed,
+		"end_time = ?, error_message = ?", endTime, errorMsg)
 	return err
 }
 
 // UpdateJobPending converts a starting job to pending status (for --queue-on-fail)
 func UpdateJobPending(db *sql.DB, id int64) error {
-	_, err := db.Exec(
-		`UPDATE jobs SET status = ? WHERE id = ? AND status = ?`,
-		StatusPending, id, StatusStarting,
-	)
+	_, err := transitionJob(db, id, []Status{StatusStarting}, StatusPending, "")
 	return err
 }
 
@@ -307,59 +888,92 @@ func UpdateJobHost(db *sql.DB, id int64, newHost string) error {
 	return err
 }
 
-// RecordCompletionByID updates a job by ID with its exit code and end time
-func RecordCompletionByID(db *sql.DB, id int64, exitCode int, endTime int64) error {
+// SetJobRemoteUser records the account a job runs as on its host; "" means
+// the one SSH chooses
+func SetJobRemoteUser(db *sql.DB, id int64, user string) error {
 	_, err := db.Exec(
-		`UPDATE jobs SET exit_code = ?, end_time = ?, status = ?
-		 WHERE id = ? AND status IN (?, ?)`,
-		exitCode, endTime, StatusCompleted, id, StatusRunning, StatusQueued,
+		`UPDATE jobs SET remote_user = NULLIF(?, '') WHERE id = ?`,
+		user, id,
 	)
 	return err
 }
 
-// MarkDeadByID marks a running or queued job as dead by ID
+// JobRemoteUser returns the account a job runs as on its host, or "" if it
+// wasn't recorded or SSH chose it
+func JobRemoteUser(db *sql.DB, id int64) (string, error) {
+	var user sql.NullString
+	err := db.QueryRow(`SELECT remote_user FROM jobs WHERE id = ?`, id).Scan(&user)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return user.String, err
+}
+
+// RecordCompletionByID updates a job by ID with its exit code and end time.
+// ExitSkipped also records why the job didn't run.
+func RecordCompletionByID(db *sql.DB, id int64, exitCode int, endTime int64) error {
+	if exitCode == ExitSkipped {
+		_, err := transitionJob(db, id, []Status{StatusRunning, StatusQueued, StatusPaused}, StatusCompleted,
+			"exit_code = ?, end_time = ?, error_message = ?", exitCode, endTime, skippedMessage)
+		return err
+	}
+	_, err := transitionJob(db, id, []Status{StatusRunning, StatusQueued, StatusPaused}, StatusCompleted,
+		"exit_code = ?, end_time = ?", exitCode, endTime)
+	return err
+}
+
+// MarkDeadByID marks a running, paused, or queued job as dead by ID
 func MarkDeadByID(db *sql.DB, id int64) error {
-	endTime := time.Now().Unix()
-	_, err := db.Exec(
-		`UPDATE jobs SET end_time = ?, status = ?
-		 WHERE id = ? AND status IN (?, ?)`,
-		endTime, StatusDead, id, StatusRunning, StatusQueued,
-	)
+	_, err := transitionJob(db, id, []Status{StatusRunning, StatusQueued, StatusPaused}, StatusDead,
+		"end_time = ?", time.Now().Unix())
 	return err
 }
 
 // RecordPending records a pending job and returns its ID
 func RecordPending(db *sql.DB, host, workingDir, command, description string) (int64, error) {
 	startTime := time.Now().Unix()
-	result, err := db.Exec(
-		`INSERT INTO jobs (host, session_name, working_dir, command, description, start_time, status)
-		 VALUES (?, NULL, ?, ?, ?, ?, ?)`,
-		host, workingDir, command, description, startTime, StatusPending,
+	return insertJob(db, StatusPending,
+		`host, working_dir, command, description, start_time, created_at`,
+		host, workingDir, command, description, startTime, startTime,
 	)
-	if err != nil {
-		return 0, err
-	}
-	return result.LastInsertId()
 }
 
 // RecordQueued records a queued job for sequential execution and returns its ID
 // Note: start_time is NULL until the job actually starts running (set by UpdateQueuedToRunning)
 func RecordQueued(db *sql.DB, host, workingDir, command, description, queueName string) (int64, error) {
-	result, err := db.Exec(
-		`INSERT INTO jobs (host, session_name, working_dir, command, description, start_time, status, queue_name)
-		 VALUES (?, NULL, ?, ?, ?, NULL, ?, ?)`,
-		host, workingDir, command, description, StatusQueued, queueName,
+	return insertJob(db, StatusQueued,
+		`host, working_dir, command, description, queue_name, created_at`,
+		host, workingDir, command, description, queueName, time.Now().Unix(),
 	)
-	if err != nil {
-		return 0, err
-	}
-	return result.LastInsertId()
+}
+
+// RecordHeld records a job that will be added to a queue later, once a job on
+// another host finishes. It has pending status until then.
+func RecordHeld(db *sql.DB, host, workingDir, command, description, queueName string) (int64, error) {
+	return insertJob(db, StatusPending,
+		`host, working_dir, command, description, queue_name, created_at`,
+		host, workingDir, command, description, queueName, time.Now().Unix(),
+	)
+}
+
+// UpdatePendingToQueued transitions a held job to queued once it's been added
+// to its host's queue
+func UpdatePendingToQueued(db *sql.DB, id int64) error {
+	_, err := transitionJob(db, id, []Status{StatusPending}, StatusQueued, "")
+	return err
+}
+
+// MarkPendingFailed marks a held job as failed without starting it
+func MarkPendingFailed(db *sql.DB, id int64, errorMsg string) error {
+	_, err := transitionJob(db, id, []Status{StatusPending}, StatusFailed,
+		"end_time = ?, error_message = ?", time.Now().Unix(), errorMsg)
+	return err
 }
 
 // ListQueued returns queued jobs for a host and queue name
 func ListQueued(db *sql.DB, host, queueName string) ([]*Job, error) {
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE status = ? AND host = ? AND queue_name = ? ORDER BY id ASC`,
 		StatusQueued, host, queueName,
 	)
@@ -367,40 +981,60 @@ func ListQueued(db *sql.DB, host, queueName string) ([]*Job, error) {
 
 // UpdateQueuedToRunning transitions a queued job to running
 func UpdateQueuedToRunning(db *sql.DB, id int64) error {
-	_, err := db.Exec(
-		`UPDATE jobs SET status = ?, start_time = ? WHERE id = ? AND status = ?`,
-		StatusRunning, time.Now().Unix(), id, StatusQueued,
-	)
+	_, err := transitionJob(db, id, []Status{StatusQueued}, StatusRunning,
+		"start_time = ?", time.Now().Unix())
 	return err
 }
 
 // RecordCompletion updates a job with its exit code and end time
 func RecordCompletion(db *sql.DB, host, sessionName string, exitCode int, endTime int64) error {
-	_, err := db.Exec(
-		`UPDATE jobs SET exit_code = ?, end_time = ?, status = ?
-		 WHERE host = ? AND session_name = ? AND status = ?`,
-		exitCode, endTime, StatusCompleted, host, sessionName, StatusRunning,
-	)
+	ids, err := runningSessionJobs(db, host, sessionName)
+	for _, id := range ids {
+		if err == nil {
+			_, err = transitionJob(db, id, []Status{StatusRunning}, StatusCompleted,
+				"exit_code = ?, end_time = ?", exitCode, endTime)
+		}
+	}
 	return err
 }
 
 // MarkDead marks a running job as dead
 func MarkDead(db *sql.DB, host, sessionName string) error {
-	endTime := time.Now().Unix()
-	_, err := db.Exec(
-		`UPDATE jobs SET end_time = ?, status = ?
-		 WHERE host = ? AND session_name = ? AND status = ?`,
-		endTime, StatusDead, host, sessionName, StatusRunning,
-	)
+	ids, err := runningSessionJobs(db, host, sessionName)
+	for _, id := range ids {
+		if err == nil {
+			_, err = transitionJob(db, id, []Status{StatusRunning}, StatusDead,
+				"end_time = ?", time.Now().Unix())
+		}
+	}
 	return err
 }
 
+// runningSessionJobs returns the IDs of the running jobs with a legacy
+// session name
+func runningSessionJobs(db *sql.DB, host, sessionName string) ([]int64, error) {
+	rows, err := db.Query(
+		`SELECT id FROM jobs WHERE host = ? AND session_name = ? AND status = ?`,
+		host, sessionName, StatusRunning,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 // MarkStarted transitions a pending job to running
 func MarkStarted(db *sql.DB, id int64, startTime int64) error {
-	_, err := db.Exec(
-		`UPDATE jobs SET start_time = ?, status = ? WHERE id = ? AND status = ?`,
-		startTime, StatusRunning, id, StatusPending,
-	)
+	_, err := transitionJob(db, id, []Status{StatusPending}, StatusRunning, "start_time = ?", startTime)
 	return err
 }
 
@@ -413,22 +1047,109 @@ func UpdateStartTime(db *sql.DB, id int64, startTime int64) error {
 	return err
 }
 
-// DeletePending deletes a pending job
+// DeletePending deletes a pending job, and everything recorded about it,
+// such as the dependency it was held for
 func DeletePending(db *sql.DB, id int64) error {
-	_, err := db.Exec(`DELETE FROM jobs WHERE id = ? AND status = ?`, id, StatusPending)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`DELETE FROM jobs WHERE id = ? AND status = ?`, id, StatusPending)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	if err := deleteJobRows(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// DeleteJob removes a job from the database without touching remote files
+// DeleteJob removes a job, and everything recorded about it, from the
+// database without touching remote files
 func DeleteJob(db *sql.DB, id int64) error {
-	_, err := db.Exec(`DELETE FROM jobs WHERE id = ?`, id)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM jobs WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := deleteJobRows(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// jobTables hold what is recorded about a job other than its row in jobs,
+// keyed by job_id. The events table isn't one: it is the history of jobs and
+// hosts, and outlives them. Nor is job_revisions, whose rows for deleted
+// jobs are how readers of ListJobChanges learn of their removal.
+var jobTables = []string{
+	"deferred_operations",
+	"job_acks",
+	"job_artifact_globs",
+	"job_artifacts",
+	"job_backends",
+	"job_clocks",
+	"job_dead_probes",
+	"job_dependencies",
+	"job_env",
+	"job_experiments",
+	"job_git",
+	"job_gpu_idle",
+	"job_gpus",
+	"job_guards",
+	"job_migrations",
+	"job_needs",
+	"job_notes",
+	"job_pauses",
+	"job_queue_settings",
+	"job_reconciliations",
+	"job_resources",
+	"job_result_specs",
+	"job_results",
+	"job_resume",
+	"job_scripts",
+	"job_secrets",
+	"job_tags",
+	"notifications",
+	"pending_dependencies",
+}
+
+// deleteJobRows deletes the rows of jobTables about a job
+func deleteJobRows(db execer, id int64) error {
+	for _, table := range jobTables {
+		if _, err := db.Exec(`DELETE FROM `+table+` WHERE job_id = ?`, id); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// deleteOrphans deletes the rows of jobTables whose job is no longer in the
+// database, returning how many it deleted
+func deleteOrphans(db execer) (int64, error) {
+	var total int64
+	for _, table := range jobTables {
+		result, err := db.Exec(`DELETE FROM ` + table + ` WHERE job_id NOT IN (SELECT id FROM jobs)`)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", table, err)
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+	return total, nil
 }
 
 // GetJob retrieves a job by host and session name (most recent)
 func GetJob(db *sql.DB, host, sessionName string) (*Job, error) {
 	row := db.QueryRow(
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE host = ? AND session_name = ? ORDER BY start_time DESC LIMIT 1`,
 		host, sessionName,
 	)
@@ -438,7 +1159,7 @@ func GetJob(db *sql.DB, host, sessionName string) (*Job, error) {
 // GetJobByID retrieves a job by ID
 func GetJobByID(db *sql.DB, id int64) (*Job, error) {
 	row := db.QueryRow(
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE id = ?`,
 		id,
 	)
@@ -448,8 +1169,8 @@ func GetJobByID(db *sql.DB, id int64) (*Job, error) {
 // GetPendingJob retrieves a pending job by ID
 func GetPendingJob(db *sql.DB, id int64) (*Job, error) {
 	row := db.QueryRow(
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
-		 FROM jobs WHERE id = ? AND status = ?`,
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE id = ? AND status = ? AND id NOT IN (SELECT job_id FROM pending_dependencies)`,
 		id, StatusPending,
 	)
 	return scanJob(row)
@@ -458,7 +1179,7 @@ func GetPendingJob(db *sql.DB, id int64) (*Job, error) {
 // GetRunningJobsByHost retrieves all running jobs for a specific host
 func GetRunningJobsByHost(db *sql.DB, host string) ([]*Job, error) {
 	rows, err := db.Query(
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE host = ? AND status = ? ORDER BY start_time DESC`,
 		host, StatusRunning,
 	)
@@ -470,17 +1191,34 @@ func GetRunningJobsByHost(db *sql.DB, host string) ([]*Job, error) {
 	return scanJobs(rows)
 }
 
+// GetActiveJobsByHost retrieves the jobs on a host that are starting or
+// running, newest first
+func GetActiveJobsByHost(db *sql.DB, host string) ([]*Job, error) {
+	rows, err := db.Query(
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE host = ? AND status IN (?, ?) ORDER BY start_time DESC`,
+		host, StatusRunning, StatusStarting,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
+
 func scanJob(row *sql.Row) (*Job, error) {
 	var j Job
 	var sessionName sql.NullString
 	var desc sql.NullString
 	var errorMsg sql.NullString
 	var queueName sql.NullString
+	var remoteUser sql.NullString
 	var startTime sql.NullInt64
 	var endTime sql.NullInt64
 	var exitCode sql.NullInt64
 
-	err := row.Scan(&j.ID, &j.Host, &sessionName, &j.WorkingDir, &j.Command, &desc, &startTime, &endTime, &exitCode, &j.Status, &errorMsg, &queueName)
+	err := row.Scan(&j.ID, &j.Host, &sessionName, &j.WorkingDir, &j.Command, &desc, &startTime, &endTime, &exitCode, &j.Status, &errorMsg, &queueName, &remoteUser)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -500,6 +1238,7 @@ func scanJob(row *sql.Row) (*Job, error) {
 	if queueName.Valid {
 		j.QueueName = queueName.String
 	}
+	j.RemoteUser = remoteUser.String
 	if startTime.Valid {
 		j.StartTime = startTime.Int64
 	}
@@ -523,11 +1262,12 @@ func scanJobs(rows *sql.Rows) ([]*Job, error) {
 		var desc sql.NullString
 		var errorMsg sql.NullString
 		var queueName sql.NullString
+		var remoteUser sql.NullString
 		var startTime sql.NullInt64
 		var endTime sql.NullInt64
 		var exitCode sql.NullInt64
 
-		err := rows.Scan(&j.ID, &j.Host, &sessionName, &j.WorkingDir, &j.Command, &desc, &startTime, &endTime, &exitCode, &j.Status, &errorMsg, &queueName)
+		err := rows.Scan(&j.ID, &j.Host, &sessionName, &j.WorkingDir, &j.Command, &desc, &startTime, &endTime, &exitCode, &j.Status, &errorMsg, &queueName, &remoteUser)
 		if err != nil {
 			return nil, err
 		}
@@ -547,6 +1287,7 @@ func scanJobs(rows *sql.Rows) ([]*Job, error) {
 		if queueName.Valid {
 			j.QueueName = queueName.String
 		}
+		j.RemoteUser = remoteUser.String
 		if endTime.Valid {
 			j.EndTime = &endTime.Int64
 		}
@@ -562,8 +1303,8 @@ func scanJobs(rows *sql.Rows) ([]*Job, error) {
 }
 
 // ListJobs returns jobs matching the given filters
-func ListJobs(db *sql.DB, status, host string, limit int) ([]*Job, error) {
-	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name FROM jobs WHERE 1=1`
+func ListJobs(db *sql.DB, status Status, host string, limit int) ([]*Job, error) {
+	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user FROM jobs WHERE 1=1`
 	args := []interface{}{}
 
 	if status != "" {
@@ -582,9 +1323,11 @@ func ListJobs(db *sql.DB, status, host string, limit int) ([]*Job, error) {
 	return queryJobs(db, query, args...)
 }
 
-// ListPending returns pending jobs, optionally filtered by host
+// ListPending returns pending jobs, optionally filtered by host. Jobs held
+// for a dependency on another host, which are pending too, aren't included,
+// since they wait for that job rather than to be retried.
 func ListPending(db *sql.DB, host string) ([]*Job, error) {
-	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name FROM jobs WHERE status = ?`
+	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user FROM jobs WHERE status = ? AND id NOT IN (SELECT job_id FROM pending_dependencies)`
 	args := []interface{}{StatusPending}
 
 	if host != "" {
@@ -599,16 +1342,45 @@ func ListPending(db *sql.DB, host string) ([]*Job, error) {
 // ListRunning returns running jobs for a host
 func ListRunning(db *sql.DB, host string) ([]*Job, error) {
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE status = ? AND host = ? ORDER BY start_time DESC`,
 		StatusRunning, host,
 	)
 }
 
+// ListPaused returns paused jobs across all hosts
+func ListPaused(db *sql.DB) ([]*Job, error) {
+	return queryJobs(db,
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE status = ? ORDER BY start_time DESC`,
+		StatusPaused,
+	)
+}
+
+// CountActiveJobs returns how many jobs are running or starting on a host
+func CountActiveJobs(db *sql.DB, host string) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM jobs WHERE host = ? AND status IN (?, ?)`,
+		host, StatusRunning, StatusStarting,
+	).Scan(&count)
+	return count, err
+}
+
+// CountQueued returns how many jobs are waiting in a queue on a host
+func CountQueued(db *sql.DB, host, queueName string) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM jobs WHERE host = ? AND queue_name = ? AND status = ?`,
+		host, queueName, StatusQueued,
+	).Scan(&count)
+	return count, err
+}
+
 // ListAllRunning returns all running jobs across all hosts
 func ListAllRunning(db *sql.DB) ([]*Job, error) {
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE status = ? ORDER BY start_time DESC`,
 		StatusRunning,
 	)
@@ -633,9 +1405,9 @@ func ListUniqueRunningHosts(db *sql.DB) ([]string, error) {
 	return hosts, rows.Err()
 }
 
-// ListUniqueActiveHosts returns unique hosts with running or queued jobs
+// ListUniqueActiveHosts returns unique hosts with running, paused, or queued jobs
 func ListUniqueActiveHosts(db *sql.DB) ([]string, error) {
-	rows, err := db.Query(`SELECT DISTINCT host FROM jobs WHERE status IN (?, ?)`, StatusRunning, StatusQueued)
+	rows, err := db.Query(`SELECT DISTINCT host FROM jobs WHERE status IN (?, ?, ?)`, StatusRunning, StatusPaused, StatusQueued)
 	if err != nil {
 		return nil, err
 	}
@@ -671,19 +1443,33 @@ func ListHostsWithQueuedJobs(db *sql.DB) ([]string, error) {
 	return hosts, rows.Err()
 }
 
-// ListActiveJobs returns all running and queued jobs for a host
+// ListActiveJobs returns all running, paused, and queued jobs for a host
 func ListActiveJobs(db *sql.DB, host string) ([]*Job, error) {
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
-		 FROM jobs WHERE host = ? AND status IN (?, ?) ORDER BY start_time ASC`,
-		host, StatusRunning, StatusQueued,
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE host = ? AND status IN (?, ?, ?) ORDER BY start_time ASC`,
+		host, StatusRunning, StatusPaused, StatusQueued,
 	)
 }
 
+// ListUnfinished returns jobs that are starting, running, paused, or queued,
+// oldest first. An empty host lists jobs on every host.
+func ListUnfinished(db *sql.DB, host string) ([]*Job, error) {
+	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE status IN (?, ?, ?, ?)`
+	args := []interface{}{StatusStarting, StatusRunning, StatusPaused, StatusQueued}
+	if host != "" {
+		query += ` AND host = ?`
+		args = append(args, host)
+	}
+	query += ` ORDER BY id ASC`
+	return queryJobs(db, query, args...)
+}
+
 // ListAllQueued returns all queued jobs across all hosts
 func ListAllQueued(db *sql.DB) ([]*Job, error) {
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE status = ? ORDER BY start_time ASC`,
 		StatusQueued,
 	)
@@ -693,21 +1479,12 @@ func ListAllQueued(db *sql.DB) ([]*Job, error) {
 // These should be re-checked in case they were incorrectly marked as dead
 func ListRecentDeadQueueJobs(db *sql.DB, since int64) ([]*Job, error) {
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE status = ? AND session_name IS NULL AND end_time > ? ORDER BY start_time ASC`,
 		StatusDead, since,
 	)
 }
 
-// ReviveDeadJob changes a dead job back to running (for incorrectly marked jobs)
-func ReviveDeadJob(db *sql.DB, id int64) error {
-	_, err := db.Exec(
-		`UPDATE jobs SET status = ?, end_time = NULL WHERE id = ? AND status = ?`,
-		StatusRunning, id, StatusDead,
-	)
-	return err
-}
-
 // ListUniqueHosts returns all unique hosts from all jobs
 func ListUniqueHosts(db *sql.DB) ([]string, error) {
 	rows, err := db.Query(`SELECT DISTINCT host FROM jobs ORDER BY host`)
@@ -731,7 +1508,7 @@ func ListUniqueHosts(db *sql.DB) ([]string, error) {
 func SearchJobs(db *sql.DB, query string, limit int) ([]*Job, error) {
 	pattern := "%" + query + "%"
 	return queryJobs(db,
-		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
 		 FROM jobs WHERE description LIKE ? OR command LIKE ? ORDER BY start_time DESC LIMIT ?`,
 		pattern, pattern, limit,
 	)
@@ -740,41 +1517,50 @@ func SearchJobs(db *sql.DB, query string, limit int) ([]*Job, error) {
 // CleanupOld deletes completed/dead jobs older than the given number of days
 func CleanupOld(db *sql.DB, days int) (int64, error) {
 	cutoff := time.Now().AddDate(0, 0, -days).Unix()
-	result, err := db.Exec(
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(
 		`DELETE FROM jobs WHERE status IN (?, ?) AND start_time < ?`,
 		StatusCompleted, StatusDead, cutoff,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return result.RowsAffected()
+	return deletedJobs(tx, result)
 }
 
 // PruneJobs deletes completed and/or dead jobs, optionally filtered by age
 func PruneJobs(db *sql.DB, deadOnly bool, olderThan *time.Time) (int64, error) {
-	var result sql.Result
-	var err error
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
+	var result sql.Result
 	if deadOnly {
 		if olderThan != nil {
-			result, err = db.Exec(
+			result, err = tx.Exec(
 				`DELETE FROM jobs WHERE status = ? AND start_time < ?`,
 				StatusDead, olderThan.Unix(),
 			)
 		} else {
-			result, err = db.Exec(
+			result, err = tx.Exec(
 				`DELETE FROM jobs WHERE status = ?`,
 				StatusDead,
 			)
 		}
 	} else {
 		if olderThan != nil {
-			result, err = db.Exec(
+			result, err = tx.Exec(
 				`DELETE FROM jobs WHERE status IN (?, ?) AND start_time < ?`,
 				StatusCompleted, StatusDead, olderThan.Unix(),
 			)
 		} else {
-			result, err = db.Exec(
+			result, err = tx.Exec(
 				`DELETE FROM jobs WHERE status IN (?, ?)`,
 				StatusCompleted, StatusDead,
 			)
@@ -783,12 +1569,25 @@ func PruneJobs(db *sql.DB, deadOnly bool, olderThan *time.Time) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return result.RowsAffected()
+	return deletedJobs(tx, result)
+}
+
+// deletedJobs finishes a transaction that deleted jobs, by deleting what was
+// recorded about them, and returns how many it deleted
+func deletedJobs(tx *sql.Tx, result sql.Result) (int64, error) {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := deleteOrphans(tx); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
 }
 
 // ListJobsForPrune returns jobs that would be deleted by prune
 func ListJobsForPrune(db *sql.DB, deadOnly bool, olderThan *time.Time) ([]*Job, error) {
-	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name FROM jobs WHERE `
+	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user FROM jobs WHERE `
 	var args []interface{}
 
 	if deadOnly {
@@ -808,6 +1607,34 @@ func ListJobsForPrune(db *sql.DB, deadOnly bool, olderThan *time.Time) ([]*Job,
 	return queryJobs(db, query, args...)
 }
 
+// ListFinishedJobs returns completed, failed, and dead jobs, newest first
+func ListFinishedJobs(db *sql.DB) ([]*Job, error) {
+	return queryJobs(db,
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE status IN (?, ?, ?) ORDER BY id DESC`,
+		StatusCompleted, StatusFailed, StatusDead,
+	)
+}
+
+// ListFinishedSince returns completed, failed, and dead jobs that ended at or
+// after since, most recently ended first
+func ListFinishedSince(db *sql.DB, since time.Time) ([]*Job, error) {
+	return queryJobs(db,
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE status IN (?, ?, ?) AND end_time >= ? ORDER BY end_time DESC, id DESC`,
+		StatusCompleted, StatusFailed, StatusDead, since.Unix(),
+	)
+}
+
+// ListJobsSince returns jobs started (or, if never started, created) at or after since
+func ListJobsSince(db *sql.DB, since time.Time) ([]*Job, error) {
+	return queryJobs(db,
+		`SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user
+		 FROM jobs WHERE COALESCE(start_time, created_at, 0) >= ? ORDER BY id`,
+		since.Unix(),
+	)
+}
+
 func queryJobs(db *sql.DB, query string, args ...interface{}) ([]*Job, error) {
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -822,11 +1649,12 @@ func queryJobs(db *sql.DB, query string, args ...interface{}) ([]*Job, error) {
 		var desc sql.NullString
 		var errorMsg sql.NullString
 		var queueName sql.NullString
+		var remoteUser sql.NullString
 		var startTime sql.NullInt64
 		var endTime sql.NullInt64
 		var exitCode sql.NullInt64
 
-		err := rows.Scan(&j.ID, &j.Host, &sessionName, &j.WorkingDir, &j.Command, &desc, &startTime, &endTime, &exitCode, &j.Status, &errorMsg, &queueName)
+		err := rows.Scan(&j.ID, &j.Host, &sessionName, &j.WorkingDir, &j.Command, &desc, &startTime, &endTime, &exitCode, &j.Status, &errorMsg, &queueName, &remoteUser)
 		if err != nil {
 			return nil, err
 		}
@@ -843,6 +1671,7 @@ func queryJobs(db *sql.DB, query string, args ...interface{}) ([]*Job, error) {
 		if queueName.Valid {
 			j.QueueName = queueName.String
 		}
+		j.RemoteUser = remoteUser.String
 		if startTime.Valid {
 			j.StartTime = startTime.Int64
 		}
@@ -966,31 +1795,47 @@ type CachedHostInfo struct {
 	CPUModel    string
 	CPUFreq     string
 	MemTotal    string
-	GPUsJSON    string // JSON array of GPU info
-	LastUpdated int64  // Unix timestamp
+	GPUsJSON    string   // JSON array of GPU info
+	DiskAvailKB int64    // Free space on the home directory's filesystem, 0 if unknown
+	ToolsJSON   string   // JSON object of tool versions (see hosttools), "" if not probed
+	LastUpdated int64    // Unix timestamp, 0 for a host added by `host add` and not yet queried
+	Tags        []string // Tags given by `host add`; not saved by SaveCachedHostInfo
+}
+
+// Fetched reports whether the host has been queried, so its info is known
+func (info *CachedHostInfo) Fetched() bool {
+	return info.LastUpdated > 0
 }
 
 // SaveCachedHostInfo saves or updates cached host information
 func SaveCachedHostInfo(db *sql.DB, info *CachedHostInfo) error {
 	_, err := db.Exec(`
-		INSERT OR REPLACE INTO hosts (name, arch, os_version, model, cpu_count, cpu_model, cpu_freq, mem_total, gpus_json, last_updated)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		info.Name, info.Arch, info.OSVersion, info.Model, info.CPUCount, info.CPUModel, info.CPUFreq, info.MemTotal, info.GPUsJSON, info.LastUpdated,
+		INSERT OR REPLACE INTO hosts (name, arch, os_version, model, cpu_count, cpu_model, cpu_freq, mem_total, gpus_json, disk_avail_kb, tools_json, last_updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		info.Name, info.Arch, info.OSVersion, info.Model, info.CPUCount, info.CPUModel, info.CPUFreq, info.MemTotal, info.GPUsJSON, info.DiskAvailKB, info.ToolsJSON, info.LastUpdated,
 	)
 	return err
 }
 
+// SaveCachedHostTools records the tool versions of a host in the cache,
+// leaving its other info as it is. Hosts that aren't in the cache are
+// skipped.
+func SaveCachedHostTools(db *sql.DB, name, toolsJSON string) error {
+	_, err := db.Exec(`UPDATE hosts SET tools_json = ? WHERE name = ?`, toolsJSON, name)
+	return err
+}
+
 // LoadCachedHostInfo retrieves cached host information by name
 func LoadCachedHostInfo(db *sql.DB, name string) (*CachedHostInfo, error) {
 	row := db.QueryRow(`
-		SELECT name, arch, os_version, model, cpu_count, cpu_model, cpu_freq, mem_total, gpus_json, last_updated
+		SELECT name, arch, os_version, model, cpu_count, cpu_model, cpu_freq, mem_total, gpus_json, disk_avail_kb, tools_json, last_updated
 		FROM hosts WHERE name = ?`, name)
 
 	var info CachedHostInfo
-	var arch, osVersion, model, cpuModel, cpuFreq, memTotal, gpusJSON sql.NullString
-	var cpuCount sql.NullInt64
+	var arch, osVersion, model, cpuModel, cpuFreq, memTotal, gpusJSON, toolsJSON sql.NullString
+	var cpuCount, diskAvailKB sql.NullInt64
 
-	err := row.Scan(&info.Name, &arch, &osVersion, &model, &cpuCount, &cpuModel, &cpuFreq, &memTotal, &gpusJSON, &info.LastUpdated)
+	err := row.Scan(&info.Name, &arch, &osVersion, &model, &cpuCount, &cpuModel, &cpuFreq, &memTotal, &gpusJSON, &diskAvailKB, &toolsJSON, &info.LastUpdated)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1022,14 +1867,19 @@ func LoadCachedHostInfo(db *sql.DB, name string) (*CachedHostInfo, error) {
 	if gpusJSON.Valid {
 		info.GPUsJSON = gpusJSON.String
 	}
+	info.DiskAvailKB = diskAvailKB.Int64
+	info.ToolsJSON = toolsJSON.String
 
+	if info.Tags, err = GetHostTags(db, name); err != nil {
+		return nil, err
+	}
 	return &info, nil
 }
 
 // LoadAllCachedHosts retrieves all cached host information
 func LoadAllCachedHosts(db *sql.DB) ([]*CachedHostInfo, error) {
 	rows, err := db.Query(`
-		SELECT name, arch, os_version, model, cpu_count, cpu_model, cpu_freq, mem_total, gpus_json, last_updated
+		SELECT name, arch, os_version, model, cpu_count, cpu_model, cpu_freq, mem_total, gpus_json, disk_avail_kb, tools_json, last_updated
 		FROM hosts ORDER BY name`)
 	if err != nil {
 		return nil, err
@@ -1039,10 +1889,10 @@ func LoadAllCachedHosts(db *sql.DB) ([]*CachedHostInfo, error) {
 	var hosts []*CachedHostInfo
 	for rows.Next() {
 		var info CachedHostInfo
-		var arch, osVersion, model, cpuModel, cpuFreq, memTotal, gpusJSON sql.NullString
-		var cpuCount sql.NullInt64
+		var arch, osVersion, model, cpuModel, cpuFreq, memTotal, gpusJSON, toolsJSON sql.NullString
+		var cpuCount, diskAvailKB sql.NullInt64
 
-		err := rows.Scan(&info.Name, &arch, &osVersion, &model, &cpuCount, &cpuModel, &cpuFreq, &memTotal, &gpusJSON, &info.LastUpdated)
+		err := rows.Scan(&info.Name, &arch, &osVersion, &model, &cpuCount, &cpuModel, &cpuFreq, &memTotal, &gpusJSON, &diskAvailKB, &toolsJSON, &info.LastUpdated)
 		if err != nil {
 			return nil, err
 		}
@@ -1071,33 +1921,24 @@ func LoadAllCachedHosts(db *sql.DB) ([]*CachedHostInfo, error) {
 		if gpusJSON.Valid {
 			info.GPUsJSON = gpusJSON.String
 		}
+		info.DiskAvailKB = diskAvailKB.Int64
+		info.ToolsJSON = toolsJSON.String
 
 		hosts = append(hosts, &info)
 	}
-
-	return hosts, rows.Err()
-}
-
-// FormatDuration formats a duration in human-readable form
-func FormatDuration(seconds int64) string {
-	d := time.Duration(seconds) * time.Second
-	h := d / time.Hour
-	d -= h * time.Hour
-	m := d / time.Minute
-	d -= m * time.Minute
-	s := d / time.Second
-
-	var parts []string
-	if h > 0 {
-		parts = append(parts, fmt.Sprintf("%dh", h))
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	if m > 0 {
-		parts = append(parts, fmt.Sprintf("%dm", m))
+	rows.Close()
+
+	tags, err := allHostTags(db)
+	if err != nil {
+		return nil, err
 	}
-	if s > 0 || len(parts) == 0 {
-		parts = append(parts, fmt.Sprintf("%ds", s))
+	for _, info := range hosts {
+		info.Tags = tags[info.Name]
 	}
-	return strings.Join(parts, " ")
+	return hosts, nil
 }
 
 // DeferredOperation represents an operation pending on an unreachable host
@@ -1112,9 +1953,11 @@ type DeferredOperation struct {
 
 // Operation types for deferred operations
 const (
-	OpKillJob       = "kill_job"
-	OpRemoveQueued  = "remove_queued"
-	OpMoveFromQueue = "move_from_queue"
+	OpKillJob           = "kill_job"
+	OpRemoveQueued      = "remove_queued"
+	OpMoveFromQueue     = "move_from_queue"
+	OpDeleteFiles       = "delete_files"       // Delete a pruned job's log, status, metadata, and pid files
+	OpReleaseDependency = "release_dependency" // Clear a queued job's --after dependency
 )
 
 // AddDeferredOperation adds an operation to execute when host becomes reachable
@@ -1158,6 +2001,25 @@ func GetDeferredOperations(db *sql.DB, host string) ([]*DeferredOperation, error
 	return ops, rows.Err()
 }
 
+// ListHostsWithDeferredOperations returns hosts that have pending deferred operations
+func ListHostsWithDeferredOperations(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT host FROM deferred_operations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, rows.Err()
+}
+
 // DeleteDeferredOperation removes a deferred operation after execution
 func DeleteDeferredOperation(db *sql.DB, id int64) error {
 	_, err := db.Exec(`DELETE FROM deferred_operations WHERE id = ?`, id)
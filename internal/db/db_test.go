@@ -1,6 +1,19 @@
 package db
 
-import "testing"
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/dbcrypt"
+)
 
 func TestParseCdCommand(t *testing.T) {
 	tests := []struct {
@@ -220,32 +233,1164 @@ func TestEffectiveWorkingDir(t *testing.T) {
 	}
 }
 
-func TestFormatDuration(t *testing.T) {
+func TestJobElapsed(t *testing.T) {
+	end := int64(1500)
+	tests := []struct {
+		name string
+		job  Job
+		want int64
+	}{
+		{"running", Job{Status: StatusRunning, StartTime: 1000}, 1000},
+		{"completed", Job{Status: StatusCompleted, StartTime: 1000, EndTime: &end}, 500},
+		{"queued", Job{Status: StatusQueued}, -1},
+		{"pending", Job{Status: StatusPending, StartTime: 1000}, -1},
+		{"dead without end", Job{Status: StatusDead, StartTime: 1000}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.job.Elapsed(2000); got != tt.want {
+				t.Errorf("Elapsed() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueWait(t *testing.T) {
+	stats := &JobStats{QueuedAt: 1000}
+	tests := []struct {
+		name  string
+		job   Job
+		stats *JobStats
+		want  int64
+	}{
+		{"still queued", Job{Status: StatusQueued, QueueName: "default"}, stats, 1000},
+		{"started from queue", Job{Status: StatusRunning, QueueName: "default", StartTime: 1300}, stats, 300},
+		{"not queued", Job{Status: StatusRunning, StartTime: 1300}, stats, -1},
+		{"no stats", Job{Status: StatusQueued, QueueName: "default"}, nil, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.QueueWait(&tt.job, 2000); got != tt.want {
+				t.Errorf("QueueWait() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobAcks(t *testing.T) {
+	end := int64(1500)
+	failed := Job{Status: StatusFailed, StartTime: 1000, EndTime: &end}
+	tests := []struct {
+		name    string
+		stats   *JobStats
+		acked   bool
+		idleGPU bool
+	}{
+		{"no stats", nil, false, false},
+		{"never acked", &JobStats{GPUIdleFlaggedAt: 1200}, false, true},
+		{"acked after ending", &JobStats{GPUIdleFlaggedAt: 1200, AckedAt: 1600}, true, false},
+		{"acked before ending", &JobStats{AckedAt: 1300}, false, false},
+		{"flagged again after ack", &JobStats{GPUIdleFlaggedAt: 1400, AckedAt: 1300}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.Acked(&failed); got != tt.acked {
+				t.Errorf("Acked() = %v, want %v", got, tt.acked)
+			}
+			if got := tt.stats.IdleGPU(); got != tt.idleGPU {
+				t.Errorf("IdleGPU() = %v, want %v", got, tt.idleGPU)
+			}
+		})
+	}
+}
+
+func TestQueueSettingsEncode(t *testing.T) {
+	tests := []QueueSettings{
+		{Timeout: "1h30m"},
+		{Timeout: "45s", SlackNotify: "failures", SlackMinDuration: "5m", SlackVerbose: true},
+		{PreStart: "module load cuda\nnvidia-smi", PostFinish: `echo "exit=$REMOTE_JOBS_EXIT_CODE"`},
+	}
+	for _, s := range tests {
+		got := ParseQueueSettings(s.Encode())
+		if got == nil || *got != s {
+			t.Errorf("ParseQueueSettings(%+v.Encode()) = %+v", s, got)
+		}
+	}
+
+	data, _ := base64.StdEncoding.DecodeString(QueueSettings{Timeout: "1h30m"}.Encode())
+	if want := "timeout=1h30m\ntimeout_seconds=5400"; string(data) != want {
+		t.Errorf("Encode() = %q, want %q", data, want)
+	}
+	data, _ = base64.StdEncoding.DecodeString(QueueSettings{SlackMinDuration: "5m"}.Encode())
+	if want := "slack_min_duration=5m"; string(data) != want {
+		t.Errorf("Encode() = %q, want only %q, so the runner keeps its other Slack settings", data, want)
+	}
+	if got := (QueueSettings{}).Encode(); got != "" {
+		t.Errorf("QueueSettings{}.Encode() = %q, want none", got)
+	}
+	if got := ParseQueueSettings(""); got != nil {
+		t.Errorf("ParseQueueSettings(\"\") = %+v, want nil", got)
+	}
+	for _, timeout := range []string{"", "2h", "1h30m"} {
+		if err := ValidateTimeout(timeout); err != nil {
+			t.Errorf("ValidateTimeout(%q): %v", timeout, err)
+		}
+	}
+	for _, timeout := range []string{"2", "two hours", "-1h"} {
+		if err := ValidateTimeout(timeout); err == nil {
+			t.Errorf("ValidateTimeout(%q) should fail", timeout)
+		}
+	}
+}
+
+func TestParseQueueWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]int
+	}{
+		{"empty", "", map[string]int{}},
+		{"weights", "gpu=3\ncpu=1\n", map[string]int{"gpu": 3, "cpu": 1}},
+		{"first line wins", "gpu=3\ngpu=5", map[string]int{"gpu": 3}},
+		{"zero", "gpu=0", map[string]int{}},
+		{"negative", "gpu=-2", map[string]int{}},
+		{"signed", "gpu=+2", map[string]int{}},
+		{"not a number", "gpu=high\ncpu=2", map[string]int{"cpu": 2}},
+		{"spaces", "gpu = 3\ncpu= 2", map[string]int{}},
+		{"no weight", "gpu=\ngpu", map[string]int{}},
+		{"no name", "=3", map[string]int{}},
+		{"extra field", "gpu=3=4", map[string]int{"gpu": 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseQueueWeights(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQueueWeights(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueWeight(t *testing.T) {
+	weights := ParseQueueWeights("gpu=3\nbad=0")
+	tests := []struct {
+		queue string
+		want  int
+	}{
+		{"gpu", 3},
+		{"bad", 1},
+		{"default", 1},
+	}
+
+	for _, tt := range tests {
+		if got := QueueWeight(weights, tt.queue); got != tt.want {
+			t.Errorf("QueueWeight(%q) = %d, want %d", tt.queue, got, tt.want)
+		}
+	}
+}
+
+func TestHostAcks(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	if at, err := HostAckedAt(database, "cool30"); err != nil || at != 0 {
+		t.Fatalf("HostAckedAt() before ack = %d, %v; want 0", at, err)
+	}
+	if err := AckHost(database, "cool30", 1500); err != nil {
+		t.Fatal(err)
+	}
+	if at, err := HostAckedAt(database, "cool30"); err != nil || at != 1500 {
+		t.Errorf("HostAckedAt() = %d, %v; want 1500", at, err)
+	}
+	if at, _ := HostAckedAt(database, "gpu1"); at != 0 {
+		t.Errorf("HostAckedAt(gpu1) = %d, want 0", at)
+	}
+	if removed, err := UnackHost(database, "cool30"); err != nil || !removed {
+		t.Errorf("UnackHost() = %v, %v; want true", removed, err)
+	}
+	if removed, _ := UnackHost(database, "cool30"); removed {
+		t.Error("UnackHost() of an unacknowledged host = true")
+	}
+}
+
+func TestJobDependencyDescribe(t *testing.T) {
+	zero, one, skipped := 0, 1, ExitSkipped
+	tests := []struct {
+		name     string
+		dep      JobDependency
+		upstream *Job
+		want     string
+	}{
+		{"running", JobDependency{AfterJobID: 42}, &Job{Status: StatusRunning}, "job 42 (on success): running"},
+		{"succeeded", JobDependency{AfterJobID: 42}, &Job{Status: StatusCompleted, ExitCode: &zero}, "job 42 (on success): completed"},
+		{"failed", JobDependency{AfterJobID: 42}, &Job{Status: StatusCompleted, ExitCode: &one}, "job 42 (on success): failed (exit 1), so this job will be skipped"},
+		{"failed any", JobDependency{AfterJobID: 42, AfterAny: true}, &Job{Status: StatusDead}, "job 42 (on any exit): dead"},
+		{"skipped", JobDependency{AfterJobID: 42}, &Job{Status: StatusCompleted, ExitCode: &skipped}, "job 42 (on success): skipped, so this job will be skipped"},
+		{"unknown", JobDependency{AfterJobID: 42}, nil, "job 42 (on success): unknown job"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dep.Describe(tt.upstream); got != tt.want {
+				t.Errorf("Describe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobDependencyResolve(t *testing.T) {
+	zero, one, skipped := 0, 1, ExitSkipped
 	tests := []struct {
-		seconds  int64
-		expected string
+		name     string
+		dep      JobDependency
+		upstream Job
+		wantRun  bool
+		wantSkip bool
 	}{
-		{0, "0s"},
-		{1, "1s"},
-		{59, "59s"},
-		{60, "1m"},
-		{61, "1m 1s"},
-		{119, "1m 59s"},
-		{120, "2m"},
-		{3600, "1h"},
-		{3601, "1h 1s"},
-		{3661, "1h 1m 1s"},
-		{7200, "2h"},
-		{7325, "2h 2m 5s"},
-		{86400, "24h"},
+		{"running", JobDependency{}, Job{Status: StatusRunning}, false, false},
+		{"queued", JobDependency{AfterAny: true}, Job{Status: StatusQueued}, false, false},
+		{"succeeded", JobDependency{}, Job{Status: StatusCompleted, ExitCode: &zero}, true, false},
+		{"failed", JobDependency{}, Job{Status: StatusCompleted, ExitCode: &one}, false, true},
+		{"failed any", JobDependency{AfterAny: true}, Job{Status: StatusCompleted, ExitCode: &one}, true, false},
+		{"dead", JobDependency{}, Job{Status: StatusDead}, false, true},
+		{"skipped", JobDependency{}, Job{Status: StatusCompleted, ExitCode: &skipped}, false, true},
+		{"skipped any", JobDependency{AfterAny: true}, Job{Status: StatusCompleted, ExitCode: &skipped}, true, false},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.expected, func(t *testing.T) {
-			got := FormatDuration(tt.seconds)
-			if got != tt.expected {
-				t.Errorf("FormatDuration(%d) = %q, want %q", tt.seconds, got, tt.expected)
+		t.Run(tt.name, func(t *testing.T) {
+			run, skip := tt.dep.Resolve(&tt.upstream)
+			if run != tt.wantRun || skip != tt.wantSkip {
+				t.Errorf("Resolve() = %v, %v, want %v, %v", run, skip, tt.wantRun, tt.wantSkip)
 			}
 		})
 	}
 }
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		want     bool
+	}{
+		{StatusStarting, StatusRunning, true},
+		{StatusPending, StatusQueued, true},
+		{StatusQueued, StatusRunning, true},
+		{StatusRunning, StatusCompleted, true},
+		{StatusRunning, StatusPaused, true},
+		{StatusPaused, StatusRunning, true},
+		{StatusDead, StatusRunning, true},
+		{StatusCompleted, StatusRunning, false},
+		{StatusFailed, StatusRunning, false},
+		{StatusQueued, StatusPaused, false},
+		{StatusRunning, StatusPending, false},
+		{StatusRunning, StatusRunning, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for _, status := range Statuses {
+		if got, err := ParseStatus(" " + strings.ToUpper(string(status))); err != nil || got != status {
+			t.Errorf("ParseStatus(%q) = %q, %v", status, got, err)
+		}
+	}
+	if _, err := ParseStatus("finished"); err == nil {
+		t.Error("ParseStatus(\"finished\") succeeded")
+	}
+}
+
+func TestStatusScanValue(t *testing.T) {
+	var s Status
+	if err := s.Scan([]byte("queued")); err != nil || s != StatusQueued {
+		t.Errorf("Scan([]byte(\"queued\")) = %q, %v", s, err)
+	}
+	if err := s.Scan(nil); err != nil || s != "" {
+		t.Errorf("Scan(nil) = %q, %v", s, err)
+	}
+	if err := s.Scan("finished"); err == nil {
+		t.Error("Scan(\"finished\") succeeded")
+	}
+	if v, err := StatusRunning.Value(); err != nil || v != "running" {
+		t.Errorf("Value() = %v, %v", v, err)
+	}
+	if _, err := Status("finished").Value(); err == nil {
+		t.Error("Value() of an unknown status succeeded")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	ok, bad := 0, 1
+	end := int64(300)
+	jobs := []*Job{
+		{Status: StatusCompleted, ExitCode: &ok, StartTime: 100, EndTime: &end},
+		{Status: StatusCompleted, ExitCode: &bad, StartTime: 50},
+		{Status: StatusDead},
+		{Status: StatusRunning, StartTime: 200},
+		{Status: StatusPaused},
+		{Status: StatusQueued},
+	}
+	p := Progress(jobs)
+	want := ExperimentProgress{Total: 6, Waiting: 1, Running: 2, Succeeded: 1, Failed: 2, FirstStart: 50, LastEnd: 300}
+	if p != want {
+		t.Errorf("Progress() = %+v, want %+v", p, want)
+	}
+	if got := p.String(); got != "2 running, 1 waiting, 1 succeeded, 2 failed (3/6 done)" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := Progress(nil).String(); got != "no jobs" {
+		t.Errorf("String() of no jobs = %q, want %q", got, "no jobs")
+	}
+}
+
+func TestJobChangesApply(t *testing.T) {
+	ids := func(jobs []*Job) []int64 {
+		var out []int64
+		for _, job := range jobs {
+			out = append(out, job.ID)
+		}
+		return out
+	}
+	listed := []*Job{{ID: 9}, {ID: 7}, {ID: 5}, {ID: 3}}
+	changes := &JobChanges{
+		Changed: []*Job{{ID: 10}, {ID: 8}, {ID: 7, Status: StatusCompleted}, {ID: 2}},
+		Removed: []int64{5},
+	}
+
+	got := changes.Apply(listed, 10)
+	if want := []int64{10, 9, 8, 7, 3, 2}; !reflect.DeepEqual(ids(got), want) {
+		t.Errorf("Apply() = %v, want %v", ids(got), want)
+	}
+	if got[1] != listed[0] {
+		t.Error("Apply() replaced an unchanged job")
+	}
+	if got[3].Status != StatusCompleted {
+		t.Error("Apply() kept the old version of a changed job")
+	}
+
+	// A full list gains newer jobs, but not older ones it had no room for
+	got = changes.Apply(listed, 4)
+	if want := []int64{10, 9, 8, 7}; !reflect.DeepEqual(ids(got), want) {
+		t.Errorf("Apply() to a full list = %v, want %v", ids(got), want)
+	}
+
+	if !(&JobChanges{Revision: 3}).Empty() || changes.Empty() {
+		t.Error("Empty() is wrong")
+	}
+}
+
+func TestParseBackupName(t *testing.T) {
+	want := time.Date(2026, 10, 17, 4, 15, 0, 0, time.Local)
+	if got, seq, ok := parseBackupName("jobs-20261017-041500.db"); !ok || !got.Equal(want) || seq != 1 {
+		t.Errorf("parseBackupName() = %v, %d, %v; want %v, 1", got, seq, ok, want)
+	}
+	if got, seq, ok := parseBackupName("jobs-20261017-041500-3.db"); !ok || !got.Equal(want) || seq != 3 {
+		t.Errorf("parseBackupName(-3) = %v, %d, %v; want %v, 3", got, seq, ok, want)
+	}
+	for _, name := range []string{"jobs.db", "jobs-20261017.db", "jobs-20261017-041500.db.restore", "jobs-20261017-041500-x.db", "jobs-20261017-0415003.db", "notes-20261017-041500.db"} {
+		if _, _, ok := parseBackupName(name); ok {
+			t.Errorf("parseBackupName(%q) should fail", name)
+		}
+	}
+}
+
+func TestBackupsToRemove(t *testing.T) {
+	var backups []BackupFile
+	for _, name := range []string{"c", "b", "a"} {
+		backups = append(backups, BackupFile{Path: name})
+	}
+	tests := []struct {
+		keep int
+		want []string
+	}{
+		{1, []string{"b", "a"}},
+		{2, []string{"a"}},
+		{3, nil},
+		{5, nil},
+		{0, nil}, // Keeps all
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, b := range backupsToRemove(backups, tt.keep) {
+			got = append(got, b.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("backupsToRemove(keep=%d) = %v, want %v", tt.keep, got, tt.want)
+		}
+	}
+}
+
+func TestOpenMemory(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	id, err := RecordStart(database, "cool30", "", "~/code", "python train.py", 1732400000, "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordCompletionByID(database, id, 1, 1732400600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetJobQueueSettings(database, id, QueueSettings{Timeout: "2h", PreStart: "module load cuda"}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := GetJobByID(database, id)
+	if err != nil || job == nil {
+		t.Fatalf("GetJobByID() = %v, %v", job, err)
+	}
+	if job.Description != "baseline" || job.Status != StatusCompleted || job.ExitCode == nil || *job.ExitCode != 1 {
+		t.Errorf("GetJobByID() = %+v, want the completed job", job)
+	}
+	if settings, err := GetJobQueueSettings(database, id); err != nil || settings == nil || settings.Timeout != "2h" || settings.PreStart != "module load cuda" {
+		t.Errorf("GetJobQueueSettings() = %+v, %v", settings, err)
+	}
+
+	// Queries that hold a connection while others run see the same database
+	rows, err := database.Query(`SELECT id FROM jobs`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs, err := ListJobs(database, "", "", -1); err != nil || len(jobs) != 1 {
+		t.Errorf("ListJobs() during a query = %d jobs, %v; want 1", len(jobs), err)
+	}
+	rows.Close()
+
+	if problems, err := Check(database); err != nil || problems != nil {
+		t.Errorf("Check() = %v, %v", problems, err)
+	}
+	if _, err := GC(database, time.Unix(1732400000, 0)); err != nil {
+		t.Errorf("GC(): %v", err)
+	}
+	if stats, err := GetStats(database); err != nil || stats.Jobs != 1 || stats.Size == 0 {
+		t.Errorf("GetStats() = %+v, %v", stats, err)
+	}
+
+	// Each in-memory database is new
+	other, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if jobs, err := ListJobs(other, "", "", -1); err != nil || len(jobs) != 0 {
+		t.Errorf("ListJobs() on a new database = %d jobs, %v; want none", len(jobs), err)
+	}
+}
+
+func TestStatusLog(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	id, err := RecordQueued(database, "cool30", "~/code", "make", "", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateQueuedToRunning(database, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordCompletionByID(database, id, 0, time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	wantLog := func(want ...Status) {
+		t.Helper()
+		changes, err := GetStatusLog(database, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Status
+		for i, c := range changes {
+			if i > 0 && c.From != got[i-1] {
+				t.Errorf("GetStatusLog()[%d].From = %q, want %q", i, c.From, got[i-1])
+			}
+			got = append(got, c.To)
+		}
+		if !reflect.DeepEqual(got, want) || changes[0].From != "" {
+			t.Errorf("GetStatusLog() = %+v, want changes to %v", changes, want)
+		}
+	}
+	wantLog(StatusQueued, StatusRunning, StatusCompleted)
+
+	// A transition that isn't allowed fails before anything is written
+	if changed, err := transitionJob(database, id, []Status{StatusCompleted}, StatusRunning, "exit_code = ?", 1); err == nil || changed {
+		t.Errorf("transitionJob(completed → running) = %v, %v; want an error", changed, err)
+	}
+	// A job that isn't in a from status is left alone
+	if changed, err := transitionJob(database, id, []Status{StatusRunning}, StatusDead, "exit_code = ?", 1); err != nil || changed {
+		t.Errorf("transitionJob(running → dead) of a completed job = %v, %v; want false", changed, err)
+	}
+	if changed, err := transitionJob(database, id+1, []Status{StatusRunning}, StatusDead, ""); err != nil || changed {
+		t.Errorf("transitionJob() of a missing job = %v, %v; want false", changed, err)
+	}
+	job, err := GetJobByID(database, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusCompleted || job.ExitCode == nil || *job.ExitCode != 0 {
+		t.Errorf("job after refused transitions = %+v, want it completed with exit code 0", job)
+	}
+	wantLog(StatusQueued, StatusRunning, StatusCompleted)
+}
+
+func TestListEvents(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	id, err := RecordStart(database, "cool30", "", "~/code", "make", 1000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordCompletionByID(database, id, 0, 1100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordStart(database, "gpu1", "", "~/code", "make", 1200, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordHostUnreachable(database, "cool30", "timeout", 1300, 1400); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := ListEvents(database, EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("ListEvents() = %+v, want 4 events", all)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].ID <= all[i-1].ID {
+			t.Errorf("ListEvents() = %+v, want them oldest first", all)
+		}
+	}
+
+	ids := func(events []Event) []int64 {
+		var ids []int64
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []Event
+	}{
+		{"limit", EventFilter{Limit: 2}, all[2:]},
+		{"after", EventFilter{AfterID: all[0].ID}, all[1:]},
+		{"after and limit", EventFilter{AfterID: all[0].ID, Limit: 1}, all[3:]},
+		{"host", EventFilter{Host: "cool30"}, []Event{all[0], all[1], all[3]}},
+		{"job", EventFilter{JobID: id}, all[:2]},
+		{"host and job", EventFilter{Host: "gpu1", JobID: id}, nil},
+	}
+	for _, tt := range tests {
+		got, err := ListEvents(database, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids(got), ids(tt.want)) {
+			t.Errorf("%s: ListEvents(%+v) = events %v, want %v", tt.name, tt.filter, ids(got), ids(tt.want))
+		}
+	}
+}
+
+func TestHostEvents(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	// Only changes between online and offline are logged, and a host seen
+	// for the first time counts as having been online
+	steps := []struct {
+		host      string
+		reachable bool
+	}{
+		{"cool30", true},
+		{"cool30", true},
+		{"cool30", false},
+		{"cool30", false},
+		{"cool30", true},
+		{"gpu1", false},
+	}
+	for i, step := range steps {
+		at := int64(1000 + i)
+		if step.reachable {
+			err = RecordHostReachable(database, step.host, at)
+		} else {
+			err = RecordHostUnreachable(database, step.host, "timeout", at, at+60)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := ListEvents(database, EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		if e.Kind != EventHost || e.JobID != 0 {
+			t.Errorf("event %+v, want a host event", e)
+		}
+		got = append(got, fmt.Sprintf("%d %s %s→%s", e.At, e.Host, e.From, e.To))
+	}
+	want := []string{
+		"1002 cool30 online→offline",
+		"1004 cool30 offline→online",
+		"1005 gpu1 online→offline",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("host events = %q, want %q", got, want)
+	}
+}
+
+func TestDeletePending(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	held, err := RecordHeld(database, "cool30", "~/code", "make", "", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPendingDependency(database, held, 1, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeletePending(database, held); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := GetJobByID(database, held); err != nil || job != nil {
+		t.Errorf("GetJobByID() after DeletePending() = %+v, %v; want none", job, err)
+	}
+	if dep, err := GetPendingDependency(database, held); err != nil || dep != nil {
+		t.Errorf("GetPendingDependency() after DeletePending() = %+v, %v; want none", dep, err)
+	}
+
+	// Jobs that aren't pending are left alone
+	running, err := RecordStart(database, "cool30", "", "~/code", "make", 1000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DeletePending(database, running); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := GetJobByID(database, running); err != nil || job == nil {
+		t.Errorf("GetJobByID() of a running job after DeletePending() = %+v, %v; want it kept", job, err)
+	}
+}
+
+func TestDeleteJobDeletesItsRows(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	record := func() int64 {
+		t.Helper()
+		id, err := RecordStart(database, "cool30", "", "~/code", "make", 1000, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := SetJobTags(database, id, []string{"sweep"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetJobNote(database, id, "lr=3e-4"); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	deleted, kept := record(), record()
+	if err := DeleteJob(database, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := GetJobTags(database, deleted); err != nil || len(tags) != 0 {
+		t.Errorf("GetJobTags() of a deleted job = %v, %v; want none", tags, err)
+	}
+	if tags, err := GetJobTags(database, kept); err != nil || len(tags) != 1 {
+		t.Errorf("GetJobTags() of another job = %v, %v; want its tag kept", tags, err)
+	}
+
+	// Rows left by a job deleted without them are pruned by gc and prune
+	if _, err := database.Exec(`DELETE FROM jobs WHERE id = ?`, kept); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := GC(database, time.Unix(1732400000, 0)); err != nil || n < 2 {
+		t.Errorf("GC() = %d, %v; want at least the orphaned tag and note deleted", n, err)
+	}
+	if tags, err := GetJobTags(database, kept); err != nil || len(tags) != 0 {
+		t.Errorf("GetJobTags() after GC() = %v, %v; want none", tags, err)
+	}
+	orphan := record()
+	if _, err := database.Exec(`DELETE FROM jobs WHERE id = ?`, orphan); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PruneJobs(database, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := GetJobTags(database, orphan); err != nil || len(tags) != 0 {
+		t.Errorf("GetJobTags() after PruneJobs() = %v, %v; want none", tags, err)
+	}
+}
+
+func TestJobTablesCoverJobIDs(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	rows, err := database.Query(`SELECT m.name FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND p.name = 'job_id' ORDER BY m.name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	listed := map[string]bool{"events": true, "job_revisions": true}
+	for _, table := range jobTables {
+		listed[table] = true
+	}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		if !listed[table] {
+			t.Errorf("table %s has a job_id column but isn't in jobTables", table)
+		}
+	}
+}
+
+func TestListPendingSkipsHeldJobs(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	pending, err := RecordPending(database, "cool30", "~/code", "make", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := RecordHeld(database, "cool30", "~/code", "make", "", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPendingDependency(database, held, 1, false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := ListPending(database, "cool30")
+	if err != nil || len(jobs) != 1 || jobs[0].ID != pending {
+		t.Errorf("ListPending() = %v, %v; want only job %d", jobs, err, pending)
+	}
+	if job, err := GetPendingJob(database, held); err != nil || job != nil {
+		t.Errorf("GetPendingJob() of a held job = %+v, %v; want none", job, err)
+	}
+}
+
+func TestGetActiveJobsByHost(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	starting, err := RecordJobStarting(database, "cool30", "~/code", "make", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, err := RecordJobStarting(database, "cool30", "~/code", "make", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateJobRunning(database, running); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordQueued(database, "cool30", "~/code", "make", "", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordJobStarting(database, "gpu1", "~/code", "make", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := GetActiveJobsByHost(database, "cool30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	slices.Sort(ids)
+	if want := []int64{starting, running}; !slices.Equal(ids, want) {
+		t.Errorf("GetActiveJobsByHost() = jobs %v, want %v", ids, want)
+	}
+}
+
+func TestOpenAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "jobs.db")
+	database, err := OpenAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RecordPending(database, "cool30", "~/code", "make", ""); err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+
+	database, err = OpenAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if jobs, err := ListPending(database, ""); err != nil || len(jobs) != 1 {
+		t.Errorf("ListPending() after reopening = %d jobs, %v; want 1", len(jobs), err)
+	}
+}
+
+func TestListFinishedSince(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var ids []int64
+	for _, end := range []int64{1000, 3000, 2000} {
+		id, err := RecordStart(database, "cool30", "", "~/code", "true", 500, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := RecordCompletionByID(database, id, 0, end); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := RecordStart(database, "cool30", "", "~/code", "sleep 60", 2500, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := ListFinishedSince(database, time.Unix(2000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != ids[1] || jobs[1].ID != ids[2] {
+		t.Errorf("ListFinishedSince() = %v, want jobs %d and %d", jobs, ids[1], ids[2])
+	}
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	sealed, working := filepath.Join(dir, "jobs.db.enc"), filepath.Join(dir, "working.db")
+	key := make([]byte, 32)
+
+	// Seal a new database, as db encrypt does
+	plain, err := OpenAt(filepath.Join(dir, "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.Close()
+	if err := reseal(filepath.Join(dir, "jobs.db"), sealed, key); err != nil {
+		t.Fatal(err)
+	}
+
+	database, err := OpenEncrypted(sealed, working, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := RecordJobStarting(database, "cool30", "/code", "python train.py", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "train.py") {
+		t.Error("the encrypted database contains a job's command in the clear")
+	}
+
+	// The last to close the database removes the decrypted copy, so the job
+	// is read from the encrypted database
+	if _, err := os.Stat(working); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the decrypted copy is still there after closing: %v", err)
+	}
+	database, err = OpenEncrypted(sealed, working, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := GetJobByID(database, id)
+	if err != nil || job == nil || job.Command != "python train.py" {
+		t.Errorf("GetJobByID() after reopening = %+v, %v", job, err)
+	}
+
+	// While another user has it open, closing keeps the copy
+	other, err := OpenEncrypted(sealed, working, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(working); err != nil {
+		t.Errorf("the decrypted copy was removed while still open: %v", err)
+	}
+	if lock, err := lockExclusive(working); err == nil {
+		lock.Close()
+		t.Error("lockExclusive() succeeded while the database was open")
+	}
+	if err := other.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(working); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the decrypted copy is still there after the last close: %v", err)
+	}
+}
+
+func TestResealWhileOpen(t *testing.T) {
+	dir := t.TempDir()
+	sealed, working := filepath.Join(dir, "jobs.db.enc"), filepath.Join(dir, "working.db")
+	key := make([]byte, 32)
+	plain, err := OpenAt(filepath.Join(dir, "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.Close()
+	if err := reseal(filepath.Join(dir, "jobs.db"), sealed, key); err != nil {
+		t.Fatal(err)
+	}
+	database, err := OpenEncrypted(sealed, working, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	connector := openSealed[database]
+	sealedData := func() string {
+		t.Helper()
+		data, err := os.ReadFile(sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	before := sealedData()
+	if err := connector.reseal(false); err != nil {
+		t.Fatal(err)
+	}
+	if sealedData() != before {
+		t.Error("reseal(false) rewrote the encrypted database when nothing had changed")
+	}
+	if _, err := RecordJobStarting(database, "cool30", "/code", "python train.py", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := connector.reseal(false); err != nil {
+		t.Fatal(err)
+	}
+	if sealedData() == before {
+		t.Error("reseal(false) didn't rewrite the encrypted database after a change")
+	}
+}
+
+func TestEncryptBackups(t *testing.T) {
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	path, err := Backup(database, dir, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := EncryptBackups(dir, key)
+	if err != nil || n != 1 {
+		t.Fatalf("EncryptBackups() = %d, %v; want 1", n, err)
+	}
+	if !dbcrypt.IsSealedFile(path) {
+		t.Error("the backup is still unencrypted")
+	}
+	// Encrypted backups are left as they are
+	if n, err := EncryptBackups(dir, key); err != nil || n != 0 {
+		t.Errorf("EncryptBackups() again = %d, %v; want 0", n, err)
+	}
+}
+
+func TestJobRemoteUser(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	id, err := RecordJobStarting(database, "cool30", "~/code", "true", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := JobRemoteUser(database, id); err != nil || user != "" {
+		t.Errorf("JobRemoteUser() before recording = %q, %v", user, err)
+	}
+	if err := SetJobRemoteUser(database, id, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := JobRemoteUser(database, id); err != nil || user != "alice" {
+		t.Errorf("JobRemoteUser() = %q, %v; want alice", user, err)
+	}
+	if err := SetJobRemoteUser(database, id, ""); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := JobRemoteUser(database, id); user != "" {
+		t.Errorf("JobRemoteUser() after clearing = %q", user)
+	}
+}
+
+func TestClaimNotifications(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	const url = "https://hooks.slack.com/x"
+	for _, id := range []int64{1, 2} {
+		if err := QueueNotification(database, url, id, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending, err := PendingNotifications(database, url)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("PendingNotifications() = %v, %v", pending, err)
+	}
+	if ok, err := ClaimNotifications(database, url, []int64{1, 2}, 1100); err != nil || !ok {
+		t.Fatalf("ClaimNotifications() = %v, %v; want true", ok, err)
+	}
+	// Another process that listed them before the claim
+	if ok, err := ClaimNotifications(database, url, []int64{1, 2}, 1100); err != nil || ok {
+		t.Errorf("second ClaimNotifications() = %v, %v; want false", ok, err)
+	}
+	if count, _, err := NotifierSends(database, url, 0); err != nil || count != 1 {
+		t.Errorf("NotifierSends() = %d, %v; want 1", count, err)
+	}
+
+	if err := UnclaimNotifications(database, url, pending, 1100); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := PendingNotifications(database, url); err != nil || len(got) != 2 || got[0] != pending[0] {
+		t.Errorf("PendingNotifications() after unclaiming = %v, %v; want %v", got, err, pending)
+	}
+	if count, _, err := NotifierSends(database, url, 0); err != nil || count != 0 {
+		t.Errorf("NotifierSends() after unclaiming = %d, %v; want 0", count, err)
+	}
+}
+
+func TestListJobPauses(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var ids []int64
+	for range 3 {
+		id, err := RecordJobStarting(database, "cool30", "~/code", "make", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateJobRunning(database, id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := MarkPaused(database, ids[0], 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkPaused(database, ids[1], ids[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	pauses, err := ListJobPauses(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pauses) != 2 || pauses[ids[0]] == nil || pauses[ids[1]] == nil {
+		t.Fatalf("ListJobPauses() = %v, want jobs %d and %d", pauses, ids[0], ids[1])
+	}
+	if got := pauses[ids[0]].PreemptedBy; got != 0 {
+		t.Errorf("job %d preempted by %d, want 0", ids[0], got)
+	}
+	if got := pauses[ids[1]].PreemptedBy; got != ids[2] {
+		t.Errorf("job %d preempted by %d, want %d", ids[1], got, ids[2])
+	}
+}
+
+func TestClearStaleGPUIdle(t *testing.T) {
+	database, err := OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	var ids []int64
+	for range 2 {
+		id, err := RecordJobStarting(database, "cool30", "~/code", "make", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateJobRunning(database, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RecordGPUIdle(database, id, true, 1000); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := MarkDeadByID(database, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ClearStaleGPUIdle(database); err != nil {
+		t.Fatal(err)
+	}
+	var remaining []int64
+	rows, err := database.Query(`SELECT job_id FROM job_gpu_idle ORDER BY job_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		remaining = append(remaining, id)
+	}
+	if want := ids[:1]; !slices.Equal(remaining, want) {
+		t.Errorf("idle stretches of jobs %v remain, want %v", remaining, want)
+	}
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// JobDependency is what a queued job started with --after is waiting for
+type JobDependency struct {
+	JobID      int64
+	AfterJobID int64
+	AfterAny   bool // Run however the upstream job exits, not only on success
+}
+
+// SetJobDependency records that a queued job waits for afterJobID
+func SetJobDependency(db *sql.DB, jobID, afterJobID int64, afterAny bool) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_dependencies (job_id, after_job_id, after_any) VALUES (?, ?, ?)`,
+		jobID, afterJobID, afterAny,
+	)
+	return err
+}
+
+// GetJobDependency returns a job's dependency, or nil if it has none
+func GetJobDependency(db *sql.DB, jobID int64) (*JobDependency, error) {
+	d := &JobDependency{JobID: jobID}
+	err := db.QueryRow(
+		`SELECT after_job_id, after_any FROM job_dependencies WHERE job_id = ?`, jobID,
+	).Scan(&d.AfterJobID, &d.AfterAny)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DeleteJobDependency removes a job's dependency
+func DeleteJobDependency(db *sql.DB, jobID int64) error {
+	_, err := db.Exec(`DELETE FROM job_dependencies WHERE job_id = ?`, jobID)
+	return err
+}
+
+// Condition describes when the dependent job runs: "on success" or "on any exit"
+func (d *JobDependency) Condition() string {
+	if d.AfterAny {
+		return "on any exit"
+	}
+	return "on success"
+}
+
+// Describe describes the dependency and the upstream job's current state,
+// e.g. "job 42 (on success): running". upstream is nil if it isn't in the
+// database.
+func (d *JobDependency) Describe(upstream *Job) string {
+	return fmt.Sprintf("job %d (%s): %s", d.AfterJobID, d.Condition(), d.upstreamState(upstream))
+}
+
+// Resolve reports whether the dependent job should run now that the
+// upstream job has finished (run), or be skipped because it failed or was
+// itself skipped (skip).
+// Both are false while the upstream job is still active.
+func (d *JobDependency) Resolve(upstream *Job) (run, skip bool) {
+	switch upstream.Status {
+	case StatusCompleted:
+		if upstream.ExitCode == nil || *upstream.ExitCode == 0 {
+			return true, false
+		}
+	case StatusDead, StatusFailed:
+	default:
+		return false, false
+	}
+	return d.AfterAny, !d.AfterAny
+}
+
+func (d *JobDependency) upstreamState(upstream *Job) string {
+	if upstream == nil {
+		return "unknown job"
+	}
+	failed := ""
+	switch upstream.Status {
+	case StatusCompleted:
+		if upstream.ExitCode == nil || *upstream.ExitCode == 0 {
+			return "completed"
+		}
+		failed = fmt.Sprintf("failed (exit %d)", *upstream.ExitCode)
+		if upstream.Skipped() {
+			failed = "skipped"
+		}
+	case StatusDead, StatusFailed:
+		failed = string(upstream.Status)
+	default:
+		return string(upstream.Status)
+	}
+	if d.AfterAny {
+		return failed
+	}
+	return failed + ", so this job will be skipped"
+}
+
+// PendingDependency is a job held locally until a job on another host
+// finishes, at which point sync adds it to its own host's queue
+type PendingDependency struct {
+	JobDependency
+	EnvVarsB64 string // The queue line's env_vars_b64 field
+	CreatedAt  int64
+}
+
+// AddPendingDependency records that a held job waits for a job on another host
+func AddPendingDependency(db *sql.DB, jobID, afterJobID int64, afterAny bool, envVarsB64 string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO pending_dependencies (job_id, after_job_id, after_any, env_vars_b64, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		jobID, afterJobID, afterAny, envVarsB64, time.Now().Unix(),
+	)
+	return err
+}
+
+// GetPendingDependency returns a held job's dependency, or nil if it has none
+func GetPendingDependency(db *sql.DB, jobID int64) (*PendingDependency, error) {
+	d := &PendingDependency{JobDependency: JobDependency{JobID: jobID}}
+	err := db.QueryRow(
+		`SELECT after_job_id, after_any, env_vars_b64, created_at FROM pending_dependencies WHERE job_id = ?`, jobID,
+	).Scan(&d.AfterJobID, &d.AfterAny, &d.EnvVarsB64, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListPendingDependencies returns all held jobs' dependencies, oldest first
+func ListPendingDependencies(db *sql.DB) ([]*PendingDependency, error) {
+	rows, err := db.Query(
+		`SELECT job_id, after_job_id, after_any, env_vars_b64, created_at FROM pending_dependencies ORDER BY job_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deps []*PendingDependency
+	for rows.Next() {
+		d := &PendingDependency{}
+		if err := rows.Scan(&d.JobID, &d.AfterJobID, &d.AfterAny, &d.EnvVarsB64, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deps = append(deps, d)
+	}
+	return deps, rows.Err()
+}
+
+// DeletePendingDependency removes a held job's dependency
+func DeletePendingDependency(db *sql.DB, jobID int64) error {
+	_, err := db.Exec(`DELETE FROM pending_dependencies WHERE job_id = ?`, jobID)
+	return err
+}
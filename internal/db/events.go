@@ -0,0 +1,173 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// Event kinds
+const (
+	EventJob  = "job"  // A job's status changed
+	EventHost = "host" // A host went online or offline
+)
+
+// Host states in host events
+const (
+	HostOnline  = "online"
+	HostOffline = "offline"
+)
+
+// Event is a change to a job's status or to whether a host answers SSH, as
+// sync and the TUI find them
+type Event struct {
+	ID    int64
+	At    int64
+	Kind  string
+	JobID int64 // Zero for host events
+	Host  string
+	From  string // Empty when a job was created
+	To    string
+}
+
+// EventFilter selects events for ListEvents. Zero fields select everything.
+type EventFilter struct {
+	AfterID int64 // Only events recorded after this one
+	Since   int64 // Only events at or after this time
+	Host    string
+	JobID   int64
+	Limit   int // The most recent Limit events
+}
+
+// ListEvents returns the events selected by f, oldest first
+func ListEvents(db *sql.DB, f EventFilter) ([]Event, error) {
+	query := `SELECT id, at, kind, job_id, host, from_state, to_state FROM events WHERE id > ? AND at >= ?`
+	args := []interface{}{f.AfterID, f.Since}
+	if f.Host != "" {
+		query += ` AND host = ?`
+		args = append(args, f.Host)
+	}
+	if f.JobID != 0 {
+		query += ` AND job_id = ?`
+		args = append(args, f.JobID)
+	}
+	query += ` ORDER BY id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var jobID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.At, &e.Kind, &jobID, &e.Host, &e.From, &e.To); err != nil {
+			return nil, err
+		}
+		e.JobID = jobID.Int64
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Selected newest first so that Limit keeps the most recent
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// logHostChange records an event if a host's reachability changed from what
+// was last recorded. A host with no record counts as online, so that hosts
+// seen for the first time don't each log an event.
+func logHostChange(tx *sql.Tx, host string, reachable bool, at int64) error {
+	wasReachable := true
+	err := tx.QueryRow(`SELECT reachable FROM host_reachability WHERE host = ?`, host).Scan(&wasReachable)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if wasReachable == reachable {
+		return nil
+	}
+	from, to := HostOnline, HostOffline
+	if reachable {
+		from, to = HostOffline, HostOnline
+	}
+	_, err = tx.Exec(
+		`INSERT INTO events (at, kind, host, from_state, to_state) VALUES (?, ?, ?, ?, ?)`,
+		at, EventHost, host, from, to,
+	)
+	return err
+}
+
+// PendingWebhookEvents returns the events that haven't yet been sent to
+// webhooks, oldest first, and the ID of the last one that has. The first
+// call returns none, so that configuring a webhook doesn't send past events.
+func PendingWebhookEvents(db *sql.DB) ([]Event, int64, error) {
+	cursor, ok, err := readCursor(db, "webhook_cursor")
+	if err != nil || !ok {
+		return nil, 0, err
+	}
+	events, err := ListEvents(db, EventFilter{AfterID: cursor})
+	return events, cursor, err
+}
+
+// AdvanceWebhookCursor records that the events up to to have been sent to
+// webhooks, given the last event recorded before them. It reports false if
+// another process has sent them first.
+func AdvanceWebhookCursor(db *sql.DB, from, to int64) (bool, error) {
+	return advanceCursor(db, "webhook_cursor", from, to)
+}
+
+// ClaimNotifierEvents returns the events recorded since the last call, by
+// any process, so that each finished job is queued for Slack notifiers once.
+// The first call returns none, so that configuring a notifier doesn't send
+// past jobs.
+func ClaimNotifierEvents(db *sql.DB) ([]Event, error) {
+	return claimEvents(db, "notifier_cursor")
+}
+
+// readCursor returns the last event in a cursor table. When the table is
+// empty, it starts the cursor at the latest event and reports false.
+func readCursor(db *sql.DB, cursorTable string) (int64, bool, error) {
+	var cursor int64
+	err := db.QueryRow(`SELECT last_event_id FROM ` + cursorTable + ` WHERE id = 1`).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		_, err = db.Exec(`INSERT OR IGNORE INTO ` + cursorTable + ` (id, last_event_id) SELECT 1, COALESCE(MAX(id), 0) FROM events`)
+		return 0, false, err
+	}
+	return cursor, err == nil, err
+}
+
+// advanceCursor moves a cursor table from one event to a later one,
+// reporting false if it no longer points at from
+func advanceCursor(db *sql.DB, cursorTable string, from, to int64) (bool, error) {
+	result, err := db.Exec(`UPDATE `+cursorTable+` SET last_event_id = ? WHERE id = 1 AND last_event_id = ?`, to, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// claimEvents returns the events recorded since the last event in the cursor
+// table, and moves the cursor past them
+func claimEvents(db *sql.DB, cursorTable string) ([]Event, error) {
+	cursor, ok, err := readCursor(db, cursorTable)
+	if err != nil || !ok {
+		return nil, err
+	}
+	events, err := ListEvents(db, EventFilter{AfterID: cursor})
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	claimed, err := advanceCursor(db, cursorTable, cursor, events[len(events)-1].ID)
+	if err != nil || !claimed {
+		// Another process claimed them first
+		return nil, err
+	}
+	return events, nil
+}
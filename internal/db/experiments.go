@@ -0,0 +1,206 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Experiment is a named group of jobs, such as the runs of an ablation
+type Experiment struct {
+	Name        string
+	Description string
+	CreatedAt   int64
+	Jobs        int // Number of member jobs, filled in by ListExperiments
+}
+
+// CreateExperiment records an experiment, and reports whether it is new. An
+// existing experiment keeps its creation time, and takes description if it
+// is not empty.
+func CreateExperiment(db *sql.DB, name, description string, createdAt int64) (bool, error) {
+	res, err := db.Exec(
+		`INSERT OR IGNORE INTO experiments (name, description, created_at) VALUES (?, ?, ?)`,
+		name, description, createdAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 && description != "" {
+		if _, err := db.Exec(`UPDATE experiments SET description = ? WHERE name = ?`, description, name); err != nil {
+			return false, err
+		}
+	}
+	return n > 0, nil
+}
+
+// GetExperiment returns an experiment, or nil if there is none by that name
+func GetExperiment(db *sql.DB, name string) (*Experiment, error) {
+	var e Experiment
+	err := db.QueryRow(
+		`SELECT name, description, created_at FROM experiments WHERE name = ?`, name,
+	).Scan(&e.Name, &e.Description, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListExperiments returns every experiment with its number of jobs, newest
+// first
+func ListExperiments(db *sql.DB) ([]*Experiment, error) {
+	rows, err := db.Query(
+		`SELECT e.name, e.description, e.created_at, COUNT(j.id)
+		 FROM experiments e
+		 LEFT JOIN job_experiments je ON je.experiment = e.name
+		 LEFT JOIN jobs j ON j.id = je.job_id
+		 GROUP BY e.name
+		 ORDER BY e.created_at DESC, e.name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []*Experiment
+	for rows.Next() {
+		var e Experiment
+		if err := rows.Scan(&e.Name, &e.Description, &e.CreatedAt, &e.Jobs); err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, &e)
+	}
+	return experiments, rows.Err()
+}
+
+// SetJobExperiment makes a job a member of an experiment, replacing any
+// experiment it was in
+func SetJobExperiment(db *sql.DB, jobID int64, experiment string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_experiments (job_id, experiment) VALUES (?, ?)`,
+		jobID, experiment,
+	)
+	return err
+}
+
+// GetJobExperiment returns the experiment a job belongs to, or "" if none
+func GetJobExperiment(db *sql.DB, jobID int64) (string, error) {
+	var experiment string
+	err := db.QueryRow(`SELECT experiment FROM job_experiments WHERE job_id = ?`, jobID).Scan(&experiment)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return experiment, err
+}
+
+// JobExperiments returns the experiments of the jobs with the given IDs that
+// belong to one, by job ID
+func JobExperiments(db *sql.DB, ids []int64) (map[int64]string, error) {
+	result := make(map[int64]string)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(
+		`SELECT job_id, experiment FROM job_experiments WHERE job_id IN (?`+strings.Repeat(",?", len(ids)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var experiment string
+		if err := rows.Scan(&id, &experiment); err != nil {
+			return nil, err
+		}
+		result[id] = experiment
+	}
+	return result, rows.Err()
+}
+
+// ListExperimentJobs returns the jobs of an experiment, oldest first
+func ListExperimentJobs(db *sql.DB, experiment string) ([]*Job, error) {
+	return queryJobs(db,
+		`SELECT j.id, j.host, j.session_name, j.working_dir, j.command, j.description, j.start_time, j.end_time, j.exit_code, j.status, j.error_message, j.queue_name, j.remote_user
+		 FROM jobs j JOIN job_experiments je ON je.job_id = j.id
+		 WHERE je.experiment = ? ORDER BY j.id`,
+		experiment,
+	)
+}
+
+// ExperimentProgress counts an experiment's jobs by how far along they are
+type ExperimentProgress struct {
+	Total      int
+	Waiting    int   // Pending, queued, or starting
+	Running    int   // Running or paused
+	Succeeded  int   // Completed with exit code 0
+	Failed     int   // Completed with another exit code, dead, or failed to start
+	FirstStart int64 // Earliest start time, 0 if none started
+	LastEnd    int64 // Latest end time, 0 if none ended
+}
+
+// Progress counts jobs by how far along they are
+func Progress(jobs []*Job) ExperimentProgress {
+	var p ExperimentProgress
+	for _, job := range jobs {
+		p.Total++
+		switch job.Status {
+		case StatusPending, StatusQueued, StatusStarting:
+			p.Waiting++
+		case StatusRunning, StatusPaused:
+			p.Running++
+		case StatusCompleted:
+			if job.ExitCode != nil && *job.ExitCode == 0 {
+				p.Succeeded++
+			} else {
+				p.Failed++
+			}
+		default:
+			p.Failed++
+		}
+		if job.StartTime > 0 && (p.FirstStart == 0 || job.StartTime < p.FirstStart) {
+			p.FirstStart = job.StartTime
+		}
+		if job.EndTime != nil && *job.EndTime > p.LastEnd {
+			p.LastEnd = *job.EndTime
+		}
+	}
+	return p
+}
+
+// Finished returns the number of jobs that have ended
+func (p ExperimentProgress) Finished() int {
+	return p.Succeeded + p.Failed
+}
+
+// String summarizes the counts, leaving out those that are zero, e.g.
+// "2 running, 5 succeeded, 1 failed (8/10 done)"
+func (p ExperimentProgress) String() string {
+	var parts []string
+	for _, c := range []struct {
+		n     int
+		label string
+	}{
+		{p.Running, "running"}, {p.Waiting, "waiting"}, {p.Succeeded, "succeeded"}, {p.Failed, "failed"},
+	} {
+		if c.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", c.n, c.label))
+		}
+	}
+	if len(parts) == 0 {
+		return "no jobs"
+	}
+	return fmt.Sprintf("%s (%d/%d done)", strings.Join(parts, ", "), p.Finished(), p.Total)
+}
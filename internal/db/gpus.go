@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/osteele/remote-jobs/internal/gpupool"
+)
+
+// SetJobGPUs records the GPU indices assigned to a job with `run --gpus`
+func SetJobGPUs(db *sql.DB, jobID int64, gpus []int) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_gpus (job_id, gpus) VALUES (?, ?)`,
+		jobID, gpupool.JoinIndices(gpus, ","),
+	)
+	return err
+}
+
+// GetJobGPUs returns the GPU indices assigned to a job, or nil if it wasn't
+// assigned any
+func GetJobGPUs(db *sql.DB, jobID int64) ([]int, error) {
+	var recorded string
+	err := db.QueryRow(`SELECT gpus FROM job_gpus WHERE job_id = ?`, jobID).Scan(&recorded)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if gpus := gpupool.ParseIndices(recorded); len(gpus) > 0 {
+		return gpus, nil
+	}
+	return nil, nil
+}
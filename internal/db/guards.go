@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// What the queue runner does with a job whose guard is false
+const (
+	GuardRequeue = "requeue" // Put the job back at the end of the queue
+	GuardSkip    = "skip"    // Record the job as skipped (see ExitSkipped)
+	GuardFail    = "fail"    // Record the job as failed
+)
+
+// ExitSkipped is the exit code the queue runner writes to the status file of
+// a job it skipped because its guard was false. It is outside the range of
+// real exit codes, so the job counts as neither a success nor a failure, and
+// jobs queued --after it are skipped too.
+const ExitSkipped = -1
+
+// skippedMessage is the error message recorded for a skipped job
+const skippedMessage = "skipped: its --if condition was false"
+
+// Skipped reports whether the queue runner skipped the job without running it
+func (j *Job) Skipped() bool {
+	return j.Status == StatusCompleted && j.ExitCode != nil && *j.ExitCode == ExitSkipped
+}
+
+// JobGuard is a shell command the queue runner checks just before starting a
+// job, set with --if
+type JobGuard struct {
+	Command string
+	IfFalse string // GuardRequeue, GuardSkip, or GuardFail
+}
+
+// ValidateGuardPolicy returns an error if policy isn't a known guard policy
+func ValidateGuardPolicy(policy string) error {
+	switch policy {
+	case GuardRequeue, GuardSkip, GuardFail:
+		return nil
+	}
+	return fmt.Errorf("unknown guard policy %q (valid: requeue, skip, fail)", policy)
+}
+
+// SetJobGuard records a job's guard
+func SetJobGuard(db *sql.DB, jobID int64, guard JobGuard) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_guards (job_id, command, if_false) VALUES (?, ?, ?)`,
+		jobID, guard.Command, guard.IfFalse,
+	)
+	return err
+}
+
+// GetJobGuard returns a job's guard, or nil if it has none
+func GetJobGuard(db *sql.DB, jobID int64) (*JobGuard, error) {
+	var g JobGuard
+	err := db.QueryRow(
+		`SELECT command, if_false FROM job_guards WHERE job_id = ?`, jobID,
+	).Scan(&g.Command, &g.IfFalse)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// String describes the guard, e.g. "test -f ready.flag (else requeue)"
+func (g *JobGuard) String() string {
+	return fmt.Sprintf("%s (else %s)", g.Command, g.IfFalse)
+}
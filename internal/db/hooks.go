@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// JobHooks holds the local commands to run when a job reaches a terminal state
+type JobHooks struct {
+	JobID     int64
+	OnSuccess string
+	OnFailure string
+}
+
+// SetJobHooks stores the local completion hooks for a job
+func SetJobHooks(db *sql.DB, jobID int64, onSuccess, onFailure string) error {
+	_, err := db.Exec(
+		`UPDATE jobs SET on_success = ?, on_failure = ?, hooks_fired = 0 WHERE id = ?`,
+		nullString(onSuccess), nullString(onFailure), jobID,
+	)
+	return err
+}
+
+// ListPendingHooks returns hooks for finished jobs whose hooks have not run yet
+func ListPendingHooks(db *sql.DB) ([]JobHooks, error) {
+	rows, err := db.Query(
+		`SELECT id, on_success, on_failure FROM jobs
+		WHERE hooks_fired = 0
+		AND status IN (?, ?, ?)
+		AND (COALESCE(on_success, '') != '' OR COALESCE(on_failure, '') != '')
+		ORDER BY id`,
+		StatusCompleted, StatusDead, StatusFailed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []JobHooks
+	for rows.Next() {
+		var h JobHooks
+		var onSuccess, onFailure sql.NullString
+		if err := rows.Scan(&h.JobID, &onSuccess, &onFailure); err != nil {
+			return nil, err
+		}
+		h.OnSuccess = onSuccess.String
+		h.OnFailure = onFailure.String
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// ClaimHooks marks a job's hooks as fired. It returns false if another
+// process already claimed them, so each hook runs at most once.
+func ClaimHooks(db *sql.DB, jobID int64) (bool, error) {
+	result, err := db.Exec(`UPDATE jobs SET hooks_fired = 1 WHERE id = ? AND hooks_fired = 0`, jobID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// GetJobHooks returns the local completion hooks stored for a job
+func GetJobHooks(db *sql.DB, jobID int64) (JobHooks, error) {
+	h := JobHooks{JobID: jobID}
+	var onSuccess, onFailure sql.NullString
+	err := db.QueryRow(`SELECT on_success, on_failure FROM jobs WHERE id = ?`, jobID).Scan(&onSuccess, &onFailure)
+	if err != nil && err != sql.ErrNoRows {
+		return h, err
+	}
+	h.OnSuccess = onSuccess.String
+	h.OnFailure = onFailure.String
+	return h, nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// HostEvent is a host-level incident such as a GPU Xid error, thermal
+// throttling, or an OOM kill
+type HostEvent struct {
+	Host    string
+	Time    int64
+	Kind    string
+	Message string
+}
+
+// SaveHostEvents records events, skipping ones already recorded. It returns
+// the number of new events.
+func SaveHostEvents(db *sql.DB, events []HostEvent) (int, error) {
+	var added int
+	for _, e := range events {
+		result, err := db.Exec(
+			`INSERT OR IGNORE INTO host_events (host, event_time, kind, message) VALUES (?, ?, ?, ?)`,
+			e.Host, e.Time, e.Kind, e.Message,
+		)
+		if err != nil {
+			return added, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// ListHostEvents returns events at or after since, newest first. An empty
+// host lists events on every host.
+func ListHostEvents(db *sql.DB, host string, since int64) ([]HostEvent, error) {
+	query := `SELECT host, event_time, kind, message FROM host_events WHERE event_time >= ?`
+	args := []interface{}{since}
+	if host != "" {
+		query += ` AND host = ?`
+		args = append(args, host)
+	}
+	query += ` ORDER BY event_time DESC, id DESC`
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []HostEvent
+	for rows.Next() {
+		var e HostEvent
+		if err := rows.Scan(&e.Host, &e.Time, &e.Kind, &e.Message); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
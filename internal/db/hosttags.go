@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// AddHost adds a host to the hosts cache without any info, so that it is
+// listed before it has been queried or run a job. It reports whether the
+// host was added, rather than already cached.
+func AddHost(db *sql.DB, name string) (bool, error) {
+	result, err := db.Exec(`INSERT OR IGNORE INTO hosts (name, last_updated) VALUES (?, 0)`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AddHostTags gives a host tags, in addition to any it has
+func AddHostTags(db *sql.DB, host string, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO host_tags (host, tag) VALUES (?, ?)`, host, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetHostTags returns the tags given to a host by `host add`
+func GetHostTags(db *sql.DB, host string) ([]string, error) {
+	rows, err := db.Query(`SELECT tag FROM host_tags WHERE host = ? ORDER BY rowid`, host)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// allHostTags returns the tags given to each host by `host add`
+func allHostTags(db *sql.DB) (map[string][]string, error) {
+	rows, err := db.Query(`SELECT host, tag FROM host_tags ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var host, tag string
+		if err := rows.Scan(&host, &tag); err != nil {
+			return nil, err
+		}
+		tags[host] = append(tags[host], tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// HostLatency is the SSH round-trip time measured on a host
+type HostLatency struct {
+	Host       string
+	Average    time.Duration // Rolling average of the samples
+	Last       time.Duration // The latest sample
+	Samples    int
+	MeasuredAt int64
+}
+
+// GetHostLatency returns the latency recorded for a host, or nil if none was
+// recorded
+func GetHostLatency(db *sql.DB, host string) (*HostLatency, error) {
+	l := &HostLatency{Host: host}
+	var avgMS, lastMS float64
+	err := db.QueryRow(
+		`SELECT avg_ms, last_ms, samples, measured_at FROM host_latency WHERE host = ?`, host,
+	).Scan(&avgMS, &lastMS, &l.Samples, &l.MeasuredAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.Average, l.Last = fromMillis(avgMS), fromMillis(lastMS)
+	return l, nil
+}
+
+// ListHostLatency returns the latency recorded for every host, by host
+func ListHostLatency(db *sql.DB) (map[string]*HostLatency, error) {
+	rows, err := db.Query(`SELECT host, avg_ms, last_ms, samples, measured_at FROM host_latency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]*HostLatency)
+	for rows.Next() {
+		l := &HostLatency{}
+		var avgMS, lastMS float64
+		if err := rows.Scan(&l.Host, &avgMS, &lastMS, &l.Samples, &l.MeasuredAt); err != nil {
+			return nil, err
+		}
+		l.Average, l.Last = fromMillis(avgMS), fromMillis(lastMS)
+		result[l.Host] = l
+	}
+	return result, rows.Err()
+}
+
+// SaveHostLatency records a host's latency, replacing the last record
+func SaveHostLatency(db *sql.DB, l *HostLatency) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO host_latency (host, avg_ms, last_ms, samples, measured_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		l.Host, toMillis(l.Average), toMillis(l.Last), l.Samples, l.MeasuredAt,
+	)
+	return err
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func fromMillis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// SetResumeCommand records the command that resumes a job from a checkpoint
+func SetResumeCommand(db *sql.DB, jobID int64, command string) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO job_resume (job_id, command) VALUES (?, ?)`, jobID, command)
+	return err
+}
+
+// GetResumeCommand returns the command that resumes a job from a checkpoint,
+// or "" if it has none
+func GetResumeCommand(db *sql.DB, jobID int64) (string, error) {
+	var command string
+	err := db.QueryRow(`SELECT command FROM job_resume WHERE job_id = ?`, jobID).Scan(&command)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return command, err
+}
+
+// Migration records that a job was started to continue another on a
+// different host, from a checkpoint of it
+type Migration struct {
+	JobID      int64  // The job started on the new host
+	FromJobID  int64  // The job it continues
+	FromHost   string // The host that job ran on
+	Checkpoint string // The checkpoint it resumed from
+	MigratedAt int64
+}
+
+// RecordMigration records a job's lineage
+func RecordMigration(db *sql.DB, m Migration) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_migrations (job_id, from_job_id, from_host, checkpoint, migrated_at) VALUES (?, ?, ?, ?, ?)`,
+		m.JobID, m.FromJobID, m.FromHost, m.Checkpoint, m.MigratedAt,
+	)
+	return err
+}
+
+// GetMigrationTo returns how a job came to continue another, or nil if it
+// wasn't started by a migration
+func GetMigrationTo(db *sql.DB, jobID int64) (*Migration, error) {
+	return scanMigration(db.QueryRow(
+		`SELECT job_id, from_job_id, from_host, checkpoint, migrated_at FROM job_migrations WHERE job_id = ?`, jobID))
+}
+
+// GetMigrationFrom returns the migration that continued a job elsewhere, or
+// nil if it wasn't migrated
+func GetMigrationFrom(db *sql.DB, jobID int64) (*Migration, error) {
+	return scanMigration(db.QueryRow(
+		`SELECT job_id, from_job_id, from_host, checkpoint, migrated_at FROM job_migrations
+		WHERE from_job_id = ? ORDER BY migrated_at DESC LIMIT 1`, jobID))
+}
+
+func scanMigration(row *sql.Row) (*Migration, error) {
+	var m Migration
+	err := row.Scan(&m.JobID, &m.FromJobID, &m.FromHost, &m.Checkpoint, &m.MigratedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
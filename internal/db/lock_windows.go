@@ -0,0 +1,27 @@
+package db
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockShared waits for a shared lock on f
+func lockShared(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), 0, 0, 1, 0, &windows.Overlapped{})
+}
+
+// tryLockExclusive takes an exclusive lock on f in place of any shared one
+// held through it, reporting false if another process holds a lock on it.
+// The shared lock may be lost when it reports false.
+func tryLockExclusive(f *os.File) (bool, error) {
+	// Windows doesn't convert locks, so give up the shared one first
+	windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
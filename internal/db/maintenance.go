@@ -0,0 +1,331 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/dbcrypt"
+	"github.com/osteele/remote-jobs/internal/xdg"
+)
+
+// BackupFile is a copy of the database in the backup directory
+type BackupFile struct {
+	Path string
+	Time time.Time // When it was made, from its name
+	Seq  int       // Orders backups made in the same second
+	Size int64
+}
+
+// backupTimeFormat is the time in a backup's name, jobs-20261017-041500.db.
+// A second backup in the same second is jobs-20261017-041500-2.db.
+const backupTimeFormat = "20060102-150405"
+
+// BackupDir returns the directory backups are kept in, next to the database
+func BackupDir() string {
+	return filepath.Join(filepath.Dir(Path()), "backups")
+}
+
+// Stats describes the size of the database
+type Stats struct {
+	Size      int64 // Bytes on disk (PageSize times Pages)
+	PageSize  int64
+	Pages     int64
+	FreePages int64 // Unused pages, which VACUUM reclaims
+	Jobs      int
+}
+
+// Reclaimable returns how many bytes VACUUM would free
+func (s Stats) Reclaimable() int64 {
+	return s.FreePages * s.PageSize
+}
+
+// GetStats returns the size of the database
+func GetStats(db *sql.DB) (Stats, error) {
+	var s Stats
+	for _, q := range []struct {
+		pragma string
+		dest   *int64
+	}{
+		{"page_size", &s.PageSize},
+		{"page_count", &s.Pages},
+		{"freelist_count", &s.FreePages},
+	} {
+		if err := db.QueryRow("PRAGMA " + q.pragma).Scan(q.dest); err != nil {
+			return s, err
+		}
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM jobs`).Scan(&s.Jobs); err != nil {
+		return s, err
+	}
+	s.Size = s.PageSize * s.Pages
+	return s, nil
+}
+
+// GC deletes what is recorded about jobs no longer in the database, rebuilds
+// the database to reclaim the space of deleted rows (VACUUM), and refreshes
+// the statistics the query planner uses (ANALYZE). It returns how many rows
+// about deleted jobs it deleted.
+func GC(db *sql.DB, now time.Time) (int64, error) {
+	orphans, err := deleteOrphans(db)
+	if err != nil {
+		return 0, fmt.Errorf("delete orphaned rows: %w", err)
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return orphans, fmt.Errorf("vacuum: %w", err)
+	}
+	if _, err := db.Exec(`ANALYZE`); err != nil {
+		return orphans, fmt.Errorf("analyze: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT OR REPLACE INTO maintenance (task, last_run) VALUES ('gc', ?)`, now.Unix(),
+	)
+	return orphans, err
+}
+
+// LastGC returns when GC last ran, or the zero time if it never has
+func LastGC(db *sql.DB) (time.Time, error) {
+	var lastRun int64
+	err := db.QueryRow(`SELECT last_run FROM maintenance WHERE task = 'gc'`).Scan(&lastRun)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(lastRun, 0), nil
+}
+
+// Check runs SQLite's integrity check, returning the problems it finds, or
+// nil if there are none
+func Check(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Backup writes a copy of the database into dir, named for now, and returns
+// its path. The copy is consistent even while other processes write to the
+// database, and compacted as VACUUM would.
+func Backup(db *sql.DB, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+	stamp := now.Format(backupTimeFormat)
+	path := filepath.Join(dir, "jobs-"+stamp+".db")
+	for seq := 2; ; seq++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("jobs-%s-%d.db", stamp, seq))
+	}
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return "", fmt.Errorf("back up database: %w", err)
+	}
+	// Backups of an encrypted database are encrypted too
+	if Encrypted() {
+		key, err := dbcrypt.Key()
+		if err == nil {
+			err = dbcrypt.SealFile(path, path, key)
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", fmt.Errorf("encrypt backup: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// ListBackups returns the backups in dir, newest first. A missing directory
+// has none.
+func ListBackups(dir string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []BackupFile
+	for _, entry := range entries {
+		t, seq, ok := parseBackupName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed since it was listed
+		}
+		backups = append(backups, BackupFile{Path: filepath.Join(dir, entry.Name()), Time: t, Seq: seq, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+		return backups[i].Seq > backups[j].Seq
+	})
+	return backups, nil
+}
+
+// parseBackupName returns when a backup was made, and its sequence number
+// within that second, from its file name. ok is false for other files.
+func parseBackupName(name string) (t time.Time, seq int, ok bool) {
+	stamp, found := strings.CutPrefix(name, "jobs-")
+	stamp, suffixed := strings.CutSuffix(stamp, ".db")
+	if !found || !suffixed || len(stamp) < len(backupTimeFormat) {
+		return time.Time{}, 0, false
+	}
+	seq = 1
+	if rest := stamp[len(backupTimeFormat):]; rest != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(rest, "-"))
+		if err != nil || !strings.HasPrefix(rest, "-") || n < 2 {
+			return time.Time{}, 0, false
+		}
+		seq = n
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local)
+	return t, seq, err == nil
+}
+
+// backupsToRemove returns the backups, newest first, beyond the newest keep
+func backupsToRemove(backups []BackupFile, keep int) []BackupFile {
+	if keep < 1 || len(backups) <= keep {
+		return nil
+	}
+	return backups[keep:]
+}
+
+// RotateBackups removes all but the newest keep backups in dir, returning
+// those it removed. A keep of less than one removes none.
+func RotateBackups(dir string, keep int) ([]BackupFile, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []BackupFile
+	for _, b := range backupsToRemove(backups, keep) {
+		if err := os.Remove(b.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed = append(removed, b)
+	}
+	return removed, nil
+}
+
+// Restore replaces the database with a backup, once it passes the
+// integrity check, and returns where the database it replaced was backed up
+// to, in dir. The database mustn't be open elsewhere. An encrypted backup
+// is decrypted, and an encrypted database stays encrypted.
+func Restore(backupPath, dir string, now time.Time) (string, error) {
+	if dbcrypt.IsSealedFile(backupPath) {
+		key, err := dbcrypt.Key()
+		if err != nil {
+			return "", err
+		}
+		runtimeDir, err := xdg.RuntimeDir()
+		if err != nil {
+			return "", err
+		}
+		plain := filepath.Join(runtimeDir, filepath.Base(backupPath)+".decrypted")
+		if err := dbcrypt.UnsealFile(backupPath, plain, key); err != nil {
+			return "", fmt.Errorf("decrypt backup: %w", err)
+		}
+		defer os.Remove(plain)
+		backupPath = plain
+	}
+
+	backup, err := sql.Open("sqlite", "file:"+backupPath+"?mode=ro")
+	if err != nil {
+		return "", fmt.Errorf("open backup: %w", err)
+	}
+	problems, err := Check(backup)
+	backup.Close()
+	if err != nil {
+		return "", fmt.Errorf("check backup: %w", err)
+	}
+	if len(problems) > 0 {
+		return "", fmt.Errorf("backup failed the integrity check: %s", problems[0])
+	}
+
+	current, err := Open()
+	if err != nil {
+		return "", err
+	}
+	saved, err := Backup(current, dir, now)
+	current.Close()
+	if err != nil {
+		return "", fmt.Errorf("back up the current database: %w", err)
+	}
+	if Encrypted() {
+		return saved, restoreEncrypted(backupPath)
+	}
+	return saved, copyFile(backupPath)
+}
+
+// restoreEncrypted replaces an encrypted database with an encrypted copy of
+// the file at path, and drops its decrypted copy
+func restoreEncrypted(path string) error {
+	key, err := dbcrypt.Key()
+	if err != nil {
+		return err
+	}
+	working, err := WorkingPath(EncryptedPath())
+	if err != nil {
+		return err
+	}
+	lock, err := lockExclusive(working)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := dbcrypt.SealFile(path, EncryptedPath(), key); err != nil {
+		return err
+	}
+	return removeDBFiles(working)
+}
+
+// copyFile replaces the database with a copy of the file at path
+func copyFile(path string) error {
+	// Copy beside the database, then rename over it, so that a failed copy
+	// leaves it as it was
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := Path() + ".restore"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, Path())
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// JobRequest is what a job asks of its host
+type JobRequest struct {
+	Needs   string   // Resources, in the form `run --needs` takes
+	Require []string // Host tags required with `run --require`
+	Avoid   []string // Host tags avoided with `run --avoid`
+}
+
+// SetJobRequest records what a job asks of its host
+func SetJobRequest(db *sql.DB, jobID int64, r JobRequest) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_needs (job_id, needs, require_tags, avoid_tags) VALUES (?, ?, ?, ?)`,
+		jobID, r.Needs, strings.Join(r.Require, ","), strings.Join(r.Avoid, ","),
+	)
+	return err
+}
+
+// GetJobRequest returns what a job asks of its host; the zero value if it
+// asked for nothing
+func GetJobRequest(db *sql.DB, jobID int64) (JobRequest, error) {
+	var r JobRequest
+	var require, avoid string
+	err := db.QueryRow(`SELECT needs, require_tags, avoid_tags FROM job_needs WHERE job_id = ?`, jobID).Scan(&r.Needs, &require, &avoid)
+	if err == sql.ErrNoRows {
+		return r, nil
+	}
+	if require != "" {
+		r.Require = strings.Split(require, ",")
+	}
+	if avoid != "" {
+		r.Avoid = strings.Split(avoid, ",")
+	}
+	return r, err
+}
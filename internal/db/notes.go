@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// JobNote is free-form Markdown text attached to a job, such as observations
+// about how a run went
+type JobNote struct {
+	Text      string
+	UpdatedAt int64
+}
+
+// SetJobNote replaces a job's note; an empty or blank note removes it
+func SetJobNote(db *sql.DB, jobID int64, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		_, err := db.Exec(`DELETE FROM job_notes WHERE job_id = ?`, jobID)
+		return err
+	}
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_notes (job_id, note, updated_at) VALUES (?, ?, ?)`,
+		jobID, text, time.Now().Unix(),
+	)
+	return err
+}
+
+// GetJobNote returns a job's note, or nil if it has none
+func GetJobNote(db *sql.DB, jobID int64) (*JobNote, error) {
+	var note JobNote
+	err := db.QueryRow(
+		`SELECT note, updated_at FROM job_notes WHERE job_id = ?`, jobID,
+	).Scan(&note.Text, &note.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
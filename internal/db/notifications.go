@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Notification is a finished job that a Slack notifier has yet to send
+type Notification struct {
+	JobID    int64
+	QueuedAt int64
+}
+
+// QueueNotification adds a finished job to those a notifier, identified by
+// its URL, has yet to send
+func QueueNotification(db *sql.DB, notifier string, jobID int64, at int64) error {
+	_, err := db.Exec(
+		`INSERT OR IGNORE INTO notifications (notifier, job_id, queued_at) VALUES (?, ?, ?)`,
+		notifier, jobID, at,
+	)
+	return err
+}
+
+// PendingNotifications returns the jobs a notifier has yet to send, in the
+// order they were queued
+func PendingNotifications(db *sql.DB, notifier string) ([]Notification, error) {
+	rows, err := db.Query(
+		`SELECT job_id, queued_at FROM notifications WHERE notifier = ? ORDER BY queued_at, job_id`, notifier,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pending []Notification
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.JobID, &n.QueuedAt); err != nil {
+			return nil, err
+		}
+		pending = append(pending, n)
+	}
+	return pending, rows.Err()
+}
+
+// ClaimNotifications removes the jobs a notifier is about to send from
+// those it has yet to send, and records that it sent them at a time, so that
+// another process doesn't send them too. It reports false, changing
+// nothing, if another process has already claimed some of them. Sends older
+// than a week, which no longer count toward rate limits or digests, are
+// forgotten.
+func ClaimNotifications(db *sql.DB, notifier string, jobIDs []int64, at int64) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	n, err := dropNotificationRows(tx, notifier, jobIDs)
+	if err != nil || n != int64(len(jobIDs)) {
+		return false, err
+	}
+	if _, err := tx.Exec(`INSERT INTO notifier_sends (notifier, sent_at) VALUES (?, ?)`, notifier, at); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM notifier_sends WHERE sent_at < ?`, at-7*24*60*60); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// UnclaimNotifications undoes ClaimNotifications for a message that
+// couldn't be sent, so that its jobs are sent the next time
+func UnclaimNotifications(db *sql.DB, notifier string, pending []Notification, at int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, n := range pending {
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO notifications (notifier, job_id, queued_at) VALUES (?, ?, ?)`,
+			notifier, n.JobID, n.QueuedAt,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		`DELETE FROM notifier_sends WHERE rowid IN (SELECT rowid FROM notifier_sends WHERE notifier = ? AND sent_at = ? LIMIT 1)`,
+		notifier, at,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DropNotifications removes jobs from those a notifier has yet to send,
+// without recording a message
+func DropNotifications(db *sql.DB, notifier string, jobIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := dropNotificationRows(tx, notifier, jobIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dropNotificationRows removes jobs from those a notifier has yet to send,
+// and returns how many it removed
+func dropNotificationRows(tx *sql.Tx, notifier string, jobIDs []int64) (int64, error) {
+	if len(jobIDs) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(jobIDs)), ",")
+	args := []interface{}{notifier}
+	for _, id := range jobIDs {
+		args = append(args, id)
+	}
+	result, err := tx.Exec(`DELETE FROM notifications WHERE notifier = ? AND job_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// NotifierSends returns how many messages a notifier has sent since a time,
+// and when it last sent one (0 if it hasn't in the last week)
+func NotifierSends(db *sql.DB, notifier string, since int64) (count int, last int64, err error) {
+	var lastSent sql.NullInt64
+	err = db.QueryRow(
+		`SELECT COUNT(CASE WHEN sent_at >= ? THEN 1 END), MAX(sent_at) FROM notifier_sends WHERE notifier = ?`,
+		since, notifier,
+	).Scan(&count, &lastSent)
+	return count, lastSent.Int64, err
+}
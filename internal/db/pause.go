@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// JobPause records when a job was suspended, and which job preempted it (if any)
+type JobPause struct {
+	JobID       int64
+	PausedAt    int64
+	PreemptedBy int64 // 0 if the job was paused directly
+}
+
+// MarkPaused changes a running job's status to paused. preemptedBy is the
+// job started in its place, or 0.
+func MarkPaused(db *sql.DB, id, preemptedBy int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	changed, err := transitionJobTx(tx, id, []Status{StatusRunning}, StatusPaused, "")
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return fmt.Errorf("job %d is not running", id)
+	}
+	if _, err := tx.Exec(
+		`INSERT OR REPLACE INTO job_pauses (job_id, paused_at, preempted_by) VALUES (?, ?, ?)`,
+		id, time.Now().Unix(), nullInt64(preemptedBy),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkResumed changes a paused job's status back to running
+func MarkResumed(db *sql.DB, id int64) error {
+	if _, err := transitionJob(db, id, []Status{StatusPaused}, StatusRunning, ""); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM job_pauses WHERE job_id = ?`, id)
+	return err
+}
+
+// GetJobPause returns the pause record for a job, or nil if it has none
+func GetJobPause(db *sql.DB, id int64) (*JobPause, error) {
+	var p JobPause
+	var preemptedBy sql.NullInt64
+	err := db.QueryRow(
+		`SELECT job_id, paused_at, preempted_by FROM job_pauses WHERE job_id = ?`, id,
+	).Scan(&p.JobID, &p.PausedAt, &preemptedBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.PreemptedBy = preemptedBy.Int64
+	return &p, nil
+}
+
+// ListJobPauses returns the pause records of all paused jobs, keyed by job ID
+func ListJobPauses(db *sql.DB) (map[int64]*JobPause, error) {
+	rows, err := db.Query(`SELECT job_id, paused_at, preempted_by FROM job_pauses`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pauses := make(map[int64]*JobPause)
+	for rows.Next() {
+		var p JobPause
+		var preemptedBy sql.NullInt64
+		if err := rows.Scan(&p.JobID, &p.PausedAt, &preemptedBy); err != nil {
+			return nil, err
+		}
+		p.PreemptedBy = preemptedBy.Int64
+		pauses[p.JobID] = &p
+	}
+	return pauses, rows.Err()
+}
+
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
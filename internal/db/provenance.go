@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// GitRevision is the commit checked out in a job's working directory when it
+// started
+type GitRevision struct {
+	Commit string
+	Dirty  bool // The work tree had uncommitted changes
+}
+
+// String describes the revision, e.g. "3f2a9c1d0b7e (uncommitted changes)"
+func (r *GitRevision) String() string {
+	commit := r.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if r.Dirty {
+		return commit + " (uncommitted changes)"
+	}
+	return commit
+}
+
+// Short describes the revision briefly for tables, e.g. "3f2a9c1d", with a
+// "*" if the work tree had uncommitted changes
+func (r *GitRevision) Short() string {
+	commit := r.Commit
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	if r.Dirty {
+		return commit + "*"
+	}
+	return commit
+}
+
+// SetJobEnv records the environment variables ("VAR=value") a job was
+// started with
+func SetJobEnv(db *sql.DB, jobID int64, envVars []string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_env (job_id, env_vars) VALUES (?, ?)`,
+		jobID, strings.Join(envVars, "\n"),
+	)
+	return err
+}
+
+// GetJobEnv returns the environment variables recorded for a job, and those
+// exported at the start of its command by older versions
+func GetJobEnv(db *sql.DB, job *Job) ([]string, error) {
+	envVars := job.ParseExportVars()
+	var recorded string
+	err := db.QueryRow(`SELECT env_vars FROM job_env WHERE job_id = ?`, job.ID).Scan(&recorded)
+	if err == sql.ErrNoRows {
+		return envVars, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recorded != "" {
+		envVars = append(envVars, strings.Split(recorded, "\n")...)
+	}
+	return envVars, nil
+}
+
+// SetJobSecrets records the names of the secrets a job was started with.
+// Their values are never stored.
+func SetJobSecrets(db *sql.DB, jobID int64, names []string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_secrets (job_id, names) VALUES (?, ?)`,
+		jobID, strings.Join(names, "\n"),
+	)
+	return err
+}
+
+// GetJobSecrets returns the names of the secrets a job was started with
+func GetJobSecrets(db *sql.DB, jobID int64) ([]string, error) {
+	var names string
+	err := db.QueryRow(`SELECT names FROM job_secrets WHERE job_id = ?`, jobID).Scan(&names)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if names == "" {
+		return nil, nil
+	}
+	return strings.Split(names, "\n"), nil
+}
+
+// RecordJobGit records the git revision of a job's working directory
+func RecordJobGit(db *sql.DB, jobID int64, rev GitRevision) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_git (job_id, git_commit, dirty) VALUES (?, ?, ?)`,
+		jobID, rev.Commit, rev.Dirty,
+	)
+	return err
+}
+
+// GetJobGit returns the git revision recorded for a job, or nil if its
+// working directory wasn't a git repository or it was never recorded
+func GetJobGit(db *sql.DB, jobID int64) (*GitRevision, error) {
+	var rev GitRevision
+	err := db.QueryRow(
+		`SELECT git_commit, dirty FROM job_git WHERE job_id = ?`, jobID,
+	).Scan(&rev.Commit, &rev.Dirty)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueueSettings are the settings of a queued job that the queue runner
+// applies when it starts the job, as run applies them to a job started now.
+// The Slack settings are those in effect when the job was queued, which the
+// runner uses instead of its own, and the hooks are the host's pre_start and
+// post_finish when it was queued.
+type QueueSettings struct {
+	Timeout          string // Kill the job after this long, e.g. "2h"; "" for no limit
+	SlackNotify      string // When to notify: all, failures, or none; "" for all
+	SlackMinDuration string // Minimum duration of a job to notify about, in seconds
+	SlackVerbose     bool   // Include the directory and command in the message
+	PreStart         string // Remote hook run before the job; the job is skipped if it fails
+	PostFinish       string // Remote hook run after the job, with $REMOTE_JOBS_EXIT_CODE set
+}
+
+// ValidateTimeout returns an error if timeout isn't a duration such as 2h,
+// 30m, or 1h30m
+func ValidateTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout %q (expected e.g. 2h, 30m, or 1h30m)", timeout)
+	}
+	return nil
+}
+
+// SetJobQueueSettings records a queued job's settings
+func SetJobQueueSettings(db *sql.DB, jobID int64, s QueueSettings) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_queue_settings (job_id, timeout, slack_notify, slack_min_duration, slack_verbose, pre_start, post_finish)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		jobID, s.Timeout, s.SlackNotify, s.SlackMinDuration, s.SlackVerbose, s.PreStart, s.PostFinish,
+	)
+	return err
+}
+
+// GetJobQueueSettings returns a queued job's settings, or nil if none were
+// recorded, as for jobs queued before they were
+func GetJobQueueSettings(db *sql.DB, jobID int64) (*QueueSettings, error) {
+	var s QueueSettings
+	err := db.QueryRow(
+		`SELECT timeout, slack_notify, slack_min_duration, slack_verbose, pre_start, post_finish FROM job_queue_settings WHERE job_id = ?`, jobID,
+	).Scan(&s.Timeout, &s.SlackNotify, &s.SlackMinDuration, &s.SlackVerbose, &s.PreStart, &s.PostFinish)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Encode formats the settings as the settings_b64 field of a queue line:
+// base64-encoded key=value lines. Only the settings that are set are given,
+// so that the runner keeps its own for the others. The timeout is also given
+// in seconds, for the runner's timer, and the hooks, which can span lines,
+// are base64-encoded again.
+func (s QueueSettings) Encode() string {
+	var lines []string
+	if d, err := time.ParseDuration(s.Timeout); err == nil && d > 0 {
+		seconds := int64((d + time.Second - 1) / time.Second)
+		lines = append(lines, "timeout="+s.Timeout, fmt.Sprintf("timeout_seconds=%d", seconds))
+	}
+	if s.SlackNotify != "" {
+		lines = append(lines, "slack_notify="+s.SlackNotify)
+	}
+	if s.SlackMinDuration != "" {
+		lines = append(lines, "slack_min_duration="+s.SlackMinDuration)
+	}
+	if s.SlackVerbose {
+		lines = append(lines, "slack_verbose=1")
+	}
+	if s.PreStart != "" {
+		lines = append(lines, "pre_start_b64="+base64.StdEncoding.EncodeToString([]byte(s.PreStart)))
+	}
+	if s.PostFinish != "" {
+		lines = append(lines, "post_finish_b64="+base64.StdEncoding.EncodeToString([]byte(s.PostFinish)))
+	}
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(lines, "\n")))
+}
+
+// ParseQueueSettings parses the settings_b64 field of a queue line. Returns
+// nil if the field is empty or malformed.
+func ParseQueueSettings(field string) *QueueSettings {
+	if field == "" {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(field)
+	if err != nil {
+		return nil
+	}
+	var s QueueSettings
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "timeout":
+			s.Timeout = value
+		case "slack_notify":
+			s.SlackNotify = value
+		case "slack_min_duration":
+			s.SlackMinDuration = value
+		case "slack_verbose":
+			s.SlackVerbose, _ = strconv.ParseBool(value)
+		case "pre_start_b64":
+			hook, _ := base64.StdEncoding.DecodeString(value)
+			s.PreStart = string(hook)
+		case "post_finish_b64":
+			hook, _ := base64.StdEncoding.DecodeString(value)
+			s.PostFinish = string(hook)
+		}
+	}
+	return &s
+}
+
+// ParseQueueWeights parses the shared queue runner's weights file: one
+// name=weight line per queue. It reads the file as the runner does: a weight
+// must be a whole number of at least 1, the first line for a queue counts,
+// and other lines are ignored.
+func ParseQueueWeights(content string) map[string]int {
+	weights := make(map[string]int)
+	for _, line := range strings.Split(content, "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		value, _, _ = strings.Cut(value, "=")
+		if !ok || name == "" || value == "" || strings.Trim(value, "0123456789") != "" {
+			continue
+		}
+		if _, seen := weights[name]; seen {
+			continue
+		}
+		if weight, err := strconv.Atoi(value); err == nil && weight > 0 {
+			weights[name] = weight
+		}
+	}
+	return weights
+}
+
+// QueueWeight returns a queue's weight, defaulting to 1
+func QueueWeight(weights map[string]int, name string) int {
+	if w, ok := weights[name]; ok {
+		return w
+	}
+	return 1
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// HostReachability is the result of the last SSH attempt on a host
+type HostReachability struct {
+	Host      string
+	Reachable bool
+	Failures  int    // Consecutive failed attempts
+	LastError string // Error from the last failed attempt
+	CheckedAt int64
+	RetryAt   int64 // When to try an unreachable host again
+}
+
+// GetHostReachability returns the last recorded reachability of a host, or
+// nil if none was recorded
+func GetHostReachability(db *sql.DB, host string) (*HostReachability, error) {
+	r := &HostReachability{Host: host}
+	var lastError sql.NullString
+	err := db.QueryRow(
+		`SELECT reachable, failures, last_error, checked_at, retry_at FROM host_reachability WHERE host = ?`, host,
+	).Scan(&r.Reachable, &r.Failures, &lastError, &r.CheckedAt, &r.RetryAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.LastError = lastError.String
+	return r, nil
+}
+
+// ListHostReachability returns the last recorded reachability of every host, by host
+func ListHostReachability(db *sql.DB) (map[string]*HostReachability, error) {
+	rows, err := db.Query(`SELECT host, reachable, failures, last_error, checked_at, retry_at FROM host_reachability`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]*HostReachability)
+	for rows.Next() {
+		r := &HostReachability{}
+		var lastError sql.NullString
+		if err := rows.Scan(&r.Host, &r.Reachable, &r.Failures, &lastError, &r.CheckedAt, &r.RetryAt); err != nil {
+			return nil, err
+		}
+		r.LastError = lastError.String
+		result[r.Host] = r
+	}
+	return result, rows.Err()
+}
+
+// RecordHostReachable records that a host answered, resetting its failures
+func RecordHostReachable(db *sql.DB, host string, checkedAt int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := logHostChange(tx, host, true, checkedAt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT OR REPLACE INTO host_reachability (host, reachable, failures, last_error, checked_at, retry_at)
+		 VALUES (?, 1, 0, NULL, ?, 0)`,
+		host, checkedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordHostUnreachable records a failed attempt on a host, and when to try
+// it again
+func RecordHostUnreachable(db *sql.DB, host, lastError string, checkedAt, retryAt int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := logHostChange(tx, host, false, checkedAt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO host_reachability (host, reachable, failures, last_error, checked_at, retry_at)
+		 VALUES (?, 0, 1, ?, ?, ?)
+		 ON CONFLICT(host) DO UPDATE SET
+		   reachable = 0, failures = failures + 1, last_error = excluded.last_error,
+		   checked_at = excluded.checked_at, retry_at = excluded.retry_at`,
+		host, lastError, checkedAt, retryAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
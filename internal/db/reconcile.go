@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// Evidence is one check made of a job on its host, and what it found
+type Evidence struct {
+	Check  string `json:"check"`  // e.g. "tmux session rj-42"
+	Result string `json:"result"` // e.g. "missing"
+}
+
+// Reconciliation is a change to a job's status that a check of its host
+// decided on, with the evidence for it: the checks that ran, in order
+type Reconciliation struct {
+	At       int64
+	Source   string // What ran the checks, e.g. "sync" or "tui"
+	From     Status
+	To       Status
+	Evidence []Evidence
+}
+
+// MarkDeadWithEvidence marks a running, paused, or queued job as dead, as
+// MarkDeadByID does, and records in the reconciliation log what source
+// found that led to it
+func MarkDeadWithEvidence(db *sql.DB, id int64, source string, evidence []Evidence) error {
+	return reconcile(db, id, []Status{StatusRunning, StatusQueued, StatusPaused}, StatusDead,
+		source, evidence, "end_time = ?", time.Now().Unix())
+}
+
+// ReviveWithEvidence changes a job that was wrongly marked dead back to
+// running, and records what source found that showed it was still running
+func ReviveWithEvidence(db *sql.DB, id int64, source string, evidence []Evidence) error {
+	return reconcile(db, id, []Status{StatusDead}, StatusRunning, source, evidence, "end_time = NULL")
+}
+
+// reconcile makes a status transition as transitionJob does, and records it
+// with its evidence if the job changed
+func reconcile(db *sql.DB, id int64, from []Status, to Status, source string, evidence []Evidence, set string, args ...any) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := reconcileTx(tx, id, from, to, source, evidence, set, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// reconcileTx is reconcile within a transaction
+func reconcileTx(tx *sql.Tx, id int64, from []Status, to Status, source string, evidence []Evidence, set string, args ...any) error {
+	data, err := json.Marshal(evidence)
+	if err != nil {
+		return err
+	}
+	var current Status
+	if err := tx.QueryRow(`SELECT status FROM jobs WHERE id = ?`, id).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	changed, err := transitionJobTx(tx, id, from, to, set, args...)
+	if err != nil || !changed {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO job_reconciliations (job_id, checked_at, source, from_status, to_status, evidence)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		id, time.Now().Unix(), source, current, to, string(data),
+	)
+	return err
+}
+
+// MarkDeadAfterGrace records that a check found a job dead, and marks it
+// dead, as MarkDeadWithEvidence does, once at least probes consecutive
+// checks over at least grace have found it so. It reports whether the job
+// was marked dead. A check that finds the job alive ends the run of checks;
+// see ClearDeadProbes.
+func MarkDeadAfterGrace(db *sql.DB, id int64, source string, evidence []Evidence, probes int, grace time.Duration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	now := time.Now().Unix()
+	if _, err := tx.Exec(
+		`INSERT INTO job_dead_probes (job_id, first_at, last_at, probes) VALUES (?, ?, ?, 1)
+		 ON CONFLICT(job_id) DO UPDATE SET last_at = excluded.last_at, probes = probes + 1`,
+		id, now, now,
+	); err != nil {
+		return false, err
+	}
+	var firstAt int64
+	var count int
+	if err := tx.QueryRow(`SELECT first_at, probes FROM job_dead_probes WHERE job_id = ?`, id).Scan(&firstAt, &count); err != nil {
+		return false, err
+	}
+	elapsed := time.Duration(now-firstAt) * time.Second
+	if count < probes || elapsed < grace {
+		return false, tx.Commit()
+	}
+	evidence = append(evidence, Evidence{
+		Check:  "consecutive checks",
+		Result: fmt.Sprintf("%d over %s found the same", count, humanfmt.Duration(now-firstAt)),
+	})
+	if err := reconcileTx(tx, id, []Status{StatusRunning, StatusQueued, StatusPaused}, StatusDead,
+		source, evidence, "end_time = ?", now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ClearDeadProbes ends the runs of checks that found jobs dead for those of
+// ids, jobs that a sync that started at since checked, that the sync didn't
+// find dead: those with no such check recorded since then
+func ClearDeadProbes(db *sql.DB, since int64, ids []int64) error {
+	for _, id := range ids {
+		if _, err := db.Exec(`DELETE FROM job_dead_probes WHERE job_id = ? AND last_at < ?`, id, since); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReconciliations returns the status changes made to a job by checks of
+// its host, oldest first
+func GetReconciliations(db *sql.DB, id int64) ([]Reconciliation, error) {
+	rows, err := db.Query(
+		`SELECT checked_at, source, from_status, to_status, evidence FROM job_reconciliations
+		 WHERE job_id = ? ORDER BY id`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []Reconciliation
+	for rows.Next() {
+		var r Reconciliation
+		var data string
+		if err := rows.Scan(&r.At, &r.Source, &r.From, &r.To, &data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &r.Evidence); err != nil {
+			return nil, err
+		}
+		recs = append(recs, r)
+	}
+	return recs, rows.Err()
+}
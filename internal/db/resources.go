@@ -0,0 +1,171 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// JobStats holds timing and last-known resource usage for a job, kept outside
+// the main jobs columns so the common job queries stay unchanged
+type JobStats struct {
+	JobID     int64
+	QueuedAt  int64  // When the job record was created (0 if unknown)
+	MemoryRSS string // Last sampled resident memory, e.g. "1.2GB"
+	GPUMemory string // Last sampled GPU memory per device, e.g. "0:1234MiB 1:2000MiB"
+	SampledAt int64  // When the resources were sampled (0 if never)
+
+	GPUIdleSince     int64 // When the job's GPUs were first seen idle (0 if not idle)
+	GPUIdleFlaggedAt int64 // When the idle-GPU watchdog flagged the job (0 if not flagged)
+
+	AckedAt int64 // When the user last acknowledged the job's alerts (0 if never)
+
+	HostUTCOffset *int // Host's time zone offset from UTC in seconds, if recorded at start
+}
+
+// IdleGPU reports whether the watchdog has flagged the job for idle GPUs,
+// and the flag hasn't been acknowledged since
+func (s *JobStats) IdleGPU() bool {
+	return s != nil && s.GPUIdleFlaggedAt > s.AckedAt
+}
+
+// Acked reports whether the job's outcome has been acknowledged: whether it
+// was acknowledged after it ended, so that a failure no longer counts as an
+// alert
+func (s *JobStats) Acked(job *Job) bool {
+	return s != nil && s.AckedAt > 0 && (job.EndTime == nil || *job.EndTime <= s.AckedAt)
+}
+
+// SaveJobResources records the latest resource sample for a running job
+func SaveJobResources(db *sql.DB, jobID int64, memoryRSS, gpuMemory string) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_resources (job_id, memory_rss, gpu_memory, sampled_at)
+		VALUES (?, ?, ?, ?)`,
+		jobID, nullString(memoryRSS), nullString(gpuMemory), time.Now().Unix(),
+	)
+	return err
+}
+
+// RecordGPUIdle records whether a running job's GPUs were idle when sampled at
+// now. It returns when they were first seen idle in the current stretch, or 0
+// if they are busy.
+func RecordGPUIdle(db *sql.DB, jobID int64, idle bool, now int64) (int64, error) {
+	if !idle {
+		_, err := db.Exec(`DELETE FROM job_gpu_idle WHERE job_id = ?`, jobID)
+		return 0, err
+	}
+	if _, err := db.Exec(
+		`INSERT OR IGNORE INTO job_gpu_idle (job_id, idle_since) VALUES (?, ?)`, jobID, now,
+	); err != nil {
+		return 0, err
+	}
+	var since int64
+	err := db.QueryRow(`SELECT idle_since FROM job_gpu_idle WHERE job_id = ?`, jobID).Scan(&since)
+	return since, err
+}
+
+// ClearStaleGPUIdle forgets the idle stretches of jobs that are no longer
+// running. A paused job starts a new stretch when it is resumed.
+func ClearStaleGPUIdle(db *sql.DB) error {
+	_, err := db.Exec(
+		`DELETE FROM job_gpu_idle WHERE job_id NOT IN (SELECT id FROM jobs WHERE status = ?)`, StatusRunning,
+	)
+	return err
+}
+
+// FlagGPUIdle marks a job as flagged by the idle-GPU watchdog. It returns
+// false if the job was already flagged during this idle stretch, so that the
+// watchdog acts only once.
+func FlagGPUIdle(db *sql.DB, jobID int64, now int64) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE job_gpu_idle SET flagged_at = ? WHERE job_id = ? AND flagged_at IS NULL`, now, jobID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// LoadJobStats returns timing and resource info for the given jobs, keyed by job ID.
+// Jobs with nothing recorded are omitted.
+func LoadJobStats(db *sql.DB, jobIDs []int64) (map[int64]*JobStats, error) {
+	stats := make(map[int64]*JobStats)
+	if len(jobIDs) == 0 {
+		return stats, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(jobIDs)), ",")
+	args := make([]interface{}, len(jobIDs))
+	for i, id := range jobIDs {
+		args[i] = id
+	}
+
+	rows, err := db.Query(
+		`SELECT j.id, j.created_at, r.memory_rss, r.gpu_memory, r.sampled_at, g.idle_since, g.flagged_at, c.remote_utc_offset, a.acked_at
+		FROM jobs j
+		LEFT JOIN job_resources r ON r.job_id = j.id
+		LEFT JOIN job_gpu_idle g ON g.job_id = j.id
+		LEFT JOIN job_clocks c ON c.job_id = j.id
+		LEFT JOIN job_acks a ON a.job_id = j.id
+		WHERE j.id IN (`+placeholders+`)
+		AND (j.created_at IS NOT NULL OR r.job_id IS NOT NULL OR g.job_id IS NOT NULL OR c.job_id IS NOT NULL OR a.job_id IS NOT NULL)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s JobStats
+		var queuedAt, sampledAt, idleSince, flaggedAt, utcOffset, ackedAt sql.NullInt64
+		var memoryRSS, gpuMemory sql.NullString
+		if err := rows.Scan(&s.JobID, &queuedAt, &memoryRSS, &gpuMemory, &sampledAt, &idleSince, &flaggedAt, &utcOffset, &ackedAt); err != nil {
+			return nil, err
+		}
+		s.QueuedAt = queuedAt.Int64
+		s.MemoryRSS = memoryRSS.String
+		s.GPUMemory = gpuMemory.String
+		s.SampledAt = sampledAt.Int64
+		s.GPUIdleSince = idleSince.Int64
+		s.GPUIdleFlaggedAt = flaggedAt.Int64
+		s.AckedAt = ackedAt.Int64
+		if utcOffset.Valid {
+			offset := int(utcOffset.Int64)
+			s.HostUTCOffset = &offset
+		}
+		stats[s.JobID] = &s
+	}
+	return stats, rows.Err()
+}
+
+// Elapsed returns how long a job has run: up to now for running jobs, or
+// start to end for finished ones. It returns -1 if the job hasn't started.
+func (j *Job) Elapsed(now int64) int64 {
+	if j.StartTime <= 0 || j.Status == StatusQueued || j.Status == StatusPending {
+		return -1
+	}
+	if j.EndTime != nil {
+		return *j.EndTime - j.StartTime
+	}
+	if j.Status == StatusRunning || j.Status == StatusStarting || j.Status == StatusPaused {
+		return now - j.StartTime
+	}
+	return -1
+}
+
+// QueueWait returns how long a job waited (or has been waiting) between being
+// queued and starting. It returns -1 if unknown or the job didn't go through a queue.
+func (s *JobStats) QueueWait(job *Job, now int64) int64 {
+	if s == nil || s.QueuedAt <= 0 || job.QueueName == "" {
+		return -1
+	}
+	if job.Status == StatusQueued || job.StartTime <= 0 {
+		return now - s.QueuedAt
+	}
+	if job.StartTime < s.QueuedAt {
+		return -1
+	}
+	return job.StartTime - s.QueuedAt
+}
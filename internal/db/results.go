@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// ResultSpec says where a job's result metrics are read from when it
+// finishes: regexes matched against the end of its log, and a JSON file
+type ResultSpec struct {
+	JobID    int64
+	Patterns []string // Regexes whose named groups are metrics
+	File     string   // JSON file, relative to the working directory
+}
+
+// IsZero reports whether the spec reads no results
+func (s ResultSpec) IsZero() bool {
+	return len(s.Patterns) == 0 && s.File == ""
+}
+
+// SetResultSpec records where a job's results are read from when it finishes
+func SetResultSpec(db *sql.DB, jobID int64, spec ResultSpec) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_result_specs (job_id, patterns, file, resolved_at) VALUES (?, ?, ?, 0)`,
+		jobID, strings.Join(spec.Patterns, "\n"), spec.File,
+	)
+	return err
+}
+
+// GetResultSpec returns where a job's results are read from, or nil if it
+// declared none, and whether they've been read
+func GetResultSpec(db *sql.DB, jobID int64) (*ResultSpec, bool, error) {
+	spec := ResultSpec{JobID: jobID}
+	var patterns string
+	var resolvedAt int64
+	err := db.QueryRow(
+		`SELECT patterns, file, resolved_at FROM job_result_specs WHERE job_id = ?`, jobID,
+	).Scan(&patterns, &spec.File, &resolvedAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if patterns != "" {
+		spec.Patterns = strings.Split(patterns, "\n")
+	}
+	return &spec, resolvedAt > 0, nil
+}
+
+// ListUnresolvedResults returns the specs of finished jobs on a host whose
+// results haven't been read
+func ListUnresolvedResults(db *sql.DB, host string) ([]ResultSpec, error) {
+	rows, err := db.Query(
+		`SELECT r.job_id, r.patterns, r.file FROM job_result_specs r JOIN jobs j ON j.id = r.job_id
+		 WHERE r.resolved_at = 0 AND j.host = ? AND j.status IN (?, ?)
+		 ORDER BY r.job_id`,
+		host, StatusCompleted, StatusDead,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var specs []ResultSpec
+	for rows.Next() {
+		var spec ResultSpec
+		var patterns string
+		if err := rows.Scan(&spec.JobID, &patterns, &spec.File); err != nil {
+			return nil, err
+		}
+		if patterns != "" {
+			spec.Patterns = strings.Split(patterns, "\n")
+		}
+		specs = append(specs, spec)
+	}
+	return specs, rows.Err()
+}
+
+// RecordResults replaces a job's result metrics and marks its spec resolved
+func RecordResults(db *sql.DB, jobID int64, metrics map[string]float64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM job_results WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+	for name, value := range metrics {
+		if _, err := tx.Exec(
+			`INSERT INTO job_results (job_id, name, value) VALUES (?, ?, ?)`, jobID, name, value,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(
+		`UPDATE job_result_specs SET resolved_at = ? WHERE job_id = ?`, time.Now().Unix(), jobID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LoadJobResults returns the result metrics of the given jobs, keyed by job
+// ID; jobs without results are omitted
+func LoadJobResults(db *sql.DB, jobIDs []int64) (map[int64]map[string]float64, error) {
+	results := make(map[int64]map[string]float64)
+	if len(jobIDs) == 0 {
+		return results, nil
+	}
+	placeholders := strings.Repeat("?,", len(jobIDs))
+	args := make([]any, len(jobIDs))
+	for i, id := range jobIDs {
+		args[i] = id
+	}
+	rows, err := db.Query(
+		`SELECT job_id, name, value FROM job_results WHERE job_id IN (`+placeholders[:len(placeholders)-1]+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID int64
+		var name string
+		var value float64
+		if err := rows.Scan(&jobID, &name, &value); err != nil {
+			return nil, err
+		}
+		if results[jobID] == nil {
+			results[jobID] = make(map[string]float64)
+		}
+		results[jobID][name] = value
+	}
+	return results, rows.Err()
+}
+
+// GetJobResults returns a job's result metrics
+func GetJobResults(db *sql.DB, jobID int64) (map[string]float64, error) {
+	results, err := LoadJobResults(db, []int64{jobID})
+	if err != nil {
+		return nil, err
+	}
+	return results[jobID], nil
+}
+
+// ListResultNames returns the names of the metrics recorded for any job
+func ListResultNames(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT name FROM job_results ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ListJobsWithResult returns the jobs that completed successfully and
+// recorded metric, optionally only those on host or tagged with tag
+func ListJobsWithResult(db *sql.DB, metric, host, tag string) ([]*Job, error) {
+	query := `SELECT id, host, session_name, working_dir, command, description, start_time, end_time, exit_code, status, error_message, queue_name, remote_user FROM jobs
+		WHERE status = ? AND exit_code = 0 AND id IN (SELECT job_id FROM job_results WHERE name = ?)`
+	args := []interface{}{StatusCompleted, metric}
+	if host != "" {
+		query += ` AND host = ?`
+		args = append(args, host)
+	}
+	if tag != "" {
+		query += ` AND id IN (SELECT job_id FROM job_tags WHERE tag = ?)`
+		args = append(args, tag)
+	}
+	query += ` ORDER BY id DESC`
+	return queryJobs(db, query, args...)
+}
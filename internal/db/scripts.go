@@ -0,0 +1,37 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// JobScript records the script a job ran, for provenance
+type JobScript struct {
+	JobID   int64
+	Name    string
+	SHA256  string
+	Content string
+}
+
+// SaveJobScript records the script a job runs
+func SaveJobScript(db *sql.DB, s JobScript) error {
+	_, err := db.Exec(
+		`INSERT OR REPLACE INTO job_scripts (job_id, name, sha256, content) VALUES (?, ?, ?, ?)`,
+		s.JobID, s.Name, s.SHA256, s.Content,
+	)
+	return err
+}
+
+// GetJobScript returns the script a job ran, or nil if it ran a command line
+func GetJobScript(db *sql.DB, jobID int64) (*JobScript, error) {
+	s := JobScript{JobID: jobID}
+	err := db.QueryRow(
+		`SELECT name, sha256, content FROM job_scripts WHERE job_id = ?`, jobID,
+	).Scan(&s.Name, &s.SHA256, &s.Content)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
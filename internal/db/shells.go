@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Statuses of an interactive shell session
+const (
+	ShellActive = "active" // The tmux session exists
+	ShellEnded  = "ended"  // The shell exited or its session was killed
+)
+
+// ShellSession is a named interactive tmux session on a host, started with
+// the shell command
+type ShellSession struct {
+	Host           string
+	Name           string
+	WorkingDir     string
+	CreatedAt      int64
+	LastAttachedAt int64
+	EndedAt        int64 // 0 while the session exists
+}
+
+// Status returns ShellActive or ShellEnded
+func (s *ShellSession) Status() string {
+	if s.EndedAt > 0 {
+		return ShellEnded
+	}
+	return ShellActive
+}
+
+// RecordShellAttach records attaching to a shell session. A session that is
+// new, or that had ended, is recorded as created now in workingDir.
+func RecordShellAttach(db *sql.DB, host, name, workingDir string) error {
+	now := time.Now().Unix()
+	_, err := db.Exec(
+		`INSERT INTO shell_sessions (host, name, working_dir, created_at, last_attached_at, ended_at)
+		 VALUES (?, ?, ?, ?, ?, 0)
+		 ON CONFLICT (host, name) DO UPDATE SET
+			working_dir = CASE WHEN ended_at > 0 THEN excluded.working_dir ELSE working_dir END,
+			created_at = CASE WHEN ended_at > 0 THEN excluded.created_at ELSE created_at END,
+			last_attached_at = excluded.last_attached_at,
+			ended_at = 0`,
+		host, name, workingDir, now, now,
+	)
+	return err
+}
+
+// MarkShellEnded records that a shell session no longer exists
+func MarkShellEnded(db *sql.DB, host, name string) error {
+	_, err := db.Exec(
+		`UPDATE shell_sessions SET ended_at = ? WHERE host = ? AND name = ? AND ended_at = 0`,
+		time.Now().Unix(), host, name,
+	)
+	return err
+}
+
+// GetShellSession returns a shell session, or nil if it was never recorded
+func GetShellSession(db *sql.DB, host, name string) (*ShellSession, error) {
+	var s ShellSession
+	err := db.QueryRow(
+		`SELECT host, name, working_dir, created_at, last_attached_at, ended_at
+		 FROM shell_sessions WHERE host = ? AND name = ?`, host, name,
+	).Scan(&s.Host, &s.Name, &s.WorkingDir, &s.CreatedAt, &s.LastAttachedAt, &s.EndedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListShellSessions returns the shell sessions on a host, or on all hosts if
+// host is "", most recently attached first
+func ListShellSessions(db *sql.DB, host string) ([]*ShellSession, error) {
+	rows, err := db.Query(
+		`SELECT host, name, working_dir, created_at, last_attached_at, ended_at
+		 FROM shell_sessions WHERE ? = '' OR host = ?
+		 ORDER BY last_attached_at DESC`, host, host,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shells []*ShellSession
+	for rows.Next() {
+		var s ShellSession
+		if err := rows.Scan(&s.Host, &s.Name, &s.WorkingDir, &s.CreatedAt, &s.LastAttachedAt, &s.EndedAt); err != nil {
+			return nil, err
+		}
+		shells = append(shells, &s)
+	}
+	return shells, rows.Err()
+}
+
+// DeleteShellSession removes a shell session's record
+func DeleteShellSession(db *sql.DB, host, name string) error {
+	_, err := db.Exec(`DELETE FROM shell_sessions WHERE host = ? AND name = ?`, host, name)
+	return err
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Status is a job's place in its lifecycle. It is stored in the jobs table
+// as text, and only changes along the transitions listed in transitions.
+type Status string
+
+const (
+	// StatusStarting indicates a job is being set up
+	StatusStarting Status = "starting"
+	// StatusRunning indicates a job is currently running
+	StatusRunning Status = "running"
+	// StatusCompleted indicates a job finished (check exit code)
+	StatusCompleted Status = "completed"
+	// StatusDead indicates a job terminated unexpectedly
+	StatusDead Status = "dead"
+	// StatusPending indicates a job that isn't queued or started yet: one
+	// held locally until a job it depends on finishes, or one that couldn't
+	// start and waits for retry
+	StatusPending Status = "pending"
+	// StatusQueued indicates a job queued for sequential execution
+	StatusQueued Status = "queued"
+	// StatusFailed indicates a job failed to start
+	StatusFailed Status = "failed"
+	// StatusPaused indicates a running job was suspended (SIGSTOP) and can be resumed
+	StatusPaused Status = "paused"
+)
+
+// Statuses lists every status, in lifecycle order
+var Statuses = []Status{
+	StatusPending, StatusQueued, StatusStarting, StatusRunning, StatusPaused,
+	StatusCompleted, StatusDead, StatusFailed,
+}
+
+// transitions lists the statuses each status may change to. Completed and
+// failed jobs never change; a dead job can be revived if it was a queue
+// runner job that was wrongly marked dead.
+var transitions = map[Status][]Status{
+	StatusStarting: {StatusRunning, StatusFailed, StatusPending},
+	StatusPending:  {StatusQueued, StatusRunning, StatusFailed},
+	StatusQueued:   {StatusRunning, StatusCompleted, StatusDead},
+	StatusRunning:  {StatusCompleted, StatusDead, StatusPaused},
+	StatusPaused:   {StatusRunning, StatusCompleted, StatusDead},
+	StatusDead:     {StatusRunning},
+}
+
+// ParseStatus returns the status named s
+func ParseStatus(s string) (Status, error) {
+	status := Status(strings.ToLower(strings.TrimSpace(s)))
+	if !status.Valid() {
+		return "", fmt.Errorf("unknown job status %q", s)
+	}
+	return status, nil
+}
+
+// Valid reports whether s is one of the statuses
+func (s Status) Valid() bool {
+	return slices.Contains(Statuses, s)
+}
+
+// Terminal reports whether a job with this status has finished for good
+func (s Status) Terminal() bool {
+	return s == StatusCompleted || s == StatusDead || s == StatusFailed
+}
+
+// CanTransition reports whether a job's status may change from from to to
+func CanTransition(from, to Status) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Scan implements sql.Scanner, rejecting text that isn't a status. NULL
+// and empty text scan as the empty status, which the status log uses for
+// a job's creation.
+func (s *Status) Scan(src any) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("scan job status: unexpected %T", src)
+	}
+	if text != "" && !Status(text).Valid() {
+		return fmt.Errorf("scan job status: unknown status %q", text)
+	}
+	*s = Status(text)
+	return nil
+}
+
+// Value implements driver.Valuer, so that only valid statuses are stored
+func (s Status) Value() (driver.Value, error) {
+	if s != "" && !s.Valid() {
+		return nil, fmt.Errorf("unknown job status %q", string(s))
+	}
+	return string(s), nil
+}
+
+// StatusChange is an entry in a job's status log
+type StatusChange struct {
+	From Status // Empty when the job was created
+	To   Status
+	At   int64
+}
+
+// execer is what status changes are written through: the database, or a
+// transaction that also writes other tables
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// logStatus records a change to a job's status in the events table
+func logStatus(db execer, id int64, from, to Status) error {
+	_, err := db.Exec(
+		`INSERT INTO events (at, kind, job_id, host, from_state, to_state)
+		 SELECT ?, ?, id, host, ?, ? FROM jobs WHERE id = ?`,
+		time.Now().Unix(), EventJob, from, to, id,
+	)
+	return err
+}
+
+// transitionJob changes a job's status to to if it is one of from, setting
+// the columns in set (e.g. "end_time = ?") to args as well, and logs the
+// change. It reports whether the job changed; a job in another status, or
+// one that doesn't exist, is left alone. A transition that isn't allowed is
+// a programming error, reported before anything is written.
+func transitionJob(db *sql.DB, id int64, from []Status, to Status, set string, args ...any) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	changed, err := transitionJobTx(tx, id, from, to, set, args...)
+	if err != nil || !changed {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// transitionJobTx is transitionJob within a transaction
+func transitionJobTx(tx *sql.Tx, id int64, from []Status, to Status, set string, args ...any) (bool, error) {
+	for _, f := range from {
+		if !CanTransition(f, to) {
+			return false, fmt.Errorf("job %d: invalid status transition %s → %s", id, f, to)
+		}
+	}
+	var current Status
+	err := tx.QueryRow(`SELECT status FROM jobs WHERE id = ?`, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !slices.Contains(from, current) {
+		return false, nil
+	}
+
+	query := `UPDATE jobs SET status = ?`
+	if set != "" {
+		query += ", " + set
+	}
+	query += ` WHERE id = ? AND status = ?`
+	updateArgs := append([]any{to}, args...)
+	updateArgs = append(updateArgs, id, current)
+	if _, err := tx.Exec(query, updateArgs...); err != nil {
+		return false, err
+	}
+	if err := logStatus(tx, id, current, to); err != nil {
+		return false, err
+	}
+	// A job whose status changed is no longer suspected of being dead
+	if _, err := tx.Exec(`DELETE FROM job_dead_probes WHERE job_id = ?`, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// insertJob inserts a job with the given columns and values, which include
+// its status, logs its creation, and returns its ID
+func insertJob(db *sql.DB, status Status, columns string, args ...any) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)+1), ", ")
+	result, err := tx.Exec(
+		`INSERT INTO jobs (status, `+columns+`) VALUES (`+placeholders+`)`,
+		append([]any{status}, args...)...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := logStatus(tx, id, "", status); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// GetStatusLog returns the changes to a job's status, oldest first. Jobs
+// created before the log was kept have no entries from before then.
+func GetStatusLog(db *sql.DB, id int64) ([]StatusChange, error) {
+	rows, err := db.Query(
+		`SELECT from_state, to_state, at FROM events WHERE kind = ? AND job_id = ? ORDER BY id`, EventJob, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []StatusChange
+	for rows.Next() {
+		var c StatusChange
+		if err := rows.Scan(&c.From, &c.To, &c.At); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
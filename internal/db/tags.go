@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// SetJobTags replaces a job's tags
+func SetJobTags(db *sql.DB, jobID int64, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM job_tags WHERE job_id = ?`, jobID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO job_tags (job_id, tag) VALUES (?, ?)`, jobID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetJobTags returns a job's tags in alphabetical order
+func GetJobTags(db *sql.DB, jobID int64) ([]string, error) {
+	rows, err := db.Query(`SELECT tag FROM job_tags WHERE job_id = ? ORDER BY tag`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// LoadJobTags returns the tags of each of jobIDs, in alphabetical order.
+// Jobs without tags are left out.
+func LoadJobTags(db *sql.DB, jobIDs []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string)
+	if len(jobIDs) == 0 {
+		return tags, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(jobIDs)), ",")
+	args := make([]interface{}, len(jobIDs))
+	for i, id := range jobIDs {
+		args[i] = id
+	}
+
+	rows, err := db.Query(`SELECT job_id, tag FROM job_tags WHERE job_id IN (`+placeholders+`) ORDER BY job_id, tag`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
+
+// JobIDsWithTag returns the IDs of jobs that have tag
+func JobIDsWithTag(db *sql.DB, tag string) (map[int64]bool, error) {
+	rows, err := db.Query(`SELECT job_id FROM job_tags WHERE tag = ?`, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,175 @@
+// Package dbcrypt encrypts the local job database at rest, since it holds
+// commands, host names, and arguments that may be sensitive.
+//
+// An encrypted database is sealed with AES-256-GCM under a random key that
+// is kept in the keychain (see the secrets package) as REMOTE_JOBS_DB_KEY,
+// or else read from the local environment variable of that name. SQLite
+// can't read a sealed file, so while the database is in use a decrypted
+// copy is kept in the user's runtime directory, and the sealed file is
+// rewritten from it whenever a command closes the database.
+package dbcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/osteele/remote-jobs/internal/secrets"
+)
+
+// KeyName is the name of the secret that holds the database key
+const KeyName = "REMOTE_JOBS_DB_KEY"
+
+// keySize is the length of a key in bytes, for AES-256
+const keySize = 32
+
+// magic begins every sealed file, followed by the nonce and the ciphertext
+var magic = []byte("RJDBENC1")
+
+// ErrWrongKey is returned when a sealed file can't be opened with the key,
+// because the key is wrong or the file was altered
+var ErrWrongKey = errors.New("wrong database key, or the encrypted database is damaged")
+
+// NewKey returns a new random key, base64 encoded as it is stored
+func NewKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a key stored as NewKey returns it
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("%s is not a database key (expected %d base64-encoded bytes)", KeyName, keySize)
+	}
+	return key, nil
+}
+
+// Key returns the database key from the keychain or the environment
+func Key() ([]byte, error) {
+	s, err := secrets.Lookup(KeyName)
+	if err != nil {
+		return nil, fmt.Errorf("the database is encrypted, and its key isn't available: %w", err)
+	}
+	return ParseKey(s)
+}
+
+// IsSealed reports whether data begins like a sealed file
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// IsSealedFile reports whether the file at path is sealed
+func IsSealedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return IsSealed(head)
+}
+
+// Seal encrypts plain with key
+func Seal(plain, key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte{}, magic...), nonce...)
+	return aead.Seal(sealed, nonce, plain, magic), nil
+}
+
+// Unseal decrypts data sealed with key
+func Unseal(sealed, key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if !IsSealed(sealed) || len(sealed) < len(magic)+aead.NonceSize() {
+		return nil, fmt.Errorf("not an encrypted database")
+	}
+	rest := sealed[len(magic):]
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealFile writes an encrypted copy of the file at src to dst, replacing dst
+// only once the copy is complete
+func SealFile(src, dst string, key []byte) error {
+	plain, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	sealed, err := Seal(plain, key)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(dst, sealed)
+}
+
+// UnsealFile writes a decrypted copy of the sealed file at src to dst,
+// replacing dst only once the copy is complete
+func UnsealFile(src, dst string, key []byte) error {
+	sealed, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	plain, err := Unseal(sealed, key)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(dst, plain)
+}
+
+// writeAtomic writes data to a file beside path, readable only by the user,
+// then renames it over path
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package dbcrypt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealRoundTrip(t *testing.T) {
+	encoded, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := []byte("SQLite format 3\x00 python train.py --token abc")
+	sealed, err := Seal(plain, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, []byte("train.py")) {
+		t.Errorf("Seal() = %q, want an encrypted file", sealed)
+	}
+	got, err := Unseal(sealed, key)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("Unseal() = %q, %v; want %q", got, err, plain)
+	}
+
+	other, _ := NewKey()
+	otherKey, _ := ParseKey(other)
+	if _, err := Unseal(sealed, otherKey); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Unseal() with another key: error = %v, want ErrWrongKey", err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := Unseal(sealed, key); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Unseal() of an altered file: error = %v, want ErrWrongKey", err)
+	}
+	if _, err := Unseal(plain, key); err == nil {
+		t.Error("Unseal() of an unencrypted file succeeded")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSealFile(t *testing.T) {
+	dir := t.TempDir()
+	encoded, _ := NewKey()
+	key, _ := ParseKey(encoded)
+	src, sealed, dst := filepath.Join(dir, "jobs.db"), filepath.Join(dir, "jobs.db.enc"), filepath.Join(dir, "copy.db")
+	if err := os.WriteFile(src, []byte("jobs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SealFile(src, sealed, key); err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealedFile(sealed) || IsSealedFile(src) {
+		t.Errorf("IsSealedFile() = %v for the sealed file, %v for the plain one", IsSealedFile(sealed), IsSealedFile(src))
+	}
+	if info, err := os.Stat(sealed); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("sealed file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if err := UnsealFile(sealed, dst, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst); string(got) != "jobs" {
+		t.Errorf("UnsealFile() wrote %q, want %q", got, "jobs")
+	}
+}
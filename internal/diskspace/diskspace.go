@@ -0,0 +1,168 @@
+// Package diskspace reports free space and inodes on the remote filesystems
+// that hold job directories and remote-jobs' own files.
+package diskspace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// CacheDir holds job logs, status files, and queues on each host
+const CacheDir = "~/.cache/remote-jobs"
+
+// LowFreePercent is the share of free space or inodes below which a
+// filesystem is shown as nearly full, whatever the configured limits
+const LowFreePercent = 5
+
+// Usage is the state of the filesystem containing Path
+type Usage struct {
+	Path        string // Path as given (may start with ~)
+	Mount       string // Mount point of the filesystem containing Path
+	TotalKB     int64
+	AvailKB     int64
+	TotalInodes int64 // 0 if unknown
+	FreeInodes  int64
+}
+
+// FreePercent returns the share of space available, or 100 if unknown
+func (u Usage) FreePercent() int {
+	if u.TotalKB <= 0 {
+		return 100
+	}
+	return int(u.AvailKB * 100 / u.TotalKB)
+}
+
+// FreeInodePercent returns the share of inodes free, or 100 if unknown
+func (u Usage) FreeInodePercent() int {
+	if u.TotalInodes <= 0 {
+		return 100
+	}
+	return int(u.FreeInodes * 100 / u.TotalInodes)
+}
+
+// Low reports whether the filesystem is below LowFreePercent of space or inodes
+func (u Usage) Low() bool {
+	return u.FreePercent() < LowFreePercent || u.FreeInodePercent() < LowFreePercent
+}
+
+// String describes the usage, e.g. "/data: 12.3GiB free (4%), inodes 61% free"
+func (u Usage) String() string {
+	s := fmt.Sprintf("%s: %s free (%d%%)", u.Mount, humanfmt.KiB(u.AvailKB), u.FreePercent())
+	if u.TotalInodes > 0 {
+		s += fmt.Sprintf(", inodes %d%% free", u.FreeInodePercent())
+	}
+	return s
+}
+
+// Check returns an error if the filesystem has less than minFreeGB of space
+// or less than minFreeInodesPct percent of its inodes free. Zero disables a check.
+func (u Usage) Check(host string, minFreeGB, minFreeInodesPct int) error {
+	if minFreeGB > 0 && u.AvailKB < int64(minFreeGB)*1024*1024 {
+		return fmt.Errorf("%s on %s has only %s free (min_free_disk_gb: %d)", u.Mount, host, humanfmt.KiB(u.AvailKB), minFreeGB)
+	}
+	if minFreeInodesPct > 0 && u.TotalInodes > 0 && u.FreeInodePercent() < minFreeInodesPct {
+		return fmt.Errorf("%s on %s has only %d%% of inodes free (min_free_inodes_pct: %d)", u.Mount, host, u.FreeInodePercent(), minFreeInodesPct)
+	}
+	return nil
+}
+
+// CheckHost returns an error if the filesystem holding workingDir on host has
+// less than minFreeGB of space or less than minFreeInodesPct percent of its
+// inodes free, as Usage.Check does. Zero disables a check. It is skipped if
+// the host can't be reached; starting the job will report that.
+func CheckHost(host, workingDir string, minFreeGB, minFreeInodesPct int) error {
+	if minFreeGB <= 0 && minFreeInodesPct <= 0 {
+		return nil
+	}
+	if workingDir == "" {
+		workingDir = "~"
+	}
+	stdout, _, err := ssh.RunWithTimeout(host, Command(workingDir), ssh.HostTimeouts(host).Probe)
+	if err != nil {
+		return nil
+	}
+	for _, usage := range Parse(stdout, workingDir) {
+		if err := usage.Check(host, minFreeGB, minFreeInodesPct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns a shell command that prints the usage of the filesystems
+// containing paths. A path that doesn't exist yet is measured at its nearest
+// existing parent.
+func Command(paths ...string) string {
+	var b strings.Builder
+	b.WriteString(`rj_df() { p=$2; while [ ! -e "$p" ] && [ "$p" != / ] && [ "$p" != . ]; do p=$(dirname "$p"); done; ` +
+		`df -Pk "$p" 2>/dev/null | awk -v i="$1" 'NR==2 { print "DISK:" i ":" $2 ":" $4 ":" $6 }'; ` +
+		// Linux prints Inodes/IUsed/IFree; macOS prints blocks first, then iused/ifree
+		`df -Pi "$p" 2>/dev/null | awk -v i="$1" 'NR==2 { if (NF >= 9) print "INODES:" i ":" $6+$7 ":" $7; else print "INODES:" i ":" $2 ":" $4 }'; }; `)
+	for i, p := range paths {
+		fmt.Fprintf(&b, "rj_df %d %s; ", i, shellquote.HomePath(p))
+	}
+	b.WriteString("true")
+	return b.String()
+}
+
+// Parse parses the output of Command for the same paths
+func Parse(output string, paths ...string) []Usage {
+	usages := make([]Usage, len(paths))
+	found := make([]bool, len(paths))
+	for i, p := range paths {
+		usages[i].Path = p
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		key, rest, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || (key != "DISK" && key != "INODES") {
+			continue
+		}
+		fields := strings.SplitN(rest, ":", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		i, err := strconv.Atoi(fields[0])
+		if err != nil || i < 0 || i >= len(paths) {
+			continue
+		}
+		total, _ := strconv.ParseInt(fields[1], 10, 64)
+		free, _ := strconv.ParseInt(fields[2], 10, 64)
+		if key == "DISK" {
+			usages[i].TotalKB, usages[i].AvailKB = total, free
+			if len(fields) == 4 {
+				usages[i].Mount = fields[3]
+			}
+			found[i] = true
+		} else {
+			usages[i].TotalInodes, usages[i].FreeInodes = total, free
+		}
+	}
+
+	var result []Usage
+	for i, u := range usages {
+		if found[i] {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// Distinct returns usages with duplicate mount points removed
+func Distinct(usages []Usage) []Usage {
+	seen := make(map[string]bool)
+	var result []Usage
+	for _, u := range usages {
+		if seen[u.Mount] {
+			continue
+		}
+		seen[u.Mount] = true
+		result = append(result, u)
+	}
+	return result
+}
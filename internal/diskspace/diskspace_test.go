@@ -0,0 +1,93 @@
+package diskspace
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestParse(t *testing.T) {
+	output := `ARCH:Linux x86_64
+DISK:0:1048576000:10485760:/home
+INODES:0:1000000:20000
+DISK:1:2097152000:1572864000:/data
+`
+	usages := Parse(output, "~/.cache/remote-jobs", "/data/run", "/missing")
+	if len(usages) != 2 {
+		t.Fatalf("Parse() returned %d usages, want 2: %+v", len(usages), usages)
+	}
+
+	home := usages[0]
+	if home.Path != "~/.cache/remote-jobs" || home.Mount != "/home" || home.AvailKB != 10485760 {
+		t.Errorf("usages[0] = %+v", home)
+	}
+	if got := home.FreePercent(); got != 1 {
+		t.Errorf("FreePercent() = %d, want 1", got)
+	}
+	if got := home.FreeInodePercent(); got != 2 {
+		t.Errorf("FreeInodePercent() = %d, want 2", got)
+	}
+	if !home.Low() {
+		t.Error("expected /home to be low")
+	}
+
+	data := usages[1]
+	if data.TotalInodes != 0 || data.FreeInodePercent() != 100 || data.Low() {
+		t.Errorf("usages[1] = %+v, want no inode info and not low", data)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	u := Usage{Mount: "/home", TotalKB: 100 * 1024 * 1024, AvailKB: 3 * 1024 * 1024, TotalInodes: 100, FreeInodes: 50}
+
+	if err := u.Check("cool30", 0, 0); err != nil {
+		t.Errorf("Check with no limits: %v", err)
+	}
+	if err := u.Check("cool30", 2, 10); err != nil {
+		t.Errorf("Check within limits: %v", err)
+	}
+	err := u.Check("cool30", 5, 0)
+	if err == nil || !strings.Contains(err.Error(), "3.0GiB free") {
+		t.Errorf("Check(min 5G) = %v, want space error", err)
+	}
+	if err := u.Check("cool30", 0, 60); err == nil || !strings.Contains(err.Error(), "inodes") {
+		t.Errorf("Check(min 60%% inodes) = %v, want inode error", err)
+	}
+}
+
+func TestCommandRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	paths := []string{"/", "/no/such/dir"}
+	out, err := exec.Command("sh", "-c", Command(paths...)).Output()
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	usages := Parse(string(out), paths...)
+	if len(usages) != 2 {
+		t.Fatalf("Parse() returned %d usages, want 2:\n%s", len(usages), out)
+	}
+	if usages[1].Mount != usages[0].Mount {
+		t.Errorf("missing dir measured on %q, want its parent's filesystem %q", usages[1].Mount, usages[0].Mount)
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	ssh.SetSandbox(t.TempDir())
+	defer ssh.SetSandbox("")
+
+	if err := CheckHost("cool30", "", 0, 0); err != nil {
+		t.Errorf("CheckHost() with no minimums = %v, want nil", err)
+	}
+	// No disk has a petabyte free
+	if err := CheckHost("cool30", "~/code", 1<<20, 0); err == nil {
+		t.Error("CheckHost() = nil, want an error for a disk below min_free_disk_gb")
+	}
+}
@@ -0,0 +1,70 @@
+// Package editor opens text in the user's text editor, for editing notes and
+// other free-form text from the command line or the TUI.
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Default is the editor used when neither $VISUAL nor $EDITOR is set
+const Default = "vi"
+
+// Command returns the command that opens path in the user's editor: $VISUAL,
+// else $EDITOR, else vi. The variable may include arguments, as in
+// EDITOR="code --wait". The command's standard streams are left unset.
+func Command(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if strings.TrimSpace(editor) == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		fields = []string{Default}
+	}
+	return exec.Command(fields[0], append(fields[1:], path)...)
+}
+
+// Prepare writes text to a new temporary file for editing with Command, and
+// returns its path. The pattern sets the file name, as for os.CreateTemp, so
+// a suffix such as ".md" can select the editor's syntax highlighting.
+func Prepare(text, pattern string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// Finish returns the text of a file made with Prepare, and removes the file
+func Finish(path string) (string, error) {
+	defer os.Remove(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read edited file: %w", err)
+	}
+	return string(data), nil
+}
+
+// Edit opens text in the editor, attached to the terminal, and returns the
+// text as saved
+func Edit(text, pattern string) (string, error) {
+	path, err := Prepare(text, pattern)
+	if err != nil {
+		return "", err
+	}
+	cmd := Command(path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("run editor %s: %w", cmd.Path, err)
+	}
+	return Finish(path)
+}
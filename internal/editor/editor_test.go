@@ -0,0 +1,45 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "code --wait")
+	if got := Command("/tmp/note.md").Args; !slices.Equal(got, []string{"code", "--wait", "/tmp/note.md"}) {
+		t.Errorf("Args = %q", got)
+	}
+	t.Setenv("VISUAL", "nvim")
+	if got := Command("/tmp/note.md").Args; !slices.Equal(got, []string{"nvim", "/tmp/note.md"}) {
+		t.Errorf("Args with VISUAL = %q", got)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	if got := Command("/tmp/note.md").Args; !slices.Equal(got, []string{Default, "/tmp/note.md"}) {
+		t.Errorf("Args without an editor = %q", got)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the editor")
+	}
+	script := filepath.Join(t.TempDir(), "append.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho 'added' >> \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VISUAL", script)
+
+	got, err := Edit("first\n", "note-*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "first\nadded\n" {
+		t.Errorf("Edit() = %q", got)
+	}
+}
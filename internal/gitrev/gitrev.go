@@ -0,0 +1,42 @@
+// Package gitrev reads the git commit checked out in a job's working
+// directory when the job starts, so that runs of the same command can be
+// traced to the code they ran.
+package gitrev
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// prefix marks the line of output Command prints
+const prefix = "git-revision "
+
+// Command returns a command that prints the commit checked out in dir, with
+// " dirty" appended if its work tree has uncommitted changes. It prints
+// nothing if dir isn't in a git repository, and always succeeds, so it can
+// be chained with other commands.
+func Command(dir string) string {
+	d := shellquote.Path(dir)
+	return fmt.Sprintf(`{ c=$(git -C %s rev-parse HEAD 2>/dev/null) && if [ -n "$(git -C %s status --porcelain 2>/dev/null | head -n 1)" ]; then echo "%s$c dirty"; else echo "%s$c"; fi; true; }`,
+		d, d, prefix, prefix)
+}
+
+// Parse finds the revision in the output of Command, which may be mixed with
+// other output. It returns nil if there is none.
+func Parse(output string) *db.GitRevision {
+	for _, line := range strings.Split(output, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), prefix)
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil
+		}
+		return &db.GitRevision{Commit: fields[0], Dirty: len(fields) > 1 && fields[1] == "dirty"}
+	}
+	return nil
+}
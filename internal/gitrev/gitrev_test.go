@@ -0,0 +1,28 @@
+package gitrev
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *db.GitRevision
+	}{
+		{"clean", "git-revision 3f2a9c1d\n1700000000 +0000\n", &db.GitRevision{Commit: "3f2a9c1d"}},
+		{"dirty", "git-revision 3f2a9c1d dirty\n1700000000 +0000\n", &db.GitRevision{Commit: "3f2a9c1d", Dirty: true}},
+		{"not a repository", "1700000000 +0000\n", nil},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
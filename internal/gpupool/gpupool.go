@@ -0,0 +1,351 @@
+// Package gpupool combines the GPUs of every host into one pool, showing how
+// much memory each has free, which job owns it, and whether it is reserved,
+// for deciding where to launch the next job.
+package gpupool
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// State says whether a GPU can take a new job
+type State string
+
+const (
+	StateFree     State = "free"
+	StateInUse    State = "in use"   // A job's process has memory on the GPU
+	StateReserved State = "reserved" // Assigned to a running job through CUDA_VISIBLE_DEVICES, but not in use by it
+	StateBusy     State = "busy"     // In use by processes that aren't remote-jobs jobs
+)
+
+// Thresholds above which a GPU without a job counts as busy; an idle GPU
+// still reports a few MiB used by the driver
+const (
+	busyMemMiB      = 512
+	busyUtilization = 5
+)
+
+// Command lists a host's NVIDIA GPUs, one per line; it prints nothing on
+// hosts without nvidia-smi
+const Command = "nvidia-smi --query-gpu=index,name,utilization.gpu,memory.used,memory.total --format=csv,noheader,nounits 2>/dev/null || true"
+
+// Thresholds above which a GPU counts as heavily used, so that starting
+// another job on it would likely run out of memory or crawl
+const (
+	contendedMemPercent  = 50
+	contendedUtilization = 50
+)
+
+// probePrefix marks the lines of ProbeCommand's output
+const probePrefix = "gpu: "
+
+// ProbeCommand lists a host's GPUs like Command, with each line prefixed so
+// that it can be chained with other commands and picked out of their output
+// by ParseProbe. It always succeeds.
+const ProbeCommand = "{ nvidia-smi --query-gpu=index,name,utilization.gpu,memory.used,memory.total --format=csv,noheader,nounits 2>/dev/null | sed 's/^/" + probePrefix + "/'; true; }"
+
+// GPU is one GPU in the pool
+type GPU struct {
+	Host        string
+	Index       int
+	Name        string
+	Utilization int // Percent, or -1 if unknown
+	MemUsedMiB  int
+	MemTotalMiB int   // 0 if unknown
+	JobID       int64 // Job using or reserving the GPU (0 if none)
+	State       State
+	Cached      bool // Read from the host info cache because the host wasn't queried
+}
+
+// FreeMiB returns the GPU's unused memory, or 0 if its total is unknown
+func (g GPU) FreeMiB() int {
+	return max(g.MemTotalMiB-g.MemUsedMiB, 0)
+}
+
+// Usage is a job's claim on one GPU
+type Usage struct {
+	Host   string
+	Index  int
+	JobID  int64
+	Active bool // The job's process has memory on the GPU; otherwise the GPU is only reserved for it
+}
+
+// Parse parses the output of Command run on host
+func Parse(host, output string) []GPU {
+	var gpus []GPU
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 5 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		gpu := GPU{Host: host, Index: index, Name: fields[1], Utilization: -1}
+		if util, err := strconv.Atoi(fields[2]); err == nil {
+			gpu.Utilization = util
+		}
+		gpu.MemUsedMiB, _ = strconv.Atoi(fields[3])
+		gpu.MemTotalMiB, _ = strconv.Atoi(fields[4])
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// ParseProbe parses the lines printed by ProbeCommand in the output of host
+func ParseProbe(host, output string) []GPU {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if rest, ok := strings.CutPrefix(line, probePrefix); ok {
+			lines = append(lines, rest)
+		}
+	}
+	return Parse(host, strings.Join(lines, "\n"))
+}
+
+// Contended reports whether the GPU is heavily used, by memory or compute
+func (g GPU) Contended() bool {
+	if g.MemTotalMiB > 0 && g.MemUsedMiB*100 >= g.MemTotalMiB*contendedMemPercent {
+		return true
+	}
+	return g.Utilization >= contendedUtilization
+}
+
+// ContendedGPUs returns the heavily used GPUs among those with the given
+// indices, or among all the GPUs if indices is nil (a job without
+// CUDA_VISIBLE_DEVICES sees every GPU)
+func ContendedGPUs(gpus []GPU, indices []int) []GPU {
+	var contended []GPU
+	for _, g := range gpus {
+		if indices != nil && !slices.Contains(indices, g.Index) {
+			continue
+		}
+		if g.Contended() {
+			contended = append(contended, g)
+		}
+	}
+	return contended
+}
+
+// DescribeContention describes heavily used GPUs of a host, e.g. "GPU 0 on
+// cool30 is heavily used (97% utilization, 68.4GiB/80.0GiB)"
+func DescribeContention(host string, gpus []GPU) string {
+	var details []string
+	indices := make([]int, len(gpus))
+	for i, g := range gpus {
+		indices[i] = g.Index
+		detail := fmt.Sprintf("%s/%s", humanfmt.MiB(g.MemUsedMiB), humanfmt.MiB(g.MemTotalMiB))
+		if g.Utilization >= 0 {
+			detail = fmt.Sprintf("%d%% utilization, %s", g.Utilization, detail)
+		}
+		if len(gpus) > 1 {
+			detail = fmt.Sprintf("GPU %d: %s", g.Index, detail)
+		}
+		details = append(details, detail)
+	}
+	if len(gpus) == 1 {
+		return fmt.Sprintf("GPU %d on %s is heavily used (%s)", indices[0], host, details[0])
+	}
+	return fmt.Sprintf("GPUs %s on %s are heavily used (%s)", FormatIndices(indices), host, strings.Join(details, "; "))
+}
+
+// cachedGPU is a GPU as recorded in the host info cache by the TUI
+type cachedGPU struct {
+	Index       int
+	Name        string
+	Utilization int
+	MemUsed     string // e.g., "12 MiB"
+	MemTotal    string // e.g., "80 GiB"
+	JobID       int64
+}
+
+// FromCache converts the GPUs in a host's cached info (a JSON array) into
+// pool entries, with the job that was last seen using each
+func FromCache(host, gpusJSON string) ([]GPU, error) {
+	if gpusJSON == "" {
+		return nil, nil
+	}
+	var cached []cachedGPU
+	if err := json.Unmarshal([]byte(gpusJSON), &cached); err != nil {
+		return nil, fmt.Errorf("parse cached GPUs for %s: %w", host, err)
+	}
+	gpus := make([]GPU, 0, len(cached))
+	for _, c := range cached {
+		gpu := GPU{
+			Host:        host,
+			Index:       c.Index,
+			Name:        c.Name,
+			Utilization: -1,
+			MemUsedMiB:  humanfmt.ParseMiB(c.MemUsed),
+			MemTotalMiB: humanfmt.ParseMiB(c.MemTotal),
+			JobID:       c.JobID,
+			Cached:      true,
+		}
+		if c.MemUsed != "" {
+			gpu.Utilization = c.Utilization
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// VisibleDevices returns the GPU indices a job's environment variables
+// ("VAR=value") restrict it to with CUDA_VISIBLE_DEVICES, or nil if they
+// don't set it. Device UUIDs can't be matched to indices and are skipped.
+func VisibleDevices(envVars []string) []int {
+	var indices []int
+	for _, env := range envVars {
+		value, ok := strings.CutPrefix(env, "CUDA_VISIBLE_DEVICES=")
+		if !ok {
+			continue
+		}
+		indices = ParseIndices(strings.Trim(value, `"'`))
+	}
+	return indices
+}
+
+// VisibleDevicesVar returns the CUDA_VISIBLE_DEVICES assignment ("VAR=value")
+// that restricts a job to the GPUs with the given indices
+func VisibleDevicesVar(indices []int) string {
+	return "CUDA_VISIBLE_DEVICES=" + JoinIndices(indices, ",")
+}
+
+// FormatIndices formats GPU indices for display, e.g. "0, 2"
+func FormatIndices(indices []int) string {
+	return JoinIndices(indices, ", ")
+}
+
+// JoinIndices joins GPU indices with a separator, e.g. "0,2"
+func JoinIndices(indices []int, sep string) string {
+	fields := make([]string, len(indices))
+	for i, index := range indices {
+		fields[i] = strconv.Itoa(index)
+	}
+	return strings.Join(fields, sep)
+}
+
+// ParseIndices parses a comma-separated list of GPU indices, such as
+// "0,2". Fields that aren't indices, such as device UUIDs, are skipped.
+func ParseIndices(s string) []int {
+	indices := []int{}
+	for _, field := range strings.Split(s, ",") {
+		if index, err := strconv.Atoi(strings.TrimSpace(field)); err == nil && index >= 0 {
+			indices = append(indices, index)
+		}
+	}
+	return indices
+}
+
+// Pick chooses n free GPUs from a host's GPUs, whose states have been set by
+// Assign, preferring those with the most free memory. The indices are
+// returned in increasing order.
+func Pick(gpus []GPU, n int) ([]int, error) {
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("no GPUs found")
+	}
+	var free []GPU
+	for _, g := range gpus {
+		if g.State == StateFree {
+			free = append(free, g)
+		}
+	}
+	if len(free) < n {
+		return nil, fmt.Errorf("%d GPU(s) requested, but only %d of %d are free", n, len(free), len(gpus))
+	}
+	SortByFree(free)
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = free[i].Index
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
+
+// Assign sets each GPU's job and state from the jobs' claims on them
+func Assign(gpus []GPU, usages []Usage) {
+	for i := range gpus {
+		g := &gpus[i]
+		var reservedBy int64
+		active := false
+		for _, u := range usages {
+			if u.Host != g.Host || u.Index != g.Index {
+				continue
+			}
+			if u.Active {
+				g.JobID, active = u.JobID, true
+				break
+			}
+			if reservedBy == 0 {
+				reservedBy = u.JobID
+			}
+		}
+		switch {
+		case active:
+			g.State = StateInUse
+		case g.JobID != 0 && g.Cached:
+			g.State = StateInUse
+		case reservedBy != 0:
+			g.JobID, g.State = reservedBy, StateReserved
+		case g.MemUsedMiB >= busyMemMiB || g.Utilization >= busyUtilization:
+			g.State = StateBusy
+		default:
+			g.State = StateFree
+		}
+	}
+}
+
+// SortByFree orders GPUs with the most free memory first, then by host and
+// index
+func SortByFree(gpus []GPU) {
+	sort.SliceStable(gpus, func(i, j int) bool {
+		if gpus[i].FreeMiB() != gpus[j].FreeMiB() {
+			return gpus[i].FreeMiB() > gpus[j].FreeMiB()
+		}
+		return lessByHost(gpus[i], gpus[j])
+	})
+}
+
+// SortByHost orders GPUs by host and index
+func SortByHost(gpus []GPU) {
+	sort.SliceStable(gpus, func(i, j int) bool { return lessByHost(gpus[i], gpus[j]) })
+}
+
+func lessByHost(a, b GPU) bool {
+	if a.Host != b.Host {
+		return a.Host < b.Host
+	}
+	return a.Index < b.Index
+}
+
+// Summary counts the GPUs in each state, e.g. "12 GPUs: 5 free, 4 in use,
+// 1 reserved, 2 busy"
+func Summary(gpus []GPU) string {
+	counts := make(map[State]int)
+	for _, g := range gpus {
+		counts[g.State]++
+	}
+	parts := []string{}
+	for _, state := range []State{StateFree, StateInUse, StateReserved, StateBusy} {
+		if counts[state] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[state], state))
+		}
+	}
+	noun := "GPUs"
+	if len(gpus) == 1 {
+		noun = "GPU"
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%d %s", len(gpus), noun)
+	}
+	return fmt.Sprintf("%d %s: %s", len(gpus), noun, strings.Join(parts, ", "))
+}
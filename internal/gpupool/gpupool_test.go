@@ -0,0 +1,172 @@
+package gpupool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	output := "0, NVIDIA A100-SXM4-80GB, 97, 70000, 81920\n1, NVIDIA A100-SXM4-80GB, 0, 4, 81920\n\n"
+	want := []GPU{
+		{Host: "cool30", Index: 0, Name: "NVIDIA A100-SXM4-80GB", Utilization: 97, MemUsedMiB: 70000, MemTotalMiB: 81920},
+		{Host: "cool30", Index: 1, Name: "NVIDIA A100-SXM4-80GB", Utilization: 0, MemUsedMiB: 4, MemTotalMiB: 81920},
+	}
+	if got := Parse("cool30", output); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+	if got := Parse("mac", ""); got != nil {
+		t.Errorf("Parse(\"\") = %+v, want nil", got)
+	}
+}
+
+func TestFromCache(t *testing.T) {
+	gpus, err := FromCache("cool30", `[{"Index":0,"Name":"A100","Utilization":40,"MemUsed":"1024 MiB","MemTotal":"80 GiB","JobID":42}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GPU{{Host: "cool30", Index: 0, Name: "A100", Utilization: 40, MemUsedMiB: 1024, MemTotalMiB: 81920, JobID: 42, Cached: true}}
+	if !reflect.DeepEqual(gpus, want) {
+		t.Errorf("FromCache() = %+v, want %+v", gpus, want)
+	}
+}
+
+func TestVisibleDevices(t *testing.T) {
+	tests := []struct {
+		env  []string
+		want []int
+	}{
+		{[]string{"BATCH=32"}, nil},
+		{[]string{"CUDA_VISIBLE_DEVICES=0,2"}, []int{0, 2}},
+		{[]string{"CUDA_VISIBLE_DEVICES=1", "CUDA_VISIBLE_DEVICES='3'"}, []int{3}},
+		{[]string{"CUDA_VISIBLE_DEVICES=GPU-8f3a"}, []int{}},
+		{[]string{"CUDA_VISIBLE_DEVICES="}, []int{}},
+	}
+	for _, tt := range tests {
+		if got := VisibleDevices(tt.env); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VisibleDevices(%q) = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestParseIndices(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []int
+	}{
+		{"0,2", []int{0, 2}},
+		{" 1, 3 ", []int{1, 3}},
+		{"GPU-8f3a,1,-1", []int{1}},
+		{"", []int{}},
+	}
+	for _, tt := range tests {
+		if got := ParseIndices(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIndices(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+		if got := ParseIndices(JoinIndices(tt.want, ",")); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIndices(JoinIndices(%v)) = %v", tt.want, got)
+		}
+	}
+}
+
+func TestAssignAndSort(t *testing.T) {
+	gpus := []GPU{
+		{Host: "a", Index: 0, MemUsedMiB: 60000, MemTotalMiB: 81920, Utilization: 90},
+		{Host: "a", Index: 1, MemUsedMiB: 4, MemTotalMiB: 81920, Utilization: 0},
+		{Host: "b", Index: 0, MemUsedMiB: 20000, MemTotalMiB: 24576, Utilization: 50},
+		{Host: "b", Index: 1, MemUsedMiB: 2, MemTotalMiB: 24576, Utilization: 0},
+	}
+	Assign(gpus, []Usage{
+		{Host: "a", Index: 0, JobID: 7, Active: true},
+		{Host: "a", Index: 0, JobID: 7},
+		{Host: "b", Index: 1, JobID: 9},
+	})
+
+	want := []struct {
+		state State
+		job   int64
+	}{{StateInUse, 7}, {StateFree, 0}, {StateBusy, 0}, {StateReserved, 9}}
+	for i, w := range want {
+		if gpus[i].State != w.state || gpus[i].JobID != w.job {
+			t.Errorf("GPU %s:%d = %s job %d, want %s job %d", gpus[i].Host, gpus[i].Index, gpus[i].State, gpus[i].JobID, w.state, w.job)
+		}
+	}
+
+	SortByFree(gpus)
+	var order []string
+	for _, g := range gpus {
+		order = append(order, g.Host+":"+string(rune('0'+g.Index)))
+	}
+	if wantOrder := []string{"a:1", "b:1", "a:0", "b:0"}; !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("SortByFree order = %v, want %v", order, wantOrder)
+	}
+
+	if got, want := Summary(gpus), "4 GPUs: 1 free, 1 in use, 1 reserved, 1 busy"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+func TestPick(t *testing.T) {
+	gpus := []GPU{
+		{Host: "a", Index: 0, MemUsedMiB: 4, MemTotalMiB: 24576, State: StateFree},
+		{Host: "a", Index: 1, MemUsedMiB: 60000, MemTotalMiB: 81920, State: StateInUse},
+		{Host: "a", Index: 2, MemUsedMiB: 4, MemTotalMiB: 81920, State: StateFree},
+		{Host: "a", Index: 3, MemUsedMiB: 4, MemTotalMiB: 81920, State: StateReserved},
+		{Host: "a", Index: 4, MemUsedMiB: 100, MemTotalMiB: 81920, State: StateFree},
+	}
+	got, err := Pick(gpus, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pick(2) = %v, want %v", got, want)
+	}
+	if _, err := Pick(gpus, 4); err == nil || err.Error() != "4 GPU(s) requested, but only 3 of 5 are free" {
+		t.Errorf("Pick(4) error = %v", err)
+	}
+	if _, err := Pick(nil, 1); err == nil {
+		t.Error("Pick(nil, 1) = nil error, want error")
+	}
+	if got, want := VisibleDevicesVar([]int{2, 4}), "CUDA_VISIBLE_DEVICES=2,4"; got != want {
+		t.Errorf("VisibleDevicesVar() = %q, want %q", got, want)
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	output := "git-revision abc123\ngpu: 0, NVIDIA A100, 97, 70000, 81920\nno-tmux\ngpu: 1, NVIDIA A100, 0, 4, 81920\n1732400000 +0000\n"
+	gpus := ParseProbe("cool30", output)
+	if len(gpus) != 2 || gpus[0].MemUsedMiB != 70000 || gpus[1].Index != 1 {
+		t.Errorf("ParseProbe() = %+v", gpus)
+	}
+	if gpus := ParseProbe("mac", "1732400000 +0000\n"); gpus != nil {
+		t.Errorf("ParseProbe() without GPUs = %+v, want nil", gpus)
+	}
+}
+
+func TestContendedGPUs(t *testing.T) {
+	gpus := []GPU{
+		{Index: 0, Utilization: 97, MemUsedMiB: 70000, MemTotalMiB: 81920},
+		{Index: 1, Utilization: 0, MemUsedMiB: 4, MemTotalMiB: 81920},
+		{Index: 2, Utilization: 10, MemUsedMiB: 45000, MemTotalMiB: 81920},
+		{Index: 3, Utilization: 60, MemUsedMiB: 2000, MemTotalMiB: 81920},
+	}
+	indices := func(gpus []GPU) []int {
+		var indices []int
+		for _, g := range gpus {
+			indices = append(indices, g.Index)
+		}
+		return indices
+	}
+	if got, want := indices(ContendedGPUs(gpus, nil)), []int{0, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ContendedGPUs(all) = %v, want %v", got, want)
+	}
+	if got := ContendedGPUs(gpus, []int{1}); got != nil {
+		t.Errorf("ContendedGPUs([1]) = %v, want nil", indices(got))
+	}
+
+	if got, want := DescribeContention("cool30", gpus[:1]), "GPU 0 on cool30 is heavily used (97% utilization, 68.4GiB/80.0GiB)"; got != want {
+		t.Errorf("DescribeContention() = %q, want %q", got, want)
+	}
+	if got, want := DescribeContention("cool30", []GPU{gpus[0], gpus[3]}), "GPUs 0, 3 on cool30 are heavily used (GPU 0: 97% utilization, 68.4GiB/80.0GiB; GPU 3: 60% utilization, 2.0GiB/80.0GiB)"; got != want {
+		t.Errorf("DescribeContention() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+// Package hooks runs local commands when remote jobs reach a terminal state.
+package hooks
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// Succeeded reports whether a finished job counts as a success for hook purposes
+func Succeeded(job *db.Job) bool {
+	return job.Status == db.StatusCompleted && job.ExitCode != nil && *job.ExitCode == 0
+}
+
+// CommandFor returns the hook command that applies to the job's outcome, or
+// "" for a job the queue runner skipped, which neither succeeded nor failed
+func CommandFor(job *db.Job, h db.JobHooks) string {
+	if job.Skipped() {
+		return ""
+	}
+	if Succeeded(job) {
+		return h.OnSuccess
+	}
+	return h.OnFailure
+}
+
+// Env returns the environment variables that describe a job to a hook command
+func Env(job *db.Job) []string {
+	exitCode := ""
+	if job.ExitCode != nil {
+		exitCode = strconv.Itoa(*job.ExitCode)
+	}
+	endTime := ""
+	if job.EndTime != nil {
+		endTime = strconv.FormatInt(*job.EndTime, 10)
+	}
+	return []string{
+		"REMOTE_JOBS_JOB_ID=" + strconv.FormatInt(job.ID, 10),
+		"REMOTE_JOBS_HOST=" + job.Host,
+		"REMOTE_JOBS_STATUS=" + string(job.Status),
+		"REMOTE_JOBS_EXIT_CODE=" + exitCode,
+		"REMOTE_JOBS_COMMAND=" + job.EffectiveCommand(),
+		"REMOTE_JOBS_WORKING_DIR=" + job.EffectiveWorkingDir(),
+		"REMOTE_JOBS_DESCRIPTION=" + job.Description,
+		"REMOTE_JOBS_START_TIME=" + strconv.FormatInt(job.StartTime, 10),
+		"REMOTE_JOBS_END_TIME=" + endTime,
+	}
+}
+
+// RunPending runs the completion hooks of every finished job whose hooks have
+// not fired yet. Hook output is written to out. It returns the number of
+// hooks that were run, and an error for each that failed, ending with the
+// last line it wrote.
+func RunPending(database *sql.DB, out io.Writer) (int, error) {
+	pending, err := db.ListPendingHooks(database)
+	if err != nil {
+		return 0, fmt.Errorf("list pending hooks: %w", err)
+	}
+
+	var ran int
+	var failures []error
+	for _, h := range pending {
+		claimed, err := db.ClaimHooks(database, h.JobID)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("claim hooks for job %d: %w", h.JobID, err))
+			return ran, errors.Join(failures...)
+		}
+		if !claimed {
+			continue
+		}
+		job, err := db.GetJobByID(database, h.JobID)
+		if err != nil || job == nil {
+			continue
+		}
+		command := CommandFor(job, h)
+		if command == "" {
+			continue
+		}
+		ran++
+		var output bytes.Buffer
+		if err := Run(command, job, io.MultiWriter(out, &output)); err != nil {
+			if line := lastLine(output.String()); line != "" {
+				err = fmt.Errorf("%w: %s", err, line)
+			}
+			failures = append(failures, fmt.Errorf("hook for job %d failed: %w", job.ID, err))
+		}
+	}
+	return ran, errors.Join(failures...)
+}
+
+// lastLine returns the last non-blank line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Run runs a local shell command with the job's REMOTE_JOBS_* environment,
+// plus any extra VAR=value entries
+func Run(command string, job *db.Job, out io.Writer, extraEnv ...string) error {
+	cmd := localShell(command)
+	cmd.Env = append(append(os.Environ(), Env(job)...), extraEnv...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// localShell returns a command that runs command in the local shell: sh, or
+// cmd on Windows
+func localShell(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,107 @@
+package hooks
+
+import (
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestCommandFor(t *testing.T) {
+	zero, one, skipped := 0, 1, db.ExitSkipped
+	h := db.JobHooks{OnSuccess: "echo ok", OnFailure: "echo fail"}
+
+	tests := []struct {
+		name string
+		job  *db.Job
+		want string
+	}{
+		{"success", &db.Job{Status: db.StatusCompleted, ExitCode: &zero}, "echo ok"},
+		{"non-zero exit", &db.Job{Status: db.StatusCompleted, ExitCode: &one}, "echo fail"},
+		{"missing exit code", &db.Job{Status: db.StatusCompleted}, "echo fail"},
+		{"dead", &db.Job{Status: db.StatusDead}, "echo fail"},
+		{"failed to start", &db.Job{Status: db.StatusFailed}, "echo fail"},
+		{"skipped", &db.Job{Status: db.StatusCompleted, ExitCode: &skipped}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CommandFor(tt.job, h); got != tt.want {
+				t.Errorf("CommandFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnv(t *testing.T) {
+	exitCode := 2
+	endTime := int64(1700000100)
+	job := &db.Job{
+		ID:          42,
+		Host:        "cool30",
+		Status:      db.StatusCompleted,
+		ExitCode:    &exitCode,
+		Command:     "export A=1 && python train.py",
+		WorkingDir:  "~/code",
+		Description: "training",
+		StartTime:   1700000000,
+		EndTime:     &endTime,
+	}
+
+	env := Env(job)
+	for _, want := range []string{
+		"REMOTE_JOBS_JOB_ID=42",
+		"REMOTE_JOBS_HOST=cool30",
+		"REMOTE_JOBS_STATUS=completed",
+		"REMOTE_JOBS_EXIT_CODE=2",
+		"REMOTE_JOBS_COMMAND=python train.py",
+		"REMOTE_JOBS_WORKING_DIR=~/code",
+		"REMOTE_JOBS_DESCRIPTION=training",
+		"REMOTE_JOBS_START_TIME=1700000000",
+		"REMOTE_JOBS_END_TIME=1700000100",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("Env() missing %q; got %v", want, env)
+		}
+	}
+}
+
+func TestRunPendingReportsFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	finished := func(onSuccess string) {
+		t.Helper()
+		id, err := db.RecordStart(database, "cool30", "", "~/code", "make", 1000, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetJobHooks(database, id, onSuccess, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordCompletionByID(database, id, 0, 2000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	finished("echo ok")
+	finished("echo downloading; echo 'no such file' >&2; exit 1")
+
+	var out strings.Builder
+	ran, err := RunPending(database, &out)
+	if ran != 2 {
+		t.Errorf("RunPending() ran %d hooks, want 2", ran)
+	}
+	if err == nil || !strings.Contains(err.Error(), "hook for job 2 failed: exit status 1: no such file") || strings.Contains(err.Error(), "job 1") {
+		t.Errorf("RunPending() error = %v, want the failure of job 2's hook with its last line", err)
+	}
+	if !strings.Contains(out.String(), "downloading") {
+		t.Errorf("hook output = %q, want it written to out", out.String())
+	}
+}
@@ -0,0 +1,132 @@
+// Package hostevents detects host-level incidents (GPU Xid errors, thermal
+// throttling, and OOM kills) that often explain jobs that died without an
+// exit status.
+package hostevents
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// Event kinds
+const (
+	KindXid     = "xid"
+	KindThermal = "thermal"
+	KindOOM     = "oom"
+)
+
+// kernelPattern selects the kernel log lines worth recording
+const kernelPattern = `NVRM: Xid|Out of memory|oom-kill|Killed process|throttled|critical temperature|temperature above threshold`
+
+// Command returns a shell command that prints recent kernel incidents and
+// current GPU thermal slowdowns. It reads the kernel log with journalctl,
+// falling back to dmesg where the journal isn't readable.
+func Command(since time.Time) string {
+	return fmt.Sprintf(`out=$(journalctl -k -o short-unix --no-pager --since @%d 2>/dev/null); `+
+		`[ -z "$out" ] && out=$(dmesg --time-format iso 2>/dev/null); `+
+		`printf '%%s\n' "$out" | grep -E '%s' | tail -200 | sed 's/^/KMSG:/'; `+
+		`nvidia-smi --query-gpu=index,clocks_throttle_reasons.hw_thermal_slowdown,clocks_throttle_reasons.sw_thermal_slowdown --format=csv,noheader 2>/dev/null | sed 's/^/GPUTHROTTLE:/'; `+
+		`true`, since.Unix(), kernelPattern)
+}
+
+// Parse parses the output of Command into events for host. Kernel messages
+// older than since, or without a timestamp, are dropped. An active GPU
+// thermal slowdown is recorded once per hour at most.
+func Parse(host, output string, since, now time.Time) []db.HostEvent {
+	var events []db.HostEvent
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "KMSG:"):
+			t, msg, ok := parseKernelLine(strings.TrimPrefix(line, "KMSG:"))
+			if !ok || t.Before(since) {
+				continue
+			}
+			events = append(events, db.HostEvent{
+				Host:    host,
+				Time:    t.Unix(),
+				Kind:    Classify(msg),
+				Message: msg,
+			})
+		case strings.HasPrefix(line, "GPUTHROTTLE:"):
+			fields := strings.Split(strings.TrimPrefix(line, "GPUTHROTTLE:"), ",")
+			if len(fields) < 3 {
+				continue
+			}
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+			if fields[1] != "Active" && fields[2] != "Active" {
+				continue
+			}
+			events = append(events, db.HostEvent{
+				Host:    host,
+				Time:    now.Truncate(time.Hour).Unix(),
+				Kind:    KindThermal,
+				Message: fmt.Sprintf("GPU %s thermal slowdown active", fields[0]),
+			})
+		}
+	}
+	return events
+}
+
+// Classify returns the kind of a kernel message
+func Classify(msg string) string {
+	switch {
+	case strings.Contains(msg, "NVRM: Xid"):
+		return KindXid
+	case strings.Contains(msg, "Out of memory"), strings.Contains(msg, "oom-kill"), strings.Contains(msg, "Killed process"):
+		return KindOOM
+	default:
+		return KindThermal
+	}
+}
+
+// parseKernelLine splits a journalctl short-unix line
+// ("1697551234.123456 host kernel: msg") or a dmesg ISO line
+// ("2023-10-17T10:00:00,123456+00:00 msg") into its time and message
+func parseKernelLine(line string) (time.Time, string, bool) {
+	stamp, rest, ok := strings.Cut(strings.TrimSpace(line), " ")
+	if !ok {
+		return time.Time{}, "", false
+	}
+	rest = strings.TrimSpace(rest)
+
+	if secs, err := strconv.ParseFloat(stamp, 64); err == nil {
+		if _, msg, ok := strings.Cut(rest, "kernel: "); ok {
+			rest = msg
+		}
+		return time.Unix(int64(secs), 0), rest, true
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05,000000-07:00", stamp); err == nil {
+		return t, rest, true
+	}
+	return time.Time{}, "", false
+}
+
+// Summary describes events by kind, e.g. "2 OOM kills, 1 Xid error"
+func Summary(events []db.HostEvent) string {
+	counts := make(map[string]int)
+	for _, e := range events {
+		counts[e.Kind]++
+	}
+	var parts []string
+	for _, k := range []struct{ kind, one, many string }{
+		{KindOOM, "OOM kill", "OOM kills"},
+		{KindXid, "Xid error", "Xid errors"},
+		{KindThermal, "thermal event", "thermal events"},
+	} {
+		switch n := counts[k.kind]; n {
+		case 0:
+		case 1:
+			parts = append(parts, "1 "+k.one)
+		default:
+			parts = append(parts, fmt.Sprintf("%d %s", n, k.many))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,60 @@
+package hostevents
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1700003000, 0)
+	since := time.Unix(1700000000, 0)
+	output := `KMSG:1700001000.123456 gpu1 kernel: NVRM: Xid (PCI:0000:3b:00): 79, pid=1234, GPU has fallen off the bus.
+KMSG:1700002000.000000 gpu1 kernel: Out of memory: Killed process 4321 (python) total-vm:100kB
+KMSG:1690000000.000000 gpu1 kernel: Out of memory: Killed process 1 (old)
+KMSG:2023-11-14T22:30:00,000000+00:00 CPU3: Core temperature above threshold, cpu clock throttled
+KMSG:[12345.678] no timestamp we can use
+GPUTHROTTLE:0, Not Active, Not Active
+GPUTHROTTLE:1, Active, Not Active
+`
+	events := Parse("gpu1", output, since, now)
+
+	want := []struct {
+		time int64
+		kind string
+	}{
+		{1700001000, KindXid},
+		{1700002000, KindOOM},
+		{1700001000, KindThermal},
+		{now.Truncate(time.Hour).Unix(), KindThermal},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Parse() returned %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		if events[i].Time != w.time || events[i].Kind != w.kind || events[i].Host != "gpu1" {
+			t.Errorf("event %d = %+v, want time %d kind %s", i, events[i], w.time, w.kind)
+		}
+	}
+	if got := events[1].Message; got != "Out of memory: Killed process 4321 (python) total-vm:100kB" {
+		t.Errorf("message = %q", got)
+	}
+	if got := events[3].Message; got != "GPU 1 thermal slowdown active" {
+		t.Errorf("throttle message = %q", got)
+	}
+
+	if got, want := Summary(events), "1 OOM kill, 1 Xid error, 2 thermal events"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+func TestCommandIsValidShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	cmd := exec.Command("sh", "-n", "-c", Command(time.Unix(1700000000, 0)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sh -n: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,191 @@
+// Package hostimport reads lists of hosts to add to the hosts cache in bulk:
+// the concrete hosts of an OpenSSH client config, and inventory files that
+// give hosts tags and groups.
+package hostimport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Host is a host to add, with the tags to give it
+type Host struct {
+	Name string
+	Tags []string
+}
+
+// maxIncludeDepth bounds nested Include directives, as ssh does
+const maxIncludeDepth = 16
+
+// SSHConfigHosts returns the hosts named by the Host lines of an ssh config
+// file, followed by those of the files it includes. Patterns such as "*" or
+// "cool*" match hosts rather than naming them, so they are skipped, as are
+// names starting with "-", which ssh would read as options. Relative Include
+// paths are relative to the directory of the file at path, as ssh resolves
+// them relative to ~/.ssh.
+func SSHConfigHosts(path string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+	err := readSSHConfig(path, filepath.Dir(path), 0, func(names []string) {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				hosts = append(hosts, name)
+			}
+		}
+	})
+	return hosts, err
+}
+
+func readSSHConfig(path, dir string, depth int, add func([]string)) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: too many nested Include directives", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hosts, includes, err := parseSSHConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	add(hosts)
+	for _, pattern := range includes {
+		if strings.HasPrefix(pattern, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				pattern = filepath.Join(home, pattern[2:])
+			}
+		} else if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if err := readSSHConfig(match, dir, depth+1, add); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseSSHConfig returns the concrete host names of a config's Host lines,
+// and the paths of its Include lines
+func parseSSHConfig(r io.Reader) (hosts, includes []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// A keyword is separated from its arguments by whitespace or "="
+		keyword, args := line, ""
+		if i := strings.IndexAny(line, " \t="); i >= 0 {
+			keyword, args = line[:i], strings.TrimLeft(line[i:], " \t=")
+		}
+		switch strings.ToLower(keyword) {
+		case "host":
+			for _, name := range strings.Fields(args) {
+				name = strings.Trim(name, `"`)
+				if checkName(name) == nil && !strings.ContainsAny(name, "*?!") {
+					hosts = append(hosts, name)
+				}
+			}
+		case "include":
+			includes = append(includes, strings.Fields(args)...)
+		}
+	}
+	return hosts, includes, scanner.Err()
+}
+
+// inventory is the format of an inventory file:
+//
+//	hosts:
+//	  cool30:
+//	    tags: [a100, infiniband]
+//	  cool31:
+//	groups:
+//	  lab: [cool30, cool31, studio]
+type inventory struct {
+	Hosts  map[string]*inventoryHost `yaml:"hosts"`
+	Groups map[string][]string       `yaml:"groups"`
+}
+
+type inventoryHost struct {
+	Tags []string `yaml:"tags"`
+}
+
+// ParseInventory parses an inventory file. Each host has its own tags
+// followed by the names of the groups it is in, and hosts that are only
+// listed in groups are included. Hosts are returned in order of name.
+func ParseInventory(data []byte) ([]Host, error) {
+	var inv inventory
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("parse inventory: %w", err)
+	}
+	if len(inv.Hosts) == 0 && len(inv.Groups) == 0 {
+		return nil, fmt.Errorf("inventory lists no hosts (expected hosts: or groups:)")
+	}
+
+	tags := make(map[string][]string)
+	for name, h := range inv.Hosts {
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+		tags[name] = nil
+		if h != nil {
+			tags[name] = appendNew(tags[name], h.Tags...)
+		}
+	}
+	groups := make([]string, 0, len(inv.Groups))
+	for group := range inv.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for _, name := range inv.Groups[group] {
+			if err := checkName(name); err != nil {
+				return nil, fmt.Errorf("group %s: %w", group, err)
+			}
+			tags[name] = appendNew(tags[name], group)
+		}
+	}
+
+	hosts := make([]Host, 0, len(tags))
+	for name, t := range tags {
+		hosts = append(hosts, Host{Name: name, Tags: t})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts, nil
+}
+
+// checkName checks that name can be passed to ssh as a host: it has no
+// whitespace, and doesn't start with "-", which ssh would read as an option
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid host name %q", name)
+	}
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("invalid host name %q: must not start with \"-\"", name)
+	}
+	return nil
+}
+
+// appendNew appends the tags that list doesn't already have
+func appendNew(list []string, tags ...string) []string {
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(list, tag) {
+			list = append(list, tag)
+		}
+	}
+	return list
+}
@@ -0,0 +1,75 @@
+package hostimport
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSHConfigHosts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("config.d/lab", "Host cool30 cool31\n  HostName cool30.lab.example.com\n")
+	config := write("config", `# Personal hosts
+Include config.d/*
+
+Host studio
+    User me
+host=cool30
+Host "quoted" -oProxyCommand=evil
+Host *.example.com !bastion cool?? *
+Match host foo
+  User other
+`)
+
+	got, err := SSHConfigHosts(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "studio cool30 quoted cool31"; strings.Join(got, " ") != want {
+		t.Errorf("SSHConfigHosts() = %q, want %q", got, want)
+	}
+
+	if _, err := SSHConfigHosts(filepath.Join(dir, "missing")); err == nil {
+		t.Error("SSHConfigHosts() of a missing file succeeded, want an error")
+	}
+}
+
+func TestParseInventory(t *testing.T) {
+	hosts, err := ParseInventory([]byte(`
+hosts:
+  cool30:
+    tags: [a100, infiniband]
+  cool31:
+groups:
+  lab: [cool30, cool31, studio]
+  gpu: [cool30]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, h := range hosts {
+		got = append(got, h.Name+"="+strings.Join(h.Tags, ","))
+	}
+	want := "cool30=a100,infiniband,gpu,lab cool31=lab studio=lab"
+	if strings.Join(got, " ") != want {
+		t.Errorf("ParseInventory() = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"", "jobs: []", "hosts: [", "groups:\n  lab: ['two words']", "hosts:\n  -oProxyCommand=evil:", "groups:\n  lab: ['-x']"} {
+		if _, err := ParseInventory([]byte(bad)); err == nil {
+			t.Errorf("ParseInventory(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Package hosttools describes the versions of the tools jobs depend on
+// (python, conda, CUDA, docker, git) that the host-info script finds on a
+// host, and checks them against requirements such as python>=3.10.
+package hosttools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Names are the tools that are probed, in the order they are shown.
+// cuda is the CUDA toolkit's version, as nvcc reports it, and cuda-driver
+// the NVIDIA driver's.
+var Names = []string{"python", "conda", "cuda", "cuda-driver", "docker", "git"}
+
+// Versions maps the names of the tools found on a host to their versions.
+// Tools that weren't found are left out. A nil Versions means the host's
+// tools haven't been probed.
+type Versions map[string]string
+
+// Clean returns versions without the tools that weren't found, or an empty,
+// non-nil Versions if none were
+func Clean(versions map[string]string) Versions {
+	v := make(Versions, len(versions))
+	for name, version := range versions {
+		if version != "" {
+			v[name] = version
+		}
+	}
+	return v
+}
+
+// Decode parses versions as Encode formats them, for the host info cache.
+// An empty string, as cached before tools were probed, gives nil.
+func Decode(s string) Versions {
+	if s == "" {
+		return nil
+	}
+	var v map[string]string
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil
+	}
+	return Clean(v)
+}
+
+// FromHostInfo returns the versions in the output of the host-info script,
+// or nil if it has none
+func FromHostInfo(output string) Versions {
+	var info struct {
+		Tools map[string]string `json:"tools"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &info); err != nil || info.Tools == nil {
+		return nil
+	}
+	return Clean(info.Tools)
+}
+
+// Encode formats versions for the host info cache
+func (v Versions) Encode() string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// String lists the tools found, e.g. "python 3.11.4, cuda 12.2, git 2.39.2"
+func (v Versions) String() string {
+	var parts []string
+	for _, name := range Names {
+		if version := v[name]; version != "" {
+			parts = append(parts, name+" "+version)
+		}
+	}
+	if len(parts) == 0 {
+		return "none found"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Requirement is a version of a tool that a job requires, given to
+// run --require as e.g. python>=3.10
+type Requirement struct {
+	Tool    string
+	Op      string // >=, >, <=, <, ==, or !=
+	Version string
+}
+
+var requirementPattern = regexp.MustCompile(`^([a-z][a-z0-9-]*)\s*(>=|<=|==|!=|>|<|=)\s*(\d+(?:\.\d+)*)$`)
+
+// IsRequirement reports whether a --require value is a tool requirement
+// rather than a host tag: whether it has a comparison
+func IsRequirement(s string) bool {
+	return strings.ContainsAny(s, "<>=!")
+}
+
+// ParseRequirement parses a tool requirement, such as python>=3.10 or
+// cuda==12. = is the same as ==.
+func ParseRequirement(s string) (Requirement, error) {
+	m := requirementPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Requirement{}, fmt.Errorf("invalid requirement %q (expected e.g. python>=3.10)", s)
+	}
+	r := Requirement{Tool: m[1], Op: m[2], Version: m[3]}
+	if !slices.Contains(Names, r.Tool) {
+		return Requirement{}, fmt.Errorf("unknown tool %q in %q (expected %s)", r.Tool, s, strings.Join(Names, ", "))
+	}
+	if r.Op == "=" {
+		r.Op = "=="
+	}
+	return r, nil
+}
+
+func (r Requirement) String() string {
+	return r.Tool + r.Op + r.Version
+}
+
+// Satisfied reports whether version meets the requirement. == and != compare
+// only as many parts as the requirement gives, so python==3.10 is met by
+// 3.10.12; the other comparisons treat missing parts as 0.
+func (r Requirement) Satisfied(version string) bool {
+	switch r.Op {
+	case "==":
+		return hasPrefix(version, r.Version)
+	case "!=":
+		return !hasPrefix(version, r.Version)
+	}
+	c := Compare(version, r.Version)
+	switch r.Op {
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	case "<":
+		return c < 0
+	}
+	return false
+}
+
+// Check returns why versions don't meet the requirement, or "" if they do
+func (r Requirement) Check(versions Versions) string {
+	version, ok := versions[r.Tool]
+	switch {
+	case !ok:
+		return fmt.Sprintf("lacks %s (requires %s)", r.Tool, r)
+	case !r.Satisfied(version):
+		return fmt.Sprintf("has %s %s (requires %s)", r.Tool, version, r)
+	}
+	return ""
+}
+
+// Compare compares two dotted versions part by part, numerically, returning
+// -1, 0, or 1. Missing parts are 0, so 3.10 and 3.10.0 are equal.
+func Compare(a, b string) int {
+	as, bs := versionParts(a), versionParts(b)
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// hasPrefix reports whether version starts with the parts of prefix
+func hasPrefix(version, prefix string) bool {
+	vs, ps := versionParts(version), versionParts(prefix)
+	return len(vs) >= len(ps) && slices.Equal(vs[:len(ps)], ps)
+}
+
+// versionParts returns the numbers of a dotted version. A part with a
+// suffix, such as the 0rc1 of 3.13.0rc1, is read up to the suffix.
+func versionParts(version string) []int {
+	var parts []int
+	for _, s := range strings.Split(version, ".") {
+		end := 0
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		n, _ := strconv.Atoi(s[:end])
+		parts = append(parts, n)
+	}
+	return parts
+}
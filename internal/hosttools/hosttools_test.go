@@ -0,0 +1,83 @@
+package hosttools
+
+import "testing"
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Requirement
+	}{
+		{"python>=3.10", Requirement{"python", ">=", "3.10"}},
+		{"cuda = 12", Requirement{"cuda", "==", "12"}},
+		{"cuda-driver<550", Requirement{"cuda-driver", "<", "550"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRequirement(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRequirement(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"python>=", "perl>=5", "python>=3.x"} {
+		if _, err := ParseRequirement(bad); err == nil {
+			t.Errorf("ParseRequirement(%q) should fail", bad)
+		}
+	}
+	if IsRequirement("a100") || !IsRequirement("git>=2.30") {
+		t.Error("IsRequirement should tell tags from tool requirements")
+	}
+}
+
+func TestSatisfied(t *testing.T) {
+	tests := []struct {
+		req, version string
+		want         bool
+	}{
+		{"python>=3.10", "3.11.4", true},
+		{"python>=3.10", "3.9.18", false},
+		{"python>=3.10", "3.10", true},
+		{"python>3.10", "3.10.0", false},
+		{"python==3.10", "3.10.12", true},
+		{"python==3.10", "3.1", false},
+		{"python!=3.12", "3.12.1", false},
+		{"cuda<12.4", "12.2", true},
+		{"cuda-driver>=535", "535.104.05", true},
+		{"python>=3.13", "3.13.0rc1", true},
+	}
+	for _, tt := range tests {
+		r, err := ParseRequirement(tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Satisfied(tt.version); got != tt.want {
+			t.Errorf("%s satisfied by %s = %v, want %v", tt.req, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	v := Decode(`{"git":"2.39.2","python":"3.11.4","conda":""}`)
+	if got, want := v.String(), "python 3.11.4, git 2.39.2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if Decode("") != nil {
+		t.Error("Decode(\"\") should be nil: the tools weren't probed")
+	}
+	if Decode(Clean(nil).Encode()) == nil {
+		t.Error("a host with none of the tools should stay probed")
+	}
+	if got := FromHostInfo(`{"arch":"Linux x86_64","tools":{"python":"3.11.4","git":""}}` + "\n"); got.String() != "python 3.11.4" {
+		t.Errorf("FromHostInfo() = %v, want python 3.11.4", got)
+	}
+	if FromHostInfo(`{"arch":"Linux x86_64"}`) != nil {
+		t.Error("FromHostInfo() of output without tools should be nil")
+	}
+
+	r, _ := ParseRequirement("python>=3.12")
+	if got, want := r.Check(v), "has python 3.11.4 (requires python>=3.12)"; got != want {
+		t.Errorf("Check() = %q, want %q", got, want)
+	}
+	r, _ = ParseRequirement("docker>=20")
+	if got, want := r.Check(v), "lacks docker (requires docker>=20)"; got != want {
+		t.Errorf("Check() = %q, want %q", got, want)
+	}
+}
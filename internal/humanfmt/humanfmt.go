@@ -0,0 +1,265 @@
+// Package humanfmt formats sizes, durations, and relative times for people
+// to read, so that every command and the TUI show them the same way.
+//
+// Sizes are shown in binary units (KiB, MiB, GiB: powers of 1024) unless
+// decimal units (kB, MB, GB: powers of 1000) are configured. Fractions use
+// the decimal separator of the configured locale, or of the one in the
+// environment (LC_ALL, LC_NUMERIC, or LANG), so "1.5GiB" reads "1,5GiB" in a
+// German locale. Output meant for other programs, such as export's, doesn't
+// go through this package.
+package humanfmt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unit systems for sizes
+const (
+	UnitsBinary  = "binary"  // KiB, MiB, GiB, TiB
+	UnitsDecimal = "decimal" // kB, MB, GB, TB
+)
+
+// Options controls how sizes and numbers are displayed. Empty fields take
+// the defaults: binary units and the environment's locale.
+type Options struct {
+	Units  string `yaml:"units"`
+	Locale string `yaml:"locale"` // e.g. "de_DE", or "C" for a decimal point
+}
+
+// Validate returns an error if the units aren't recognized
+func (o Options) Validate() error {
+	switch o.Units {
+	case "", UnitsBinary, UnitsDecimal:
+		return nil
+	default:
+		return fmt.Errorf("unknown units %q (valid: binary, decimal)", o.Units)
+	}
+}
+
+// settings are the options in effect, as set by Set
+type settings struct {
+	decimal   bool
+	separator string
+}
+
+var (
+	mu      sync.RWMutex
+	current = settings{separator: "."}
+)
+
+// Set makes o the options used by this package's functions. Until it is
+// called, sizes are binary and fractions use a decimal point.
+func Set(o Options) {
+	locale := o.Locale
+	if locale == "" {
+		locale = envLocale()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = settings{decimal: o.Units == UnitsDecimal, separator: DecimalSeparator(locale)}
+}
+
+func get() settings {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// envLocale returns the locale that governs number formatting in the
+// environment
+func envLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// commaLanguages are the languages whose numbers use a decimal comma
+var commaLanguages = map[string]bool{
+	"af": true, "az": true, "be": true, "bg": true, "bs": true, "ca": true,
+	"cs": true, "da": true, "de": true, "el": true, "es": true, "et": true,
+	"eu": true, "fi": true, "fo": true, "fr": true, "gl": true, "hr": true,
+	"hu": true, "hy": true, "id": true, "is": true, "it": true, "ka": true,
+	"kk": true, "ky": true, "lt": true, "lv": true, "mk": true, "mn": true,
+	"nb": true, "nl": true, "nn": true, "no": true, "pl": true, "pt": true,
+	"ro": true, "ru": true, "sk": true, "sl": true, "sq": true, "sr": true,
+	"sv": true, "tr": true, "uk": true, "uz": true, "vi": true,
+}
+
+// DecimalSeparator returns the decimal separator for a locale such as
+// "de_DE.UTF-8", decided by its language
+func DecimalSeparator(locale string) string {
+	language, _, _ := strings.Cut(locale, ".")
+	language, _, _ = strings.Cut(language, "@")
+	language = strings.ToLower(language)
+	if i := strings.IndexAny(language, "_-"); i >= 0 {
+		language = language[:i]
+	}
+	if commaLanguages[language] {
+		return ","
+	}
+	return "."
+}
+
+// Decimal formats f with the given number of digits after the decimal
+// separator
+func Decimal(f float64, digits int) string {
+	s := strconv.FormatFloat(f, 'f', digits, 64)
+	if sep := get().separator; sep != "." {
+		s = strings.Replace(s, ".", sep, 1)
+	}
+	return s
+}
+
+var (
+	binaryUnits  = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	decimalUnits = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+)
+
+// Bytes formats a size, e.g. "512B", "12MiB", or "68.4GiB". Sizes from a
+// gigabyte up have one decimal place.
+func Bytes(n int64) string {
+	units, base := binaryUnits, 1024.0
+	if get().decimal {
+		units, base = decimalUnits, 1000.0
+	}
+	value := float64(n)
+	i := 0
+	for value >= base && i < len(units)-1 {
+		value /= base
+		i++
+	}
+	if i < 3 {
+		return fmt.Sprintf("%d%s", int64(value), units[i])
+	}
+	return Decimal(value, 1) + units[i]
+}
+
+// KiB formats a size given in KiB, as /proc and df -k report them
+func KiB(kib int64) string {
+	return Bytes(kib * 1024)
+}
+
+// MiB formats a size given in MiB, as nvidia-smi reports them
+func MiB(mib int) string {
+	return Bytes(int64(mib) * 1024 * 1024)
+}
+
+// sizeSuffixes are the suffixes ParseSize accepts, with their multipliers.
+// Single letters and "i" forms are binary, as free -h, df -h, and nvidia-smi
+// print them; "kB", "MB", "GB", and "TB" are decimal. Longer suffixes come
+// first so that "GiB" isn't read as "B".
+var sizeSuffixes = []struct {
+	suffix string
+	scale  float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "80 GiB", "16G", "58.5Gi", or "512MB" into
+// bytes. A number without a suffix is in units of bare bytes.
+func ParseSize(s string, bare int64) (int64, bool) {
+	s = strings.TrimSpace(s)
+	scale := float64(bare)
+	for _, u := range sizeSuffixes {
+		if rest, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, scale = strings.TrimSpace(rest), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int64(n * scale), true
+}
+
+// ParseMiB parses a size in MiB, the unit of a number without a suffix, and
+// returns 0 if s isn't a size
+func ParseMiB(s string) int {
+	n, ok := ParseSize(s, 1<<20)
+	if !ok {
+		return 0
+	}
+	return int(n >> 20)
+}
+
+// Duration formats seconds in human-readable form, e.g. "2h 5s" or "3m 12s"
+func Duration(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+
+	var parts []string
+	if h > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", h))
+	}
+	if m > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", m))
+	}
+	if s > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%ds", s))
+	}
+	return strings.Join(parts, " ")
+}
+
+// DurationShort formats seconds compactly for table columns, keeping the
+// two most significant units (e.g. "45s", "3m12s", "2h05m", "3d4h"), or "—"
+// if seconds is negative (unknown)
+func DurationShort(seconds int64) string {
+	if seconds < 0 {
+		return "—"
+	}
+	d, h, m, s := seconds/86400, seconds%86400/3600, seconds%3600/60, seconds%60
+	switch {
+	case d > 0:
+		return fmt.Sprintf("%dd%dh", d, h)
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
+
+// Latency formats a round-trip time, e.g. "42ms" or "1.3s", or "—" if it
+// is 0 (not measured)
+func Latency(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "—"
+	case d < time.Second:
+		return fmt.Sprintf("%dms", max(d.Milliseconds(), 1))
+	default:
+		return Decimal(d.Seconds(), 1) + "s"
+	}
+}
+
+// Relative formats how long ago something happened, e.g. "5m ago"
+func Relative(elapsed time.Duration) string {
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	case elapsed < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(elapsed.Hours()/24))
+	}
+}
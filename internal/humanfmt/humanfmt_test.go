@@ -0,0 +1,167 @@
+package humanfmt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		opts Options
+		n    int64
+		want string
+	}{
+		{Options{Locale: "C"}, 512, "512B"},
+		{Options{Locale: "C"}, 12 * 1024 * 1024, "12MiB"},
+		{Options{Locale: "C"}, 3 * 1024 * 1024 * 1024 / 2, "1.5GiB"},
+		{Options{Locale: "C"}, 2 * 1024 * 1024 * 1024 * 1024, "2.0TiB"},
+		{Options{Units: UnitsDecimal, Locale: "C"}, 12 * 1000 * 1000, "12MB"},
+		{Options{Units: UnitsDecimal, Locale: "C"}, 1500 * 1000 * 1000, "1.5GB"},
+		{Options{Locale: "de_DE.UTF-8"}, 3 * 1024 * 1024 * 1024 / 2, "1,5GiB"},
+	}
+	defer Set(Options{Locale: "C"})
+	for _, tt := range tests {
+		Set(tt.opts)
+		if got := Bytes(tt.n); got != tt.want {
+			t.Errorf("Bytes(%d) with %+v = %q, want %q", tt.n, tt.opts, got, tt.want)
+		}
+	}
+	Set(Options{Locale: "C"})
+	if got := MiB(70041); got != "68.4GiB" {
+		t.Errorf("MiB(70041) = %q", got)
+	}
+	if got := KiB(10485760); got != "10.0GiB" {
+		t.Errorf("KiB(10485760) = %q", got)
+	}
+}
+
+func TestDecimalSeparator(t *testing.T) {
+	tests := map[string]string{
+		"":            ".",
+		"C":           ".",
+		"POSIX":       ".",
+		"en_US.UTF-8": ".",
+		"de_DE.UTF-8": ",",
+		"fr_FR@euro":  ",",
+		"pt-BR":       ",",
+		"ja_JP":       ".",
+	}
+	for locale, want := range tests {
+		if got := DecimalSeparator(locale); got != want {
+			t.Errorf("DecimalSeparator(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestParseMiB(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"123MiB", 123},
+		{"12 MiB", 12},
+		{"80GiB", 80 * 1024},
+		{"80 GiB", 80 * 1024},
+		{"16G", 16 * 1024},
+		{"128Gi", 128 * 1024},
+		{"58.5G", int(58.5 * 1024)},
+		{"0.5GiB", 512},
+		{"1000MB", 953},
+		{"512", 512},
+		{"", 0},
+		{"N/A", 0},
+		{"unknown", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := ParseMiB(tt.input); got != tt.want {
+				t.Errorf("ParseMiB(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		seconds  int64
+		expected string
+	}{
+		{0, "0s"},
+		{1, "1s"},
+		{59, "59s"},
+		{60, "1m"},
+		{61, "1m 1s"},
+		{119, "1m 59s"},
+		{120, "2m"},
+		{3600, "1h"},
+		{3601, "1h 1s"},
+		{3661, "1h 1m 1s"},
+		{7200, "2h"},
+		{7325, "2h 2m 5s"},
+		{86400, "24h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			got := Duration(tt.seconds)
+			if got != tt.expected {
+				t.Errorf("Duration(%d) = %q, want %q", tt.seconds, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDurationShort(t *testing.T) {
+	tests := []struct {
+		seconds  int64
+		expected string
+	}{
+		{-1, "—"},
+		{0, "0s"},
+		{59, "59s"},
+		{61, "1m01s"},
+		{3599, "59m59s"},
+		{3661, "1h01m"},
+		{86399, "23h59m"},
+		{90000, "1d1h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			got := DurationShort(tt.seconds)
+			if got != tt.expected {
+				t.Errorf("DurationShort(%d) = %q, want %q", tt.seconds, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRelative(t *testing.T) {
+	tests := map[time.Duration]string{
+		10 * time.Second: "just now",
+		5 * time.Minute:  "5m ago",
+		3 * time.Hour:    "3h ago",
+		72 * time.Hour:   "3d ago",
+	}
+	for elapsed, want := range tests {
+		if got := Relative(elapsed); got != want {
+			t.Errorf("Relative(%v) = %q, want %q", elapsed, got, want)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                       "—",
+		300 * time.Microsecond:  "1ms",
+		42 * time.Millisecond:   "42ms",
+		1250 * time.Millisecond: "1.2s",
+		12 * time.Second:        "12.0s",
+	}
+	for d, want := range tests {
+		if got := Latency(d); got != want {
+			t.Errorf("Latency(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
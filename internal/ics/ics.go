@@ -0,0 +1,94 @@
+// Package ics writes iCalendar (RFC 5545) files.
+package ics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a single calendar event
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Location    string
+	Description string
+}
+
+// timeFormat is the UTC date-time form used for DTSTART, DTEND, and DTSTAMP
+const timeFormat = "20060102T150405Z"
+
+// maxLineOctets is the longest content line allowed before folding
+const maxLineOctets = 75
+
+// Write writes a VCALENDAR containing events. now is used as the DTSTAMP of
+// every event.
+func Write(w io.Writer, name string, events []Event, now time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//remote-jobs//remote-jobs//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	if name != "" {
+		lines = append(lines, "X-WR-CALNAME:"+escapeText(name))
+	}
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escapeText(e.UID),
+			"DTSTAMP:"+now.UTC().Format(timeFormat),
+			"DTSTART:"+e.Start.UTC().Format(timeFormat),
+			"DTEND:"+e.End.UTC().Format(timeFormat),
+			"SUMMARY:"+escapeText(e.Summary),
+		)
+		if e.Location != "" {
+			lines = append(lines, "LOCATION:"+escapeText(e.Location))
+		}
+		if e.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeText(e.Description))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, fold(line)+"\r\n"); err != nil {
+			return fmt.Errorf("write calendar: %w", err)
+		}
+	}
+	return nil
+}
+
+// textEscaper escapes the characters that are special in TEXT property values
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// fold splits a content line into chunks of at most 75 octets, continuing
+// each chunk with CRLF and a space. Multi-byte characters are never split.
+func fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	width := 0
+	for _, r := range line {
+		n := len(string(r))
+		if width+n > limit {
+			b.WriteString("\r\n ")
+			width = 0
+			limit = maxLineOctets - 1 // the leading space counts toward the limit
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	events := []Event{{
+		UID:         "job-42@remote-jobs",
+		Start:       start,
+		End:         start.Add(3 * time.Hour),
+		Summary:     "Train model, run 1; lr=0.01",
+		Location:    "cool30",
+		Description: "python train.py\nexit 0",
+	}}
+
+	var b strings.Builder
+	if err := Write(&b, "remote-jobs", events, start); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:remote-jobs\r\n",
+		"UID:job-42@remote-jobs\r\n",
+		"DTSTART:20240301T090000Z\r\n",
+		"DTEND:20240301T120000Z\r\n",
+		`SUMMARY:Train model\, run 1\; lr=0.01` + "\r\n",
+		"LOCATION:cool30\r\n",
+		`DESCRIPTION:python train.py\nexit 0` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:short"},
+		{"ascii", "DESCRIPTION:" + strings.Repeat("x", 200)},
+		{"multibyte", "DESCRIPTION:" + strings.Repeat("…", 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := fold(tt.line)
+			parts := strings.Split(folded, "\r\n")
+			for i, part := range parts {
+				if len(part) > maxLineOctets {
+					t.Errorf("line %d is %d octets: %q", i, len(part), part)
+				}
+				if i > 0 && !strings.HasPrefix(part, " ") {
+					t.Errorf("continuation line %d doesn't start with a space: %q", i, part)
+				}
+			}
+			if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolded = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
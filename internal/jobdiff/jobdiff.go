@@ -0,0 +1,173 @@
+// Package jobdiff compares two jobs: what they ran (command, environment,
+// working directory, git revision) and how it went (duration, exit code),
+// and the tails of their logs.
+package jobdiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// Run is a job with what was recorded about what it ran
+type Run struct {
+	Job *db.Job
+	Env []string        // "VAR=value"
+	Git *db.GitRevision // nil if unknown
+}
+
+// Row is a property of the two jobs being compared
+type Row struct {
+	Name string
+	A, B string
+}
+
+// Differs reports whether the jobs differ in this property
+func (r Row) Differs() bool {
+	return r.A != r.B
+}
+
+// Compare returns the properties of two runs, as of now (for the duration of
+// running jobs). Unknown values are shown as "—". Each environment variable
+// set in either run is a row.
+func Compare(a, b Run, now int64) []Row {
+	rows := []Row{
+		{"Host", a.Job.Host, b.Job.Host},
+		{"Command", a.Job.EffectiveCommand(), b.Job.EffectiveCommand()},
+		{"Working dir", a.Job.WorkingDir, b.Job.WorkingDir},
+		{"Git commit", gitString(a.Git), gitString(b.Git)},
+	}
+	envA, envB := envMap(a.Env), envMap(b.Env)
+	for _, name := range envNames(envA, envB) {
+		rows = append(rows, Row{"$" + name, valueOrDash(envA[name]), valueOrDash(envB[name])})
+	}
+	rows = append(rows,
+		Row{"Status", string(a.Job.Status), string(b.Job.Status)},
+		Row{"Duration", duration(a.Job, now), duration(b.Job, now)},
+		Row{"Exit code", exitCode(a.Job), exitCode(b.Job)},
+	)
+	return rows
+}
+
+// envMap maps variable names to values. A variable set twice has its later value.
+func envMap(envVars []string) map[string]string {
+	m := make(map[string]string)
+	for _, v := range envVars {
+		name, value, _ := strings.Cut(v, "=")
+		if name != "" {
+			m[name] = value
+		}
+	}
+	return m
+}
+
+// envNames returns the names of the variables set in either map, sorted
+func envNames(a, b map[string]string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range []map[string]string{a, b} {
+		for name := range m {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func gitString(rev *db.GitRevision) string {
+	if rev == nil {
+		return "—"
+	}
+	return rev.String()
+}
+
+func duration(job *db.Job, now int64) string {
+	elapsed := job.Elapsed(now)
+	if elapsed < 0 {
+		return "—"
+	}
+	return humanfmt.Duration(elapsed)
+}
+
+func exitCode(job *db.Job) string {
+	if job.ExitCode == nil {
+		return "—"
+	}
+	return fmt.Sprintf("%d", *job.ExitCode)
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// Op is how a line of a log diff changed
+type Op byte
+
+const (
+	Same    Op = ' '
+	Removed Op = '-' // Only in the first log
+	Added   Op = '+' // Only in the second log
+)
+
+// Line is a line of a log diff
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// String formats the line as in a unified diff, e.g. "- loss: 0.42"
+func (l Line) String() string {
+	return string(l.Op) + " " + l.Text
+}
+
+// Lines diffs two logs line by line, keeping the longest run of lines they
+// have in common
+func Lines(a, b []string) []Line {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []Line
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Same, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Removed, a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Added, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Removed, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Added, b[j]})
+	}
+	return lines
+}
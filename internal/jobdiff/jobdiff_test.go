@@ -0,0 +1,73 @@
+package jobdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestCompare(t *testing.T) {
+	zero, one := 0, 1
+	end1, end2 := int64(1100), int64(1300)
+	a := Run{
+		Job: &db.Job{ID: 1, Host: "cool30", Command: "python train.py", WorkingDir: "~/code", Status: db.StatusCompleted, StartTime: 1000, EndTime: &end1, ExitCode: &zero},
+		Env: []string{"LR=0.1", "SEED=1"},
+		Git: &db.GitRevision{Commit: "3f2a9c1d"},
+	}
+	b := Run{
+		Job: &db.Job{ID: 2, Host: "cool30", Command: "python train.py", WorkingDir: "~/code", Status: db.StatusCompleted, StartTime: 1000, EndTime: &end2, ExitCode: &one},
+		Env: []string{"LR=0.2", "BATCH=64"},
+	}
+
+	var differing []string
+	for _, row := range Compare(a, b, 2000) {
+		if row.Differs() {
+			differing = append(differing, row.Name+": "+row.A+" | "+row.B)
+		}
+	}
+	want := []string{
+		"Git commit: 3f2a9c1d | —",
+		"$BATCH: — | 64",
+		"$LR: 0.1 | 0.2",
+		"$SEED: 1 | —",
+		"Duration: 1m 40s | 5m",
+		"Exit code: 0 | 1",
+	}
+	if !reflect.DeepEqual(differing, want) {
+		t.Errorf("Compare() differing rows =\n%s\nwant\n%s", strings.Join(differing, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "x\ny", "x\ny", "  x|  y"},
+		{"changed", "epoch 1\nloss 0.5\ndone", "epoch 1\nloss 0.4\ndone", "  epoch 1|- loss 0.5|+ loss 0.4|  done"},
+		{"added", "a", "a\nb", "  a|+ b"},
+		{"removed", "a\nb", "b", "- a|  b"},
+		{"empty", "", "a", "+ a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, line := range Lines(splitLines(tt.a), splitLines(tt.b)) {
+				got = append(got, line.String())
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("Lines() = %q, want %q", strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,204 @@
+// Package jobselect resolves the selectors that commands accept in place of
+// a job ID: "latest", for the most recently submitted job; references such
+// as @failed and @running[1]; and descriptions, matched loosely.
+package jobselect
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// Latest selects the most recently submitted job
+const Latest = "latest"
+
+// ParseID parses an argument that is a job ID rather than a selector
+func ParseID(arg string) (int64, bool) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// IsDescription reports whether selector is matched loosely against
+// descriptions, rather than being a job ID, Latest, or a reference
+func IsDescription(selector string) bool {
+	_, isID := ParseID(selector)
+	return !isID && selector != Latest && !strings.HasPrefix(selector, "@")
+}
+
+// AmbiguousError is returned for a description that matches jobs with
+// different descriptions equally well
+type AmbiguousError struct {
+	Selector string
+	Jobs     []*db.Job // Newest first
+}
+
+// maxListed is how many of the jobs an ambiguous selector matches are listed
+const maxListed = 5
+
+func (e *AmbiguousError) Error() string {
+	var listed []string
+	for i, job := range e.Jobs {
+		if i == maxListed {
+			listed = append(listed, fmt.Sprintf("and %d more", len(e.Jobs)-maxListed))
+			break
+		}
+		listed = append(listed, fmt.Sprintf("%d (%s)", job.ID, job.Description))
+	}
+	return fmt.Sprintf("%q matches %d jobs: %s; use a job ID or more of the description",
+		e.Selector, len(e.Jobs), strings.Join(listed, ", "))
+}
+
+// Select returns the job that selector picks from jobs, which are newest
+// first, or nil if there is none. Latest picks the first, and a reference
+// (see Reference) the job it refers to. Otherwise the selector is matched
+// against descriptions, and the best matches are kept (see Matches). If they
+// are runs of the same description, the newest is picked; if their
+// descriptions differ, the result is an AmbiguousError.
+func Select(jobs []*db.Job, selector string) (*db.Job, error) {
+	if selector == Latest {
+		if len(jobs) == 0 {
+			return nil, nil
+		}
+		return jobs[0], nil
+	}
+	if strings.HasPrefix(selector, "@") {
+		return Reference(jobs, selector)
+	}
+	matches := Matches(jobs, selector)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	for _, job := range matches[1:] {
+		if !strings.EqualFold(job.Description, matches[0].Description) {
+			return nil, &AmbiguousError{Selector: selector, Jobs: matches}
+		}
+	}
+	return matches[0], nil
+}
+
+// Matches returns the jobs whose descriptions match query best, in the
+// order given. Case is ignored. The best match is the whole description,
+// then a part of it, then a description that contains each of the query's
+// words, in any order.
+func Matches(jobs []*db.Job, query string) []*db.Job {
+	var best []*db.Job
+	bestScore := 0
+	for _, job := range jobs {
+		score := matchScore(job.Description, query)
+		switch {
+		case score == 0 || score < bestScore:
+		case score > bestScore:
+			best, bestScore = []*db.Job{job}, score
+		default:
+			best = append(best, job)
+		}
+	}
+	return best
+}
+
+// matchScore rates how well a description matches a query: 3 for the whole
+// description, 2 for a part of it, 1 for all of its words, and 0 otherwise
+func matchScore(description, query string) int {
+	d, q := strings.ToLower(description), strings.ToLower(strings.TrimSpace(query))
+	words := strings.Fields(q)
+	switch {
+	case d == "" || len(words) == 0:
+		return 0
+	case d == q:
+		return 3
+	case strings.Contains(d, q):
+		return 2
+	}
+	for _, w := range words {
+		if !strings.Contains(d, w) {
+			return 0
+		}
+	}
+	return 1
+}
+
+// references are the kinds of job a reference can refer to, with when each
+// job happened, for counting them most recent first
+var references = map[string]struct {
+	include func(*db.Job) bool
+	time    func(*db.Job) int64 // 0 to count by ID alone
+}{
+	"last":    {func(*db.Job) bool { return true }, func(*db.Job) int64 { return 0 }},
+	"failed":  {Failed, endTime},
+	"running": {func(j *db.Job) bool { return j.Status == db.StatusRunning }, func(j *db.Job) int64 { return j.StartTime }},
+}
+
+var referencePattern = regexp.MustCompile(`^@([a-z]+)(?:\[(\d+)\])?$`)
+
+// Reference returns the job that a reference such as @last, @failed, or
+// @running[1] refers to. @last counts jobs in the order they were submitted,
+// @failed in the order they ended, and @running in the order they started,
+// most recent first, with ties counted by ID; [N] picks the Nth, counting
+// from 0, and is [0] if left out. It is an error for there to be no such job.
+func Reference(jobs []*db.Job, ref string) (*db.Job, error) {
+	m := referencePattern.FindStringSubmatch(ref)
+	if m == nil {
+		return nil, fmt.Errorf("invalid job reference %q (expected @last, @failed, or @running, optionally with an index, e.g. @failed[1])", ref)
+	}
+	kind, ok := references[m[1]]
+	if !ok {
+		return nil, fmt.Errorf("unknown job reference %q (expected @last, @failed, or @running)", ref)
+	}
+	index := 0
+	if m[2] != "" {
+		var err error
+		if index, err = strconv.Atoi(m[2]); err != nil {
+			return nil, fmt.Errorf("invalid job reference %q: %w", ref, err)
+		}
+	}
+
+	var matched []*db.Job
+	for _, job := range jobs {
+		if kind.include(job) {
+			matched = append(matched, job)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if ta, tb := kind.time(a), kind.time(b); ta != tb {
+			return ta > tb
+		}
+		return a.ID > b.ID
+	})
+
+	noun := "jobs"
+	if m[1] != "last" {
+		noun = m[1] + " jobs"
+	}
+	switch {
+	case len(matched) == 0:
+		return nil, fmt.Errorf("%s: no %s", ref, noun)
+	case index >= len(matched):
+		return nil, fmt.Errorf("%s: there are only %d %s", ref, len(matched), noun)
+	}
+	return matched[index], nil
+}
+
+// Failed reports whether a job failed: exited with an error, couldn't
+// start, or died. A job the queue runner skipped didn't fail.
+func Failed(job *db.Job) bool {
+	switch job.Status {
+	case db.StatusFailed, db.StatusDead:
+		return true
+	case db.StatusCompleted:
+		return job.ExitCode != nil && *job.ExitCode != 0 && !job.Skipped()
+	}
+	return false
+}
+
+// endTime returns when a job ended, or when it started if that isn't known
+func endTime(job *db.Job) int64 {
+	if job.EndTime != nil {
+		return *job.EndTime
+	}
+	return job.StartTime
+}
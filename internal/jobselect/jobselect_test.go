@@ -0,0 +1,130 @@
+package jobselect
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestSelect(t *testing.T) {
+	// Newest first
+	jobs := []*db.Job{
+		{ID: 7, Description: "gpt2 ablation"},
+		{ID: 6, Description: "GPT2 ablation"},
+		{ID: 5, Description: "gpt2 ablation (no dropout)"},
+		{ID: 4, Description: "eval ablation gpt2"},
+		{ID: 3, Description: "bert baseline"},
+		{ID: 2, Description: "bert baseline v2"},
+		{ID: 1},
+	}
+	tests := []struct {
+		selector  string
+		want      int64 // 0 for none
+		ambiguous bool
+	}{
+		{Latest, 7, false},
+		{"gpt2 ablation", 7, false}, // The same description twice, in different cases: the newest
+		{"no dropout", 5, false},    // Part of a description
+		{"eval gpt2", 4, false},     // Words in any order
+		{"bert", 0, true},           // Part of two different descriptions
+		{"bert baseline", 3, false}, // The whole description beats a part
+		{"ablation dropout gpt2", 5, false},
+		{"llama", 0, false},
+		{"  ", 0, false},
+	}
+	for _, tt := range tests {
+		job, err := Select(jobs, tt.selector)
+		var ambiguous *AmbiguousError
+		if got := errors.As(err, &ambiguous); got != tt.ambiguous {
+			t.Errorf("Select(%q) error = %v, want ambiguous %v", tt.selector, err, tt.ambiguous)
+			continue
+		}
+		var got int64
+		if job != nil {
+			got = job.ID
+		}
+		if got != tt.want {
+			t.Errorf("Select(%q) = job %d, want %d", tt.selector, got, tt.want)
+		}
+	}
+	if job, err := Select(nil, Latest); job != nil || err != nil {
+		t.Errorf("Select(nil, Latest) = %v, %v, want nil", job, err)
+	}
+}
+
+func TestAmbiguousError(t *testing.T) {
+	var jobs []*db.Job
+	for id := int64(8); id > 0; id-- {
+		jobs = append(jobs, &db.Job{ID: id, Description: "sweep"})
+	}
+	err := &AmbiguousError{Selector: "sw", Jobs: jobs}
+	want := `"sw" matches 8 jobs: 8 (sweep), 7 (sweep), 6 (sweep), 5 (sweep), 4 (sweep), and 3 more; use a job ID or more of the description`
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	for arg, want := range map[string]bool{"42": true, "0": false, "-1": false, "latest": false, "42a": false} {
+		if _, got := ParseID(arg); got != want {
+			t.Errorf("ParseID(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
+
+func TestIsDescription(t *testing.T) {
+	for arg, want := range map[string]bool{"42": false, "latest": false, "@failed[1]": false, "gpt2 ablation": true, "42a": true} {
+		if got := IsDescription(arg); got != want {
+			t.Errorf("IsDescription(%q) = %v, want %v", arg, got, want)
+		}
+	}
+}
+
+func TestReference(t *testing.T) {
+	end := func(t int64) *int64 { return &t }
+	exit := func(c int) *int { return &c }
+	// Newest first; job 4 ended before job 2
+	jobs := []*db.Job{
+		{ID: 6, Status: db.StatusRunning, StartTime: 500},
+		{ID: 5, Status: db.StatusRunning, StartTime: 600},
+		{ID: 4, Status: db.StatusCompleted, StartTime: 300, EndTime: end(350), ExitCode: exit(1)},
+		{ID: 3, Status: db.StatusCompleted, StartTime: 200, EndTime: end(900), ExitCode: exit(0)},
+		{ID: 2, Status: db.StatusDead, StartTime: 100, EndTime: end(400)},
+		{ID: 1, Status: db.StatusRunning, StartTime: 600},
+	}
+	tests := []struct {
+		ref  string
+		want int64 // 0 for an error
+	}{
+		{"@last", 6},
+		{"@last[2]", 4},
+		{"@failed", 2},
+		{"@failed[0]", 2},
+		{"@failed[1]", 4},
+		{"@failed[2]", 0},
+		{"@running", 5}, // Started last, with the higher ID of the two started at 600
+		{"@running[1]", 1},
+		{"@running[2]", 6},
+		{"@queued", 0},
+		{"@failed[-1]", 0},
+		{"@", 0},
+	}
+	for _, tt := range tests {
+		job, err := Select(jobs, tt.ref)
+		var got int64
+		if job != nil {
+			got = job.ID
+		}
+		if got != tt.want || (err == nil) != (tt.want != 0) {
+			t.Errorf("Select(%q) = job %d, %v; want job %d", tt.ref, got, err, tt.want)
+		}
+	}
+	if _, err := Reference(jobs, "@failed[5]"); err == nil || err.Error() != "@failed[5]: there are only 2 failed jobs" {
+		t.Errorf("Reference(@failed[5]) error = %v", err)
+	}
+	if _, err := Reference(jobs, "@failed[99999999999999999999]"); err == nil || !strings.Contains(err.Error(), "invalid job reference") {
+		t.Errorf("Reference(@failed[99999999999999999999]) error = %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Package jobstate checks and changes the state of jobs' processes on their
+// hosts, and records what finished jobs left behind. The CLI and the TUI
+// share it, so that both reach the same verdicts.
+package jobstate
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// host returns the host to run commands for a job on, as the account the
+// job was started as (see ssh.WithUser)
+func host(job *db.Job) string {
+	return ssh.WithUser(job.Host, job.RemoteUser)
+}
+
+// Signal sends signal (e.g. "STOP" or "CONT") to a job's process tree on its
+// host
+func Signal(job *db.Job, signal string) error {
+	stdout, stderr, err := ssh.Run(host(job), session.SignalJobCommand(job.ID, signal))
+	if err != nil {
+		return fmt.Errorf("%s", ssh.FriendlyError(job.Host, stderr, err))
+	}
+	switch strings.TrimSpace(stdout) {
+	case "ok":
+		return nil
+	case "not_running":
+		return fmt.Errorf("job %d has no running process on %s", job.ID, job.Host)
+	default:
+		return fmt.Errorf("failed to send SIG%s to job %d", signal, job.ID)
+	}
+}
+
+// SyncPaused checks whether a paused job has been resumed, has finished, or
+// has died, and records what it finds on behalf of source (e.g. "sync"). A
+// job paused to make way for another is resumed here if the preempting job
+// ended without resuming it (e.g. it was killed). It returns true if the
+// job's status changed, and an error if its host couldn't be reached.
+func SyncPaused(database *sql.DB, job *db.Job, source string, timeout time.Duration) (bool, error) {
+	stdout, _, err := ssh.RunWithTimeout(host(job), session.JobStateCommand(job.ID), timeout)
+	if err != nil {
+		return false, err
+	}
+
+	result := strings.TrimSpace(stdout)
+	if pid, dead := session.DeadState(result); dead {
+		return MarkDeadAfterGrace(database, job, source, StateDeadEvidence(job, pid))
+	}
+	switch result {
+	case "PAUSED":
+		if !preemptorFinished(database, job.ID) {
+			return false, nil
+		}
+		if err := Signal(job, "CONT"); err != nil {
+			return false, nil
+		}
+		return true, db.MarkResumed(database, job.ID)
+	case "RUNNING":
+		return true, db.MarkResumed(database, job.ID)
+	case "":
+		return false, nil
+	default:
+		exitCode, err := strconv.Atoi(result)
+		if err != nil {
+			return false, nil
+		}
+		return true, db.RecordCompletionByID(database, job.ID, exitCode, time.Now().Unix())
+	}
+}
+
+// preemptorFinished reports whether a job paused by preemption is waiting on a
+// job that has already ended
+func preemptorFinished(database *sql.DB, jobID int64) bool {
+	pause, err := db.GetJobPause(database, jobID)
+	if err != nil || pause == nil || pause.PreemptedBy == 0 {
+		return false
+	}
+	by, err := db.GetJobByID(database, pause.PreemptedBy)
+	if err != nil {
+		return false
+	}
+	return by == nil || by.Status == db.StatusCompleted || by.Status == db.StatusDead || by.Status == db.StatusFailed
+}
+
+var (
+	deadJobsMu  sync.Mutex
+	deadJobsCfg = config.DefaultConfig()
+)
+
+// SetConfig sets the config whose dead_jobs settings MarkDeadAfterGrace
+// applies, so that it is read once rather than for each check
+func SetConfig(cfg *config.Config) {
+	deadJobsMu.Lock()
+	defer deadJobsMu.Unlock()
+	deadJobsCfg = cfg
+}
+
+// MarkDeadAfterGrace records that a check by source found a job dead, and
+// marks it dead once enough consecutive checks over long enough have, as set
+// by the host's dead_jobs config. It reports whether the job was marked dead.
+func MarkDeadAfterGrace(database *sql.DB, job *db.Job, source string, evidence []db.Evidence) (bool, error) {
+	deadJobsMu.Lock()
+	probes, grace := deadJobsCfg.HostDeadJobs(job.Host)
+	deadJobsMu.Unlock()
+	return db.MarkDeadAfterGrace(database, job.ID, source, evidence, probes, grace)
+}
+
+// SessionDeadEvidence is the evidence that a job with its own tmux session is
+// dead: the session is gone and the job left no status file
+func SessionDeadEvidence(tmuxSession, statusFile string) []db.Evidence {
+	return []db.Evidence{
+		{Check: "tmux session " + tmuxSession, Result: "missing"},
+		{Check: "status file " + statusFile, Result: "missing"},
+	}
+}
+
+// QueueRunnerDeadEvidence is the evidence that a job started by a queue
+// runner is dead: it left no status file, the runner isn't running it or
+// holding it, and the PID it recorded, if any, isn't running
+func QueueRunnerDeadEvidence(job *db.Job, queueName, pid string) []db.Evidence {
+	return []db.Evidence{
+		{Check: "status file " + session.StatusFilePattern(job.ID), Result: "missing"},
+		{Check: "queue " + queueName + " current job", Result: "another job or none"},
+		{Check: "queue " + queueName + " waiting jobs", Result: "not listed"},
+		PIDEvidence(job.ID, pid),
+	}
+}
+
+// StateDeadEvidence is the evidence behind a DEAD from
+// session.JobStateCommand, given the PID it reported
+func StateDeadEvidence(job *db.Job, pid string) []db.Evidence {
+	return []db.Evidence{
+		{Check: "status file " + session.StatusFilePattern(job.ID), Result: "missing"},
+		PIDEvidence(job.ID, pid),
+	}
+}
+
+// PIDEvidence describes a check of a job's PID file that found no running
+// process, given the PID the file recorded, if any
+func PIDEvidence(jobID int64, pid string) db.Evidence {
+	check := "PID file " + session.PidFilePattern(jobID)
+	if pid == "" {
+		return db.Evidence{Check: check, Result: "no PID recorded"}
+	}
+	return db.Evidence{Check: check, Result: "process " + pid + " not running"}
+}
+
+// RunsWithoutTmux reports whether a job was started with the nohup backend
+func RunsWithoutTmux(database *sql.DB, jobID int64) bool {
+	backend, err := db.GetJobBackend(database, jobID)
+	return err == nil && backend == session.BackendNohup
+}
+
+// Kill ends a running or paused job and marks it dead. A job with its own
+// tmux session has the session killed; one without, started by a queue
+// runner or without tmux, has its process tree sent SIGTERM. A paused job is
+// continued first, since a stopped process can't act on the signal.
+func Kill(database *sql.DB, job *db.Job) error {
+	if job.Status == db.StatusPaused {
+		Signal(job, "CONT")
+	}
+	var err error
+	if job.SessionName == "" || RunsWithoutTmux(database, job.ID) {
+		err = Signal(job, "TERM")
+	} else {
+		err = ssh.TmuxKillSession(host(job), session.JobTmuxSession(job.ID, job.SessionName))
+	}
+	if err != nil {
+		return err
+	}
+	return db.MarkDeadByID(database, job.ID)
+}
@@ -0,0 +1,117 @@
+package jobstate
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestSyncPausedResumesPreemptedJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	ssh.SetSandbox(t.TempDir())
+	defer ssh.SetSandbox("")
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	runningJob := func() *db.Job {
+		t.Helper()
+		id, err := db.RecordJobStarting(database, "cool30", "~/code", "make", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.UpdateJobRunning(database, id); err != nil {
+			t.Fatal(err)
+		}
+		job, err := db.GetJobByID(database, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+	paused, preemptor := runningJob(), runningJob()
+
+	process := exec.Command("sleep", "60")
+	if err := process.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer process.Process.Kill()
+	pidFile := filepath.Join(ssh.SandboxHostDir("cool30"), ".cache", "remote-jobs", "logs", fmt.Sprintf("%d-1000.pid", paused.ID))
+	if err := os.MkdirAll(filepath.Dir(pidFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pidFile, []byte(fmt.Sprint(process.Process.Pid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("kill", "-STOP", fmt.Sprint(process.Process.Pid)).CombinedOutput(); err != nil {
+		t.Fatalf("stop: %v\n%s", err, out)
+	}
+	if err := db.MarkPaused(database, paused.ID, preemptor.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the preempting job runs, the paused job stays paused
+	if changed, err := SyncPaused(database, paused, "sync", 10*time.Second); err != nil || changed {
+		t.Fatalf("SyncPaused() = %v, %v; want no change", changed, err)
+	}
+
+	// Once it's gone, the paused job is resumed
+	if err := db.MarkDeadByID(database, preemptor.ID); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := SyncPaused(database, paused, "sync", 10*time.Second); err != nil || !changed {
+		t.Fatalf("SyncPaused() = %v, %v; want the job resumed", changed, err)
+	}
+	if job, err := db.GetJobByID(database, paused.ID); err != nil || job.Status != db.StatusRunning {
+		t.Errorf("job = %+v, %v; want it running", job, err)
+	}
+	if pause, err := db.GetJobPause(database, paused.ID); err != nil || pause != nil {
+		t.Errorf("pause record = %+v, %v; want it deleted", pause, err)
+	}
+}
+
+func TestMarkDeadAfterGraceUsesConfig(t *testing.T) {
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	id, err := db.RecordJobStarting(database, "cool30", "~/code", "make", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateJobRunning(database, id); err != nil {
+		t.Fatal(err)
+	}
+	job, err := db.GetJobByID(database, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evidence := StateDeadEvidence(job, "")
+
+	// By default, one check isn't enough
+	if dead, err := MarkDeadAfterGrace(database, job, "sync", evidence); err != nil || dead {
+		t.Fatalf("MarkDeadAfterGrace() = %v, %v; want the job given a grace period", dead, err)
+	}
+
+	one, zero := 1, 0
+	cfg := config.DefaultConfig()
+	cfg.Hosts = map[string]config.HostConfig{"cool30": {DeadJobs: config.DeadJobs{Probes: &one, GraceMinutes: &zero}}}
+	SetConfig(cfg)
+	defer SetConfig(config.DefaultConfig())
+	if dead, err := MarkDeadAfterGrace(database, job, "sync", evidence); err != nil || !dead {
+		t.Errorf("MarkDeadAfterGrace() = %v, %v; want the job marked dead", dead, err)
+	}
+}
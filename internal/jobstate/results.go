@@ -0,0 +1,56 @@
+package jobstate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/results"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// ResolveResults records the result metrics of jobs on host that have
+// finished since the last sync. A job whose results can't be read doesn't
+// stop the others from being read; the errors are returned together.
+func ResolveResults(database *sql.DB, host string) error {
+	specs, err := db.ListUnresolvedResults(database, host)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, spec := range specs {
+		job, err := db.GetJobByID(database, spec.JobID)
+		if err != nil || job == nil {
+			continue
+		}
+		if err := resolveJobResults(database, job, spec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveJobResults reads a job's results file and the end of its log from
+// its host and records the metrics found. A results file that isn't valid
+// JSON is reported after the metrics from the log are recorded.
+func resolveJobResults(database *sql.DB, job *db.Job, spec db.ResultSpec) error {
+	logCommand := ""
+	if len(spec.Patterns) > 0 {
+		logCommand = session.LogTailCommand(job.ID, job.SessionName, results.LogLines)
+	}
+	command := results.Command(job.EffectiveWorkingDir(), spec.File, logCommand)
+	stdout, stderr, err := ssh.RunWithTimeout(host(job), command, ssh.HostTimeouts(job.Host).Sync)
+	if err != nil {
+		return fmt.Errorf("read results of job %d: %s", job.ID, ssh.FriendlyError(job.Host, stderr, err))
+	}
+	metrics, parseErr := results.Parse(stdout, spec.Patterns)
+	if err := db.RecordResults(database, job.ID, metrics); err != nil {
+		return fmt.Errorf("record results of job %d: %w", job.ID, err)
+	}
+	if parseErr != nil {
+		return fmt.Errorf("job %d: %w", job.ID, parseErr)
+	}
+	return nil
+}
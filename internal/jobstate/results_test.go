@@ -0,0 +1,65 @@
+package jobstate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestResolveResultsReadsEveryJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	ssh.SetSandbox(t.TempDir())
+	defer ssh.SetSandbox("")
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	dir := filepath.Join(ssh.SandboxHostDir("cool30"), "code")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	finishedJob := func(file, content string) int64 {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := db.RecordJobStarting(database, "cool30", "~/code", "make", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.UpdateJobRunning(database, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetResultSpec(database, id, db.ResultSpec{File: file}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordCompletionByID(database, id, 0, time.Now().Unix()); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	bad := finishedJob("bad.json", "{not json")
+	good := finishedJob("good.json", `{"accuracy": 0.9}`)
+
+	err = ResolveResults(database, "cool30")
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("job %d", bad)) {
+		t.Errorf("ResolveResults() = %v, want an error for job %d", err, bad)
+	}
+	if metrics, err := db.GetJobResults(database, good); err != nil || metrics["accuracy"] != 0.9 {
+		t.Errorf("job %d results = %v, %v; want accuracy 0.9", good, metrics, err)
+	}
+	if specs, err := db.ListUnresolvedResults(database, "cool30"); err != nil || len(specs) != 0 {
+		t.Errorf("unresolved results = %v, %v; want none", specs, err)
+	}
+}
@@ -0,0 +1,312 @@
+// Package notify posts Slack messages about finished jobs from this machine,
+// for the notifiers in the config file.
+//
+// Unlike the notify script that runs on a host as each job exits, a notifier
+// learns that jobs have finished when sync, the TUI, or tray --sync does. It
+// queues them, so that it can send several in one message: those that finish
+// within its batch window, those held back by its hourly limit, or all those
+// of a digest period. Queued jobs are sent once they are due, by whichever
+// of those runs next.
+package notify
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/hooks"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/redact"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// maxListed is the most jobs a message lists one by one
+const maxListed = 20
+
+// Finished reports whether a job status change is to a final status
+func Finished(to string) bool {
+	switch db.Status(to) {
+	case db.StatusCompleted, db.StatusFailed, db.StatusDead:
+		return true
+	}
+	return false
+}
+
+// Due reports whether a notifier should send the jobs it has queued now,
+// given how many messages it has sent in the last hour and when it last sent
+// one (0 if it hasn't lately)
+func Due(n config.Notifier, pending []db.Notification, sentLastHour int, lastSent, now int64) bool {
+	if len(pending) == 0 {
+		return false
+	}
+	if n.MaxPerHour > 0 && sentLastHour >= n.MaxPerHour {
+		return false
+	}
+	if n.DigestHours > 0 {
+		start := lastSent
+		if start == 0 {
+			start = pending[0].QueuedAt
+		}
+		return now-start >= int64(n.DigestHours)*60*60
+	}
+	return now-pending[0].QueuedAt >= int64(n.BatchMinutes)*60
+}
+
+// Message returns the text of a Slack message about finished jobs. A single
+// job gets a message of its own; several get a count of their outcomes and
+// one entry each, up to maxListed. A digest is titled with its period. Each
+// job is formatted by format, or if it is nil, by the builtin format: a
+// sentence, followed by the command, redacted by r, when the job is alone.
+func Message(n config.Notifier, jobs []*db.Job, format func(*db.Job) string, r *redact.Redactor) string {
+	if len(jobs) == 1 && n.DigestHours == 0 {
+		job := jobs[0]
+		if format != nil {
+			return format(job)
+		}
+		return summary(job) + ".\nCommand: " + slackCode(r.String(job.EffectiveCommand()))
+	}
+	if format == nil {
+		format = summary
+	}
+
+	var failed, skipped int
+	for _, job := range jobs {
+		switch {
+		case job.Skipped():
+			skipped++
+		case !hooks.Succeeded(job):
+			failed++
+		}
+	}
+	noun := "jobs"
+	if len(jobs) == 1 {
+		noun = "job"
+	}
+	header := fmt.Sprintf("%d %s finished: %d succeeded, %d failed", len(jobs), noun, len(jobs)-failed-skipped, failed)
+	if skipped > 0 {
+		header += fmt.Sprintf(", %d skipped", skipped)
+	}
+	if n.DigestHours > 0 {
+		header = fmt.Sprintf("Jobs in the last %s — %s", humanfmt.Duration(int64(n.DigestHours)*60*60), header)
+	}
+	icon := ":white_check_mark:"
+	if failed > 0 {
+		icon = ":x:"
+	}
+	lines := []string{fmt.Sprintf("%s *%s*", icon, header)}
+	for i, job := range jobs {
+		if i == maxListed {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(jobs)-maxListed))
+			break
+		}
+		lines = append(lines, format(job))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// summary describes a job in the builtin format, e.g. ":x: Job *42* on
+// `cool30` failed with exit code 1 in 5m 3s"
+func summary(job *db.Job) string {
+	return fmt.Sprintf("%s %s %s%s", emoji(job), jobTitle(job), outcome(job), duration(job))
+}
+
+// jobTitle names a job by its description, if it has one, and its ID and host
+func jobTitle(job *db.Job) string {
+	if job.Description != "" {
+		return fmt.Sprintf("*%s* (job %d on %s)", job.Description, job.ID, slackCode(job.Host))
+	}
+	return fmt.Sprintf("Job *%d* on %s", job.ID, slackCode(job.Host))
+}
+
+func emoji(job *db.Job) string {
+	switch {
+	case hooks.Succeeded(job):
+		return ":white_check_mark:"
+	case job.Skipped():
+		return ":fast_forward:"
+	}
+	return ":x:"
+}
+
+// outcome describes how a finished job ended
+func outcome(job *db.Job) string {
+	switch {
+	case hooks.Succeeded(job):
+		return "completed successfully"
+	case job.Skipped():
+		return "was skipped"
+	case job.ExitCode != nil:
+		return fmt.Sprintf("failed with exit code %d", *job.ExitCode)
+	case job.Status == db.StatusDead:
+		return "died"
+	}
+	return "failed"
+}
+
+// duration returns " in" and how long the job ran, or "" if that's unknown
+func duration(job *db.Job) string {
+	if elapsed := job.Elapsed(time.Now().Unix()); elapsed >= 0 {
+		return " in " + humanfmt.Duration(elapsed)
+	}
+	return ""
+}
+
+// slackCode formats a value as inline code, unless it contains backticks,
+// which Slack can't escape
+func slackCode(s string) string {
+	if strings.Contains(s, "`") {
+		return s
+	}
+	return "`" + s + "`"
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Post sends a message to a Slack incoming webhook
+func Post(url, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// DeliverPending queues the jobs that have finished since the last call, by
+// any process, for the notifiers whose settings they match, then sends each
+// notifier's queued jobs if they are due. The jobs of a message are claimed
+// before it is sent, so that two processes don't both send them, and
+// requeued if it can't be sent, to be tried again the next time. Commands
+// and logs are redacted by r. Failures are written to out. It returns the number of
+// messages sent.
+func DeliverPending(database *sql.DB, notifiers []config.Notifier, r *redact.Redactor, out io.Writer) (int, error) {
+	if len(notifiers) == 0 {
+		return 0, nil
+	}
+	events, err := db.ClaimNotifierEvents(database)
+	if err != nil {
+		return 0, fmt.Errorf("claim events for notifiers: %w", err)
+	}
+
+	now := time.Now().Unix()
+	for _, e := range events {
+		if e.Kind != db.EventJob || !Finished(e.To) {
+			continue
+		}
+		job, err := db.GetJobByID(database, e.JobID)
+		if err != nil || job == nil {
+			continue
+		}
+		for i, n := range notifiers {
+			failuresOnly, err := n.NotifiesFailuresOnly()
+			if err != nil {
+				fmt.Fprintf(out, "Warning: Slack notifier %d: %v\n", i+1, err)
+				continue
+			}
+			if n.Slack == "" || failuresOnly && (hooks.Succeeded(job) || job.Skipped()) {
+				continue
+			}
+			if err := db.QueueNotification(database, n.Slack, job.ID, now); err != nil {
+				return 0, fmt.Errorf("queue notification: %w", err)
+			}
+		}
+	}
+
+	var sent int
+	for i, n := range notifiers {
+		if n.Slack == "" {
+			continue
+		}
+		pending, err := db.PendingNotifications(database, n.Slack)
+		if err != nil {
+			return sent, fmt.Errorf("list notifications: %w", err)
+		}
+		count, last, err := db.NotifierSends(database, n.Slack, now-60*60)
+		if err != nil {
+			return sent, fmt.Errorf("list notifier sends: %w", err)
+		}
+		if !Due(n, pending, count, last, now) {
+			continue
+		}
+
+		var jobs []*db.Job
+		ids := make([]int64, len(pending))
+		for j, p := range pending {
+			ids[j] = p.JobID
+			// Jobs removed since they were queued are dropped
+			if job, err := db.GetJobByID(database, p.JobID); err == nil && job != nil {
+				jobs = append(jobs, job)
+			}
+		}
+		if len(jobs) == 0 {
+			if err := db.DropNotifications(database, n.Slack, ids); err != nil {
+				return sent, fmt.Errorf("drop notifications: %w", err)
+			}
+			continue
+		}
+		claimed, err := db.ClaimNotifications(database, n.Slack, ids, now)
+		if err != nil {
+			return sent, fmt.Errorf("claim notifications: %w", err)
+		}
+		if !claimed {
+			// Another process is sending them
+			continue
+		}
+		if err := Post(n.Slack, Message(n, jobs, templateFormat(n, i, r, out), r)); err != nil {
+			fmt.Fprintf(out, "Warning: Slack notifier %d: %v\n", i+1, err)
+			if err := db.UnclaimNotifications(database, n.Slack, pending, now); err != nil {
+				return sent, fmt.Errorf("requeue notifications: %w", err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// templateFormat returns a function that formats a job with the notifier's
+// template, fetching the end of the job's log if the template shows it, or
+// nil if the notifier has no template. The command and log are redacted by
+// r. A template that doesn't parse is reported to out, and a job it can't
+// format is described in the builtin format.
+func templateFormat(n config.Notifier, index int, r *redact.Redactor, out io.Writer) func(*db.Job) string {
+	if n.Template == "" {
+		return nil
+	}
+	tmpl, err := ParseTemplate(n.Template)
+	if err != nil {
+		fmt.Fprintf(out, "Warning: Slack notifier %d: %v\n", index+1, err)
+		return nil
+	}
+	return func(job *db.Job) string {
+		var logTail string
+		if UsesLogTail(n.Template) {
+			host := ssh.WithUser(job.Host, job.RemoteUser)
+			logTail, _, _ = ssh.RunWithTimeout(host, session.LogTailCommand(job.ID, job.SessionName, LogTailLines), ssh.HostTimeouts(job.Host).Sync)
+		}
+		fields := JobFields(job, r.String(logTail))
+		fields.Command = r.String(fields.Command)
+		text, err := Render(tmpl, fields)
+		if err != nil {
+			fmt.Fprintf(out, "Warning: Slack notifier %d: %v\n", index+1, err)
+			return summary(job)
+		}
+		return text
+	}
+}
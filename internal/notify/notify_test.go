@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/redact"
+)
+
+func TestDue(t *testing.T) {
+	pending := []db.Notification{{JobID: 1, QueuedAt: 1000}, {JobID: 2, QueuedAt: 1100}}
+	tests := []struct {
+		name     string
+		n        config.Notifier
+		pending  []db.Notification
+		sent     int
+		lastSent int64
+		now      int64
+		want     bool
+	}{
+		{"nothing queued", config.Notifier{}, nil, 0, 0, 1000, false},
+		{"immediate", config.Notifier{}, pending, 0, 0, 1000, true},
+		{"within the batch window", config.Notifier{BatchMinutes: 5}, pending, 0, 0, 1200, false},
+		{"batch window over", config.Notifier{BatchMinutes: 5}, pending, 0, 0, 1300, true},
+		{"hourly limit reached", config.Notifier{MaxPerHour: 3}, pending, 3, 900, 1300, false},
+		{"under the hourly limit", config.Notifier{MaxPerHour: 3}, pending, 2, 900, 1300, true},
+		{"first digest pending", config.Notifier{DigestHours: 1}, pending, 0, 0, 1000 + 3599, false},
+		{"first digest due", config.Notifier{DigestHours: 1}, pending, 0, 0, 1000 + 3600, true},
+		{"digest since last sent", config.Notifier{DigestHours: 1}, pending, 0, 500, 500 + 3600, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Due(tt.n, tt.pending, tt.sent, tt.lastSent, tt.now); got != tt.want {
+				t.Errorf("Due() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	end, ok, bad := int64(1300), 0, 2
+	succeeded := &db.Job{ID: 41, Host: "cool30", Command: "python train.py", Description: "baseline",
+		Status: db.StatusCompleted, ExitCode: &ok, StartTime: 1000, EndTime: &end}
+	failed := &db.Job{ID: 42, Host: "cool31", Command: "python train.py --lr 1",
+		Status: db.StatusCompleted, ExitCode: &bad, StartTime: 1000, EndTime: &end}
+
+	got := Message(config.Notifier{}, []*db.Job{failed}, nil, nil)
+	want := ":x: Job *42* on `cool31` failed with exit code 2 in 5m.\nCommand: `python train.py --lr 1`"
+	if got != want {
+		t.Errorf("Message(one job) = %q, want %q", got, want)
+	}
+
+	got = Message(config.Notifier{BatchMinutes: 5}, []*db.Job{succeeded, failed}, nil, nil)
+	for _, line := range []string{
+		":x: *2 jobs finished: 1 succeeded, 1 failed*",
+		":white_check_mark: *baseline* (job 41 on `cool30`) completed successfully in 5m",
+		":x: Job *42* on `cool31` failed with exit code 2 in 5m",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("Message(batch) missing %q:\n%s", line, got)
+		}
+	}
+
+	got = Message(config.Notifier{}, []*db.Job{succeeded, failed}, func(job *db.Job) string { return job.Host }, nil)
+	if !strings.HasSuffix(got, "*\ncool30\ncool31") {
+		t.Errorf("Message(batch, format) = %q", got)
+	}
+
+	r, err := redact.New(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &db.Job{ID: 43, Host: "cool31", Command: "python train.py --api-key abc123456789",
+		Status: db.StatusCompleted, ExitCode: &bad, StartTime: 1000, EndTime: &end}
+	got = Message(config.Notifier{}, []*db.Job{secret}, nil, r)
+	if !strings.HasSuffix(got, "Command: `python train.py --api-key <redacted>`") {
+		t.Errorf("Message(one job, redacted) = %q", got)
+	}
+
+	got = Message(config.Notifier{DigestHours: 6}, []*db.Job{succeeded}, nil, nil)
+	if !strings.HasPrefix(got, ":white_check_mark: *Jobs in the last 6h — 1 job finished") {
+		t.Errorf("Message(digest) = %q", got)
+	}
+}
+
+func TestTemplateFormatRedacts(t *testing.T) {
+	r, err := redact.New(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := templateFormat(config.Notifier{Template: "{{.Command}}"}, 0, r, io.Discard)
+	got := format(&db.Job{ID: 42, Host: "cool30", Command: "python train.py --api-key abc123456789"})
+	if want := "python train.py --api-key <redacted>"; got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+}
+
+func TestPost(t *testing.T) {
+	var text string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload map[string]string
+		json.Unmarshal(body, &payload)
+		text = payload["text"]
+	}))
+	defer srv.Close()
+
+	if err := Post(srv.URL, "done \"quoted\""); err != nil {
+		t.Fatal(err)
+	}
+	if text != `done "quoted"` {
+		t.Errorf("posted text = %q", text)
+	}
+}
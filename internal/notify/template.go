@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/hooks"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// LogTailLines is how many of the last lines of a job's log .LogTail holds
+const LogTailLines = 10
+
+// Fields are what a message template is given about a finished job. They
+// are text, so that the notify script on a host can fill them in; see
+// HostMessages.
+type Fields struct {
+	ID          string
+	Description string
+	Host        string
+	Command     string
+	Dir         string
+	Duration    string // e.g. "5m 3s", or "" if unknown
+	ExitCode    string // "" if the job died without one
+	LogTail     string // The last LogTailLines lines of the log
+	Succeeded   bool
+}
+
+// JobFields returns the fields of a finished job, given the end of its log
+func JobFields(job *db.Job, logTail string) Fields {
+	f := Fields{
+		ID:          strconv.FormatInt(job.ID, 10),
+		Description: job.Description,
+		Host:        job.Host,
+		Command:     job.EffectiveCommand(),
+		Dir:         job.EffectiveWorkingDir(),
+		LogTail:     strings.TrimRight(logTail, "\n"),
+		Succeeded:   hooks.Succeeded(job),
+	}
+	if elapsed := job.Elapsed(time.Now().Unix()); elapsed >= 0 {
+		f.Duration = humanfmt.Duration(elapsed)
+	}
+	if job.ExitCode != nil {
+		f.ExitCode = strconv.Itoa(*job.ExitCode)
+	}
+	return f
+}
+
+// ParseTemplate parses a message template, a Go text/template that is
+// given Fields
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("message").Parse(text)
+}
+
+// Render formats a job's fields with a message template
+func Render(tmpl *template.Template, f Fields) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, f); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// UsesLogTail reports whether a template shows the end of the job's log,
+// which is only fetched from the job's host if it does
+func UsesLogTail(text string) bool {
+	return strings.Contains(text, ".LogTail")
+}
+
+// hostPlaceholders are the fields the notify script fills in, and the
+// placeholders that stand for them in the messages HostMessages renders
+var hostPlaceholders = Fields{
+	ID:          "@@ID@@",
+	Description: "@@DESCRIPTION@@",
+	Host:        "@@HOST@@",
+	Command:     "@@COMMAND@@",
+	Dir:         "@@DIR@@",
+	Duration:    "@@DURATION@@",
+	ExitCode:    "@@EXIT_CODE@@",
+	LogTail:     "@@LOG_TAIL@@",
+}
+
+// HostMessages renders a template for the notify script that runs on a host
+// when a job exits, where Go templates can't run: once for a job that
+// succeeds and once for one that fails. Every field but Succeeded is a
+// placeholder, such as @@HOST@@, that the script replaces with its value, so
+// only Succeeded can be tested with if.
+func HostMessages(text string) (succeeded, failed string, err error) {
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return "", "", err
+	}
+	f := hostPlaceholders
+	f.Succeeded = true
+	if succeeded, err = Render(tmpl, f); err != nil {
+		return "", "", err
+	}
+	f.Succeeded = false
+	if failed, err = Render(tmpl, f); err != nil {
+		return "", "", err
+	}
+	return succeeded, failed, nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+const testTemplate = `{{if .Succeeded}}:tada:{{else}}:fire: exit {{.ExitCode}}{{end}} {{.ID}} on {{.Host}} after {{.Duration}}
+{{.LogTail}}`
+
+func TestRender(t *testing.T) {
+	end, bad := int64(1065), 1
+	job := &db.Job{ID: 42, Host: "cool30", Command: "python train.py",
+		Status: db.StatusCompleted, ExitCode: &bad, StartTime: 1000, EndTime: &end}
+
+	tmpl, err := ParseTemplate(testTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Render(tmpl, JobFields(job, "loss: nan\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ":fire: exit 1 42 on cool30 after 1m 5s\nloss: nan"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	if _, err := ParseTemplate("{{.ID"); err == nil {
+		t.Error("ParseTemplate should reject an unclosed action")
+	}
+	if !UsesLogTail(testTemplate) || UsesLogTail("{{.ID}}") {
+		t.Error("UsesLogTail should find .LogTail")
+	}
+}
+
+func TestHostMessages(t *testing.T) {
+	succeeded, failed, err := HostMessages(testTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ":tada: @@ID@@ on @@HOST@@ after @@DURATION@@\n@@LOG_TAIL@@"; succeeded != want {
+		t.Errorf("succeeded = %q, want %q", succeeded, want)
+	}
+	if want := ":fire: exit @@EXIT_CODE@@ @@ID@@ on @@HOST@@ after @@DURATION@@\n@@LOG_TAIL@@"; failed != want {
+		t.Errorf("failed = %q, want %q", failed, want)
+	}
+}
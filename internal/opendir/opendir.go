@@ -0,0 +1,168 @@
+// Package opendir builds URIs that open a job's remote working directory in
+// a local application, such as VS Code's Remote-SSH window or a file
+// manager's SFTP view, and launches them.
+package opendir
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/pathmap"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// DefaultTemplate is the preset used when none is configured
+const DefaultTemplate = "vscode"
+
+// Presets are named URI templates. In a template, {host} is replaced with
+// the job's host, {path} with the absolute path of its working directory
+// (which needs an SSH round trip to expand ~), {dir} with the directory as
+// recorded (e.g., ~/code/project), and {local} with the local directory a
+// path mapping pairs it with, such as an SSHFS mount point.
+var Presets = map[string]string{
+	"vscode": "vscode://vscode-remote/ssh-remote+{host}{path}",
+	"cursor": "cursor://vscode-remote/ssh-remote+{host}{path}",
+	"sftp":   "sftp://{host}{path}",
+}
+
+// localPresets replace the presets for jobs on this machine (see
+// ssh.IsLocal), which open as local folders instead of over SSH
+var localPresets = map[string]string{
+	"vscode": "vscode://file{path}",
+	"cursor": "cursor://file{path}",
+	"sftp":   "file://{path}",
+}
+
+// Template returns the URI template named by s: a preset name, a template
+// containing at least one placeholder, or "" for the default preset
+func Template(s string) (string, error) {
+	if s == "" {
+		s = DefaultTemplate
+	}
+	if t, ok := Presets[s]; ok {
+		return t, nil
+	}
+	for _, placeholder := range []string{"{host}", "{path}", "{dir}", "{local}"} {
+		if strings.Contains(s, placeholder) {
+			return s, nil
+		}
+	}
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown open_dir preset %q (expected %s, or a template using {host}, {path}, {dir}, or {local})", s, strings.Join(names, ", "))
+}
+
+// NeedsPath reports whether a template uses {path}, so the directory must be
+// resolved on the host
+func NeedsPath(template string) bool {
+	return strings.Contains(template, "{path}")
+}
+
+// URI fills in a template. path and local may be empty if the template
+// doesn't use them.
+func URI(template, host, dir, path, local string) string {
+	return strings.NewReplacer(
+		"{host}", host,
+		"{path}", escapePath(path),
+		"{dir}", escapePath(dir),
+		"{local}", escapePath(filepath.ToSlash(local)),
+	).Replace(template)
+}
+
+// escapePath percent-encodes each segment of a path, keeping the slashes
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// ResolveCommand prints the absolute path of dir, expanding a leading ~
+func ResolveCommand(dir string) string {
+	return "cd " + shellquote.HomePath(dir) + " && pwd"
+}
+
+// AbsolutePath returns dir as an absolute path on host. Absolute paths are
+// returned as they are; others are resolved over SSH.
+func AbsolutePath(host, dir string) (string, error) {
+	if strings.HasPrefix(dir, "/") {
+		return dir, nil
+	}
+	stdout, stderr, err := ssh.Run(host, ResolveCommand(dir))
+	if err != nil {
+		return "", fmt.Errorf("resolve %s on %s: %s", dir, host, strings.TrimSpace(stderr+stdout))
+	}
+	path := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("resolve %s on %s: unexpected output %q", dir, host, path)
+	}
+	return path, nil
+}
+
+// Build returns the URI for a job directory, resolving its path on the host
+// if the template needs it, and its local directory through the host's path
+// mappings. Presets open a local host's directories as local folders.
+func Build(template, host, dir string, mappings []pathmap.Mapping) (string, error) {
+	if ssh.IsLocal(host) {
+		for name, t := range Presets {
+			if t == template {
+				template = localPresets[name]
+			}
+		}
+	}
+	var path string
+	if NeedsPath(template) {
+		var err error
+		if path, err = AbsolutePath(host, dir); err != nil {
+			return "", err
+		}
+	}
+	var local string
+	if strings.Contains(template, "{local}") {
+		var ok bool
+		if local, ok = pathmap.ToLocal(mappings, dir); !ok && path != "" {
+			local, ok = pathmap.ToLocal(mappings, path)
+		}
+		if !ok {
+			return "", fmt.Errorf("no path mapping for %s on %s, so it has no local directory", dir, host)
+		}
+	}
+	return URI(template, host, dir, path, local), nil
+}
+
+// openCommand returns the command that opens a URI with its registered
+// application on an OS
+func openCommand(goos string) []string {
+	switch goos {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	default:
+		return []string{"xdg-open"}
+	}
+}
+
+// Open hands a URI to the application registered for it. The launchers
+// return once the application has it, and fail if none is registered.
+func Open(uri string) error {
+	args := append(openCommand(runtime.GOOS), uri)
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %s", args[0], msg)
+		}
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package opendir
+
+import (
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/pathmap"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := map[string]string{
+		"":                       Presets["vscode"],
+		"sftp":                   "sftp://{host}{path}",
+		"zed://ssh/{host}{path}": "zed://ssh/{host}{path}",
+		"smb://nas/home{dir}":    "smb://nas/home{dir}",
+	}
+	for s, want := range tests {
+		if got, err := Template(s); err != nil || got != want {
+			t.Errorf("Template(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	if _, err := Template("emacs"); err == nil {
+		t.Error("Template(\"emacs\") = nil error, want error")
+	}
+}
+
+func TestURI(t *testing.T) {
+	tests := []struct {
+		template, host, dir, path, local, want string
+	}{
+		{Presets["vscode"], "cool30", "~/code/LM2", "/home/ada/code/LM2", "", "vscode://vscode-remote/ssh-remote+cool30/home/ada/code/LM2"},
+		{Presets["sftp"], "ada@cool30", "~/my project", "/home/ada/my project", "", "sftp://ada@cool30/home/ada/my%20project"},
+		{"sftp://{host}/{dir}", "cool30", "~/code", "", "", "sftp://cool30/~/code"},
+		{"vscode://file{local}", "cool30", "/mnt/code/LM2", "", "/Volumes/cool30/LM2", "vscode://file/Volumes/cool30/LM2"},
+	}
+	for _, tt := range tests {
+		if got := URI(tt.template, tt.host, tt.dir, tt.path, tt.local); got != tt.want {
+			t.Errorf("URI(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+	if NeedsPath("sftp://{host}/{dir}") || !NeedsPath(Presets["vscode"]) {
+		t.Error("NeedsPath() is wrong")
+	}
+}
+
+func TestBuildLocal(t *testing.T) {
+	mappings := []pathmap.Mapping{{Local: "/Volumes/cool30", Remote: "/mnt/code"}}
+	got, err := Build("vscode://file{local}", "cool30", "/mnt/code/LM2", mappings)
+	if err != nil || got != "vscode://file/Volumes/cool30/LM2" {
+		t.Errorf("Build() = %q, %v", got, err)
+	}
+	if _, err := Build("vscode://file{local}", "cool30", "/data/LM2", mappings); err == nil {
+		t.Error("Build() of an unmapped directory = nil error, want error")
+	}
+}
+
+func TestBuildLocalHost(t *testing.T) {
+	got, err := Build(Presets["vscode"], "localhost", "/home/me/LM2", nil)
+	if err != nil || got != "vscode://file/home/me/LM2" {
+		t.Errorf("Build() on localhost = %q, %v; want a local folder URI", got, err)
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	if got, want := ResolveCommand("~/my project"), `cd "$HOME/my project" && pwd`; got != want {
+		t.Errorf("ResolveCommand() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+// Package overview summarizes what is going on right now, for
+// `remote-jobs ps`: the jobs that are running or recently finished, the
+// depths of each host's queues, and whether the hosts involved answer.
+package overview
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// DefaultQueue is the queue of jobs queued without a queue name
+const DefaultQueue = "default"
+
+// QueueDepth is how many jobs wait in one of a host's queues
+type QueueDepth struct {
+	Queue string
+	Jobs  int
+}
+
+// Host summarizes one host's jobs and reachability
+type Host struct {
+	Name         string
+	Active       int // Starting, running, or paused
+	Pending      int // Waiting to be started by hand
+	Queues       []QueueDepth
+	Reachability *db.HostReachability // nil if never checked
+	Latency      *db.HostLatency      // nil if never measured
+}
+
+// Queued returns how many jobs wait in the host's queues
+func (h Host) Queued() int {
+	n := 0
+	for _, q := range h.Queues {
+		n += q.Jobs
+	}
+	return n
+}
+
+// QueueSummary describes the host's queues, e.g. "3 (default 2, gpu 1)",
+// or "—" if they are empty
+func (h Host) QueueSummary() string {
+	switch len(h.Queues) {
+	case 0:
+		return "—"
+	case 1:
+		if h.Queues[0].Queue == DefaultQueue {
+			return fmt.Sprintf("%d", h.Queues[0].Jobs)
+		}
+	}
+	parts := make([]string, len(h.Queues))
+	for i, q := range h.Queues {
+		parts[i] = fmt.Sprintf("%s %d", q.Queue, q.Jobs)
+	}
+	return fmt.Sprintf("%d (%s)", h.Queued(), strings.Join(parts, ", "))
+}
+
+// Status describes whether the host answered when it was last tried, e.g.
+// "up, 42ms", "unreachable, retry in 2m", or "unreachable, checked 1h ago"
+func (h Host) Status(now time.Time) string {
+	r := h.Reachability
+	switch {
+	case r == nil:
+		return "not checked"
+	case r.Reachable:
+		if h.Latency != nil && h.Latency.Average > 0 {
+			return "up, " + humanfmt.Latency(h.Latency.Average)
+		}
+		return "up"
+	case r.RetryAt > now.Unix():
+		return "unreachable, retry in " + humanfmt.DurationShort(r.RetryAt-now.Unix())
+	default:
+		return "unreachable, checked " + humanfmt.Relative(now.Sub(time.Unix(r.CheckedAt, 0)))
+	}
+}
+
+// Overview is what `remote-jobs ps` shows
+type Overview struct {
+	Jobs  []*db.Job // Active jobs, oldest first, then finished ones, most recently ended first
+	Hosts []Host    // By name
+}
+
+// Build summarizes jobs, which are the unfinished and pending jobs and
+// those that finished recently, with the hosts' reachability and latency
+// by host. Queued and pending jobs are counted by host rather than listed.
+func Build(jobs []*db.Job, reachability map[string]*db.HostReachability, latencies map[string]*db.HostLatency) Overview {
+	var active, finished []*db.Job
+	hosts := make(map[string]*Host)
+	host := func(name string) *Host {
+		if hosts[name] == nil {
+			hosts[name] = &Host{Name: name, Reachability: reachability[name], Latency: latencies[name]}
+		}
+		return hosts[name]
+	}
+	queues := make(map[string]map[string]int)
+
+	for _, job := range jobs {
+		switch job.Status {
+		case db.StatusQueued:
+			queue := job.QueueName
+			if queue == "" {
+				queue = DefaultQueue
+			}
+			if queues[job.Host] == nil {
+				queues[job.Host] = make(map[string]int)
+			}
+			queues[job.Host][queue]++
+			host(job.Host)
+		case db.StatusPending:
+			host(job.Host).Pending++
+		case db.StatusStarting, db.StatusRunning, db.StatusPaused:
+			active = append(active, job)
+			host(job.Host).Active++
+		default:
+			finished = append(finished, job)
+			host(job.Host)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+	sort.SliceStable(finished, func(i, j int) bool { return endTime(finished[i]) > endTime(finished[j]) })
+
+	o := Overview{Jobs: append(active, finished...)}
+	for name, h := range hosts {
+		for queue, n := range queues[name] {
+			h.Queues = append(h.Queues, QueueDepth{Queue: queue, Jobs: n})
+		}
+		sort.Slice(h.Queues, func(i, j int) bool {
+			// The default queue first, then by name
+			a, b := h.Queues[i].Queue, h.Queues[j].Queue
+			return a == DefaultQueue || (b != DefaultQueue && a < b)
+		})
+		o.Hosts = append(o.Hosts, *h)
+	}
+	sort.Slice(o.Hosts, func(i, j int) bool { return o.Hosts[i].Name < o.Hosts[j].Name })
+	return o
+}
+
+func endTime(job *db.Job) int64 {
+	if job.EndTime != nil {
+		return *job.EndTime
+	}
+	return 0
+}
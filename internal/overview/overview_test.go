@@ -0,0 +1,92 @@
+package overview
+
+import (
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func ended(t int64) *int64 { return &t }
+
+func TestBuild(t *testing.T) {
+	jobs := []*db.Job{
+		{ID: 5, Host: "b", Status: db.StatusRunning},
+		{ID: 2, Host: "a", Status: db.StatusPaused},
+		{ID: 3, Host: "a", Status: db.StatusCompleted, EndTime: ended(100)},
+		{ID: 4, Host: "c", Status: db.StatusFailed, EndTime: ended(200)},
+		{ID: 6, Host: "a", Status: db.StatusQueued},
+		{ID: 7, Host: "a", Status: db.StatusQueued, QueueName: "gpu"},
+		{ID: 8, Host: "a", Status: db.StatusQueued, QueueName: "default"},
+		{ID: 9, Host: "d", Status: db.StatusPending},
+	}
+	reach := map[string]*db.HostReachability{"a": {Host: "a", Reachable: true}}
+	o := Build(jobs, reach, nil)
+
+	var ids []int64
+	for _, job := range o.Jobs {
+		ids = append(ids, job.ID)
+	}
+	want := []int64{2, 5, 4, 3}
+	if len(ids) != len(want) {
+		t.Fatalf("job ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("job ids = %v, want %v", ids, want)
+		}
+	}
+
+	if len(o.Hosts) != 4 {
+		t.Fatalf("got %d hosts, want 4", len(o.Hosts))
+	}
+	a := o.Hosts[0]
+	if a.Name != "a" || a.Active != 1 || a.Queued() != 3 || a.Reachability == nil {
+		t.Errorf("host a = %+v", a)
+	}
+	if got := a.QueueSummary(); got != "3 (default 2, gpu 1)" {
+		t.Errorf("QueueSummary() = %q", got)
+	}
+	if d := o.Hosts[3]; d.Name != "d" || d.Pending != 1 || d.QueueSummary() != "—" {
+		t.Errorf("host d = %+v", d)
+	}
+}
+
+func TestQueueSummary(t *testing.T) {
+	tests := []struct {
+		queues []QueueDepth
+		want   string
+	}{
+		{nil, "—"},
+		{[]QueueDepth{{DefaultQueue, 2}}, "2"},
+		{[]QueueDepth{{"gpu", 1}}, "1 (gpu 1)"},
+	}
+	for _, tt := range tests {
+		if got := (Host{Queues: tt.queues}).QueueSummary(); got != tt.want {
+			t.Errorf("QueueSummary(%v) = %q, want %q", tt.queues, got, tt.want)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name string
+		host Host
+		want string
+	}{
+		{"never tried", Host{}, "not checked"},
+		{"up", Host{Reachability: &db.HostReachability{Reachable: true}}, "up"},
+		{"up with latency", Host{
+			Reachability: &db.HostReachability{Reachable: true},
+			Latency:      &db.HostLatency{Average: 42 * time.Millisecond},
+		}, "up, 42ms"},
+		{"backing off", Host{Reachability: &db.HostReachability{Failures: 2, RetryAt: now.Unix() + 120}}, "unreachable, retry in 2m00s"},
+		{"backoff passed", Host{Reachability: &db.HostReachability{Failures: 2, CheckedAt: now.Unix() - 3600}}, "unreachable, checked 1h ago"},
+	}
+	for _, tt := range tests {
+		if got := tt.host.Status(now); got != tt.want {
+			t.Errorf("%s: Status() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package pathmap translates directories between the local machine and a
+// remote host whose layout differs from the local one.
+//
+// Without a mapping, a local directory under the home directory is assumed
+// to be at the same home-relative path on the host (see session.RemoteDir).
+// A mapping pairs a local directory with the remote directory that holds the
+// same files, e.g. /Users/me/code with /mnt/code, and applies to everything
+// below it.
+package pathmap
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Mapping pairs a local directory with its remote counterpart
+type Mapping struct {
+	Local  string `yaml:"local"`  // Local directory; a leading ~ is the local home directory
+	Remote string `yaml:"remote"` // Remote directory; may start with ~
+}
+
+// ExpandHome replaces a leading ~ in the mappings' local directories with home
+func ExpandHome(mappings []Mapping, home string) []Mapping {
+	expanded := make([]Mapping, len(mappings))
+	for i, m := range mappings {
+		if m.Local == "~" || strings.HasPrefix(m.Local, "~/") {
+			m.Local = filepath.Join(home, filepath.FromSlash(strings.TrimPrefix(m.Local[1:], "/")))
+		}
+		expanded[i] = m
+	}
+	return expanded
+}
+
+// ToRemote returns the remote directory for a local directory, using the
+// mapping with the longest local directory that contains it, and reports
+// whether one did
+func ToRemote(mappings []Mapping, dir string) (string, bool) {
+	best, bestLen := "", -1
+	for _, m := range mappings {
+		if m.Local == "" || m.Remote == "" {
+			continue
+		}
+		rel, err := filepath.Rel(m.Local, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(m.Local) > bestLen {
+			best, bestLen = joinRemote(m.Remote, filepath.ToSlash(rel)), len(m.Local)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// ToLocal returns the local directory for a remote directory, using the
+// mapping with the longest remote directory that contains it, and reports
+// whether one did
+func ToLocal(mappings []Mapping, dir string) (string, bool) {
+	best, bestLen := "", -1
+	for _, m := range mappings {
+		remote := strings.TrimSuffix(m.Remote, "/")
+		if m.Local == "" || remote == "" {
+			continue
+		}
+		var rest string
+		switch {
+		case dir == remote:
+		case strings.HasPrefix(dir, remote+"/"):
+			rest = dir[len(remote)+1:]
+		default:
+			continue
+		}
+		if len(remote) > bestLen {
+			best, bestLen = filepath.Join(m.Local, filepath.FromSlash(rest)), len(remote)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// joinRemote joins a remote directory and a relative slash-separated path
+func joinRemote(dir, rel string) string {
+	if rel == "." {
+		return dir
+	}
+	return path.Join(dir, rel)
+}
@@ -0,0 +1,61 @@
+package pathmap
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestToRemote(t *testing.T) {
+	mappings := []Mapping{
+		{Local: "/Users/me/code", Remote: "/mnt/code"},
+		{Local: "/Users/me/code/big", Remote: "/scratch/big"},
+		{Local: "/Users/me/notes", Remote: "~/notes"},
+	}
+	tests := []struct {
+		dir, want string
+		ok        bool
+	}{
+		{"/Users/me/code", "/mnt/code", true},
+		{"/Users/me/code/LM2/src", "/mnt/code/LM2/src", true},
+		{"/Users/me/code/big/data", "/scratch/big/data", true},
+		{"/Users/me/notes", "~/notes", true},
+		{"/Users/me/codebase", "", false},
+		{"/Users/me", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ToRemote(mappings, filepath.FromSlash(tt.dir))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ToRemote(%q) = %q, %v; want %q, %v", tt.dir, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestToLocal(t *testing.T) {
+	mappings := []Mapping{
+		{Local: "/Users/me/code", Remote: "/mnt/code/"},
+		{Local: "/Volumes/big", Remote: "/mnt/code/big"},
+	}
+	tests := []struct {
+		dir, want string
+		ok        bool
+	}{
+		{"/mnt/code", "/Users/me/code", true},
+		{"/mnt/code/LM2", "/Users/me/code/LM2", true},
+		{"/mnt/code/big/x", "/Volumes/big/x", true},
+		{"/mnt/codex", "", false},
+		{"~/code", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ToLocal(mappings, tt.dir)
+		if got != filepath.FromSlash(tt.want) || ok != tt.ok {
+			t.Errorf("ToLocal(%q) = %q, %v; want %q, %v", tt.dir, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	got := ExpandHome([]Mapping{{Local: "~/code", Remote: "/mnt/code"}, {Local: "/data", Remote: "/data"}}, "/Users/me")
+	if got[0].Local != filepath.FromSlash("/Users/me/code") || got[1].Local != "/data" {
+		t.Errorf("ExpandHome() = %+v", got)
+	}
+}
@@ -0,0 +1,327 @@
+// Package placement checks whether hosts can satisfy the resources a job
+// declares it needs, the host tags it requires or avoids, and the tool
+// versions it requires, and picks a host for jobs run on the "auto" host.
+package placement
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/hosttools"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// AutoHost is the host name that asks run to pick a host
+const AutoHost = "auto"
+
+// Needs are the resources a job declares it needs, as given to
+// `run --needs`. Zero fields are not needed.
+type Needs struct {
+	GPUs      int   // GPUs
+	VRAMMiB   int   // Memory on each of those GPUs
+	RAMBytes  int64 // Host memory
+	DiskBytes int64 // Free disk space
+}
+
+// IsZero reports whether no resources are needed
+func (n Needs) IsZero() bool {
+	return n == Needs{}
+}
+
+// ParseNeeds parses a comma-separated list of resources such as
+// "gpu:1,vram:40GiB,ram:64GiB,disk:200GiB". Sizes without a unit are in
+// bytes, except vram, which is in MiB as nvidia-smi reports it.
+func ParseNeeds(s string) (Needs, error) {
+	var n Needs
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			return n, fmt.Errorf("invalid resource %q (expected name:amount)", field)
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "gpu", "gpus":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 0 {
+				return n, fmt.Errorf("invalid GPU count %q", value)
+			}
+			n.GPUs = count
+		case "vram":
+			mib := humanfmt.ParseMiB(value)
+			if mib <= 0 {
+				return n, fmt.Errorf("invalid size %q for vram", value)
+			}
+			n.VRAMMiB = mib
+		case "ram", "mem", "memory":
+			size, ok := humanfmt.ParseSize(value, 1)
+			if !ok || size <= 0 {
+				return n, fmt.Errorf("invalid size %q for ram", value)
+			}
+			n.RAMBytes = size
+		case "disk":
+			size, ok := humanfmt.ParseSize(value, 1)
+			if !ok || size <= 0 {
+				return n, fmt.Errorf("invalid size %q for disk", value)
+			}
+			n.DiskBytes = size
+		default:
+			return n, fmt.Errorf("unknown resource %q (expected gpu, vram, ram, or disk)", key)
+		}
+	}
+	if n.VRAMMiB > 0 && n.GPUs == 0 {
+		n.GPUs = 1
+	}
+	return n, nil
+}
+
+// String formats needs as ParseNeeds parses them
+func (n Needs) String() string {
+	var parts []string
+	if n.GPUs > 0 {
+		parts = append(parts, "gpu:"+strconv.Itoa(n.GPUs))
+	}
+	if n.VRAMMiB > 0 {
+		parts = append(parts, "vram:"+strconv.Itoa(n.VRAMMiB)+"MiB")
+	}
+	if n.RAMBytes > 0 {
+		parts = append(parts, "ram:"+strconv.FormatInt(n.RAMBytes, 10)+"B")
+	}
+	if n.DiskBytes > 0 {
+		parts = append(parts, "disk:"+strconv.FormatInt(n.DiskBytes, 10)+"B")
+	}
+	return strings.Join(parts, ",")
+}
+
+// Describe formats needs for people, e.g. "1 GPU with 40 GiB, 64 GiB RAM"
+func (n Needs) Describe() string {
+	var parts []string
+	if n.GPUs > 0 {
+		gpus := strconv.Itoa(n.GPUs) + " GPU"
+		if n.GPUs > 1 {
+			gpus += "s"
+		}
+		if n.VRAMMiB > 0 {
+			gpus += " with " + humanfmt.MiB(n.VRAMMiB)
+		}
+		parts = append(parts, gpus)
+	}
+	if n.RAMBytes > 0 {
+		parts = append(parts, humanfmt.Bytes(n.RAMBytes)+" RAM")
+	}
+	if n.DiskBytes > 0 {
+		parts = append(parts, humanfmt.Bytes(n.DiskBytes)+" free disk")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Inventory is what a host has, as last recorded in the host info cache.
+// Unknown amounts are zero, and aren't checked.
+type Inventory struct {
+	Host           string
+	GPUMemoryMiB   []int // Total memory of each GPU, 0 where unknown
+	RAMBytes       int64
+	DiskAvailBytes int64
+	Tags           []string           // Capability tags, set in config and detected
+	Tools          hosttools.Versions // Tool versions, nil if they haven't been probed
+	Known          bool               // Whether the host has been seen at all
+}
+
+// Shortfalls returns how a host falls short of needs, or nil if it can
+// satisfy them as far as its inventory shows. Hosts that have never been
+// seen fall short of any needs.
+func (n Needs) Shortfalls(inv Inventory) []string {
+	if n.IsZero() {
+		return nil
+	}
+	if !inv.Known {
+		return []string{"no cached information about " + inv.Host + " (open its hosts view in the TUI to record it)"}
+	}
+
+	var short []string
+	if n.GPUs > 0 {
+		fitting := 0
+		for _, mib := range inv.GPUMemoryMiB {
+			if n.VRAMMiB == 0 || mib == 0 || mib >= n.VRAMMiB {
+				fitting++
+			}
+		}
+		switch {
+		case len(inv.GPUMemoryMiB) < n.GPUs:
+			short = append(short, fmt.Sprintf("needs %d GPU(s), has %d", n.GPUs, len(inv.GPUMemoryMiB)))
+		case fitting < n.GPUs:
+			short = append(short, fmt.Sprintf("needs %d GPU(s) with %s, has %d", n.GPUs, humanfmt.MiB(n.VRAMMiB), fitting))
+		}
+	}
+	if n.RAMBytes > 0 && inv.RAMBytes > 0 && inv.RAMBytes < n.RAMBytes {
+		short = append(short, fmt.Sprintf("needs %s RAM, has %s", humanfmt.Bytes(n.RAMBytes), humanfmt.Bytes(inv.RAMBytes)))
+	}
+	if n.DiskBytes > 0 && inv.DiskAvailBytes > 0 && inv.DiskAvailBytes < n.DiskBytes {
+		short = append(short, fmt.Sprintf("needs %s free disk, has %s", humanfmt.Bytes(n.DiskBytes), humanfmt.Bytes(inv.DiskAvailBytes)))
+	}
+	return short
+}
+
+// Request is what a job asks of its host: resources, host tags it requires
+// or avoids, and tool versions it requires
+type Request struct {
+	Needs   Needs
+	Require []string // Tags the host must have, and tool requirements such as python>=3.10
+	Avoid   []string // Tags the host must not have
+}
+
+// Validate checks the tool requirements among the required tags
+func (r Request) Validate() error {
+	for _, s := range r.Require {
+		if hosttools.IsRequirement(s) {
+			if _, err := hosttools.ParseRequirement(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ToolRequirements returns the tool requirements among the required tags
+func (r Request) ToolRequirements() []hosttools.Requirement {
+	var reqs []hosttools.Requirement
+	for _, s := range r.Require {
+		if !hosttools.IsRequirement(s) {
+			continue
+		}
+		if req, err := hosttools.ParseRequirement(s); err == nil {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// Violations returns the ways a host's tags break a request's constraints
+func (r Request) Violations(tags []string) []string {
+	var v []string
+	for _, tag := range r.Require {
+		if hosttools.IsRequirement(tag) {
+			continue // See ToolShortfalls
+		}
+		if !slices.Contains(tags, tag) {
+			v = append(v, "lacks required tag "+tag)
+		}
+	}
+	for _, tag := range r.Avoid {
+		if slices.Contains(tags, tag) {
+			v = append(v, "has avoided tag "+tag)
+		}
+	}
+	return v
+}
+
+// ToolShortfalls returns the ways a host's tools break a request's tool
+// requirements. Hosts whose tools haven't been probed break them all.
+func (r Request) ToolShortfalls(inv Inventory) []string {
+	reqs := r.ToolRequirements()
+	if len(reqs) == 0 {
+		return nil
+	}
+	if inv.Tools == nil {
+		return []string{"no recorded tool versions for " + inv.Host + " (it couldn't be reached to probe them)"}
+	}
+	var short []string
+	for _, req := range reqs {
+		if problem := req.Check(inv.Tools); problem != "" {
+			short = append(short, problem)
+		}
+	}
+	return short
+}
+
+// Problems returns why a host can't run a request: tags it breaks, tools
+// it lacks, then resources it falls short of
+func (r Request) Problems(inv Inventory) []string {
+	problems := append(r.Violations(inv.Tags), r.ToolShortfalls(inv)...)
+	return append(problems, r.Needs.Shortfalls(inv)...)
+}
+
+// Candidate is a host that a job could be placed on
+type Candidate struct {
+	Inventory
+	RunningJobs int       // Jobs now running on the host
+	Unreachable bool      // Whether the host was unreachable when last tried
+	OpensAt     time.Time // When the host's availability window opens, if it is closed now
+}
+
+// Choose picks the host for a request: among reachable hosts that satisfy
+// it, the one running the fewest jobs, and of those the first by name.
+// Hosts outside their availability windows are only chosen if no other host
+// fits, the one that opens first. If none fits, the error says why each host
+// doesn't.
+func Choose(r Request, candidates []Candidate) (string, error) {
+	var fitting []Candidate
+	var reasons []string
+	for _, c := range candidates {
+		if c.Unreachable {
+			reasons = append(reasons, c.Host+": unreachable")
+			continue
+		}
+		if short := r.Problems(c.Inventory); len(short) > 0 {
+			reasons = append(reasons, c.Host+": "+strings.Join(short, "; "))
+			continue
+		}
+		fitting = append(fitting, c)
+	}
+	if len(fitting) == 0 {
+		if len(reasons) == 0 {
+			return "", fmt.Errorf("no known hosts to place the job on")
+		}
+		return "", fmt.Errorf("no host can run the job:\n  %s", strings.Join(reasons, "\n  "))
+	}
+	sort.SliceStable(fitting, func(i, j int) bool {
+		if !fitting[i].OpensAt.Equal(fitting[j].OpensAt) {
+			return fitting[i].OpensAt.Before(fitting[j].OpensAt)
+		}
+		if fitting[i].RunningJobs != fitting[j].RunningJobs {
+			return fitting[i].RunningJobs < fitting[j].RunningJobs
+		}
+		return fitting[i].Host < fitting[j].Host
+	})
+	return fitting[0].Host, nil
+}
+
+// gpuModel matches the model in an NVIDIA GPU name, such as the A100 of
+// "NVIDIA A100-SXM4-80GB" or the RTX 4090 of "NVIDIA GeForce RTX 4090"
+var gpuModel = regexp.MustCompile(`(?i)\b((?:rtx|gtx)\s?[a-z]?\d{3,4}|[a-z]\d{1,3}[a-z]?)\b`)
+
+// DetectTags derives capability tags from what the host info cache records:
+// the OS and architecture from the arch string (e.g. "Linux x86_64"), "gpu"
+// if there are GPUs, and the lowercased model of each NVIDIA GPU, such as
+// a100 or rtx4090
+func DetectTags(arch string, gpuNames []string) []string {
+	var tags []string
+	add := func(tag string) {
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	for _, field := range strings.Fields(strings.ToLower(arch)) {
+		add(field)
+	}
+	if len(gpuNames) > 0 {
+		add("gpu")
+	}
+	for _, name := range gpuNames {
+		if !strings.Contains(strings.ToLower(name), "nvidia") && !strings.Contains(strings.ToLower(name), "tesla") {
+			continue
+		}
+		if m := gpuModel.FindString(name); m != "" {
+			add(strings.ToLower(strings.ReplaceAll(m, " ", "")))
+		}
+	}
+	return tags
+}
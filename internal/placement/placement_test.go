@@ -0,0 +1,202 @@
+package placement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/hosttools"
+)
+
+func TestParseNeeds(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Needs
+		wantErr bool
+	}{
+		{"", Needs{}, false},
+		{"gpu:1,vram:40GiB,ram:64GiB,disk:200GiB", Needs{GPUs: 1, VRAMMiB: 40 << 10, RAMBytes: 64 << 30, DiskBytes: 200 << 30}, false},
+		{"gpus:2, ram:16G", Needs{GPUs: 2, RAMBytes: 16 << 30}, false},
+		{"vram:24000", Needs{GPUs: 1, VRAMMiB: 24000}, false},
+		{"gpu:two", Needs{}, true},
+		{"ram", Needs{}, true},
+		{"cpu:4", Needs{}, true},
+		{"disk:lots", Needs{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseNeeds(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNeeds(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseNeeds(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			again, err := ParseNeeds(got.String())
+			if err != nil || again != got {
+				t.Errorf("ParseNeeds(%q) = %+v, %v; want it to round-trip %+v", got.String(), again, err, got)
+			}
+		})
+	}
+}
+
+func TestShortfalls(t *testing.T) {
+	a100s := Inventory{Host: "big", Known: true, GPUMemoryMiB: []int{81920, 81920}, RAMBytes: 256 << 30, DiskAvailBytes: 500 << 30}
+	t4 := Inventory{Host: "small", Known: true, GPUMemoryMiB: []int{15360}, RAMBytes: 16 << 30}
+
+	tests := []struct {
+		name  string
+		needs Needs
+		inv   Inventory
+		want  []string
+	}{
+		{"fits", Needs{GPUs: 2, VRAMMiB: 40 << 10, RAMBytes: 64 << 30}, a100s, nil},
+		{"no needs on unknown host", Needs{}, Inventory{Host: "new"}, nil},
+		{"unknown host", Needs{GPUs: 1}, Inventory{Host: "new"}, []string{"no cached information"}},
+		{"too few GPUs", Needs{GPUs: 2}, t4, []string{"needs 2 GPU(s), has 1"}},
+		{"GPU too small", Needs{GPUs: 1, VRAMMiB: 40 << 10}, t4, []string{"with 40"}},
+		{"too little RAM", Needs{RAMBytes: 64 << 30}, t4, []string{"RAM"}},
+		{"disk unknown", Needs{DiskBytes: 1 << 40}, t4, nil},
+		{"too little disk", Needs{DiskBytes: 1 << 40}, a100s, []string{"free disk"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.needs.Shortfalls(tt.inv)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Shortfalls() = %q, want %d matching %q", got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				if !strings.Contains(got[i], tt.want[i]) {
+					t.Errorf("Shortfalls()[%d] = %q, want it to contain %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChoose(t *testing.T) {
+	gpu := func(host string, running int, unreachable bool) Candidate {
+		return Candidate{
+			Inventory:   Inventory{Host: host, Known: true, GPUMemoryMiB: []int{81920}},
+			RunningJobs: running,
+			Unreachable: unreachable,
+		}
+	}
+	cpuOnly := Candidate{Inventory: Inventory{Host: "cpu", Known: true}}
+
+	got, err := Choose(Request{Needs: Needs{GPUs: 1}}, []Candidate{cpuOnly, gpu("b", 1, false), gpu("c", 0, true), gpu("a", 1, false)})
+	if err != nil || got != "a" {
+		t.Errorf("Choose() = %q, %v; want a (fits, idlest reachable, then by name)", got, err)
+	}
+
+	got, err = Choose(Request{}, []Candidate{gpu("b", 2, false), cpuOnly})
+	if err != nil || got != "cpu" {
+		t.Errorf("Choose() with no needs = %q, %v; want cpu (fewest running jobs)", got, err)
+	}
+
+	_, err = Choose(Request{Needs: Needs{GPUs: 1}}, []Candidate{cpuOnly, gpu("c", 0, true)})
+	if err == nil || !strings.Contains(err.Error(), "cpu: needs 1 GPU") || !strings.Contains(err.Error(), "c: unreachable") {
+		t.Errorf("Choose() error = %v, want the reason for each host", err)
+	}
+}
+
+func TestChooseWithTags(t *testing.T) {
+	hosts := []Candidate{
+		{Inventory: Inventory{Host: "a", Known: true, Tags: []string{"gpu", "a100", "slow-disk"}}},
+		{Inventory: Inventory{Host: "b", Known: true, Tags: []string{"gpu", "a100"}}, RunningJobs: 3},
+		{Inventory: Inventory{Host: "c", Known: true, Tags: []string{"gpu", "t4"}}},
+	}
+
+	got, err := Choose(Request{Require: []string{"a100"}, Avoid: []string{"slow-disk"}}, hosts)
+	if err != nil || got != "b" {
+		t.Errorf("Choose() = %q, %v; want b", got, err)
+	}
+
+	_, err = Choose(Request{Require: []string{"h100"}}, hosts)
+	if err == nil || !strings.Contains(err.Error(), "lacks required tag h100") {
+		t.Errorf("Choose() error = %v, want it to name the missing tag", err)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	r := Request{Require: []string{"a100", "infiniband"}, Avoid: []string{"slow-disk"}}
+	got := r.Violations([]string{"a100", "slow-disk"})
+	want := []string{"lacks required tag infiniband", "has avoided tag slow-disk"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Violations() = %q, want %q", got, want)
+	}
+	if got := r.Violations([]string{"a100", "infiniband"}); len(got) != 0 {
+		t.Errorf("Violations() = %q, want none", got)
+	}
+}
+
+func TestChooseWithTools(t *testing.T) {
+	hosts := []Candidate{
+		{Inventory: Inventory{Host: "a", Known: true, Tools: hosttools.Versions{"python": "3.8.10", "git": "2.25.1"}}},
+		{Inventory: Inventory{Host: "b", Known: true, Tools: hosttools.Versions{"python": "3.11.4"}}, RunningJobs: 2},
+		{Inventory: Inventory{Host: "c", Known: true}},
+	}
+	r := Request{Require: []string{"python>=3.10"}}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Choose(r, hosts)
+	if err != nil || got != "b" {
+		t.Errorf("Choose() = %q, %v; want b", got, err)
+	}
+
+	_, err = Choose(Request{Require: []string{"python>=3.12"}}, hosts)
+	for _, reason := range []string{"a: has python 3.8.10 (requires python>=3.12)", "c: no recorded tool versions"} {
+		if err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("Choose() error = %v, want %q", err, reason)
+		}
+	}
+
+	if err := (Request{Require: []string{"perl>=5"}}).Validate(); err == nil {
+		t.Error("Validate() should reject an unknown tool")
+	}
+}
+
+func TestDetectTags(t *testing.T) {
+	tests := []struct {
+		arch string
+		gpus []string
+		want string
+	}{
+		{"Linux x86_64", []string{"NVIDIA A100-SXM4-80GB", "NVIDIA A100-SXM4-80GB"}, "linux x86_64 gpu a100"},
+		{"Linux x86_64", []string{"NVIDIA GeForce RTX 4090"}, "linux x86_64 gpu rtx4090"},
+		{"Linux aarch64", []string{"NVIDIA H100 80GB HBM3", "Tesla T4"}, "linux aarch64 gpu h100 t4"},
+		{"Linux x86_64", []string{"NVIDIA L40S"}, "linux x86_64 gpu l40s"},
+		{"Darwin arm64", []string{"Apple M1 (8 cores)"}, "darwin arm64 gpu"},
+		{"", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := strings.Join(DetectTags(tt.arch, tt.gpus), " "); got != tt.want {
+				t.Errorf("DetectTags(%q, %q) = %q, want %q", tt.arch, tt.gpus, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseOutsideAvailability(t *testing.T) {
+	now := time.Date(2026, 10, 12, 14, 0, 0, 0, time.UTC)
+	hosts := []Candidate{
+		{Inventory: Inventory{Host: "office1", Known: true}, OpensAt: now.Add(6 * time.Hour)},
+		{Inventory: Inventory{Host: "office2", Known: true}, OpensAt: now.Add(2 * time.Hour)},
+		{Inventory: Inventory{Host: "server", Known: true}, RunningJobs: 5},
+	}
+
+	if got, err := Choose(Request{}, hosts); err != nil || got != "server" {
+		t.Errorf("Choose() = %q, %v; want server (available now, however busy)", got, err)
+	}
+	if got, err := Choose(Request{}, hosts[:2]); err != nil || got != "office2" {
+		t.Errorf("Choose() = %q, %v; want office2 (opens first)", got, err)
+	}
+}
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"errors"
 	"fmt"
 
 	"gopkg.in/yaml.v3"
@@ -8,9 +9,10 @@ import (
 
 // File represents a parsed job plan file
 type File struct {
-	Version int64   `yaml:"version"`
-	Kill    []int64 `yaml:"kill"`
-	Jobs    []Entry `yaml:"jobs"`
+	Version int64             `yaml:"version"`
+	Vars    map[string]string `yaml:"vars"`
+	Kill    []int64           `yaml:"kill"`
+	Jobs    []Entry           `yaml:"jobs"`
 }
 
 // Defaults contains values that can be applied to a parsed plan.
@@ -23,6 +25,7 @@ type Entry struct {
 	Job      *Job      `yaml:"job"`
 	Parallel *Parallel `yaml:"parallel"`
 	Series   *Series   `yaml:"series"`
+	pos      Position
 }
 
 // Job represents a single job specification
@@ -36,24 +39,29 @@ type Job struct {
 	Queue       string            `yaml:"queue"`
 	QueueOnly   bool              `yaml:"queue_only"`
 	When        *When             `yaml:"when"`
+	pos         Position
 }
 
 // Parallel represents a block of jobs that can start at the same time
 type Parallel struct {
-	Name string            `yaml:"name"`
-	Dir  string            `yaml:"dir"`
-	Env  map[string]string `yaml:"env"`
-	Jobs []Job             `yaml:"jobs"`
+	Name   string            `yaml:"name"`
+	Dir    string            `yaml:"dir"`
+	Env    map[string]string `yaml:"env"`
+	Matrix Matrix            `yaml:"matrix"`
+	Jobs   []Job             `yaml:"jobs"`
+	pos    Position
 }
 
 // Series represents a block of jobs that should run sequentially
 type Series struct {
-	Name  string            `yaml:"name"`
-	Dir   string            `yaml:"dir"`
-	Env   map[string]string `yaml:"env"`
-	Queue string            `yaml:"queue"`
-	Wait  string            `yaml:"wait"`
-	Jobs  []Job             `yaml:"jobs"`
+	Name   string            `yaml:"name"`
+	Dir    string            `yaml:"dir"`
+	Env    map[string]string `yaml:"env"`
+	Queue  string            `yaml:"queue"`
+	Wait   string            `yaml:"wait"`
+	Matrix Matrix            `yaml:"matrix"`
+	Jobs   []Job             `yaml:"jobs"`
+	pos    Position
 }
 
 // When represents the reserved future syntax for resource triggers
@@ -70,157 +78,219 @@ type WhenGPU struct {
 	MemoryFreeGB *float64 `yaml:"memory_free_gb"`
 }
 
-// Decode parses the YAML data into a plan File
+// Decode parses the YAML data into a plan File. It reports every key that
+// isn't part of the schema and every value of the wrong type, by line and
+// column, rather than only the first.
 func Decode(data []byte) (*File, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("the plan is empty")
+	}
+	root := doc.Content[0]
+	var p problems
+	checkFile(root, &p)
+	if err := p.err(); err != nil {
+		return nil, err
+	}
 	var f File
-	if err := yaml.Unmarshal(data, &f); err != nil {
+	if err := root.Decode(&f); err != nil {
 		return nil, err
 	}
+	f.setPositions(root)
 	return &f, nil
 }
 
-// Validate ensures the plan file contains supported constructs
+// Validate ensures the plan file contains supported constructs, reporting
+// every problem
 func (f *File) Validate() error {
+	var p problems
 	if f.Version != 1 {
 		if f.Version == 0 {
-			return fmt.Errorf("plan file missing required version: set version: 1")
+			p.add(Position{}, "", "plan file missing required version: set version: 1")
+		} else {
+			p.add(Position{}, "version", "unsupported plan version %d", f.Version)
 		}
-		return fmt.Errorf("unsupported plan version %d", f.Version)
 	}
 	if len(f.Jobs) == 0 {
-		return fmt.Errorf("plan must contain at least one job entry")
+		p.add(Position{}, "", "plan must contain at least one job entry")
 	}
-	for i, entry := range f.Jobs {
-		if err := entry.validate(fmt.Sprintf("jobs[%d]", i)); err != nil {
-			return err
+	for _, id := range f.Kill {
+		if id <= 0 {
+			p.add(Position{}, "kill", "invalid job ID %d", id)
 		}
 	}
-	return nil
+	for i, entry := range f.Jobs {
+		entry.validate(fmt.Sprintf("jobs[%d]", i), &p)
+	}
+	return p.err()
 }
 
-// ApplyDefaults fills in missing values such as host names.
+// ApplyDefaults fills in missing values such as host names, reporting every
+// job that still has no host
 func (f *File) ApplyDefaults(defaults Defaults) error {
+	var p problems
 	for i := range f.Jobs {
-		path := fmt.Sprintf("jobs[%d]", i)
-		if err := f.Jobs[i].applyDefaults(defaults, path); err != nil {
-			return err
+		f.Jobs[i].applyDefaults(defaults, fmt.Sprintf("jobs[%d]", i), &p)
+	}
+	return p.err()
+}
+
+// Count returns how many jobs the plan starts now and how many it queues
+func (f *File) Count() (started, queued int) {
+	for _, entry := range f.Jobs {
+		switch {
+		case entry.Job != nil:
+			if entry.Job.QueueOnly {
+				queued++
+			} else {
+				started++
+			}
+		case entry.Parallel != nil:
+			for _, job := range entry.Parallel.Jobs {
+				if job.QueueOnly {
+					queued++
+				} else {
+					started++
+				}
+			}
+		case entry.Series != nil:
+			queued += len(entry.Series.Jobs)
 		}
 	}
-	return nil
+	return started, queued
 }
 
-func (e *Entry) validate(path string) error {
+func (e *Entry) validate(path string, p *problems) {
 	count := 0
 	if e.Job != nil {
 		count++
-		if err := e.Job.validate(path + ".job"); err != nil {
-			return err
-		}
+		e.Job.validate(path+".job", p)
 	}
 	if e.Parallel != nil {
 		count++
-		if err := e.Parallel.validate(path + ".parallel"); err != nil {
-			return err
-		}
+		e.Parallel.validate(path+".parallel", p)
 	}
 	if e.Series != nil {
 		count++
-		if err := e.Series.validate(path + ".series"); err != nil {
-			return err
-		}
+		e.Series.validate(path+".series", p)
 	}
 	if count == 0 {
-		return fmt.Errorf("%s must contain job, parallel, or series", path)
+		p.add(e.pos, path, "must contain job, parallel, or series")
 	}
 	if count > 1 {
-		return fmt.Errorf("%s cannot contain more than one of job/parallel/series", path)
+		p.add(e.pos, path, "cannot contain more than one of job/parallel/series")
 	}
-	return nil
 }
 
-func (e *Entry) applyDefaults(defaults Defaults, path string) error {
+func (e *Entry) applyDefaults(defaults Defaults, path string, p *problems) {
 	if e.Job != nil {
-		return e.Job.applyDefaults(defaults, path+".job")
+		e.Job.applyDefaults(defaults, path+".job", p)
 	}
 	if e.Parallel != nil {
-		return e.Parallel.applyDefaults(defaults, path+".parallel")
+		applyJobDefaults(e.Parallel.Jobs, defaults, path+".parallel", p)
 	}
 	if e.Series != nil {
-		return e.Series.applyDefaults(defaults, path+".series")
+		applyJobDefaults(e.Series.Jobs, defaults, path+".series", p)
 	}
-	return nil
 }
 
-func (j *Job) validate(path string) error {
+const missingHost = "missing host (provide --host or set host in the plan)"
+
+func (j *Job) validate(path string, p *problems) {
 	if j.Command == "" {
-		return fmt.Errorf("%s missing command", path)
+		p.add(j.pos, path, "missing command")
 	}
 	if j.Host == "" {
-		return fmt.Errorf("%s missing host", path)
+		p.add(j.pos, path, missingHost)
 	}
 	if j.When != nil {
-		return fmt.Errorf("%s: the when block is not supported in this version", path)
+		p.add(j.pos, path, "the when block is not supported in this version")
 	}
-	return nil
 }
 
-func (j *Job) applyDefaults(defaults Defaults, path string) error {
+func (j *Job) applyDefaults(defaults Defaults, path string, p *problems) {
 	if j.Host == "" {
 		if defaults.Host == "" {
-			return fmt.Errorf("%s.host missing (provide --host or set host in the plan)", path)
+			p.add(j.pos, path, missingHost)
+			return
 		}
 		j.Host = defaults.Host
 	}
-	return nil
 }
 
-func (p *Parallel) validate(path string) error {
-	if len(p.Jobs) == 0 {
-		return fmt.Errorf("%s must contain at least one job", path)
+func applyJobDefaults(jobs []Job, defaults Defaults, path string, p *problems) {
+	for i := range jobs {
+		jobs[i].applyDefaults(defaults, fmt.Sprintf("%s.jobs[%d]", path, i), p)
 	}
-	for i := range p.Jobs {
-		jobPath := fmt.Sprintf("%s.jobs[%d]", path, i)
-		if err := p.Jobs[i].validate(jobPath); err != nil {
-			return err
-		}
+}
+
+func validateJobs(jobs []Job, path string, p *problems) {
+	for i := range jobs {
+		jobs[i].validate(fmt.Sprintf("%s.jobs[%d]", path, i), p)
 	}
-	return nil
 }
 
-func (p *Parallel) applyDefaults(defaults Defaults, path string) error {
-	for i := range p.Jobs {
-		jobPath := fmt.Sprintf("%s.jobs[%d]", path, i)
-		if err := p.Jobs[i].applyDefaults(defaults, jobPath); err != nil {
-			return err
-		}
+func (b *Parallel) validate(path string, p *problems) {
+	if len(b.Jobs) == 0 {
+		p.add(b.pos, path, "must contain at least one job")
 	}
-	return nil
+	validateJobs(b.Jobs, path, p)
 }
 
-func (s *Series) validate(path string) error {
+func (s *Series) validate(path string, p *problems) {
 	if len(s.Jobs) == 0 {
-		return fmt.Errorf("%s must contain at least one job", path)
-	}
-	for i := range s.Jobs {
-		jobPath := fmt.Sprintf("%s.jobs[%d]", path, i)
-		if err := s.Jobs[i].validate(jobPath); err != nil {
-			return err
-		}
+		p.add(s.pos, path, "must contain at least one job")
 	}
+	validateJobs(s.Jobs, path, p)
 	switch s.Wait {
 	case "", "success", "any":
 	default:
-		return fmt.Errorf("%s.wait must be 'success' or 'any'", path)
+		p.add(s.pos, path+".wait", "must be 'success' or 'any'")
+	}
+	// The queue runner can only wait for jobs on its own host
+	for i, job := range s.Jobs {
+		if job.Host != "" && s.Jobs[0].Host != "" && job.Host != s.Jobs[0].Host {
+			p.add(job.pos, fmt.Sprintf("%s.jobs[%d]", path, i),
+				"series block jobs must target the same host (found %s and %s)", s.Jobs[0].Host, job.Host)
+			break
+		}
 	}
-	return nil
 }
 
-func (s *Series) applyDefaults(defaults Defaults, path string) error {
-	for i := range s.Jobs {
-		jobPath := fmt.Sprintf("%s.jobs[%d]", path, i)
-		if err := s.Jobs[i].applyDefaults(defaults, jobPath); err != nil {
-			return err
-		}
+// Position is a line and column of a plan file, or zero if unknown
+type Position struct {
+	Line, Column int
+}
+
+// Error is a problem with a plan, at the position in its file of the value
+// it concerns
+type Error struct {
+	Position
+	Path string // The value, e.g. jobs[1].parallel.jobs[0]; "" for the plan itself
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	msg := e.Msg
+	if e.Path != "" {
+		msg = e.Path + ": " + msg
 	}
-	return nil
+	if e.Line > 0 {
+		msg = fmt.Sprintf("%d:%d: %s", e.Line, e.Column, msg)
+	}
+	return msg
+}
+
+// problems collects a plan's errors, so that all of them can be reported
+type problems []error
+
+func (p *problems) add(pos Position, path, format string, args ...any) {
+	*p = append(*p, &Error{Position: pos, Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p problems) err() error {
+	return errors.Join(p...)
 }
@@ -0,0 +1,325 @@
+package plan
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// check checks that a YAML value fits the schema, adding problems for the
+// parts that don't
+type check func(n *yaml.Node, path string, p *problems)
+
+// fields are the keys an object may have, and the checks of their values
+type fields map[string]check
+
+var jobFields = fields{
+	"name":        scalar,
+	"host":        scalar,
+	"dir":         scalar,
+	"command":     scalar,
+	"description": scalar,
+	"env":         stringMap,
+	"queue":       scalar,
+	"queue_only":  boolean,
+	"when":        object(whenFields),
+}
+
+var whenFields = fields{
+	"cpu_below":   number,
+	"ram_free_gb": number,
+	"gpu": object(fields{
+		"device":         scalar,
+		"util_below":     number,
+		"memory_free_gb": number,
+	}),
+}
+
+var parallelFields = fields{
+	"name":   scalar,
+	"dir":    scalar,
+	"env":    stringMap,
+	"matrix": matrix,
+	"jobs":   listOf(object(jobFields)),
+}
+
+var seriesFields = fields{
+	"name":   scalar,
+	"dir":    scalar,
+	"env":    stringMap,
+	"queue":  scalar,
+	"wait":   oneOf("success", "any"),
+	"matrix": matrix,
+	"jobs":   listOf(object(jobFields)),
+}
+
+var entryFields = fields{
+	"job":      object(jobFields),
+	"parallel": object(parallelFields),
+	"series":   object(seriesFields),
+}
+
+var fileFields = fields{
+	"version": integer,
+	"vars":    stringMap,
+	"kill":    listOf(integer),
+	"jobs":    listOf(object(entryFields)),
+}
+
+// checkFile checks a plan's YAML against the schema
+func checkFile(root *yaml.Node, p *problems) {
+	object(fileFields)(root, "", p)
+}
+
+func positionOf(n *yaml.Node) Position {
+	return Position{Line: n.Line, Column: n.Column}
+}
+
+func resolve(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		return n.Alias
+	}
+	return n
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
+
+// describe names the kind of a YAML value for error messages
+func describe(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	switch n.Tag {
+	case "!!int", "!!float":
+		return "the number " + n.Value
+	case "!!bool":
+		return "the boolean " + n.Value
+	default:
+		return fmt.Sprintf("%q", n.Value)
+	}
+}
+
+func scalar(n *yaml.Node, path string, p *problems) {
+	n = resolve(n)
+	if n.Kind != yaml.ScalarNode {
+		p.add(positionOf(n), path, "want a string, got %s", describe(n))
+	}
+}
+
+func integer(n *yaml.Node, path string, p *problems) {
+	n = resolve(n)
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!int" {
+		p.add(positionOf(n), path, "want an integer, got %s", describe(n))
+	}
+}
+
+func number(n *yaml.Node, path string, p *problems) {
+	n = resolve(n)
+	if n.Kind != yaml.ScalarNode || (n.Tag != "!!int" && n.Tag != "!!float") {
+		p.add(positionOf(n), path, "want a number, got %s", describe(n))
+	}
+}
+
+func boolean(n *yaml.Node, path string, p *problems) {
+	n = resolve(n)
+	if isNull(n) {
+		return
+	}
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!bool" {
+		p.add(positionOf(n), path, "want true or false, got %s", describe(n))
+	}
+}
+
+func oneOf(values ...string) check {
+	return func(n *yaml.Node, path string, p *problems) {
+		n = resolve(n)
+		if isNull(n) {
+			return
+		}
+		if n.Kind != yaml.ScalarNode || !slices.Contains(values, n.Value) {
+			p.add(positionOf(n), path, "want %s, got %s", quoteList(values), describe(n))
+		}
+	}
+}
+
+// stringMap checks a mapping of names to single values, such as env
+func stringMap(n *yaml.Node, path string, p *problems) {
+	n = resolve(n)
+	if isNull(n) {
+		return
+	}
+	if n.Kind != yaml.MappingNode {
+		p.add(positionOf(n), path, "want a mapping of names to values, got %s", describe(n))
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		scalar(n.Content[i+1], path+"."+n.Content[i].Value, p)
+	}
+}
+
+// matrix checks a mapping of variable names to lists of values
+func matrix(n *yaml.Node, path string, p *problems) {
+	n = resolve(n)
+	if isNull(n) {
+		return
+	}
+	if n.Kind != yaml.MappingNode {
+		p.add(positionOf(n), path, "want a mapping of variable names to lists of values, got %s", describe(n))
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		listOf(scalar)(n.Content[i+1], path+"."+n.Content[i].Value, p)
+	}
+}
+
+func listOf(item check) check {
+	return func(n *yaml.Node, path string, p *problems) {
+		n = resolve(n)
+		if isNull(n) {
+			return
+		}
+		if n.Kind != yaml.SequenceNode {
+			p.add(positionOf(n), path, "want a list, got %s", describe(n))
+			return
+		}
+		for i, c := range n.Content {
+			item(c, fmt.Sprintf("%s[%d]", path, i), p)
+		}
+	}
+}
+
+func object(fs fields) check {
+	return func(n *yaml.Node, path string, p *problems) {
+		n = resolve(n)
+		if isNull(n) {
+			return
+		}
+		if n.Kind != yaml.MappingNode {
+			p.add(positionOf(n), path, "want a mapping, got %s", describe(n))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "<<" {
+				continue // A merged anchor; its keys are checked where it is defined
+			}
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			c, ok := fs[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", key.Value)
+				if s := suggest(key.Value, fs); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				p.add(positionOf(key), path, "%s", msg)
+				continue
+			}
+			c(value, keyPath, p)
+		}
+	}
+}
+
+// suggest returns the key that name is most likely a misspelling of, or ""
+func suggest(name string, fs fields) string {
+	best, bestDistance := "", 3
+	for key := range fs {
+		if d := editDistance(name, key); d < bestDistance || (d == bestDistance && key < best) {
+			best, bestDistance = key, d
+		}
+	}
+	if bestDistance > 2 {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, " or ")
+}
+
+// setPositions records where the plan's entries, blocks, and jobs are in
+// its file, for the errors of Validate and ApplyDefaults
+func (f *File) setPositions(root *yaml.Node) {
+	entries := mappingValue(root, "jobs")
+	if entries == nil || entries.Kind != yaml.SequenceNode {
+		return
+	}
+	for i, n := range entries.Content {
+		if i >= len(f.Jobs) {
+			break
+		}
+		n = resolve(n)
+		entry := &f.Jobs[i]
+		entry.pos = positionOf(n)
+		if v := mappingValue(n, "job"); v != nil && entry.Job != nil {
+			entry.Job.pos = positionOf(v)
+		}
+		if v := mappingValue(n, "parallel"); v != nil && entry.Parallel != nil {
+			entry.Parallel.pos = positionOf(v)
+			setJobPositions(entry.Parallel.Jobs, mappingValue(v, "jobs"))
+		}
+		if v := mappingValue(n, "series"); v != nil && entry.Series != nil {
+			entry.Series.pos = positionOf(v)
+			setJobPositions(entry.Series.Jobs, mappingValue(v, "jobs"))
+		}
+	}
+}
+
+func setJobPositions(jobs []Job, list *yaml.Node) {
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return
+	}
+	for i, n := range list.Content {
+		if i < len(jobs) {
+			jobs[i].pos = positionOf(resolve(n))
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping, or nil
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	n = resolve(n)
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return resolve(n.Content[i+1])
+		}
+	}
+	return nil
+}
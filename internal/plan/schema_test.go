@@ -0,0 +1,157 @@
+package plan
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// planErrors returns the plan errors in err
+func planErrors(t *testing.T, err error) []*Error {
+	t.Helper()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []*Error
+		for _, e := range joined.Unwrap() {
+			out = append(out, planErrors(t, e)...)
+		}
+		return out
+	}
+	var pe *Error
+	if !errors.As(err, &pe) {
+		t.Fatalf("%v is not a plan error", err)
+	}
+	return []*Error{pe}
+}
+
+func TestDecodeReportsSchemaErrors(t *testing.T) {
+	data := `version: 1
+kill: [x]
+jobs:
+  - job:
+      comand: python train.py
+      host: cool42
+      queue_only: maybe
+  - parallel:
+      env: [A]
+      matrix:
+        lr: 0.1
+      jobs:
+        - command: python train.py
+  - series:
+      wait: later
+      jobs: {host: cool42}
+  - jobs: []
+`
+	_, err := Decode([]byte(data))
+	errs := planErrors(t, err)
+	want := []struct {
+		line, column int
+		path, msg    string
+	}{
+		{2, 8, "kill[0]", "want an integer"},
+		{5, 7, "jobs[0].job", `unknown key "comand" (did you mean "command"?)`},
+		{7, 19, "jobs[0].job.queue_only", "want true or false"},
+		{9, 12, "jobs[1].parallel.env", "want a mapping"},
+		{11, 13, "jobs[1].parallel.matrix.lr", "want a list"},
+		{15, 13, "jobs[2].series.wait", `want "success" or "any"`},
+		{16, 13, "jobs[2].series.jobs", "want a list"},
+		{17, 5, "jobs[3]", `unknown key "jobs"`},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Decode() = %v, want %d errors", err, len(want))
+	}
+	for i, w := range want {
+		e := errs[i]
+		if e.Line != w.line || e.Column != w.column || e.Path != w.path || !strings.HasPrefix(e.Msg, w.msg) {
+			t.Errorf("error %d = %d:%d %s: %s, want %d:%d %s: %s...", i, e.Line, e.Column, e.Path, e.Msg, w.line, w.column, w.path, w.msg)
+		}
+	}
+}
+
+func TestDecodeAcceptsValidPlans(t *testing.T) {
+	data := `version: 1
+vars:
+  lr: 0.1
+defaults: &defaults
+  host: cool42
+  dir: ~/code
+jobs:
+  - job:
+      <<: *defaults
+      command: python prep.py
+      env:
+        CUDA_VISIBLE_DEVICES: 0
+      queue_only: true
+  - series:
+      env:
+      jobs:
+        - host: cool42
+          command: python eval.py
+`
+	// "defaults" isn't part of the schema
+	if _, err := Decode([]byte(data)); err == nil || !strings.Contains(err.Error(), `unknown key "defaults"`) {
+		t.Errorf("Decode() = %v", err)
+	}
+	data = strings.Replace(data, "defaults: &defaults\n  host: cool42\n  dir: ~/code\n", "", 1)
+	data = strings.Replace(data, "      <<: *defaults\n", "      host: cool42\n", 1)
+	f, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if started, queued := f.Count(); started != 0 || queued != 2 {
+		t.Errorf("Count() = %d, %d, want 0, 2", started, queued)
+	}
+}
+
+func TestValidateReportsPositions(t *testing.T) {
+	data := `version: 1
+jobs:
+  - job:
+      host: cool42
+  - parallel:
+      jobs:
+        - command: python a.py
+        - command: python b.py
+          host: cool42
+  - series:
+      jobs:
+        - {host: cool42, command: a}
+        - {host: cool43, command: b}
+`
+	f, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := planErrors(t, errors.Join(f.ApplyDefaults(Defaults{}), f.Validate()))
+	got := make([]string, len(errs))
+	for i, e := range errs {
+		got[i] = e.Error()
+	}
+	for _, want := range []string{
+		"7:11: jobs[1].parallel.jobs[0]: " + missingHost,
+		"4:7: jobs[0].job: missing command",
+		"13:11: jobs[2].series.jobs[1]: series block jobs must target the same host (found cool42 and cool43)",
+	} {
+		found := false
+		for _, g := range got {
+			found = found || g == want
+		}
+		if !found {
+			t.Errorf("errors = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	for name, want := range map[string]string{"comand": "command", "hots": "host", "xyzzy": ""} {
+		if got := suggest(name, jobFields); got != want {
+			t.Errorf("suggest(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varPattern matches ${name}, and $${name}, which stands for a literal
+// ${name}
+var varPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MatrixVar is one variable of a matrix and the values it takes
+type MatrixVar struct {
+	Name   string
+	Values []string
+}
+
+// Matrix lists variables whose values a block's jobs are repeated for, in
+// the order the plan lists them
+type Matrix []MatrixVar
+
+// UnmarshalYAML decodes a mapping of variable names to lists of values,
+// keeping the names in order
+func (m *Matrix) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: matrix must map variable names to lists of values", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		v := MatrixVar{Name: node.Content[i].Value}
+		if err := node.Content[i+1].Decode(&v.Values); err != nil {
+			return fmt.Errorf("line %d: matrix.%s must be a list of values", node.Content[i+1].Line, v.Name)
+		}
+		*m = append(*m, v)
+	}
+	return nil
+}
+
+// ParseVars parses name=value assignments, such as --var flags
+func ParseVars(assignments []string) (map[string]string, error) {
+	vars := make(map[string]string, len(assignments))
+	for _, a := range assignments {
+		name, value, ok := strings.Cut(a, "=")
+		if !ok || !varNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable %q (want name=value)", a)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// Expand applies the plan's variables. Each parallel or series block with a
+// matrix gets its jobs once for every combination of the matrix's values,
+// the first variable varying slowest, and the block's dir and env are
+// copied into its jobs. Then ${name} in a job's fields becomes the value of
+// the variable name, from overrides (such as --var flags), the block's
+// matrix, or the plan's vars, in that order. An override of a matrix
+// variable runs the block for that value alone. ${name} is left alone if no
+// variable is named name, for the shell to expand.
+func (f *File) Expand(overrides map[string]string) error {
+	for name := range f.Vars {
+		if !varNamePattern.MatchString(name) {
+			return fmt.Errorf("vars: invalid variable name %q", name)
+		}
+	}
+	vars := make(map[string]string, len(f.Vars)+len(overrides))
+	for name, value := range f.Vars {
+		vars[name] = value
+	}
+	for name, value := range overrides {
+		vars[name] = value
+	}
+
+	for i := range f.Jobs {
+		entry := &f.Jobs[i]
+		path := fmt.Sprintf("jobs[%d]", i)
+		switch {
+		case entry.Job != nil:
+			job := entry.Job.expand(vars)
+			entry.Job = &job
+		case entry.Parallel != nil:
+			p := entry.Parallel
+			jobs, err := expandBlock(p.Jobs, p.Dir, p.Env, p.Matrix, vars, overrides, path+".parallel")
+			if err != nil {
+				return err
+			}
+			p.Jobs, p.Dir, p.Env, p.Matrix = jobs, "", nil, nil
+			p.Name = interpolate(p.Name, vars)
+		case entry.Series != nil:
+			s := entry.Series
+			jobs, err := expandBlock(s.Jobs, s.Dir, s.Env, s.Matrix, vars, overrides, path+".series")
+			if err != nil {
+				return err
+			}
+			s.Jobs, s.Dir, s.Env, s.Matrix = jobs, "", nil, nil
+			s.Name = interpolate(s.Name, vars)
+			s.Queue = interpolate(s.Queue, vars)
+		}
+	}
+	return nil
+}
+
+// expandBlock returns a block's jobs for each combination of its matrix,
+// with the block's dir and env copied into them
+func expandBlock(jobs []Job, dir string, env map[string]string, matrix Matrix, vars, overrides map[string]string, path string) ([]Job, error) {
+	combinations, err := matrix.combinations(overrides, path)
+	if err != nil {
+		return nil, err
+	}
+	var out []Job
+	for _, combination := range combinations {
+		scope := make(map[string]string, len(vars)+len(combination))
+		for name, value := range vars {
+			scope[name] = value
+		}
+		var labels []string
+		for _, v := range combination {
+			if _, ok := overrides[v.Name]; !ok {
+				scope[v.Name] = v.Values[0]
+				labels = append(labels, v.Name+"="+v.Values[0])
+			}
+		}
+		for _, job := range jobs {
+			if job.Dir == "" {
+				job.Dir = dir
+			}
+			merged := make(map[string]string, len(env)+len(job.Env))
+			for k, v := range env {
+				merged[k] = v
+			}
+			for k, v := range job.Env {
+				merged[k] = v
+			}
+			if len(merged) > 0 {
+				job.Env = merged
+			}
+			expanded := job.expand(scope)
+			// Tell the copies of a named job apart
+			if job.Name != "" && !strings.Contains(job.Name, "${") && len(labels) > 0 {
+				expanded.Name += " (" + strings.Join(labels, ", ") + ")"
+			}
+			out = append(out, expanded)
+		}
+	}
+	return out, nil
+}
+
+// combinations returns every combination of the matrix's values, each as
+// a list of single-valued variables. A matrix variable in overrides takes
+// only the overriding value. An empty matrix has one, empty, combination.
+func (m Matrix) combinations(overrides map[string]string, path string) ([][]MatrixVar, error) {
+	combinations := [][]MatrixVar{nil}
+	var seen []string
+	for _, v := range m {
+		if !varNamePattern.MatchString(v.Name) {
+			return nil, fmt.Errorf("%s.matrix: invalid variable name %q", path, v.Name)
+		}
+		if slices.Contains(seen, v.Name) {
+			return nil, fmt.Errorf("%s.matrix: %s is listed twice", path, v.Name)
+		}
+		seen = append(seen, v.Name)
+		values := v.Values
+		if value, ok := overrides[v.Name]; ok {
+			values = []string{value}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%s.matrix.%s has no values", path, v.Name)
+		}
+		var next [][]MatrixVar
+		for _, c := range combinations {
+			for _, value := range values {
+				next = append(next, append(slices.Clone(c), MatrixVar{Name: v.Name, Values: []string{value}}))
+			}
+		}
+		combinations = next
+	}
+	return combinations, nil
+}
+
+// expand returns a copy of the job with variables replaced in its fields
+func (j Job) expand(vars map[string]string) Job {
+	j.Name = interpolate(j.Name, vars)
+	j.Host = interpolate(j.Host, vars)
+	j.Dir = interpolate(j.Dir, vars)
+	j.Command = interpolate(j.Command, vars)
+	j.Description = interpolate(j.Description, vars)
+	j.Queue = interpolate(j.Queue, vars)
+	if j.Env != nil {
+		env := make(map[string]string, len(j.Env))
+		for k, v := range j.Env {
+			env[k] = interpolate(v, vars)
+		}
+		j.Env = env
+	}
+	return j
+}
+
+// interpolate replaces ${name} in s with the value of the variable name, and
+// $${name} with ${name}
+func interpolate(s string, vars map[string]string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := match[2 : len(match)-1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,143 @@
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	data := []byte(`
+version: 1
+vars:
+  epochs: 10
+  data: imagenet
+jobs:
+  - job:
+      host: cool42
+      command: python prep.py --data ${data} --out $${HOME}/out ${HOME}
+  - parallel:
+      dir: ~/code/${data}
+      env:
+        LR: ${lr}
+      matrix:
+        lr: [0.1, 0.01]
+        bs: [32, 64]
+      jobs:
+        - name: train
+          host: cool42
+          command: python train.py --lr ${lr} --bs ${bs} --epochs ${epochs}
+  - series:
+      queue: q-${data}
+      matrix:
+        seed: [1, 2]
+      jobs:
+        - host: cool42
+          command: python train.py --seed ${seed}
+        - host: cool42
+          command: python eval.py --seed ${seed}
+`)
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Expand(map[string]string{"epochs": "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := f.Jobs[0].Job.Command, "python prep.py --data imagenet --out ${HOME}/out ${HOME}"; got != want {
+		t.Errorf("job command = %q, want %q", got, want)
+	}
+
+	p := f.Jobs[1].Parallel
+	if p.Matrix != nil || p.Env != nil || p.Dir != "" {
+		t.Errorf("parallel block keeps its matrix, env, or dir: %+v", p)
+	}
+	var commands, names []string
+	for _, job := range p.Jobs {
+		commands = append(commands, job.Command)
+		names = append(names, job.Name)
+		if job.Dir != "~/code/imagenet" || job.Env["LR"] == "" || job.Env["LR"] == "${lr}" {
+			t.Errorf("job %q has dir %q and env %v", job.Command, job.Dir, job.Env)
+		}
+	}
+	wantCommands := []string{
+		"python train.py --lr 0.1 --bs 32 --epochs 3",
+		"python train.py --lr 0.1 --bs 64 --epochs 3",
+		"python train.py --lr 0.01 --bs 32 --epochs 3",
+		"python train.py --lr 0.01 --bs 64 --epochs 3",
+	}
+	if !reflect.DeepEqual(commands, wantCommands) {
+		t.Errorf("parallel commands = %q, want %q", commands, wantCommands)
+	}
+	if names[1] != "train (lr=0.1, bs=64)" {
+		t.Errorf("parallel job name = %q", names[1])
+	}
+
+	s := f.Jobs[2].Series
+	commands = nil
+	for _, job := range s.Jobs {
+		commands = append(commands, job.Command)
+	}
+	wantCommands = []string{
+		"python train.py --seed 1", "python eval.py --seed 1",
+		"python train.py --seed 2", "python eval.py --seed 2",
+	}
+	if !reflect.DeepEqual(commands, wantCommands) || s.Queue != "q-imagenet" {
+		t.Errorf("series = %q in queue %q, want %q", commands, s.Queue, wantCommands)
+	}
+}
+
+func TestExpandOverridesMatrix(t *testing.T) {
+	f := &File{
+		Version: 1,
+		Jobs: []Entry{{Parallel: &Parallel{
+			Matrix: Matrix{{Name: "lr", Values: []string{"0.1", "0.01"}}, {Name: "bs", Values: []string{"32", "64"}}},
+			Jobs:   []Job{{Name: "train", Host: "h", Command: "train --lr ${lr} --bs ${bs}"}},
+		}}},
+	}
+	if err := f.Expand(map[string]string{"lr": "0.5"}); err != nil {
+		t.Fatal(err)
+	}
+	jobs := f.Jobs[0].Parallel.Jobs
+	if len(jobs) != 2 || jobs[0].Command != "train --lr 0.5 --bs 32" || jobs[1].Name != "train (bs=64)" {
+		t.Errorf("jobs = %+v", jobs)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	for _, data := range []string{
+		"version: 1\njobs:\n  - parallel:\n      matrix:\n        lr: []\n      jobs:\n        - {host: h, command: c}\n",
+		"version: 1\njobs:\n  - parallel:\n      matrix:\n        lr: [1]\n        lr: [2]\n      jobs:\n        - {host: h, command: c}\n",
+		"version: 1\nvars:\n  a-b: 1\njobs:\n  - job: {host: h, command: c}\n",
+	} {
+		f, err := Decode([]byte(data))
+		if err == nil {
+			err = f.Expand(nil)
+		}
+		if err == nil {
+			t.Errorf("expected an error for:\n%s", data)
+		}
+	}
+	if _, err := Decode([]byte("version: 1\njobs:\n  - parallel:\n      matrix: [1, 2]\n")); err == nil {
+		t.Error("expected an error for a matrix that isn't a mapping")
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars([]string{"lr=0.1", "note=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"lr": "0.1", "note": "a=b", "empty": ""}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("ParseVars() = %v, want %v", vars, want)
+	}
+	for _, bad := range []string{"lr", "1lr=2", "=3"} {
+		if _, err := ParseVars([]string{bad}); err == nil {
+			t.Errorf("ParseVars(%q) should fail", bad)
+		}
+	}
+}
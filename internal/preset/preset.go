@@ -0,0 +1,179 @@
+// Package preset reads per-project submission defaults from a
+// .remote-jobs.toml file in a project's directory, so that run and queue add
+// started anywhere in the project pick up its host, remote directory,
+// environment, conda environment, and tags:
+//
+//	host = "cool30"
+//	dir = "~/code/lm2"       # remote directory of the project root
+//	conda = "lm2"
+//	tags = ["lm2"]
+//
+//	[env]
+//	WANDB_PROJECT = "lm2"
+//	OMP_NUM_THREADS = 8
+//
+// Environment values may be strings, numbers, or booleans.
+package preset
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// FileName is the name of a project's preset file
+const FileName = ".remote-jobs.toml"
+
+// Preset holds a project's submission defaults. Flags given on the command
+// line take precedence over each of them.
+type Preset struct {
+	Path  string   // File the preset was read from
+	Host  string   // Host to use when none is given
+	Dir   string   // Remote directory corresponding to the project root, the file's directory
+	Conda string   // Conda environment to activate before the command
+	Tags  []string // Tags added to every job
+	Env   []string // Environment variables (VAR=value), in file order
+}
+
+// Find looks for a preset file in dir and its parents, and returns the first
+// found, or nil if there is none
+func Find(dir string) (*Preset, error) {
+	for {
+		p := filepath.Join(dir, FileName)
+		if _, err := os.Stat(p); err == nil {
+			return Load(p)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads a preset file
+func Load(file string) (*Preset, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	p.Path = file
+	return p, nil
+}
+
+// file is the layout of a preset file
+type file struct {
+	Host  string         `toml:"host"`
+	Dir   string         `toml:"dir"`
+	Conda string         `toml:"conda"`
+	Tags  []string       `toml:"tags"`
+	Env   map[string]any `toml:"env"`
+}
+
+// Parse parses the contents of a preset file
+func Parse(data string) (*Preset, error) {
+	var f file
+	md, err := toml.Decode(data, &f)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown setting %q (expected host, dir, conda, tags, or [env])", undecoded[0].String())
+	}
+	p := &Preset{Host: f.Host, Dir: f.Dir, Conda: f.Conda, Tags: f.Tags}
+	// The table loses the variables' order, which the keys keep
+	for _, key := range md.Keys() {
+		if len(key) != 2 || key[0] != "env" {
+			continue
+		}
+		value, err := envValue(f.Env[key[1]])
+		if err != nil {
+			return nil, fmt.Errorf("env.%s: %w", key[1], err)
+		}
+		p.Env = append(p.Env, key[1]+"="+value)
+	}
+	return p, nil
+}
+
+// envValue formats a string, number, or boolean as an environment value
+func envValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("expected a string, number, or boolean, got %v", v)
+}
+
+// WorkingDir returns the remote directory for cwd, a local directory in the
+// project: Dir joined with cwd's path below the project root. It returns ""
+// if the preset has no Dir, or cwd is outside the project.
+func (p *Preset) WorkingDir(cwd string) string {
+	if p.Dir == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Dir(p.Path), cwd)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	if rel == "." {
+		return p.Dir
+	}
+	return path.Join(p.Dir, filepath.ToSlash(rel))
+}
+
+// WrapCommand returns command preceded by the activation of the preset's
+// conda environment, if it has one
+func (p *Preset) WrapCommand(command string) string {
+	if p.Conda == "" {
+		return command
+	}
+	return `eval "$(conda shell.bash hook)" && conda activate ` + shellquote.Quote(p.Conda) + " && " + command
+}
+
+// MergeEnv returns the preset's environment variables followed by those
+// given on the command line, leaving out preset variables that the command
+// line sets
+func (p *Preset) MergeEnv(flags []string) []string {
+	set := make(map[string]bool)
+	for _, ev := range flags {
+		name, _, _ := strings.Cut(ev, "=")
+		set[name] = true
+	}
+	var env []string
+	for _, ev := range p.Env {
+		if name, _, _ := strings.Cut(ev, "="); !set[name] {
+			env = append(env, ev)
+		}
+	}
+	return append(env, flags...)
+}
+
+// MergeTags returns the preset's tags followed by the command line's,
+// without duplicates
+func (p *Preset) MergeTags(flags []string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, t := range append(append([]string{}, p.Tags...), flags...) {
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
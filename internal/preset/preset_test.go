@@ -0,0 +1,109 @@
+package preset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := `
+# LM2 training
+host = "cool30"
+dir = '~/code/lm 2'   # remote checkout
+conda = "lm2"
+tags = [
+  "lm2", 'sweep#3',
+]
+
+[env]
+WANDB_PROJECT = "lm2"
+OMP_NUM_THREADS = 8
+"HF_HOME" = "/data/hf # cache"
+`
+	p, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Preset{
+		Host:  "cool30",
+		Dir:   "~/code/lm 2",
+		Conda: "lm2",
+		Tags:  []string{"lm2", "sweep#3"},
+		Env:   []string{"WANDB_PROJECT=lm2", "OMP_NUM_THREADS=8", "HF_HOME=/data/hf # cache"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Parse() = %+v, want %+v", p, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		`hots = "cool30"`:           `unknown setting "hots"`,
+		"[hosts]\n":                 `unknown setting "hosts"`,
+		`host = cool30`:             `line 1 (last key "host")`,
+		"tags = [lm2]":              `line 1 (last key "tags")`,
+		"[env]\nA = [1]":            "env.A: expected a string, number, or boolean",
+		"host":                      "line 1",
+		"conda = \"lm2\"\nhost = 1": `line 2 (last key "host"): incompatible types`,
+	}
+	for data, want := range tests {
+		if _, err := Parse(data); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want %q", data, err, want)
+		}
+	}
+}
+
+func TestFindAndWorkingDir(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "src", "models")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, FileName), []byte(`dir = "~/code/lm2"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Find(sub)
+	if err != nil || p == nil {
+		t.Fatalf("Find() = %v, %v", p, err)
+	}
+	if p.Path != filepath.Join(root, FileName) {
+		t.Errorf("Path = %q", p.Path)
+	}
+	if got, want := p.WorkingDir(sub), "~/code/lm2/src/models"; got != want {
+		t.Errorf("WorkingDir(sub) = %q, want %q", got, want)
+	}
+	if got, want := p.WorkingDir(root), "~/code/lm2"; got != want {
+		t.Errorf("WorkingDir(root) = %q, want %q", got, want)
+	}
+	if got := p.WorkingDir(filepath.Dir(root)); got != "" {
+		t.Errorf("WorkingDir(outside) = %q, want \"\"", got)
+	}
+
+	if p, err := Find(t.TempDir()); p != nil || err != nil {
+		t.Errorf("Find(empty) = %+v, %v; want nil", p, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	p := &Preset{
+		Conda: "lm 2",
+		Tags:  []string{"lm2", "gpu"},
+		Env:   []string{"WANDB_PROJECT=lm2", "BATCH=32"},
+	}
+	if got, want := p.MergeEnv([]string{"BATCH=64", "SEED=1"}), []string{"WANDB_PROJECT=lm2", "BATCH=64", "SEED=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeEnv() = %v, want %v", got, want)
+	}
+	if got, want := p.MergeTags([]string{"gpu", "ablation"}), []string{"lm2", "gpu", "ablation"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeTags() = %v, want %v", got, want)
+	}
+	if got, want := p.WrapCommand("python train.py"), `eval "$(conda shell.bash hook)" && conda activate 'lm 2' && python train.py`; got != want {
+		t.Errorf("WrapCommand() = %q, want %q", got, want)
+	}
+	if got := (&Preset{}).WrapCommand("make"); got != "make" {
+		t.Errorf("WrapCommand() without conda = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+// Package profile keeps separate job databases and config files under named
+// profiles, such as for personal and lab work, and lets the database be put
+// anywhere.
+//
+// A profile's database and config file are in its directory,
+// ~/.config/remote-jobs/profiles/NAME (under $XDG_CONFIG_HOME if it is
+// set); the default profile's are in ~/.config/remote-jobs itself.
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/xdg"
+)
+
+// EnvVar selects a profile, like --profile, and is set for the commands
+// remote-jobs starts, such as the tray's TUI, so that they use the same one
+const EnvVar = "REMOTE_JOBS_PROFILE"
+
+// DBEnvVar sets the database's path, like --db, and is likewise passed on
+const DBEnvVar = "REMOTE_JOBS_DB"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateName checks that a profile name can be a directory name
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, -, _, and .)", name)
+	}
+	return nil
+}
+
+// BaseDir returns the directory of the default profile (see the xdg
+// package)
+func BaseDir() (string, error) {
+	dir := xdg.ConfigDir()
+	if dir == "" {
+		return "", fmt.Errorf("no home directory for profiles")
+	}
+	return dir, nil
+}
+
+// Dir returns the directory of the named profile
+func Dir(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	base, err := BaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "profiles", name), nil
+}
+
+// Current returns the profile selected by the environment, or "" for the
+// default one
+func Current() string {
+	if name := os.Getenv(EnvVar); name != "default" {
+		return name
+	}
+	return ""
+}
+
+// Apply makes the database and config file those of the named profile, or
+// of the default one if name is "". dbPath, if set, then overrides the
+// database's path. Empty arguments fall back to $REMOTE_JOBS_PROFILE and
+// $REMOTE_JOBS_DB, and the settings are exported to them for child
+// processes.
+func Apply(name, dbPath string) error {
+	if name == "" {
+		name = os.Getenv(EnvVar)
+	}
+	if dbPath == "" {
+		dbPath = os.Getenv(DBEnvVar)
+	}
+	if name != "" && name != "default" {
+		dir, err := Dir(name)
+		if err != nil {
+			return err
+		}
+		db.SetPath(filepath.Join(dir, "jobs.db"))
+		config.SetPath(filepath.Join(dir, "config.yaml"))
+		if err := os.Setenv(EnvVar, name); err != nil {
+			return err
+		}
+	}
+	if dbPath != "" {
+		abs, err := filepath.Abs(dbPath)
+		if err != nil {
+			return err
+		}
+		db.SetPath(abs)
+		if err := os.Setenv(DBEnvVar, abs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
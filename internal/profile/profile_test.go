@@ -0,0 +1,65 @@
+package profile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"work", "lab-2", "personal_v1.0"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../work", "a/b", ".hidden", "my lab"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) should fail", name)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv(EnvVar, "")
+	t.Setenv(DBEnvVar, "")
+	dbPath, configPath := db.Path(), config.ConfigPath()
+	t.Cleanup(func() {
+		db.SetPath(dbPath)
+		config.SetPath(configPath)
+	})
+
+	if err := Apply("work", ""); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(home, ".config", "remote-jobs", "profiles", "work")
+	if got := db.Path(); got != filepath.Join(dir, "jobs.db") {
+		t.Errorf("database = %s, want the profile's", got)
+	}
+	if got := config.ConfigPath(); got != filepath.Join(dir, "config.yaml") {
+		t.Errorf("config = %s, want the profile's", got)
+	}
+	if Current() != "work" {
+		t.Errorf("Current() = %q, want the profile exported to child processes", Current())
+	}
+
+	custom := filepath.Join(t.TempDir(), "lab.db")
+	t.Setenv(DBEnvVar, custom)
+	if err := Apply("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Path(); got != custom {
+		t.Errorf("database = %s, want $%s", got, DBEnvVar)
+	}
+	if got := config.ConfigPath(); got != filepath.Join(dir, "config.yaml") {
+		t.Errorf("config = %s, want $%s's profile's", got, EnvVar)
+	}
+
+	if err := Apply("../etc", ""); err == nil {
+		t.Error("Apply() should reject an invalid profile name")
+	}
+}
@@ -0,0 +1,105 @@
+// Package prunepolicy selects finished jobs to remove under the configured
+// prune policy, so the job database doesn't grow without bound.
+package prunepolicy
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// Removal is a job the policy removes, and the rule that selected it
+type Removal struct {
+	Job    *db.Job
+	Reason string
+}
+
+// Select returns the jobs in finished (newest first, as returned by
+// db.ListFinishedJobs) that the policy removes as of now
+func Select(p config.PrunePolicy, finished []*db.Job, now time.Time) []Removal {
+	var removals []Removal
+	for i, job := range finished {
+		isFailed := Failed(job)
+		if isFailed && p.KeepFailed {
+			continue
+		}
+		age := now.Sub(time.Unix(endTime(job), 0))
+		switch {
+		case p.KeepLast > 0 && i >= p.KeepLast:
+			removals = append(removals, Removal{job, fmt.Sprintf("not in the last %d", p.KeepLast)})
+		case endTime(job) <= 0:
+			// Age rules don't apply to jobs with no recorded times
+		case !isFailed && p.SucceededMaxAgeDays > 0 && age > days(p.SucceededMaxAgeDays):
+			removals = append(removals, Removal{job, fmt.Sprintf("succeeded over %dd ago", p.SucceededMaxAgeDays)})
+		case isFailed && p.FailedMaxAgeDays > 0 && age > days(p.FailedMaxAgeDays):
+			removals = append(removals, Removal{job, fmt.Sprintf("failed over %dd ago", p.FailedMaxAgeDays)})
+		}
+	}
+	return removals
+}
+
+// Failed reports whether a finished job failed, died, or exited non-zero
+func Failed(job *db.Job) bool {
+	switch job.Status {
+	case db.StatusFailed, db.StatusDead:
+		return true
+	case db.StatusCompleted:
+		return job.ExitCode != nil && *job.ExitCode != 0
+	}
+	return false
+}
+
+// Apply deletes the removed jobs from the database. If deferFiles is set, it
+// also records deferred operations that delete their remote files on each
+// host's next sync. Returns the number of jobs deleted.
+func Apply(database *sql.DB, removals []Removal, deferFiles bool) (int, error) {
+	count := 0
+	for _, r := range removals {
+		// Legacy jobs' file names can't be derived from the job ID
+		if deferFiles && r.Job.SessionName == "" {
+			if err := db.AddDeferredOperation(database, r.Job.Host, db.OpDeleteFiles, r.Job.ID, ""); err != nil {
+				return count, fmt.Errorf("defer deleting files for job %d: %w", r.Job.ID, err)
+			}
+		}
+		if err := db.DeleteJob(database, r.Job.ID); err != nil {
+			return count, fmt.Errorf("delete job %d: %w", r.Job.ID, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Summary counts removals by reason, e.g. "3 not in the last 200, 5 succeeded over 30d ago"
+func Summary(removals []Removal) string {
+	counts := make(map[string]int)
+	for _, r := range removals {
+		counts[r.Reason]++
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// endTime returns when a job finished, or when it started if the end wasn't recorded
+func endTime(job *db.Job) int64 {
+	if job.EndTime != nil && *job.EndTime > 0 {
+		return *job.EndTime
+	}
+	return job.StartTime
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
@@ -0,0 +1,62 @@
+package prunepolicy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestSelect(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(days int) *int64 {
+		t := now.Add(-time.Duration(days) * 24 * time.Hour).Unix()
+		return &t
+	}
+	zero, one := 0, 1
+	// Newest first, as returned by db.ListFinishedJobs
+	jobs := []*db.Job{
+		{ID: 6, Status: db.StatusCompleted, ExitCode: &zero, EndTime: ago(1)},
+		{ID: 5, Status: db.StatusCompleted, ExitCode: &zero, EndTime: ago(40)},
+		{ID: 4, Status: db.StatusCompleted, ExitCode: &one, EndTime: ago(40)},
+		{ID: 3, Status: db.StatusDead, EndTime: ago(50)},
+		{ID: 2, Status: db.StatusCompleted, ExitCode: &zero, EndTime: ago(60)},
+		{ID: 1, Status: db.StatusCompleted, ExitCode: &zero},
+	}
+
+	tests := []struct {
+		name   string
+		policy config.PrunePolicy
+		want   []int64
+	}{
+		{"none", config.PrunePolicy{}, nil},
+		{"keep last", config.PrunePolicy{KeepLast: 4}, []int64{2, 1}},
+		{"succeeded age", config.PrunePolicy{SucceededMaxAgeDays: 30}, []int64{5, 2}},
+		{"failed age", config.PrunePolicy{FailedMaxAgeDays: 30}, []int64{4, 3}},
+		{"keep failed", config.PrunePolicy{KeepLast: 1, KeepFailed: true}, []int64{5, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			for _, r := range Select(tt.policy, jobs, now) {
+				got = append(got, r.Job.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	removals := []Removal{
+		{Reason: "succeeded over 30d ago"},
+		{Reason: "not in the last 200"},
+		{Reason: "succeeded over 30d ago"},
+	}
+	if got, want := Summary(removals), "1 not in the last 200, 2 succeeded over 30d ago"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package reachability
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// latencyWeight is the weight of a new sample in a host's rolling average
+// latency, so that one slow round trip doesn't swing it
+const latencyWeight = 0.3
+
+// pingTimeout bounds a latency measurement on a host that hasn't recorded a
+// high latency
+const pingTimeout = 10 * time.Second
+
+// MeasureLatency times a round trip to host and adds it to the host's
+// rolling average, which then stretches the timeouts of commands on the
+// host. It returns the updated record. Saving the record is best effort.
+func MeasureLatency(database *sql.DB, host string, now time.Time) (*db.HostLatency, error) {
+	rtt, err := ssh.Ping(host, pingTimeout)
+	if err != nil {
+		return nil, err
+	}
+	prev, _ := db.GetHostLatency(database, host)
+	l := addSample(prev, host, rtt, now)
+	_ = db.SaveHostLatency(database, l)
+	ssh.SetLatency(host, l.Average)
+	return l, nil
+}
+
+// addSample returns the latency record prev, which may be nil, updated with
+// a new sample
+func addSample(prev *db.HostLatency, host string, rtt time.Duration, now time.Time) *db.HostLatency {
+	l := &db.HostLatency{Host: host, Average: rtt, Last: rtt, Samples: 1, MeasuredAt: now.Unix()}
+	if prev != nil && prev.Samples > 0 {
+		l.Average = prev.Average + time.Duration(latencyWeight*float64(rtt-prev.Average))
+		l.Samples = prev.Samples + 1
+	}
+	return l
+}
+
+// LoadLatencies sets the timeouts of commands on each host from its recorded
+// latency, and returns the records by host. Errors reading the records leave
+// the default timeouts.
+func LoadLatencies(database *sql.DB) map[string]*db.HostLatency {
+	latencies, err := db.ListHostLatency(database)
+	if err != nil {
+		return nil
+	}
+	for host, l := range latencies {
+		ssh.SetLatency(host, l.Average)
+	}
+	return latencies
+}
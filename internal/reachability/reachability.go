@@ -0,0 +1,82 @@
+// Package reachability caches whether hosts answer SSH, and how quickly. The
+// cache lives in the job database, so the CLI and the TUI share it: once a
+// host fails to answer, both skip it for a backoff window instead of each
+// waiting on a connection timeout for every job on the host, and both allow
+// longer timeouts on hosts whose round trips are slow.
+package reachability
+
+import (
+	"database/sql"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// The backoff after a host's first failure, doubling with each consecutive
+// failure up to MaxBackoff
+const (
+	MinBackoff = 30 * time.Second
+	MaxBackoff = 5 * time.Minute
+)
+
+// jitterFraction spreads retries of hosts that went down together
+const jitterFraction = 0.2
+
+// Failed reports whether err, from an SSH attempt, means the host couldn't be
+// reached. ssh itself exits with status 255 when it can't connect or log in;
+// other errors mean the host answered.
+func Failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	return ssh.IsConnectionError(err.Error()) || strings.Contains(err.Error(), "exit status 255")
+}
+
+// Backoff returns how long to skip a host after its nth consecutive failure,
+// before jitter: 30s, 1m, 2m, 4m, then 5m
+func Backoff(failures int) time.Duration {
+	d := MinBackoff
+	for i := 1; i < failures && d < MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, MaxBackoff)
+}
+
+// jitter shifts d by up to jitterFraction either way
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration((rand.Float64()*2-1)*jitterFraction*float64(d))
+}
+
+// Skip reports whether host failed its last attempt and its backoff window
+// hasn't passed, so it shouldn't be tried now. Errors reading the cache
+// never skip a host.
+func Skip(database *sql.DB, host string, now time.Time) bool {
+	r, err := db.GetHostReachability(database, host)
+	if err != nil {
+		return false
+	}
+	return skip(r, now)
+}
+
+func skip(r *db.HostReachability, now time.Time) bool {
+	return r != nil && !r.Reachable && now.Unix() < r.RetryAt
+}
+
+// Record records the result of an SSH attempt on host, given the attempt's
+// error. A failure backs the host off for longer than the last one did; a
+// success resets it. Recording is best effort: the cache only saves time.
+func Record(database *sql.DB, host string, err error, now time.Time) {
+	if !Failed(err) {
+		_ = db.RecordHostReachable(database, host, now.Unix())
+		return
+	}
+	failures := 1
+	if r, _ := db.GetHostReachability(database, host); r != nil && !r.Reachable {
+		failures = r.Failures + 1
+	}
+	retryAt := now.Add(jitter(Backoff(failures)))
+	_ = db.RecordHostUnreachable(database, host, err.Error(), now.Unix(), retryAt.Unix())
+}
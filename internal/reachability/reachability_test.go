@@ -0,0 +1,77 @@
+package reachability
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("connection error: ssh: connect to host cool30 port 22: Connection refused"), true},
+		{errors.New("ssh command timed out after 5s"), true},
+		{errors.New("exit status 255"), true},
+		{errors.New("exit status 1"), false},
+	}
+	for _, tt := range tests {
+		if got := Failed(tt.err); got != tt.want {
+			t.Errorf("Failed(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name string
+		r    *db.HostReachability
+		want bool
+	}{
+		{"never tried", nil, false},
+		{"reachable", &db.HostReachability{Reachable: true}, false},
+		{"backing off", &db.HostReachability{Failures: 1, RetryAt: now.Unix() + 30}, true},
+		{"backoff passed", &db.HostReachability{Failures: 1, RetryAt: now.Unix() - 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skip(tt.r, now); got != tt.want {
+				t.Errorf("skip() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		if got := jitter(time.Minute); got < 48*time.Second || got > 72*time.Second {
+			t.Fatalf("jitter(1m) = %v, want within 20%%", got)
+		}
+	}
+}
+
+func TestAddSample(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := addSample(nil, "cool30", 100*time.Millisecond, now)
+	if l.Average != 100*time.Millisecond || l.Samples != 1 {
+		t.Errorf("addSample() of a first sample = %v over %d, want 100ms over 1", l.Average, l.Samples)
+	}
+	l = addSample(l, "cool30", 1100*time.Millisecond, now)
+	if l.Average != 400*time.Millisecond || l.Last != 1100*time.Millisecond || l.Samples != 2 {
+		t.Errorf("addSample() = %v (last %v) over %d, want 400ms (last 1.1s) over 2", l.Average, l.Last, l.Samples)
+	}
+}
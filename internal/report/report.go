@@ -0,0 +1,182 @@
+// Package report aggregates job history into usage statistics.
+package report
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// Row summarizes the jobs in one group
+type Row struct {
+	Key           string
+	Jobs          int
+	Succeeded     int
+	Failed        int
+	Skipped       int   // Skipped by the queue runner because a --if condition was false
+	Running       int   // Running, queued, or pending
+	TotalSeconds  int64 // Sum of run times of jobs that started
+	GPUSeconds    int64 // Run time multiplied by the number of GPUs sampled for each job
+	MedianSeconds int64 // Median run time of finished jobs (-1 if none)
+	durations     []int64
+}
+
+// SuccessRate returns the fraction of finished jobs that succeeded, or -1 if none finished
+func (r *Row) SuccessRate() float64 {
+	finished := r.Succeeded + r.Failed
+	if finished == 0 {
+		return -1
+	}
+	return float64(r.Succeeded) / float64(finished)
+}
+
+// GPUHours returns the total GPU time in hours
+func (r *Row) GPUHours() float64 {
+	return float64(r.GPUSeconds) / 3600
+}
+
+// KeyFunc extracts the grouping keys for a job. A job is counted in the
+// group for each key, so groups can overlap.
+type KeyFunc func(job *db.Job) []string
+
+// NoTag is the group ByTag puts untagged jobs in
+const NoTag = "(none)"
+
+// ByHost groups jobs by host
+func ByHost(job *db.Job) []string { return []string{job.Host} }
+
+// ByStatus groups jobs by outcome: succeeded, failed, skipped, running, or queued
+func ByStatus(job *db.Job) []string { return []string{outcome(job)} }
+
+// ByCommand groups jobs by command template (see CommandTemplate)
+func ByCommand(job *db.Job) []string {
+	return []string{CommandTemplate(job.EffectiveCommand())}
+}
+
+// ByTag groups jobs by tag, given each job's tags. A job with several tags
+// is counted under each of them, and untagged jobs under NoTag.
+func ByTag(tags map[int64][]string) KeyFunc {
+	return func(job *db.Job) []string {
+		if len(tags[job.ID]) == 0 {
+			return []string{NoTag}
+		}
+		return tags[job.ID]
+	}
+}
+
+// Summarize groups jobs by key and computes statistics for each group.
+// stats supplies sampled GPU usage and may be nil. now is used for running jobs.
+// Rows are sorted by job count, largest first.
+func Summarize(jobs []*db.Job, key KeyFunc, stats map[int64]*db.JobStats, now int64) []*Row {
+	groups := make(map[string]*Row)
+	for _, job := range jobs {
+		for _, k := range key(job) {
+			row := groups[k]
+			if row == nil {
+				row = &Row{Key: k}
+				groups[k] = row
+			}
+			row.add(job, stats[job.ID], now)
+		}
+	}
+
+	rows := make([]*Row, 0, len(groups))
+	for _, row := range groups {
+		row.MedianSeconds = median(row.durations)
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Jobs != rows[j].Jobs {
+			return rows[i].Jobs > rows[j].Jobs
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+// Total summarizes all jobs as a single row
+func Total(jobs []*db.Job, stats map[int64]*db.JobStats, now int64) *Row {
+	rows := Summarize(jobs, func(*db.Job) []string { return []string{"total"} }, stats, now)
+	if len(rows) == 0 {
+		return &Row{Key: "total", MedianSeconds: -1}
+	}
+	return rows[0]
+}
+
+func (r *Row) add(job *db.Job, stats *db.JobStats, now int64) {
+	r.Jobs++
+	switch outcome(job) {
+	case "succeeded":
+		r.Succeeded++
+	case "failed":
+		r.Failed++
+	case "skipped":
+		r.Skipped++
+		return // It never ran
+	default:
+		r.Running++
+	}
+
+	elapsed := job.Elapsed(now)
+	if elapsed < 0 {
+		return
+	}
+	r.TotalSeconds += elapsed
+	if stats != nil && stats.GPUMemory != "" {
+		r.GPUSeconds += elapsed * int64(len(strings.Fields(stats.GPUMemory)))
+	}
+	if job.EndTime != nil {
+		r.durations = append(r.durations, elapsed)
+	}
+}
+
+func outcome(job *db.Job) string {
+	switch job.Status {
+	case db.StatusCompleted:
+		if job.ExitCode != nil && *job.ExitCode == 0 {
+			return "succeeded"
+		}
+		if job.Skipped() {
+			return "skipped"
+		}
+		return "failed"
+	case db.StatusDead, db.StatusFailed:
+		return "failed"
+	case db.StatusQueued, db.StatusPending:
+		return "queued"
+	default:
+		return "running"
+	}
+}
+
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return -1
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+var (
+	quotedPattern = regexp.MustCompile(`'[^']*'|"[^"]*"`)
+	numberPattern = regexp.MustCompile(`\b\d+(\.\d+)?([eE][-+]?\d+)?\b`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// CommandTemplate reduces a command to a template so that runs differing only
+// in numbers or quoted arguments are grouped together:
+//
+//	python train.py --lr 0.001 --seed 3  -> python train.py --lr N --seed N
+//	python eval.py --name "run 1"        -> python eval.py --name "…"
+func CommandTemplate(command string) string {
+	t := quotedPattern.ReplaceAllString(command, `"…"`)
+	t = numberPattern.ReplaceAllString(t, "N")
+	return strings.TrimSpace(spacePattern.ReplaceAllString(t, " "))
+}
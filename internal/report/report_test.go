@@ -0,0 +1,110 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func completed(id int64, host, command string, start, end int64, exitCode int) *db.Job {
+	return &db.Job{ID: id, Host: host, Command: command, Status: db.StatusCompleted,
+		StartTime: start, EndTime: &end, ExitCode: &exitCode}
+}
+
+func TestSummarizeByHost(t *testing.T) {
+	jobs := []*db.Job{
+		completed(1, "cool30", "python train.py", 100, 3700, 0),
+		completed(2, "cool30", "python train.py", 100, 7300, 1),
+		completed(3, "cool30", "python train.py", 100, 700, 0),
+		{ID: 4, Host: "cool30", Status: db.StatusRunning, StartTime: 9000},
+		completed(5, "cool100", "make test", 100, 160, 0),
+		{ID: 6, Host: "cool100", Status: db.StatusDead, StartTime: 100},
+		completed(7, "cool100", "make test", 100, 9000, db.ExitSkipped),
+	}
+	stats := map[int64]*db.JobStats{
+		1: {JobID: 1, GPUMemory: "0:1000MiB 1:1000MiB"},
+		4: {JobID: 4, GPUMemory: "0:500MiB"},
+	}
+
+	rows := Summarize(jobs, ByHost, stats, 10000)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+
+	cool30 := rows[0]
+	if cool30.Key != "cool30" || cool30.Jobs != 4 || cool30.Succeeded != 2 || cool30.Failed != 1 || cool30.Running != 1 {
+		t.Errorf("cool30 row = %+v", cool30)
+	}
+	if got := cool30.SuccessRate(); got < 0.66 || got > 0.67 {
+		t.Errorf("cool30 success rate = %v, want 2/3", got)
+	}
+	if cool30.MedianSeconds != 3600 {
+		t.Errorf("cool30 median = %d, want 3600", cool30.MedianSeconds)
+	}
+	// Job 1: 1h on 2 GPUs; job 4: 1000s on 1 GPU (still running)
+	if cool30.GPUSeconds != 2*3600+1000 {
+		t.Errorf("cool30 GPU seconds = %d, want %d", cool30.GPUSeconds, 2*3600+1000)
+	}
+
+	cool100 := rows[1]
+	if cool100.Jobs != 3 || cool100.Failed != 1 || cool100.Skipped != 1 || cool100.MedianSeconds != 60 {
+		t.Errorf("cool100 row = %+v", cool100)
+	}
+}
+
+func TestSummarizeByTag(t *testing.T) {
+	jobs := []*db.Job{
+		completed(1, "cool30", "python train.py", 100, 200, 0),
+		completed(2, "cool30", "python train.py", 100, 400, 1),
+		completed(3, "cool30", "python eval.py", 100, 150, 0),
+	}
+	tags := map[int64][]string{
+		1: {"baseline", "sweep"},
+		2: {"sweep"},
+	}
+
+	got := make(map[string]*Row)
+	for _, row := range Summarize(jobs, ByTag(tags), nil, 1000) {
+		got[row.Key] = row
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d rows, want 3: %v", len(got), got)
+	}
+	if row := got["sweep"]; row.Jobs != 2 || row.Succeeded != 1 || row.Failed != 1 {
+		t.Errorf("sweep row = %+v", row)
+	}
+	if row := got["baseline"]; row.Jobs != 1 || row.Succeeded != 1 || row.TotalSeconds != 100 {
+		t.Errorf("baseline row = %+v", row)
+	}
+	if row := got[NoTag]; row == nil || row.Jobs != 1 || row.MedianSeconds != 50 {
+		t.Errorf("untagged row = %+v", row)
+	}
+}
+
+func TestTotalEmpty(t *testing.T) {
+	row := Total(nil, nil, 0)
+	if row.Jobs != 0 || row.MedianSeconds != -1 || row.SuccessRate() != -1 {
+		t.Errorf("Total(nil) = %+v", row)
+	}
+}
+
+func TestCommandTemplate(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"python train.py --lr 0.001 --seed 3", "python train.py --lr N --seed N"},
+		{`python eval.py --name "run 1"`, `python eval.py --name "…"`},
+		{"python eval.py --name 'run 2'", `python eval.py --name "…"`},
+		{"make  test", "make test"},
+		{"python train_v2.py --lr 1e-4", "python train_v2.py --lr N"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := CommandTemplate(tt.command); got != tt.want {
+				t.Errorf("CommandTemplate(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package results reads the metrics a job declared with --result and
+// --results-file, such as its final accuracy or loss, when it finishes.
+//
+// A --result pattern is a regular expression whose named groups are metrics,
+// e.g. `val_acc=(?P<accuracy>[0-9.]+)`; it is matched against the end of the
+// job's log, and the last match wins. A --results-file is a JSON file whose
+// numbers are metrics, named by their dotted paths ("eval.loss").
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// LogLines is how many lines from the end of a job's log patterns are
+// matched against
+const LogLines = 2000
+
+// marker separates the results file from the log in Command's output
+const marker = "--- remote-jobs results log ---"
+
+// ValidatePattern checks that pattern compiles and names at least one metric
+func ValidatePattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid result pattern %q: %w", pattern, err)
+	}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("result pattern %q has no named group, e.g. (?P<accuracy>[0-9.]+)", pattern)
+}
+
+// Command returns a command that prints the results file, relative to dir,
+// followed by the output of logCommand, which prints the end of the job's
+// log. Either is skipped if it's empty.
+func Command(dir, file, logCommand string) string {
+	var parts []string
+	if file != "" {
+		parts = append(parts, fmt.Sprintf("(cd %s && cat %s) 2>/dev/null", shellquote.Path(dir), shellquote.Path(file)))
+	}
+	parts = append(parts, "echo", "echo "+shellquote.Quote(marker))
+	if logCommand != "" {
+		parts = append(parts, "{ "+logCommand+"; }")
+	}
+	return strings.Join(parts, "; ") + "; true"
+}
+
+// Parse reads the metrics from the output of Command. Metrics from patterns
+// take precedence over those of the same name in the file. If the file isn't
+// valid JSON, the metrics from patterns are returned with the error.
+func Parse(output string, patterns []string) (map[string]float64, error) {
+	file, log, _ := strings.Cut(output, marker+"\n")
+	metrics := make(map[string]float64)
+	var fileErr error
+	if file = strings.TrimSpace(file); file != "" {
+		var v any
+		if err := json.Unmarshal([]byte(file), &v); err != nil {
+			fileErr = fmt.Errorf("parse results file: %w", err)
+		} else {
+			flatten("", v, metrics)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		matches := re.FindAllStringSubmatch(log, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		last := matches[len(matches)-1]
+		for i, name := range re.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			if value, ok := parseNumber(last[i]); ok {
+				metrics[name] = value
+			}
+		}
+	}
+	return metrics, fileErr
+}
+
+// flatten adds the numbers in v to metrics, named by their dotted paths
+func flatten(prefix string, v any, metrics map[string]float64) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flatten(join(key), child, metrics)
+		}
+	case []any:
+		for i, child := range v {
+			flatten(join(strconv.Itoa(i)), child, metrics)
+		}
+	case float64:
+		if prefix != "" {
+			metrics[prefix] = v
+		}
+	case string:
+		if value, ok := parseNumber(v); ok && prefix != "" {
+			metrics[prefix] = value
+		}
+	}
+}
+
+// parseNumber parses a metric, allowing a trailing percent sign
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "%")
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+// FormatValue formats a metric for display
+func FormatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', 6, 64)
+}
+
+// Format formats metrics as "name=value" pairs in name order
+func Format(metrics map[string]float64) string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + FormatValue(metrics[name])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// lowerIsBetter are words of metric names for which smaller values are
+// better
+var lowerIsBetter = []string{"loss", "err", "error", "perplexity", "ppl", "mse", "rmse", "mae", "wer", "cer", "time", "latency"}
+
+// LowerIsBetter guesses from a metric's name whether smaller values are
+// better, as for loss or error rate. Only whole words of the name count, so
+// "val_loss" and "eval.WER" are, but "answer_acc" isn't.
+func LowerIsBetter(metric string) bool {
+	for _, word := range nameWords(metric) {
+		word = strings.TrimRight(word, "0123456789")
+		if slices.Contains(lowerIsBetter, word) || slices.Contains(lowerIsBetter, strings.TrimSuffix(word, "s")) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameWords splits a metric name into its lowercase words, which are
+// separated by punctuation or start with a capital letter: "val_loss",
+// "eval.loss" and "valLoss" all end with "loss"
+func nameWords(name string) []string {
+	var words []string
+	var word []rune
+	afterLower := false
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range name {
+		switch {
+		case unicode.IsUpper(r):
+			if afterLower {
+				flush()
+			}
+			word = append(word, unicode.ToLower(r))
+			afterLower = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+			afterLower = true
+		default:
+			flush()
+			afterLower = false
+		}
+	}
+	flush()
+	return words
+}
+
+// Entry is a job's place on a leaderboard
+type Entry struct {
+	Rank  int // 1 for the best; jobs with equal values share a rank
+	JobID int64
+	Value float64
+}
+
+// Rank orders jobs, given by ID, by their value of metric, best first:
+// largest first, or smallest first if ascending. Jobs without the metric are
+// left out; jobs with equal values keep their order.
+func Rank(jobIDs []int64, metrics map[int64]map[string]float64, metric string, ascending bool) []Entry {
+	var entries []Entry
+	for _, id := range jobIDs {
+		if value, ok := metrics[id][metric]; ok {
+			entries = append(entries, Entry{JobID: id, Value: value})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if ascending {
+			return entries[i].Value < entries[j].Value
+		}
+		return entries[i].Value > entries[j].Value
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Value == entries[i-1].Value {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	return entries
+}
@@ -0,0 +1,108 @@
+package results
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestValidatePattern(t *testing.T) {
+	if err := ValidatePattern(`acc=(?P<accuracy>[0-9.]+)`); err != nil {
+		t.Errorf("ValidatePattern(named) = %v", err)
+	}
+	if err := ValidatePattern(`acc=([0-9.]+)`); err == nil {
+		t.Error("ValidatePattern(unnamed) succeeded")
+	}
+	if err := ValidatePattern(`(?P<x>`); err == nil {
+		t.Error("ValidatePattern(invalid) succeeded")
+	}
+}
+
+func TestCommandAndParse(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "results.json"), []byte(`{"accuracy": 0.5, "eval": {"loss": 1.25, "name": "x"}, "f1": "88%"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "job.log")
+	if err := os.WriteFile(log, []byte("epoch 1 acc=0.7 loss=0.9\nepoch 2 acc=0.81 loss=0.4\ndone\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns := []string{`acc=(?P<accuracy>[0-9.]+) loss=(?P<loss>[0-9.]+)`, `(?P<missing>never)`}
+	out, err := exec.Command("sh", "-c", Command(dir, "results.json", "cat "+log)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(string(out), patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"accuracy": 0.81, "loss": 0.4, "eval.loss": 1.25, "f1": 88}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	// A missing results file isn't an error
+	out, err = exec.Command("sh", "-c", Command(filepath.Join(dir, "gone"), "results.json", "")).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Parse(string(out), nil); err != nil || len(got) != 0 {
+		t.Errorf("Parse(missing file) = %v, %v", got, err)
+	}
+}
+
+func TestParseInvalidFile(t *testing.T) {
+	got, err := Parse("not json\n\n"+marker+"\nloss=2\n", []string{`loss=(?P<loss>\d)`})
+	if err == nil {
+		t.Error("Parse(invalid JSON) succeeded")
+	}
+	if got["loss"] != 2 {
+		t.Errorf("Parse(invalid JSON) = %v, want the log's metrics", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := Format(map[string]float64{"loss": 0.123456789, "accuracy": 0.9, "step": 12000})
+	if want := "accuracy=0.9 loss=0.123457 step=12000"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
+
+func TestLowerIsBetter(t *testing.T) {
+	for metric, want := range map[string]bool{
+		"accuracy": false, "val_loss": true, "eval.WER": true, "f1": false,
+		"answer_acc": false, "valLoss": true, "top1_errors": true, "loss2": true, "runtime": false,
+	} {
+		if got := LowerIsBetter(metric); got != want {
+			t.Errorf("LowerIsBetter(%q) = %v, want %v", metric, got, want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	jobs := []int64{1, 2, 3, 4}
+	metrics := map[int64]map[string]float64{
+		1: {"acc": 0.8},
+		2: {"acc": 0.9},
+		3: {"loss": 0.1},
+		4: {"acc": 0.8},
+	}
+	var got []string
+	for _, e := range Rank(jobs, metrics, "acc", false) {
+		got = append(got, fmt.Sprintf("%d:#%d=%g", e.Rank, e.JobID, e.Value))
+	}
+	if want := []string{"1:#2=0.9", "2:#1=0.8", "2:#4=0.8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Rank() = %v, want %v", got, want)
+	}
+	if entries := Rank(jobs, metrics, "acc", true); entries[0].JobID != 1 || entries[2].JobID != 2 {
+		t.Errorf("Rank(ascending) = %v", entries)
+	}
+}
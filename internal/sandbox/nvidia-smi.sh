@@ -0,0 +1,79 @@
+#!/bin/sh
+#
+# Simulated nvidia-smi for remote-jobs --sandbox hosts. Each host has
+# $REMOTE_JOBS_SANDBOX_GPUS GPUs (default 2). Each running job uses 20 GiB
+# of GPU (job ID mod GPU count) and keeps it busy.
+#
+
+gpus=${REMOTE_JOBS_SANDBOX_GPUS:-2}
+query=
+fields=
+units=yes
+for arg; do
+  case $arg in
+    --query-gpu=*) query=gpu fields=${arg#*=} ;;
+    --query-compute-apps=*) query=apps fields=${arg#*=} ;;
+    --format=*nounits*) units= ;;
+  esac
+done
+
+# jobs prints "pid gpu" for each job whose process is alive
+jobs() {
+  for f in "$HOME"/.cache/remote-jobs/logs/*.pid; do
+    [ -f "$f" ] || continue
+    pid=$(cat "$f" 2>/dev/null)
+    [ -n "$pid" ] && kill -0 "$pid" 2>/dev/null || continue
+    id=${f##*/}
+    id=${id%%-*}
+    echo "$pid $((id % gpus))"
+  done
+}
+
+unit() {
+  if [ -n "$units" ]; then echo "$1 $2"; else echo "$1"; fi
+}
+
+case $query in
+  gpu)
+    running=$(jobs)
+    i=0
+    while [ "$i" -lt "$gpus" ]; do
+      n=$(echo "$running" | awk -v g="$i" '$2 == g' | grep -c .)
+      line=
+      for field in $(echo "$fields" | tr ',' ' '); do
+        case $field in
+          index) v=$i ;;
+          name) v="NVIDIA A100-SXM4-80GB" ;;
+          uuid) v=GPU-sandbox-$i ;;
+          utilization.gpu) v=$(unit $((n > 0 ? 90 + i : 0)) %) ;;
+          memory.used) v=$(unit $((n * 20480)) MiB) ;;
+          memory.total) v=$(unit 81920 MiB) ;;
+          temperature.gpu) v=$((35 + (n > 0 ? 30 : 0))) ;;
+          clocks_throttle_reasons.*) v="Not Active" ;;
+          *) v="[N/A]" ;;
+        esac
+        line=${line:+$line, }$v
+      done
+      echo "$line"
+      i=$((i + 1))
+    done
+    ;;
+  apps)
+    jobs | while read -r pid gpu; do
+      line=
+      for field in $(echo "$fields" | tr ',' ' '); do
+        case $field in
+          pid) v=$pid ;;
+          gpu_uuid) v=GPU-sandbox-$gpu ;;
+          used_memory) v=$(unit 20480 MiB) ;;
+          *) v="[N/A]" ;;
+        esac
+        line=${line:+$line, }$v
+      done
+      echo "$line"
+    done
+    ;;
+  *)
+    echo "NVIDIA-SMI (remote-jobs sandbox): $gpus simulated GPU(s)"
+    ;;
+esac
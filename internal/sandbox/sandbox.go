@@ -0,0 +1,106 @@
+// Package sandbox sets up simulated hosts, so that remote-jobs can be tried,
+// demonstrated, and tested end to end without real hosts or SSH.
+//
+// In a sandbox every host name is valid. A host is a directory under
+// hosts/ in the sandbox directory that its commands run in, as their home
+// directory; jobs really run, on this machine, through the same wrapper,
+// queue runner, and log and status files as on a remote host. Hosts have
+// simulated GPUs. The sandbox has its own job database and config file, so
+// the user's jobs are untouched.
+package sandbox
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// EnvVar enables the sandbox when set to a non-empty value, and is set for
+// the commands remote-jobs starts, such as the tray's TUI, so that they stay
+// in it
+const EnvVar = "REMOTE_JOBS_SANDBOX"
+
+// DirEnvVar overrides the sandbox directory, e.g. for a test's own sandbox.
+// Enable sets it, so the commands remote-jobs starts use the same one.
+const DirEnvVar = "REMOTE_JOBS_SANDBOX_DIR"
+
+//go:embed nvidia-smi.sh
+var nvidiaSMI []byte
+
+// FromEnv reports whether the environment enables the sandbox
+func FromEnv() bool {
+	return os.Getenv(EnvVar) != ""
+}
+
+// Dir returns the sandbox directory: $REMOTE_JOBS_SANDBOX_DIR, or else a
+// directory of the user's own in the temporary directory, made with a name
+// that can't be guessed the first time and recorded in the user's cache
+// directory so that later commands find it
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnvVar); dir != "" {
+		return dir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	record := filepath.Join(cache, "remote-jobs", "sandbox-dir")
+	if data, err := os.ReadFile(record); err == nil {
+		if dir := strings.TrimSpace(string(data)); dir != "" {
+			if info, err := os.Stat(dir); err == nil && info.IsDir() {
+				return dir, nil
+			}
+		}
+	}
+	dir, err := os.MkdirTemp("", "remote-jobs-sandbox-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(record), 0755); err != nil {
+		return "", err
+	}
+	return dir, os.WriteFile(record, []byte(dir+"\n"), 0600)
+}
+
+// Enable simulates every host in dir, and keeps the job database and config
+// there. It reports whether the sandbox was created. Since the commands of
+// simulated hosts run on this machine as the user, a directory that others
+// can write to, or that belongs to someone else, isn't used.
+func Enable(dir string) (created bool, err error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		created = true
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return created, err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return created, fmt.Errorf("make %s private: %w", dir, err)
+	}
+	bin := filepath.Join(dir, "bin")
+	for _, d := range []string{bin, filepath.Join(dir, "hosts"), filepath.Join(dir, "tmux")} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return created, err
+		}
+	}
+	tool := filepath.Join(bin, "nvidia-smi")
+	if current, err := os.ReadFile(tool); err != nil || !bytes.Equal(current, nvidiaSMI) {
+		if err := os.WriteFile(tool, nvidiaSMI, 0755); err != nil {
+			return created, err
+		}
+	}
+
+	db.SetPath(filepath.Join(dir, "jobs.db"))
+	config.SetPath(filepath.Join(dir, "config.yaml"))
+	ssh.SetSandbox(dir)
+	if err := os.Setenv(DirEnvVar, dir); err != nil {
+		return created, err
+	}
+	return created, os.Setenv(EnvVar, "1")
+}
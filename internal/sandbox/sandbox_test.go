@@ -0,0 +1,77 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestEnable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sandbox")
+	t.Setenv(EnvVar, "")
+	t.Setenv(DirEnvVar, "")
+	t.Cleanup(func() {
+		ssh.SetSandbox("")
+		db.SetPath("")
+		config.SetPath("")
+	})
+
+	created, err := Enable(dir)
+	if err != nil || !created {
+		t.Fatalf("Enable() = %v, %v; want a new sandbox", created, err)
+	}
+	if !FromEnv() {
+		t.Error("Enable() should set the environment for child processes")
+	}
+	if created, err := Enable(dir); err != nil || created {
+		t.Errorf("Enable() again = %v, %v; want the existing sandbox", created, err)
+	}
+	if got := os.Getenv(DirEnvVar); got != dir {
+		t.Errorf("$%s = %q, want %q for child processes", DirEnvVar, got, dir)
+	}
+
+	stdout, stderr, err := ssh.Run("gpu1", `echo "$HOME"; nvidia-smi --query-gpu=index,name --format=csv,noheader`)
+	if err != nil {
+		t.Fatalf("Run() on a simulated host: %v: %s", err, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || lines[0] != ssh.SandboxHostDir("gpu1") || lines[1] != "0, NVIDIA A100-SXM4-80GB" {
+		t.Errorf("Run() on a simulated host = %q, want its home directory and two GPUs", lines)
+	}
+	if _, err := os.Stat(ssh.SandboxHostDir("gpu1")); err != nil {
+		t.Errorf("simulated host directory: %v", err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the cache and temporary directories don't come from $HOME and $TMPDIR")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv(DirEnvVar, "")
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(dir) != filepath.Clean(os.TempDir()) || filepath.Base(dir) == "remote-jobs-sandbox" {
+		t.Errorf("Dir() = %q, want a new directory in the temporary directory", dir)
+	}
+	if again, err := Dir(); err != nil || again != dir {
+		t.Errorf("Dir() again = %q, %v; want %q", again, err, dir)
+	}
+
+	t.Setenv(DirEnvVar, "/srv/sandbox")
+	if got, err := Dir(); err != nil || got != "/srv/sandbox" {
+		t.Errorf("Dir() with $%s = %q, %v", DirEnvVar, got, err)
+	}
+}
@@ -2,7 +2,7 @@
 #
 # Map jobs to GPUs by checking process trees
 # Input: space-separated list of "job_id:pid_file" pairs
-# Output: JOB_GPU:job_id:gpu_index:mem_mib lines
+# Output: JOB_GPU:job_id:gpu_index:mem_mib:util_pct lines
 #
 
 set -euo pipefail
@@ -19,6 +19,9 @@ fi
 # Get GPU index→UUID mapping
 GPU_UUIDS=$(nvidia-smi --query-gpu=index,uuid --format=csv,noheader 2>/dev/null || true)
 
+# Get GPU index→utilization mapping
+GPU_UTILS=$(nvidia-smi --query-gpu=index,utilization.gpu --format=csv,noheader,nounits 2>/dev/null || true)
+
 # Function to get all descendant PIDs recursively
 get_descendants() {
   local pid=$1
@@ -61,7 +64,8 @@ for arg in "$@"; do
         UUID_TRIMMED=$(echo "$UUID" | tr -d ' ')
         IDX=$(echo "$GPU_UUIDS" | grep "$UUID_TRIMMED" | cut -d, -f1 | tr -d ' ')
         MEM=$(echo "$MEM" | tr -d ' ')
-        echo "JOB_GPU:$JOB_ID:$IDX:$MEM"
+        UTIL=$(echo "$GPU_UTILS" | awk -F', *' -v i="$IDX" '$1 == i { print $2 }')
+        echo "JOB_GPU:$JOB_ID:$IDX:$MEM:$UTIL"
         break  # Found match for this GPU process, move to next
       fi
     done
@@ -0,0 +1,183 @@
+#!/bin/sh
+#
+# Describe a host for the TUI's hosts view as one JSON document: its
+# hardware, current load and memory, GPUs, queue status, running jobs with
+# the GPUs they use, the filesystems holding the given paths, and the
+# versions of the tools jobs depend on
+# Usage: host-info.sh QUEUE [PATH...]
+#
+
+QUEUE=${1:-default}
+[ $# -gt 0 ] && shift
+CACHE="$HOME/.cache/remote-jobs"
+
+# json_str prints its argument as a JSON string
+json_str() {
+  printf '"'
+  printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e 's/"/\\"/g' -e 's/	/\\t/g' |
+    tr -d '\000-\010\013-\037' | awk 'NR > 1 { printf "\\n" } { printf "%s", $0 }'
+  printf '"'
+}
+
+# json_int prints its argument if it is a whole number, and null otherwise
+json_int() {
+  case $1 in
+    '' | *[!0-9]*) printf null ;;
+    *) printf '%s' "$1" ;;
+  esac
+}
+
+# json_bool prints true if the command it runs succeeds, and false otherwise
+json_bool() {
+  if "$@" >/dev/null 2>&1; then printf true; else printf false; fi
+}
+
+printf '{"arch":%s' "$(json_str "$(uname -sm)")"
+printf ',"os":%s' "$(json_str "$(uname -r)")"
+printf ',"model":%s' "$(json_str "$(sysctl -n hw.model 2>/dev/null)")"
+printf ',"cpu_model":%s' "$(json_str "$( (sysctl -n machdep.cpu.brand_string 2>/dev/null ||
+  grep -m1 'model name' /proc/cpuinfo 2>/dev/null | cut -d: -f2) | sed 's/^[[:space:]]*//')")"
+printf ',"cpus":%s' "$(json_int "$(nproc 2>/dev/null || sysctl -n hw.ncpu 2>/dev/null)")"
+printf ',"load":%s' "$(json_str "$(uptime | sed 's/.*load average[s]*: //')")"
+
+# Memory: Linux has free; macOS reports the total with sysctl and the pages
+# in use (active + wired + compressed) with vm_stat
+if command -v free >/dev/null 2>&1; then
+  MEM_TOTAL=$(free -h | awk '/^Mem:/ { print $2 }')
+  MEM_USED=$(free -h | awk '/^Mem:/ { print $3 }')
+else
+  MEM_TOTAL=$(sysctl -n hw.memsize 2>/dev/null | awk '{ printf "%.0fG", $1/1024/1024/1024 }')
+  MEM_USED=
+  PAGE_SIZE=$(sysctl -n hw.pagesize 2>/dev/null)
+  VM_OUT=$(vm_stat 2>/dev/null)
+  PAGES_ACTIVE=$(echo "$VM_OUT" | awk '/Pages active/ { gsub(/\./, "", $3); print $3 }')
+  PAGES_WIRED=$(echo "$VM_OUT" | awk '/Pages wired/ { gsub(/\./, "", $4); print $4 }')
+  PAGES_COMP=$(echo "$VM_OUT" | awk '/Pages occupied by compressor/ { gsub(/\./, "", $5); print $5 }')
+  if [ -n "$PAGES_ACTIVE" ] && [ -n "$PAGE_SIZE" ]; then
+    MEM_USED="$(((PAGES_ACTIVE + ${PAGES_WIRED:-0} + ${PAGES_COMP:-0}) * PAGE_SIZE / 1024 / 1024 / 1024))G"
+  fi
+fi
+printf ',"mem_total":%s,"mem_used":%s' "$(json_str "$MEM_TOTAL")" "$(json_str "$MEM_USED")"
+
+# NVIDIA GPUs; [N/A] values read as 0
+printf ',"gpus":['
+nvidia-smi --query-gpu=index,name,temperature.gpu,utilization.gpu,memory.used,memory.total \
+  --format=csv,noheader,nounits 2>/dev/null | awk -F', *' 'NF >= 6 {
+    gsub(/\\/, "\\\\", $2); gsub(/"/, "\\\"", $2)
+    printf "%s{\"index\":%d,\"name\":\"%s\",\"temperature\":%d,\"utilization\":%d,\"mem_used_mib\":%d,\"mem_total_mib\":%d}",
+      sep, $1, $2, $3 + 0, $4 + 0, $5 + 0, $6 + 0
+    sep = ","
+  }'
+printf ']'
+
+# macOS GPUs, as system_profiler's "Key: value" lines
+printf ',"mac_gpus":['
+SEP=
+system_profiler SPDisplaysDataType 2>/dev/null | grep -E '(Chipset Model|VRAM|Total Number of Cores)' |
+  sed 's/^[[:space:]]*//' | while IFS= read -r line; do
+    printf '%s%s' "$SEP" "$(json_str "$line")"
+    SEP=,
+  done
+printf ']'
+
+# The queue's runner, which may be the shared one that serves all queues
+QUEUE_DIR="$CACHE/queue"
+printf ',"queue":{"runner":%s' "$(json_bool sh -c "tmux has-session -t 'rj-queue-$QUEUE' || tmux has-session -t rj-queues")"
+printf ',"current":%s' "$(json_str "$(head -1 "$QUEUE_DIR/$QUEUE.current" 2>/dev/null)")"
+printf ',"depth":%s' "$(json_int "$(cat "$QUEUE_DIR/$QUEUE.queue" 2>/dev/null | wc -l | tr -d ' ')")"
+printf ',"stop":%s}' "$(json_bool test -f "$QUEUE_DIR/$QUEUE.stop")"
+
+# Running jobs: those whose PID file names a live process, with the GPUs
+# used by the process or its descendants
+GPU_APPS=$(nvidia-smi --query-compute-apps=pid,gpu_uuid,used_memory --format=csv,noheader,nounits 2>/dev/null)
+GPU_UUIDS=$(nvidia-smi --query-gpu=index,uuid --format=csv,noheader 2>/dev/null)
+
+descendants() {
+  for child in $(pgrep -P "$1" 2>/dev/null); do
+    echo "$child"
+    descendants "$child"
+  done
+}
+
+printf ',"jobs":['
+JOB_SEP=
+for pid_file in "$CACHE"/logs/*.pid; do
+  [ -f "$pid_file" ] || continue
+  PID=$(cat "$pid_file" 2>/dev/null)
+  case $PID in '' | *[!0-9]*) continue ;; esac
+  kill -0 "$PID" 2>/dev/null || continue
+  NAME=$(basename "$pid_file" .pid)
+  JOB_ID=${NAME%%-*}
+  case $JOB_ID in '' | *[!0-9]*) continue ;; esac
+
+  printf '%s{"id":%s,"pid":%s,"gpus":[' "$JOB_SEP" "$JOB_ID" "$PID"
+  JOB_SEP=,
+  if [ -n "$GPU_APPS" ]; then
+    PIDS=" $PID $(descendants "$PID" | tr '\n' ' ') "
+    echo "$GPU_APPS" | awk -F', *' -v pids="$PIDS" -v uuids="$GPU_UUIDS" '
+      BEGIN {
+        n = split(uuids, lines, "\n")
+        for (i = 1; i <= n; i++) {
+          split(lines[i], f, ", *")
+          index_of[f[2]] = f[1]
+        }
+      }
+      index(pids, " " $1 " ") && ($2 in index_of) {
+        printf "%s{\"index\":%d,\"mem_mib\":%d}", sep, index_of[$2], $3 + 0
+        sep = ","
+      }'
+  fi
+  printf ']}'
+done
+printf ']'
+
+# Filesystems holding the given paths; a path that doesn't exist yet is
+# measured at its nearest existing parent. Linux's df -Pi prints
+# Inodes/IUsed/IFree; macOS's prints blocks first, then iused/ifree.
+printf ',"disks":['
+DISK_SEP=
+for path in "$@"; do
+  p=$path
+  while [ ! -e "$p" ] && [ "$p" != / ] && [ "$p" != . ]; do p=$(dirname "$p"); done
+  DF=$(df -Pk "$p" 2>/dev/null | awk 'NR == 2 { print $2, $4, $6 }')
+  [ -n "$DF" ] || { printf '%snull' "$DISK_SEP"; DISK_SEP=,; continue; }
+  TOTAL_KB=${DF%% *}
+  REST=${DF#* }
+  AVAIL_KB=${REST%% *}
+  MOUNT=${REST#* }
+  INODES=$(df -Pi "$p" 2>/dev/null | awk 'NR == 2 { if (NF >= 9) print $6 + $7, $7; else print $2, $4 }')
+  printf '%s{"mount":%s,"total_kb":%s,"avail_kb":%s' "$DISK_SEP" "$(json_str "$MOUNT")" \
+    "$(json_int "$TOTAL_KB")" "$(json_int "$AVAIL_KB")"
+  printf ',"total_inodes":%s,"free_inodes":%s}' "$(json_int "${INODES%% *}")" "$(json_int "${INODES#* }")"
+  DISK_SEP=,
+done
+printf ']'
+
+# Versions of the tools jobs depend on, for run --require python>=3.10.
+# They change rarely, and conda is slow to start, so they are probed at most
+# once an hour. Tools that aren't found have empty versions.
+tool_version() {
+  "$@" 2>&1 | grep -Eo '[0-9]+(\.[0-9]+)+' | head -1
+}
+TOOLS_FILE="$CACHE/tools.json"
+TOOLS=
+if [ -n "$(find "$TOOLS_FILE" -mmin -60 2>/dev/null)" ]; then
+  TOOLS=$(cat "$TOOLS_FILE" 2>/dev/null)
+fi
+if [ -z "$TOOLS" ]; then
+  PYTHON=$(tool_version python3 --version)
+  [ -n "$PYTHON" ] || PYTHON=$(tool_version python --version)
+  CONDA_BIN=$(command -v "${CONDA_EXE:-conda}" 2>/dev/null ||
+    ls "$HOME"/*conda*/bin/conda "$HOME"/miniforge*/bin/conda 2>/dev/null | head -1)
+  CONDA=
+  [ -n "$CONDA_BIN" ] && CONDA=$(tool_version "$CONDA_BIN" --version)
+  CUDA=$( (nvcc --version || /usr/local/cuda/bin/nvcc --version) 2>/dev/null |
+    sed -n 's/.*release \([0-9.]*\).*/\1/p' | head -1)
+  DRIVER=$(nvidia-smi --query-gpu=driver_version --format=csv,noheader 2>/dev/null | head -1)
+  TOOLS=$(printf '{"python":%s,"conda":%s,"cuda":%s,"cuda-driver":%s,"docker":%s,"git":%s}' \
+    "$(json_str "$PYTHON")" "$(json_str "$CONDA")" "$(json_str "$CUDA")" \
+    "$(json_str "$DRIVER")" "$(json_str "$(tool_version docker --version)")" \
+    "$(json_str "$(tool_version git --version)")")
+  mkdir -p "$CACHE" 2>/dev/null && printf '%s\n' "$TOOLS" >"$TOOLS_FILE" 2>/dev/null
+fi
+printf ',"tools":%s}\n' "$TOOLS"
@@ -14,6 +14,13 @@
 #   REMOTE_JOBS_SLACK_WEBHOOK       Slack webhook URL (required)
 #   REMOTE_JOBS_SLACK_NOTIFY        When to notify: "all" (default), "failures", "none"
 #   REMOTE_JOBS_SLACK_MIN_DURATION  Minimum job duration in seconds to trigger notification (default: 15)
+#   REMOTE_JOBS_SLACK_MESSAGE_OK_B64, REMOTE_JOBS_SLACK_MESSAGE_FAILED_B64
+#                                   Base64 messages to send instead of the default ones,
+#                                   rendered from slack_template in config.yaml, with
+#                                   placeholders such as @@HOST@@ for the job's fields
+#   REMOTE_JOBS_REDACT_B64          Base64 regular expressions, one per line, for credentials
+#                                   to redact from the command and log (needs perl; without
+#                                   it, a command or log that might hold them isn't sent)
 #
 
 set -euo pipefail
@@ -49,6 +56,7 @@ fi
 METADATA_FILE="${METADATA_FILE/#\~/$HOME}"
 
 duration_text=""
+duration_plain=""
 display_dir=""
 display_cmd=""
 description=""
@@ -61,12 +69,13 @@ if [ -f "$METADATA_FILE" ]; then
         minutes=$(((duration_secs % 3600) / 60))
         seconds=$((duration_secs % 60))
         if [ $hours -gt 0 ]; then
-            duration_text=" in ${hours}h ${minutes}m ${seconds}s"
+            duration_plain="${hours}h ${minutes}m ${seconds}s"
         elif [ $minutes -gt 0 ]; then
-            duration_text=" in ${minutes}m ${seconds}s"
+            duration_plain="${minutes}m ${seconds}s"
         else
-            duration_text=" in ${seconds}s"
+            duration_plain="${seconds}s"
         fi
+        duration_text=" in $duration_plain"
     fi
     # Extract display_dir and display_cmd (computed by Go code, with cd prefix parsed out)
     display_dir=$(grep '^display_dir=' "$METADATA_FILE" | cut -d= -f2- || true)
@@ -80,6 +89,7 @@ if [ -f "$METADATA_FILE" ]; then
     fi
     # Extract description
     description=$(grep '^description=' "$METADATA_FILE" | cut -d= -f2- || true)
+    job_id=$(grep '^job_id=' "$METADATA_FILE" | cut -d= -f2- || true)
 fi
 
 # Get notification settings (defaults: notify all, 15s minimum duration)
@@ -121,14 +131,17 @@ else
 fi
 
 # Escape a string for JSON
-# Handles: backslashes, double quotes, newlines, tabs
+# Handles: backslashes, double quotes, newlines, tabs, carriage returns. Other
+# control characters, such as the color codes of log output, are removed.
 # Note: Backticks don't need escaping in JSON
 json_escape() {
-    local s="$1"
+    local s
+    s=$(printf '%s' "$1" | tr -d '\000-\010\013\014\016-\037')
     s="${s//\\/\\\\}"        # Escape backslashes first
     s="${s//\"/\\\"}"        # Escape double quotes
     s="${s//$'\n'/\\n}"      # Convert newlines to \n
     s="${s//$'\t'/\\t}"      # Convert tabs to \t
+    s="${s//$'\r'/\\r}"      # Convert carriage returns to \r
     printf '%s' "$s"
 }
 
@@ -144,23 +157,76 @@ slack_code() {
     fi
 }
 
-# Build message using actual newlines (will be escaped for JSON later)
-# Format with description: :emoji: *Description* (job *rj-123* on `host`) completed successfully in Xm Ys.
-# Format without:          :emoji: Job *rj-123* on `host` completed successfully in Xm Ys.
-#                          Directory: `~/code/project`
-#                          Command: `python train.py`
-if [ -n "$description" ]; then
-    message="$status_emoji *$description* (job *$SESSION_NAME* on \`$HOST\`) $status_text$duration_text."
+# Redact credentials in $1 with the patterns in REMOTE_JOBS_REDACT_B64,
+# replacing the first group of a pattern that has one and the whole match
+# otherwise, as remote-jobs does locally. Without perl to apply them, prints
+# $2 instead.
+redact() {
+    if [ -z "${REMOTE_JOBS_REDACT_B64:-}" ] || [ -z "$1" ]; then
+        printf '%s' "$1"
+        return
+    fi
+    if ! command -v perl >/dev/null 2>&1; then
+        printf '%s' "$2"
+        return
+    fi
+    printf '%s' "$1" | REDACT_PATTERNS=$(printf '%s' "$REMOTE_JOBS_REDACT_B64" | base64 -d 2>/dev/null || true) perl -0777 -pe '
+        BEGIN { @res = grep { length($_) && eval { qr/$_/ } } split /\n/, $ENV{REDACT_PATTERNS} }
+        for my $re (@res) {
+            s{$re}{ my $m = $&; substr($m, $-[1] - $-[0], $+[1] - $-[1]) = "<redacted>" if defined $1; $m }ge;
+        }'
+}
+
+display_cmd=$(redact "$display_cmd" "")
+
+# A message rendered from slack_template when the job was started, once for
+# success and once for failure, has placeholders for the job's fields
+if [ "$EXIT_CODE" -eq 0 ]; then
+    template_b64="${REMOTE_JOBS_SLACK_MESSAGE_OK_B64:-}"
 else
-    message="$status_emoji Job *$SESSION_NAME* on \`$HOST\` $status_text$duration_text."
+    template_b64="${REMOTE_JOBS_SLACK_MESSAGE_FAILED_B64:-}"
 fi
-if [ -n "$display_dir" ]; then
-    dir_formatted=$(slack_code "$display_dir")
-    message="$message"$'\n'"Directory: $dir_formatted"
+template=""
+if [ -n "$template_b64" ]; then
+    template=$(printf '%s' "$template_b64" | base64 -d 2>/dev/null || true)
 fi
-if [ -n "$display_cmd" ]; then
-    cmd_formatted=$(slack_code "$display_cmd")
-    message="$message"$'\n'"Command: $cmd_formatted"
+
+if [ -n "$template" ]; then
+    log_tail=""
+    log_file="${METADATA_FILE%.meta}.log"
+    if [ -f "$log_file" ]; then
+        log_tail=$(tail -n 10 "$log_file" 2>/dev/null || true)
+        log_tail=$(redact "$log_tail" "(not shown: perl is needed to redact it)")
+    fi
+    message="$template"
+    message="${message//@@ID@@/"${job_id:-${SESSION_NAME#rj-}}"}"
+    message="${message//@@DESCRIPTION@@/"$description"}"
+    message="${message//@@HOST@@/"$HOST"}"
+    message="${message//@@COMMAND@@/"$display_cmd"}"
+    message="${message//@@DIR@@/"$display_dir"}"
+    message="${message//@@DURATION@@/"$duration_plain"}"
+    message="${message//@@EXIT_CODE@@/"$EXIT_CODE"}"
+    # Last, so that text in the log that looks like a placeholder is left alone
+    message="${message//@@LOG_TAIL@@/"$log_tail"}"
+else
+    # Build message using actual newlines (will be escaped for JSON later)
+    # Format with description: :emoji: *Description* (job *rj-123* on `host`) completed successfully in Xm Ys.
+    # Format without:          :emoji: Job *rj-123* on `host` completed successfully in Xm Ys.
+    #                          Directory: `~/code/project`
+    #                          Command: `python train.py`
+    if [ -n "$description" ]; then
+        message="$status_emoji *$description* (job *$SESSION_NAME* on \`$HOST\`) $status_text$duration_text."
+    else
+        message="$status_emoji Job *$SESSION_NAME* on \`$HOST\` $status_text$duration_text."
+    fi
+    if [ -n "$display_dir" ]; then
+        dir_formatted=$(slack_code "$display_dir")
+        message="$message"$'\n'"Directory: $dir_formatted"
+    fi
+    if [ -n "$display_cmd" ]; then
+        cmd_formatted=$(slack_code "$display_cmd")
+        message="$message"$'\n'"Command: $cmd_formatted"
+    fi
 fi
 
 # Escape the message for JSON
@@ -5,18 +5,51 @@
 #
 # Usage:
 #   queue-runner.sh <queue-name>
+#   queue-runner.sh --shared
+#
+# If the availability file exists, jobs are only started during its windows;
+# outside them they wait in the queue. Its lines are an optional tz=ZONE,
+# then one line per window: the days it starts on as `date +%w` digits, and
+# its start and end in minutes after midnight (an end at or before the start
+# is the next day), e.g. "12345 1200 480" for weeknights from 8pm to 8am.
+#
+# With --shared, one runner serves every queue that doesn't have its own
+# runner, interleaving them by weight (smooth weighted round-robin): a queue
+# with weight 3 gets three jobs started for each one from a queue with weight 1.
+# Weights are read from the weights file before each job; unlisted queues
+# have weight 1.
 #
 # Queue file format (one job per line, tab-separated):
-#   {job_id}\t{working_dir}\t{command}\t{description}\t{env_vars_b64}\t{after_job_id}
+#   {job_id}\t{working_dir}\t{command}\t{description}\t{env_vars_b64}\t{after_job_id}\t{guard_b64}\t{guard_policy}\t{settings_b64}
 #
 # env_vars_b64 is base64-encoded newline-separated VAR=value pairs (optional)
 # after_job_id is the job ID to wait for before starting (optional)
 #   Format: "ID" (wait for success) or "ID:any" (wait for completion)
+# guard_b64 is a base64-encoded shell command run in the working directory
+#   just before starting (optional). If it exits non-zero, guard_policy says
+#   what to do: "requeue" (default) puts the job back at the end of the queue,
+#   "skip" records it as skipped, with SKIPPED_EXIT_CODE as its exit code,
+#   and "fail" records it as failed. A guard still running after
+#   REMOTE_JOBS_GUARD_TIMEOUT seconds (default 60) is killed, and counts as
+#   false.
+# settings_b64 is base64-encoded newline-separated key=value settings
+#   recorded when the job was queued (optional): timeout and timeout_seconds,
+#   after which the job is killed; slack_notify, slack_min_duration, and
+#   slack_verbose, which when given replace the runner's REMOTE_JOBS_SLACK_*
+#   settings below for the job's notification; and pre_start_b64 and
+#   post_finish_b64, the host's base64-encoded hooks, run in the working
+#   directory before the job (which is skipped, taking the hook's exit code,
+#   if it fails) and after it exits, as the wrapper of a job started with run
+#   does.
 #
 # Files:
 #   ~/.cache/remote-jobs/queue/{queue-name}.queue    - Queue file (jobs waiting)
+#   ~/.cache/remote-jobs/queue/{queue-name}.queue.lock - Locked while a runner takes a job
 #   ~/.cache/remote-jobs/queue/{queue-name}.current  - Currently running job ID
 #   ~/.cache/remote-jobs/queue/{queue-name}.runner.pid - Runner process ID
+#   ~/.cache/remote-jobs/queue/weights               - Queue weights (name=weight per line)
+#   ~/.cache/remote-jobs/queue/.shared.runner.pid    - Shared runner process ID
+#   ~/.cache/remote-jobs/queue/availability          - Availability windows (optional)
 #   ~/.cache/remote-jobs/logs/{job_id}-{ts}.log      - Job output
 #   ~/.cache/remote-jobs/logs/{job_id}-{ts}.status   - Exit code
 #   ~/.cache/remote-jobs/logs/{job_id}-{ts}.meta     - Metadata
@@ -30,13 +63,184 @@
 
 set -euo pipefail
 
-QUEUE_NAME="${1:-default}"
 QUEUE_DIR="$HOME/.cache/remote-jobs/queue"
 LOG_DIR="$HOME/.cache/remote-jobs/logs"
-QUEUE_FILE="$QUEUE_DIR/${QUEUE_NAME}.queue"
-CURRENT_FILE="$QUEUE_DIR/${QUEUE_NAME}.current"
-PID_FILE="$QUEUE_DIR/${QUEUE_NAME}.runner.pid"
-NOTIFY_SCRIPT="/tmp/remote-jobs-notify-slack.sh"
+WEIGHTS_FILE="$QUEUE_DIR/weights"
+AVAILABILITY_FILE="$QUEUE_DIR/availability"
+NOTIFY_SCRIPT="$HOME/.cache/remote-jobs/scripts/notify-slack.sh"
+
+# Seconds a guard may run before it is killed and counts as false
+GUARD_TIMEOUT="${REMOTE_JOBS_GUARD_TIMEOUT:-60}"
+
+# Exit code recorded for a job skipped because its guard was false. No
+# process exits with it, and it isn't 0, so jobs queued after it are skipped.
+SKIPPED_EXIT_CODE=-1
+
+if [ "${1:-}" = "--shared" ]; then
+    SHARED=1
+    RUNNER_NAME=".shared"
+    QUEUE_NAME=""
+else
+    SHARED=0
+    RUNNER_NAME="${1:-default}"
+    QUEUE_NAME="$RUNNER_NAME"
+fi
+PID_FILE="$QUEUE_DIR/${RUNNER_NAME}.runner.pid"
+STOP_FILE="$QUEUE_DIR/${RUNNER_NAME}.stop"
+CURRENT_FILE=""
+
+# Fair-share credit per queue (parallel arrays; bash 3 has no associative arrays)
+CREDIT_NAMES=()
+CREDIT_VALUES=()
+
+# export_env_vars exports the VAR=value lines of a job's base64-encoded
+# env_vars_b64 field
+export_env_vars() {
+    [ -n "$1" ] || return 0
+    local env_line
+    while IFS= read -r env_line || [ -n "$env_line" ]; do
+        [ -n "$env_line" ] && export "$env_line"
+    done < <(echo "$1" | base64 -d 2>/dev/null)
+}
+
+# run_hook runs a job's pre-start or post-finish hook, bracketing its output
+# so that it can be told apart from the job's, and returns its exit status
+run_hook() {
+    local stage="$1" hook="$2" hook_exit
+    echo "=== $stage HOOK ==="
+    REMOTE_JOBS_JOB_ID="$job_id" REMOTE_JOBS_EXIT_CODE="${exit_code:-}" bash -c "$hook"
+    hook_exit=$?
+    echo "=== $stage HOOK END exit=$hook_exit ==="
+    return $hook_exit
+}
+
+# queue_weight prints the weight of a queue from the weights file (default 1)
+queue_weight() {
+    local w
+    w=$(awk -F= -v q="$1" '$1 == q { print $2; exit }' "$WEIGHTS_FILE" 2>/dev/null || true)
+    case "$w" in
+        ''|*[!0-9]*|0) echo 1 ;;
+        *) echo "$w" ;;
+    esac
+}
+
+# credit_index prints the index of a queue in CREDIT_NAMES, adding it if needed
+credit_index() {
+    local i
+    if [ ${#CREDIT_NAMES[@]} -eq 0 ]; then
+        echo 0
+        return
+    fi
+    for i in "${!CREDIT_NAMES[@]}"; do
+        if [ "${CREDIT_NAMES[$i]}" = "$1" ]; then
+            echo "$i"
+            return
+        fi
+    done
+    echo "${#CREDIT_NAMES[@]}"
+}
+
+# pick_queue sets QUEUE_NAME to the next queue to take a job from. Each
+# non-empty queue gains its weight in credit, the queue with the most credit
+# is chosen, and it pays back the total weight. Queues with their own runner
+# are left to it. Returns 1 if there is nothing to run.
+pick_queue() {
+    local f q w i c total=0 best="" best_credit=0
+    local names=() weights=()
+    for f in "$QUEUE_DIR"/*.queue; do
+        [ -s "$f" ] || continue
+        q=$(basename "$f" .queue)
+        if tmux has-session -t "rj-queue-$q" 2>/dev/null; then
+            continue
+        fi
+        w=$(queue_weight "$q")
+        names+=("$q")
+        weights+=("$w")
+        total=$((total + w))
+    done
+    [ ${#names[@]} -gt 0 ] || return 1
+
+    for i in "${!names[@]}"; do
+        q="${names[$i]}"
+        c=$(credit_index "$q")
+        CREDIT_NAMES[$c]="$q"
+        CREDIT_VALUES[$c]=$(( ${CREDIT_VALUES[$c]:-0} + ${weights[$i]} ))
+        if [ -z "$best" ] || [ "${CREDIT_VALUES[$c]}" -gt "$best_credit" ]; then
+            best="$c"
+            best_credit="${CREDIT_VALUES[$c]}"
+        fi
+    done
+
+    CREDIT_VALUES[$best]=$((best_credit - total))
+    QUEUE_NAME="${CREDIT_NAMES[$best]}"
+}
+
+# lock_queue takes an exclusive lock on QUEUE_FILE, so that a shared runner
+# and the queue's own runner never both take the same job or lose a requeued
+# one. Hosts without flock (such as macOS) run without the lock.
+lock_queue() {
+    exec 9>>"$QUEUE_FILE.lock"
+    if command -v flock >/dev/null 2>&1; then
+        flock 9
+    fi
+}
+
+# unlock_queue releases the lock taken by lock_queue
+unlock_queue() {
+    exec 9>&-
+}
+
+# pop_job sets job_line to the first line of QUEUE_FILE and removes it from
+# the file, or sets it to "" if the queue is empty
+pop_job() {
+    local temp_file
+    lock_queue
+    job_line=$(head -n 1 "$QUEUE_FILE" 2>/dev/null || true)
+    if [ -n "$job_line" ]; then
+        # Write the rest beside the queue file, so that mv replaces it atomically
+        temp_file=$(mktemp "$QUEUE_DIR/.${QUEUE_NAME}.XXXXXX")
+        tail -n +2 "$QUEUE_FILE" > "$temp_file" 2>/dev/null || true
+        mv "$temp_file" "$QUEUE_FILE"
+    fi
+    unlock_queue
+}
+
+# requeue_job puts job_line back at the end of QUEUE_FILE
+requeue_job() {
+    lock_queue
+    echo "$job_line" >> "$QUEUE_FILE"
+    unlock_queue
+}
+
+# host_available succeeds if the availability file is absent or one of its
+# windows is open now
+host_available() {
+    [ -s "$AVAILABILITY_FILE" ] || return 0
+    local tz now_fields today hour minute now yesterday days start end
+    tz=$(sed -n 's/^tz=//p' "$AVAILABILITY_FILE")
+    if [ -n "$tz" ]; then
+        now_fields=$(TZ="$tz" date '+%w %H %M')
+    else
+        now_fields=$(date '+%w %H %M')
+    fi
+    read -r today hour minute <<< "$now_fields"
+    now=$((10#$hour * 60 + 10#$minute))
+    yesterday=$(( (today + 6) % 7 ))
+    while read -r days start end; do
+        case "$days" in
+            ''|tz=*) continue ;;
+        esac
+        if [ "$start" -lt "$end" ]; then
+            if [[ "$days" == *"$today"* ]] && [ "$now" -ge "$start" ] && [ "$now" -lt "$end" ]; then
+                return 0
+            fi
+        elif { [[ "$days" == *"$today"* ]] && [ "$now" -ge "$start" ]; } ||
+             { [[ "$days" == *"$yesterday"* ]] && [ "$now" -lt "$end" ]; }; then
+            return 0
+        fi
+    done < "$AVAILABILITY_FILE"
+    return 1
+}
 
 # Create directories
 mkdir -p "$QUEUE_DIR" "$LOG_DIR"
@@ -46,32 +250,63 @@ echo $$ > "$PID_FILE"
 
 # Cleanup on exit
 cleanup() {
-    rm -f "$PID_FILE" "$CURRENT_FILE"
+    rm -f "$PID_FILE"
+    if [ -n "$CURRENT_FILE" ]; then
+        rm -f "$CURRENT_FILE"
+    fi
 }
 trap cleanup EXIT
 
-echo "Queue runner started for queue: $QUEUE_NAME"
-echo "Queue file: $QUEUE_FILE"
+if [ "$SHARED" = 1 ]; then
+    echo "Shared queue runner started for all queues"
+    echo "Weights file: $WEIGHTS_FILE"
+else
+    echo "Queue runner started for queue: $QUEUE_NAME"
+    echo "Queue file: $QUEUE_DIR/${QUEUE_NAME}.queue"
+fi
 echo "PID: $$"
 echo ""
 
 # Main loop
+WAITING_FOR_WINDOW=0
 while true; do
     # Check for STOP signal
-    if [ -f "$QUEUE_DIR/${QUEUE_NAME}.stop" ]; then
+    if [ -f "$STOP_FILE" ]; then
         echo "STOP signal received, exiting after current job..."
-        rm -f "$QUEUE_DIR/${QUEUE_NAME}.stop"
+        rm -f "$STOP_FILE"
         break
     fi
 
+    # Outside the host's availability windows, leave jobs in the queue
+    if ! host_available; then
+        if [ "$WAITING_FOR_WINDOW" = 0 ]; then
+            echo "Outside the host's availability windows; waiting to start jobs ($(date))"
+            WAITING_FOR_WINDOW=1
+        fi
+        sleep 30
+        continue
+    fi
+    if [ "$WAITING_FOR_WINDOW" = 1 ]; then
+        echo "Availability window open; starting jobs ($(date))"
+        WAITING_FOR_WINDOW=0
+    fi
+
+    # Choose which queue to take the next job from
+    if [ "$SHARED" = 1 ] && ! pick_queue; then
+        sleep 5
+        continue
+    fi
+    QUEUE_FILE="$QUEUE_DIR/${QUEUE_NAME}.queue"
+    CURRENT_FILE="$QUEUE_DIR/${QUEUE_NAME}.current"
+
     # Check if queue file exists
     if [ ! -f "$QUEUE_FILE" ]; then
         sleep 5
         continue
     fi
 
-    # Read first line from queue (atomic read and remove)
-    job_line=$(head -n 1 "$QUEUE_FILE" 2>/dev/null || true)
+    # Take the first job from the queue
+    pop_job
 
     if [ -z "$job_line" ]; then
         # Queue is empty, wait and check again
@@ -79,13 +314,11 @@ while true; do
         continue
     fi
 
-    # Remove first line from queue file (atomic operation)
-    temp_file=$(mktemp)
-    tail -n +2 "$QUEUE_FILE" > "$temp_file" 2>/dev/null || true
-    mv "$temp_file" "$QUEUE_FILE"
-
-    # Parse job line (tab-separated: job_id, working_dir, command, description, env_vars_b64, after_job_id)
-    IFS=$'\t' read -r job_id working_dir command description env_vars_b64 after_job_id <<< "$job_line"
+    # Parse job line (tab-separated: job_id, working_dir, command, description,
+    # env_vars_b64, after_job_id, guard_b64, guard_policy, settings_b64). Tabs
+    # are whitespace to read, which would merge empty fields, so split on \x1f
+    # instead.
+    IFS=$'\x1f' read -r job_id working_dir command description env_vars_b64 after_job_id guard_b64 guard_policy settings_b64 <<< "${job_line//$'\t'/$'\x1f'}"
 
     if [ -z "$job_id" ] || [ -z "$working_dir" ] || [ -z "$command" ]; then
         echo "Invalid job line, skipping: $job_line"
@@ -107,17 +340,21 @@ while true; do
         if [ -z "$dep_status_file" ]; then
             # Dependency job not completed yet - put job back in queue
             echo "Job $job_id: waiting for job $dep_id to complete (not finished yet)"
-            echo "$job_line" >> "$QUEUE_FILE"
+            requeue_job
             sleep 10  # Avoid busy loop
             continue
         fi
 
         dep_exit=$(cat "$dep_status_file")
         if [ "$dep_mode" = "success" ] && [ "$dep_exit" != "0" ]; then
-            echo "Job $job_id: skipped, dependency job $dep_id failed with exit code $dep_exit"
+            dep_outcome="failed with exit code $dep_exit"
+            if [ "$dep_exit" = "$SKIPPED_EXIT_CODE" ]; then
+                dep_outcome="was skipped"
+            fi
+            echo "Job $job_id: skipped, dependency job $dep_id $dep_outcome"
             # Write failure status for this job
             timestamp=$(date +%Y%m%d-%H%M%S)
-            echo "SKIPPED: dependency job $dep_id failed with exit code $dep_exit" > "$LOG_DIR/${job_id}-${timestamp}.log"
+            echo "SKIPPED: dependency job $dep_id $dep_outcome" > "$LOG_DIR/${job_id}-${timestamp}.log"
             echo "1" > "$LOG_DIR/${job_id}-${timestamp}.status"
             continue
         fi
@@ -129,6 +366,83 @@ while true; do
         fi
     fi
 
+    # Check guard if specified
+    if [ -n "$guard_b64" ]; then
+        guard=$(echo "$guard_b64" | base64 -d 2>/dev/null || true)
+        guard_start=$(date +%s)
+        set +e
+        (
+            cd "${working_dir/#\~/$HOME}" 2>/dev/null || exit 1
+            export_env_vars "$env_vars_b64"
+            exec bash -c "$guard"
+        ) > /dev/null 2>&1 &
+        guard_pid=$!
+        # A guard that hangs, as on a stale network mount, counts as false
+        # rather than holding up the queue
+        (
+            sleep "$GUARD_TIMEOUT"
+            pkill -P "$guard_pid" 2>/dev/null
+            kill "$guard_pid" 2>/dev/null
+        ) &
+        guard_timeout_pid=$!
+        wait "$guard_pid"
+        guard_exit=$?
+        pkill -P "$guard_timeout_pid" 2>/dev/null
+        kill "$guard_timeout_pid" 2>/dev/null
+        guard_state="was false (exit $guard_exit)"
+        if [ "$guard_exit" -ne 0 ] && [ $(($(date +%s) - guard_start)) -ge "$GUARD_TIMEOUT" ]; then
+            guard_state="timed out after ${GUARD_TIMEOUT}s"
+        fi
+        set -e
+
+        if [ "$guard_exit" -ne 0 ]; then
+            timestamp=$(date +%Y%m%d-%H%M%S)
+            case "${guard_policy:-requeue}" in
+                skip)
+                    echo "Job $job_id: skipped, guard $guard_state: $guard"
+                    echo "SKIPPED: guard $guard_state: $guard" > "$LOG_DIR/${job_id}-${timestamp}.log"
+                    echo "$SKIPPED_EXIT_CODE" > "$LOG_DIR/${job_id}-${timestamp}.status"
+                    ;;
+                fail)
+                    echo "Job $job_id: failed, guard $guard_state: $guard"
+                    echo "FAILED: guard $guard_state: $guard" > "$LOG_DIR/${job_id}-${timestamp}.log"
+                    echo "1" > "$LOG_DIR/${job_id}-${timestamp}.status"
+                    ;;
+                *)
+                    echo "Job $job_id: guard $guard_state, requeueing: $guard"
+                    requeue_job
+                    sleep 10  # Avoid busy loop
+                    ;;
+            esac
+            continue
+        fi
+    fi
+
+    # Read the settings recorded when the job was queued
+    timeout=""
+    timeout_seconds=""
+    slack_notify="${REMOTE_JOBS_SLACK_NOTIFY:-}"
+    slack_min_duration="${REMOTE_JOBS_SLACK_MIN_DURATION:-}"
+    slack_verbose="${REMOTE_JOBS_SLACK_VERBOSE:-}"
+    pre_start=""
+    post_finish=""
+    exit_code=""
+    if [ -n "$settings_b64" ]; then
+        # Split at the first "=", keeping the base64 padding of the hooks
+        while IFS= read -r setting || [ -n "$setting" ]; do
+            value="${setting#*=}"
+            case "${setting%%=*}" in
+                timeout) timeout="$value" ;;
+                timeout_seconds) timeout_seconds="$value" ;;
+                slack_notify) slack_notify="$value" ;;
+                slack_min_duration) slack_min_duration="$value" ;;
+                slack_verbose) slack_verbose="$value" ;;
+                pre_start_b64) pre_start=$(echo "$value" | base64 -d 2>/dev/null || true) ;;
+                post_finish_b64) post_finish=$(echo "$value" | base64 -d 2>/dev/null || true) ;;
+            esac
+        done < <(echo "$settings_b64" | base64 -d 2>/dev/null)
+    fi
+
     # Generate timestamp for file names
     timestamp=$(date +%Y%m%d-%H%M%S)
     start_time=$(date +%s)
@@ -143,7 +457,7 @@ while true; do
     echo "$job_id" > "$CURRENT_FILE"
 
     echo "=========================================="
-    echo "Starting job $job_id"
+    echo "Starting job $job_id (queue: $QUEUE_NAME)"
     echo "  Working dir: $working_dir"
     echo "  Command: $command"
     [ -n "$description" ] && echo "  Description: $description"
@@ -170,6 +484,7 @@ while true; do
         if [ -n "$env_vars_b64" ]; then
             echo "env: $(echo "$env_vars_b64" | base64 -d 2>/dev/null | tr '\n' ' ')"
         fi
+        [ -n "$timeout" ] && echo "timeout: $timeout"
         echo "==="
     } > "$log_file"
 
@@ -185,10 +500,11 @@ while true; do
         }
 
         # Apply environment variables if present (base64 encoded, newline-separated)
-        if [ -n "$env_vars_b64" ]; then
-            while IFS= read -r env_line; do
-                [ -n "$env_line" ] && export "$env_line"
-            done < <(echo "$env_vars_b64" | base64 -d 2>/dev/null)
+        export_env_vars "$env_vars_b64"
+
+        # A pre-start hook gates the job: if it fails, its exit code becomes the job's
+        if [ -n "$pre_start" ]; then
+            run_hook PRE-START "$pre_start" || exit
         fi
 
         # Record PID before exec - after exec, this becomes the command's PID
@@ -198,16 +514,43 @@ while true; do
         exec bash -c "$command"
     ) >> "$log_file" 2>&1 &
     cmd_pid=$!
+
+    # Kill the job if it outlasts its timeout, as run --timeout does
+    timeout_pid=""
+    if [ -n "$timeout_seconds" ]; then
+        (
+            sleep "$timeout_seconds"
+            if kill -0 "$cmd_pid" 2>/dev/null; then
+                echo "=== TIMEOUT after $timeout ===" >> "$log_file"
+                kill "$cmd_pid" 2>/dev/null
+            fi
+        ) &
+        timeout_pid=$!
+    fi
+
     wait $cmd_pid
     exit_code=$?
+    if [ -n "$timeout_pid" ]; then
+        pkill -P "$timeout_pid" 2>/dev/null
+        kill "$timeout_pid" 2>/dev/null
+    fi
     set -e
 
     end_time=$(date +%s)
     duration=$((end_time - start_time))
 
-    # Write status and end marker
-    echo "$exit_code" > "$status_file"
+    # Write end marker, run the post-finish hook, and write status
     echo "=== END exit=$exit_code $(date) ===" >> "$log_file"
+    if [ -n "$post_finish" ]; then
+        set +e
+        (
+            cd "$eval_working_dir" 2>/dev/null || exit 1
+            export_env_vars "$env_vars_b64"
+            run_hook POST-FINISH "$post_finish"
+        ) >> "$log_file" 2>&1
+        set -e
+    fi
+    echo "$exit_code" > "$status_file"
 
     # Format duration
     hours=$((duration / 3600))
@@ -232,7 +575,10 @@ while true; do
 
     # Send Slack notification if script exists
     if [ -x "$NOTIFY_SCRIPT" ]; then
-        "$NOTIFY_SCRIPT" "rj-$job_id" "$exit_code" "$(hostname)" "$meta_file" 2>/dev/null || true
+        REMOTE_JOBS_SLACK_NOTIFY="$slack_notify" \
+        REMOTE_JOBS_SLACK_MIN_DURATION="$slack_min_duration" \
+        REMOTE_JOBS_SLACK_VERBOSE="$slack_verbose" \
+            "$NOTIFY_SCRIPT" "rj-$job_id" "$exit_code" "$(hostname)" "$meta_file" 2>/dev/null || true
     fi
 
     echo ""
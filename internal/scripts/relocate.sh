@@ -0,0 +1,74 @@
+#!/bin/sh
+#
+# Move remote-jobs' files on this host (job logs, queues, and deployed
+# scripts) to CACHE_HOME/remote-jobs, leaving ~/.cache/remote-jobs as a
+# symbolic link to it, so that every path under it keeps working. Without
+# CACHE_HOME, they move to ${XDG_CACHE_HOME:-~/.cache}/remote-jobs.
+# Prints the directory they are in.
+# Usage: relocate.sh [CACHE_HOME]
+#
+
+set -e
+
+link="$HOME/.cache/remote-jobs"
+cache_home=${1:-${XDG_CACHE_HOME:-$HOME/.cache}}
+case $cache_home in
+  "~") cache_home=$HOME ;;
+  "~/"*) cache_home="$HOME/${cache_home#"~/"}" ;;
+esac
+case $cache_home in
+  /*) ;;
+  *) echo "cache_home must be an absolute path or start with ~/: $cache_home" >&2; exit 1 ;;
+esac
+target="${cache_home%/}/remote-jobs"
+
+# Where the files are now: the directory the link leads to, or the one at
+# its path. A link whose directory is gone has none.
+current=""
+if [ -L "$link" ]; then
+  current=$(cd -P "$link" 2>/dev/null && pwd) || current=""
+elif [ -d "$link" ]; then
+  current=$link
+fi
+
+# Another account on this host may use the same cache_home; sharing its
+# directory would mix the two accounts' queues
+if [ -d "$target" ] && [ ! -O "$target" ]; then
+  echo "$target belongs to another user; give this host a cache_home of its own, such as ~/work or /scratch/$(id -un)" >&2
+  exit 1
+fi
+
+case $target in
+  "$current"/* | "$link"/*)
+    echo "$target is inside $link" >&2
+    exit 1
+    ;;
+esac
+
+if [ "$target" = "$link" ]; then
+  # Back to the default: replace the link with a directory
+  if [ -L "$link" ]; then
+    rm "$link"
+  fi
+  mkdir -p "$link"
+  resolved=$(cd -P "$link" && pwd)
+else
+  mkdir -p "$target"
+  resolved=$(cd -P "$target" && pwd)
+fi
+
+if [ -n "$current" ] && [ "$current" != "$resolved" ]; then
+  # Copy, merging into anything already there, and only then remove the
+  # originals, so that a failed copy loses nothing
+  cp -Rp "$current"/. "$resolved"/
+  rm -rf "$current"
+fi
+
+if [ "$target" != "$link" ]; then
+  mkdir -p "$HOME/.cache"
+  if [ -d "$link" ] && [ ! -L "$link" ]; then
+    rmdir "$link" 2>/dev/null || true
+  fi
+  ln -sfn "$resolved" "$link"
+fi
+echo "$resolved"
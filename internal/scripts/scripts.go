@@ -10,3 +10,9 @@ var NotifySlackScript []byte
 
 //go:embed gpu-job-mapping.sh
 var GPUJobMappingScript []byte
+
+//go:embed host-info.sh
+var HostInfoScript []byte
+
+//go:embed relocate.sh
+var RelocateScript []byte
@@ -0,0 +1,243 @@
+package scripts
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/redact"
+)
+
+// TestQueueRunnerWeights runs the shared queue runner over two queues and
+// checks the order it takes their jobs in
+func TestQueueRunnerWeights(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	tests := []struct {
+		name    string
+		weights string
+		jobs    map[string]int
+		want    string
+	}{
+		{"equal", "", map[string]int{"a": 3, "b": 3}, "a b a b a b"},
+		{"weighted", "a=3\n", map[string]int{"a": 6, "b": 2}, "a a b a a a b a"},
+		{"invalid weight", "a=0\nb=x\n", map[string]int{"a": 2, "b": 2}, "a b a b"},
+		{"empty queue", "b=3\n", map[string]int{"a": 3}, "a a a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			home := t.TempDir()
+			queueDir := filepath.Join(home, ".cache", "remote-jobs", "queue")
+			if err := os.MkdirAll(queueDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(queueDir, "weights"), []byte(tt.weights), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			total, id := 0, 0
+			for _, queue := range []string{"a", "b"} {
+				var lines strings.Builder
+				for i := 0; i < tt.jobs[queue]; i++ {
+					id++
+					fmt.Fprintf(&lines, "%d\t%s\ttrue\t\n", id, home)
+				}
+				total += tt.jobs[queue]
+				if err := os.WriteFile(filepath.Join(queueDir, queue+".queue"), []byte(lines.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := runSharedQueueRunner(t, bash, home, total); got != tt.want {
+				t.Errorf("queues taken in order %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+var startedPattern = regexp.MustCompile(`Starting job \d+ \(queue: (\w+)\)`)
+
+// runSharedQueueRunner runs the shared queue runner with home as $HOME until
+// it has started n jobs, and returns the queues they came from, in order
+func runSharedQueueRunner(t *testing.T, bash, home string, n int) string {
+	t.Helper()
+	script := filepath.Join(home, "queue-runner.sh")
+	if err := os.WriteFile(script, QueueRunnerScript, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// No queue has a runner of its own
+	bin := filepath.Join(home, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "tmux"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out lockedBuffer
+	cmd := exec.Command(bash, script, "--shared")
+	cmd.Env = append(os.Environ(), "HOME="+home, "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// The runner's sleep outlives it, holding its output open
+	cmd.WaitDelay = 100 * time.Millisecond
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		matches := startedPattern.FindAllStringSubmatch(out.String(), -1)
+		if len(matches) >= n {
+			queues := make([]string, len(matches))
+			for i, m := range matches {
+				queues[i] = m[1]
+			}
+			return strings.Join(queues, " ")
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runner started %d of %d jobs:\n%s", len(matches), n, out.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestQueueRunnerGuardTimeout checks that a guard that hangs is killed and
+// counts as false, rather than holding up the queue
+func TestQueueRunnerGuardTimeout(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	home := t.TempDir()
+	queueDir := filepath.Join(home, ".cache", "remote-jobs", "queue")
+	if err := os.MkdirAll(queueDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	guard := base64.StdEncoding.EncodeToString([]byte("sleep 30"))
+	queue := fmt.Sprintf("1\t%s\ttrue\t\t\t\t%s\tskip\t\n2\t%s\ttrue\t\n", home, guard, home)
+	if err := os.WriteFile(filepath.Join(queueDir, "default.queue"), []byte(queue), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(home, "queue-runner.sh")
+	if err := os.WriteFile(script, QueueRunnerScript, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var out lockedBuffer
+	cmd := exec.Command(bash, script, "default")
+	cmd.Env = append(os.Environ(), "HOME="+home, "REMOTE_JOBS_GUARD_TIMEOUT=1")
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = 100 * time.Millisecond
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	deadline := time.Now().Add(20 * time.Second)
+	for !strings.Contains(out.String(), "Starting job 2") {
+		if time.Now().After(deadline) {
+			t.Fatalf("runner didn't reach the job after the hanging guard:\n%s", out.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if want := "Job 1: skipped, guard timed out after 1s"; !strings.Contains(out.String(), want) {
+		t.Errorf("runner output doesn't report %q:\n%s", want, out.String())
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that a running command can write to while
+// the test reads it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestNotifySlackRedacts runs the notify script with a template that shows
+// a job's command and log, and checks that the credentials in them aren't
+// sent
+func TestNotifySlackRedacts(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	if _, err := exec.LookPath("perl"); err != nil {
+		t.Skip("perl not available")
+	}
+	dir := t.TempDir()
+	writeFile := func(path, content string, perm os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(dir, "notify-slack.sh")
+	writeFile(script, string(NotifySlackScript), 0o755)
+	// curl records the message instead of sending it
+	bin := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(filepath.Join(bin, "curl"), "#!/bin/sh\nwhile [ $# -gt 0 ]; do\n  if [ \"$1\" = --data ]; then printf '%s' \"$2\" > \"$SENT\"; fi\n  shift\ndone\n", 0o755)
+	meta := filepath.Join(dir, "42-1000.meta")
+	writeFile(meta, "job_id=42\nstart_time=1000\ndisplay_dir=~/code\ndisplay_cmd=python train.py --api-key abc123456789\n", 0o644)
+	writeFile(filepath.Join(dir, "42-1000.log"), "step 1\nWANDB_API_KEY=wandbsecret123\n", 0o644)
+
+	sent := filepath.Join(dir, "sent.json")
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	cmd := exec.Command(bash, script, "rj-42", "1", "cool30", meta)
+	cmd.Env = append(os.Environ(),
+		"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"SENT="+sent,
+		"REMOTE_JOBS_SLACK_WEBHOOK=https://example.com/hook",
+		"REMOTE_JOBS_SLACK_MESSAGE_FAILED_B64="+encode("@@COMMAND@@\n@@LOG_TAIL@@"),
+		"REMOTE_JOBS_REDACT_B64="+encode(strings.Join(redact.Builtin, "\n")),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("notify script: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"abc123456789", "wandbsecret123"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("message %s contains the credential %s", data, secret)
+		}
+	}
+	if want := "python train.py --api-key <redacted>"; !strings.Contains(string(data), want) {
+		t.Errorf("message %s doesn't contain %q", data, want)
+	}
+}
@@ -0,0 +1,250 @@
+// Package secrets keeps values such as API keys out of command lines, the
+// job database, and the files written on remote hosts.
+//
+// Values are kept in the operating system's keychain: the login keychain on
+// macOS (through the security tool) and the Secret Service keyring, such as
+// GNOME Keyring or KWallet, on Linux (through secret-tool). A secret that
+// isn't in the keychain is read from the local environment variable of the
+// same name, so one exported in the local shell also works.
+//
+// Only a secret's name is recorded with a job. Its value reaches the remote
+// host through ssh's standard input, in a file only the job's user can read,
+// which the job's wrapper reads and deletes before the job starts.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// Service is the keychain service that secrets are stored under
+const Service = "remote-jobs"
+
+// Placeholder replaces secret values in displayed text
+const Placeholder = "<secret>"
+
+// ErrNotFound is returned when a secret is in neither the keychain nor the
+// local environment
+var ErrNotFound = errors.New("secret not found")
+
+// keychain is the command-line interface to a platform's keychain. Each
+// function returns the command's arguments; set reads the value from stdin
+// unless setArgs includes it.
+type keychain struct {
+	tool     string
+	getArgs  func(name string) []string
+	setArgs  func(name, value string) []string
+	setStdin bool
+	delArgs  func(name string) []string
+}
+
+// keychainFor returns the keychain interface for an operating system, or nil
+// if there is none
+func keychainFor(goos string) *keychain {
+	switch goos {
+	case "darwin":
+		return &keychain{
+			tool: "security",
+			getArgs: func(name string) []string {
+				return []string{"find-generic-password", "-s", Service, "-a", name, "-w"}
+			},
+			// security has no way to read the value from stdin that works
+			// without a terminal, so it is briefly visible to local ps
+			setArgs: func(name, value string) []string {
+				return []string{"add-generic-password", "-U", "-s", Service, "-a", name, "-w", value}
+			},
+			delArgs: func(name string) []string {
+				return []string{"delete-generic-password", "-s", Service, "-a", name}
+			},
+		}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return &keychain{
+			tool: "secret-tool",
+			getArgs: func(name string) []string {
+				return []string{"lookup", "service", Service, "name", name}
+			},
+			setArgs: func(name, value string) []string {
+				return []string{"store", "--label", Service + " " + name, "service", Service, "name", name}
+			},
+			setStdin: true,
+			delArgs: func(name string) []string {
+				return []string{"clear", "service", Service, "name", name}
+			},
+		}
+	}
+	return nil
+}
+
+// available returns the platform's keychain, or an error saying why there is
+// none
+func available() (*keychain, error) {
+	k := keychainFor(runtime.GOOS)
+	if k == nil {
+		return nil, fmt.Errorf("no supported keychain on %s", runtime.GOOS)
+	}
+	if _, err := exec.LookPath(k.tool); err != nil {
+		if k.tool == "secret-tool" {
+			return nil, fmt.Errorf("secret-tool not found (install libsecret-tools)")
+		}
+		return nil, fmt.Errorf("%s not found", k.tool)
+	}
+	return k, nil
+}
+
+// ValidateName checks that name can be used as an environment variable
+func ValidateName(name string) error {
+	if !shellquote.IsName(name) {
+		return fmt.Errorf("invalid secret name %q (must be an environment variable name)", name)
+	}
+	return nil
+}
+
+// Set stores a secret's value in the keychain
+func Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	k, err := available()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(k.tool, k.setArgs(name, value)...)
+	if k.setStdin {
+		cmd.Stdin = strings.NewReader(value)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("store %s in keychain: %s", name, commandError(out, err))
+	}
+	return nil
+}
+
+// Delete removes a secret from the keychain
+func Delete(name string) error {
+	k, err := available()
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command(k.tool, k.delArgs(name)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("remove %s from keychain: %s", name, commandError(out, err))
+	}
+	return nil
+}
+
+// Lookup returns a secret's value from the keychain, or else from the local
+// environment variable of the same name
+func Lookup(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	if k, err := available(); err == nil {
+		var stdout bytes.Buffer
+		cmd := exec.Command(k.tool, k.getArgs(name)...)
+		cmd.Stdout = &stdout
+		if cmd.Run() == nil && stdout.Len() > 0 {
+			return strings.TrimSuffix(stdout.String(), "\n"), nil
+		}
+	}
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %s (store it with: remote-jobs secret set %s)", ErrNotFound, name, name)
+}
+
+// LookupAll returns the values of the named secrets
+func LookupAll(names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		value, err := Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// Values returns the values of the named secrets that can be found, for
+// redacting output; missing secrets are skipped
+func Values(names []string) []string {
+	var values []string
+	for _, name := range names {
+		if value, err := Lookup(name); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// EnvFile returns a shell script that exports each secret, for the job's
+// wrapper to source
+func EnvFile(values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s\n", shellquote.Assignment(name+"="+values[name]))
+	}
+	return b.String()
+}
+
+// Redact replaces each of values in text with Placeholder
+func Redact(text string, values []string) string {
+	// Longer values first, so one that contains another is replaced whole
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			sorted = append(sorted, v)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, v := range sorted {
+		text = strings.ReplaceAll(text, v, Placeholder)
+	}
+	return text
+}
+
+// RedactEnv returns "NAME=<secret>" for each secret name, for display
+// alongside a job's environment variables
+func RedactEnv(names []string) []string {
+	vars := make([]string, len(names))
+	for i, name := range names {
+		vars[i] = name + "=" + Placeholder
+	}
+	return vars
+}
+
+// ReadValue reads a secret's value from r: the first line, without its line
+// ending
+func ReadValue(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	value := strings.TrimRight(line, "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("empty secret value")
+	}
+	return value, nil
+}
+
+// commandError describes a failed keychain command by its output, or its
+// exit status if it printed nothing
+func commandError(out []byte, err error) string {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
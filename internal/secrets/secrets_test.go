@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKeychainFor(t *testing.T) {
+	mac := keychainFor("darwin")
+	if got := mac.getArgs("WANDB_API_KEY"); !slices.Equal(got, []string{"find-generic-password", "-s", "remote-jobs", "-a", "WANDB_API_KEY", "-w"}) {
+		t.Errorf("darwin get = %q", got)
+	}
+	linux := keychainFor("linux")
+	if got := linux.setArgs("WANDB_API_KEY", "abc"); slices.Contains(got, "abc") || !linux.setStdin {
+		t.Errorf("linux set = %q, stdin %v; want the value on stdin", got, linux.setStdin)
+	}
+	if keychainFor("windows") != nil {
+		t.Error("keychainFor(windows) != nil")
+	}
+}
+
+func TestLookupFallsBackToEnvironment(t *testing.T) {
+	t.Setenv("PATH", "")
+	t.Setenv("RJ_TEST_TOKEN", "s3cret")
+	if got, err := Lookup("RJ_TEST_TOKEN"); err != nil || got != "s3cret" {
+		t.Errorf("Lookup() = %q, %v", got, err)
+	}
+	if _, err := Lookup("RJ_TEST_MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := Lookup("NOT-A-NAME"); err == nil {
+		t.Error("Lookup(invalid name) succeeded")
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	got := EnvFile(map[string]string{"B": "it's", "A": "x y"})
+	want := "export A='x y'\nexport B='it'\\''s'\n"
+	if got != want {
+		t.Errorf("EnvFile() = %q, want %q", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := Redact("key=abc123 again abc123456", []string{"abc123", "abc123456", ""})
+	if want := "key=<secret> again <secret>"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestReadValue(t *testing.T) {
+	if got, err := ReadValue(strings.NewReader("abc\r\nrest")); err != nil || got != "abc" {
+		t.Errorf("ReadValue() = %q, %v", got, err)
+	}
+	if _, err := ReadValue(strings.NewReader("\n")); err == nil {
+		t.Error("ReadValue(empty) succeeded")
+	}
+}
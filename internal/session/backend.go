@@ -0,0 +1,74 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// Backends that keep a job running on the remote host after ssh disconnects
+const (
+	BackendAuto  = "auto"  // tmux if the host has it, otherwise nohup
+	BackendTmux  = "tmux"  // A detached tmux session per job
+	BackendNohup = "nohup" // A background process in its own session, for hosts without tmux
+)
+
+// noTmuxMarker is the line TmuxProbeCommand prints when tmux is missing
+const noTmuxMarker = "no-tmux"
+
+// TmuxProbeCommand prints a marker line if tmux isn't installed on the host.
+// It always succeeds, so it can be chained with other commands.
+const TmuxProbeCommand = "{ command -v tmux >/dev/null 2>&1 || echo " + noTmuxMarker + "; }"
+
+// ValidateBackend checks that name is auto, tmux, or nohup
+func ValidateBackend(name string) error {
+	switch name {
+	case BackendAuto, BackendTmux, BackendNohup:
+		return nil
+	}
+	return fmt.Errorf("invalid backend %q (must be auto, tmux, or nohup)", name)
+}
+
+// HasTmux reports whether tmux is installed, given the output of a command
+// that included TmuxProbeCommand
+func HasTmux(probeOutput string) bool {
+	for _, line := range strings.Split(probeOutput, "\n") {
+		if strings.TrimSpace(line) == noTmuxMarker {
+			return false
+		}
+	}
+	return true
+}
+
+// ChooseBackend resolves the requested backend ("" means auto) for a host
+func ChooseBackend(requested string, hasTmux bool) string {
+	if requested != "" && requested != BackendAuto {
+		return requested
+	}
+	if hasTmux {
+		return BackendTmux
+	}
+	return BackendNohup
+}
+
+// BuildNohupLaunchCommand returns the remote command that starts a wrapped
+// job in the background, for hosts without tmux. The wrapper runs in a new
+// session (when setsid is available) with hangups ignored, so it survives
+// the ssh connection closing; it follows the same PID, status, and log file
+// protocol as a tmux job, so the job is probed and killed through those files.
+func BuildNohupLaunchCommand(workingDir, wrappedCommand string, createDir bool) string {
+	return workingDirCheck(workingDir, createDir) + fmt.Sprintf(
+		"{ rj_setsid=; command -v setsid >/dev/null 2>&1 && rj_setsid=setsid; "+
+			"$rj_setsid nohup bash -c %s >/dev/null 2>&1 </dev/null & }",
+		shellquote.Quote(wrappedCommand))
+}
+
+// LaunchCommand returns the remote command that starts a wrapped job with
+// the given backend ("" means tmux)
+func LaunchCommand(backend, tmuxSession, workingDir, wrappedCommand string, createDir bool) string {
+	if backend == BackendNohup {
+		return BuildNohupLaunchCommand(workingDir, wrappedCommand, createDir)
+	}
+	return BuildLaunchCommand(tmuxSession, workingDir, wrappedCommand, createDir)
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChooseBackend(t *testing.T) {
+	tests := []struct {
+		requested string
+		output    string
+		want      string
+	}{
+		{BackendAuto, "git-revision abc\n1732400000 +0000\n", BackendTmux},
+		{"", "no-tmux\n1732400000 +0000\n", BackendNohup},
+		{BackendAuto, "no-tmux\n", BackendNohup},
+		{BackendTmux, "no-tmux\n", BackendTmux},
+		{BackendNohup, "", BackendNohup},
+	}
+	for _, tt := range tests {
+		if got := ChooseBackend(tt.requested, HasTmux(tt.output)); got != tt.want {
+			t.Errorf("ChooseBackend(%q, HasTmux(%q)) = %q, want %q", tt.requested, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestValidateBackend(t *testing.T) {
+	for _, name := range []string{BackendAuto, BackendTmux, BackendNohup} {
+		if err := ValidateBackend(name); err != nil {
+			t.Errorf("ValidateBackend(%q) = %v, want nil", name, err)
+		}
+	}
+	if err := ValidateBackend("screen"); err == nil {
+		t.Error("ValidateBackend(\"screen\") = nil, want error")
+	}
+}
+
+func TestBuildLaunchPlan_Nohup(t *testing.T) {
+	plan := BuildLaunchPlan(LaunchSpec{JobID: 42, StartTime: 1732400000, Host: "box", WorkingDir: "~/code", Command: "make", Backend: BackendNohup})
+	if want := BuildNohupLaunchCommand("~/code", plan.WrapperCommand, false); plan.LaunchCommand != want {
+		t.Errorf("LaunchCommand = %q, want %q", plan.LaunchCommand, want)
+	}
+	if strings.Contains(plan.LaunchCommand, "tmux") {
+		t.Errorf("LaunchCommand uses tmux: %q", plan.LaunchCommand)
+	}
+}
+
+// TestBuildNohupLaunchCommand_Detaches runs the launch command under bash and
+// checks that it returns while the job is still running, and that the job
+// then completes through the status file
+func TestBuildNohupLaunchCommand_Detaches(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	tmp := t.TempDir()
+	params := WrapperCommandParams{
+		JobID:      9,
+		WorkingDir: tmp,
+		Command:    "sleep 1; echo done",
+		LogFile:    filepath.Join(tmp, "9.log"),
+		StatusFile: filepath.Join(tmp, "9.status"),
+		PidFile:    filepath.Join(tmp, "9.pid"),
+	}
+
+	start := time.Now()
+	launch := BuildNohupLaunchCommand(tmp, BuildWrapperCommand(params), false)
+	if out, err := exec.Command(bash, "-c", launch).CombinedOutput(); err != nil {
+		t.Fatalf("launch failed: %v\n%s", err, out)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("launch command waited for the job (%v)", elapsed)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status, err := os.ReadFile(params.StatusFile)
+		if err == nil && strings.TrimSpace(string(status)) != "" {
+			if strings.TrimSpace(string(status)) != "0" {
+				t.Errorf("exit status = %q, want 0", status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never wrote its status file")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if log, _ := os.ReadFile(params.LogFile); !strings.Contains(string(log), "done\n") {
+		t.Errorf("log missing job output:\n%s", log)
+	}
+}
@@ -0,0 +1,183 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// LaunchSpec describes a job to be started on a remote host
+type LaunchSpec struct {
+	JobID       int64
+	StartTime   int64
+	Host        string
+	WorkingDir  string
+	Command     string
+	Description string
+	EnvVars     []string
+	Timeout     string
+	NotifyCmd   string
+	PreStart    string
+	PostFinish  string
+	CreateDir   bool         // Create the working directory instead of failing when it is missing
+	Script      *Script      // Uploaded script the command runs, recorded in the metadata
+	Backend     string       // BackendNohup, or tmux for anything else
+	GPUs        []int        // GPUs assigned with run --gpus, recorded in the metadata
+	Secrets     bool         // The job is given secrets through SecretsFile
+	SplitStderr bool         // Also write the job's stderr to ErrFile
+	Staged      []StagedFile // Input files to copy to the host before the job starts
+}
+
+// LaunchPlan holds the file paths and remote commands used to start a job.
+// It is built without side effects, so it can be printed for a dry run.
+type LaunchPlan struct {
+	JobID           int64
+	Host            string
+	Backend         string
+	TmuxSession     string
+	LogFile         string
+	ErrFile         string // Also receives the job's stderr; empty unless it is split
+	StatusFile      string
+	MetadataFile    string
+	PidFile         string
+	Metadata        string
+	MkdirCommand    string       // Creates the log directory, checks for tmux, and prints the host's clock
+	ScriptCommand   string       // Uploads the job script; empty for a command line
+	Staged          []StagedFile // With each destination resolved against the working directory
+	StageCommand    string       // Creates the directories of the staged files; empty without any
+	SecretsFile     string       // Passes the job's secrets to the wrapper; empty without secrets
+	MetadataCommand string       // Writes the metadata file
+	WrapperCommand  string       // Runs inside the tmux session or background process
+	LaunchCommand   string       // Checks the working directory and starts the wrapper, first writing SecretsFile from stdin if set
+}
+
+// BuildLaunchPlan computes the paths and remote commands for starting a job
+func BuildLaunchPlan(spec LaunchSpec) LaunchPlan {
+	plan := LaunchPlan{
+		JobID:        spec.JobID,
+		Host:         spec.Host,
+		Backend:      spec.Backend,
+		TmuxSession:  TmuxSessionName(spec.JobID),
+		LogFile:      LogFile(spec.JobID, spec.StartTime),
+		StatusFile:   StatusFile(spec.JobID, spec.StartTime),
+		MetadataFile: MetadataFile(spec.JobID, spec.StartTime),
+		PidFile:      PidFile(spec.JobID, spec.StartTime),
+		Metadata:     FormatMetadata(spec.JobID, spec.WorkingDir, spec.Command, spec.Host, spec.Description, spec.StartTime),
+		MkdirCommand: fmt.Sprintf("mkdir -p %s && %s && date '+%%s %%z'", LogDir, TmuxProbeCommand),
+	}
+	if spec.Script != nil {
+		plan.Metadata += "\n" + spec.Script.MetadataLines()
+		plan.ScriptCommand = spec.Script.UploadCommand()
+	}
+	if len(spec.GPUs) > 0 {
+		plan.Metadata += "\n" + gpusMetadataLine(spec.GPUs)
+	}
+	if len(spec.Staged) > 0 {
+		for _, f := range spec.Staged {
+			plan.Staged = append(plan.Staged, StagedFile{Local: f.Local, Remote: f.RemotePath(spec.WorkingDir)})
+		}
+		plan.StageCommand = StageDirsCommand(spec.Staged, spec.WorkingDir, spec.CreateDir)
+		plan.Metadata += "\n" + stageMetadataLines(plan.Staged)
+	}
+	if spec.SplitStderr {
+		plan.ErrFile = ErrFile(spec.JobID, spec.StartTime)
+		plan.Metadata += fmt.Sprintf("\nlog_file=%s\nstderr_file=%s", plan.LogFile, plan.ErrFile)
+	}
+	plan.MetadataCommand = shellquote.WriteFile(plan.MetadataFile, plan.Metadata)
+	if spec.Secrets {
+		plan.SecretsFile = SecretsFile(spec.JobID, spec.StartTime)
+	}
+
+	plan.WrapperCommand = BuildWrapperCommand(WrapperCommandParams{
+		JobID:       spec.JobID,
+		WorkingDir:  spec.WorkingDir,
+		Command:     spec.Command,
+		LogFile:     plan.LogFile,
+		ErrFile:     plan.ErrFile,
+		StatusFile:  plan.StatusFile,
+		PidFile:     plan.PidFile,
+		NotifyCmd:   spec.NotifyCmd,
+		Timeout:     spec.Timeout,
+		EnvVars:     spec.EnvVars,
+		SecretsFile: plan.SecretsFile,
+		PreStart:    spec.PreStart,
+		PostFinish:  spec.PostFinish,
+	})
+	if plan.Backend != BackendNohup {
+		plan.Backend = BackendTmux
+	}
+	plan.LaunchCommand = LaunchCommand(plan.Backend, plan.TmuxSession, spec.WorkingDir, plan.WrapperCommand, spec.CreateDir)
+	if plan.SecretsFile != "" {
+		plan.LaunchCommand = SecretsLaunchCommand(plan.SecretsFile, plan.LaunchCommand)
+	}
+
+	return plan
+}
+
+// Format renders the plan as readable text, with each remote command shown
+// exactly as it would be passed to ssh
+func (p LaunchPlan) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host:          %s\n", p.Host)
+	if p.Backend == BackendNohup {
+		fmt.Fprintf(&b, "Backend:       nohup\n")
+	} else {
+		fmt.Fprintf(&b, "Tmux session:  %s\n", p.TmuxSession)
+	}
+	fmt.Fprintf(&b, "Log file:      %s\n", p.LogFile)
+	if p.ErrFile != "" {
+		fmt.Fprintf(&b, "Stderr file:   %s\n", p.ErrFile)
+	}
+	fmt.Fprintf(&b, "Status file:   %s\n", p.StatusFile)
+	fmt.Fprintf(&b, "Metadata file: %s\n", p.MetadataFile)
+	fmt.Fprintf(&b, "PID file:      %s\n", p.PidFile)
+	fmt.Fprintf(&b, "\nMetadata:\n%s\n", indent(p.Metadata))
+	fmt.Fprintf(&b, "\nRemote commands:\n")
+	commands := []string{p.MkdirCommand}
+	if p.ScriptCommand != "" {
+		commands = append(commands, p.ScriptCommand)
+	}
+	if p.StageCommand != "" {
+		commands = append(commands, p.StageCommand)
+	}
+	var copies []string
+	for _, f := range p.Staged {
+		copies = append(copies, fmt.Sprintf("scp %s %s:%s", shellquote.Quote(f.Local), p.Host, f.Remote))
+	}
+	commands = append(commands, p.MetadataCommand)
+	if p.SecretsFile != "" {
+		commands = append(commands, p.LaunchCommand+" < (secret values)")
+	} else {
+		commands = append(commands, p.LaunchCommand)
+	}
+	for i, c := range commands {
+		if ssh.IsLocal(p.Host) {
+			fmt.Fprintf(&b, "  %d. %s\n", i+1, c)
+		} else {
+			fmt.Fprintf(&b, "  %d. ssh %s %s\n", i+1, p.Host, c)
+		}
+		if c == p.StageCommand {
+			for _, c := range copies {
+				fmt.Fprintf(&b, "     %s\n", c)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nWrapper script (run by bash -c under %s):\n%s\n", p.Backend, indent(p.WrapperCommand))
+	return b.String()
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+// gpusMetadataLine records a job's assigned GPUs, e.g. "gpus=0,2"
+func gpusMetadataLine(gpus []int) string {
+	fields := make([]string, len(gpus))
+	for i, index := range gpus {
+		fields[i] = strconv.Itoa(index)
+	}
+	return "gpus=" + strings.Join(fields, ",")
+}
@@ -0,0 +1,49 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// QueueDir is the directory for queue files on remote hosts
+const QueueDir = "~/.cache/remote-jobs/queue"
+
+// QueueFile returns the path of a queue's file on a remote host. An empty
+// name is the default queue.
+func QueueFile(queueName string) string {
+	if queueName == "" {
+		queueName = "default"
+	}
+	return fmt.Sprintf("%s/%s.queue", QueueDir, queueName)
+}
+
+// LockedQueueCommand returns a shell command that runs command while holding
+// the lock that queue runners take jobs from the queue under: flock on the
+// queue file's .lock file, where flock is installed. Every change to a queue
+// file goes through it, so that no change is lost to a runner rewriting the
+// file. The command fails without running command if the queue directory
+// doesn't exist.
+func LockedQueueCommand(queueName, command string) string {
+	return fmt.Sprintf("{ if command -v flock >/dev/null 2>&1; then flock 9; fi; %s; } 9>>%s",
+		command, shellquote.Path(QueueFile(queueName)+".lock"))
+}
+
+// AppendToQueueCommand returns a shell command that adds line to the end of
+// a queue, creating the queue directory if needed
+func AppendToQueueCommand(queueName, line string) string {
+	return "mkdir -p " + QueueDir + " && " + LockedQueueCommand(queueName,
+		fmt.Sprintf("echo %s >> %s", shellquote.Quote(line), shellquote.Path(QueueFile(queueName))))
+}
+
+// RemoveFromQueueCommand returns a shell command that removes a job's line
+// from a queue. It prints "removed", or "missing" if the job isn't in the
+// queue, as when its runner has already taken it.
+func RemoveFromQueueCommand(jobID int64, queueName string) string {
+	file := shellquote.Path(QueueFile(queueName))
+	tmp := shellquote.Path(QueueFile(queueName) + ".tmp")
+	remove := fmt.Sprintf("if grep -q '^%d\t' %s 2>/dev/null; then "+
+		"grep -v '^%d\t' %s > %s; mv %s %s && echo removed; else echo missing; fi",
+		jobID, file, jobID, file, tmp, tmp, file)
+	return fmt.Sprintf("if [ -f %s ]; then %s; else echo missing; fi", file, LockedQueueCommand(queueName, remove))
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	home := t.TempDir()
+	run := func(command string) string {
+		t.Helper()
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", command, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	queueFile := filepath.Join(home, ".cache", "remote-jobs", "queue", "gpu.queue")
+	wantQueue := func(want string) {
+		t.Helper()
+		got, err := os.ReadFile(queueFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("queue file = %q, want %q", got, want)
+		}
+	}
+
+	if got := run(RemoveFromQueueCommand(1, "gpu")); got != "missing" {
+		t.Errorf("removing from a queue that doesn't exist printed %q, want missing", got)
+	}
+	run(AppendToQueueCommand("gpu", "1\t~/code\techo 'it''s'"))
+	run(AppendToQueueCommand("gpu", "12\t~/code\tmake"))
+	run(AppendToQueueCommand("gpu", "2\t~/code\tmake"))
+	wantQueue("1\t~/code\techo 'it''s'\n12\t~/code\tmake\n2\t~/code\tmake\n")
+
+	if got := run(RemoveFromQueueCommand(1, "gpu")); got != "removed" {
+		t.Errorf("removing job 1 printed %q, want removed", got)
+	}
+	wantQueue("12\t~/code\tmake\n2\t~/code\tmake\n")
+	if got := run(RemoveFromQueueCommand(1, "gpu")); got != "missing" {
+		t.Errorf("removing job 1 again printed %q, want missing", got)
+	}
+	run(RemoveFromQueueCommand(12, "gpu"))
+	run(RemoveFromQueueCommand(2, "gpu"))
+	wantQueue("")
+
+	// Changes wait for a runner that holds the lock
+	if _, err := exec.LookPath("flock"); err != nil {
+		return
+	}
+	holder := exec.Command("flock", queueFile+".lock", "sleep", "0.5")
+	if err := holder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Wait()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	run(AppendToQueueCommand("gpu", "3\t~/code\tmake"))
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("append took %v while the queue was locked, want it to wait", elapsed)
+	}
+	wantQueue("3\t~/code\tmake\n")
+}
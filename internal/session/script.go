@@ -0,0 +1,67 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// ScriptDir holds scripts uploaded by run --script or read from stdin. Files
+// are named by content hash, so uploading the same script again is harmless
+// and a job's script stays available for restarts.
+const ScriptDir = "~/.cache/remote-jobs/scripts"
+
+// Script is a job script that is uploaded to the host and run in place of a
+// command line
+type Script struct {
+	Name    string // Local file name, or "stdin"
+	Content string
+}
+
+// Hash returns the SHA-256 of the script content, in hex
+func (s Script) Hash() string {
+	sum := sha256.Sum256([]byte(s.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+var unsafeScriptChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// RemotePath returns where the script is stored on the host
+func (s Script) RemotePath() string {
+	base := unsafeScriptChars.ReplaceAllString(filepath.Base(s.Name), "_")
+	if base == "" || base == "." || base == "_" {
+		base = "script"
+	}
+	return ScriptDir + "/" + s.Hash()[:12] + "-" + base
+}
+
+// UploadCommand returns a remote command that writes the script and makes it executable
+func (s Script) UploadCommand() string {
+	path := s.RemotePath()
+	return "mkdir -p " + ScriptDir + " && " +
+		shellquote.WriteFile(path, strings.TrimSuffix(s.Content, "\n")) +
+		" && chmod +x " + shellquote.Path(path)
+}
+
+// Command returns the job command that runs the uploaded script with args (a
+// shell fragment, may be empty). Scripts with a #! line run directly; others
+// run with bash.
+func (s Script) Command(args string) string {
+	command := s.RemotePath()
+	if !strings.HasPrefix(s.Content, "#!") {
+		command = "bash " + command
+	}
+	if args = strings.TrimSpace(args); args != "" {
+		command += " " + args
+	}
+	return command
+}
+
+// MetadataLines returns the provenance lines recorded in the job's metadata file
+func (s Script) MetadataLines() string {
+	return "script=" + s.Name + "\nscript_sha256=" + s.Hash()
+}
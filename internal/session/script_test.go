@@ -0,0 +1,64 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestScriptCommand(t *testing.T) {
+	s := Script{Name: "my train.sh", Content: "python train.py\npython eval.py\n"}
+
+	path := s.RemotePath()
+	if !strings.HasPrefix(path, ScriptDir+"/"+s.Hash()[:12]+"-") || !strings.HasSuffix(path, "-my_train.sh") {
+		t.Errorf("RemotePath() = %q", path)
+	}
+	if got, want := s.Command("--epochs 3"), "bash "+path+" --epochs 3"; got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+
+	py := Script{Name: "run.py", Content: "#!/usr/bin/env python3\nprint('hi')\n"}
+	if got := py.Command(""); got != py.RemotePath() {
+		t.Errorf("Command() for a #! script = %q, want the path alone", got)
+	}
+
+	if got := (Script{Name: "stdin", Content: "x"}).MetadataLines(); !strings.HasPrefix(got, "script=stdin\nscript_sha256=") {
+		t.Errorf("MetadataLines() = %q", got)
+	}
+}
+
+func TestScriptUploadCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	home := t.TempDir()
+	s := Script{Name: "job.sh", Content: "echo \"it's $((1 + 2))\"\necho done\n"}
+
+	upload := exec.Command("sh", "-c", s.UploadCommand())
+	upload.Env = append(os.Environ(), "HOME="+home)
+	if out, err := upload.CombinedOutput(); err != nil {
+		t.Fatalf("upload: %v\n%s", err, out)
+	}
+
+	local := filepath.Join(home, strings.TrimPrefix(s.RemotePath(), "~/"))
+	content, err := os.ReadFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != s.Content {
+		t.Errorf("uploaded content = %q, want %q", content, s.Content)
+	}
+
+	run := exec.Command("sh", "-c", s.Command(""))
+	run.Env = append(os.Environ(), "HOME="+home)
+	out, err := run.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	if string(out) != "it's 3\ndone\n" {
+		t.Errorf("script output = %q", out)
+	}
+}
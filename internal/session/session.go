@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
 )
 
 // LogDir is the directory for job logs on remote hosts
@@ -29,17 +31,46 @@ func DefaultWorkingDir() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return RemoteDir(cwd)
+}
 
+// RemoteDir converts a local directory to a remote-friendly path by making it
+// relative to the home directory. Directories outside the home directory are
+// used as is, except on Windows, where they have no remote equivalent.
+func RemoteDir(dir string) (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
+	return remoteDir(dir, home, os.PathSeparator)
+}
 
-	if strings.HasPrefix(cwd, home) {
-		return "~" + cwd[len(home):], nil
+// remoteDir converts dir, a local path using the separator sep, to a remote
+// path relative to home. Windows paths (sep is a backslash) are compared
+// case-insensitively and converted to forward slashes.
+func remoteDir(dir, home string, sep byte) (string, error) {
+	windows := sep == '\\'
+	home = strings.TrimRight(home, string(sep))
+	hasPrefix := func(prefix string) bool {
+		if windows {
+			return len(dir) >= len(prefix) && strings.EqualFold(dir[:len(prefix)], prefix)
+		}
+		return strings.HasPrefix(dir, prefix)
 	}
 
-	return cwd, nil
+	switch {
+	case hasPrefix(home) && len(dir) == len(home):
+		return "~", nil
+	case hasPrefix(home + string(sep)):
+		rest := dir[len(home)+1:]
+		if windows {
+			rest = strings.ReplaceAll(rest, `\`, "/")
+		}
+		return "~/" + rest, nil
+	case windows:
+		return "", fmt.Errorf("%s is outside your home directory, so it has no remote equivalent (use -C to set the remote directory)", dir)
+	}
+	return dir, nil
 }
 
 // LogFile returns the log file path for a job
@@ -47,6 +78,12 @@ func LogFile(jobID int64, startTime int64) string {
 	return fmt.Sprintf("%s/%s.log", LogDir, FileBasename(jobID, startTime))
 }
 
+// ErrFile returns the path of the file that also receives a job's stderr,
+// for jobs started with their streams split
+func ErrFile(jobID int64, startTime int64) string {
+	return fmt.Sprintf("%s/%s.err", LogDir, FileBasename(jobID, startTime))
+}
+
 // StatusFile returns the status file path for a job
 func StatusFile(jobID int64, startTime int64) string {
 	return fmt.Sprintf("%s/%s.status", LogDir, FileBasename(jobID, startTime))
@@ -62,6 +99,21 @@ func PidFile(jobID int64, startTime int64) string {
 	return fmt.Sprintf("%s/%s.pid", LogDir, FileBasename(jobID, startTime))
 }
 
+// SecretsFile returns the path of the file that passes a job's secrets to
+// its wrapper, which deletes it once read
+func SecretsFile(jobID int64, startTime int64) string {
+	return fmt.Sprintf("%s/%s.secrets", LogDir, FileBasename(jobID, startTime))
+}
+
+// SecretsLaunchCommand returns a launch command that first writes its stdin
+// to a secrets file readable only by the user, for the wrapper to read, and
+// deletes the file again if the job can't be started. Writing and launching
+// in one command keeps a failed launch from leaving the secrets on the host.
+func SecretsLaunchCommand(secretsFile, launchCommand string) string {
+	return fmt.Sprintf("(umask 077 && cat > %s) && { (%s) || { rj_status=$?; rm -f %s; exit $rj_status; }; }",
+		secretsFile, launchCommand, secretsFile)
+}
+
 // StatusFilePattern returns a glob pattern to find status files for a job ID
 // This is useful for queued jobs where the exact timestamp is unknown
 func StatusFilePattern(jobID int64) string {
@@ -86,6 +138,19 @@ func MetadataFilePattern(jobID int64) string {
 	return fmt.Sprintf("%s/%d-*.meta", LogDir, jobID)
 }
 
+// LogTailCommand returns a command that prints the last n lines of a job's
+// log, or nothing if it has none. sessionName is the tmux session of a job
+// from before log files were named by ID, or "". Jobs started by a queue
+// runner have log names whose timestamp isn't recorded, so their log is
+// found by job ID.
+func LogTailCommand(jobID int64, sessionName string, n int) string {
+	if sessionName != "" {
+		return fmt.Sprintf("tail -n %d %s 2>/dev/null || true", n, LegacyLogFile(sessionName))
+	}
+	return fmt.Sprintf(`f=$(ls -t %s 2>/dev/null | head -n 1); [ -z "$f" ] || tail -n %d "$f"`,
+		LogFilePattern(jobID), n)
+}
+
 // LegacyLogFile returns the old-style log file path for backward compatibility
 func LegacyLogFile(sessionName string) string {
 	return fmt.Sprintf("/tmp/tmux-%s.log", sessionName)
@@ -210,15 +275,19 @@ func FormatMetadata(jobID int64, workingDir, command, host, description string,
 
 // WrapperCommandParams contains parameters for building a wrapper command
 type WrapperCommandParams struct {
-	JobID      int64
-	WorkingDir string
-	Command    string
-	LogFile    string
-	StatusFile string
-	PidFile    string
-	NotifyCmd  string   // Optional notification command to run after job completes
-	Timeout    string   // Optional timeout duration (e.g., "2h", "30m")
-	EnvVars    []string // Optional environment variables (VAR=value format)
+	JobID       int64
+	WorkingDir  string
+	Command     string
+	LogFile     string
+	ErrFile     string // Optional file that also receives the job's stderr, which stays in the log too
+	StatusFile  string
+	PidFile     string
+	NotifyCmd   string   // Optional notification command to run after job completes
+	Timeout     string   // Optional timeout duration (e.g., "2h", "30m")
+	EnvVars     []string // Optional environment variables (VAR=value format)
+	SecretsFile string   // Optional file of exported secrets, sourced and deleted before the job starts
+	PreStart    string   // Optional remote hook run before the job; the job is skipped if it fails
+	PostFinish  string   // Optional remote hook run after the job, with $REMOTE_JOBS_EXIT_CODE set
 }
 
 // BuildWrapperCommand creates the bash command that wraps a job with logging,
@@ -237,10 +306,10 @@ func BuildWrapperCommand(params WrapperCommandParams) string {
 	envPrefix := ""
 	for _, ev := range params.EnvVars {
 		// Each env var is in VAR=value format, export it before the command
-		envPrefix += fmt.Sprintf("export %s; ", escapeForBashC(ev))
+		envPrefix += fmt.Sprintf("export %s; ", shellquote.Assignment(ev))
 	}
 
-	escapedCmd := envPrefix + escapeForBashC(params.Command)
+	escapedCmd := shellquote.Escape(envPrefix + params.Command)
 
 	// Prepare working directory: replace ~ with $HOME and quote for spaces
 	// This allows both tilde expansion and support for spaces in paths
@@ -263,52 +332,98 @@ func BuildWrapperCommand(params WrapperCommandParams) string {
 			params.Timeout, params.PidFile, params.Timeout, params.LogFile, params.PidFile)
 	}
 
-	return fmt.Sprintf(
+	header := fmt.Sprintf(
 		`echo "=== START $(date) ===" > %s; `+
 			`echo "job_id: %d" >> %s; `+
-			`echo "cd: %s" >> %s; `+
-			`echo "cmd: %s" >> %s; `+
+			`echo %s >> %s; `+
+			`echo %s >> %s; `+
 			`%s`+ // timeout line (empty if no timeout)
-			`echo "===" >> %s; `+
-			`%s`+ // timeout monitor (empty if no timeout)
-			`cd %s && { (echo $BASHPID > %s; exec bash -c '%s') >> %s 2>&1 & wait $!; }; `+
-			`EXIT_CODE=$?; `+
-			`echo "=== END exit=$EXIT_CODE $(date) ===" >> %s; `+
-			`echo $EXIT_CODE > %s%s`,
+			`echo "===" >> %s; `,
 		params.LogFile,
 		params.JobID, params.LogFile,
-		params.WorkingDir, params.LogFile,
-		params.Command, params.LogFile,
+		shellquote.Quote("cd: "+params.WorkingDir), params.LogFile,
+		shellquote.Quote("cmd: "+params.Command), params.LogFile,
 		func() string {
 			if params.Timeout != "" {
-				return fmt.Sprintf(`echo "timeout: %s" >> %s; `, params.Timeout, params.LogFile)
+				return fmt.Sprintf(`echo %s >> %s; `, shellquote.Quote("timeout: "+params.Timeout), params.LogFile)
 			}
 			return ""
 		}(),
-		params.LogFile,
+		params.LogFile)
+
+	job := fmt.Sprintf(`(echo $BASHPID > %s; exec bash -c '%s') >> %s 2>&1`,
+		params.PidFile, escapedCmd, params.LogFile)
+	if params.ErrFile != "" {
+		// stderr goes through tee to both files. The pipeline is waited for
+		// as a whole, so the log is complete before the END line, and
+		// pipefail makes its status the job's rather than tee's.
+		job = fmt.Sprintf(`{ set -o pipefail; (echo $BASHPID > %s; exec bash -c '%s') 2>&1 1>&3 | tee -a %s; } 3>> %s >> %s`,
+			params.PidFile, escapedCmd, params.ErrFile, params.LogFile, params.LogFile)
+	}
+	run := fmt.Sprintf(
+		`%s`+ // timeout monitor (empty if no timeout)
+			`cd %s && { %s & wait $!; }; `+
+			`EXIT_CODE=$?; `,
 		timeoutMonitor,
-		workingDirQuoted, params.PidFile, escapedCmd, params.LogFile,
-		params.LogFile,
-		params.StatusFile, params.NotifyCmd)
-}
+		workingDirQuoted, job)
 
-// prepareWorkingDir replaces ~ with $HOME and quotes the path to handle spaces
-// Example: "~/my project" -> "$HOME/my project" (with quotes)
-func prepareWorkingDir(dir string) string {
-	// Replace leading ~ or ~/ with $HOME
-	if strings.HasPrefix(dir, "~/") {
-		dir = "$HOME/" + dir[2:]
-	} else if dir == "~" {
-		dir = "$HOME"
+	// A pre-start hook gates the job: if it fails, its exit code becomes the job's
+	if params.PreStart != "" {
+		run = buildHookSection("PRE-START", params.PreStart, envPrefix, workingDirQuoted, params) +
+			`if [ $HOOK_EXIT -eq 0 ]; then ` + run + `else EXIT_CODE=$HOOK_EXIT; fi; `
+	}
+
+	// Secrets are exported by the wrapper, so the job and its hooks inherit
+	// them without their values appearing in the command
+	if params.SecretsFile != "" {
+		header = fmt.Sprintf(`. %s 2>/dev/null; rm -f %s; `, params.SecretsFile, params.SecretsFile) + header
+	}
+
+	footer := `echo "=== END exit=$EXIT_CODE $(date) ===" >> ` + params.LogFile + `; `
+	if params.PostFinish != "" {
+		footer += buildHookSection("POST-FINISH", params.PostFinish, envPrefix, workingDirQuoted, params)
 	}
 
-	// Quote the path to handle spaces and special characters
-	// Use double quotes to allow $HOME expansion
-	return fmt.Sprintf(`"%s"`, dir)
+	return header + run + footer + fmt.Sprintf(`echo $EXIT_CODE > %s%s`, params.StatusFile, params.NotifyCmd)
 }
 
-// escapeForBashC escapes a command for use in bash -c '...'
-func escapeForBashC(s string) string {
-	// Replace single quotes with '\'' (end quote, escaped quote, start quote)
-	return strings.ReplaceAll(s, "'", `'\''`)
+// buildHookSection runs a remote hook in the job's working directory and
+// brackets its output in the log so it can be told apart from job output.
+// The hook's exit status is left in $HOOK_EXIT.
+func buildHookSection(stage, hook, envPrefix, workingDirQuoted string, params WrapperCommandParams) string {
+	return fmt.Sprintf(
+		`echo "=== %s HOOK ===" >> %s; `+
+			`(cd %s && export REMOTE_JOBS_JOB_ID=%d REMOTE_JOBS_EXIT_CODE=$EXIT_CODE && exec bash -c '%s') >> %s 2>&1; `+
+			`HOOK_EXIT=$?; `+
+			`echo "=== %s HOOK END exit=$HOOK_EXIT ===" >> %s; `,
+		stage, params.LogFile,
+		workingDirQuoted, params.JobID, shellquote.Escape(envPrefix+hook), params.LogFile,
+		stage, params.LogFile)
+}
+
+// BuildLaunchCommand returns the remote command that starts a wrapped job in a
+// detached tmux session. The working directory is checked in the same command,
+// so a missing directory fails the launch with "directory not found" instead of
+// leaving a session that exits immediately. With createDir, the directory is
+// created instead.
+func BuildLaunchCommand(tmuxSession, workingDir, wrappedCommand string, createDir bool) string {
+	return workingDirCheck(workingDir, createDir) +
+		fmt.Sprintf("tmux new-session -d -s %s bash -c %s", shellquote.Quote(tmuxSession), shellquote.Quote(wrappedCommand))
+}
+
+// workingDirCheck returns the prefix of a launch command that fails if the
+// working directory is missing, or creates it with createDir
+func workingDirCheck(workingDir string, createDir bool) string {
+	dir := prepareWorkingDir(workingDir)
+	if createDir {
+		return fmt.Sprintf(`mkdir -p %s && `, dir)
+	}
+	return fmt.Sprintf(`[ -d %s ] || { echo %s >&2; exit 1; }; `,
+		dir, shellquote.Quote("directory not found: "+workingDir))
+}
+
+// prepareWorkingDir replaces ~ with $HOME and quotes the path to handle spaces
+// Example: "~/my project" -> "$HOME/my project" (with quotes)
+func prepareWorkingDir(dir string) string {
+	return shellquote.HomePath(dir)
 }
@@ -1,8 +1,13 @@
 package session
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
 )
 
 func TestTmuxSessionName(t *testing.T) {
@@ -23,6 +28,36 @@ func TestTmuxSessionName(t *testing.T) {
 	}
 }
 
+func TestRemoteDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		home    string
+		sep     byte
+		want    string
+		wantErr bool
+	}{
+		{"posix home", "/home/ada", "/home/ada", '/', "~", false},
+		{"posix under home", "/home/ada/code/LM2", "/home/ada", '/', "~/code/LM2", false},
+		{"posix sibling of home", "/home/ada2/code", "/home/ada", '/', "/home/ada2/code", false},
+		{"posix outside home", "/data/runs", "/home/ada", '/', "/data/runs", false},
+		{"windows under home", `C:\Users\Ada\code\LM2`, `C:\Users\Ada`, '\\', "~/code/LM2", false},
+		{"windows case", `c:\users\ada\code`, `C:\Users\Ada`, '\\', "~/code", false},
+		{"windows outside home", `D:\data`, `C:\Users\Ada`, '\\', "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := remoteDir(tt.dir, tt.home, tt.sep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("remoteDir(%q) error = %v, wantErr %v", tt.dir, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("remoteDir(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFileBasename(t *testing.T) {
 	// Test with a known timestamp: 2024-12-12 21:03:00 UTC
 	startTime := int64(1734040980)
@@ -396,3 +431,312 @@ func TestBuildWrapperCommand_ExitCodeCapture(t *testing.T) {
 		t.Errorf("BuildWrapperCommand: exit code file write not found\nCommand: %s", cmd)
 	}
 }
+
+// TestBuildWrapperCommand_Hooks verifies remote hooks are bracketed in the log
+// and that a failing pre-start hook skips the job
+func TestBuildWrapperCommand_Hooks(t *testing.T) {
+	params := WrapperCommandParams{
+		JobID:      42,
+		WorkingDir: "~/code",
+		Command:    "python train.py",
+		LogFile:    "~/.cache/remote-jobs/logs/42.log",
+		StatusFile: "~/.cache/remote-jobs/logs/42.status",
+		PidFile:    "~/.cache/remote-jobs/logs/42.pid",
+		PreStart:   "mount-dataset 'imagenet'",
+		PostFinish: "rm -rf /scratch/$USER",
+	}
+
+	cmd := BuildWrapperCommand(params)
+
+	for _, want := range []string{
+		`echo "=== PRE-START HOOK ===" >> ~/.cache/remote-jobs/logs/42.log`,
+		`exec bash -c 'mount-dataset '\''imagenet'\''')`,
+		`if [ $HOOK_EXIT -eq 0 ]; then`,
+		`else EXIT_CODE=$HOOK_EXIT; fi;`,
+		`echo "=== POST-FINISH HOOK ===" >> ~/.cache/remote-jobs/logs/42.log`,
+		`REMOTE_JOBS_EXIT_CODE=$EXIT_CODE`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("BuildWrapperCommand: missing %q\nCommand: %s", want, cmd)
+		}
+	}
+
+	// Post-finish hook must run after the END marker and before the status file is written
+	end := strings.Index(cmd, "=== END exit=")
+	post := strings.Index(cmd, "=== POST-FINISH HOOK ===")
+	status := strings.Index(cmd, "echo $EXIT_CODE > ~/.cache/remote-jobs/logs/42.status")
+	if !(end < post && post < status) {
+		t.Errorf("BuildWrapperCommand: post-finish hook out of order\nCommand: %s", cmd)
+	}
+
+	// Without hooks, no hook sections are emitted
+	params.PreStart, params.PostFinish = "", ""
+	if cmd := BuildWrapperCommand(params); strings.Contains(cmd, "HOOK") {
+		t.Errorf("BuildWrapperCommand: unexpected hook section\nCommand: %s", cmd)
+	}
+}
+
+// TestBuildLaunchCommand verifies the working directory is checked (or created)
+// in the same remote command that starts the tmux session
+func TestBuildLaunchCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		dir       string
+		createDir bool
+		want      string
+	}{
+		{
+			name: "check tilde dir",
+			dir:  "~/code",
+			want: `[ -d "$HOME/code" ] || { echo 'directory not found: ~/code' >&2; exit 1; }; tmux new-session -d -s rj-7 bash -c 'echo '\''hi'\'''`,
+		},
+		{
+			name: "check dir with quote",
+			dir:  "/data/bob's runs",
+			want: `[ -d "/data/bob's runs" ] || { echo 'directory not found: /data/bob'\''s runs' >&2; exit 1; }; tmux new-session -d -s rj-7 bash -c 'echo '\''hi'\'''`,
+		},
+		{
+			name:      "create dir",
+			dir:       "~/runs/exp 1",
+			createDir: true,
+			want:      `mkdir -p "$HOME/runs/exp 1" && tmux new-session -d -s rj-7 bash -c 'echo '\''hi'\'''`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildLaunchCommand("rj-7", tt.dir, "echo 'hi'", tt.createDir)
+			if got != tt.want {
+				t.Errorf("BuildLaunchCommand() =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBuildLaunchPlan verifies the plan's commands agree with its file paths
+func TestBuildLaunchPlan(t *testing.T) {
+	startTime := int64(1732400000)
+	plan := BuildLaunchPlan(LaunchSpec{
+		JobID:      42,
+		StartTime:  startTime,
+		Host:       "cool30",
+		WorkingDir: "~/code",
+		Command:    "python train.py",
+		EnvVars:    []string{"BATCH=32"},
+	})
+
+	if plan.TmuxSession != "rj-42" {
+		t.Errorf("TmuxSession = %q, want rj-42", plan.TmuxSession)
+	}
+	if plan.LogFile != LogFile(42, startTime) || plan.PidFile != PidFile(42, startTime) {
+		t.Errorf("unexpected paths: log=%q pid=%q", plan.LogFile, plan.PidFile)
+	}
+	if want := shellquote.WriteFile(MetadataFile(42, startTime), plan.Metadata); plan.MetadataCommand != want {
+		t.Errorf("MetadataCommand = %q, want %q", plan.MetadataCommand, want)
+	}
+	if !strings.Contains(plan.Metadata, "\ncommand=python train.py\n") {
+		t.Errorf("Metadata missing command: %q", plan.Metadata)
+	}
+	if !strings.Contains(plan.WrapperCommand, "export BATCH=32; python train.py") {
+		t.Errorf("WrapperCommand missing env and command: %q", plan.WrapperCommand)
+	}
+	if want := BuildLaunchCommand("rj-42", "~/code", plan.WrapperCommand, false); plan.LaunchCommand != want {
+		t.Errorf("LaunchCommand = %q, want %q", plan.LaunchCommand, want)
+	}
+	if out := plan.Format(); !strings.Contains(out, "ssh cool30 "+plan.LaunchCommand) {
+		t.Errorf("Format() missing launch command:\n%s", out)
+	}
+}
+
+func TestBuildLaunchPlan_GPUs(t *testing.T) {
+	plan := BuildLaunchPlan(LaunchSpec{
+		JobID:      42,
+		StartTime:  1732400000,
+		Host:       "cool30",
+		WorkingDir: "~/code",
+		Command:    "python train.py",
+		EnvVars:    []string{"CUDA_VISIBLE_DEVICES=1,3"},
+		GPUs:       []int{1, 3},
+	})
+	if !strings.HasSuffix(plan.Metadata, "\ngpus=1,3") {
+		t.Errorf("Metadata missing GPUs: %q", plan.Metadata)
+	}
+	if !strings.Contains(plan.WrapperCommand, "export CUDA_VISIBLE_DEVICES=1,3; python train.py") {
+		t.Errorf("WrapperCommand missing CUDA_VISIBLE_DEVICES: %q", plan.WrapperCommand)
+	}
+	if plan.ErrFile != "" || strings.Contains(plan.WrapperCommand, "tee") {
+		t.Errorf("stderr split without SplitStderr: ErrFile = %q", plan.ErrFile)
+	}
+}
+
+func TestBuildLaunchPlan_SplitStderr(t *testing.T) {
+	plan := BuildLaunchPlan(LaunchSpec{
+		JobID:       42,
+		StartTime:   1732400000,
+		Host:        "cool30",
+		WorkingDir:  "~/code",
+		Command:     "python train.py",
+		SplitStderr: true,
+	})
+	if plan.ErrFile != ErrFile(42, 1732400000) {
+		t.Errorf("ErrFile = %q, want %q", plan.ErrFile, ErrFile(42, 1732400000))
+	}
+	if want := "\nlog_file=" + plan.LogFile + "\nstderr_file=" + plan.ErrFile; !strings.HasSuffix(plan.Metadata, want) {
+		t.Errorf("Metadata missing the log paths: %q", plan.Metadata)
+	}
+	if !strings.Contains(plan.WrapperCommand, "tee -a "+plan.ErrFile) {
+		t.Errorf("WrapperCommand doesn't write stderr to %s: %q", plan.ErrFile, plan.WrapperCommand)
+	}
+}
+
+// TestBuildWrapperCommand_ShellRoundTrip runs the wrapper under bash with a
+// command and directory full of shell metacharacters
+func TestBuildWrapperCommand_ShellRoundTrip(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	dir := filepath.Join(t.TempDir(), "it's a $dir `x`")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	command := `printf '%s|%s\n' "$GREETING" "$(basename "$PWD")"`
+	params := WrapperCommandParams{
+		JobID:      9,
+		WorkingDir: dir,
+		Command:    command,
+		LogFile:    filepath.Join(t.TempDir(), "9.log"),
+		StatusFile: filepath.Join(t.TempDir(), "9.status"),
+		PidFile:    filepath.Join(t.TempDir(), "9.pid"),
+		EnvVars:    []string{"GREETING=hello 'world' $USER"},
+	}
+
+	if out, err := exec.Command(bash, "-c", BuildWrapperCommand(params)).CombinedOutput(); err != nil {
+		t.Fatalf("wrapper failed: %v\n%s", err, out)
+	}
+
+	status, _ := os.ReadFile(params.StatusFile)
+	if strings.TrimSpace(string(status)) != "0" {
+		t.Errorf("exit status = %q, want 0", status)
+	}
+	log, _ := os.ReadFile(params.LogFile)
+	for _, want := range []string{
+		"cmd: " + command + "\n",
+		"cd: " + dir + "\n",
+		"hello 'world' $USER|it's a $dir `x`\n",
+	} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("log missing %q\nLog:\n%s", want, log)
+		}
+	}
+}
+
+// TestBuildWrapperCommand_ErrFile checks that a job's stderr is written to
+// its own file as well as the log, and that its exit code survives the tee
+func TestBuildWrapperCommand_ErrFile(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	tmp := t.TempDir()
+	params := WrapperCommandParams{
+		JobID:      9,
+		WorkingDir: tmp,
+		Command:    `echo out; echo oops >&2; exit 3`,
+		LogFile:    filepath.Join(tmp, "9.log"),
+		ErrFile:    filepath.Join(tmp, "9.err"),
+		StatusFile: filepath.Join(tmp, "9.status"),
+		PidFile:    filepath.Join(tmp, "9.pid"),
+	}
+	if out, err := exec.Command(bash, "-c", BuildWrapperCommand(params)).CombinedOutput(); err != nil {
+		t.Fatalf("wrapper failed: %v\n%s", err, out)
+	}
+
+	status, _ := os.ReadFile(params.StatusFile)
+	if strings.TrimSpace(string(status)) != "3" {
+		t.Errorf("exit status = %q, want 3", status)
+	}
+	stderr, _ := os.ReadFile(params.ErrFile)
+	if string(stderr) != "oops\n" {
+		t.Errorf("stderr file = %q, want %q", stderr, "oops\n")
+	}
+	log, _ := os.ReadFile(params.LogFile)
+	if !strings.Contains(string(log), "out\n") || !strings.Contains(string(log), "oops\n===") {
+		t.Errorf("log should have both streams, ending before the END line\nLog:\n%s", log)
+	}
+}
+
+// TestSecretsLaunchCommand checks that the secrets file is written from stdin
+// and deleted again when the launch fails, as it does for a missing directory
+func TestSecretsLaunchCommand(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	tmp := t.TempDir()
+	secretsFile := filepath.Join(tmp, "9.secrets")
+	run := func(launch string) error {
+		cmd := exec.Command(bash, "-c", SecretsLaunchCommand(secretsFile, launch))
+		cmd.Stdin = strings.NewReader("export TOKEN=x\n")
+		return cmd.Run()
+	}
+
+	if err := run("true"); err != nil {
+		t.Fatalf("launch failed: %v", err)
+	}
+	if data, err := os.ReadFile(secretsFile); err != nil || string(data) != "export TOKEN=x\n" {
+		t.Errorf("secrets file = %q, %v", data, err)
+	}
+	if info, err := os.Stat(secretsFile); err == nil && info.Mode().Perm() != 0o600 {
+		t.Errorf("secrets file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	missing := filepath.Join(tmp, "missing")
+	if err := run(BuildLaunchCommand("rj-9", missing, "true", false)); err == nil {
+		t.Fatal("launch in a missing directory succeeded")
+	}
+	if _, err := os.Stat(secretsFile); !os.IsNotExist(err) {
+		t.Errorf("secrets file left after a failed launch: %v", err)
+	}
+}
+
+// TestBuildWrapperCommand_Secrets checks that the wrapper exports the secrets
+// file to the job and deletes it
+func TestBuildWrapperCommand_Secrets(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	tmp := t.TempDir()
+	params := WrapperCommandParams{
+		JobID:       9,
+		WorkingDir:  tmp,
+		Command:     `echo "token=$TOKEN"`,
+		LogFile:     filepath.Join(tmp, "9.log"),
+		StatusFile:  filepath.Join(tmp, "9.status"),
+		PidFile:     filepath.Join(tmp, "9.pid"),
+		SecretsFile: filepath.Join(tmp, "9.secrets"),
+	}
+	if err := os.WriteFile(params.SecretsFile, []byte("export TOKEN='s3 cret'\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	wrapper := BuildWrapperCommand(params)
+	if strings.Contains(wrapper, "s3 cret") {
+		t.Errorf("wrapper contains the secret value: %q", wrapper)
+	}
+	if out, err := exec.Command(bash, "-c", wrapper).CombinedOutput(); err != nil {
+		t.Fatalf("wrapper failed: %v\n%s", err, out)
+	}
+	log, _ := os.ReadFile(params.LogFile)
+	if !strings.Contains(string(log), "token=s3 cret\n") {
+		t.Errorf("job didn't see the secret\nLog:\n%s", log)
+	}
+	if _, err := os.Stat(params.SecretsFile); !os.IsNotExist(err) {
+		t.Errorf("secrets file not deleted: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// shellSessionPrefix begins the tmux session names of interactive shells, so
+// that they aren't mistaken for jobs
+const shellSessionPrefix = "rj-shell-"
+
+// DefaultShellName is the name of the shell session used when none is given
+const DefaultShellName = "main"
+
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateShellName returns an error if name can't be used as a shell
+// session name
+func ValidateShellName(name string) error {
+	if !shellNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid shell name %q (use letters, digits, - and _)", name)
+	}
+	return nil
+}
+
+// ShellSessionName returns the tmux session name for a named interactive shell
+func ShellSessionName(name string) string {
+	return shellSessionPrefix + name
+}
+
+// IsShellSession reports whether a tmux session is an interactive shell
+// rather than a job
+func IsShellSession(tmuxSession string) bool {
+	return strings.HasPrefix(tmuxSession, shellSessionPrefix)
+}
+
+// ShellName returns the name of an interactive shell from its tmux session
+// name, or "" if it isn't one
+func ShellName(tmuxSession string) string {
+	name, ok := strings.CutPrefix(tmuxSession, shellSessionPrefix)
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// AttachShellCommand returns a command that attaches to a named shell's tmux
+// session, creating it in dir if it doesn't exist
+func AttachShellCommand(name, dir string) string {
+	return fmt.Sprintf("tmux new-session -A -s %s -c %s", shellquote.Quote(ShellSessionName(name)), shellquote.Path(dir))
+}
+
+// ListShellsCommand returns a command that prints a "name attached" line for
+// each interactive shell on a host, where attached is the number of clients
+// attached to it
+func ListShellsCommand() string {
+	return fmt.Sprintf("tmux list-sessions -F '#{session_name} #{session_attached}' 2>/dev/null | grep '^%s' || true", shellSessionPrefix)
+}
+
+// ParseShellList parses the output of ListShellsCommand into the number of
+// clients attached to each shell, by name
+func ParseShellList(output string) map[string]int {
+	shells := make(map[string]int)
+	for _, line := range strings.Split(output, "\n") {
+		var tmuxSession string
+		var attached int
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), "%s %d", &tmuxSession, &attached); err != nil {
+			continue
+		}
+		if name := ShellName(tmuxSession); name != "" {
+			shells[name] = attached
+		}
+	}
+	return shells
+}
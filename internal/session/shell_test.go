@@ -0,0 +1,40 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellSessionName(t *testing.T) {
+	tmuxSession := ShellSessionName("scratch")
+	if !IsShellSession(tmuxSession) {
+		t.Errorf("IsShellSession(%q) = false, want true", tmuxSession)
+	}
+	if got := ShellName(tmuxSession); got != "scratch" {
+		t.Errorf("ShellName(%q) = %q, want %q", tmuxSession, got, "scratch")
+	}
+	if IsShellSession(TmuxSessionName(42)) {
+		t.Errorf("IsShellSession(%q) = true, want false", TmuxSessionName(42))
+	}
+}
+
+func TestValidateShellName(t *testing.T) {
+	for _, name := range []string{"main", "scratch-2", "gpu_debug"} {
+		if err := ValidateShellName(name); err != nil {
+			t.Errorf("ValidateShellName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "a b", "x:y", "a.b", "$(rm)"} {
+		if err := ValidateShellName(name); err == nil {
+			t.Errorf("ValidateShellName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestParseShellList(t *testing.T) {
+	output := "rj-shell-main 1\nrj-shell-scratch 0\n\n"
+	want := map[string]int{"main": 1, "scratch": 0}
+	if got := ParseShellList(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseShellList() = %v, want %v", got, want)
+	}
+}
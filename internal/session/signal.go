@@ -0,0 +1,55 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SignalJobCommand returns a shell command that sends signal (e.g. "STOP" or
+// "CONT") to a job's process and all of its descendants, found through the
+// job's PID file. Signalling the whole tree matters for jobs such as
+// dataloaders that fork workers. The command prints "ok", "not_running", or
+// "failed", and runs in a subshell so it can be embedded in a hook.
+func SignalJobCommand(jobID int64, signal string) string {
+	return fmt.Sprintf(`(pid=$(cat %s 2>/dev/null | head -1); `+
+		`if [ -z "$pid" ] || ! kill -0 "$pid" 2>/dev/null; then echo not_running; exit 0; fi; `+
+		`rj_tree() { echo "$1"; for c in $(pgrep -P "$1" 2>/dev/null); do rj_tree "$c"; done; }; `+
+		`kill -%s $(rj_tree "$pid") 2>/dev/null && echo ok || echo failed)`,
+		PidFilePattern(jobID), signal)
+}
+
+// JobStateCommand returns a shell command that reports a job's process state
+// from its status and PID files: the exit code if it finished, "PAUSED" if
+// it is stopped, "RUNNING", or "DEAD" followed by the PID the job last had,
+// if it recorded one
+func JobStateCommand(jobID int64) string {
+	statusPattern := StatusFilePattern(jobID)
+	return fmt.Sprintf(`if ls %s >/dev/null 2>&1; then cat %s 2>/dev/null | head -1; `+
+		`elif pid=$(cat %s 2>/dev/null | head -1) && [ -n "$pid" ] && kill -0 "$pid" 2>/dev/null; then `+
+		`case "$(ps -o stat= -p "$pid" 2>/dev/null)" in *T*) echo PAUSED ;; *) echo RUNNING ;; esac; `+
+		`else echo DEAD $pid; fi`,
+		statusPattern, statusPattern, PidFilePattern(jobID))
+}
+
+// DeadState reports whether state, a line printed by JobStateCommand, says
+// the job is dead, and returns the PID it last had, or "" if it recorded none
+func DeadState(state string) (pid string, dead bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(state), "DEAD")
+	if !ok || (rest != "" && rest[0] != ' ') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// WaitForExitCommand returns a shell command that waits until a job has
+// finished, checking its state each second. A job whose process is gone is
+// given grace seconds to record its exit code, which its wrapper does after
+// any post-finish hook, before the command gives up on it. A job that hasn't
+// recorded a PID yet, such as one running a pre-start hook, is waited for.
+func WaitForExitCommand(jobID int64, grace int) string {
+	return fmt.Sprintf(`rj_gone=0; while :; do `+
+		`case "$(%s)" in RUNNING|PAUSED|DEAD) ;; `+
+		`"DEAD "*) rj_gone=$((rj_gone + 1)); [ $rj_gone -gt %d ] && break ;; `+
+		`*) break ;; esac; sleep 1; done`,
+		JobStateCommand(jobID), grace)
+}
@@ -0,0 +1,160 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignalJobCommand stops and continues a real process tree through its
+// PID file, checking the state reported by JobStateCommand along the way
+func TestSignalJobCommand(t *testing.T) {
+	if _, err := exec.LookPath("pgrep"); err != nil {
+		t.Skip("pgrep not available")
+	}
+	home := t.TempDir()
+	logDir := filepath.Join(home, ".cache", "remote-jobs", "logs")
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A parent shell with a child, like a job that forks workers
+	job := exec.Command("sh", "-c", "sleep 30 & wait")
+	if err := job.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		exec.Command("pkill", "-KILL", "-P", fmt.Sprint(job.Process.Pid)).Run()
+		job.Process.Kill()
+		job.Wait()
+	}()
+	pidFile := filepath.Join(logDir, "7-20240101-120000.pid")
+	if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", job.Process.Pid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(command string) string {
+		t.Helper()
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	// Process state changes are asynchronous; poll briefly
+	waitForState := func(want string) {
+		t.Helper()
+		var got string
+		for i := 0; i < 50; i++ {
+			if got = run(JobStateCommand(7)); got == want {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Errorf("job state = %q, want %q", got, want)
+	}
+
+	time.Sleep(100 * time.Millisecond) // let the child start
+	waitForState("RUNNING")
+
+	if got := run(SignalJobCommand(7, "STOP")); got != "ok" {
+		t.Fatalf("STOP = %q, want ok", got)
+	}
+	waitForState("PAUSED")
+
+	if got := run(SignalJobCommand(7, "CONT")); got != "ok" {
+		t.Fatalf("CONT = %q, want ok", got)
+	}
+	waitForState("RUNNING")
+
+	if err := os.WriteFile(filepath.Join(logDir, "7-20240101-120000.status"), []byte("0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForState("0")
+
+	if got := run(SignalJobCommand(8, "STOP")); got != "not_running" {
+		t.Errorf("STOP of missing job = %q, want not_running", got)
+	}
+	if got := run(JobStateCommand(8)); got != "DEAD" {
+		t.Errorf("state of missing job = %q, want DEAD", got)
+	}
+}
+
+func TestWaitForExitCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	home := t.TempDir()
+	logDir := filepath.Join(home, ".cache", "remote-jobs", "logs")
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	job := exec.Command("sleep", "30")
+	if err := job.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		job.Process.Kill()
+		job.Wait()
+	}()
+	if err := os.WriteFile(filepath.Join(logDir, "9-20240101-120000.pid"), []byte(fmt.Sprintf("%d\n", job.Process.Pid)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := exec.Command("sh", "-c", WaitForExitCommand(9, 5))
+	wait.Env = append(os.Environ(), "HOME="+home)
+	if err := wait.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- wait.Wait() }()
+
+	select {
+	case <-done:
+		t.Fatal("returned while the job was running")
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	// The job exits and its wrapper records the exit code
+	job.Process.Kill()
+	job.Wait()
+	if err := os.WriteFile(filepath.Join(logDir, "9-20240101-120000.status"), []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("wait: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		wait.Process.Kill()
+		t.Fatal("still waiting after the job recorded its exit code")
+	}
+}
+
+func TestDeadState(t *testing.T) {
+	tests := []struct {
+		state   string
+		wantPID string
+		dead    bool
+	}{
+		{"DEAD", "", true},
+		{"DEAD 1234\n", "1234", true},
+		{"RUNNING", "", false},
+		{"DEADLOCK", "", false},
+		{"0", "", false},
+	}
+	for _, tt := range tests {
+		pid, dead := DeadState(tt.state)
+		if pid != tt.wantPID || dead != tt.dead {
+			t.Errorf("DeadState(%q) = %q, %v; want %q, %v", tt.state, pid, dead, tt.wantPID, tt.dead)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// StagedFile is a local input file that run --stage copies to the host
+// before the job starts
+type StagedFile struct {
+	Local  string // Local path
+	Remote string // Destination on the host, relative to the job's working directory unless absolute or under ~
+}
+
+// ParseStage parses a --stage value, "local:remote". A remote path ending in
+// / is a directory the file is copied into, and one that is left out is the
+// working directory. The last colon separates the paths, so Windows local
+// paths such as C:\data\x.csv can be given with a destination.
+func ParseStage(spec string) (StagedFile, error) {
+	local, remote := spec, ""
+	if i := strings.LastIndex(spec, ":"); i >= 0 && !isDriveColon(spec, i) {
+		local, remote = spec[:i], spec[i+1:]
+	}
+	if local == "" {
+		return StagedFile{}, fmt.Errorf("invalid --stage %q: no local file (use local:remote)", spec)
+	}
+	if remote == "" || strings.HasSuffix(remote, "/") {
+		remote += filepath.Base(local)
+	}
+	return StagedFile{Local: local, Remote: remote}, nil
+}
+
+// isDriveColon reports whether the colon at i ends the drive letter of a
+// Windows path, as in C:\data
+func isDriveColon(spec string, i int) bool {
+	return i == 1 && len(spec) > 2 && (spec[2] == '\\' || spec[2] == '/')
+}
+
+// RemotePath returns where the file is copied to for a job in workingDir
+func (f StagedFile) RemotePath(workingDir string) string {
+	if path.IsAbs(f.Remote) || f.Remote == "~" || strings.HasPrefix(f.Remote, "~/") {
+		return f.Remote
+	}
+	return strings.TrimSuffix(workingDir, "/") + "/" + f.Remote
+}
+
+// StageDirsCommand returns a remote command that creates the directories
+// files are staged into, after checking the working directory as the launch
+// command does, so a missing directory fails before any file is copied
+func StageDirsCommand(files []StagedFile, workingDir string, createDir bool) string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		dir := path.Dir(f.RemotePath(workingDir))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, shellquote.HomePath(dir))
+		}
+	}
+	return workingDirCheck(workingDir, createDir) + "mkdir -p " + strings.Join(dirs, " ")
+}
+
+// stageMetadataLines records where each staged file came from and went
+func stageMetadataLines(files []StagedFile) string {
+	lines := make([]string, len(files))
+	for i, f := range files {
+		lines[i] = fmt.Sprintf("staged=%s -> %s", f.Local, f.Remote)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,61 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStage(t *testing.T) {
+	tests := []struct {
+		spec   string
+		want   StagedFile
+		remote string // RemotePath in ~/code
+	}{
+		{"config.yaml", StagedFile{"config.yaml", "config.yaml"}, "~/code/config.yaml"},
+		{"data/train.csv:inputs/", StagedFile{"data/train.csv", "inputs/train.csv"}, "~/code/inputs/train.csv"},
+		{"train.csv:inputs/small.csv", StagedFile{"train.csv", "inputs/small.csv"}, "~/code/inputs/small.csv"},
+		{"train.csv:/data/", StagedFile{"train.csv", "/data/train.csv"}, "/data/train.csv"},
+		{"train.csv:~/shared/", StagedFile{"train.csv", "~/shared/train.csv"}, "~/shared/train.csv"},
+		{`C:\data\train.csv:inputs/small.csv`, StagedFile{`C:\data\train.csv`, "inputs/small.csv"}, "~/code/inputs/small.csv"},
+	}
+	for _, tt := range tests {
+		got, err := ParseStage(tt.spec)
+		if err != nil {
+			t.Errorf("ParseStage(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseStage(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+		if remote := got.RemotePath("~/code"); remote != tt.remote {
+			t.Errorf("ParseStage(%q).RemotePath(~/code) = %q, want %q", tt.spec, remote, tt.remote)
+		}
+	}
+	if _, err := ParseStage(":inputs/"); err == nil {
+		t.Error("ParseStage(\":inputs/\") should fail without a local file")
+	}
+}
+
+func TestBuildLaunchPlan_Staged(t *testing.T) {
+	plan := BuildLaunchPlan(LaunchSpec{
+		JobID:      42,
+		StartTime:  1732400000,
+		Host:       "cool30",
+		WorkingDir: "~/code",
+		Command:    "python train.py",
+		Staged: []StagedFile{
+			{"train.csv", "inputs/train.csv"},
+			{"test.csv", "inputs/test.csv"},
+			{"config.yaml", "config.yaml"},
+		},
+	})
+	if want := `[ -d "$HOME/code" ] || { echo 'directory not found: ~/code' >&2; exit 1; }; mkdir -p "$HOME/code/inputs" "$HOME/code"`; plan.StageCommand != want {
+		t.Errorf("StageCommand = %q, want %q", plan.StageCommand, want)
+	}
+	if !strings.Contains(plan.Metadata, "\nstaged=train.csv -> ~/code/inputs/train.csv\n") {
+		t.Errorf("Metadata missing the staged files: %q", plan.Metadata)
+	}
+	if out := plan.Format(); !strings.Contains(out, "scp test.csv cool30:~/code/inputs/test.csv") {
+		t.Errorf("Format() missing the copies:\n%s", out)
+	}
+}
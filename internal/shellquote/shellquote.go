@@ -0,0 +1,126 @@
+// Package shellquote quotes strings for embedding in POSIX shell commands.
+//
+// Every command remote-jobs sends over SSH is parsed by the remote login
+// shell, and job wrappers are parsed a second time by bash -c. Anything that
+// comes from the user (commands, paths, descriptions, environment values)
+// must go through this package before it is spliced into a command string.
+package shellquote
+
+import (
+	"strings"
+)
+
+// Quote returns s as a single shell word. Strings made only of characters
+// that are never special to the shell are returned unchanged; anything else
+// is wrapped in single quotes, with embedded single quotes escaped as by Escape.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if isSafe(s) {
+		return s
+	}
+	return "'" + Escape(s) + "'"
+}
+
+// Escape escapes s for use inside an existing single-quoted string by
+// replacing each single quote with a close quote, an escaped quote (\'), and
+// an open quote.
+func Escape(s string) string {
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+// Join quotes each argument and joins them with spaces
+func Join(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Path quotes a remote file path while keeping a leading ~ or ~/ unquoted, so
+// the remote shell still expands it to the home directory.
+//
+//	~/.cache/logs/1.log -> ~/.cache/logs/1.log
+//	~/my project        -> ~/'my project'
+//	/data/it's          -> '/data/it'\''s'
+func Path(p string) string {
+	switch {
+	case p == "~":
+		return p
+	case strings.HasPrefix(p, "~/"):
+		if p == "~/" {
+			return p
+		}
+		return "~/" + Quote(p[2:])
+	default:
+		return Quote(p)
+	}
+}
+
+// HomePath double-quotes a remote path, replacing a leading ~ with $HOME so
+// the home directory still expands while the rest of the path is literal.
+//
+//	~/my project -> "$HOME/my project"
+//	/data/$x     -> "/data/\$x"
+func HomePath(p string) string {
+	prefix := ""
+	switch {
+	case p == "~":
+		prefix, p = "$HOME", ""
+	case strings.HasPrefix(p, "~/"):
+		prefix, p = "$HOME/", p[2:]
+	}
+	return `"` + prefix + doubleQuoteEscaper.Replace(p) + `"`
+}
+
+// doubleQuoteEscaper escapes the characters that stay special inside double quotes
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+// Assignment quotes the value of a NAME=value environment assignment so the
+// value is passed literally. Strings without a valid variable name before the
+// first = are quoted as a whole, which makes the shell reject rather than
+// interpret them.
+func Assignment(assign string) string {
+	name, value, ok := strings.Cut(assign, "=")
+	if !ok || !IsName(name) {
+		return Quote(assign)
+	}
+	return name + "=" + Quote(value)
+}
+
+// IsName reports whether s is a valid shell variable name
+func IsName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WriteFile returns a command that writes content, followed by a newline, to
+// path. Unlike a heredoc it has no delimiter that content could collide with.
+func WriteFile(path, content string) string {
+	return "printf '%s\\n' " + Quote(content) + " > " + Path(path)
+}
+
+// isSafe reports whether s contains only characters that need no quoting
+func isSafe(s string) bool {
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("@%+=:,./_-", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
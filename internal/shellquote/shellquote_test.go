@@ -0,0 +1,248 @@
+package shellquote
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"rj-42", "rj-42"},
+		{"/tmp/remote-jobs-notify.sh", "/tmp/remote-jobs-notify.sh"},
+		{"hello world", "'hello world'"},
+		{"it's", `'it'\''s'`},
+		{"`whoami`", "'`whoami`'"},
+		{"$HOME", "'$HOME'"},
+		{`say "hi"`, `'say "hi"'`},
+		{"line1\nline2", "'line1\nline2'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Quote(tt.in); got != tt.want {
+				t.Errorf("Quote(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"~", "~"},
+		{"~/", "~/"},
+		{"~/.cache/remote-jobs/logs/1-20240101-120000.log", "~/.cache/remote-jobs/logs/1-20240101-120000.log"},
+		{"~/my project", "~/'my project'"},
+		{"/data/bob's runs", `'/data/bob'\''s runs'`},
+		{"~bob/x", "'~bob/x'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Path(tt.in); got != tt.want {
+				t.Errorf("Path(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHomePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"~", `"$HOME"`},
+		{"~/code/project", `"$HOME/code/project"`},
+		{"~/my project", `"$HOME/my project"`},
+		{"/data/$x", `"/data/\$x"`},
+		{"/a/\"b\"/`c`", "\"/a/\\\"b\\\"/\\`c\\`\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := HomePath(tt.in); got != tt.want {
+				t.Errorf("HomePath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHomePathShell checks HomePath expands only the home directory
+func TestHomePathShell(t *testing.T) {
+	sh := findShell(t)
+	for _, rest := range []string{"code", "my project", "$USER", "`id`", `a"b`, `back\slash`, "it's"} {
+		cmd := exec.Command(sh, "-c", "printf '%s' "+HomePath("~/"+rest))
+		cmd.Env = append(os.Environ(), "HOME=/home/test")
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("sh -c for %q: %v", rest, err)
+		}
+		if want := "/home/test/" + rest; string(out) != want {
+			t.Errorf("HomePath round trip = %q, want %q", out, want)
+		}
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"BATCH_SIZE=32", "BATCH_SIZE=32"},
+		{"MSG=hello world", "MSG='hello world'"},
+		{"EMPTY=", "EMPTY=''"},
+		{"X=a=b", "X=a=b"},
+		{"Q=it's", `Q='it'\''s'`},
+		{"1BAD=x", "1BAD=x"},
+		{"BAD NAME=x", "'BAD NAME=x'"},
+		{"novalue", "novalue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Assignment(tt.in); got != tt.want {
+				t.Errorf("Assignment(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestQuoteShell runs quoted strings through a real shell and checks they
+// come back unchanged
+func TestQuoteShell(t *testing.T) {
+	sh := findShell(t)
+	inputs := []string{
+		"plain",
+		"with space",
+		"it's",
+		`"double"`,
+		"`backtick`",
+		"$(echo pwned)",
+		"$HOME ${PATH}",
+		"semi; colon && amp | pipe",
+		"new\nline",
+		"trailing backslash\\",
+		"'''",
+		"METADATA_EOF",
+		"glob * ? [a]",
+		"~/tilde",
+	}
+
+	for _, in := range inputs {
+		out, err := exec.Command(sh, "-c", "printf '%s' "+Quote(in)).Output()
+		if err != nil {
+			t.Fatalf("sh -c for %q: %v", in, err)
+		}
+		if string(out) != in {
+			t.Errorf("shell round trip of %q = %q", in, out)
+		}
+	}
+}
+
+// TestWriteFileShell verifies WriteFile writes arbitrary content verbatim
+func TestWriteFileShell(t *testing.T) {
+	sh := findShell(t)
+	path := t.TempDir() + "/it's a file.meta"
+	content := "job_id=1\ndescription=it's `quoted` \"text\" $(date)\nMETADATA_EOF\nend"
+
+	if err := exec.Command(sh, "-c", WriteFile(path, content)).Run(); err != nil {
+		t.Fatalf("WriteFile command failed: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if string(got) != content+"\n" {
+		t.Errorf("file content = %q, want %q", got, content+"\n")
+	}
+}
+
+// FuzzQuote checks that Quote always produces a single word that a POSIX
+// shell would read back as the original string
+func FuzzQuote(f *testing.F) {
+	for _, seed := range []string{"", "a", "it's", "'", "''", "a b", "`x`", "$(x)", "\n", "\\'"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) || strings.ContainsRune(s, 0) {
+			t.Skip()
+		}
+		q := Quote(s)
+		got, ok := unquote(q)
+		if !ok {
+			t.Fatalf("Quote(%q) = %q is not a single shell word", s, q)
+		}
+		if got != s {
+			t.Fatalf("unquote(Quote(%q)) = %q", s, got)
+		}
+	})
+}
+
+// FuzzAssignment checks that the value of an assignment survives quoting
+func FuzzAssignment(f *testing.F) {
+	for _, seed := range []string{"A=1", "A=it's", "A=", "PATH=$PATH:/x", "A=`x`"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || !IsName(name) || !utf8.ValidString(s) || strings.ContainsRune(s, 0) {
+			t.Skip()
+		}
+		a := Assignment(s)
+		if !strings.HasPrefix(a, name+"=") {
+			t.Fatalf("Assignment(%q) = %q lost the name", s, a)
+		}
+		got, ok := unquote(strings.TrimPrefix(a, name+"="))
+		if !ok || got != value {
+			t.Fatalf("Assignment(%q) = %q, value reads back as %q", s, a, got)
+		}
+	})
+}
+
+// unquote parses a single shell word made of unquoted safe characters,
+// single-quoted strings, and backslash-escaped characters. It reports false if
+// the word contains anything a shell would treat specially.
+func unquote(word string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(word); {
+		switch c := word[i]; {
+		case c == '\'':
+			end := strings.IndexByte(word[i+1:], '\'')
+			if end < 0 {
+				return "", false
+			}
+			b.WriteString(word[i+1 : i+1+end])
+			i += end + 2
+		case c == '\\':
+			if i+1 >= len(word) {
+				return "", false
+			}
+			b.WriteByte(word[i+1])
+			i += 2
+		case isSafe(string(c)):
+			b.WriteByte(c)
+			i++
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+func findShell(t *testing.T) string {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	return sh
+}
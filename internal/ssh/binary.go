@@ -0,0 +1,59 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+var (
+	binaryOnce sync.Once
+	sshBinary  string
+	scpBinary  string
+	sftpBinary string
+)
+
+// Binary returns the ssh client to run: $REMOTE_JOBS_SSH if set, else ssh on
+// the PATH. On Windows, where the PATH often lacks it, it falls back to the
+// OpenSSH client that ships with Windows.
+func Binary() string {
+	binaryOnce.Do(findBinaries)
+	return sshBinary
+}
+
+// SCPBinary returns the scp client: scp on the PATH, or on Windows the
+// OpenSSH client's
+func SCPBinary() string {
+	binaryOnce.Do(findBinaries)
+	return scpBinary
+}
+
+// SFTPBinary returns the sftp client: sftp on the PATH, or on Windows the
+// OpenSSH client's
+func SFTPBinary() string {
+	binaryOnce.Do(findBinaries)
+	return sftpBinary
+}
+
+func findBinaries() {
+	sshBinary, scpBinary, sftpBinary = findOnPath("ssh"), findOnPath("scp"), findOnPath("sftp")
+	if custom := os.Getenv("REMOTE_JOBS_SSH"); custom != "" {
+		sshBinary = custom
+	}
+}
+
+// findOnPath returns name if it's on the PATH (exec resolves it again when
+// run), else the Windows OpenSSH client of that name if it exists, else name
+// so that running it reports the usual not-found error
+func findOnPath(name string) string {
+	if _, err := exec.LookPath(name); err == nil || runtime.GOOS != "windows" {
+		return name
+	}
+	builtin := filepath.Join(os.Getenv("SystemRoot"), "System32", "OpenSSH", name+".exe")
+	if _, err := os.Stat(builtin); err == nil {
+		return builtin
+	}
+	return name
+}
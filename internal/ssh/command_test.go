@@ -1,9 +1,14 @@
 package ssh
 
 import (
+	"errors"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestTildeExpansion verifies that paths with ~ are not quoted
@@ -238,3 +243,255 @@ func TestIsConnectionError(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJobGPUMappings(t *testing.T) {
+	output := "JOB_GPU:42:0:1234:97\nJOB_GPU:42:1:2000:\nJOB_GPU:43:2:512\nnoise\n"
+	got := parseJobGPUMappings(output)
+	want := []JobGPUMapping{
+		{JobID: 42, GPUIndex: 0, MemMiB: 1234, Utilization: 97},
+		{JobID: 42, GPUIndex: 1, MemMiB: 2000, Utilization: -1},
+		{JobID: 43, GPUIndex: 2, MemMiB: 512, Utilization: -1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseJobGPUMappings() returned %d mappings, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mapping %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseStatusFileOutput(t *testing.T) {
+	tests := []struct {
+		output  string
+		content string
+		mtime   int64
+	}{
+		{"0\n\nMTIME:1697551234\n", "0", 1697551234},
+		{"137\nMTIME:\n", "137", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		content, mtime := parseStatusFileOutput(tt.output)
+		if content != tt.content || mtime != tt.mtime {
+			t.Errorf("parseStatusFileOutput(%q) = %q, %d; want %q, %d", tt.output, content, mtime, tt.content, tt.mtime)
+		}
+	}
+}
+
+func TestParsePSTime(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+		ok   bool
+	}{
+		{"0:01.25", 125, true},
+		{"12:03.50", 72350, true},
+		{"1:02:03", 372300, true},
+		{"2-01:00:00", 17640000, true},
+		{"5", 0, false},
+		{"x:01", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePSTime(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parsePSTime(%q) = %d, %v; want %d, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestParseProcessStatsDarwin checks the stats macOS hosts report through ps
+// and sysctl instead of /proc
+func TestParseProcessStatsDarwin(t *testing.T) {
+	output := "PID:4242\nTIMESTAMP:1732400000\nRUNNING:YES\n" +
+		"PS_CPU:1:05.00 1:15.50\nMEM_RSS_KB:524288\nTHREADS:12\nMEM_TOTAL_KB:16777216\n"
+	stats := parseProcessStats(output)
+	if !stats.Running || stats.PID != "4242" {
+		t.Fatalf("stats = %+v, want running PID 4242", stats)
+	}
+	if stats.CPUUserTicks != 6500 || stats.CPUSysTicks != 1050 {
+		t.Errorf("ticks = %d user, %d sys; want 6500, 1050", stats.CPUUserTicks, stats.CPUSysTicks)
+	}
+	if stats.CPUUser != "1m 5s" || stats.CPUSys != "10s" {
+		t.Errorf("CPU = %q user, %q sys; want 1m 5s, 10s", stats.CPUUser, stats.CPUSys)
+	}
+	if stats.Threads != 12 || stats.MemoryPct != "3.1%" {
+		t.Errorf("threads = %d, memory = %q; want 12, 3.1%%", stats.Threads, stats.MemoryPct)
+	}
+}
+
+// TestParseJobsProcessStats checks that each job's stats are parsed from its
+// own section of a host-wide sample
+func TestParseJobsProcessStats(t *testing.T) {
+	output := "JOB:7\nPID:100\nTIMESTAMP:1732400000\nRUNNING:YES\nMEM_RSS_KB:2048\nGPU_MEM:0:500MiB\n" +
+		"JOB:8\nPID:NOTFOUND\n" +
+		"JOB:9\nPID:300\nRUNNING:NO\n"
+	stats := parseJobsProcessStats(output)
+	if len(stats) != 3 {
+		t.Fatalf("got stats for %d jobs, want 3", len(stats))
+	}
+	if s := stats[7]; !s.Running || s.PID != "100" || s.MemoryRSS == "" || len(s.GPUs) != 1 {
+		t.Errorf("job 7 = %+v, want running PID 100 with memory and one GPU", s)
+	}
+	if s := stats[8]; s.Running || s.Error == "" {
+		t.Errorf("job 8 = %+v, want a PID file error", s)
+	}
+	if s := stats[9]; s.Running || s.PID != "300" || len(s.GPUs) != 0 {
+		t.Errorf("job 9 = %+v, want stopped PID 300 without GPUs", s)
+	}
+}
+
+func TestDeployScriptCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	home := t.TempDir()
+	deploy := func(script string) {
+		t.Helper()
+		cmd := exec.Command("sh", "-c", deployScriptCommand("host-info", []byte(script)))
+		cmd.Env = append(os.Environ(), "HOME="+home)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("deploy: %v\n%s", err, out)
+		}
+	}
+	deploy("echo old")
+	deploy("echo 'new'")
+	deploy("echo 'new'")
+
+	dir := filepath.Join(home, ".cache", "remote-jobs", "scripts")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Base(ScriptPath("host-info", []byte("echo 'new'")))
+	if len(entries) != 1 || entries[0].Name() != want {
+		t.Fatalf("scripts directory has %v, want only %s", entries, want)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, want)); err != nil || string(got) != "echo 'new'\n" {
+		t.Errorf("deployed script = %q, %v", got, err)
+	}
+}
+
+// TestLocalCommand checks that commands for localhost run in a local shell
+// from the home directory, and that other hosts still go through SSH
+func TestLocalCommand(t *testing.T) {
+	for _, host := range []string{"localhost", "local"} {
+		cmd := Command(host, "echo hi", "-o", "BatchMode=yes")
+		if len(cmd.Args) != 3 || cmd.Args[1] != "-c" || cmd.Args[2] != "echo hi" {
+			t.Errorf("Command(%q) args = %q, want [shell -c 'echo hi']", host, cmd.Args)
+		}
+		if home, err := os.UserHomeDir(); err == nil && cmd.Dir != home {
+			t.Errorf("Command(%q) dir = %q, want %q", host, cmd.Dir, home)
+		}
+	}
+
+	cmd := Command("cool30", "echo hi", "-o", "BatchMode=yes")
+	if got := strings.Join(cmd.Args[1:], " "); got != "-o BatchMode=yes cool30 echo hi" {
+		t.Errorf("Command(cool30) args = %q, want options, host, then command", got)
+	}
+
+	// Running the command needs a POSIX shell
+	if runtime.GOOS == "windows" {
+		return
+	}
+	stdout, _, err := Run("localhost", "echo $((1 + 2))")
+	if err != nil || strings.TrimSpace(stdout) != "3" {
+		t.Errorf("Run(localhost) = %q, %v; want 3", stdout, err)
+	}
+}
+
+func TestAdaptTimeout(t *testing.T) {
+	t.Cleanup(func() { SetLatency("slow", 0) })
+	if got := AdaptTimeout("slow", 5*time.Second); got != 5*time.Second {
+		t.Errorf("AdaptTimeout() with no latency = %v, want 5s", got)
+	}
+	SetLatency("slow", 100*time.Millisecond)
+	if got := AdaptTimeout("slow", 5*time.Second); got != 5*time.Second {
+		t.Errorf("AdaptTimeout() on a fast host = %v, want 5s", got)
+	}
+	SetLatency("slow", 3*time.Second)
+	if got := AdaptTimeout("slow", 5*time.Second); got != 12*time.Second {
+		t.Errorf("AdaptTimeout() on a slow host = %v, want 12s", got)
+	}
+}
+
+func TestHostTimeouts(t *testing.T) {
+	t.Cleanup(func() {
+		SetTimeouts(Timeouts{}, nil)
+		SetLatency("slow", 0)
+	})
+	SetTimeouts(Timeouts{Probe: 20 * time.Second}, map[string]Timeouts{"slow": {Sync: 15 * time.Second}})
+	if got := HostTimeouts("fast"); got.Sync != 5*time.Second || got.Probe != 20*time.Second {
+		t.Errorf("HostTimeouts() of an unconfigured host = %+v, want the default sync and configured probe", got)
+	}
+	SetLatency("slow", 6*time.Second)
+	got := HostTimeouts("slow")
+	if got.Sync != 24*time.Second || got.Probe != 24*time.Second || got.FastSync != 24*time.Second {
+		t.Errorf("HostTimeouts() of a slow host = %+v, want each stretched to 24s", got)
+	}
+}
+
+func TestRunWithTimeoutPartialOutput(t *testing.T) {
+	stdout, _, err := RunWithTimeout("localhost", "echo first; echo sec; exec sleep 5", 500*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("RunWithTimeout() error = %v, want a timeout", err)
+	}
+	if stdout != "first\nsec\n" {
+		t.Errorf("RunWithTimeout() output = %q, want the lines printed before the timeout", stdout)
+	}
+	if got := CompleteLines("first\nsec"); got != "first\n" {
+		t.Errorf("CompleteLines() = %q, want %q", got, "first\n")
+	}
+}
+
+// TestTarget verifies that configured users are added to hosts named
+// without one, and that users named in the host take precedence
+func TestTarget(t *testing.T) {
+	SetUsers(map[string]string{"gpu1": "alice", "gpu2": "", "localhost": "alice"})
+	defer SetUsers(nil)
+
+	tests := []struct {
+		host, target, user string
+	}{
+		{"gpu1", "alice@gpu1", "alice"},
+		{"bob@gpu1", "bob@gpu1", "bob"},
+		{"gpu2", "gpu2", ""},
+		{"other", "other", ""},
+		{"localhost", "localhost", ""},
+	}
+	for _, tt := range tests {
+		if got := Target(tt.host); got != tt.target {
+			t.Errorf("Target(%q) = %q, want %q", tt.host, got, tt.target)
+		}
+		if got := User(tt.host); got != tt.user {
+			t.Errorf("User(%q) = %q, want %q", tt.host, got, tt.user)
+		}
+	}
+}
+
+// TestWithUser verifies that a job's recorded user is added to its host
+// only when commands for the host would log in as someone else
+func TestWithUser(t *testing.T) {
+	SetUsers(map[string]string{"gpu1": "alice"})
+	defer SetUsers(nil)
+
+	tests := []struct {
+		host, user, want string
+	}{
+		{"gpu1", "alice", "gpu1"},
+		{"gpu1", "bob", "bob@gpu1"},
+		{"gpu1", "", "gpu1"},
+		{"carol@gpu1", "bob", "carol@gpu1"},
+		{"other", "bob", "bob@other"},
+		{"localhost", "bob", "localhost"},
+	}
+	for _, tt := range tests {
+		if got := WithUser(tt.host, tt.user); got != tt.want {
+			t.Errorf("WithUser(%q, %q) = %q, want %q", tt.host, tt.user, got, tt.want)
+		}
+	}
+	if got, want := HostTimeouts("bob@gpu1"), HostTimeouts("gpu1"); got != want {
+		t.Errorf("HostTimeouts(bob@gpu1) = %+v, want those of gpu1, %+v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyTimeoutFactor is how many of a host's round trips a command's
+// timeout allows for, at least. Connecting takes several round trips, and
+// a command's output takes more.
+const latencyTimeoutFactor = 4
+
+var (
+	latencyMu sync.Mutex
+	latencies = make(map[string]time.Duration)
+)
+
+// SetLatency records the typical round-trip time of host, for AdaptTimeout
+func SetLatency(host string, rtt time.Duration) {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	latencies[host] = rtt
+}
+
+// Latency returns the typical round-trip time recorded for host, or 0 if
+// none was recorded
+func Latency(host string) time.Duration {
+	latencyMu.Lock()
+	defer latencyMu.Unlock()
+	return latencies[host]
+}
+
+// AdaptTimeout stretches a timeout chosen for a typical host to allow for
+// host's recorded latency, so that slow links aren't mistaken for dead ones
+func AdaptTimeout(host string, timeout time.Duration) time.Duration {
+	return max(timeout, latencyTimeoutFactor*Latency(host))
+}
+
+// Ping measures the round-trip time of a trivial command on host, including
+// the time to connect
+func Ping(host string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	if _, _, err := RunWithTimeout(host, "true", timeout); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,71 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// LocalHosts are the host names that mean this machine. Commands for them run
+// in a local shell instead of over SSH, so jobs on a workstation get the same
+// wrapper, queueing, and logs as remote ones, and the whole pipeline can be
+// tried without a network.
+var LocalHosts = []string{"localhost", "local"}
+
+// IsLocal reports whether host names this machine
+func IsLocal(host string) bool {
+	for _, h := range LocalHosts {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// Command returns a command that runs a shell command on host: over SSH to
+// Target(host) with any sshOptions, or for a local host, in a shell started in the home
+// directory, where SSH would start it. In a sandbox (see SetSandbox), every
+// host is simulated.
+func Command(host, command string, sshOptions ...string) *exec.Cmd {
+	if Sandboxed() {
+		return sandboxCommand(execCommand(localShell(), "-c", command), host)
+	}
+	if IsLocal(host) {
+		cmd := execCommand(localShell(), "-c", command)
+		cmd.Dir = localHome()
+		return cmd
+	}
+	return execCommand(Binary(), append(append(sshOptions, Target(host)), command)...)
+}
+
+// CommandContext is like Command, but the command is killed when ctx is done
+func CommandContext(ctx context.Context, host, command string) *exec.Cmd {
+	if Sandboxed() {
+		return sandboxCommand(exec.CommandContext(ctx, localShell(), "-c", command), host)
+	}
+	if IsLocal(host) {
+		cmd := exec.CommandContext(ctx, localShell(), "-c", command)
+		cmd.Dir = localHome()
+		return cmd
+	}
+	return exec.CommandContext(ctx, Binary(), Target(host), command)
+}
+
+// localShell returns the shell that runs commands for local hosts: bash, as
+// most SSH servers' login shells are, or sh if it isn't installed
+func localShell() string {
+	if path, err := exec.LookPath("bash"); err == nil {
+		return path
+	}
+	return "sh"
+}
+
+// localHome returns the directory local commands start in, or "" (the
+// current directory) if the home directory is unknown
+func localHome() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home
+}
@@ -0,0 +1,47 @@
+package ssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+)
+
+// sandboxDir is the directory in which every host is simulated, or "" to run
+// commands on real hosts
+var sandboxDir string
+
+// SetSandbox simulates every host in a directory under dir instead of
+// connecting to it: each host's commands run in a local shell with the
+// host's directory as its home, dir/bin ahead on the PATH (for simulated
+// tools such as nvidia-smi), and tmux sessions on a server of their own
+func SetSandbox(dir string) {
+	sandboxDir = dir
+}
+
+// Sandboxed reports whether hosts are simulated
+func Sandboxed() bool {
+	return sandboxDir != ""
+}
+
+var unsafeHostChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// SandboxHostDir returns the home directory of a simulated host
+func SandboxHostDir(host string) string {
+	return filepath.Join(sandboxDir, "hosts", unsafeHostChars.ReplaceAllString(host, "_"))
+}
+
+// sandboxCommand makes cmd, a local shell command, run on a simulated host
+func sandboxCommand(cmd *exec.Cmd, host string) *exec.Cmd {
+	home := SandboxHostDir(host)
+	_ = os.MkdirAll(home, 0755)
+	cmd.Dir = home
+	cmd.Env = append(os.Environ(),
+		"HOME="+home,
+		"XDG_CACHE_HOME=", // The host's ~/.cache, not the user's
+		"PATH="+filepath.Join(sandboxDir, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"TMUX_TMPDIR="+filepath.Join(sandboxDir, "tmux"),
+		"REMOTE_JOBS_SANDBOX_HOST="+host,
+	)
+	return cmd
+}
@@ -0,0 +1,149 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// RemoteFile is an entry of a remote directory listing
+type RemoteFile struct {
+	Name     string
+	Size     int64
+	Modified string // As the listing shows it, e.g. "Oct 17 03:44" or "Mar  2  2025"
+	Dir      bool
+	Link     bool // A symbolic link, which may lead to a directory
+}
+
+// ListDir lists a directory on host through the ssh client's SFTP
+// subsystem. dir may start with ~. It returns the directory's absolute path,
+// so that the parent of the home directory can be found, and its entries,
+// directories first. The . and .. entries are left out.
+func ListDir(host, dir string) (string, []RemoteFile, error) {
+	if IsLocal(host) || Sandboxed() {
+		stdout, stderr, err := Run(host, "cd "+shellquote.Path(dir)+" && pwd && LC_ALL=C ls -la")
+		if err != nil {
+			return "", nil, shellFailure("ls", stderr, err)
+		}
+		abs, files := parseListing(stdout)
+		return abs, files, nil
+	}
+	stdout, err := runSFTP(host, sftpCommand("cd", sftpPath(dir))+"@pwd\n@ls -la\n")
+	if err != nil {
+		return "", nil, err
+	}
+	abs, files := parseListing(stdout)
+	return abs, files, nil
+}
+
+// Download copies a file or, recursively, a directory from host to
+// localPath, which should be absolute
+func Download(host, remotePath, localPath string) error {
+	if IsLocal(host) || Sandboxed() {
+		_, stderr, err := Run(host, "cp -R "+shellquote.Path(remotePath)+" "+shellquote.Quote(localPath))
+		return shellFailure("cp", stderr, err)
+	}
+	_, err := runSFTP(host, sftpCommand("get -r", sftpPath(remotePath), localPath))
+	return err
+}
+
+// shellFailure returns err with the command's error output, if any
+func shellFailure(name, stderr string, err error) error {
+	if err != nil && stderr != "" {
+		return fmt.Errorf("%s: %s", name, strings.TrimSpace(stderr))
+	}
+	return err
+}
+
+// runSFTP runs an sftp batch script on host. The script's commands are
+// prefixed with @ so they aren't echoed, and a failing one ends it.
+func runSFTP(host, script string) (string, error) {
+	connect := int(math.Ceil(HostTimeouts(host).Connect.Seconds()))
+	args := []string{"-q", "-b", "-", "-o", fmt.Sprintf("ConnectTimeout=%d", connect), "-o", "BatchMode=yes"}
+	if Binary() != "ssh" {
+		// Use the same client as other commands, such as $REMOTE_JOBS_SSH
+		args = append(args, "-S", Binary())
+	}
+	cmd := execCommand(SFTPBinary(), append(args, Target(host))...)
+	cmd.Stdin = strings.NewReader(script)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if IsConnectionError(msg) {
+			return "", errors.New(FriendlyError(host, msg, err))
+		}
+		if msg != "" {
+			return "", fmt.Errorf("sftp: %s", msg)
+		}
+		return "", fmt.Errorf("sftp: %w", err)
+	}
+	return stdout.String(), nil
+}
+
+// sftpCommand returns a line of an sftp batch script, quoting its arguments
+func sftpCommand(name string, args ...string) string {
+	var b strings.Builder
+	b.WriteString("@" + name)
+	for _, arg := range args {
+		b.WriteString(` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// sftpPath returns a path as sftp takes it: sftp starts in the home
+// directory but doesn't expand ~
+func sftpPath(p string) string {
+	switch {
+	case p == "~" || p == "~/":
+		return "."
+	case strings.HasPrefix(p, "~/"):
+		return p[2:]
+	}
+	return p
+}
+
+// longListingLine matches a line of ls -l output, as both ls and sftp print
+// it: mode, links, owner, group, size, date, and name
+var longListingLine = regexp.MustCompile(`^([-dlbcps])\S*\s+\d+\s+\S+\s+\S+\s+(\d+)\s+(\S+\s+\d+\s+[\d:]+)\s(.+)$`)
+
+// parseListing parses the output of pwd followed by ls -la, returning the
+// directory and its entries. sftp's pwd prefixes the directory with
+// "Remote working directory: ".
+func parseListing(output string) (string, []RemoteFile) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	dir := strings.TrimPrefix(strings.TrimSpace(lines[0]), "Remote working directory: ")
+	var files []RemoteFile
+	for _, line := range lines[1:] {
+		m := longListingLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue // "total 48", or sftp's prompt
+		}
+		f := RemoteFile{Name: m[4], Modified: m[3], Dir: m[1] == "d", Link: m[1] == "l"}
+		f.Size, _ = strconv.ParseInt(m[2], 10, 64)
+		if f.Link {
+			// ls shows where a link leads; sftp doesn't
+			f.Name, _, _ = strings.Cut(f.Name, " -> ")
+		}
+		if f.Name == "." || f.Name == ".." {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Dir != files[j].Dir {
+			return files[i].Dir
+		}
+		return files[i].Name < files[j].Name
+	})
+	return dir, files
+}
@@ -0,0 +1,58 @@
+package ssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListing(t *testing.T) {
+	// ls -la, as run for local and sandboxed hosts
+	ls := `/home/alice/code
+total 24
+drwxr-xr-x  4 alice staff  4096 Oct 17 03:44 .
+drwxr-xr-x 12 alice staff  4096 Oct  1 10:02 ..
+-rw-r--r--  1 alice staff 12345 Oct 17 03:40 train.py
+drwxr-xr-x  2 alice staff  4096 Mar  2  2025 data
+lrwxrwxrwx  1 alice staff     9 Oct 17 03:41 latest -> runs/run 7
+-rw-r--r--  1 alice staff     0 Oct 17 03:42 my notes.txt
+`
+	dir, files := parseListing(ls)
+	if dir != "/home/alice/code" {
+		t.Errorf("dir = %q, want /home/alice/code", dir)
+	}
+	want := []RemoteFile{
+		{Name: "data", Size: 4096, Modified: "Mar  2  2025", Dir: true},
+		{Name: "latest", Size: 9, Modified: "Oct 17 03:41", Link: true},
+		{Name: "my notes.txt", Size: 0, Modified: "Oct 17 03:42"},
+		{Name: "train.py", Size: 12345, Modified: "Oct 17 03:40"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %+v, want %+v", files, want)
+	}
+
+	// sftp's pwd and ls -la
+	sftp := "Remote working directory: /home/alice\n" +
+		"drwx------    5 alice    alice        4096 Oct 17 03:44 .\n" +
+		"-rw-r--r--    1 alice    alice         220 Jan  6  2024 .profile\n"
+	dir, files = parseListing(sftp)
+	if dir != "/home/alice" || len(files) != 1 || files[0].Name != ".profile" || files[0].Size != 220 {
+		t.Errorf("parseListing(sftp) = %q, %+v", dir, files)
+	}
+}
+
+func TestSFTPCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"cd", []string{sftpPath("~/my \"runs\"")}, "@cd \"my \\\"runs\\\"\"\n"},
+		{"cd", []string{sftpPath("~")}, "@cd \".\"\n"},
+		{"get -r", []string{"/data/out", `C:\tmp\out`}, "@get -r \"/data/out\" \"C:\\\\tmp\\\\out\"\n"},
+	}
+	for _, tt := range tests {
+		if got := sftpCommand(tt.name, tt.args...); got != tt.want {
+			t.Errorf("sftpCommand(%q, %q) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package ssh
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 )
 
 // execCommand is the function used to create exec.Cmd objects.
@@ -23,7 +29,7 @@ const (
 )
 
 // connectionErrorPattern matches SSH connection errors that should trigger retry
-var connectionErrorPattern = regexp.MustCompile(`(?i)(connection timed out|operation timed out|no route to host|host is unreachable|connection refused|network is unreachable|could not resolve hostname|name or service not known)`)
+var connectionErrorPattern = regexp.MustCompile(`(?i)(connection timed out|operation timed out|no route to host|host is unreachable|connection refused|network is unreachable|could not resolve hostname|name or service not known|ssh command timed out)`)
 
 // IsConnectionError checks if the error output indicates a connection failure
 func IsConnectionError(output string) bool {
@@ -48,6 +54,11 @@ func FriendlyError(host, stderr string, err error) string {
 		return fmt.Sprintf("SSH connection to %s failed", host)
 	}
 
+	// Check for a missing working directory (reported by the launch command)
+	if i := strings.Index(stderr, "directory not found:"); i >= 0 {
+		return fmt.Sprintf("%s on %s", strings.TrimSpace(stderr[i:]), host)
+	}
+
 	// Check for permission denied
 	if strings.Contains(strings.ToLower(combined), "permission denied") {
 		return fmt.Sprintf("SSH permission denied on %s", host)
@@ -69,14 +80,27 @@ func FriendlyError(host, stderr string, err error) string {
 }
 
 // EscapeForSingleQuotes escapes a string for embedding in single quotes
-// by replacing ' with '\” (end quote, escaped quote, start quote)
+//
+// Deprecated: Use shellquote.Escape, or shellquote.Quote to add the quotes too.
 func EscapeForSingleQuotes(s string) string {
-	return strings.ReplaceAll(s, "'", `'\''`)
+	return shellquote.Escape(s)
 }
 
 // Run executes an SSH command and returns stdout, stderr, and error
 func Run(host string, command string) (string, string, error) {
-	cmd := execCommand("ssh", host, command)
+	cmd := Command(host, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+// RunWithInput executes an SSH command with input on its stdin, for data
+// that must not appear on the command line
+func RunWithInput(host string, command string, input string) (string, string, error) {
+	cmd := Command(host, command)
+	cmd.Stdin = strings.NewReader(input)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -85,15 +109,19 @@ func Run(host string, command string) (string, string, error) {
 }
 
 // RunWithTimeout executes an SSH command with a timeout and connection options
-// to prevent hanging on unreachable hosts or password prompts
+// to prevent hanging on unreachable hosts or password prompts. The timeout is
+// stretched for hosts with a high recorded latency (see AdaptTimeout). If the
+// command times out, the error wraps ErrTimeout, and the output received
+// until then is returned with it.
 func RunWithTimeout(host string, command string, timeout time.Duration) (string, string, error) {
-	cmd := exec.Command("ssh",
-		"-o", "ConnectTimeout=10",
-		"-o", "BatchMode=yes",
-		host, command)
+	timeout = AdaptTimeout(host, timeout)
+	connect := int(math.Ceil(HostTimeouts(host).Connect.Seconds()))
+	cmd := Command(host, command, "-o", fmt.Sprintf("ConnectTimeout=%d", connect), "-o", "BatchMode=yes")
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	// Don't wait on the output of processes left behind by a killed command
+	cmd.WaitDelay = time.Second
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
@@ -112,7 +140,8 @@ func RunWithTimeout(host string, command string, timeout time.Duration) (string,
 		return stdout.String(), stderr.String(), err
 	case <-time.After(timeout):
 		cmd.Process.Kill()
-		return "", "", fmt.Errorf("ssh command timed out after %v", timeout)
+		<-done
+		return stdout.String(), stderr.String(), fmt.Errorf("%w after %v", ErrTimeout, timeout)
 	}
 }
 
@@ -164,7 +193,7 @@ func RunWithRetryVerbose(host string, command string, verbose bool) (string, str
 
 // RunInteractive runs an SSH command that may require terminal interaction
 func RunInteractive(host string, command string) error {
-	cmd := exec.Command("ssh", host, "-t", command)
+	cmd := Command(host, command, "-t")
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -173,7 +202,7 @@ func RunInteractive(host string, command string) error {
 
 // RunStreaming runs an SSH command and streams output to the provided writers
 func RunStreaming(host string, command string, stdout, stderr io.Writer) error {
-	cmd := exec.Command("ssh", host, command)
+	cmd := Command(host, command)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	return cmd.Run()
@@ -193,8 +222,16 @@ func CopyToWithRetry(localPath, host, remotePath string) error {
 func CopyToWithRetryVerbose(localPath, host, remotePath string, verbose bool) error {
 	var lastErr error
 
+	if IsLocal(host) || Sandboxed() {
+		_, stderr, err := Run(host, "cp "+shellquote.Quote(localPath)+" "+shellquote.Path(remotePath))
+		if err != nil && stderr != "" {
+			return fmt.Errorf("cp: %s", strings.TrimSpace(stderr))
+		}
+		return err
+	}
+
 	for attempt := 1; attempt <= MaxRetries; attempt++ {
-		cmd := exec.Command("scp", "-q", localPath, fmt.Sprintf("%s:%s", host, remotePath))
+		cmd := exec.Command(SCPBinary(), "-q", localPath, fmt.Sprintf("%s:%s", Target(host), remotePath))
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr
 		err := cmd.Run()
@@ -227,7 +264,7 @@ func CopyToWithRetryVerbose(localPath, host, remotePath string, verbose bool) er
 
 // TmuxSessionExists checks if a tmux session exists on the remote host (with retry)
 func TmuxSessionExists(host, sessionName string) (bool, error) {
-	stdout, stderr, err := RunWithRetry(host, fmt.Sprintf("tmux has-session -t '%s' 2>&1 && echo YES || echo NO", sessionName))
+	stdout, stderr, err := RunWithRetry(host, fmt.Sprintf("tmux has-session -t %s 2>&1 && echo YES || echo NO", shellquote.Quote(sessionName)))
 	if err != nil {
 		// Check if it's a connection error
 		if IsConnectionError(stdout + stderr) {
@@ -245,7 +282,7 @@ func TmuxSessionExists(host, sessionName string) (bool, error) {
 
 // TmuxSessionExistsQuick checks if a tmux session exists without retrying (for sync)
 func TmuxSessionExistsQuick(host, sessionName string) (bool, error) {
-	stdout, stderr, err := Run(host, fmt.Sprintf("tmux has-session -t '%s' 2>&1 && echo YES || echo NO", sessionName))
+	stdout, stderr, err := Run(host, fmt.Sprintf("tmux has-session -t %s 2>&1 && echo YES || echo NO", shellquote.Quote(sessionName)))
 	if err != nil {
 		// Check if it's a connection error
 		if IsConnectionError(stdout + stderr) {
@@ -274,6 +311,39 @@ func ReadRemoteFileQuick(host, path string) (string, error) {
 	return strings.TrimSpace(stdout), nil
 }
 
+// ReadStatusFile reads a job status file and its modification time (on the
+// host's clock) without retrying, giving up after timeout. The content is
+// empty and mtime is 0 if the file doesn't exist; mtime is 0 if stat isn't
+// available.
+// Note: path is not quoted to allow tilde expansion
+func ReadStatusFile(host, path string, timeout time.Duration) (content string, mtime int64, err error) {
+	cmd := fmt.Sprintf(`if [ -f %[1]s ]; then cat %[1]s; echo; echo "MTIME:$(stat -c %%Y %[1]s 2>/dev/null || stat -f %%m %[1]s 2>/dev/null)"; fi`, path)
+	stdout, stderr, err := RunWithTimeout(host, cmd, timeout)
+	if err != nil {
+		if IsConnectionError(stdout + stderr) {
+			return "", 0, fmt.Errorf("connection error: %s", strings.TrimSpace(stdout+stderr))
+		}
+		return "", 0, err
+	}
+	content, mtime = parseStatusFileOutput(stdout)
+	return content, mtime, nil
+}
+
+// parseStatusFileOutput splits the output of ReadStatusFile's command into
+// the file content and mtime
+func parseStatusFileOutput(output string) (string, int64) {
+	var lines []string
+	var mtime int64
+	for _, line := range strings.Split(output, "\n") {
+		if rest, ok := strings.CutPrefix(line, "MTIME:"); ok {
+			mtime, _ = strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), mtime
+}
+
 // TmuxListSessions lists all tmux sessions on a remote host
 func TmuxListSessions(host string) ([]string, error) {
 	stdout, _, err := Run(host, "tmux list-sessions -F '#{session_name}' 2>/dev/null || true")
@@ -292,13 +362,13 @@ func TmuxListSessions(host string) ([]string, error) {
 
 // TmuxKillSession kills a tmux session on a remote host
 func TmuxKillSession(host, sessionName string) error {
-	_, _, err := Run(host, fmt.Sprintf("tmux kill-session -t '%s'", sessionName))
+	_, _, err := Run(host, "tmux kill-session -t "+shellquote.Quote(sessionName))
 	return err
 }
 
 // TmuxCapturePaneOutput captures the last N lines from a tmux pane
 func TmuxCapturePaneOutput(host, sessionName string, lines int) (string, error) {
-	stdout, _, err := Run(host, fmt.Sprintf("tmux capture-pane -t '%s' -p | tail -%d", sessionName, lines))
+	stdout, _, err := Run(host, fmt.Sprintf("tmux capture-pane -t %s -p | tail -%d", shellquote.Quote(sessionName), lines))
 	return stdout, err
 }
 
@@ -316,12 +386,12 @@ func RemoteFileExists(host, path string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return strings.Contains(stdout, "EXISTS"), nil
+	return strings.TrimSpace(stdout) == "EXISTS", nil
 }
 
 // GetTmuxPanePID gets the PID of the process running in a tmux pane
 func GetTmuxPanePID(host, sessionName string) (string, error) {
-	stdout, _, err := Run(host, fmt.Sprintf("tmux list-panes -t '%s' -F '#{pane_pid}' 2>/dev/null | head -1", sessionName))
+	stdout, _, err := Run(host, fmt.Sprintf("tmux list-panes -t %s -F '#{pane_pid}' 2>/dev/null | head -1", shellquote.Quote(sessionName)))
 	return strings.TrimSpace(stdout), err
 }
 
@@ -362,24 +432,36 @@ type ProcessGPU struct {
 	Utilization int    // GPU utilization % (0-100)
 }
 
-// GetProcessStats fetches process statistics from a remote host
-// The pidFile should contain the PID to query
-func GetProcessStats(host, pidFile string) (*ProcessStats, error) {
-	// Build a command that outputs all stats we need in a parseable format
-	// This runs in a single SSH call for efficiency
-	cmd := fmt.Sprintf(`
-		PID=$(cat %s 2>/dev/null)
+// GPUMemorySummary returns the process's GPU memory per device, e.g. "0:1234MiB 1:2000MiB"
+func (s *ProcessStats) GPUMemorySummary() string {
+	parts := make([]string, 0, len(s.GPUs))
+	for _, gpu := range s.GPUs {
+		parts = append(parts, fmt.Sprintf("%d:%s", gpu.Index, gpu.MemUsed))
+	}
+	return strings.Join(parts, " ")
+}
+
+// processStatsScript defines job_stats, a shell function that prints the
+// statistics of the process whose PID is in the file named by its argument.
+// nvidia-smi is queried once, up front, however many jobs are sampled.
+const processStatsScript = `
+	GPU_UTILS=$(nvidia-smi --query-gpu=index,utilization.gpu --format=csv,noheader,nounits 2>/dev/null)
+	GPU_APPS=$(nvidia-smi --query-compute-apps=pid,gpu_uuid,used_memory --format=csv,noheader,nounits 2>/dev/null)
+	GPU_UUIDS=$(nvidia-smi --query-gpu=index,uuid --format=csv,noheader 2>/dev/null)
+
+	job_stats() {
+		PID=$(cat "$1" 2>/dev/null)
 		if [ -z "$PID" ]; then
 			echo "PID:NOTFOUND"
-			exit 0
+			return 0
 		fi
 		echo "PID:$PID"
-		echo "TIMESTAMP:$(date +%%s)"
+		echo "TIMESTAMP:$(date +%s)"
 
 		# Check if process is running
 		if ! kill -0 $PID 2>/dev/null; then
 			echo "RUNNING:NO"
-			exit 0
+			return 0
 		fi
 		echo "RUNNING:YES"
 
@@ -398,6 +480,22 @@ func GetProcessStats(host, pidFile string) (*ProcessStats, error) {
 				echo "CPU_SYS_TICKS:$STIME"
 				echo "CLK_TCK:$CLK_TCK"
 			fi
+		elif [ "$(uname -s)" = Darwin ]; then
+			# macOS has no /proc: ps reports user and total CPU time, RSS in KB,
+			# and one line per thread with -M
+			echo "PS_CPU:$(ps -o utime=,time= -p $PID 2>/dev/null)"
+			RSS_KB=$(ps -o rss= -p $PID 2>/dev/null | tr -d ' ')
+			if [ -n "$RSS_KB" ]; then
+				echo "MEM_RSS_KB:$RSS_KB"
+			fi
+			THREADS=$(ps -M -p $PID 2>/dev/null | tail -n +2 | wc -l | tr -d ' ')
+			if [ -n "$THREADS" ] && [ "$THREADS" -gt 0 ]; then
+				echo "THREADS:$THREADS"
+			fi
+			MEM_BYTES=$(sysctl -n hw.memsize 2>/dev/null)
+			if [ -n "$MEM_BYTES" ]; then
+				echo "MEM_TOTAL_KB:$((MEM_BYTES / 1024))"
+			fi
 		fi
 
 		# Get memory and thread count from /proc/PID/status
@@ -419,31 +517,71 @@ func GetProcessStats(host, pidFile string) (*ProcessStats, error) {
 		fi
 
 		# Get GPU utilization (per-GPU)
-		nvidia-smi --query-gpu=index,utilization.gpu --format=csv,noheader,nounits 2>/dev/null | while read line; do
+		echo "$GPU_UTILS" | while read line; do
+			[ -n "$line" ] || continue
 			GPU_IDX=$(echo "$line" | cut -d',' -f1 | tr -d ' ')
 			GPU_UTIL=$(echo "$line" | cut -d',' -f2 | tr -d ' ')
 			echo "GPU_UTIL:$GPU_IDX:$GPU_UTIL"
 		done
 
 		# Get GPU memory usage from process (if available)
-		nvidia-smi --query-compute-apps=pid,gpu_uuid,used_memory --format=csv,noheader,nounits 2>/dev/null | while read line; do
+		echo "$GPU_APPS" | while read line; do
 			APP_PID=$(echo "$line" | cut -d',' -f1 | tr -d ' ')
 			if [ "$APP_PID" = "$PID" ]; then
 				GPU_UUID=$(echo "$line" | cut -d',' -f2 | tr -d ' ')
 				GPU_MEM=$(echo "$line" | cut -d',' -f3 | tr -d ' ')
 				# Get GPU index from UUID
-				GPU_IDX=$(nvidia-smi --query-gpu=index,uuid --format=csv,noheader 2>/dev/null | grep "$GPU_UUID" | cut -d',' -f1 | tr -d ' ')
+				GPU_IDX=$(echo "$GPU_UUIDS" | grep "$GPU_UUID" | cut -d',' -f1 | tr -d ' ')
 				echo "GPU_MEM:$GPU_IDX:${GPU_MEM}MiB"
 			fi
 		done
-	`, pidFile)
+	}
+`
+
+// GetJobsProcessStats fetches the process statistics of a host's jobs in a
+// single SSH call, keyed by job ID. Each job's PID file holds the PID to query.
+func GetJobsProcessStats(host string, jobs []JobPIDInfo) (map[int64]*ProcessStats, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	var cmd strings.Builder
+	cmd.WriteString(processStatsScript)
+	for _, job := range jobs {
+		// The PID file is left unquoted so that its ~ expands
+		fmt.Fprintf(&cmd, "echo JOB:%d; job_stats %s\n", job.JobID, job.PIDFile)
+	}
 
-	stdout, _, err := RunWithTimeout(host, cmd, 15*time.Second)
+	stdout, _, err := RunWithTimeout(host, cmd.String(), max(15*time.Second, HostTimeouts(host).Probe))
 	if err != nil {
-		return &ProcessStats{Error: err.Error()}, err
+		return nil, err
 	}
 
-	return parseProcessStats(stdout), nil
+	return parseJobsProcessStats(stdout), nil
+}
+
+// parseJobsProcessStats splits the output of several job_stats calls, each
+// after a JOB:id line, and parses each job's section
+func parseJobsProcessStats(output string) map[int64]*ProcessStats {
+	stats := make(map[int64]*ProcessStats)
+	var jobID int64
+	var section strings.Builder
+	flush := func() {
+		if jobID != 0 {
+			stats[jobID] = parseProcessStats(section.String())
+		}
+		section.Reset()
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if id, ok := strings.CutPrefix(strings.TrimSpace(line), "JOB:"); ok {
+			flush()
+			jobID, _ = strconv.ParseInt(id, 10, 64)
+			continue
+		}
+		section.WriteString(line + "\n")
+	}
+	flush()
+	return stats
 }
 
 // parseProcessStats parses the output of the process stats command
@@ -478,15 +616,31 @@ func parseProcessStats(output string) *ProcessStats {
 		case "RUNNING":
 			stats.Running = value == "YES"
 		case "CPU_USER":
-			stats.CPUUser = formatDuration(value)
+			stats.CPUUser = formatSeconds(value)
 		case "CPU_SYS":
-			stats.CPUSys = formatDuration(value)
+			stats.CPUSys = formatSeconds(value)
 		case "CPU_USER_TICKS":
 			fmt.Sscanf(value, "%d", &stats.CPUUserTicks)
 		case "CPU_SYS_TICKS":
 			fmt.Sscanf(value, "%d", &stats.CPUSysTicks)
+		case "PS_CPU":
+			// Format: PS_CPU:user total, from ps on hosts without /proc.
+			// Times are kept in hundredths of a second, matching the usual CLK_TCK.
+			fields := strings.Fields(value)
+			if len(fields) != 2 {
+				continue
+			}
+			user, okUser := parsePSTime(fields[0])
+			total, okTotal := parsePSTime(fields[1])
+			if !okUser || !okTotal || total < user {
+				continue
+			}
+			stats.CPUUserTicks = user
+			stats.CPUSysTicks = total - user
+			stats.CPUUser = humanfmt.Duration(user / 100)
+			stats.CPUSys = humanfmt.Duration((total - user) / 100)
 		case "MEM_RSS_KB":
-			stats.MemoryRSS = formatMemoryKB(value)
+			stats.MemoryRSS = formatKB(value)
 		case "MEM_TOTAL_KB":
 			// Calculate percentage if we have RSS
 			if stats.MemoryRSS != "" {
@@ -529,39 +683,56 @@ func parseProcessStats(output string) *ProcessStats {
 	return stats
 }
 
-// formatDuration converts seconds to a human-readable duration
-func formatDuration(seconds string) string {
-	var sec int
-	if _, err := fmt.Sscanf(seconds, "%d", &sec); err != nil {
-		return seconds
+// parsePSTime parses a CPU time printed by ps, such as "1:02.50" on macOS or
+// "1-02:03:04" on Linux ([[dd-]hh:]mm:ss[.ss]), into hundredths of a second
+func parsePSTime(s string) (int64, bool) {
+	var days int64
+	if d, rest, ok := strings.Cut(s, "-"); ok {
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		days, s = n, rest
 	}
-
-	if sec < 60 {
-		return fmt.Sprintf("%ds", sec)
-	} else if sec < 3600 {
-		return fmt.Sprintf("%dm%ds", sec/60, sec%60)
-	} else {
-		h := sec / 3600
-		m := (sec % 3600) / 60
-		s := sec % 60
-		return fmt.Sprintf("%dh%dm%ds", h, m, s)
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, false
+	}
+	total := days*86400*100 + int64(math.Round(seconds*100))
+	unit := int64(60 * 100)
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += n * unit
+		unit *= 60
 	}
+	return total, true
 }
 
-// formatMemoryKB converts kB to human-readable format
-func formatMemoryKB(kb string) string {
-	var kbVal int
-	if _, err := fmt.Sscanf(kb, "%d", &kbVal); err != nil {
-		return kb + " kB"
+// formatSeconds formats a number of seconds reported by a stats script, or
+// returns it as is if it isn't one
+func formatSeconds(value string) string {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value
 	}
+	return humanfmt.Duration(seconds)
+}
 
-	if kbVal < 1024 {
-		return fmt.Sprintf("%d kB", kbVal)
-	} else if kbVal < 1024*1024 {
-		return fmt.Sprintf("%.1f MB", float64(kbVal)/1024)
-	} else {
-		return fmt.Sprintf("%.1f GB", float64(kbVal)/(1024*1024))
+// formatKB formats a size in KiB reported by a stats script, or returns it
+// with its unit if it isn't a number
+func formatKB(value string) string {
+	kb, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return value + " KiB"
 	}
+	return humanfmt.KiB(kb)
 }
 
 // calculateMemoryPct calculates memory percentage from the output
@@ -584,6 +755,47 @@ func calculateMemoryPct(output string) string {
 	return ""
 }
 
+// ScriptsDir is the directory on remote hosts that helper scripts are deployed to
+const ScriptsDir = "~/.cache/remote-jobs/scripts"
+
+// scriptMissing is printed by the command RunScript sends when the script
+// hasn't been deployed yet
+const scriptMissing = "RJ_SCRIPT_MISSING"
+
+// ScriptPath returns where RunScript deploys a script. The name includes a
+// hash of the content, so a changed script is deployed afresh.
+func ScriptPath(name string, script []byte) string {
+	sum := sha256.Sum256(script)
+	return fmt.Sprintf("%s/%s-%x.sh", ScriptsDir, name, sum[:4])
+}
+
+// deployScriptCommand returns a shell command that deploys a script to its
+// ScriptPath. The script is written to a temporary file and renamed into
+// place, so that a concurrent run never reads it half-written, and the
+// earlier versions of the script are removed.
+func deployScriptCommand(name string, script []byte) string {
+	dir := shellquote.HomePath(ScriptsDir)
+	path := shellquote.HomePath(ScriptPath(name, script))
+	return fmt.Sprintf(`mkdir -p %[1]s && rj_tmp=$(mktemp %[1]s/.%[2]s.XXXXXX) && `+
+		`printf '%%s\n' %[3]s > "$rj_tmp" && chmod 755 "$rj_tmp" && mv -f "$rj_tmp" %[4]s && `+
+		`for rj_old in %[1]s/%[2]s-????????.sh; do [ "$rj_old" = %[4]s ] || rm -f "$rj_old"; done`,
+		dir, name, shellquote.Quote(string(script)), path)
+}
+
+// RunScript runs a helper script on a host, with args already quoted for the
+// remote shell. It takes one SSH call once the script is deployed; the first
+// call on a host takes a second one to deploy it.
+func RunScript(host, name string, script []byte, args string, timeout time.Duration) (string, string, error) {
+	path := shellquote.HomePath(ScriptPath(name, script))
+	runCmd := fmt.Sprintf("if [ -f %s ]; then sh %s %s; else echo %s; fi", path, path, args, scriptMissing)
+	stdout, stderr, err := RunWithTimeout(host, runCmd, timeout)
+	if err != nil || strings.TrimSpace(stdout) != scriptMissing {
+		return stdout, stderr, err
+	}
+
+	return RunWithTimeout(host, deployScriptCommand(name, script)+" && sh "+path+" "+args, timeout)
+}
+
 // JobPIDInfo holds job ID and PID file path for GPU mapping
 type JobPIDInfo struct {
 	JobID   int64
@@ -592,9 +804,10 @@ type JobPIDInfo struct {
 
 // JobGPUMapping holds the result of GPU job mapping
 type JobGPUMapping struct {
-	JobID    int64
-	GPUIndex int
-	MemMiB   int
+	JobID       int64
+	GPUIndex    int
+	MemMiB      int
+	Utilization int // GPU utilization % (-1 if not reported)
 }
 
 // GetJobGPUMappings runs the GPU job mapping script and returns the results
@@ -610,33 +823,35 @@ func GetJobGPUMappings(host string, script []byte, jobs []JobPIDInfo) ([]JobGPUM
 		args = append(args, fmt.Sprintf("%d:%s", job.JobID, job.PIDFile))
 	}
 
-	// Write script to remote and execute with arguments
-	remoteScript := "/tmp/remote-jobs-gpu-mapping.sh"
-	writeCmd := fmt.Sprintf("cat > '%s' << 'SCRIPT_EOF'\n%s\nSCRIPT_EOF && chmod +x '%s'",
-		remoteScript, string(script), remoteScript)
-
-	if _, _, err := RunWithTimeout(host, writeCmd, 10*time.Second); err != nil {
+	// Write script to remote and execute with arguments. It's a bash script,
+	// so it runs directly rather than through RunScript's sh.
+	remoteScript := ScriptPath("gpu-mapping", script)
+	if _, _, err := RunWithTimeout(host, deployScriptCommand("gpu-mapping", script), HostTimeouts(host).Probe); err != nil {
 		return nil, fmt.Errorf("write script: %w", err)
 	}
 
 	// Run the script with job arguments
-	runCmd := fmt.Sprintf("'%s' %s", remoteScript, strings.Join(args, " "))
-	stdout, _, err := RunWithTimeout(host, runCmd, 15*time.Second)
+	runCmd := shellquote.Path(remoteScript) + " " + shellquote.Join(args...)
+	stdout, _, err := RunWithTimeout(host, runCmd, max(15*time.Second, HostTimeouts(host).Probe))
 	if err != nil {
 		// Script might fail if no GPUs or no nvidia-smi, that's okay
 		return nil, nil
 	}
 
-	// Parse output: JOB_GPU:job_id:gpu_index:mem_mib
+	return parseJobGPUMappings(stdout), nil
+}
+
+// parseJobGPUMappings parses JOB_GPU:job_id:gpu_index:mem_mib[:util_pct] lines
+func parseJobGPUMappings(output string) []JobGPUMapping {
 	var mappings []JobGPUMapping
-	for _, line := range strings.Split(stdout, "\n") {
+	for _, line := range strings.Split(output, "\n") {
 		line = strings.TrimSpace(line)
 		if !strings.HasPrefix(line, "JOB_GPU:") {
 			continue
 		}
 
 		parts := strings.Split(strings.TrimPrefix(line, "JOB_GPU:"), ":")
-		if len(parts) != 3 {
+		if len(parts) != 3 && len(parts) != 4 {
 			continue
 		}
 
@@ -645,13 +860,20 @@ func GetJobGPUMappings(host string, script []byte, jobs []JobPIDInfo) ([]JobGPUM
 		fmt.Sscanf(parts[0], "%d", &jobID)
 		fmt.Sscanf(parts[1], "%d", &gpuIdx)
 		fmt.Sscanf(parts[2], "%d", &memMiB)
+		util := -1
+		if len(parts) == 4 {
+			if _, err := fmt.Sscanf(parts[3], "%d", &util); err != nil {
+				util = -1
+			}
+		}
 
 		mappings = append(mappings, JobGPUMapping{
-			JobID:    jobID,
-			GPUIndex: gpuIdx,
-			MemMiB:   memMiB,
+			JobID:       jobID,
+			GPUIndex:    gpuIdx,
+			MemMiB:      memMiB,
+			Utilization: util,
 		})
 	}
 
-	return mappings, nil
+	return mappings
 }
@@ -0,0 +1,90 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is wrapped by the error of a command that RunWithTimeout gave up
+// on. The output received before then is returned along with it.
+var ErrTimeout = errors.New("ssh command timed out")
+
+// Timeouts are how long to wait on a host's commands
+type Timeouts struct {
+	Sync     time.Duration // Each check of a job's status
+	FastSync time.Duration // The sync of a host before job list and job status
+	Probe    time.Duration // Queries of the host's info, load, GPUs, and events
+	Connect  time.Duration // Establishing a connection (ssh's ConnectTimeout)
+}
+
+// DefaultTimeouts are the timeouts of hosts that don't configure their own
+var DefaultTimeouts = Timeouts{
+	Sync:     5 * time.Second,
+	FastSync: 2 * time.Second,
+	Probe:    10 * time.Second,
+	Connect:  10 * time.Second,
+}
+
+var (
+	timeoutsMu      sync.Mutex
+	defaultTimeouts = DefaultTimeouts
+	hostTimeouts    = make(map[string]Timeouts)
+)
+
+// SetTimeouts sets the timeouts of every host, and of the hosts in hosts.
+// Zero durations in either are taken from DefaultTimeouts.
+func SetTimeouts(defaults Timeouts, hosts map[string]Timeouts) {
+	timeoutsMu.Lock()
+	defer timeoutsMu.Unlock()
+	defaultTimeouts = defaults.orDefaults(DefaultTimeouts)
+	hostTimeouts = make(map[string]Timeouts, len(hosts))
+	for host, t := range hosts {
+		hostTimeouts[host] = t.orDefaults(defaultTimeouts)
+	}
+}
+
+// HostTimeouts returns the timeouts of host: those configured for it, each
+// stretched to allow for its recorded latency (see AdaptTimeout). The
+// timeouts of a "user@host" host are those of host.
+func HostTimeouts(host string) Timeouts {
+	host = hostName(host)
+	timeoutsMu.Lock()
+	t, ok := hostTimeouts[host]
+	if !ok {
+		t = defaultTimeouts
+	}
+	timeoutsMu.Unlock()
+	return Timeouts{
+		Sync:     AdaptTimeout(host, t.Sync),
+		FastSync: AdaptTimeout(host, t.FastSync),
+		Probe:    AdaptTimeout(host, t.Probe),
+		Connect:  AdaptTimeout(host, t.Connect),
+	}
+}
+
+// orDefaults returns t with its zero durations replaced by those of defaults
+func (t Timeouts) orDefaults(defaults Timeouts) Timeouts {
+	pick := func(d, def time.Duration) time.Duration {
+		if d > 0 {
+			return d
+		}
+		return def
+	}
+	return Timeouts{
+		Sync:     pick(t.Sync, defaults.Sync),
+		FastSync: pick(t.FastSync, defaults.FastSync),
+		Probe:    pick(t.Probe, defaults.Probe),
+		Connect:  pick(t.Connect, defaults.Connect),
+	}
+}
+
+// CompleteLines returns the lines of output that arrived whole, dropping the
+// last one if a timeout cut it off
+func CompleteLines(output string) string {
+	if i := strings.LastIndexByte(output, '\n'); i >= 0 {
+		return output[:i+1]
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package ssh
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	usersMu   sync.Mutex
+	hostUsers = make(map[string]string)
+)
+
+// SetUsers sets the account to log in to each host in users as, for hosts
+// named without one. Hosts not in it log in as SSH chooses: the User in
+// ~/.ssh/config, or the local user name.
+func SetUsers(users map[string]string) {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	hostUsers = make(map[string]string, len(users))
+	for host, user := range users {
+		if user != "" {
+			hostUsers[host] = user
+		}
+	}
+}
+
+// User returns the account that commands for host run as: the one named in
+// a "user@host" host, otherwise the one set with SetUsers, or "" if SSH
+// chooses it or the host is local
+func User(host string) string {
+	if user, _, ok := strings.Cut(host, "@"); ok {
+		return user
+	}
+	if IsLocal(host) {
+		return ""
+	}
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	return hostUsers[host]
+}
+
+// Target returns the destination ssh, scp, and sftp connect to for host:
+// "user@host" if a user is set for it, otherwise host as it is
+func Target(host string) string {
+	if strings.Contains(host, "@") || IsLocal(host) {
+		return host
+	}
+	if user := User(host); user != "" {
+		return user + "@" + host
+	}
+	return host
+}
+
+// WithUser returns the host to run a job's commands on, given the account
+// the job was started as: host as it is, unless commands for host would now
+// log in as another account, as after users in config.yaml has changed, in
+// which case "user@host"
+func WithUser(host, user string) string {
+	if user == "" || strings.Contains(host, "@") || IsLocal(host) || user == User(host) {
+		return host
+	}
+	return user + "@" + host
+}
+
+// hostName returns host without the account of a "user@host" host
+func hostName(host string) string {
+	if _, name, ok := strings.Cut(host, "@"); ok {
+		return name
+	}
+	return host
+}
@@ -0,0 +1,130 @@
+// Package taskrunner finds Makefile targets and justfile recipes, so that
+// `run --make` and `run --just` can start them as remote jobs.
+package taskrunner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/osteele/remote-jobs/internal/shellquote"
+)
+
+// Runner is a local task runner
+type Runner struct {
+	Name  string   // Program run on the remote host
+	Files []string // File names it reads, in order of precedence
+	parse func(content string) []string
+}
+
+// Make runs Makefile targets
+var Make = Runner{
+	Name:  "make",
+	Files: []string{"GNUmakefile", "makefile", "Makefile"},
+	parse: MakeTargets,
+}
+
+// Just runs justfile recipes
+var Just = Runner{
+	Name:  "just",
+	Files: []string{"justfile", "Justfile", ".justfile"},
+	parse: JustRecipes,
+}
+
+// Find returns the path of the runner's file in dir or its nearest ancestor
+// that has one
+func (r Runner) Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range r.Files {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s found in this directory or its parents", r.Files[len(r.Files)-1])
+		}
+		dir = parent
+	}
+}
+
+// Targets returns the sorted target or recipe names in the file at path
+func (r Runner) Targets(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.parse(string(content)), nil
+}
+
+// Command returns the command line that runs target with args (a shell
+// fragment, may be empty)
+func (r Runner) Command(target, args string) string {
+	command := r.Name + " " + shellquote.Quote(target)
+	if args = strings.TrimSpace(args); args != "" {
+		command += " " + args
+	}
+	return command
+}
+
+var makeRule = regexp.MustCompile(`^([^\s:=#][^:=#]*?)\s*::?(\s|$|[^=:])`)
+
+// MakeTargets returns the explicit targets in a Makefile. Special targets
+// (.PHONY etc.), pattern rules, and variable assignments are skipped.
+func MakeTargets(content string) []string {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			continue
+		}
+		m := makeRule.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, name := range strings.Fields(m[1]) {
+			if strings.HasPrefix(name, ".") || strings.ContainsAny(name, "%$()") {
+				continue
+			}
+			seen[name] = true
+		}
+	}
+	return sortedNames(seen)
+}
+
+var justRecipe = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)[^:]*:([^=]|$)`)
+
+// justKeywords begin justfile lines that aren't recipes
+var justKeywords = map[string]bool{
+	"alias": true, "export": true, "import": true, "mod": true, "set": true,
+}
+
+// JustRecipes returns the public recipes in a justfile. Private recipes
+// (named with a leading underscore) are skipped.
+func JustRecipes(content string) []string {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		m := justRecipe.FindStringSubmatch(line)
+		if m == nil || justKeywords[m[1]] || strings.HasPrefix(m[1], "_") {
+			continue
+		}
+		seen[m[1]] = true
+	}
+	return sortedNames(seen)
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
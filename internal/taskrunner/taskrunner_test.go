@@ -0,0 +1,94 @@
+package taskrunner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMakeTargets(t *testing.T) {
+	content := `CC := gcc
+PYTHON ?= python3
+DATA = a:b
+.PHONY: train eval
+.DEFAULT_GOAL := train
+
+train: data
+	$(PYTHON) train.py
+
+eval data: env
+	@echo $@ :done
+
+%.o: %.c
+	$(CC) -c $<
+$(OUT): train
+install::
+	cp x y
+clean ::= nothing
+# comment: not a target
+`
+	want := []string{"data", "eval", "install", "train"}
+	if got := MakeTargets(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("MakeTargets() = %v, want %v", got, want)
+	}
+}
+
+func TestJustRecipes(t *testing.T) {
+	content := `set shell := ["bash", "-c"]
+export CUDA := "0"
+version := "1.0"
+alias t := train
+
+# Train the model
+train epochs='10': build
+    python train.py --epochs {{epochs}}
+
+@build:
+    echo building
+
+_helper:
+    true
+
+[private]
+serve url='http://localhost:8000':
+    open {{url}}
+`
+	want := []string{"build", "serve", "train"}
+	if got := JustRecipes(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("JustRecipes() = %v, want %v", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	makefile := filepath.Join(root, "Makefile")
+	if err := os.WriteFile(makefile, []byte("all:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Make.Find(sub)
+	if err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if got != makefile {
+		t.Errorf("Find() = %q, want %q", got, makefile)
+	}
+
+	if _, err := Just.Find(sub); err == nil {
+		t.Error("Find() found a justfile in a tree without one")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if got, want := Make.Command("train", " EPOCHS=3 "), "make train EPOCHS=3"; got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+	if got, want := Just.Command("train", ""), "just train"; got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+}
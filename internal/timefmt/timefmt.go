@@ -0,0 +1,107 @@
+// Package timefmt formats job times for display: in the local time zone,
+// UTC, or the time zone of the job's host, and as absolute or relative times.
+package timefmt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+)
+
+// Time zones times can be shown in
+const (
+	ZoneLocal = "local"
+	ZoneUTC   = "utc"
+	ZoneHost  = "host" // The job's host, as recorded when the job started
+)
+
+// Styles for short times
+const (
+	StyleAuto     = "auto"     // Relative for the last 12 hours, absolute before that
+	StyleAbsolute = "absolute" // e.g. "01/02 15:04"
+	StyleRelative = "relative" // e.g. "3h ago"
+)
+
+// autoRelativeWindow is how far back StyleAuto shows relative times
+const autoRelativeWindow = 12 * time.Hour
+
+// Options controls how times are displayed. Empty fields take the defaults:
+// the local time zone, and the style the caller passes to WithDefaultStyle.
+type Options struct {
+	Zone  string `yaml:"zone"`
+	Style string `yaml:"style"`
+}
+
+// Validate returns an error if the zone or style isn't recognized
+func (o Options) Validate() error {
+	switch o.Zone {
+	case "", ZoneLocal, ZoneUTC, ZoneHost:
+	default:
+		return fmt.Errorf("unknown time zone %q (valid: local, utc, host)", o.Zone)
+	}
+	switch o.Style {
+	case "", StyleAuto, StyleAbsolute, StyleRelative:
+	default:
+		return fmt.Errorf("unknown time style %q (valid: auto, absolute, relative)", o.Style)
+	}
+	return nil
+}
+
+// WithDefaultStyle returns o with style filled in if none was set
+func (o Options) WithDefaultStyle(style string) Options {
+	if o.Style == "" {
+		o.Style = style
+	}
+	return o
+}
+
+// HostZone returns the time zone for a host's UTC offset in seconds, or nil
+// if the offset is unknown
+func HostZone(utcOffset *int) *time.Location {
+	if utcOffset == nil {
+		return nil
+	}
+	name := time.Unix(0, 0).In(time.FixedZone("", *utcOffset)).Format("-0700")
+	return time.FixedZone(name, *utcOffset)
+}
+
+// location returns the zone to show times in. Host times fall back to the
+// local zone when the host's zone is unknown.
+func (o Options) location(host *time.Location) *time.Location {
+	switch {
+	case o.Zone == ZoneUTC:
+		return time.UTC
+	case o.Zone == ZoneHost && host != nil:
+		return host
+	default:
+		return time.Local
+	}
+}
+
+// Short formats a Unix time for a table column, or "—" if it is unset. host
+// is the job host's zone, or nil if unknown.
+func (o Options) Short(unix int64, host *time.Location, now time.Time) string {
+	if unix <= 0 {
+		return "—"
+	}
+	t := time.Unix(unix, 0)
+	switch o.Style {
+	case StyleRelative:
+		return humanfmt.Relative(now.Sub(t))
+	case StyleAuto:
+		if now.Sub(t) < autoRelativeWindow {
+			return humanfmt.Relative(now.Sub(t))
+		}
+	}
+	loc := o.location(host)
+	if loc == time.Local {
+		return t.Format("01/02 15:04")
+	}
+	return t.In(loc).Format("01/02 15:04 MST")
+}
+
+// Full formats a Unix time with the date, seconds, and zone, for detail views
+func (o Options) Full(unix int64, host *time.Location) string {
+	return time.Unix(unix, 0).In(o.location(host)).Format("2006-01-02 15:04:05 MST")
+}
@@ -0,0 +1,58 @@
+package timefmt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShort(t *testing.T) {
+	now := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	offset := 2 * 3600
+	host := HostZone(&offset)
+	recent := now.Add(-3 * time.Hour).Unix()
+	old := now.Add(-72 * time.Hour).Unix()
+
+	tests := []struct {
+		name string
+		opts Options
+		unix int64
+		host *time.Location
+		want string
+	}{
+		{"unset", Options{}, 0, nil, "—"},
+		{"relative", Options{Style: StyleRelative}, old, nil, "3d ago"},
+		{"auto recent", Options{Style: StyleAuto}, recent, nil, "3h ago"},
+		{"auto old utc", Options{Zone: ZoneUTC, Style: StyleAuto}, old, nil, "03/07 18:00 UTC"},
+		{"absolute host", Options{Zone: ZoneHost, Style: StyleAbsolute}, recent, host, "03/10 17:00 +0200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Short(tt.unix, tt.host, now); got != tt.want {
+				t.Errorf("Short() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFull(t *testing.T) {
+	unix := time.Date(2024, 3, 10, 18, 0, 5, 0, time.UTC).Unix()
+	offset := -5 * 3600
+	if got, want := (Options{Zone: ZoneHost}).Full(unix, HostZone(&offset)), "2024-03-10 13:00:05 -0500"; got != want {
+		t.Errorf("Full() = %q, want %q", got, want)
+	}
+	if got, want := (Options{Zone: ZoneUTC}).Full(unix, nil), "2024-03-10 18:00:05 UTC"; got != want {
+		t.Errorf("Full() = %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Options{Zone: ZoneHost, Style: StyleRelative}).Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	if err := (Options{Zone: "Europe/Paris"}).Validate(); err == nil {
+		t.Error("Validate() accepted an unknown zone")
+	}
+	if err := (Options{Style: "iso"}).Validate(); err == nil {
+		t.Error("Validate() accepted an unknown style")
+	}
+}
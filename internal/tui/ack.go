@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// toggleAck acknowledges a failed job, or a running job the idle-GPU
+// watchdog has flagged, or removes the acknowledgment of one that has been
+// acknowledged. The job's stats are updated in place so that the list shows
+// the change before the next refresh.
+func (m Model) toggleAck(job *db.Job) (Model, tea.Cmd) {
+	alerting, acked := m.ackState(job)
+	if acked {
+		if _, err := db.UnackJob(m.database, job.ID); err != nil {
+			return m, m.setFlash(fmt.Sprintf("Unacknowledge failed: %v", err), true)
+		}
+		m.setAckedAt(job.ID, 0)
+		return m, m.setFlash(fmt.Sprintf("Job %d unacknowledged", job.ID), false)
+	}
+	if !alerting {
+		return m, m.setFlash(fmt.Sprintf("Job %d has nothing to acknowledge", job.ID), false)
+	}
+	now := time.Now().Unix()
+	if err := db.AckJob(m.database, job.ID, now); err != nil {
+		return m, m.setFlash(fmt.Sprintf("Acknowledge failed: %v", err), true)
+	}
+	m.setAckedAt(job.ID, now)
+	return m, m.setFlash(fmt.Sprintf("Job %d acknowledged", job.ID), false)
+}
+
+// setAckedAt records a job's acknowledgment in its stats
+func (m *Model) setAckedAt(jobID, ackedAt int64) {
+	if m.jobStats == nil {
+		m.jobStats = make(map[int64]*db.JobStats)
+	}
+	s := m.jobStats[jobID]
+	if s == nil {
+		s = &db.JobStats{JobID: jobID}
+		m.jobStats[jobID] = s
+	}
+	s.AckedAt = ackedAt
+}
+
+// ackState reports whether a job has a failure or idle-GPU flag to
+// acknowledge, and whether one has been acknowledged
+func (m Model) ackState(job *db.Job) (alerting, acked bool) {
+	stats := m.jobStats[job.ID]
+	if statusGroup(job) == "failed" {
+		acked = stats.Acked(job)
+		return !acked || stats.IdleGPU(), acked
+	}
+	return stats.IdleGPU(), !stats.IdleGPU() && stats != nil && stats.AckedAt > 0
+}
+
+// acked reports whether a job's failure has been acknowledged, for showing
+// it dimmed
+func (m Model) acked(job *db.Job) bool {
+	return statusGroup(job) == "failed" && m.jobStats[job.ID].Acked(job)
+}
+
+// toggleHostAck acknowledges the incidents recorded on a host, or removes
+// the acknowledgment if there are none newer to acknowledge
+func (m Model) toggleHostAck(host *Host) (Model, tea.Cmd) {
+	unacked := host.UnackedEvents()
+	if len(unacked) == 0 {
+		if host.EventsAckedAt == 0 {
+			return m, m.setFlash(fmt.Sprintf("%s has no incidents to acknowledge", host.Name), false)
+		}
+		if _, err := db.UnackHost(m.database, host.Name); err != nil {
+			return m, m.setFlash(fmt.Sprintf("Unacknowledge failed: %v", err), true)
+		}
+		host.EventsAckedAt = 0
+		return m, m.setFlash(fmt.Sprintf("Incidents on %s unacknowledged", host.Name), false)
+	}
+	// Events are stamped by the host's clock, which may be ahead of ours
+	now := max(time.Now().Unix(), unacked[0].Time)
+	if err := db.AckHost(m.database, host.Name, now); err != nil {
+		return m, m.setFlash(fmt.Sprintf("Acknowledge failed: %v", err), true)
+	}
+	host.EventsAckedAt = now
+	return m, m.setFlash(fmt.Sprintf("Incidents on %s acknowledged", host.Name), false)
+}
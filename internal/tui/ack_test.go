@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestAckState(t *testing.T) {
+	end, exit := int64(1500), 1
+	failed := &db.Job{ID: 1, Status: db.StatusCompleted, ExitCode: &exit, EndTime: &end}
+	running := &db.Job{ID: 2, Status: db.StatusRunning}
+	tests := []struct {
+		name            string
+		job             *db.Job
+		stats           *db.JobStats
+		alerting, acked bool
+		dimmed          bool
+	}{
+		{"failed", failed, nil, true, false, false},
+		{"failed and acked", failed, &db.JobStats{AckedAt: 1600}, false, true, true},
+		{"running", running, nil, false, false, false},
+		{"idle GPUs", running, &db.JobStats{GPUIdleFlaggedAt: 1200}, true, false, false},
+		{"idle GPUs acked", running, &db.JobStats{GPUIdleFlaggedAt: 1200, AckedAt: 1300}, false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Model{jobStats: map[int64]*db.JobStats{}}
+			if tt.stats != nil {
+				m.jobStats[tt.job.ID] = tt.stats
+			}
+			alerting, acked := m.ackState(tt.job)
+			if alerting != tt.alerting || acked != tt.acked {
+				t.Errorf("ackState() = %v, %v, want %v, %v", alerting, acked, tt.alerting, tt.acked)
+			}
+			if got := m.acked(tt.job); got != tt.dimmed {
+				t.Errorf("acked() = %v, want %v", got, tt.dimmed)
+			}
+		})
+	}
+}
+
+func TestToggleHostAck(t *testing.T) {
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	future := time.Now().Add(time.Hour).Unix() // The host's clock may be ahead
+	host := &Host{Name: "cool30", Events: []db.HostEvent{{Host: "cool30", Time: future, Kind: "xid"}}}
+	m := Model{database: database}
+
+	m, _ = m.toggleHostAck(host)
+	if len(host.UnackedEvents()) != 0 {
+		t.Fatalf("after ack, unacknowledged events = %v", host.UnackedEvents())
+	}
+	if at, _ := db.HostAckedAt(database, "cool30"); at != host.EventsAckedAt {
+		t.Errorf("recorded ack at %d, want %d", at, host.EventsAckedAt)
+	}
+
+	m.toggleHostAck(host)
+	if len(host.UnackedEvents()) != 1 {
+		t.Errorf("after toggling again, unacknowledged events = %v, want the event", host.UnackedEvents())
+	}
+	if at, _ := db.HostAckedAt(database, "cool30"); at != 0 {
+		t.Errorf("ack still recorded at %d", at)
+	}
+}
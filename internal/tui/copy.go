@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/clipboard"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// copyField is something about a job that can be copied to the clipboard,
+// chosen by the key pressed after the copy key
+type copyField struct {
+	key   string
+	name  string // e.g., "log path"
+	value func(job *db.Job) string
+}
+
+var copyFields = []copyField{
+	{"c", "command", func(job *db.Job) string { return job.EffectiveCommand() }},
+	{"l", "log path", jobLogPath},
+	{"s", "ssh tail command", func(job *db.Job) string {
+		if ssh.IsLocal(job.Host) {
+			return "tail -f " + jobLogPath(job)
+		}
+		return "ssh " + shellquote.Quote(job.Host) + " " + shellquote.Quote("tail -f "+jobLogPath(job))
+	}},
+	{"i", "job ID", func(job *db.Job) string { return fmt.Sprint(job.ID) }},
+}
+
+type clipboardCopiedMsg struct {
+	jobID  int64
+	name   string
+	text   string
+	copied bool // A local clipboard tool took the text
+}
+
+// clipboardWrittenMsg is sent once the frame carrying an OSC 52 sequence has
+// been rendered
+type clipboardWrittenMsg struct{ sequence string }
+
+// clipboardSequenceLifetime is how long an OSC 52 sequence stays in the view:
+// long enough for the renderer to draw a frame with it
+const clipboardSequenceLifetime = 100 * time.Millisecond
+
+// jobLogPath returns the remote path of a job's log file. It starts with ~,
+// for the remote shell to expand. A job that hasn't started yet has no start
+// time in its file name, so its path is a glob.
+func jobLogPath(job *db.Job) string {
+	if job.SessionName == "" && job.StartTime == 0 {
+		return session.LogFilePattern(job.ID)
+	}
+	return session.JobLogFile(job.ID, job.StartTime, job.SessionName)
+}
+
+// copyPrompt lists the keys that pick what to copy
+func copyPrompt(job *db.Job) string {
+	var choices []string
+	for _, f := range copyFields {
+		choices = append(choices, f.key+": "+f.name)
+	}
+	return fmt.Sprintf("Copy from job %d — %s (Esc: cancel)", job.ID, strings.Join(choices, " • "))
+}
+
+// handleCopyKeyPress handles the key pressed after the copy key
+func (m Model) handleCopyKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.copyPending = false
+	m.flashMessage = ""
+	job := m.getTargetJob()
+	if job == nil {
+		return m, nil
+	}
+	for _, f := range copyFields {
+		if msg.String() == f.key {
+			return m, copyToClipboard(job, f)
+		}
+	}
+	return m, nil
+}
+
+// copyToClipboard copies a field of a job to the clipboard
+func copyToClipboard(job *db.Job, f copyField) tea.Cmd {
+	text := f.value(job)
+	return func() tea.Msg {
+		copied := clipboard.CopyWithTool(text)
+		return clipboardCopiedMsg{jobID: job.ID, name: f.name, text: text, copied: copied}
+	}
+}
+
+// handleClipboardCopied reports a copy. If no local clipboard tool took the
+// text, it is sent to the terminal as an OSC 52 sequence. Writing that to
+// stdout directly would race the renderer, and tea.Println prints nothing in
+// the alternate screen, so the sequence rides along with the next frame
+// instead. It takes up no cells.
+func (m Model) handleClipboardCopied(msg clipboardCopiedMsg) (tea.Model, tea.Cmd) {
+	text := fmt.Sprintf("Copied %s of job %d", msg.name, msg.jobID)
+	if msg.copied {
+		return m, m.setFlash(text, false)
+	}
+	m.clipboardSequence = clipboard.Sequence(msg.text)
+	// The terminal can't confirm that it honored the request
+	flash := m.setFlash(text+" (via terminal)", false)
+	return m, tea.Batch(flash, tea.Tick(clipboardSequenceLifetime, func(time.Time) tea.Msg {
+		return clipboardWrittenMsg{sequence: m.clipboardSequence}
+	}))
+}
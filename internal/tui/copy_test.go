@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/clipboard"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/session"
+)
+
+func TestCopyFields(t *testing.T) {
+	job := &db.Job{ID: 42, Host: "cool30", StartTime: 1732400000, Command: "cd ~/code && python train.py"}
+	logFile := session.LogFile(42, 1732400000)
+	want := map[string]string{
+		"c": "python train.py",
+		"l": logFile,
+		"s": "ssh cool30 'tail -f " + logFile + "'",
+		"i": "42",
+	}
+	for _, f := range copyFields {
+		if got := f.value(job); got != want[f.key] {
+			t.Errorf("copy %s = %q, want %q", f.name, got, want[f.key])
+		}
+	}
+
+	local := &db.Job{ID: 42, Host: "localhost", StartTime: 1732400000}
+	if got := copyFields[2].value(local); got != "tail -f "+logFile {
+		t.Errorf("copy ssh tail command for a local job = %q, want a plain tail", got)
+	}
+
+	queued := &db.Job{ID: 7, Host: "cool30", Status: db.StatusQueued}
+	if got, want := jobLogPath(queued), "~/.cache/remote-jobs/logs/7-*.log"; got != want {
+		t.Errorf("jobLogPath(queued) = %q, want %q", got, want)
+	}
+}
+
+func TestCopyKeys(t *testing.T) {
+	m := Model{jobs: []*db.Job{{ID: 42, Host: "cool30", Command: "make"}}}
+
+	model, _ := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = model.(Model)
+	if !m.copyPending || m.flashMessage == "" {
+		t.Fatalf("after y: copyPending %v, flash %q", m.copyPending, m.flashMessage)
+	}
+
+	model, cmd := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("i")})
+	m = model.(Model)
+	if m.copyPending || cmd == nil {
+		t.Errorf("after y i: copyPending %v, command %v", m.copyPending, cmd)
+	}
+
+	model, _ = m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	model, cmd = model.(Model).handleKeyPress(tea.KeyMsg{Type: tea.KeyEsc})
+	if m := model.(Model); m.copyPending || m.flashMessage != "" || cmd != nil {
+		t.Errorf("after y Esc: copyPending %v, flash %q", m.copyPending, m.flashMessage)
+	}
+}
+
+func TestClipboardSequenceRidesWithFrame(t *testing.T) {
+	m := Model{width: 100, height: 40}
+	model, _ := m.Update(clipboardCopiedMsg{jobID: 42, name: "job ID", text: "42"})
+	m = model.(Model)
+	if !strings.HasSuffix(m.View(), clipboard.Sequence("42")) {
+		t.Fatal("the frame after a copy doesn't carry the OSC 52 sequence")
+	}
+	if !strings.Contains(m.flashMessage, "via terminal") {
+		t.Errorf("flash = %q, want it to say the copy went via the terminal", m.flashMessage)
+	}
+
+	model, _ = m.Update(clipboardWrittenMsg{sequence: m.clipboardSequence})
+	if m := model.(Model); strings.Contains(m.View(), "\x1b]52;") {
+		t.Error("the sequence is still in the view after it was written")
+	}
+}
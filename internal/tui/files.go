@@ -0,0 +1,255 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// filesListedMsg carries the listing of dir, a directory on host
+type filesListedMsg struct {
+	host  string
+	dir   string // As requested
+	abs   string // Absolute path of the directory
+	files []ssh.RemoteFile
+	err   error
+}
+
+type fileDownloadedMsg struct {
+	name  string
+	local string
+	err   error
+}
+
+// openFileBrowser switches to the file browser, listing dir on host
+func (m Model) openFileBrowser(host, dir string) (Model, tea.Cmd) {
+	if m.viewMode != ViewModeFiles {
+		m.filesReturn = m.viewMode
+	}
+	m.viewMode = ViewModeFiles
+	m.filesHost, m.filesDir = host, "" // Until it is listed
+	m.filesEntries, m.filesIdx, m.filesErr = nil, 0, nil
+	m.filesLocal, _ = os.Getwd()
+	return m, m.browseDir(dir, "")
+}
+
+// browseDir starts listing dir on the browser's host. Once it is listed,
+// the entry named selected is highlighted, if there is one.
+func (m *Model) browseDir(dir, selected string) tea.Cmd {
+	m.filesPending, m.filesSelect = dir, selected
+	host := m.filesHost
+	return func() tea.Msg {
+		abs, files, err := ssh.ListDir(host, dir)
+		return filesListedMsg{host: host, dir: dir, abs: abs, files: files, err: err}
+	}
+}
+
+// handleFilesListed shows a directory's listing, unless another was
+// requested since. A directory that can't be listed leaves the previous one
+// shown.
+func (m Model) handleFilesListed(msg filesListedMsg) (Model, tea.Cmd) {
+	if msg.host != m.filesHost || msg.dir != m.filesPending {
+		return m, nil
+	}
+	m.filesPending = ""
+	if msg.err != nil {
+		if m.filesDir == "" {
+			m.filesDir, m.filesErr = msg.dir, msg.err
+		}
+		return m, m.setFlash(fmt.Sprintf("Can't list %s: %v", msg.dir, msg.err), true)
+	}
+	m.filesDir, m.filesEntries, m.filesErr = msg.abs, msg.files, nil
+	m.filesIdx = 0
+	for i, f := range m.filesEntries {
+		if f.Name == m.filesSelect {
+			m.filesIdx = i
+		}
+	}
+	return m, nil
+}
+
+// selectedFile returns the highlighted entry of the browser, or nil
+func (m Model) selectedFile() *ssh.RemoteFile {
+	if m.filesIdx < len(m.filesEntries) {
+		return &m.filesEntries[m.filesIdx]
+	}
+	return nil
+}
+
+// downloadFile copies a file or directory from the browser's host into the
+// directory the TUI was started in, refusing to replace one already there
+func (m Model) downloadFile(f ssh.RemoteFile) tea.Cmd {
+	host, remote, dir := m.filesHost, path.Join(m.filesDir, f.Name), m.filesLocal
+	return func() tea.Msg {
+		if dir == "" {
+			return fileDownloadedMsg{name: f.Name, err: fmt.Errorf("can't find the current directory")}
+		}
+		local := filepath.Join(dir, f.Name)
+		if _, err := os.Lstat(local); err == nil {
+			return fileDownloadedMsg{name: f.Name, local: local, err: fmt.Errorf("%s already exists", local)}
+		}
+		return fileDownloadedMsg{name: f.Name, local: local, err: ssh.Download(host, remote, local)}
+	}
+}
+
+func (m Model) handleFileDownloaded(msg fileDownloadedMsg) (Model, tea.Cmd) {
+	if msg.err != nil {
+		return m, m.setFlash(fmt.Sprintf("Download of %s failed: %v", msg.name, msg.err), true)
+	}
+	return m, m.setFlash(fmt.Sprintf("Downloaded %s to %s", msg.name, msg.local), false)
+}
+
+// handleFilesKey handles the keys of the file browser: moving through the
+// listing, opening directories, going up, and downloading. Other keys that
+// act on jobs are ignored here; handled is false for those the other views
+// also handle, such as quitting.
+func (m Model) handleFilesKey(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch msg.String() {
+	case "up":
+		m.filesIdx = max(m.filesIdx-1, 0)
+	case "down":
+		m.filesIdx = max(min(m.filesIdx+1, len(m.filesEntries)-1), 0)
+	case "pgup", "pgdown":
+		page := m.filesPageSize()
+		if msg.String() == "pgup" {
+			page = -page
+		}
+		m.filesIdx = max(min(m.filesIdx+page, len(m.filesEntries)-1), 0)
+	case "home":
+		m.filesIdx = 0
+	case "end":
+		m.filesIdx = max(len(m.filesEntries)-1, 0)
+	case "enter", "right":
+		f := m.selectedFile()
+		switch {
+		case f == nil:
+		case f.Dir || f.Link:
+			// A link that isn't to a directory fails to list, with a message
+			return m, m.browseDir(path.Join(m.filesDir, f.Name), ""), true
+		default:
+			return m, m.setFlash("Press d to download "+f.Name, false), true
+		}
+	case "backspace", "left", "-":
+		if m.filesDir != "" && m.filesDir != "/" {
+			return m, m.browseDir(path.Dir(m.filesDir), path.Base(m.filesDir)), true
+		}
+	case "d":
+		if f := m.selectedFile(); f != nil {
+			return m, tea.Batch(m.setFlash(fmt.Sprintf("Downloading %s...", f.Name), false), m.downloadFile(*f)), true
+		}
+	case "r":
+		if m.filesDir == "" {
+			break
+		}
+		selected := ""
+		if f := m.selectedFile(); f != nil {
+			selected = f.Name
+		}
+		return m, m.browseDir(m.filesDir, selected), true
+	case "F", "esc":
+		m.viewMode = m.filesReturn
+	case "q", "ctrl+c", "ctrl+z", "?", ":", "h", "j", "tab":
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// filesPageSize returns how many entries the browser's list panel shows
+func (m Model) filesPageSize() int {
+	list, _ := m.panelHeights()
+	return max(list-4, 1) // Less the borders and header
+}
+
+// renderFiles renders the listing of the browsed directory
+func (m Model) renderFiles(height int) string {
+	var rows []string
+	header := fmt.Sprintf(" %-*s %9s  %s", max(m.width-30, 10), "NAME", "SIZE", "MODIFIED")
+	rows = append(rows, headerStyle.Render(fitWidth(header, m.width-4)))
+	switch {
+	case m.filesErr != nil:
+		rows = append(rows, dimStyle.Render(" "+m.filesErr.Error()))
+	case m.filesDir == "":
+		rows = append(rows, dimStyle.Render(" Loading..."))
+	case len(m.filesEntries) == 0:
+		rows = append(rows, dimStyle.Render(" Empty directory"))
+	}
+
+	// Keep the highlighted entry in view
+	contentHeight := height - 4
+	start := 0
+	if m.filesIdx >= contentHeight && contentHeight > 0 {
+		start = m.filesIdx - contentHeight + 1
+	}
+	for i := start; i < len(m.filesEntries) && i < start+contentHeight; i++ {
+		f := m.filesEntries[i]
+		name, size := f.Name, humanfmt.Bytes(f.Size)
+		switch {
+		case f.Dir:
+			name, size = name+"/", "-"
+		case f.Link:
+			name += "@"
+		}
+		line := fmt.Sprintf(" %-*s %9s  %s", max(m.width-30, 10), name, size, f.Modified)
+		line = fitWidth(line, m.width-4)
+		if i == m.filesIdx {
+			line = selectedStyle.Width(m.width - 4).Render(line)
+		}
+		rows = append(rows, line)
+	}
+	return listPanelStyle.Width(m.width - 2).Height(height).Render(strings.Join(rows, "\n"))
+}
+
+// renderFilesDetail renders the directory's path and the highlighted entry
+func (m Model) renderFilesDetail(height int) string {
+	dir := m.filesDir
+	if dir == "" {
+		dir = m.filesPending
+	}
+	title := fmt.Sprintf("Files on %s: %s", m.filesHost, dir)
+	if m.filesPending != "" && m.filesPending != dir {
+		title += " (opening " + m.filesPending + "...)"
+	}
+
+	var lines []string
+	if f := m.selectedFile(); f != nil {
+		kind := "file"
+		switch {
+		case f.Dir:
+			kind = "directory"
+		case f.Link:
+			kind = "symbolic link"
+		}
+		lines = append(lines, fmt.Sprintf("Name:     %s", f.Name))
+		lines = append(lines, fmt.Sprintf("Type:     %s", kind))
+		if !f.Dir {
+			lines = append(lines, fmt.Sprintf("Size:     %s (%d bytes)", humanfmt.Bytes(f.Size), f.Size))
+		}
+		lines = append(lines, fmt.Sprintf("Modified: %s", f.Modified))
+	}
+	if m.filesLocal != "" {
+		lines = append(lines, dimStyle.Render("Downloads go to "+m.filesLocal))
+	}
+
+	availableLines := height - 4
+	if len(lines) > availableLines && availableLines > 0 {
+		lines = lines[:availableLines]
+	}
+	content := titleStyle.Render(title) + "\n" + strings.Join(lines, "\n")
+	return logPanelStyle.Width(m.width - 2).Height(height).Render(content)
+}
+
+func (m Model) renderFilesStatusBar() string {
+	help := m.statusHelp("?:help q:quit ↑/↓:nav enter:open ←:up d:download r:reload esc:back")
+	gap := m.width - lipgloss.Width(help) - 2
+	if gap < 0 {
+		gap = 0
+	}
+	return " " + strings.Repeat(" ", gap) + help
+}
@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestFileBrowserNavigation(t *testing.T) {
+	m := Model{jobs: []*db.Job{{ID: 1, Host: "cool30", WorkingDir: "~/code"}}, width: 100, height: 40}
+	press := func(k tea.KeyMsg) {
+		t.Helper()
+		updated, _ := m.Update(k)
+		m = updated.(Model)
+	}
+	listed := func(dir, abs string, files ...ssh.RemoteFile) {
+		t.Helper()
+		updated, _ := m.Update(filesListedMsg{host: "cool30", dir: dir, abs: abs, files: files})
+		m = updated.(Model)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("F")})
+	if m.viewMode != ViewModeFiles || m.filesPending != "~/code" {
+		t.Fatalf("F opened view %d listing %q, want the files of ~/code", m.viewMode, m.filesPending)
+	}
+	if cwd, _ := os.Getwd(); m.filesLocal != cwd {
+		t.Errorf("downloads go to %q, want the working directory %q", m.filesLocal, cwd)
+	}
+	listed("~/code", "/home/alice/code",
+		ssh.RemoteFile{Name: "runs", Dir: true},
+		ssh.RemoteFile{Name: "train.py", Size: 2048, Modified: "Oct 17 03:40"})
+	if view := m.renderFiles(20); !strings.Contains(view, "runs/") || !strings.Contains(view, "2KiB") {
+		t.Errorf("listing doesn't show the entries:\n%s", view)
+	}
+
+	// Into runs, then back up to code with runs highlighted
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.filesPending != "/home/alice/code/runs" {
+		t.Fatalf("enter lists %q, want /home/alice/code/runs", m.filesPending)
+	}
+	listed("/home/alice/code/runs", "/home/alice/code/runs")
+	press(tea.KeyMsg{Type: tea.KeyBackspace})
+	listed("/home/alice/code", "/home/alice/code",
+		ssh.RemoteFile{Name: "data", Dir: true},
+		ssh.RemoteFile{Name: "runs", Dir: true})
+	if f := m.selectedFile(); f == nil || f.Name != "runs" {
+		t.Errorf("after going up the highlighted entry is %+v, want runs", f)
+	}
+
+	// A listing that arrives after another was requested is ignored
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	listed("/home/alice/code", "/home/alice/code")
+	if len(m.filesEntries) != 2 {
+		t.Error("a stale listing replaced the entries")
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.viewMode != ViewModeJobs {
+		t.Errorf("esc went to view %d, want the jobs view", m.viewMode)
+	}
+}
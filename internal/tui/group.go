@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// jobGroupMode controls how the Jobs view groups its list
+type jobGroupMode int
+
+const (
+	groupNone jobGroupMode = iota
+	groupByHost
+	groupByExperiment
+	groupByStatus
+	groupModeCount
+)
+
+func groupModeDescription(mode jobGroupMode) string {
+	switch mode {
+	case groupByHost:
+		return "host"
+	case groupByExperiment:
+		return "experiment"
+	case groupByStatus:
+		return "status"
+	default:
+		return "none"
+	}
+}
+
+// jobGroup is a section of the grouped job list
+type jobGroup struct {
+	Name      string    // "" for jobs that aren't in an experiment
+	Jobs      []*db.Job // The group's jobs that match the filter, shown or not
+	Collapsed bool      // Only the group's header is shown
+}
+
+// groupJobs divides jobs into groups by key, in the order of each group's
+// first job, with the jobs whose key is "" last. Jobs keep their order
+// within a group.
+func groupJobs(jobs []*db.Job, key func(*db.Job) string) []*jobGroup {
+	var groups []*jobGroup
+	var ungrouped *jobGroup
+	byName := make(map[string]*jobGroup)
+	for _, job := range jobs {
+		name := key(job)
+		g := byName[name]
+		if g == nil {
+			g = &jobGroup{Name: name}
+			byName[name] = g
+			if name == "" {
+				ungrouped = g
+			} else {
+				groups = append(groups, g)
+			}
+		}
+		g.Jobs = append(g.Jobs, job)
+	}
+	if ungrouped != nil {
+		groups = append(groups, ungrouped)
+	}
+	return groups
+}
+
+// layoutJobList returns the rows of a job list grouped by key, with a header
+// for each group and the jobs of the groups that aren't collapsed, and the
+// jobs that are shown
+func layoutJobList(jobs []*db.Job, key func(*db.Job) string, collapsed map[string]bool) (rows []jobListRow, shown []*db.Job) {
+	for _, g := range groupJobs(jobs, key) {
+		g.Collapsed = collapsed[g.Name]
+		rows = append(rows, jobListRow{group: g})
+		if g.Collapsed {
+			continue
+		}
+		for _, job := range g.Jobs {
+			rows = append(rows, jobListRow{job: job})
+			shown = append(shown, job)
+		}
+	}
+	return rows, shown
+}
+
+// setGroupMode groups the job list by mode, with every group expanded
+func (m *Model) setGroupMode(mode jobGroupMode) {
+	m.groupMode = mode
+	m.collapsedGroups = nil
+	m.headerSelected = false
+	m.applyJobFilter()
+}
+
+// groupKey returns the name of the group job is in under the current mode
+func (m Model) groupKey(job *db.Job) string {
+	switch m.groupMode {
+	case groupByHost:
+		return job.Host
+	case groupByExperiment:
+		return m.jobExperiments[job.ID]
+	case groupByStatus:
+		return statusGroup(job)
+	}
+	return ""
+}
+
+// statusGroup names the outcome of a job for grouping by status, counting
+// jobs that exited with an error or died as failed
+func statusGroup(job *db.Job) string {
+	switch job.Status {
+	case db.StatusCompleted:
+		if job.ExitCode != nil && *job.ExitCode == 0 {
+			return "succeeded"
+		}
+		return "failed"
+	case db.StatusDead:
+		return "failed"
+	}
+	return string(job.Status)
+}
+
+// highlightedGroup returns the group whose header is highlighted, or that
+// the highlighted job is in, or nil if the list isn't grouped
+func (m Model) highlightedGroup() *jobGroup {
+	if m.groupMode == groupNone {
+		return nil
+	}
+	var name string
+	switch {
+	case m.headerSelected:
+		name = m.selectedGroup
+	case m.selectedIndex < len(m.jobs):
+		name = m.groupKey(m.jobs[m.selectedIndex])
+	default:
+		return nil
+	}
+	for _, row := range m.rows {
+		if row.group != nil && row.group.Name == name {
+			return row.group
+		}
+	}
+	return nil
+}
+
+// toggleGroup collapses the highlighted group, leaving its header
+// highlighted, or expands it
+func (m *Model) toggleGroup() {
+	g := m.highlightedGroup()
+	if g == nil {
+		return
+	}
+	if m.collapsedGroups == nil {
+		m.collapsedGroups = make(map[string]bool)
+	}
+	m.collapsedGroups[g.Name] = !g.Collapsed
+	m.headerSelected, m.selectedGroup = true, g.Name
+	m.applyJobFilter()
+}
+
+// toggleAllGroups collapses every group, or expands them all if they all are
+func (m *Model) toggleAllGroups() {
+	all := true
+	var names []string
+	for _, row := range m.rows {
+		if row.group != nil {
+			all = all && row.group.Collapsed
+			names = append(names, row.group.Name)
+		}
+	}
+	if g := m.highlightedGroup(); g != nil {
+		m.headerSelected, m.selectedGroup = true, g.Name
+	}
+	m.collapsedGroups = make(map[string]bool)
+	if !all {
+		for _, name := range names {
+			m.collapsedGroups[name] = true
+		}
+	}
+	m.applyJobFilter()
+}
+
+// groupHeader is the row of a group in the job list, with the progress of
+// its jobs, which ends with their number
+func (m Model) groupHeader(g *jobGroup) string {
+	marker := "▾"
+	if g.Collapsed {
+		marker = "▸"
+	}
+	name := g.Name
+	if name == "" {
+		name = "No experiment"
+	}
+	return fmt.Sprintf(" %s %s: %s", marker, name, db.Progress(g.Jobs))
+}
+
+// renderGroupDetails describes the group whose header is highlighted
+func (m Model) renderGroupDetails(g *jobGroup) string {
+	progress := db.Progress(g.Jobs)
+	name := g.Name
+	if name == "" {
+		name = "No experiment"
+	}
+	s := fmt.Sprintf("%s: %s\n\n", groupModeTitle(m.groupMode), name)
+	s += fmt.Sprintf("Jobs:      %d (%d/%d done)\n", progress.Total, progress.Finished(), progress.Total)
+	for _, c := range []struct {
+		label string
+		n     int
+	}{
+		{"Running:  ", progress.Running}, {"Waiting:  ", progress.Waiting},
+		{"Succeeded:", progress.Succeeded}, {"Failed:   ", progress.Failed},
+	} {
+		if c.n > 0 {
+			s += fmt.Sprintf("%s %d\n", c.label, c.n)
+		}
+	}
+	if g.Collapsed {
+		s += "\n" + dimStyle.Render("Press z to expand, Z to expand all")
+	} else {
+		s += "\n" + dimStyle.Render("Press z to collapse, Z to collapse all")
+	}
+	return s
+}
+
+func groupModeTitle(mode jobGroupMode) string {
+	switch mode {
+	case groupByHost:
+		return "Host"
+	case groupByExperiment:
+		return "Experiment"
+	default:
+		return "Status"
+	}
+}
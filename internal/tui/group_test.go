@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// describeRows lists rows as "[name]" for headers ("[name+]" if collapsed)
+// and job IDs
+func describeRows(rows []jobListRow) string {
+	var parts []string
+	for _, row := range rows {
+		if row.group != nil {
+			collapsed := ""
+			if row.group.Collapsed {
+				collapsed = "+"
+			}
+			parts = append(parts, fmt.Sprintf("[%s%s]", row.group.Name, collapsed))
+		} else {
+			parts = append(parts, fmt.Sprint(row.job.ID))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestLayoutJobList(t *testing.T) {
+	var jobs []*db.Job
+	for id := int64(6); id >= 1; id-- {
+		jobs = append(jobs, &db.Job{ID: id})
+	}
+	experiments := map[int64]string{1: "ablation", 2: "sweep", 4: "ablation", 5: "sweep"}
+	key := func(job *db.Job) string { return experiments[job.ID] }
+
+	rows, shown := layoutJobList(jobs, key, nil)
+	if got, want := describeRows(rows), "[sweep] 5 2 [ablation] 4 1 [] 6 3"; got != want {
+		t.Errorf("layoutJobList() rows = %s, want %s", got, want)
+	}
+	if len(shown) != 6 {
+		t.Errorf("layoutJobList() shows %d jobs, want 6", len(shown))
+	}
+
+	rows, shown = layoutJobList(jobs, key, map[string]bool{"sweep": true})
+	if got, want := describeRows(rows), "[sweep+] [ablation] 4 1 [] 6 3"; got != want {
+		t.Errorf("layoutJobList() with sweep collapsed = %s, want %s", got, want)
+	}
+	if len(shown) != 4 || len(rows[0].group.Jobs) != 2 {
+		t.Errorf("collapsed layout shows %d jobs, sweep has %d; want 4 and 2", len(shown), len(rows[0].group.Jobs))
+	}
+}
+
+func TestStatusGroup(t *testing.T) {
+	zero, one := 0, 1
+	tests := []struct {
+		job  db.Job
+		want string
+	}{
+		{db.Job{Status: db.StatusCompleted, ExitCode: &zero}, "succeeded"},
+		{db.Job{Status: db.StatusCompleted, ExitCode: &one}, "failed"},
+		{db.Job{Status: db.StatusDead}, "failed"},
+		{db.Job{Status: db.StatusQueued}, "queued"},
+	}
+	for _, tt := range tests {
+		if got := statusGroup(&tt.job); got != tt.want {
+			t.Errorf("statusGroup(%s) = %q, want %q", tt.job.Status, got, tt.want)
+		}
+	}
+}
+
+func TestGroupNavigationAndCollapse(t *testing.T) {
+	jobs := []*db.Job{{ID: 3, Host: "b"}, {ID: 2, Host: "a"}, {ID: 1, Host: "b"}}
+	m := Model{allJobs: jobs, jobs: jobs, groupMode: groupByHost}
+	m.applyJobFilter()
+	// [b] 3 1 [a] 2, with job 3 highlighted
+
+	if got := m.getTargetJob(); got == nil || got.ID != 3 {
+		t.Fatalf("highlighted job = %v, want job 3", got)
+	}
+	m.moveCursor(-1)
+	if !m.headerSelected || m.selectedGroup != "b" || m.getTargetJob() != nil {
+		t.Fatalf("after moving up, want the header of b highlighted and no target job")
+	}
+
+	m.toggleGroup()
+	if got, want := describeRows(m.listRows()), "[b+] [a] 2"; got != want {
+		t.Fatalf("after collapsing b, rows = %s, want %s", got, want)
+	}
+	m.moveCursor(2)
+	if got := m.getTargetJob(); got == nil || got.ID != 2 {
+		t.Fatalf("after moving down twice, highlighted job = %v, want job 2", got)
+	}
+
+	m.toggleAllGroups()
+	if got, want := describeRows(m.listRows()), "[b+] [a+]"; got != want {
+		t.Fatalf("after collapsing all, rows = %s, want %s", got, want)
+	}
+	if !m.headerSelected || m.selectedGroup != "a" {
+		t.Errorf("after collapsing all, want the header of a (the highlighted job's group) highlighted")
+	}
+	m.toggleAllGroups()
+	if got, want := describeRows(m.listRows()), "[b] 3 1 [a] 2"; got != want {
+		t.Errorf("after expanding all, rows = %s, want %s", got, want)
+	}
+}
@@ -1,10 +1,21 @@
 package tui
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/osteele/remote-jobs/internal/availability"
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/diskspace"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/hosttools"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 )
 
 // HostStatus represents the connectivity status of a host
@@ -50,6 +61,7 @@ type HostRunningJob struct {
 	Description string
 	Command     string
 	GPUs        []JobGPUUsage // GPUs this job is using
+	Reserved    []int         // GPUs assigned to the job with CUDA_VISIBLE_DEVICES
 }
 
 // Host represents a remote host with its system information
@@ -66,9 +78,26 @@ type Host struct {
 	MemUsed   string // e.g., "58G"
 	LoadAvg   string // e.g., "0.5, 0.3, 0.2"
 	GPUs      []GPUInfo
+	Tools     hosttools.Versions // Versions of python, CUDA, etc.; nil until probed
 	LastCheck time.Time
 	Error     string // connection error message (not displayed as error)
 
+	// Backoff after failed connections, shared with the CLI through the reachability cache
+	Failures int       // Consecutive failed attempts
+	RetryAt  time.Time // When syncs and refreshes try the host again
+
+	// SSH round-trip time, measured when the host is queried; nil until then
+	Latency *db.HostLatency
+
+	// Partial is set when the last query timed out partway. received holds
+	// the fields of the host-info script's output that arrived whole; the
+	// others keep what an earlier query found.
+	Partial  bool
+	received map[string]bool
+
+	// Probing is set while the host is being queried
+	Probing bool
+
 	// Queue status
 	QueueStatus       QueueCheckStatus // Unknown, Checking, Checked
 	QueueRunnerActive bool             // Whether queue runner tmux session exists
@@ -78,217 +107,249 @@ type Host struct {
 
 	// Running jobs on this host
 	RunningJobs []HostRunningJob
-}
-
-// HostInfoCommand is the SSH command to gather host information
-// It outputs structured lines that parseHostInfo can parse
-// GPU info is parsed from standard nvidia-smi output for maximum compatibility
-// The awk script captures GPU name lines and their following stats lines
-const HostInfoCommand = `echo "ARCH:$(uname -sm)"; ` +
-	`echo "OS:$(uname -r)"; ` +
-	`echo "CPUS:$(nproc 2>/dev/null || sysctl -n hw.ncpu 2>/dev/null || echo -)"; ` +
-	`echo "LOAD:$(uptime | sed 's/.*load average[s]*: //')"; ` +
-	// Memory: Linux uses free, macOS uses sysctl + vm_stat
-	`if command -v free >/dev/null 2>&1; then ` +
-	`echo "MEM:$(free -h | awk '/^Mem:/ {print $2":"$3}')"; ` +
-	`else ` +
-	// macOS: get total from sysctl, used from vm_stat (active + wired + compressed)
-	`total_gb=$(sysctl -n hw.memsize 2>/dev/null | awk '{printf "%.0f", $1/1024/1024/1024}'); ` +
-	`page_size=$(sysctl -n hw.pagesize 2>/dev/null); ` +
-	`vm_out=$(vm_stat 2>/dev/null); ` +
-	`pages_active=$(echo "$vm_out" | awk '/Pages active/ {gsub(/\./,"",$3); print $3}'); ` +
-	`pages_wired=$(echo "$vm_out" | awk '/Pages wired/ {gsub(/\./,"",$4); print $4}'); ` +
-	`pages_comp=$(echo "$vm_out" | awk '/Pages occupied by compressor/ {gsub(/\./,"",$5); print $5}'); ` +
-	`if [ -n "$pages_active" ] && [ -n "$page_size" ]; then ` +
-	`used_gb=$(( (pages_active + pages_wired + pages_comp) * page_size / 1024 / 1024 / 1024 )); ` +
-	`echo "MEM:${total_gb}G:${used_gb}G"; ` +
-	`else ` +
-	`echo "MEM:${total_gb}G:-"; ` +
-	`fi; ` +
-	`fi; ` +
-	// Model: macOS hw.model
-	`sysctl -n hw.model 2>/dev/null | sed 's/^/MODEL:/' || true; ` +
-	// CPU model: macOS uses brand_string, Linux uses /proc/cpuinfo
-	`(sysctl -n machdep.cpu.brand_string 2>/dev/null || grep -m1 'model name' /proc/cpuinfo 2>/dev/null | cut -d: -f2) | sed 's/^[[:space:]]*//' | sed 's/^/CPUMODEL:/' || true; ` +
-	// macOS GPU: system_profiler (brief format)
-	`system_profiler SPDisplaysDataType 2>/dev/null | grep -E '(Chipset Model|VRAM|Total Number of Cores|Metal)' | sed 's/^[[:space:]]*/MACGPU:/' || true; ` +
-	// Linux GPU: nvidia-smi
-	`nvidia-smi 2>/dev/null | awk '/^\|[[:space:]]+[0-9]+[[:space:]]+[A-Z]/ { print "GPUNAME:" $0; getline; print "GPUSTAT:" $0 }'`
-
-// ParseHostInfo parses the output of HostInfoCommand into a Host struct
-func ParseHostInfo(output string) *Host {
-	host := &Host{
-		Status:    HostStatusOnline,
-		LastCheck: time.Now(),
-	}
 
-	// Track pending GPU info (name parsed, waiting for stats)
-	var pendingGPU *GPUInfo
+	// Recent incidents (Xid errors, thermal throttling, OOM kills), newest
+	// first, and when the user last acknowledged them (0 if never)
+	Events        []db.HostEvent
+	EventsAckedAt int64
 
-	for _, line := range strings.Split(output, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+	// Disk usage of the filesystems holding job directories and remote-jobs' files
+	Disks      []diskspace.Usage
+	DiskLimits config.Limits // Configured minimums, for highlighting low disks
+}
 
-		if idx := strings.Index(line, ":"); idx > 0 {
-			key := line[:idx]
-			value := strings.TrimSpace(line[idx+1:])
-
-			switch key {
-			case "ARCH":
-				host.Arch = value
-			case "OS":
-				host.OS = value
-			case "MODEL":
-				host.Model = value
-			case "CPUMODEL":
-				host.CPUModel = value
-			case "CPUS":
-				if n, err := strconv.Atoi(value); err == nil {
-					host.CPUs = n
-				}
-			case "LOAD":
-				host.LoadAvg = strings.TrimSpace(value)
-			case "MEM":
-				parts := strings.SplitN(value, ":", 2)
-				if len(parts) == 2 {
-					host.MemTotal = parts[0]
-					host.MemUsed = parts[1]
-					// Clean up "-" for unused values (macOS doesn't report used)
-					if host.MemUsed == "-" {
-						host.MemUsed = ""
-					}
-				}
-			case "MACGPU":
-				// Parse macOS GPU info lines
-				parseMacGPULine(value, host)
-			case "GPU":
-				gpu := parseGPULine(value)
-				if gpu != nil {
-					host.GPUs = append(host.GPUs, *gpu)
-				}
-			case "GPUNAME":
-				// Save pending GPU, parse name line
-				if pendingGPU != nil {
-					host.GPUs = append(host.GPUs, *pendingGPU)
-				}
-				pendingGPU = parseNvidiaSmiNameLine(value)
-			case "GPUSTAT":
-				// Parse stats and merge with pending GPU
-				if pendingGPU != nil {
-					parseNvidiaSmiStatsLine(value, pendingGPU)
-					host.GPUs = append(host.GPUs, *pendingGPU)
-					pendingGPU = nil
-				}
-			case "GPULINE":
-				// Legacy: single line format (name only)
-				gpu := parseNvidiaSmiNameLine(value)
-				if gpu != nil {
-					host.GPUs = append(host.GPUs, *gpu)
-				}
-			}
+// UnackedEvents returns the host's incidents that came after the user last
+// acknowledged them, which are the ones to warn about
+func (h *Host) UnackedEvents() []db.HostEvent {
+	var events []db.HostEvent
+	for _, e := range h.Events {
+		if e.Time > h.EventsAckedAt {
+			events = append(events, e)
 		}
 	}
+	return events
+}
+
+// loadHostEvents returns a host's incidents since a time, and when the user
+// last acknowledged them
+func loadHostEvents(database *sql.DB, host string, since time.Time) ([]db.HostEvent, int64) {
+	events, _ := db.ListHostEvents(database, host, since.Unix())
+	ackedAt, _ := db.HostAckedAt(database, host)
+	return events, ackedAt
+}
 
-	// Don't forget any pending GPU
-	if pendingGPU != nil {
-		host.GPUs = append(host.GPUs, *pendingGPU)
+// LowDisk reports whether any of the host's filesystems is nearly full
+func (h *Host) LowDisk() bool {
+	for _, d := range h.Disks {
+		if d.Low() || d.Check(h.Name, h.DiskLimits.MinFreeDiskGB, h.DiskLimits.MinFreeInodesPct) != nil {
+			return true
+		}
 	}
+	return false
+}
 
-	return host
+// hostInfo is the JSON document printed by the host-info script
+type hostInfo struct {
+	Arch     string `json:"arch"`
+	OS       string `json:"os"`
+	Model    string `json:"model"`
+	CPUModel string `json:"cpu_model"`
+	CPUs     int    `json:"cpus"`
+	Load     string `json:"load"`
+	MemTotal string `json:"mem_total"`
+	MemUsed  string `json:"mem_used"`
+	GPUs     []struct {
+		Index       int    `json:"index"`
+		Name        string `json:"name"`
+		Temperature int    `json:"temperature"`
+		Utilization int    `json:"utilization"`
+		MemUsedMiB  int    `json:"mem_used_mib"`
+		MemTotalMiB int    `json:"mem_total_mib"`
+	} `json:"gpus"`
+	MacGPUs []string `json:"mac_gpus"` // system_profiler lines, such as "Chipset Model: Apple M2 Max"
+	Queue   struct {
+		Runner  bool   `json:"runner"`
+		Current string `json:"current"`
+		Depth   int    `json:"depth"`
+		Stop    bool   `json:"stop"`
+	} `json:"queue"`
+	Jobs  []hostInfoJob `json:"jobs"`
+	Disks []*struct {
+		Mount       string `json:"mount"`
+		TotalKB     int64  `json:"total_kb"`
+		AvailKB     int64  `json:"avail_kb"`
+		TotalInodes int64  `json:"total_inodes"`
+		FreeInodes  int64  `json:"free_inodes"`
+	} `json:"disks"` // One per path, null if it couldn't be measured
+	Tools map[string]string `json:"tools"` // Version of each tool, "" if it isn't installed
 }
 
-// parseNvidiaSmiNameLine parses the GPU name line from standard nvidia-smi output
-// Format: |   0  NVIDIA GeForce ...  On   | 00000000:01:00.0 Off |                  N/A |
-func parseNvidiaSmiNameLine(line string) *GPUInfo {
-	// Remove leading/trailing pipe characters and whitespace
-	line = strings.Trim(line, "| ")
-	fields := strings.Fields(line)
-	if len(fields) < 3 {
-		return nil
-	}
+// hostInfoJob is a job the host-info script found running: its PID file
+// names a live process
+type hostInfoJob struct {
+	ID   int64 `json:"id"`
+	PID  int   `json:"pid"`
+	GPUs []struct {
+		Index  int `json:"index"`
+		MemMiB int `json:"mem_mib"`
+	} `json:"gpus"` // GPUs used by the process or its descendants
+}
 
-	// First field is GPU index
-	idx, err := strconv.Atoi(fields[0])
-	if err != nil {
-		return nil
+// hostInfoArgs returns the arguments of the host-info script: the queue to
+// report on, and the paths whose filesystems to measure
+func hostInfoArgs(queueName string, diskPaths ...string) string {
+	args := []string{shellquote.Quote(queueName)}
+	for _, p := range diskPaths {
+		args = append(args, shellquote.HomePath(p))
 	}
+	return strings.Join(args, " ")
+}
 
-	// Second field should be a GPU vendor name (NVIDIA, AMD, etc.), not "N/A"
-	// Skip process lines which have "N/A" as the second field
-	if fields[1] == "N/A" {
-		return nil
+// parseHostInfo parses the output of the host-info script, run with the
+// given disk paths, into a Host. The jobs it found running are returned
+// separately, since describing them takes the job database.
+func parseHostInfo(output string, diskPaths ...string) (*Host, []hostInfoJob, error) {
+	var info hostInfo
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &info); err != nil {
+		return nil, nil, fmt.Errorf("parse host info: %w", err)
 	}
 
-	gpu := &GPUInfo{Index: idx}
+	host := &Host{
+		Status:    HostStatusOnline,
+		LastCheck: time.Now(),
+		Arch:      info.Arch,
+		OS:        info.OS,
+		Model:     info.Model,
+		CPUModel:  info.CPUModel,
+		CPUs:      info.CPUs,
+		LoadAvg:   info.Load,
+		MemTotal:  info.MemTotal,
+		MemUsed:   info.MemUsed,
+
+		QueueStatus:       QueueCheckChecked,
+		QueueRunnerActive: info.Queue.Runner,
+		QueuedJobCount:    info.Queue.Depth,
+		CurrentQueueJob:   info.Queue.Current,
+		QueueStopPending:  info.Queue.Stop,
+	}
+	if info.Tools != nil {
+		host.Tools = hosttools.Clean(info.Tools)
+	}
+	for _, gpu := range info.GPUs {
+		host.GPUs = append(host.GPUs, GPUInfo{
+			Index:       gpu.Index,
+			Name:        gpu.Name,
+			Temperature: gpu.Temperature,
+			Utilization: gpu.Utilization,
+			MemUsed:     fmt.Sprintf("%dMiB", gpu.MemUsedMiB),
+			MemTotal:    fmt.Sprintf("%dMiB", gpu.MemTotalMiB),
+		})
+	}
+	for _, line := range info.MacGPUs {
+		parseMacGPULine(line, host)
+	}
+	for i, disk := range info.Disks {
+		if disk == nil || i >= len(diskPaths) {
+			continue
+		}
+		host.Disks = append(host.Disks, diskspace.Usage{
+			Path:        diskPaths[i],
+			Mount:       disk.Mount,
+			TotalKB:     disk.TotalKB,
+			AvailKB:     disk.AvailKB,
+			TotalInodes: disk.TotalInodes,
+			FreeInodes:  disk.FreeInodes,
+		})
+	}
+	return host, info.Jobs, nil
+}
 
-	// Build GPU name from remaining fields until we hit a non-name field
-	var nameParts []string
-	for i := 1; i < len(fields); i++ {
-		// Stop at common end markers
-		if fields[i] == "On" || fields[i] == "Off" || strings.HasPrefix(fields[i], "0000") {
+// parsePartialHostInfo parses the output of a host-info script that timed
+// out, keeping the top-level fields that arrived whole. It returns false if
+// none did.
+func parsePartialHostInfo(output string, diskPaths ...string) (*Host, []hostInfoJob, bool) {
+	dec := json.NewDecoder(strings.NewReader(output))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, false
+	}
+	fields := make(map[string]json.RawMessage)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, ok := tok.(string)
+		if !ok {
+			break
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
 			break
 		}
-		nameParts = append(nameParts, fields[i])
+		fields[key] = value
+	}
+	if len(fields) == 0 {
+		return nil, nil, false
 	}
-	gpu.Name = strings.Join(nameParts, " ")
-	// Remove trailing "..." from truncated names
-	gpu.Name = strings.TrimSuffix(gpu.Name, "...")
-
-	return gpu
-}
 
-// parseNvidiaSmiStatsLine parses the GPU stats line from standard nvidia-smi output
-// Format: | 30%   45C    P8    20W / 350W |    123MiB / 24564MiB |      0%      Default |
-func parseNvidiaSmiStatsLine(line string, gpu *GPUInfo) {
-	if gpu == nil {
-		return
+	whole, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, false
+	}
+	host, running, err := parseHostInfo(string(whole), diskPaths...)
+	if err != nil {
+		return nil, nil, false
 	}
+	host.Partial = true
+	host.received = make(map[string]bool, len(fields))
+	for key := range fields {
+		host.received[key] = true
+	}
+	if !host.received["queue"] {
+		host.QueueStatus = QueueCheckUnknown
+	}
+	return host, running, true
+}
 
-	// Split by | to get the three sections
-	sections := strings.Split(line, "|")
-	if len(sections) < 4 {
+// keepMissing fills in the info that a partial query didn't receive from
+// prev, the host as an earlier query found it
+func (h *Host) keepMissing(prev *Host) {
+	if !h.Partial {
 		return
 	}
-
-	// Section 1: Fan%, Temp, Perf, Power
-	// e.g., " 30%   45C    P8    20W / 350W "
-	section1 := strings.Fields(sections[1])
-	for i, field := range section1 {
-		// Temperature ends with C
-		if strings.HasSuffix(field, "C") {
-			tempStr := strings.TrimSuffix(field, "C")
-			if temp, err := strconv.Atoi(tempStr); err == nil {
-				gpu.Temperature = temp
-			}
-		}
-		// Fan percentage (first field ending with %)
-		if i == 0 && strings.HasSuffix(field, "%") {
-			// This is fan speed, not GPU utilization - skip
+	keep := func(field string, dst *string, src string) {
+		if !h.received[field] {
+			*dst = src
 		}
 	}
-
-	// Section 2: Memory usage
-	// e.g., "    123MiB / 24564MiB "
-	section2 := strings.TrimSpace(sections[2])
-	memParts := strings.Split(section2, "/")
-	if len(memParts) == 2 {
-		gpu.MemUsed = strings.TrimSpace(memParts[0])
-		gpu.MemTotal = strings.TrimSpace(memParts[1])
-	}
-
-	// Section 3: GPU utilization
-	// e.g., "      0%      Default "
-	section3 := strings.Fields(sections[3])
-	for _, field := range section3 {
-		if strings.HasSuffix(field, "%") {
-			utilStr := strings.TrimSuffix(field, "%")
-			if util, err := strconv.Atoi(utilStr); err == nil {
-				gpu.Utilization = util
-				break
-			}
-		}
+	keep("arch", &h.Arch, prev.Arch)
+	keep("os", &h.OS, prev.OS)
+	keep("model", &h.Model, prev.Model)
+	keep("cpu_model", &h.CPUModel, prev.CPUModel)
+	keep("load", &h.LoadAvg, prev.LoadAvg)
+	keep("mem_total", &h.MemTotal, prev.MemTotal)
+	keep("mem_used", &h.MemUsed, prev.MemUsed)
+	if !h.received["cpus"] {
+		h.CPUs = prev.CPUs
+	}
+	if !h.received["gpus"] && !h.received["mac_gpus"] {
+		h.GPUs = prev.GPUs
+	}
+	if !h.received["queue"] {
+		h.QueueStatus = prev.QueueStatus
+		h.QueueRunnerActive = prev.QueueRunnerActive
+		h.QueuedJobCount = prev.QueuedJobCount
+		h.CurrentQueueJob = prev.CurrentQueueJob
+		h.QueueStopPending = prev.QueueStopPending
+	}
+	if !h.received["jobs"] {
+		h.setRunningJobs(prev.RunningJobs)
+	}
+	if !h.received["disks"] {
+		h.Disks = prev.Disks
+	}
+	if !h.received["tools"] {
+		h.Tools = prev.Tools
 	}
 }
 
@@ -318,36 +379,34 @@ func parseMacGPULine(line string, host *Host) {
 	}
 }
 
-// parseGPULine parses a single nvidia-smi CSV output line
-// Format: index, name, temperature, utilization, memory.used, memory.total
-func parseGPULine(line string) *GPUInfo {
-	parts := strings.Split(line, ",")
-	if len(parts) < 6 {
-		return nil
-	}
-
-	// Trim spaces from all parts
-	for i := range parts {
-		parts[i] = strings.TrimSpace(parts[i])
-	}
-
-	gpu := &GPUInfo{
-		Name:     parts[1],
-		MemUsed:  parts[4] + " MiB",
-		MemTotal: parts[5] + " MiB",
-	}
-
-	if idx, err := strconv.Atoi(parts[0]); err == nil {
-		gpu.Index = idx
-	}
-	if temp, err := strconv.Atoi(parts[2]); err == nil {
-		gpu.Temperature = temp
-	}
-	if util, err := strconv.Atoi(parts[3]); err == nil {
-		gpu.Utilization = util
+// setRunningJobs records the jobs running on the host, and labels the GPUs
+// they use with them
+func (h *Host) setRunningJobs(jobs []HostRunningJob) {
+	h.RunningJobs = jobs
+	for j := range h.GPUs {
+		h.GPUs[j].JobID = 0
+		h.GPUs[j].JobLabel = ""
+	}
+	for _, job := range jobs {
+		for _, gpu := range job.GPUs {
+			for j := range h.GPUs {
+				if h.GPUs[j].Index == gpu.GPUIndex {
+					h.GPUs[j].JobID = job.ID
+					label := fmt.Sprintf("#%d", job.ID)
+					if job.Description != "" {
+						// Truncate description if too long
+						desc := job.Description
+						if len(desc) > 15 {
+							desc = desc[:12] + "..."
+						}
+						label += " " + desc
+					}
+					h.GPUs[j].JobLabel = label
+					break
+				}
+			}
+		}
 	}
-
-	return gpu
 }
 
 // StatusString returns a human-readable status string
@@ -364,6 +423,23 @@ func (h *Host) StatusString() string {
 	}
 }
 
+// nextAttempt describes when an offline host will be tried again
+func nextAttempt(retryAt, now time.Time) string {
+	if !now.Before(retryAt) {
+		return "next refresh"
+	}
+	return "in " + retryAt.Sub(now).Round(time.Second).String()
+}
+
+// availabilityNote says when a host outside its availability windows next
+// opens, e.g. "◷ Mon 20:00 (in 5h12m)", or is empty if it is open now
+func availabilityNote(s availability.Schedule, now time.Time) string {
+	if s.Open(now) {
+		return ""
+	}
+	return "◷ " + availability.FormatOpensAt(s.NextOpen(now), now)
+}
+
 // GPUSummary returns a brief GPU summary for the list view
 func (h *Host) GPUSummary() string {
 	if len(h.GPUs) == 0 {
@@ -435,88 +511,39 @@ func (h *Host) RAMUtilization() string {
 	if h.MemTotal == "" || h.MemUsed == "" {
 		return "-"
 	}
-	// Parse memory values (handles formats like "128G", "58G", "128Gi", "58Gi", "128GiB")
-	parseMemGB := func(s string) float64 {
-		s = strings.TrimSpace(s)
-		s = strings.TrimSuffix(s, "iB")
-		s = strings.TrimSuffix(s, "i")
-		s = strings.TrimSuffix(s, "B")
-		multiplier := 1.0
-		if strings.HasSuffix(s, "G") {
-			s = strings.TrimSuffix(s, "G")
-			multiplier = 1.0
-		} else if strings.HasSuffix(s, "M") {
-			s = strings.TrimSuffix(s, "M")
-			multiplier = 1.0 / 1024.0
-		} else if strings.HasSuffix(s, "T") {
-			s = strings.TrimSuffix(s, "T")
-			multiplier = 1024.0
-		}
-		val, err := strconv.ParseFloat(s, 64)
-		if err != nil {
-			return 0
-		}
-		return val * multiplier
-	}
-
-	total := parseMemGB(h.MemTotal)
-	used := parseMemGB(h.MemUsed)
+	total := humanfmt.ParseMiB(h.MemTotal)
+	used := humanfmt.ParseMiB(h.MemUsed)
 	if total == 0 {
 		return "-"
 	}
-	pct := int((used / total) * 100)
+	pct := used * 100 / total
 	return fmt.Sprintf("%d%%", pct)
 }
 
-// QueueStatusCommand returns the SSH command to check queue status for a given queue name
-// It outputs structured lines that ParseQueueStatus can parse
-func QueueStatusCommand(queueName string) string {
-	return fmt.Sprintf(
-		`tmux has-session -t 'rj-queue-%s' 2>/dev/null && echo "RUNNER:yes" || echo "RUNNER:no"; `+
-			`cat ~/.cache/remote-jobs/queue/%s.current 2>/dev/null | head -1 | sed 's/^/CURRENT:/' || echo "CURRENT:"; `+
-			`wc -l < ~/.cache/remote-jobs/queue/%s.queue 2>/dev/null | tr -d ' ' | sed 's/^/DEPTH:/' || echo "DEPTH:0"; `+
-			`test -f ~/.cache/remote-jobs/queue/%s.stop && echo "STOP:yes" || echo "STOP:no"`,
-		queueName, queueName, queueName, queueName)
-}
-
-// QueueStatus holds the parsed queue status information
-type QueueStatusInfo struct {
-	RunnerActive   bool
-	QueuedJobCount int
-	CurrentJob     string
-	StopPending    bool
+// DiskUtilization returns the used share of the host's fullest filesystem,
+// marked with "!" if it is nearly full
+func (h *Host) DiskUtilization() string {
+	if len(h.Disks) == 0 {
+		return "-"
+	}
+	minFree := 100
+	for _, d := range h.Disks {
+		minFree = min(minFree, d.FreePercent())
+	}
+	s := fmt.Sprintf("%d%%", 100-minFree)
+	if h.LowDisk() {
+		s += "!"
+	}
+	return s
 }
 
-// ParseQueueStatus parses the output of QueueStatusCommand into QueueStatusInfo
-func ParseQueueStatus(output string) *QueueStatusInfo {
-	info := &QueueStatusInfo{}
-
-	for _, line := range strings.Split(output, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		if idx := strings.Index(line, ":"); idx > 0 {
-			key := line[:idx]
-			value := strings.TrimSpace(line[idx+1:])
-
-			switch key {
-			case "RUNNER":
-				info.RunnerActive = value == "yes"
-			case "CURRENT":
-				info.CurrentJob = value
-			case "DEPTH":
-				if n, err := strconv.Atoi(value); err == nil {
-					info.QueuedJobCount = n
-				}
-			case "STOP":
-				info.StopPending = value == "yes"
-			}
-		}
+// LatencySummary returns the host's average round-trip time for the list
+// view, or "-" if it hasn't been measured
+func (h *Host) LatencySummary() string {
+	if h.Latency == nil {
+		return "-"
 	}
-
-	return info
+	return humanfmt.Latency(h.Latency.Average)
 }
 
 // QueueSummary returns a brief queue status string for the list view
@@ -536,3 +563,43 @@ func (h *Host) QueueSummary() string {
 		return "-"
 	}
 }
+
+// gpuPool returns the GPUs of all hosts as one pool, most free memory first,
+// with their states set from the running jobs. GPUs of hosts that aren't
+// online are marked as cached.
+func gpuPool(hosts []*Host) []gpupool.GPU {
+	var gpus []gpupool.GPU
+	var usages []gpupool.Usage
+	for _, host := range hosts {
+		cached := host.Status != HostStatusOnline
+		for _, info := range host.GPUs {
+			gpu := gpupool.GPU{
+				Host:        host.Name,
+				Index:       info.Index,
+				Name:        info.Name,
+				Utilization: -1,
+				MemUsedMiB:  humanfmt.ParseMiB(info.MemUsed),
+				MemTotalMiB: humanfmt.ParseMiB(info.MemTotal),
+				Cached:      cached,
+			}
+			if info.MemUsed != "" {
+				gpu.Utilization = info.Utilization
+			}
+			if cached {
+				gpu.JobID = info.JobID
+			}
+			gpus = append(gpus, gpu)
+		}
+		for _, job := range host.RunningJobs {
+			for _, g := range job.GPUs {
+				usages = append(usages, gpupool.Usage{Host: host.Name, Index: g.GPUIndex, JobID: job.ID, Active: true})
+			}
+			for _, index := range job.Reserved {
+				usages = append(usages, gpupool.Usage{Host: host.Name, Index: index, JobID: job.ID})
+			}
+		}
+	}
+	gpupool.Assign(gpus, usages)
+	gpupool.SortByFree(gpus)
+	return gpus
+}
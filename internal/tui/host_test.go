@@ -1,47 +1,26 @@
 package tui
 
 import (
+	"strings"
 	"testing"
-)
+	"time"
 
-func TestParseMiB(t *testing.T) {
-	tests := []struct {
-		input string
-		want  int
-	}{
-		{"123MiB", 123},
-		{"80GiB", 80 * 1024},
-		{"16G", 16 * 1024},
-		{"128Gi", 128 * 1024},
-		{"58.5G", int(58.5 * 1024)},
-		{"0.5GiB", 512},
-		{"", 0},
-		{"unknown", 0},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got := parseMiB(tt.input)
-			if got != tt.want {
-				t.Errorf("parseMiB(%q) = %d, want %d", tt.input, got, tt.want)
-			}
-		})
-	}
-}
+	"github.com/osteele/remote-jobs/internal/availability"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/diskspace"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+)
 
 func TestParseHostInfo(t *testing.T) {
-	output := `ARCH:Darwin arm64
-OS:24.6.0
-CPUS:8
-LOAD:4.81 5.59 5.52
-MEM:16G:-
-MODEL:MacBookPro17,1
-CPUMODEL:Apple M1
-MACGPU:Chipset Model: Apple M1
-MACGPU:Total Number of Cores: 8
-MACGPU:Metal Support: Metal 3`
-
-	host := ParseHostInfo(output)
+	output := `{"arch":"Darwin arm64","os":"24.6.0","model":"MacBookPro17,1","cpu_model":"Apple M1",` +
+		`"cpus":8,"load":"4.81 5.59 5.52","mem_total":"16G","mem_used":"",` +
+		`"gpus":[],"mac_gpus":["Chipset Model: Apple M1","Total Number of Cores: 8"],` +
+		`"queue":{"runner":false,"current":"","depth":0,"stop":false},"jobs":[],"disks":[]}`
+
+	host, _, err := parseHostInfo(output)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if host.Arch != "Darwin arm64" {
 		t.Errorf("Arch = %q, want %q", host.Arch, "Darwin arm64")
@@ -76,15 +55,17 @@ MACGPU:Metal Support: Metal 3`
 }
 
 func TestParseHostInfoLinux(t *testing.T) {
-	output := `ARCH:Linux x86_64
-OS:5.15.0-generic
-CPUS:12
-LOAD:0.5, 0.3, 0.2
-MEM:128G:58G
-GPUNAME:|   0  NVIDIA A100-SXM4-80GB   On   | 00000000:01:00.0 Off |                    0 |
-GPUSTAT:| 30%   45C    P8    20W / 350W |    123MiB / 80000MiB |      5%      Default |`
+	output := `{"arch":"Linux x86_64","os":"5.15.0-generic","model":"","cpu_model":"","cpus":12,` +
+		`"load":"0.5, 0.3, 0.2","mem_total":"128G","mem_used":"58G",` +
+		`"gpus":[{"index":0,"name":"NVIDIA A100-SXM4-80GB","temperature":45,"utilization":5,"mem_used_mib":123,"mem_total_mib":80000}],` +
+		`"mac_gpus":[],"queue":{"runner":true,"current":"41","depth":2,"stop":false},` +
+		`"jobs":[{"id":42,"pid":1234,"gpus":[{"index":0,"mem_mib":100}]}],` +
+		`"disks":[{"mount":"/","total_kb":1000,"avail_kb":400,"total_inodes":50,"free_inodes":20},null]}`
 
-	host := ParseHostInfo(output)
+	host, jobs, err := parseHostInfo(output, "~", "/data/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if host.Arch != "Linux x86_64" {
 		t.Errorf("Arch = %q, want %q", host.Arch, "Linux x86_64")
@@ -113,4 +94,191 @@ GPUSTAT:| 30%   45C    P8    20W / 350W |    123MiB / 80000MiB |      5%      De
 	if host.GPUs[0].MemUsed != "123MiB" {
 		t.Errorf("GPUs[0].MemUsed = %q, want %q", host.GPUs[0].MemUsed, "123MiB")
 	}
+	if host.QueueStatus != QueueCheckChecked || !host.QueueRunnerActive || host.QueuedJobCount != 2 || host.CurrentQueueJob != "41" {
+		t.Errorf("queue = %v runner %v, %d waiting, current %q; want checked, active, 2, 41",
+			host.QueueStatus, host.QueueRunnerActive, host.QueuedJobCount, host.CurrentQueueJob)
+	}
+	if len(host.Disks) != 1 || host.Disks[0].Path != "~" || host.Disks[0].AvailKB != 400 || host.Disks[0].FreeInodes != 20 {
+		t.Errorf("Disks = %+v, want only ~ with 400KB and 20 inodes free", host.Disks)
+	}
+	if len(jobs) != 1 || jobs[0].ID != 42 || len(jobs[0].GPUs) != 1 || jobs[0].GPUs[0].MemMiB != 100 {
+		t.Errorf("jobs = %+v, want job 42 using 100MiB of GPU 0", jobs)
+	}
+
+	if _, _, err := parseHostInfo("bash: sh: not found"); err == nil {
+		t.Error("expected an error for output that isn't JSON")
+	}
+}
+
+func TestSetRunningJobs(t *testing.T) {
+	host := &Host{GPUs: []GPUInfo{{Index: 0, JobID: 7, JobLabel: "#7"}, {Index: 1}}}
+	host.setRunningJobs([]HostRunningJob{
+		{ID: 42, Description: "a long training description", GPUs: []JobGPUUsage{{GPUIndex: 1}}},
+	})
+	if host.GPUs[0].JobID != 0 || host.GPUs[0].JobLabel != "" {
+		t.Errorf("GPU 0 = %+v, want no job", host.GPUs[0])
+	}
+	if host.GPUs[1].JobID != 42 || host.GPUs[1].JobLabel != "#42 a long train..." {
+		t.Errorf("GPU 1 = %+v, want job 42", host.GPUs[1])
+	}
+}
+
+func TestDiskUtilization(t *testing.T) {
+	h := &Host{Name: "cool30"}
+	if got := h.DiskUtilization(); got != "-" {
+		t.Errorf("DiskUtilization() with no disks = %q, want -", got)
+	}
+
+	h.Disks = []diskspace.Usage{
+		{Mount: "/", TotalKB: 1000, AvailKB: 600},
+		{Mount: "/data", TotalKB: 1000, AvailKB: 300},
+	}
+	if got := h.DiskUtilization(); got != "70%" {
+		t.Errorf("DiskUtilization() = %q, want 70%%", got)
+	}
+
+	h.Disks[1].AvailKB = 20
+	if got := h.DiskUtilization(); got != "98%!" {
+		t.Errorf("DiskUtilization() on a nearly full disk = %q, want 98%%!", got)
+	}
+}
+
+func TestNextAttempt(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got, want := nextAttempt(now.Add(90*time.Second), now), "in 1m30s"; got != want {
+		t.Errorf("nextAttempt() = %q, want %q", got, want)
+	}
+	if got, want := nextAttempt(now.Add(-time.Second), now), "next refresh"; got != want {
+		t.Errorf("nextAttempt() past due = %q, want %q", got, want)
+	}
+}
+
+func TestAvailabilityNote(t *testing.T) {
+	schedule, err := availability.Parse([]string{"20:00-08:00"}, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	afternoon := time.Date(2026, 10, 12, 14, 0, 0, 0, time.UTC)
+	if got := availabilityNote(schedule, afternoon); !strings.HasPrefix(got, "◷ ") || !strings.HasSuffix(got, "(in 6h00m)") {
+		t.Errorf("availabilityNote() = %q, want when the window opens", got)
+	}
+	if got := availabilityNote(schedule, afternoon.Add(8*time.Hour)); got != "" {
+		t.Errorf("availabilityNote() inside the window = %q, want none", got)
+	}
+	if got := availabilityNote(availability.Schedule{}, afternoon); got != "" {
+		t.Errorf("availabilityNote() with no windows = %q, want none", got)
+	}
+}
+
+func TestGPUPool(t *testing.T) {
+	hosts := []*Host{
+		{
+			Name:   "cool30",
+			Status: HostStatusOnline,
+			GPUs: []GPUInfo{
+				{Index: 0, Utilization: 95, MemUsed: "70000 MiB", MemTotal: "80 GiB"},
+				{Index: 1, Utilization: 0, MemUsed: "3 MiB", MemTotal: "80 GiB"},
+				{Index: 2, Utilization: 0, MemUsed: "3 MiB", MemTotal: "80 GiB"},
+			},
+			RunningJobs: []HostRunningJob{
+				{ID: 42, GPUs: []JobGPUUsage{{GPUIndex: 0, MemUsed: "69000"}}, Reserved: []int{0, 2}},
+			},
+		},
+		{
+			Name:   "cool31",
+			Status: HostStatusOffline,
+			GPUs:   []GPUInfo{{Index: 0, MemUsed: "20000 MiB", MemTotal: "24 GiB", JobID: 7}},
+		},
+	}
+
+	gpus := gpuPool(hosts)
+	want := []struct {
+		host   string
+		index  int
+		state  gpupool.State
+		jobID  int64
+		cached bool
+	}{
+		{"cool30", 1, gpupool.StateFree, 0, false},
+		{"cool30", 2, gpupool.StateReserved, 42, false},
+		{"cool30", 0, gpupool.StateInUse, 42, false},
+		{"cool31", 0, gpupool.StateInUse, 7, true},
+	}
+	if len(gpus) != len(want) {
+		t.Fatalf("gpuPool() returned %d GPUs, want %d", len(gpus), len(want))
+	}
+	for i, w := range want {
+		g := gpus[i]
+		if g.Host != w.host || g.Index != w.index || g.State != w.state || g.JobID != w.jobID || g.Cached != w.cached {
+			t.Errorf("gpus[%d] = %s:%d %s job %d cached %v, want %s:%d %s job %d cached %v",
+				i, g.Host, g.Index, g.State, g.JobID, g.Cached, w.host, w.index, w.state, w.jobID, w.cached)
+		}
+	}
+}
+
+func TestLatencySummary(t *testing.T) {
+	h := &Host{Name: "cool30"}
+	if got := h.LatencySummary(); got != "-" {
+		t.Errorf("LatencySummary() before measuring = %q, want \"-\"", got)
+	}
+	h.Latency = &db.HostLatency{Average: 42 * time.Millisecond}
+	if got := h.LatencySummary(); got != "42ms" {
+		t.Errorf("LatencySummary() = %q, want \"42ms\"", got)
+	}
+}
+
+func TestParsePartialHostInfo(t *testing.T) {
+	// Cut off in the middle of the jobs, after the queue
+	output := `{"arch":"Linux x86_64","cpus":32,"gpus":[{"index":0,"name":"NVIDIA A100","mem_used_mib":100,"mem_total_mib":81920}],` +
+		`"queue":{"runner":true,"current":"","depth":3,"stop":false},"jobs":[{"id":7,"pid":12`
+	host, running, ok := parsePartialHostInfo(output)
+	if !ok {
+		t.Fatal("parsePartialHostInfo() found nothing")
+	}
+	if !host.Partial || host.Arch != "Linux x86_64" || host.CPUs != 32 || len(host.GPUs) != 1 || host.QueuedJobCount != 3 {
+		t.Errorf("parsePartialHostInfo() = %+v, want the fields before the jobs", host)
+	}
+	if running != nil {
+		t.Errorf("parsePartialHostInfo() jobs = %v, want none from a cut-off list", running)
+	}
+
+	prev := &Host{Name: "cool30", MemTotal: "512G", RunningJobs: []HostRunningJob{{ID: 5}}}
+	host.keepMissing(prev)
+	if host.MemTotal != "512G" || len(host.RunningJobs) != 1 || host.Arch != "Linux x86_64" {
+		t.Errorf("keepMissing() = %+v, want the missing memory and jobs from before", host)
+	}
+
+	for _, bad := range []string{"", "not json", `{"arch":"Linux`} {
+		if _, _, ok := parsePartialHostInfo(bad); ok {
+			t.Errorf("parsePartialHostInfo(%q) succeeded, want nothing", bad)
+		}
+	}
+}
+
+func TestHostTools(t *testing.T) {
+	output := `{"arch":"Linux x86_64","tools":{"python":"3.10.12","conda":"","cuda":"12.2","cuda-driver":"535.104.05","docker":"","git":"2.34.1"}}`
+	host, _, err := parseHostInfo(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := host.Tools.String(), "python 3.10.12, cuda 12.2, cuda-driver 535.104.05, git 2.34.1"; got != want {
+		t.Errorf("Tools = %q, want %q", got, want)
+	}
+
+	// The tools survive the host info cache, and a query cut off before them
+	cached := hostFromCachedInfo(cachedInfoFromHost(host))
+	if cached.Tools["cuda"] != "12.2" {
+		t.Errorf("cached Tools = %v, want those probed", cached.Tools)
+	}
+	partial, _, _ := parsePartialHostInfo(`{"arch":"Linux x86_64","cpus":8,"gpus":[`)
+	partial.keepMissing(host)
+	if partial.Tools["python"] != "3.10.12" {
+		t.Errorf("keepMissing() Tools = %v, want those from before", partial.Tools)
+	}
+
+	// Info from before the tools were probed doesn't claim the host has none
+	host, _, _ = parseHostInfo(`{"arch":"Linux x86_64"}`)
+	if host.Tools != nil {
+		t.Errorf("Tools = %v without a tools field, want nil", host.Tools)
+	}
 }
@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// jobListLimit is how many of the most recent jobs the Jobs view loads. Only
+// the rows that fit in the list panel are rendered, so this bounds the work
+// of each refresh rather than of each frame.
+const jobListLimit = 2000
+
+// jobListRow is a line of the job list: a group's header or a job
+type jobListRow struct {
+	group *jobGroup
+	job   *db.Job
+}
+
+// jobRows returns a row for each job
+func jobRows(jobs []*db.Job) []jobListRow {
+	rows := make([]jobListRow, len(jobs))
+	for i, job := range jobs {
+		rows[i] = jobListRow{job: job}
+	}
+	return rows
+}
+
+// listRows returns the lines of the job list
+func (m Model) listRows() []jobListRow {
+	if m.groupMode == groupNone && len(m.rows) != len(m.jobs) {
+		// The jobs were set without applyJobFilter laying them out
+		return jobRows(m.jobs)
+	}
+	return m.rows
+}
+
+// cursorRow returns the index in rows of the highlighted row
+func (m Model) cursorRow(rows []jobListRow) int {
+	if m.groupMode == groupNone {
+		// Each row is a job
+		return m.selectedIndex
+	}
+	for i, row := range rows {
+		switch {
+		case m.headerSelected:
+			if row.group != nil && row.group.Name == m.selectedGroup {
+				return i
+			}
+		case row.job != nil && m.selectedIndex < len(m.jobs) && row.job == m.jobs[m.selectedIndex]:
+			return i
+		}
+	}
+	return 0
+}
+
+// moveCursor moves the highlight by delta rows of the job list, onto a job
+// or a group's header, stopping at the first and last rows, and reports
+// whether it moved
+func (m *Model) moveCursor(delta int) bool {
+	rows := m.listRows()
+	if len(rows) == 0 {
+		return false
+	}
+	from := m.cursorRow(rows)
+	to := max(0, min(from+delta, len(rows)-1))
+	if to == from {
+		return false
+	}
+	m.setCursorRow(rows, to)
+	return true
+}
+
+// setCursorRow highlights row i of rows, scrolling to show it
+func (m *Model) setCursorRow(rows []jobListRow, i int) {
+	defer m.scrollToCursor()
+	if g := rows[i].group; g != nil {
+		m.headerSelected, m.selectedGroup = true, g.Name
+		return
+	}
+	m.headerSelected = false
+	if m.groupMode == groupNone {
+		m.selectedIndex = i
+		return
+	}
+	for j, job := range m.jobs {
+		if job == rows[i].job {
+			m.selectedIndex = j
+		}
+	}
+}
+
+// listPageSize returns how many rows of the job list its panel shows
+func (m Model) listPageSize() int {
+	list, _ := m.panelHeights()
+	return max(list-5, 1) // Less the borders, header, and filter line
+}
+
+// scrollToCursor scrolls the job list as little as possible to show the
+// highlighted row
+func (m *Model) scrollToCursor() {
+	rows := m.listRows()
+	m.listOffset = scrollOffset(m.listOffset, m.cursorRow(rows), m.listPageSize(), len(rows))
+}
+
+// firstListRow returns the index of the first row the job list shows
+func (m Model) firstListRow(rows []jobListRow, page int) int {
+	return scrollOffset(m.listOffset, m.cursorRow(rows), page, len(rows))
+}
+
+// scrollOffset returns the first of n rows to show, page at a time, moved as
+// little as possible from offset to show the row at cursor, and no further
+// than needed to fill the page
+func scrollOffset(offset, cursor, page, n int) int {
+	if cursor < offset {
+		offset = cursor
+	}
+	if cursor >= offset+page {
+		offset = cursor - page + 1
+	}
+	return max(0, min(offset, n-page))
+}
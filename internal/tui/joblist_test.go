@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestScrollOffset(t *testing.T) {
+	tests := []struct {
+		name                    string
+		offset, cursor, page, n int
+		want                    int
+	}{
+		{"cursor shown", 5, 7, 10, 100, 5},
+		{"cursor above", 5, 2, 10, 100, 2},
+		{"cursor below", 5, 20, 10, 100, 11},
+		{"past the end", 95, 96, 10, 100, 90},
+		{"fewer rows than a page", 3, 1, 10, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scrollOffset(tt.offset, tt.cursor, tt.page, tt.n); got != tt.want {
+				t.Errorf("scrollOffset(%d, %d, %d, %d) = %d, want %d", tt.offset, tt.cursor, tt.page, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+// manyJobs returns jobs with IDs from n down to 1, newest first as listed
+func manyJobs(n int) []*db.Job {
+	jobs := make([]*db.Job, n)
+	for i := range jobs {
+		jobs[i] = &db.Job{ID: int64(n - i), Host: "cool30", Status: db.StatusCompleted, Command: "python train.py"}
+	}
+	return jobs
+}
+
+func TestJobListRendersVisibleRows(t *testing.T) {
+	m := Model{allJobs: manyJobs(1500), jobsLoaded: true, width: 100, height: 40}
+	m.applyJobFilter()
+	m.moveCursor(700)
+
+	page := m.listPageSize()
+	first := m.firstListRow(m.listRows(), page)
+	if first > 700 || first+page <= 700 {
+		t.Fatalf("first row shown = %d with %d rows a page, want row 700 shown", first, page)
+	}
+	list, _ := m.panelHeights()
+	view := m.renderJobList(list)
+	if n := strings.Count(view, "python train.py"); n != page {
+		t.Errorf("rendered %d job rows, want %d", n, page)
+	}
+	if !strings.Contains(view, "of 1500]") {
+		t.Error("expected the filter line to show the scroll position")
+	}
+}
+
+func TestJobListKeepsPositionAcrossRefresh(t *testing.T) {
+	m := Model{allJobs: manyJobs(100), jobsLoaded: true, width: 100, height: 40}
+	m.applyJobFilter()
+	m.moveCursor(50)
+	selected := m.getTargetJob().ID
+	screenRow := m.cursorRow(m.listRows()) - m.listOffset
+
+	// Three new jobs arrive at the top of the list
+	m.allJobs = manyJobs(103)
+	m.applyJobFilter()
+
+	if got := m.getTargetJob().ID; got != selected {
+		t.Errorf("selected job = %d after refresh, want %d", got, selected)
+	}
+	if got := m.cursorRow(m.listRows()) - m.listOffset; got != screenRow {
+		t.Errorf("selected job moved from screen row %d to %d", screenRow, got)
+	}
+}
+
+func TestMoveCursorStopsAtEnds(t *testing.T) {
+	m := Model{allJobs: manyJobs(5), width: 100, height: 40}
+	m.applyJobFilter()
+	if m.moveCursor(-1) {
+		t.Error("moveCursor(-1) at the first row moved")
+	}
+	if !m.moveCursor(100) || m.selectedIndex != 4 {
+		t.Errorf("moveCursor(100) selected row %d, want the last, 4", m.selectedIndex)
+	}
+	if m.moveCursor(1) {
+		t.Error("moveCursor(1) at the last row moved")
+	}
+}
+
+func TestJobsRefreshAppliesChanges(t *testing.T) {
+	m := Model{allJobs: manyJobs(100), jobsLoaded: true, jobsRevision: 7, width: 100, height: 40}
+	m.applyJobFilter()
+	m.moveCursor(50)
+	selected := m.getTargetJob()
+
+	// A refresh with no changes leaves the list as it was laid out
+	rows := m.rows
+	updated, _ := m.Update(jobsRefreshedMsg{changes: &db.JobChanges{Revision: 7}, revision: 7})
+	m = updated.(Model)
+	if &m.rows[0] != &rows[0] || m.getTargetJob() != selected {
+		t.Fatal("a refresh with no changes rebuilt the job list")
+	}
+
+	// A job is added, the selected one finishes, and another is deleted
+	finished := *selected
+	finished.Status = db.StatusDead
+	changes := &db.JobChanges{
+		Changed:  []*db.Job{{ID: 101, Host: "cool30", Status: db.StatusRunning}, &finished},
+		Removed:  []int64{99},
+		Revision: 9,
+	}
+	updated, _ = m.Update(jobsRefreshedMsg{changes: changes, revision: 9})
+	m = updated.(Model)
+	if len(m.allJobs) != 100 || m.allJobs[0].ID != 101 || m.allJobs[1].ID != 100 {
+		t.Fatalf("after the refresh the jobs start %d, %d of %d, want 101, 100 of 100", m.allJobs[0].ID, m.allJobs[1].ID, len(m.allJobs))
+	}
+	if got := m.getTargetJob(); got != &finished {
+		t.Errorf("selected job = %+v after the refresh, want the updated job %d", got, finished.ID)
+	}
+	if m.jobsRevision != 9 {
+		t.Errorf("jobsRevision = %d, want 9", m.jobsRevision)
+	}
+}
+
+func TestJobsRefreshReloadsFullListAfterRemovals(t *testing.T) {
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	var ids []int64
+	for range 3 {
+		id, err := db.RecordStart(database, "cool30", "", "~/code", "make", 1000, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	revision, err := db.JobsRevision(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteJob(database, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// A list with room for more only drops the removed job
+	m := Model{database: database, allJobs: manyJobs(10), jobsLoaded: true, jobsRevision: revision}
+	msg := m.refreshJobs()().(jobsRefreshedMsg)
+	if msg.err != nil || msg.changes == nil || len(msg.changes.Removed) != 1 {
+		t.Errorf("refresh of a short list = %+v, want the changes", msg)
+	}
+
+	// A full list is read again, so that older jobs fill the removed places
+	m.allJobs = manyJobs(jobListLimit)
+	msg = m.refreshJobs()().(jobsRefreshedMsg)
+	if msg.err != nil || msg.changes != nil || len(msg.jobs) != 2 {
+		t.Errorf("refresh of a full list = %+v, want the 2 remaining jobs", msg)
+	}
+}
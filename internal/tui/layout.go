@@ -0,0 +1,77 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Layout breakpoints. Below minWidth×minHeight the TUI shows only a notice
+// that the terminal is too small. Narrower than compactWidth, the job list
+// drops its HOST and STARTED columns; shorter than compactHeight, one panel
+// fills the screen instead of the list and detail panels sharing it.
+const (
+	minWidth      = 40
+	minHeight     = 10
+	compactWidth  = 80
+	compactHeight = 24
+)
+
+// tooSmall reports whether the terminal is below the minimum size
+func (m Model) tooSmall() bool {
+	return m.width < minWidth || m.height < minHeight
+}
+
+// narrow reports whether the terminal is narrower than the full layout needs
+func (m Model) narrow() bool {
+	return m.width < compactWidth
+}
+
+// panelHeights returns the heights, inside their borders, of the list panel
+// and the detail panel below it; a height of 0 means the panel is hidden.
+// Together the panels leave room for the flash message and status bar. On
+// short terminals the jobs view shows the list, or only the detail panel in
+// the Logs and Diff tabs, and the other views show only their list.
+func (m Model) panelHeights() (list, detail int) {
+	if m.height >= compactHeight {
+		// Two panels with two border lines each, split 55:35
+		avail := m.height - 6
+		list = avail * 55 / 90
+		return list, avail - list
+	}
+	// One panel with its borders
+	full := m.height - 4
+	if m.viewMode == ViewModeJobs && m.detailTab != DetailTabDetails {
+		return 0, full
+	}
+	return full, 0
+}
+
+// renderTooSmall is shown instead of the panels when the terminal is below
+// the minimum size
+func (m Model) renderTooSmall() string {
+	msg := fmt.Sprintf("Terminal too small (%d×%d)\nNeeds at least %d×%d\n\nq:quit", m.width, m.height, minWidth, minHeight)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		dimStyle.Render(msg), lipgloss.WithWhitespaceChars(" "))
+}
+
+// fitToScreen cuts a rendered view to the terminal's size, so a line that is
+// too long or a panel that grew can't scroll or wrap the display
+func (m Model) fitToScreen(view string) string {
+	return lipgloss.NewStyle().MaxWidth(m.width).MaxHeight(m.height).Render(view)
+}
+
+// statusHelp returns the key hints for a status bar, or just the essential
+// ones if the full hints don't fit
+func (m Model) statusHelp(full string) string {
+	if lipgloss.Width(full) > m.width-2 {
+		return helpStyle.Render("?:help ::commands q:quit")
+	}
+	return helpStyle.Render(full)
+}
+
+// fitWidth cuts s to width terminal cells, ending it with "…" if it is cut
+func fitWidth(s string, width int) string {
+	return ansi.Truncate(s, width, "…")
+}
@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestViewFitsTerminal(t *testing.T) {
+	jobs := []*db.Job{
+		{ID: 1, Host: "a-rather-long-hostname", Status: db.StatusCompleted, Command: strings.Repeat("python train.py ", 10)},
+		{ID: 2, Host: "cool30", Status: db.StatusRunning, Command: "make"},
+	}
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	sizes := []struct{ width, height int }{{120, 40}, {60, 30}, {50, 15}, {80, 12}, {30, 8}}
+	for _, size := range sizes {
+		for _, tab := range []DetailTab{DetailTabLogs, DetailTabDetails} {
+			m := Model{database: database, jobs: jobs, jobsLoaded: true, width: size.width, height: size.height, detailTab: tab}
+			view := m.View()
+			lines := strings.Split(view, "\n")
+			if len(lines) > size.height {
+				t.Errorf("%dx%d tab %d: %d lines", size.width, size.height, tab, len(lines))
+			}
+			if w := lipgloss.Width(view); w > size.width {
+				t.Errorf("%dx%d tab %d: %d columns", size.width, size.height, tab, w)
+			}
+		}
+	}
+}
+
+func TestPanelHeights(t *testing.T) {
+	m := Model{width: 100, height: 40}
+	if list, detail := m.panelHeights(); list == 0 || detail == 0 {
+		t.Errorf("tall terminal: panels %d, %d; want both shown", list, detail)
+	}
+	// Each panel has a border line above and below, and the flash message
+	// and status bar take a line each
+	if list, detail := m.panelHeights(); list+detail+6 != m.height {
+		t.Errorf("tall terminal: panels %d, %d don't fill %d lines", list, detail, m.height)
+	}
+	m.height = 15
+	if list, detail := m.panelHeights(); list != 11 || detail != 0 {
+		t.Errorf("short terminal, Details tab: panels %d, %d; want 11, 0", list, detail)
+	}
+	m.detailTab = DetailTabLogs
+	if list, detail := m.panelHeights(); list != 0 || detail != 11 {
+		t.Errorf("short terminal, Logs tab: panels %d, %d; want 0, 11", list, detail)
+	}
+	m.height = 8
+	if !m.tooSmall() || !strings.Contains(m.View(), "Terminal too small") {
+		t.Error("expected a notice on a terminal below the minimum size")
+	}
+}
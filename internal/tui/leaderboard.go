@@ -0,0 +1,201 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/results"
+)
+
+// showLeaderboard switches to the leaderboard view, ranking by the metric
+// shown last or else the first one recorded
+func (m Model) showLeaderboard() Model {
+	m.viewMode = ViewModeLeaderboard
+	m.leaderboardMetrics, _ = db.ListResultNames(m.database)
+	if !slices.Contains(m.leaderboardMetrics, m.leaderboardMetric) {
+		m.leaderboardMetric = ""
+		if len(m.leaderboardMetrics) > 0 {
+			m.setLeaderboardMetric(m.leaderboardMetrics[0])
+		}
+	}
+	m.loadLeaderboard()
+	return m
+}
+
+// leaderboardEntry is a job's place on the leaderboard, with the job
+type leaderboardEntry struct {
+	results.Entry
+	Job *db.Job
+}
+
+// setLeaderboardMetric ranks by metric, in the order its name suggests
+func (m *Model) setLeaderboardMetric(metric string) {
+	m.leaderboardMetric = metric
+	m.leaderboardAscending = results.LowerIsBetter(metric)
+	m.leaderboardIdx = 0
+}
+
+// loadLeaderboard ranks the successfully completed jobs by the current metric
+func (m *Model) loadLeaderboard() {
+	m.leaderboard = nil
+	if m.leaderboardMetric == "" {
+		return
+	}
+	jobs, err := db.ListJobsWithResult(m.database, m.leaderboardMetric, "", "")
+	if err != nil {
+		return
+	}
+	ids := make([]int64, len(jobs))
+	byID := make(map[int64]*db.Job, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+		byID[job.ID] = job
+	}
+	metrics, err := db.LoadJobResults(m.database, ids)
+	if err != nil {
+		return
+	}
+	m.leaderboardResults = metrics
+	for _, e := range results.Rank(ids, metrics, m.leaderboardMetric, m.leaderboardAscending) {
+		m.leaderboard = append(m.leaderboard, leaderboardEntry{Entry: e, Job: byID[e.JobID]})
+	}
+	if m.leaderboardIdx >= len(m.leaderboard) {
+		m.leaderboardIdx = max(len(m.leaderboard)-1, 0)
+	}
+}
+
+// handleLeaderboardKey handles the keys of the leaderboard view: moving
+// through the ranking, changing the metric and order, and opening a job in
+// the jobs view. Other keys that act on jobs are ignored here; handled is
+// false for those the jobs view also handles, such as quitting.
+func (m Model) handleLeaderboardKey(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	switch msg.String() {
+	case "up":
+		if m.leaderboardIdx > 0 {
+			m.leaderboardIdx--
+		}
+	case "down":
+		if m.leaderboardIdx < len(m.leaderboard)-1 {
+			m.leaderboardIdx++
+		}
+	case "left", "right":
+		if len(m.leaderboardMetrics) == 0 {
+			return m, nil, true
+		}
+		i := max(slices.Index(m.leaderboardMetrics, m.leaderboardMetric), 0)
+		if msg.String() == "left" {
+			i = (i - 1 + len(m.leaderboardMetrics)) % len(m.leaderboardMetrics)
+		} else {
+			i = (i + 1) % len(m.leaderboardMetrics)
+		}
+		m.setLeaderboardMetric(m.leaderboardMetrics[i])
+		m.loadLeaderboard()
+	case "a":
+		m.leaderboardAscending = !m.leaderboardAscending
+		m.leaderboardIdx = 0
+		m.loadLeaderboard()
+	case "enter":
+		if m.leaderboardIdx < len(m.leaderboard) {
+			id := m.leaderboard[m.leaderboardIdx].Job.ID
+			if !m.selectJob(id) {
+				cmd := m.setFlash(fmt.Sprintf("Job %d isn't shown by the current filter", id), true)
+				return m, cmd, true
+			}
+		}
+	case "L", "j", "tab", "esc":
+		m.viewMode = ViewModeJobs
+	case "q", "ctrl+c", "ctrl+z", "?", ":", "h", "G":
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// renderLeaderboard renders the ranking, best job first
+func (m Model) renderLeaderboard(height int) string {
+	var rows []string
+	if m.leaderboardMetric == "" {
+		rows = append(rows, dimStyle.Render(" No results recorded yet. Start jobs with run --result or --results-file."))
+		return listPanelStyle.Width(m.width - 2).Height(height).Render(strings.Join(rows, "\n"))
+	}
+
+	header := fmt.Sprintf(" %4s %-6s %-12s %-12s %-10s %s",
+		"RANK", "ID", truncate(strings.ToUpper(m.leaderboardMetric), 12), "HOST", "COMMIT", "COMMAND / DESCRIPTION")
+	rows = append(rows, headerStyle.Render(fitWidth(header, m.width-4)))
+	if len(m.leaderboard) == 0 {
+		rows = append(rows, dimStyle.Render(" No completed jobs with "+m.leaderboardMetric))
+	}
+
+	// Keep the highlighted entry in view
+	contentHeight := height - 4
+	start := 0
+	if m.leaderboardIdx >= contentHeight && contentHeight > 0 {
+		start = m.leaderboardIdx - contentHeight + 1
+	}
+	for i := start; i < len(m.leaderboard) && i < start+contentHeight; i++ {
+		e := m.leaderboard[i]
+		commit := "-"
+		if rev, _ := db.GetJobGit(m.database, e.Job.ID); rev != nil {
+			commit = rev.Short()
+		}
+		width := max(m.width-56, 10)
+		line := fmt.Sprintf(" %4d #%-5d %-12s %-12s %-10s %s",
+			e.Rank, e.Job.ID, results.FormatValue(e.Value), truncate(e.Job.Host, 12), commit, truncate(m.jobLabel(e.Job), width))
+		line = fitWidth(line, m.width-4)
+		if i == m.leaderboardIdx {
+			line = selectedStyle.Width(m.width - 4).Render(line)
+		}
+		rows = append(rows, line)
+	}
+	return listPanelStyle.Width(m.width - 2).Height(height).Render(strings.Join(rows, "\n"))
+}
+
+// renderLeaderboardDetail renders the highlighted job's command, commit, and
+// all its results
+func (m Model) renderLeaderboardDetail(height int) string {
+	order := "largest first"
+	if m.leaderboardAscending {
+		order = "smallest first"
+	}
+	title := "Leaderboard"
+	if m.leaderboardMetric != "" {
+		title = fmt.Sprintf("Leaderboard: %s, %s", m.leaderboardMetric, order)
+	}
+
+	var lines []string
+	if m.leaderboardIdx < len(m.leaderboard) {
+		job := m.leaderboard[m.leaderboardIdx].Job
+		lines = append(lines, fmt.Sprintf("Job:     #%d on %s", job.ID, job.Host))
+		lines = append(lines, fmt.Sprintf("Cmd:     %s", m.redactor.String(job.EffectiveCommand())))
+		if job.Description != "" {
+			lines = append(lines, fmt.Sprintf("Desc:    %s", job.Description))
+		}
+		lines = append(lines, fmt.Sprintf("Dir:     %s", job.EffectiveWorkingDir()))
+		if rev, _ := db.GetJobGit(m.database, job.ID); rev != nil {
+			lines = append(lines, fmt.Sprintf("Git:     %s", rev))
+		}
+		if tags, _ := db.GetJobTags(m.database, job.ID); len(tags) > 0 {
+			lines = append(lines, fmt.Sprintf("Tags:    %s", strings.Join(tags, ", ")))
+		}
+		lines = append(lines, fmt.Sprintf("Results: %s", results.Format(m.leaderboardResults[job.ID])))
+	}
+
+	availableLines := height - 4
+	if len(lines) > availableLines && availableLines > 0 {
+		lines = lines[:availableLines]
+	}
+	content := titleStyle.Render(title) + "\n" + strings.Join(lines, "\n")
+	return logPanelStyle.Width(m.width - 2).Height(height).Render(content)
+}
+
+func (m Model) renderLeaderboardStatusBar() string {
+	help := m.statusHelp("?:help q:quit ↑/↓:nav ←/→:metric a:order enter:open job j:jobs")
+	gap := m.width - lipgloss.Width(help) - 2
+	if gap < 0 {
+		gap = 0
+	}
+	return " " + strings.Repeat(" ", gap) + help
+}
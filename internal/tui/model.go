@@ -3,21 +3,48 @@ package tui
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/osteele/remote-jobs/internal/availability"
+	"github.com/osteele/remote-jobs/internal/background"
+	"github.com/osteele/remote-jobs/internal/clockskew"
+	"github.com/osteele/remote-jobs/internal/config"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/diskspace"
+	"github.com/osteele/remote-jobs/internal/gpupool"
+	"github.com/osteele/remote-jobs/internal/hooks"
+	"github.com/osteele/remote-jobs/internal/hostevents"
+	"github.com/osteele/remote-jobs/internal/hosttools"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/jobdiff"
+	"github.com/osteele/remote-jobs/internal/jobstate"
+	"github.com/osteele/remote-jobs/internal/notify"
+	"github.com/osteele/remote-jobs/internal/opendir"
+	"github.com/osteele/remote-jobs/internal/pathmap"
+	"github.com/osteele/remote-jobs/internal/prunepolicy"
+	"github.com/osteele/remote-jobs/internal/reachability"
+	"github.com/osteele/remote-jobs/internal/redact"
+	"github.com/osteele/remote-jobs/internal/results"
 	"github.com/osteele/remote-jobs/internal/scripts"
+	"github.com/osteele/remote-jobs/internal/secrets"
 	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/shellquote"
 	"github.com/osteele/remote-jobs/internal/ssh"
+	"github.com/osteele/remote-jobs/internal/timefmt"
+	"github.com/osteele/remote-jobs/internal/watchdog"
+	"github.com/osteele/remote-jobs/internal/webhooks"
 )
 
 // Default intervals for background operations
@@ -28,12 +55,31 @@ const (
 	DefaultHostCacheDuration   = 24 * time.Hour // How long cached host info is considered fresh
 )
 
+// hostEventWindow is how far back the host details panel shows incidents
+const hostEventWindow = 24 * time.Hour
+
+// maxHostProbes bounds how many host queries run at once, so that entering
+// the hosts view with many hosts doesn't open dozens of SSH connections
+const maxHostProbes = 8
+
+// hostSpinnerFrames animate the status of hosts being queried
+var hostSpinnerFrames = []string{"◐", "◓", "◑", "◒"}
+
+const hostSpinnerInterval = 150 * time.Millisecond
+
+// selectionSettleDelay is how long the highlighted job must stay highlighted
+// before its log and process stats are fetched, so that holding an arrow key
+// doesn't start SSH commands for every job passed over
+const selectionSettleDelay = 150 * time.Millisecond
+
 // ViewMode represents which view is currently active
 type ViewMode int
 
 const (
 	ViewModeJobs ViewMode = iota
 	ViewModeHosts
+	ViewModeLeaderboard
+	ViewModeFiles
 )
 
 // jobFilterMode controls which subset of jobs is displayed in the Jobs view
@@ -53,17 +99,23 @@ type DetailTab int
 const (
 	DetailTabDetails DetailTab = iota
 	DetailTabLogs
+	DetailTabDiff // Comparison of the highlighted job with the marked job
 )
 
 // Key bindings
 type keyMap struct {
 	Up          key.Binding
 	Down        key.Binding
+	PageUp      key.Binding
+	PageDown    key.Binding
+	Top         key.Binding
+	Bottom      key.Binding
 	Enter       key.Binding
 	Logs        key.Binding
 	Filter      key.Binding
 	Escape      key.Binding
 	Kill        key.Binding
+	Pause       key.Binding
 	Restart     key.Binding
 	EditRestart key.Binding
 	Remove      key.Binding
@@ -78,6 +130,21 @@ type keyMap struct {
 	Help        key.Binding
 	StartQueue  key.Binding
 	StartNow    key.Binding
+	Mark        key.Binding
+	Diff        key.Binding
+	Edit        key.Binding
+	GPUPool     key.Binding
+	Palette     key.Binding
+	Copy        key.Binding
+	OpenDir     key.Binding
+	Note        key.Binding
+	Ack         key.Binding
+	Leaderboard key.Binding
+	Group       key.Binding
+	Collapse    key.Binding
+	CollapseAll key.Binding
+	Stderr      key.Binding
+	Files       key.Binding
 }
 
 var keys = keyMap{
@@ -89,6 +156,22 @@ var keys = keyMap{
 		key.WithKeys("down"),
 		key.WithHelp("↓", "down"),
 	),
+	PageUp: key.NewBinding(
+		key.WithKeys("pgup"),
+		key.WithHelp("pgup", "page up"),
+	),
+	PageDown: key.NewBinding(
+		key.WithKeys("pgdown"),
+		key.WithHelp("pgdn", "page down"),
+	),
+	Top: key.NewBinding(
+		key.WithKeys("home"),
+		key.WithHelp("home", "first job"),
+	),
+	Bottom: key.NewBinding(
+		key.WithKeys("end"),
+		key.WithHelp("end", "last job"),
+	),
 	Enter: key.NewBinding(
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "select"),
@@ -109,6 +192,10 @@ var keys = keyMap{
 		key.WithKeys("k", "delete"),
 		key.WithHelp("k", "kill"),
 	),
+	Pause: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "pause/resume"),
+	),
 	Restart: key.NewBinding(
 		key.WithKeys("r"),
 		key.WithHelp("r", "restart"),
@@ -164,21 +251,98 @@ var keys = keyMap{
 		key.WithKeys("g"),
 		key.WithHelp("g", "start now"),
 	),
+	Mark: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "mark for diff"),
+	),
+	Diff: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "diff with marked"),
+	),
+	Edit: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit description & tags"),
+	),
+	GPUPool: key.NewBinding(
+		key.WithKeys("G"),
+		key.WithHelp("G", "GPU pool"),
+	),
+	Palette: key.NewBinding(
+		key.WithKeys(":"),
+		key.WithHelp(":", "command palette"),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy"),
+	),
+	OpenDir: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "open directory"),
+	),
+	Note: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("N", "edit note"),
+	),
+	Ack: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "acknowledge"),
+	),
+	Leaderboard: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "leaderboard"),
+	),
+	Group: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "group by"),
+	),
+	Collapse: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("z", "collapse/expand group"),
+	),
+	CollapseAll: key.NewBinding(
+		key.WithKeys("Z"),
+		key.WithHelp("Z", "collapse/expand all groups"),
+	),
+	Stderr: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "stderr only"),
+	),
+	Files: key.NewBinding(
+		key.WithKeys("F"),
+		key.WithHelp("F", "browse files"),
+	),
 }
 
 // Messages
+// jobsRefreshedMsg carries either the whole job list, on the first load, or
+// the changes to it since the revision the model last saw
 type jobsRefreshedMsg struct {
-	jobs []*db.Job
-	err  error
+	jobs        []*db.Job
+	changes     *db.JobChanges
+	revision    int64
+	stats       map[int64]*db.JobStats
+	pauses      map[int64]*db.JobPause
+	experiments map[int64]string // Of the listed jobs, or of the changed ones
+	err         error
 }
 
 type syncCompletedMsg struct {
-	updated int
-	err     error
+	updated      int
+	idleAlerts   []string                        // Jobs the idle-GPU watchdog flagged during this sync
+	reachability map[string]*db.HostReachability // Hosts' backoff state after this sync
+	processStats map[int64]*ssh.ProcessStats     // Samples of the running jobs on the hosts reached
+	resultErrors []string                        // From reading the results of jobs that finished
+	err          error
+}
+
+// hooksRanMsg reports the completion hooks run after a sync
+type hooksRanMsg struct {
+	err error // Each hook that failed
 }
 
 type logFetchedMsg struct {
 	jobID     int64
+	stderr    bool // The content is the job's stderr file rather than its log
 	content   string
 	err       error
 	connError bool // true if this was a connection error (host unreachable)
@@ -189,6 +353,17 @@ type jobKilledMsg struct {
 	err   error
 }
 
+type dirOpenedMsg struct {
+	jobID int64
+	err   error
+}
+
+type jobPausedMsg struct {
+	jobID  int64
+	paused bool // true if the job was paused, false if resumed
+	err    error
+}
+
 type jobRestartedMsg struct {
 	oldJobID int64
 	newJobID int64
@@ -205,6 +380,12 @@ type pruneCompletedMsg struct {
 	err   error
 }
 
+type autoPrunedMsg struct {
+	count   int
+	summary string
+	err     error
+}
+
 type queueStartedMsg struct {
 	host    string
 	already bool // true if queue was already running
@@ -217,8 +398,9 @@ type jobRemovedMsg struct {
 }
 
 type jobCreatedMsg struct {
-	jobID int64
-	err   error
+	jobID   int64
+	warning string // Set when the host's clock is skewed
+	err     error
 }
 
 type jobCreateProgressMsg struct {
@@ -229,11 +411,19 @@ type tickMsg time.Time
 type logTickMsg time.Time
 type createTickMsg time.Time
 type hostRefreshTickMsg time.Time
+
+type hostSpinnerTickMsg struct{}
+
+// selectionSettledMsg is sent selectionSettleDelay after the highlighted job
+// changes; seq identifies the change, so only the latest one fetches
+type selectionSettledMsg struct{ seq int }
 type flashExpiredMsg struct{}
 
 // Host-related messages
 type hostsLoadedMsg struct {
 	hostNames []string
+	cached    map[string]*db.CachedHostInfo // Cached info of the hosts that have it
+	latency   map[string]*db.HostLatency    // Recorded round-trip times
 	err       error
 }
 
@@ -242,19 +432,10 @@ type hostInfoMsg struct {
 	info     *Host
 }
 
-type queueStatusMsg struct {
-	hostName string
-	info     *QueueStatusInfo
-}
-
-type hostJobsGPUMsg struct {
-	hostName    string
-	runningJobs []HostRunningJob
-}
-
+// processStatsMsg carries samples of the running jobs on one host, keyed by
+// job ID
 type processStatsMsg struct {
-	jobID int64
-	stats *ssh.ProcessStats
+	stats map[int64]*ssh.ProcessStats
 }
 
 // Input field indices for new job form
@@ -266,6 +447,12 @@ const (
 	inputEnvVars
 )
 
+// Input field indices for the edit job form
+const (
+	editDescription = iota
+	editTags
+)
+
 // Model is the main TUI state
 type Model struct {
 	// View mode
@@ -277,6 +464,31 @@ type Model struct {
 	selectedIndex int
 	selectedJob   *db.Job
 	jobFilter     jobFilterMode
+	markedJobID   int64                  // Job to compare the highlighted job with, or 0
+	jobStats      map[int64]*db.JobStats // Queue times and last sampled resources, by job ID
+	jobPauses     map[int64]*db.JobPause // When paused jobs were paused, and for what, by job ID
+
+	jobExperiments map[int64]string // Experiments of the loaded jobs, by job ID
+	jobsRevision   int64            // Revision of the jobs table allJobs is up to date with
+
+	// Lines of the job list, laid out when the jobs or their filter or
+	// grouping change rather than on each frame, and the first one shown
+	rows       []jobListRow
+	listOffset int
+
+	// Grouping of the job list. When it is grouped, jobs holds the jobs of
+	// the groups that aren't collapsed, and the highlight can also be on a
+	// group's header.
+	groupMode       jobGroupMode
+	collapsedGroups map[string]bool // By group name
+	headerSelected  bool            // The highlight is on the header of selectedGroup
+	selectedGroup   string
+
+	// Startup: the job list saved by the last run is shown until the
+	// database has been read, and slower work waits for that first read
+	jobSnapshotPath  string
+	jobsFromSnapshot bool // The listed jobs are from the snapshot
+	jobsLoaded       bool // The job list has been read from the database
 
 	// Hosts data
 	hosts           []*Host
@@ -287,16 +499,19 @@ type Model struct {
 	logContent   string
 	logStale     bool             // true if showing cached content due to connection error
 	logCache     map[int64]string // cache of last successful log content per job
+	stderrCache  map[int64]string // Likewise for the jobs' stderr files
+	logStderr    bool             // The logs tab shows only stderr, for jobs that split it out
 	logLoading   bool
+	selectionSeq int // Counts changes of the highlighted job; see highlightChanged
 	logViewport  viewport.Model
 	flashMessage string
 	flashIsError bool
 	flashExpiry  time.Time
 
+	diffJobIDs [2]int64 // Marked and highlighted jobs shown in the Diff tab
+
 	// Process stats for running jobs
-	processStats      *ssh.ProcessStats
-	prevProcessStats  *ssh.ProcessStats // Previous sample for CPU% calculation
-	processStatsJobID int64
+	processStats map[int64]*ssh.ProcessStats // Latest sample of each running job; see recordProcessStats
 
 	// Operation state
 	restarting         bool
@@ -307,10 +522,18 @@ type Model struct {
 	inputMode      bool
 	inputFocus     int
 	inputs         []textinput.Model
+	inputPreview   bool   // Show the remote commands the form would run
+	previewText    string // The preview, rebuilt as the form changes (see updateLaunchPreview)
 	creatingJob    bool
 	createJobStart time.Time
 	createJobStep  string
 
+	// Edit description and tags mode
+	editMode   bool
+	editJobID  int64
+	editFocus  int
+	editInputs []textinput.Model
+
 	// Layout
 	width  int
 	height int
@@ -321,18 +544,82 @@ type Model struct {
 	// Background sync state
 	syncing      bool
 	lastSyncTime time.Time
+	runningHooks bool // Completion hooks started after a sync are still running
 
 	// Help overlay
 	showHelp bool
 
+	// GPU pool panel, shown in the hosts view in place of the host details
+	showGPUPool bool
+
+	// Leaderboard view: completed jobs ranked by a result metric
+	leaderboard          []leaderboardEntry
+	leaderboardResults   map[int64]map[string]float64 // All metrics of the ranked jobs
+	leaderboardMetrics   []string                     // Names of all recorded metrics
+	leaderboardMetric    string
+	leaderboardAscending bool
+	leaderboardIdx       int
+
+	// File browser: a directory of a job's or host's, listed over SFTP
+	filesHost    string
+	filesDir     string // Absolute path of the listed directory; empty until listed
+	filesEntries []ssh.RemoteFile
+	filesIdx     int
+	filesPending string // Directory being listed
+	filesSelect  string // Entry to highlight once it is listed
+	filesErr     error  // Why the first directory couldn't be listed
+	filesReturn  ViewMode
+	filesLocal   string // Local directory that downloads go to; empty if unknown
+
+	// Command palette: fuzzy-searchable list of actions, opened with ":"
+	paletteMode     bool
+	paletteInput    textinput.Model
+	paletteIndex    int // Highlighted row among the matching commands
+	paletteCommands []paletteCommand
+
+	// Copy key pressed; the next key picks what to copy (see copyFields)
+	copyPending bool
+	// OSC 52 sequence that View writes out with the next frame, so that it
+	// reaches the terminal through the renderer (see handleClipboardCopied)
+	clipboardSequence string
+
 	// Configurable intervals
 	syncInterval        time.Duration
 	logRefreshInterval  time.Duration
 	hostRefreshInterval time.Duration
 	hostCacheDuration   time.Duration
 
+	// Idle-GPU watchdog settings (disabled unless IdleGPUMinutes is set)
+	watchdog config.Watchdog
+
+	// How job times are displayed
+	times timefmt.Options
+
+	// Prune policy applied on startup if its Auto flag is set
+	prunePolicy config.PrunePolicy
+
+	// open_dir setting for a host, or nil for the default
+	openDir func(host string) string
+
+	// Path mappings for a host, or nil for none
+	pathMappings func(host string) []pathmap.Mapping
+
+	// Hides credentials in commands and logs; nil redacts nothing
+	redactor *redact.Redactor
+
+	// Availability windows for a host, or nil for always available
+	availability func(host string) (availability.Schedule, error)
+
+	// Refuse actions that change jobs (see mutatingKeys)
+	readOnly bool
+
 	// Host cache tracking - which hosts have been freshly queried this session
 	hostsQueriedThisSession map[string]bool
+
+	// Host queries in flight hold a slot; the spinner animates their rows
+	hostProbeSlots    chan struct{}
+	hostSpinnerFrame  int
+	hostSpinnerActive bool
 }
 
 // ModelOptions contains configuration for the TUI model
@@ -341,6 +628,15 @@ type ModelOptions struct {
 	LogRefreshInterval  time.Duration
 	HostRefreshInterval time.Duration
 	HostCacheDuration   time.Duration // How long cached host info is considered fresh
+	Watchdog            config.Watchdog
+	TimeDisplay         timefmt.Options // Style defaults to auto
+	PrunePolicy         config.PrunePolicy
+	OpenDir             func(host string) string // open_dir setting for a host; nil means the default
+	PathMappings        func(host string) []pathmap.Mapping
+	Redactor            *redact.Redactor // Hides credentials in commands and logs
+	Availability        func(host string) (availability.Schedule, error)
+	JobSnapshot         string // File the job list is saved in, to show at the next start; "" for none
+	ReadOnly            bool   // Refuse actions that change jobs, such as kill, remove, and run
 }
 
 // DefaultModelOptions returns the default TUI options
@@ -393,47 +689,113 @@ func NewModelWithOptions(database *sql.DB, opts ModelOptions) Model {
 	inputs[inputEnvVars].Width = 40
 	inputs[inputEnvVars].CharLimit = 512
 
-	return Model{
+	// Create text inputs for edit job form
+	editInputs := make([]textinput.Model, 2)
+
+	editInputs[editDescription] = textinput.New()
+	editInputs[editDescription].Placeholder = "(none)"
+	editInputs[editDescription].Prompt = ""
+	editInputs[editDescription].Width = 40
+	editInputs[editDescription].CharLimit = 256
+
+	editInputs[editTags] = textinput.New()
+	editInputs[editTags].Placeholder = "tag1, tag2 (optional)"
+	editInputs[editTags].Prompt = ""
+	editInputs[editTags].Width = 40
+	editInputs[editTags].CharLimit = 256
+
+	paletteInput := textinput.New()
+	paletteInput.Placeholder = "kill 42, filter failure, logs 17..."
+	paletteInput.Prompt = ": "
+	paletteInput.Width = 60
+	paletteInput.CharLimit = 128
+
+	m := Model{
 		database:                database,
 		selectedIndex:           0,
 		jobFilter:               jobFilterAll,
 		inputs:                  inputs,
+		editInputs:              editInputs,
+		paletteInput:            paletteInput,
 		syncInterval:            opts.SyncInterval,
 		logRefreshInterval:      opts.LogRefreshInterval,
 		hostRefreshInterval:     opts.HostRefreshInterval,
 		hostCacheDuration:       opts.HostCacheDuration,
+		watchdog:                opts.Watchdog,
+		times:                   opts.TimeDisplay.WithDefaultStyle(timefmt.StyleAuto),
+		prunePolicy:             opts.PrunePolicy,
+		openDir:                 opts.OpenDir,
+		pathMappings:            opts.PathMappings,
+		availability:            opts.Availability,
+		redactor:                opts.Redactor,
 		hostsQueriedThisSession: make(map[string]bool),
+		hostProbeSlots:          make(chan struct{}, maxHostProbes),
 		logCache:                make(map[int64]string),
+		processStats:            make(map[int64]*ssh.ProcessStats),
+		jobSnapshotPath:         opts.JobSnapshot,
+		readOnly:                opts.ReadOnly,
+	}
+	if jobs := loadJobSnapshot(opts.JobSnapshot); jobs != nil {
+		m.allJobs = jobs
+		m.applyJobFilter()
+		m.jobsFromSnapshot = true
 	}
+	return m
 }
 
-// Init initializes the model
+// Init initializes the model. Only the job list is read at first, so it
+// replaces the snapshot quickly; startDeferred begins the rest once it has.
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.refreshJobs(),
-		m.loadHosts(),
 		m.startSyncTicker(),
 		m.startLogTicker(),
 		m.startHostRefreshTicker(),
 	)
 }
 
+// startDeferred begins the startup work that waits for the job list: loading
+// the host cache, auto-pruning, a first sync (rather than waiting for the sync
+// interval), and fetching the highlighted job's log so the logs tab opens
+// with it
+func (m *Model) startDeferred() tea.Cmd {
+	cmds := []tea.Cmd{m.loadHosts(), m.autoPrune()}
+	if !m.syncing {
+		m.syncing = true
+		cmds = append(cmds, m.performBackgroundSync())
+	}
+	if job := m.getTargetJob(); job != nil && job.Status != db.StatusQueued && job.Status != db.StatusPending {
+		cmds = append(cmds, m.fetchJobLog(job))
+	}
+	return tea.Batch(cmds...)
+}
+
 // Update handles messages
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		// Update viewport dimensions for log scrolling
-		detailHeight := int(float64(m.height) * 0.35)
-		m.logViewport.Width = m.width - 6
-		m.logViewport.Height = detailHeight - 4
+		// Update viewport dimensions for log scrolling, as laid out in the
+		// Logs tab
+		logs := m
+		logs.viewMode, logs.detailTab = ViewModeJobs, DetailTabLogs
+		_, detailHeight := logs.panelHeights()
+		m.logViewport.Width = max(m.width-6, 1)
+		m.logViewport.Height = max(detailHeight-4, 1)
+		m.scrollToCursor()
 		return m, nil
 
 	case tea.KeyMsg:
 		if m.inputMode {
 			return m.handleInputKeyPress(msg)
 		}
+		if m.editMode {
+			return m.handleEditKeyPress(msg)
+		}
+		if m.paletteMode {
+			return m.handlePaletteKeyPress(msg)
+		}
 		return m.handleKeyPress(msg)
 
 	case tea.MouseMsg:
@@ -443,42 +805,91 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.err != nil {
 			return m, m.setFlash(fmt.Sprintf("Error loading jobs: %v", msg.err), true)
 		}
-		m.allJobs = msg.jobs
+		m.jobStats = msg.stats
+		m.jobPauses = msg.pauses
+		switch {
+		case msg.changes == nil:
+			m.allJobs = msg.jobs
+			m.jobExperiments = msg.experiments
+		case msg.revision > m.jobsRevision && !msg.changes.Empty():
+			m.applyJobChanges(msg.changes, msg.experiments)
+		default:
+			// Nothing changed: keep the list as laid out
+			return m, nil
+		}
+		m.jobsRevision = msg.revision
 		m.applyJobFilter()
+		m.jobsFromSnapshot = false
+		if m.viewMode == ViewModeLeaderboard {
+			m.loadLeaderboard()
+		}
+		cmds := []tea.Cmd{m.saveJobSnapshot(m.allJobs)}
+		if !m.jobsLoaded {
+			m.jobsLoaded = true
+			cmds = append(cmds, m.startDeferred())
+		}
 
 		// If there's a pending job selection, find and select it
 		if m.pendingSelectJobID > 0 {
 			for i, job := range m.jobs {
 				if job.ID == m.pendingSelectJobID {
 					m.selectedIndex = i
+					m.headerSelected = false
+					m.scrollToCursor()
 					break
 				}
 			}
 			m.pendingSelectJobID = 0
 		}
-		return m, nil
+		return m, tea.Batch(cmds...)
 
 	case syncCompletedMsg:
 		m.syncing = false
 		m.lastSyncTime = time.Now()
+		m.applyReachability(msg.reachability)
+		m.recordProcessStats(msg.processStats)
+		// Hooks run apart from the sync, so that slow ones don't hold it up
+		var runHooks tea.Cmd
+		if !m.runningHooks {
+			m.runningHooks = true
+			runHooks = m.runHooks()
+		}
 		if msg.err != nil {
-			return m, m.setFlash(fmt.Sprintf("Sync error: %v", msg.err), true)
+			return m, tea.Batch(m.setFlash(fmt.Sprintf("Sync error: %v", msg.err), true), runHooks)
+		} else if len(msg.idleAlerts) > 0 {
+			return m, tea.Batch(m.setFlash("Idle GPUs: "+strings.Join(msg.idleAlerts, "; "), true), m.refreshJobs(), runHooks)
+		} else if len(msg.resultErrors) > 0 {
+			return m, tea.Batch(m.setFlash("Results: "+strings.Join(msg.resultErrors, "; "), true), m.refreshJobs(), runHooks)
 		} else if msg.updated > 0 {
 			// Silently refresh jobs without flash message
-			return m, m.refreshJobs()
+			return m, tea.Batch(m.refreshJobs(), runHooks)
+		}
+		return m, runHooks
+
+	case hooksRanMsg:
+		m.runningHooks = false
+		if msg.err != nil {
+			return m, m.setFlash("Hooks: "+strings.ReplaceAll(msg.err.Error(), "\n", "; "), true)
 		}
 		return m, nil
 
 	case logFetchedMsg:
+		if msg.err == nil && !msg.connError {
+			m.logCacheFor(msg.stderr)[msg.jobID] = msg.content
+		}
+		if m.selectedJob == nil || msg.jobID != m.selectedJob.ID || msg.stderr != m.logStderr {
+			// Fetched ahead of time, or for a job or stream no longer shown
+			return m, nil
+		}
 		m.logLoading = false
 		if msg.err != nil {
 			m.logContent = fmt.Sprintf("Error: %v", msg.err)
 			m.logStale = false
 			m.logViewport.SetContent(m.logContent)
-		} else if m.selectedJob != nil && msg.jobID == m.selectedJob.ID {
+		} else {
 			if msg.connError {
 				// Connection error - try to show cached content
-				if cached, ok := m.logCache[msg.jobID]; ok {
+				if cached, ok := m.logCacheFor(msg.stderr)[msg.jobID]; ok {
 					m.logContent = cached
 					m.logStale = true
 				} else {
@@ -486,8 +897,6 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.logStale = false
 				}
 			} else {
-				// Successful fetch - update cache and show content
-				m.logCache[msg.jobID] = msg.content
 				m.logContent = msg.content
 				m.logStale = false
 			}
@@ -497,33 +906,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case processStatsMsg:
-		// Accept stats for the currently highlighted job (whether in log mode or not)
-		targetJob := m.getTargetJob()
-		if targetJob != nil && msg.jobID == targetJob.ID {
-			// Only update if we got valid stats (Running=true means process check succeeded)
-			// Don't overwrite good stats with failed fetches
-			if msg.stats.Running || m.processStats == nil || m.processStatsJobID != msg.jobID {
-				// Calculate CPU% from delta if we have a previous sample
-				if m.prevProcessStats != nil && m.processStatsJobID == msg.jobID &&
-					msg.stats.Timestamp > m.prevProcessStats.Timestamp && msg.stats.Running {
-					deltaTicks := (msg.stats.CPUUserTicks + msg.stats.CPUSysTicks) -
-						(m.prevProcessStats.CPUUserTicks + m.prevProcessStats.CPUSysTicks)
-					deltaTime := msg.stats.Timestamp - m.prevProcessStats.Timestamp
-					// CPU% = (ticks / (time_seconds * CLK_TCK)) * 100
-					// CLK_TCK is typically 100, so ticks/time gives rough %
-					if deltaTime > 0 {
-						msg.stats.CPUPct = float64(deltaTicks) / float64(deltaTime)
-					}
-				}
-				if msg.stats.Running {
-					m.prevProcessStats = m.processStats
-				}
-				m.processStats = msg.stats
-				m.processStatsJobID = msg.jobID
-			}
+		m.recordProcessStats(msg.stats)
+		return m, nil
+
+	case clipboardCopiedMsg:
+		return m.handleClipboardCopied(msg)
+
+	case clipboardWrittenMsg:
+		// A later copy may have replaced the sequence
+		if msg.sequence == m.clipboardSequence {
+			m.clipboardSequence = ""
 		}
 		return m, nil
 
+	case noteEditedMsg:
+		return m.handleNoteEdited(msg)
+
+	case filesListedMsg:
+		return m.handleFilesListed(msg)
+
+	case fileDownloadedMsg:
+		return m.handleFileDownloaded(msg)
+
+	case dirOpenedMsg:
+		if msg.err != nil {
+			return m, m.setFlash(fmt.Sprintf("Open directory failed: %v", msg.err), true)
+		}
+		return m, m.setFlash(fmt.Sprintf("Opened directory of job %d", msg.jobID), false)
+
 	case jobKilledMsg:
 		var flashCmd tea.Cmd
 		if msg.err != nil {
@@ -533,6 +943,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, tea.Batch(flashCmd, m.refreshJobs())
 
+	case jobPausedMsg:
+		var flashCmd tea.Cmd
+		switch {
+		case msg.err != nil && msg.paused:
+			flashCmd = m.setFlash(fmt.Sprintf("Pause failed: %v", msg.err), true)
+		case msg.err != nil:
+			flashCmd = m.setFlash(fmt.Sprintf("Resume failed: %v", msg.err), true)
+		case msg.paused:
+			flashCmd = m.setFlash(fmt.Sprintf("Job %d paused", msg.jobID), false)
+		default:
+			flashCmd = m.setFlash(fmt.Sprintf("Job %d resumed", msg.jobID), false)
+		}
+		return m, tea.Batch(flashCmd, m.refreshJobs())
+
 	case jobRestartedMsg:
 		m.restarting = false
 		m.restartingJobName = ""
@@ -559,6 +983,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, tea.Batch(flashCmd, m.refreshJobs())
 
+	case autoPrunedMsg:
+		if msg.err != nil {
+			return m, m.setFlash(fmt.Sprintf("Auto-prune failed: %v", msg.err), true)
+		}
+		if msg.count == 0 {
+			return m, nil
+		}
+		return m, tea.Batch(m.setFlash(fmt.Sprintf("Auto-pruned %d job(s) (%s)", msg.count, msg.summary), false), m.refreshJobs())
+
 	case queueStartedMsg:
 		if msg.err != nil {
 			return m, m.setFlash(fmt.Sprintf("Failed to start queue: %v", msg.err), true)
@@ -591,6 +1024,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			flashCmd = m.setFlash(fmt.Sprintf("Create failed: %v", msg.err), true)
 		} else {
 			flashCmd = m.setFlash(fmt.Sprintf("Job %d started", msg.jobID), false)
+			if msg.warning != "" {
+				flashCmd = m.setFlash(fmt.Sprintf("Job %d started; warning: %s", msg.jobID, msg.warning), true)
+			}
 			m.pendingSelectJobID = msg.jobID
 			// Keep inputs for easy re-use (user can modify and submit again)
 		}
@@ -611,15 +1047,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case logTickMsg:
 		var cmds []tea.Cmd
 		cmds = append(cmds, m.startLogTicker())
-		// Refresh logs if in Logs tab with a running job
+		// Refresh logs if in Logs tab with a running job. Process stats are
+		// sampled by the background sync instead.
 		if m.detailTab == DetailTabLogs && m.selectedJob != nil && m.selectedJob.Status == db.StatusRunning {
 			cmds = append(cmds, m.fetchSelectedJobLog())
 		}
-		// Refresh process stats for highlighted running job (even if not in log mode)
-		targetJob := m.getTargetJob()
-		if targetJob != nil && targetJob.Status == db.StatusRunning {
-			cmds = append(cmds, m.fetchProcessStats(targetJob))
-		}
 		return m, tea.Batch(cmds...)
 
 	case createTickMsg:
@@ -645,10 +1077,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 			if !found {
-				// Try to load cached host info
+				// Use cached host info, read along with the host names
 				var host *Host
-				cachedInfo, err := db.LoadCachedHostInfo(m.database, name)
-				if err == nil && cachedInfo != nil {
+				if cachedInfo := msg.cached[name]; cachedInfo != nil {
 					// Use cached info
 					host = hostFromCachedInfo(cachedInfo)
 					// Check if cache is stale (older than configured duration)
@@ -656,100 +1087,57 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if cacheAge > m.hostCacheDuration {
 						// Cache is stale, mark as checking and fetch fresh
 						host.Status = HostStatusChecking
+						host.Probing = true
 						cmds = append(cmds, m.fetchHostInfo(name))
-						cmds = append(cmds, m.fetchQueueStatus(name))
 					}
 					// If cache is fresh, we'll still show it but won't fetch unless user switches to hosts view
 				} else {
 					// No cached info, create empty host and fetch
 					host = &Host{
-						Name:   name,
-						Status: HostStatusChecking,
+						Name:    name,
+						Status:  HostStatusChecking,
+						Probing: true,
 					}
 					cmds = append(cmds, m.fetchHostInfo(name))
-					cmds = append(cmds, m.fetchQueueStatus(name))
 				}
+				host.Latency = msg.latency[name]
 				m.hosts = append(m.hosts, host)
 			}
 		}
 		if len(cmds) > 0 {
+			cmds = append(cmds, m.startHostSpinner())
 			return m, tea.Batch(cmds...)
 		}
 		return m, nil
 
 	case hostInfoMsg:
 		// Update host info
-		var cmd tea.Cmd
 		for i, h := range m.hosts {
 			if h.Name == msg.hostName {
 				msg.info.Name = msg.hostName
-				// Preserve queue status when updating host info
-				msg.info.QueueStatus = h.QueueStatus
-				msg.info.QueueRunnerActive = h.QueueRunnerActive
-				msg.info.QueuedJobCount = h.QueuedJobCount
-				msg.info.CurrentQueueJob = h.CurrentQueueJob
-				msg.info.QueueStopPending = h.QueueStopPending
-				// Preserve running jobs until new data arrives
-				msg.info.RunningJobs = h.RunningJobs
+				if msg.info.Status != HostStatusOnline {
+					// Keep the queue status and running jobs last seen
+					msg.info.QueueStatus = h.QueueStatus
+					msg.info.QueueRunnerActive = h.QueueRunnerActive
+					msg.info.QueuedJobCount = h.QueuedJobCount
+					msg.info.CurrentQueueJob = h.CurrentQueueJob
+					msg.info.QueueStopPending = h.QueueStopPending
+					msg.info.RunningJobs = h.RunningJobs
+				}
 				// Preserve LastCheck from previous state if new one is zero (offline)
 				if msg.info.LastCheck.IsZero() && !h.LastCheck.IsZero() {
 					msg.info.LastCheck = h.LastCheck
 				}
+				if msg.info.Latency == nil {
+					msg.info.Latency = h.Latency
+				}
+				msg.info.keepMissing(h)
 				m.hosts[i] = msg.info
 				break
 			}
 		}
 		// Mark host as queried this session
 		m.hostsQueriedThisSession[msg.hostName] = true
-		return m, cmd
-
-	case queueStatusMsg:
-		// Update queue status for host
-		for i, h := range m.hosts {
-			if h.Name == msg.hostName {
-				m.hosts[i].QueueStatus = QueueCheckChecked
-				m.hosts[i].QueueRunnerActive = msg.info.RunnerActive
-				m.hosts[i].QueuedJobCount = msg.info.QueuedJobCount
-				m.hosts[i].CurrentQueueJob = msg.info.CurrentJob
-				m.hosts[i].QueueStopPending = msg.info.StopPending
-				break
-			}
-		}
-		return m, nil
-
-	case hostJobsGPUMsg:
-		// Update running jobs and GPU mappings for host
-		for i, h := range m.hosts {
-			if h.Name == msg.hostName {
-				m.hosts[i].RunningJobs = msg.runningJobs
-				// Update GPU table with job info
-				for j := range m.hosts[i].GPUs {
-					m.hosts[i].GPUs[j].JobID = 0
-					m.hosts[i].GPUs[j].JobLabel = ""
-				}
-				for _, job := range msg.runningJobs {
-					for _, gpu := range job.GPUs {
-						for j := range m.hosts[i].GPUs {
-							if m.hosts[i].GPUs[j].Index == gpu.GPUIndex {
-								m.hosts[i].GPUs[j].JobID = job.ID
-								label := fmt.Sprintf("#%d", job.ID)
-								if job.Description != "" {
-									// Truncate description if too long
-									desc := job.Description
-									if len(desc) > 15 {
-										desc = desc[:12] + "..."
-									}
-									label += " " + desc
-								}
-								m.hosts[i].GPUs[j].JobLabel = label
-								break
-							}
-						}
-					}
-				}
-				break
-			}
-		}
 		return m, nil
 
 	case hostRefreshTickMsg:
@@ -757,16 +1145,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, m.startHostRefreshTicker())
 		// Only refresh hosts if in hosts view
 		if m.viewMode == ViewModeHosts {
-			for _, host := range m.hosts {
-				// Only refresh if:
-				// 1. Host hasn't been queried this session yet, OR
-				// 2. Host is online (to get updated dynamic info like load/memory)
-				if !m.hostsQueriedThisSession[host.Name] || host.Status == HostStatusOnline {
-					cmds = append(cmds, m.fetchHostInfo(host.Name))
-					cmds = append(cmds, m.fetchQueueStatus(host.Name))
-				}
+			cmds = append(cmds, m.refreshHosts())
+		}
+		return m, tea.Batch(cmds...)
+
+	case hostSpinnerTickMsg:
+		m.hostSpinnerActive = false
+		for _, host := range m.hosts {
+			if host.Probing {
+				m.hostSpinnerFrame++
+				return m, m.startHostSpinner()
 			}
 		}
+		return m, nil
+
+	case selectionSettledMsg:
+		if msg.seq != m.selectionSeq {
+			// The highlight has moved on since
+			return m, nil
+		}
+		var cmds []tea.Cmd
+		if m.detailTab == DetailTabLogs && m.selectedJob != nil {
+			cmds = append(cmds, m.fetchSelectedJobLog())
+		}
+		cmds = append(cmds, m.fetchProcessStats(m.getTargetJob()))
 		return m, tea.Batch(cmds...)
 
 	case flashExpiredMsg:
@@ -782,6 +1184,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// highlightChanged updates the log state after the highlighted job changes,
+// and schedules fetching its log, and process stats if none have been
+// sampled yet, once the highlight settles. Fetches for jobs passed over are
+// never made; logs that arrive for a job no longer highlighted are ignored.
+func (m *Model) highlightChanged() tea.Cmd {
+	if m.detailTab == DetailTabLogs && !m.headerSelected && m.selectedIndex < len(m.jobs) {
+		m.selectedJob = m.jobs[m.selectedIndex]
+		m.logLoading = true
+	}
+	m.selectionSeq++
+	seq := m.selectionSeq
+	return tea.Tick(selectionSettleDelay, func(time.Time) tea.Msg {
+		return selectionSettledMsg{seq: seq}
+	})
+}
+
 // handleMouseClick handles mouse click events
 func (m Model) handleMouseClick(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 	// Only handle left button press
@@ -790,42 +1208,42 @@ func (m Model) handleMouseClick(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 	}
 
 	// Ignore clicks when in input mode or showing overlays
-	if m.inputMode || m.showHelp || m.restarting || m.creatingJob {
+	if m.inputMode || m.editMode || m.paletteMode || m.showHelp || m.restarting || m.creatingJob {
 		return m, nil
 	}
 
 	// Calculate list panel height (same as in View)
-	listHeight := int(float64(m.height) * 0.55)
+	listHeight, _ := m.panelHeights()
 
 	// Check if click is within the list panel (top portion of screen)
-	// Account for: top border (1), header row (1), then job rows
-	// So first job row is at Y=2
-	if msg.Y >= 2 && msg.Y < listHeight-1 {
-		clickedIndex := msg.Y - 2 // Subtract border + header
+	// Account for: top border (1), header row (1), and in the jobs view the
+	// filter line (1), then the rows
+	firstRow := 2
+	if m.viewMode == ViewModeJobs {
+		firstRow = 3
+	}
+	if msg.Y >= firstRow && msg.Y <= listHeight {
+		clickedIndex := msg.Y - firstRow
 
 		if m.viewMode == ViewModeJobs {
-			if clickedIndex >= 0 && clickedIndex < len(m.jobs) {
-				m.selectedIndex = clickedIndex
-				// Clear cached process stats when changing jobs
-				m.processStats = nil
-				m.prevProcessStats = nil
-				m.processStatsJobID = 0
+			rows := m.listRows()
+			clickedIndex += m.firstListRow(rows, m.listPageSize())
+			if clickedIndex >= 0 && clickedIndex < len(rows) {
+				m.setCursorRow(rows, clickedIndex)
+				if m.headerSelected {
+					return m, nil
+				}
 				// If in Logs tab, fetch logs for new selection
 				if m.detailTab == DetailTabLogs {
 					m.selectedJob = m.jobs[m.selectedIndex]
 					m.logLoading = true
 					var cmds []tea.Cmd
 					cmds = append(cmds, m.fetchSelectedJobLog())
-					if m.selectedJob.Status == db.StatusRunning {
-						cmds = append(cmds, m.fetchProcessStats(m.selectedJob))
-					}
+					cmds = append(cmds, m.fetchProcessStats(m.selectedJob))
 					return m, tea.Batch(cmds...)
 				}
 				// Fetch stats for running jobs even if not in Logs tab
-				job := m.jobs[m.selectedIndex]
-				if job.Status == db.StatusRunning {
-					return m, m.fetchProcessStats(job)
-				}
+				return m, m.fetchProcessStats(m.jobs[m.selectedIndex])
 			}
 		} else if m.viewMode == ViewModeHosts {
 			if clickedIndex >= 0 && clickedIndex < len(m.hosts) {
@@ -846,6 +1264,16 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if m.copyPending {
+		return m.handleCopyKeyPress(msg)
+	}
+
+	if m.viewMode == ViewModeFiles {
+		if m, cmd, handled := m.handleFilesKey(msg); handled {
+			return m, cmd
+		}
+	}
+
 	// When in log view, forward scroll keys to viewport
 	if m.detailTab == DetailTabLogs {
 		switch msg.String() {
@@ -862,6 +1290,10 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	if key.Matches(msg, keys.Palette) && !m.creatingJob {
+		return m.openPalette(), nil
+	}
+
 	// Allow cancelling job creation with Escape
 	if m.creatingJob && key.Matches(msg, keys.Escape) {
 		m.creatingJob = false
@@ -869,6 +1301,18 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, m.setFlash("Job creation running in background...", false)
 	}
 
+	if m.viewMode == ViewModeLeaderboard {
+		if m, cmd, handled := m.handleLeaderboardKey(msg); handled {
+			return m, cmd
+		}
+	} else if key.Matches(msg, keys.Leaderboard) {
+		return m.showLeaderboard(), nil
+	}
+
+	if m.refusedInReadOnly(msg) {
+		return m, m.setFlash("Read-only mode: jobs can't be changed (restart without --read-only)", true)
+	}
+
 	switch {
 	case key.Matches(msg, keys.Quit):
 		return m, tea.Quit
@@ -882,14 +1326,12 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if m.detailTab == DetailTabDetails {
 				// Switch to Logs tab
 				m.detailTab = DetailTabLogs
-				if len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
+				if !m.headerSelected && len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
 					m.selectedJob = m.jobs[m.selectedIndex]
 					m.logLoading = true
 					var cmds []tea.Cmd
 					cmds = append(cmds, m.fetchSelectedJobLog())
-					if m.selectedJob.Status == db.StatusRunning {
-						cmds = append(cmds, m.fetchProcessStats(m.selectedJob))
-					}
+					cmds = append(cmds, m.fetchProcessStats(m.selectedJob))
 					return m, tea.Batch(cmds...)
 				}
 			} else {
@@ -906,16 +1348,17 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.viewMode != ViewModeHosts {
 			m.viewMode = ViewModeHosts
 			// Refresh hosts when switching to hosts view, but only if needed
-			var cmds []tea.Cmd
-			for _, host := range m.hosts {
-				// Only refresh if not queried this session or if online (for dynamic data)
-				if !m.hostsQueriedThisSession[host.Name] || host.Status == HostStatusOnline {
-					cmds = append(cmds, m.fetchHostInfo(host.Name))
-					cmds = append(cmds, m.fetchQueueStatus(host.Name))
-				}
-			}
-			return m, tea.Batch(cmds...)
+			return m, m.refreshHosts()
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.GPUPool):
+		if m.viewMode != ViewModeHosts {
+			m.viewMode = ViewModeHosts
+			m.showGPUPool = true
+			return m, m.refreshHosts()
 		}
+		m.showGPUPool = !m.showGPUPool
 		return m, nil
 
 	case key.Matches(msg, keys.JobsView):
@@ -923,15 +1366,7 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.viewMode == ViewModeJobs {
 			m.viewMode = ViewModeHosts
 			// Refresh hosts when switching to hosts view, but only if needed
-			var cmds []tea.Cmd
-			for _, host := range m.hosts {
-				// Only refresh if not queried this session or if online (for dynamic data)
-				if !m.hostsQueriedThisSession[host.Name] || host.Status == HostStatusOnline {
-					cmds = append(cmds, m.fetchHostInfo(host.Name))
-					cmds = append(cmds, m.fetchQueueStatus(host.Name))
-				}
-			}
-			return m, tea.Batch(cmds...)
+			return m, m.refreshHosts()
 		}
 		m.viewMode = ViewModeJobs
 		return m, nil
@@ -942,31 +1377,8 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.selectedHostIdx--
 			}
 		} else {
-			if m.selectedIndex > 0 {
-				m.selectedIndex--
-				// Clear cached process stats when changing jobs
-				m.processStats = nil
-				m.prevProcessStats = nil
-				m.processStatsJobID = 0
-				// If in Logs tab, fetch logs for new job
-				if m.detailTab == DetailTabLogs && len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
-					m.selectedJob = m.jobs[m.selectedIndex]
-					m.logLoading = true
-					var cmds []tea.Cmd
-					cmds = append(cmds, m.fetchSelectedJobLog())
-					// Fetch process stats for running jobs
-					if m.selectedJob.Status == db.StatusRunning {
-						cmds = append(cmds, m.fetchProcessStats(m.selectedJob))
-					}
-					return m, tea.Batch(cmds...)
-				}
-				// Even if not in Logs tab, fetch stats for running jobs
-				if len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
-					job := m.jobs[m.selectedIndex]
-					if job.Status == db.StatusRunning {
-						return m, m.fetchProcessStats(job)
-					}
-				}
+			if m.moveCursor(-1) {
+				return m, m.highlightChanged()
 			}
 		}
 		return m, nil
@@ -977,35 +1389,30 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.selectedHostIdx++
 			}
 		} else {
-			if len(m.jobs) > 0 && m.selectedIndex < len(m.jobs)-1 {
-				m.selectedIndex++
-				// Clear cached process stats when changing jobs
-				m.processStats = nil
-				m.prevProcessStats = nil
-				m.processStatsJobID = 0
-				// If in Logs tab, fetch logs for new job
-				if m.detailTab == DetailTabLogs && m.selectedIndex < len(m.jobs) {
-					m.selectedJob = m.jobs[m.selectedIndex]
-					m.logLoading = true
-					var cmds []tea.Cmd
-					cmds = append(cmds, m.fetchSelectedJobLog())
-					// Fetch process stats for running jobs
-					if m.selectedJob.Status == db.StatusRunning {
-						cmds = append(cmds, m.fetchProcessStats(m.selectedJob))
-					}
-					return m, tea.Batch(cmds...)
-				}
-				// Even if not in Logs tab, fetch stats for running jobs
-				if m.selectedIndex < len(m.jobs) {
-					job := m.jobs[m.selectedIndex]
-					if job.Status == db.StatusRunning {
-						return m, m.fetchProcessStats(job)
-					}
-				}
+			if m.moveCursor(1) {
+				return m, m.highlightChanged()
 			}
 		}
 		return m, nil
 
+	case key.Matches(msg, keys.PageUp, keys.PageDown, keys.Top, keys.Bottom):
+		if m.viewMode != ViewModeJobs {
+			return m, nil
+		}
+		delta := m.listPageSize()
+		switch {
+		case key.Matches(msg, keys.PageUp):
+			delta = -delta
+		case key.Matches(msg, keys.Top):
+			delta = -len(m.listRows())
+		case key.Matches(msg, keys.Bottom):
+			delta = len(m.listRows())
+		}
+		if m.moveCursor(delta) {
+			return m, m.highlightChanged()
+		}
+		return m, nil
+
 	case key.Matches(msg, keys.EditRestart):
 		if m.viewMode != ViewModeJobs {
 			return m, nil
@@ -1031,7 +1438,7 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if m.detailTab == DetailTabLogs {
 				// Already in logs mode - go back to details
 				m.detailTab = DetailTabDetails
-			} else if len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
+			} else if !m.headerSelected && len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
 				// Enter logs mode
 				m.detailTab = DetailTabLogs
 				m.selectedJob = m.jobs[m.selectedIndex]
@@ -1047,6 +1454,22 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case key.Matches(msg, keys.Stderr):
+		if m.viewMode != ViewModeJobs {
+			return m, nil
+		}
+		if m.detailTab != DetailTabLogs {
+			m.logStderr = true
+			return m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys.Logs.Keys()[0])})
+		}
+		m.logStderr = !m.logStderr
+		if m.selectedJob == nil {
+			return m, nil
+		}
+		m.logContent = ""
+		m.logLoading = true
+		return m, m.fetchSelectedJobLog()
+
 	case key.Matches(msg, keys.Escape):
 		m.detailTab = DetailTabDetails
 		m.selectedJob = nil
@@ -1057,11 +1480,24 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case key.Matches(msg, keys.Kill):
 		job := m.getTargetJob()
-		if job != nil && job.Status == db.StatusRunning {
+		if job != nil && (job.Status == db.StatusRunning || job.Status == db.StatusPaused) {
 			return m, tea.Batch(m.setFlash("Killing job...", false), m.killJob(job))
 		}
 		return m, nil
 
+	case key.Matches(msg, keys.Pause):
+		job := m.getTargetJob()
+		if job == nil {
+			return m, nil
+		}
+		switch job.Status {
+		case db.StatusRunning:
+			return m, tea.Batch(m.setFlash("Pausing job...", false), m.togglePause(job))
+		case db.StatusPaused:
+			return m, tea.Batch(m.setFlash("Resuming job...", false), m.togglePause(job))
+		}
+		return m, m.setFlash("Can only pause running jobs", true)
+
 	case key.Matches(msg, keys.Restart):
 		job := m.getTargetJob()
 		if job == nil {
@@ -1083,6 +1519,7 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case key.Matches(msg, keys.NewJob):
 		m.inputMode = true
+		m.inputPreview = false
 		m.inputFocus = 0
 		m.inputs[inputHost].Focus()
 		m.flashMessage = ""
@@ -1103,6 +1540,25 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.applyJobFilter()
 		return m, m.setFlash(fmt.Sprintf("Filter: %s", jobFilterDescription(m.jobFilter)), false)
 
+	case key.Matches(msg, keys.Group):
+		if m.viewMode != ViewModeJobs {
+			return m, nil
+		}
+		m.setGroupMode((m.groupMode + 1) % groupModeCount)
+		return m, m.setFlash(fmt.Sprintf("Group by: %s", groupModeDescription(m.groupMode)), false)
+
+	case key.Matches(msg, keys.Collapse):
+		if m.viewMode == ViewModeJobs {
+			m.toggleGroup()
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.CollapseAll):
+		if m.viewMode == ViewModeJobs {
+			m.toggleAllGroups()
+		}
+		return m, nil
+
 	case key.Matches(msg, keys.Prune):
 		return m, tea.Batch(m.setFlash("Pruning completed/dead jobs...", false), m.pruneJobs())
 
@@ -1120,24 +1576,122 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, m.setFlash("Can only start queued jobs", true)
 
-	case key.Matches(msg, keys.Sync):
-		if m.viewMode == ViewModeJobs && !m.syncing {
-			m.syncing = true
-			return m, tea.Batch(m.setFlash("Syncing...", false), m.performBackgroundSync())
+	case key.Matches(msg, keys.OpenDir):
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
 		}
-		return m, nil
-	}
-
-	return m, nil
-}
+		return m, tea.Batch(m.setFlash(fmt.Sprintf("Opening directory of job %d...", job.ID), false), m.openJobDir(job))
 
-func (m Model) handleInputKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.Type {
-	case tea.KeyEsc:
-		// Cancel input mode
-		m.inputMode = false
-		m.inputs[m.inputFocus].Blur()
-		return m, nil
+	case key.Matches(msg, keys.Files):
+		if m.viewMode == ViewModeHosts {
+			if m.selectedHostIdx < len(m.hosts) {
+				return m.openFileBrowser(m.hosts[m.selectedHostIdx].Name, "~")
+			}
+			return m, nil
+		}
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		return m.openFileBrowser(job.Host, job.EffectiveWorkingDir())
+
+	case key.Matches(msg, keys.Note):
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		return m, m.editNote(job)
+
+	case key.Matches(msg, keys.Ack):
+		if m.viewMode == ViewModeHosts {
+			if m.selectedHostIdx < len(m.hosts) {
+				return m.toggleHostAck(m.hosts[m.selectedHostIdx])
+			}
+			return m, nil
+		}
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		return m.toggleAck(job)
+
+	case key.Matches(msg, keys.Copy):
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		// Show the choices until a key is pressed
+		m.copyPending = true
+		m.flashMessage = copyPrompt(job)
+		m.flashIsError = false
+		m.flashExpiry = time.Time{}
+		return m, nil
+
+	case key.Matches(msg, keys.Edit):
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		tags, err := db.GetJobTags(m.database, job.ID)
+		if err != nil {
+			return m, m.setFlash(fmt.Sprintf("Error loading tags: %v", err), true)
+		}
+		m.editMode = true
+		m.editJobID = job.ID
+		m.editFocus = editDescription
+		m.editInputs[editDescription].SetValue(job.Description)
+		m.editInputs[editTags].SetValue(strings.Join(tags, ", "))
+		m.editInputs[editDescription].Focus()
+		m.editInputs[editTags].Blur()
+		m.flashMessage = ""
+		return m, nil
+
+	case key.Matches(msg, keys.Mark):
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		if m.markedJobID == job.ID {
+			m.markedJobID = 0
+			return m, m.setFlash(fmt.Sprintf("Unmarked job %d", job.ID), false)
+		}
+		m.markedJobID = job.ID
+		return m, m.setFlash(fmt.Sprintf("Marked job %d; highlight another job and press d to compare", job.ID), false)
+
+	case key.Matches(msg, keys.Diff):
+		job := m.getTargetJob()
+		if m.viewMode != ViewModeJobs || job == nil {
+			return m, nil
+		}
+		if m.markedJobID == 0 {
+			return m, m.setFlash("No job marked (press m to mark a job to compare with)", true)
+		}
+		if m.markedJobID == job.ID {
+			return m, m.setFlash("Highlight a different job to compare with the marked job", true)
+		}
+		m.detailTab = DetailTabDiff
+		m.diffJobIDs = [2]int64{m.markedJobID, job.ID}
+		return m, nil
+
+	case key.Matches(msg, keys.Sync):
+		if m.viewMode == ViewModeJobs && !m.syncing {
+			m.syncing = true
+			return m, tea.Batch(m.setFlash("Syncing...", false), m.performBackgroundSync())
+		}
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m Model) handleInputKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		// Cancel input mode
+		m.inputMode = false
+		m.inputs[m.inputFocus].Blur()
+		return m, nil
 
 	case tea.KeyTab, tea.KeyShiftTab:
 		// Cycle through inputs
@@ -1156,6 +1710,12 @@ func (m Model) handleInputKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.inputs[m.inputFocus].Focus()
 		return m, nil
 
+	case tea.KeyCtrlP:
+		// Toggle a dry-run preview of the remote invocation
+		m.inputPreview = !m.inputPreview
+		m.updateLaunchPreview()
+		return m, nil
+
 	case tea.KeyEnter:
 		// Submit if we have required fields
 		host := strings.TrimSpace(m.inputs[inputHost].Value())
@@ -1178,6 +1738,42 @@ func (m Model) handleInputKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// Forward other keys to the focused input
 	var cmd tea.Cmd
 	m.inputs[m.inputFocus], cmd = m.inputs[m.inputFocus].Update(msg)
+	m.updateLaunchPreview()
+	return m, cmd
+}
+
+// handleEditKeyPress handles keys in the edit description and tags form
+func (m Model) handleEditKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.editMode = false
+		m.editInputs[m.editFocus].Blur()
+		return m, nil
+
+	case tea.KeyTab, tea.KeyShiftTab:
+		m.editInputs[m.editFocus].Blur()
+		m.editFocus = (m.editFocus + 1) % len(m.editInputs)
+		m.editInputs[m.editFocus].Focus()
+		return m, nil
+
+	case tea.KeyEnter:
+		description := strings.TrimSpace(m.editInputs[editDescription].Value())
+		tags := strings.FieldsFunc(m.editInputs[editTags].Value(), func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		m.editMode = false
+		m.editInputs[m.editFocus].Blur()
+		if err := db.UpdateJobDescription(m.database, m.editJobID, description); err != nil {
+			return m, m.setFlash(fmt.Sprintf("Error updating job %d: %v", m.editJobID, err), true)
+		}
+		if err := db.SetJobTags(m.database, m.editJobID, tags); err != nil {
+			return m, m.setFlash(fmt.Sprintf("Error updating tags of job %d: %v", m.editJobID, err), true)
+		}
+		return m, tea.Batch(m.setFlash(fmt.Sprintf("Updated job %d", m.editJobID), false), m.refreshJobs())
+	}
+
+	var cmd tea.Cmd
+	m.editInputs[m.editFocus], cmd = m.editInputs[m.editFocus].Update(msg)
 	return m, cmd
 }
 
@@ -1186,42 +1782,56 @@ func (m Model) View() string {
 	if m.width == 0 || m.height == 0 {
 		return "Loading..."
 	}
+	if m.tooSmall() {
+		return m.renderTooSmall()
+	}
+	return m.fitToScreen(m.renderView()) + m.clipboardSequence
+}
 
+// renderView renders the current view and any overlay
+func (m Model) renderView() string {
 	// Calculate panel heights
-	listHeight := int(float64(m.height) * 0.55)
-	detailHeight := int(float64(m.height) * 0.35)
-
-	var mainView string
+	listHeight, detailHeight := m.panelHeights()
 
+	var panels []string
 	if m.viewMode == ViewModeHosts {
 		// Hosts view
-		listView := m.renderHostList(listHeight)
-		detailView := m.renderHostDetail(detailHeight)
-		flashView := m.renderFlash()
-		statusView := m.renderHostsStatusBar()
-
-		mainView = lipgloss.JoinVertical(
-			lipgloss.Left,
-			listView,
-			detailView,
-			flashView,
-			statusView,
-		)
+		if listHeight > 0 {
+			panels = append(panels, m.renderHostList(listHeight))
+		}
+		if detailHeight > 0 && m.showGPUPool {
+			panels = append(panels, m.renderGPUPool(detailHeight))
+		} else if detailHeight > 0 {
+			panels = append(panels, m.renderHostDetail(detailHeight))
+		}
+		panels = append(panels, m.renderFlash(), m.renderHostsStatusBar())
+	} else if m.viewMode == ViewModeLeaderboard {
+		if listHeight > 0 {
+			panels = append(panels, m.renderLeaderboard(listHeight))
+		}
+		if detailHeight > 0 {
+			panels = append(panels, m.renderLeaderboardDetail(detailHeight))
+		}
+		panels = append(panels, m.renderFlash(), m.renderLeaderboardStatusBar())
+	} else if m.viewMode == ViewModeFiles {
+		if listHeight > 0 {
+			panels = append(panels, m.renderFiles(listHeight))
+		}
+		if detailHeight > 0 {
+			panels = append(panels, m.renderFilesDetail(detailHeight))
+		}
+		panels = append(panels, m.renderFlash(), m.renderFilesStatusBar())
 	} else {
 		// Jobs view (default)
-		listView := m.renderJobList(listHeight)
-		logView := m.renderLogPanel(detailHeight)
-		flashView := m.renderFlash()
-		statusView := m.renderStatusBar()
-
-		mainView = lipgloss.JoinVertical(
-			lipgloss.Left,
-			listView,
-			logView,
-			flashView,
-			statusView,
-		)
+		if listHeight > 0 {
+			panels = append(panels, m.renderJobList(listHeight))
+		}
+		if detailHeight > 0 {
+			panels = append(panels, m.renderLogPanel(detailHeight))
+		}
+		panels = append(panels, m.renderFlash(), m.renderStatusBar())
 	}
+	mainView := lipgloss.JoinVertical(lipgloss.Left, panels...)
 
 	// Show help overlay
 	if m.showHelp {
@@ -1243,6 +1853,12 @@ func (m Model) View() string {
 	if m.inputMode {
 		return m.renderInputForm(mainView)
 	}
+	if m.editMode {
+		return m.renderEditForm()
+	}
+	if m.paletteMode {
+		return m.renderPalette()
+	}
 
 	return mainView
 }
@@ -1288,16 +1904,32 @@ func (m Model) renderHelpOverlay(background string) string {
 		b.WriteString("\n")
 		shortcuts := []struct{ key, desc string }{
 			{"↑/↓", "Navigate job list"},
+			{"PgUp/PgDn", "Page through job list"},
+			{"Home/End", "First/last job"},
 			{"l", "Toggle logs view"},
+			{"E", "Toggle stderr only in logs (run --split-stderr)"},
 			{"s", "Sync job statuses"},
 			{"n", "New job"},
 			{"r", "Restart job"},
 			{"R", "Edit & restart job"},
 			{"k", "Kill running job"},
+			{"p", "Pause/resume running job"},
 			{"S", "Start queue (for queued jobs)"},
+			{"e", "Edit description & tags"},
+			{"y", "Copy command, log path, ssh tail, or ID"},
+			{"o", "Open working directory in editor"},
+			{"F", "Browse working directory's files"},
+			{"N", "Edit job notes in $EDITOR"},
+			{"a", "Acknowledge/unacknowledge failed or idle-GPU job"},
+			{"m", "Mark job to compare"},
+			{"d", "Compare with marked job"},
 			{"x", "Remove job from list"},
 			{"P", "Prune completed/dead jobs"},
 			{"h / Tab", "Switch to hosts view"},
+			{"G", "Show GPU pool"},
+			{"L", "Leaderboard of job results"},
+			{"b", "Group by host, experiment, or status"},
+			{"z / Z", "Collapse/expand group / all groups"},
 			{"Esc", "Clear selection/messages"},
 		}
 		for _, s := range shortcuts {
@@ -1305,11 +1937,45 @@ func (m Model) renderHelpOverlay(background string) string {
 			b.WriteString(descStyle.Render(s.desc))
 			b.WriteString("\n")
 		}
+	} else if m.viewMode == ViewModeLeaderboard {
+		b.WriteString(titleStyle.Render("Leaderboard"))
+		b.WriteString("\n")
+		shortcuts := []struct{ key, desc string }{
+			{"↑/↓", "Navigate ranking"},
+			{"←/→", "Rank by another metric"},
+			{"a", "Reverse the order"},
+			{"Enter", "Show job in jobs view"},
+			{"L / j / Esc", "Back to jobs view"},
+		}
+		for _, s := range shortcuts {
+			b.WriteString(keyStyle.Render(s.key))
+			b.WriteString(descStyle.Render(s.desc))
+			b.WriteString("\n")
+		}
+	} else if m.viewMode == ViewModeFiles {
+		b.WriteString(titleStyle.Render("Files"))
+		b.WriteString("\n")
+		shortcuts := []struct{ key, desc string }{
+			{"↑/↓", "Navigate files"},
+			{"Enter / →", "Open directory"},
+			{"← / ⌫", "Parent directory"},
+			{"d", "Download to the current directory"},
+			{"r", "Reload"},
+			{"F / Esc", "Back"},
+		}
+		for _, s := range shortcuts {
+			b.WriteString(keyStyle.Render(s.key))
+			b.WriteString(descStyle.Render(s.desc))
+			b.WriteString("\n")
+		}
 	} else {
 		b.WriteString(titleStyle.Render("Hosts View"))
 		b.WriteString("\n")
 		shortcuts := []struct{ key, desc string }{
 			{"↑/↓", "Navigate host list"},
+			{"G", "Show/hide GPU pool"},
+			{"F", "Browse files in home directory"},
+			{"a", "Acknowledge/unacknowledge host incidents"},
 			{"j / Tab", "Switch to jobs view"},
 		}
 		for _, s := range shortcuts {
@@ -1324,6 +1990,7 @@ func (m Model) renderHelpOverlay(background string) string {
 	b.WriteString("\n")
 	generalShortcuts := []struct{ key, desc string }{
 		{"?", "Show/hide this help"},
+		{":", "Command palette (search all actions)"},
 		{"q", "Quit"},
 		{"Ctrl+Z", "Suspend (fg to resume)"},
 	}
@@ -1352,6 +2019,10 @@ func (m Model) renderInputForm(background string) string {
 		BorderForeground(lipgloss.Color("62")).
 		Padding(1, 2).
 		Width(60)
+	if m.inputPreview {
+		// The wrapper script is long; use most of the screen width
+		modalStyle = modalStyle.Width(max(60, m.width-8))
+	}
 
 	labelStyle := lipgloss.NewStyle().Width(14).Foreground(lipgloss.Color("245"))
 	focusedLabelStyle := lipgloss.NewStyle().Width(14).Foreground(lipgloss.Color("69")).Bold(true)
@@ -1370,8 +2041,13 @@ func (m Model) renderInputForm(background string) string {
 		b.WriteString("\n\n")
 	}
 
+	if m.inputPreview {
+		b.WriteString(dimStyle.Render(m.previewText))
+		b.WriteString("\n")
+	}
+
 	b.WriteString("\n")
-	helpText := "Tab: next field • Enter: create job • Esc: cancel"
+	helpText := "Tab: next field • Enter: create job • Ctrl+P: preview • Esc: cancel"
 	if m.flashIsError && m.flashMessage != "" {
 		helpText = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(m.flashMessage)
 	}
@@ -1388,45 +2064,121 @@ func (m Model) renderInputForm(background string) string {
 	)
 }
 
+// renderEditForm renders the form for editing a job's description and tags
+func (m Model) renderEditForm() string {
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(1, 2).
+		Width(60)
+
+	labelStyle := lipgloss.NewStyle().Width(14).Foreground(lipgloss.Color("245"))
+	focusedLabelStyle := lipgloss.NewStyle().Width(14).Foreground(lipgloss.Color("69")).Bold(true)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Edit Job %d\n\n", m.editJobID))
+
+	labels := []string{"Description:", "Tags:"}
+	for i, input := range m.editInputs {
+		label := labelStyle
+		if i == m.editFocus {
+			label = focusedLabelStyle
+		}
+		b.WriteString(label.Render(labels[i]))
+		b.WriteString(input.View())
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("Tab: next field • Enter: save • Esc: cancel"))
+
+	return lipgloss.Place(
+		m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		modalStyle.Render(b.String()),
+		lipgloss.WithWhitespaceChars(" "),
+		lipgloss.WithWhitespaceForeground(lipgloss.Color("237")),
+	)
+}
+
 func (m Model) renderJobList(height int) string {
 	var rows []string
 
-	// Header
-	header := fmt.Sprintf(" %-4s %-10s %-12s %-12s %s",
-		"ID", "HOST", "STATUS", "STARTED", "COMMAND / DESCRIPTION")
-	rows = append(rows, headerStyle.Render(header))
+	// Header. Narrow terminals leave out the host and start time, and rows
+	// are cut to the panel's width rather than wrapped.
+	narrow := m.narrow()
+	rowWidth := m.width - 4
+	header := fmt.Sprintf(" %-4s %-10s %-12s %-12s %-8s %s",
+		"ID", "HOST", "STATUS", "STARTED", "DURATION", "COMMAND / DESCRIPTION")
+	if narrow {
+		header = fmt.Sprintf(" %-4s %-12s %-8s %s", "ID", "STATUS", "DURATION", "COMMAND")
+	}
+	rows = append(rows, headerStyle.Render(fitWidth(header, rowWidth)))
+	listRows := m.listRows()
+	contentHeight := height - 5 // Account for borders, header, and filter line
+	cursor := m.cursorRow(listRows)
+	first := m.firstListRow(listRows, contentHeight)
 	filterLabel := fmt.Sprintf(" Filter: %s (press f to cycle)", jobFilterDescription(m.jobFilter))
-	rows = append(rows, dimStyle.Render(filterLabel))
+	if m.groupMode != groupNone {
+		filterLabel += fmt.Sprintf(", grouped by %s (b)", groupModeDescription(m.groupMode))
+	}
+	if len(listRows) > contentHeight {
+		filterLabel += fmt.Sprintf("  [%d-%d of %d]", first+1, min(first+contentHeight, len(listRows)), len(listRows))
+	}
+	rows = append(rows, dimStyle.Render(fitWidth(filterLabel, rowWidth)))
 
-	if len(m.jobs) == 0 {
+	if len(listRows) == 0 && !m.jobsLoaded {
+		rows = append(rows, dimStyle.Render(" Loading jobs..."))
+		content := strings.Join(rows, "\n")
+		return listPanelStyle.Width(m.width - 2).Height(height).Render(content)
+	}
+	if len(listRows) == 0 {
 		rows = append(rows, dimStyle.Render(" No jobs match this filter"))
 		content := strings.Join(rows, "\n")
 		return listPanelStyle.Width(m.width - 2).Height(height).Render(content)
 	}
 
-	// Jobs
-	contentHeight := height - 5 // Account for borders, header, and filter line
-	for i, job := range m.jobs {
-		if i >= contentHeight {
-			break
+	// Only the rows that fit are rendered
+	for i := first; i < len(listRows) && i < first+contentHeight; i++ {
+		row := listRows[i]
+		if row.group != nil {
+			line := fitWidth(m.groupHeader(row.group), rowWidth)
+			if i == cursor {
+				line = selectedStyle.Width(m.width - 4).Render(line)
+			} else {
+				line = headerStyle.Render(line)
+			}
+			rows = append(rows, line)
+			continue
 		}
 
+		job := row.job
 		status := m.formatStatus(job)
-		started := formatStartTime(job.StartTime)
+		started := m.formatTime(job, job.StartTime)
 
 		// Show description if available, otherwise truncated command
 		display := job.Description
 		if display == "" {
-			display = job.EffectiveCommand()
+			display = m.redactor.String(job.EffectiveCommand())
 		}
 		display = truncate(display, 40)
 
-		line := fmt.Sprintf(" %-4d %-10s %-12s %-12s %s",
-			job.ID, truncate(job.Host, 10),
-			status, started, display)
+		marker := " "
+		if job.ID == m.markedJobID {
+			marker = "*"
+		}
+		line := fmt.Sprintf("%s%-4d %-10s %-12s %-12s %-8s %s",
+			marker, job.ID, truncate(job.Host, 10),
+			status, started, m.formatJobDuration(job), display)
+		if narrow {
+			line = fmt.Sprintf("%s%-4d %-12s %-8s %s", marker, job.ID, status, m.formatJobDuration(job), display)
+		}
+		line = fitWidth(line, rowWidth)
 
-		if i == m.selectedIndex {
+		if i == cursor {
 			line = selectedStyle.Width(m.width - 4).Render(line)
+		} else if m.acked(job) {
+			line = dimStyle.Render(line)
 		} else {
 			line = m.styleForStatus(job.Status).Render(line)
 		}
@@ -1438,10 +2190,26 @@ func (m Model) renderJobList(height int) string {
 	return listPanelStyle.Width(m.width - 2).Height(height).Render(content)
 }
 
+// formatJobDuration returns the job's run time, or for queued jobs how long
+// they have been waiting
+func (m Model) formatJobDuration(job *db.Job) string {
+	now := time.Now().Unix()
+	if job.Status == db.StatusQueued {
+		if wait := m.jobStats[job.ID].QueueWait(job, now); wait >= 0 {
+			return "+" + humanfmt.DurationShort(wait)
+		}
+		return "—"
+	}
+	return humanfmt.DurationShort(job.Elapsed(now))
+}
+
 func (m Model) renderLogPanel(height int) string {
 	// Render based on active tab
-	if m.detailTab == DetailTabLogs {
+	switch m.detailTab {
+	case DetailTabLogs:
 		return m.renderLogsOnly(height)
+	case DetailTabDiff:
+		return m.renderJobDiff(height)
 	}
 	return m.renderJobDetails(height)
 }
@@ -1450,6 +2218,9 @@ func (m Model) renderLogPanel(height int) string {
 func (m Model) renderTabHeader() string {
 	detailsLabel := "Details"
 	logsLabel := "Logs"
+	if m.logStderr {
+		logsLabel = "Logs (stderr)"
+	}
 
 	if m.detailTab == DetailTabDetails {
 		detailsLabel = headerStyle.Render(detailsLabel)
@@ -1459,7 +2230,51 @@ func (m Model) renderTabHeader() string {
 		logsLabel = headerStyle.Render(logsLabel)
 	}
 
-	return detailsLabel + "  " + logsLabel
+	header := detailsLabel + "  " + logsLabel
+	if m.detailTab == DetailTabDiff {
+		header += "  " + headerStyle.Render("Diff")
+	}
+	return header
+}
+
+// renderJobDiff compares the marked job with the job highlighted when d was
+// pressed, marking the rows that differ
+func (m Model) renderJobDiff(height int) string {
+	var runs [2]jobdiff.Run
+	for i, id := range m.diffJobIDs {
+		job, _ := db.GetJobByID(m.database, id)
+		if job == nil {
+			content := m.renderTabHeader() + "\n" + dimStyle.Render(fmt.Sprintf("Job %d no longer exists", id))
+			return logPanelStyle.Width(m.width - 2).Height(height).Render(content)
+		}
+		env, _ := db.GetJobEnv(m.database, job)
+		names, _ := db.GetJobSecrets(m.database, id)
+		env = append(env, secrets.RedactEnv(names)...)
+		git, _ := db.GetJobGit(m.database, id)
+		runs[i] = jobdiff.Run{Job: job, Env: env, Git: git}
+	}
+
+	rows := jobdiff.Compare(runs[0], runs[1], time.Now().Unix())
+	nameWidth := 0
+	for _, row := range rows {
+		nameWidth = max(nameWidth, len(row.Name))
+	}
+	valueWidth := max((m.width-nameWidth-14)/2, 10)
+
+	lines := []string{m.renderTabHeader(), dimStyle.Render(fmt.Sprintf("%-*s  %-*s  %s",
+		nameWidth+2, "", valueWidth, fmt.Sprintf("Job %d (marked)", runs[0].Job.ID), fmt.Sprintf("Job %d", runs[1].Job.ID)))}
+	for _, row := range rows {
+		line := fmt.Sprintf("%-*s  %-*s  %s", nameWidth+2, "  "+row.Name,
+			valueWidth, truncate(row.A, valueWidth), truncate(row.B, valueWidth))
+		if row.Differs() {
+			line = headerStyle.Render("*" + line[1:])
+		}
+		lines = append(lines, line)
+	}
+	if maxLines := height - 2; len(lines) > maxLines && maxLines > 0 {
+		lines = lines[:maxLines]
+	}
+	return logPanelStyle.Width(m.width - 2).Height(height).Render(strings.Join(lines, "\n"))
 }
 
 func (m Model) renderLogsOnly(height int) string {
@@ -1483,19 +2298,28 @@ func (m Model) renderLogsOnly(height int) string {
 		viewportHeight -= 1 // Make room for stale indicator
 	}
 
+	// While a fresh log loads, show the last one fetched, if any
+	logContent, refreshing := m.logContent, false
 	if m.logLoading {
+		logContent, refreshing = m.logCacheFor(m.logStderr)[job.ID], true
+	}
+
+	if logContent == "" && m.logLoading {
 		content = dimStyle.Render("Loading logs...")
-	} else if m.logContent == "" {
+	} else if logContent == "" {
 		content = dimStyle.Render("No log content available")
 	} else {
 		// Create viewport with correct dimensions and content for rendering
 		vp := m.logViewport
 		vp.Width = viewportWidth
 		vp.Height = viewportHeight
-		vp.SetContent(m.logContent)
+		vp.SetContent(logContent)
+		if refreshing {
+			vp.GotoBottom()
+		}
 
 		// Use viewport for scrollable content
-		if m.logStale {
+		if m.logStale || refreshing {
 			// Use slightly dimmer style for stale content
 			staleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
 			content = staleStyle.Render(vp.View())
@@ -1507,6 +2331,8 @@ func (m Model) renderLogsOnly(height int) string {
 	jobInfo := fmt.Sprintf("Job %d on %s", job.ID, job.Host)
 	if m.logStale {
 		staleIndicator = lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Render(" (cached - host offline)")
+	} else if refreshing && logContent != "" {
+		staleIndicator = dimStyle.Render(" (refreshing...)")
 	}
 
 	// Show scroll position if there's more content
@@ -1526,47 +2352,112 @@ func (m Model) renderJobDetails(height int) string {
 
 	highlightedJob := m.getTargetJob()
 
-	if highlightedJob == nil {
+	if g := m.highlightedGroup(); m.headerSelected && g != nil && highlightedJob == nil {
+		content = m.renderGroupDetails(g)
+	} else if highlightedJob == nil {
 		content = dimStyle.Render("No jobs to display")
 	} else {
 		job := highlightedJob
 		header = fmt.Sprintf("Job %d on %s\n", job.ID, job.Host)
 
 		// Show Cmd and Dir first (most useful info)
-		header += fmt.Sprintf("Cmd:     %s\n", job.EffectiveCommand())
+		header += fmt.Sprintf("Cmd:     %s\n", m.redactor.String(job.EffectiveCommand()))
 		header += fmt.Sprintf("Dir:     %s\n", job.EffectiveWorkingDir())
 
+		if rev, _ := db.GetJobGit(m.database, job.ID); rev != nil {
+			header += fmt.Sprintf("Git:     %s\n", rev)
+		}
+
 		// Show environment variables if any
-		envVars := job.ParseExportVars()
+		envVars, _ := db.GetJobEnv(m.database, job)
+		names, _ := db.GetJobSecrets(m.database, job.ID)
+		envVars = append(envVars, secrets.RedactEnv(names)...)
 		if len(envVars) > 0 {
 			header += fmt.Sprintf("Env:     %s\n", strings.Join(envVars, ", "))
 		}
+		if tags, _ := db.GetJobTags(m.database, job.ID); len(tags) > 0 {
+			header += fmt.Sprintf("Tags:    %s\n", strings.Join(tags, ", "))
+		}
+		if experiment, _ := db.GetJobExperiment(m.database, job.ID); experiment != "" {
+			header += fmt.Sprintf("Experiment: %s\n", experiment)
+		}
+		if gpus, _ := db.GetJobGPUs(m.database, job.ID); len(gpus) > 0 {
+			header += fmt.Sprintf("GPUs:    %s\n", gpupool.FormatIndices(gpus))
+		}
 
 		// Then timing information
 		if job.StartTime > 0 {
 			startTime := time.Unix(job.StartTime, 0)
-			header += fmt.Sprintf("Started: %s (%s)\n", startTime.Format("2006-01-02 15:04:05"), formatStartTime(job.StartTime))
+			header += fmt.Sprintf("Started: %s\n", m.formatFullTime(job, job.StartTime))
 
 			// Show timing information based on job status
 			if job.Status == db.StatusRunning {
 				elapsed := time.Since(startTime)
-				header += fmt.Sprintf("Elapsed: %s (running)\n", formatDuration(elapsed))
+				header += fmt.Sprintf("Elapsed: %s (running)\n", humanfmt.Duration(int64(elapsed.Seconds())))
+				if stats := m.jobStats[job.ID]; stats.IdleGPU() {
+					idle := time.Since(time.Unix(stats.GPUIdleSince, 0))
+					header += fmt.Sprintf("Warning: GPUs idle for %s\n", humanfmt.Duration(int64(idle.Seconds())))
+				}
+			} else if job.Status == db.StatusPaused {
+				elapsed := time.Since(startTime)
+				header += fmt.Sprintf("Elapsed: %s (paused)\n", humanfmt.Duration(int64(elapsed.Seconds())))
+				if pause := m.jobPauses[job.ID]; pause != nil {
+					line := fmt.Sprintf("Paused:  %s", m.formatFullTime(job, pause.PausedAt))
+					if pause.PreemptedBy != 0 {
+						line += fmt.Sprintf(" (preempted by job %d)", pause.PreemptedBy)
+					}
+					header += line + "\n"
+				}
 			} else if job.EndTime != nil {
 				endTime := time.Unix(*job.EndTime, 0)
 				duration := endTime.Sub(startTime)
-				header += fmt.Sprintf("Ended:   %s (%s)\n", endTime.Format("2006-01-02 15:04:05"), formatStartTime(*job.EndTime))
-				header += fmt.Sprintf("Duration: %s\n", formatDuration(duration))
+				header += fmt.Sprintf("Ended:   %s\n", m.formatFullTime(job, *job.EndTime))
+				header += fmt.Sprintf("Duration: %s\n", humanfmt.Duration(int64(duration.Seconds())))
 			}
 		} else if job.EndTime != nil {
 			// Job ended without ever starting (failed/killed before start)
-			endTime := time.Unix(*job.EndTime, 0)
-			header += fmt.Sprintf("Ended:   %s (%s)\n", endTime.Format("2006-01-02 15:04:05"), formatStartTime(*job.EndTime))
+			header += fmt.Sprintf("Ended:   %s\n", m.formatFullTime(job, *job.EndTime))
+		}
+
+		// Time spent waiting in a queue
+		if wait := m.jobStats[job.ID].QueueWait(job, time.Now().Unix()); wait >= 0 {
+			if job.Status == db.StatusQueued {
+				header += fmt.Sprintf("Waiting: %s (queued)\n", humanfmt.Duration(wait))
+			} else {
+				header += fmt.Sprintf("Waited:  %s in queue\n", humanfmt.Duration(wait))
+			}
+		}
+
+		if _, acked := m.ackState(job); acked {
+			at := m.jobStats[job.ID].AckedAt
+			header += fmt.Sprintf("Acked:   %s (%s)\n", m.times.Full(at, nil), humanfmt.Relative(time.Since(time.Unix(at, 0))))
+		}
+
+		// What a job queued with --after is waiting for
+		switch job.Status {
+		case db.StatusQueued:
+			if dep, _ := db.GetJobDependency(m.database, job.ID); dep != nil {
+				upstream, _ := db.GetJobByID(m.database, dep.AfterJobID)
+				header += fmt.Sprintf("After:   %s\n", dep.Describe(upstream))
+			}
+		case db.StatusPending:
+			if dep, _ := db.GetPendingDependency(m.database, job.ID); dep != nil {
+				upstream, _ := db.GetJobByID(m.database, dep.AfterJobID)
+				header += fmt.Sprintf("After:   %s (held locally until it finishes)\n", dep.Describe(upstream))
+			}
+		}
+		if job.Status == db.StatusQueued || job.Status == db.StatusPending {
+			if guard, _ := db.GetJobGuard(m.database, job.ID); guard != nil {
+				header += fmt.Sprintf("If:      %s\n", guard)
+			}
 		}
 
 		// Show exit status if available
 		if job.Status == db.StatusCompleted && job.ExitCode != nil {
 			if *job.ExitCode == 0 {
 				header += "Exit:    0 (success)\n"
+			} else if job.Skipped() {
+				header += "Exit:    skipped (its --if condition was false)\n"
 			} else {
 				header += fmt.Sprintf("Exit:    %d (failed)\n", *job.ExitCode)
 			}
@@ -1579,38 +2470,69 @@ func (m Model) renderJobDetails(height int) string {
 			}
 		}
 
+		// Output files declared with --artifact
+		if globs, resolved, _ := db.GetArtifactGlobs(m.database, job.ID); globs != nil {
+			if !resolved {
+				header += fmt.Sprintf("Artifacts: %s\n", strings.Join(globs, ", "))
+			} else if found, _ := db.GetArtifacts(m.database, job.ID); len(found) > 0 {
+				names := make([]string, len(found))
+				for i, a := range found {
+					names[i] = fmt.Sprintf("%s (%s)", a.Name, humanfmt.Bytes(a.Size))
+				}
+				header += fmt.Sprintf("Artifacts: %s\n", strings.Join(names, ", "))
+			} else {
+				header += "Artifacts: none matched\n"
+			}
+		}
+
+		// Metrics declared with --result and --results-file
+		if spec, resolved, _ := db.GetResultSpec(m.database, job.ID); spec != nil {
+			if !resolved {
+				header += "Results: read when the job finishes\n"
+			} else if metrics, _ := db.GetJobResults(m.database, job.ID); len(metrics) > 0 {
+				header += fmt.Sprintf("Results: %s\n", results.Format(metrics))
+			} else {
+				header += "Results: none found\n"
+			}
+		}
+
+		// Notes attached with N or the note command
+		if note, _ := db.GetJobNote(m.database, job.ID); note != nil {
+			header += "\nNotes:\n" + renderNote(m.redactor.String(note.Text))
+		}
+
 		// Show process stats for running jobs (show whatever stats we have for this job)
-		if job.Status == db.StatusRunning && m.processStats != nil && m.processStatsJobID == job.ID {
+		if stats := m.processStats[job.ID]; job.Status == db.StatusRunning && stats != nil {
 			header += "\n"
 			header += "Process Stats:\n"
 
 			// CPU: show % if available, plus user/sys time
-			if m.processStats.CPUUser != "" || m.processStats.CPUSys != "" {
+			if stats.CPUUser != "" || stats.CPUSys != "" {
 				cpuLine := "  CPU:     "
-				if m.processStats.CPUPct > 0 {
-					cpuLine += fmt.Sprintf("%.0f%% ", m.processStats.CPUPct)
+				if stats.CPUPct > 0 {
+					cpuLine += fmt.Sprintf("%.0f%% ", stats.CPUPct)
 				}
-				cpuLine += fmt.Sprintf("(%s user, %s sys)\n", m.processStats.CPUUser, m.processStats.CPUSys)
+				cpuLine += fmt.Sprintf("(%s user, %s sys)\n", stats.CPUUser, stats.CPUSys)
 				header += cpuLine
 			}
 
 			// Memory
-			if m.processStats.MemoryRSS != "" {
-				mem := m.processStats.MemoryRSS
-				if m.processStats.MemoryPct != "" {
-					mem += " (" + m.processStats.MemoryPct + ")"
+			if stats.MemoryRSS != "" {
+				mem := stats.MemoryRSS
+				if stats.MemoryPct != "" {
+					mem += " (" + stats.MemoryPct + ")"
 				}
 				header += fmt.Sprintf("  Memory:  %s\n", mem)
 			}
 
 			// Threads
-			if m.processStats.Threads > 0 {
-				header += fmt.Sprintf("  Threads: %d\n", m.processStats.Threads)
+			if stats.Threads > 0 {
+				header += fmt.Sprintf("  Threads: %d\n", stats.Threads)
 			}
 
 			// GPUs with utilization and memory
-			if len(m.processStats.GPUs) > 0 {
-				for _, gpu := range m.processStats.GPUs {
+			if len(stats.GPUs) > 0 {
+				for _, gpu := range stats.GPUs {
 					gpuLine := fmt.Sprintf("  GPU %d:   ", gpu.Index)
 					if gpu.Utilization > 0 {
 						gpuLine += fmt.Sprintf("%d%% util, ", gpu.Utilization)
@@ -1631,81 +2553,6 @@ func (m Model) renderJobDetails(height int) string {
 	return logPanelStyle.Width(m.width - 2).Height(height).Render(panelContent)
 }
 
-// parseMiB extracts a MiB value from various memory string formats
-// Handles: "123MiB", "80GiB", "16G", "128Gi", "58.5G", etc.
-func parseMiB(mem string) int {
-	mem = strings.TrimSpace(mem)
-
-	// Try MiB suffix first
-	if strings.HasSuffix(mem, "MiB") {
-		numStr := strings.TrimSuffix(mem, "MiB")
-		if mib, err := strconv.Atoi(strings.TrimSpace(numStr)); err == nil {
-			return mib
-		}
-	}
-
-	// Try GiB suffix (convert to MiB)
-	if strings.HasSuffix(mem, "GiB") {
-		numStr := strings.TrimSuffix(mem, "GiB")
-		if gib, err := strconv.ParseFloat(strings.TrimSpace(numStr), 64); err == nil {
-			return int(gib * 1024)
-		}
-	}
-
-	// Try Gi suffix (convert to MiB)
-	if strings.HasSuffix(mem, "Gi") {
-		numStr := strings.TrimSuffix(mem, "Gi")
-		if gib, err := strconv.ParseFloat(strings.TrimSpace(numStr), 64); err == nil {
-			return int(gib * 1024)
-		}
-	}
-
-	// Try G suffix (treat as GB, convert to MiB approximately)
-	if strings.HasSuffix(mem, "G") {
-		numStr := strings.TrimSuffix(mem, "G")
-		if gb, err := strconv.ParseFloat(strings.TrimSpace(numStr), 64); err == nil {
-			return int(gb * 1024) // Approximate GB as GiB for simplicity
-		}
-	}
-
-	return 0
-}
-
-// formatGPUMem formats GPU memory, converting large MiB values to GiB
-func formatGPUMem(mem string) string {
-	mem = strings.TrimSpace(mem)
-	// Try to parse as MiB
-	if strings.HasSuffix(mem, "MiB") {
-		numStr := strings.TrimSuffix(mem, "MiB")
-		if mib, err := strconv.Atoi(strings.TrimSpace(numStr)); err == nil {
-			if mib >= 1024 {
-				gib := float64(mib) / 1024.0
-				return fmt.Sprintf("%.1fGiB", gib)
-			}
-			return fmt.Sprintf("%dMiB", mib)
-		}
-	}
-	return mem
-}
-
-// formatDuration formats a duration in a human-readable form
-func formatDuration(d time.Duration) string {
-	d = d.Truncate(time.Second)
-	h := d / time.Hour
-	d -= h * time.Hour
-	m := d / time.Minute
-	d -= m * time.Minute
-	s := d / time.Second
-
-	if h > 0 {
-		return fmt.Sprintf("%dh %dm %ds", h, m, s)
-	}
-	if m > 0 {
-		return fmt.Sprintf("%dm %ds", m, s)
-	}
-	return fmt.Sprintf("%ds", s)
-}
-
 func (m Model) renderFlash() string {
 	if m.flashMessage == "" {
 		return ""
@@ -1730,11 +2577,17 @@ func (m Model) renderFlash() string {
 }
 
 func (m Model) renderStatusBar() string {
-	help := helpStyle.Render("?:help q:quit ↑/↓:nav l:logs f:filter s:sync n:new r:restart k:kill P:prune h:hosts")
+	help := m.statusHelp("?:help ::commands q:quit ↑/↓:nav l:logs f:filter s:sync n:new r:restart k:kill y:copy P:prune h:hosts")
+	if m.readOnly {
+		help = syncingStyle.Render("READ-ONLY ") + m.statusHelp(readOnlyStatusHelp)
+	}
 
 	if m.syncing {
 		help = syncingStyle.Render("⟳ ") + help
 	}
+	if m.jobsFromSnapshot {
+		help = syncingStyle.Render("(jobs as of last run) ") + help
+	}
 
 	// Right-align the help text
 	gap := m.width - lipgloss.Width(help) - 2
@@ -1749,9 +2602,9 @@ func (m Model) renderHostList(height int) string {
 	var rows []string
 
 	// Header
-	header := fmt.Sprintf(" %-12s %-10s %-6s %-16s %-5s %-5s",
-		"HOST", "STATUS", "QUEUE", "ARCH", "CPU", "RAM")
-	rows = append(rows, headerStyle.Render(header))
+	header := fmt.Sprintf(" %-12s %-10s %-6s %-16s %-5s %-5s %-5s %-6s",
+		"HOST", "STATUS", "QUEUE", "ARCH", "CPU", "RAM", "DISK", "PING")
+	rows = append(rows, headerStyle.Render(fitWidth(header, m.width-4)))
 
 	if len(m.hosts) == 0 {
 		rows = append(rows, dimStyle.Render(" No hosts found. Run a job first."))
@@ -1771,9 +2624,14 @@ func (m Model) renderHostList(height int) string {
 			}
 			cpu := host.CPUUtilization()
 			ram := host.RAMUtilization()
+			disk := host.DiskUtilization()
 
-			line := fmt.Sprintf(" %-12s %-10s %-6s %-16s %-5s %-5s",
-				truncate(host.Name, 12), status, queue, arch, cpu, ram)
+			line := fmt.Sprintf(" %-12s %-10s %-6s %-16s %-5s %-5s %-5s %-6s",
+				truncate(host.Name, 12), status, queue, arch, cpu, ram, disk, host.LatencySummary())
+			if note := availabilityNote(m.hostAvailability(host.Name), time.Now()); note != "" {
+				line += "  " + note
+			}
+			line = fitWidth(line, m.width-4)
 
 			if i == m.selectedHostIdx {
 				line = selectedStyle.Width(m.width - 4).Render(line)
@@ -1803,9 +2661,23 @@ func (m Model) renderHostDetail(height int) string {
 			statusLine += fmt.Sprintf(" (%s)", host.Error)
 		}
 		lines = append(lines, statusLine)
+		if host.Status == HostStatusOffline && host.Failures > 0 {
+			lines = append(lines, fmt.Sprintf("Next attempt: %s (%d failed attempt(s))", nextAttempt(host.RetryAt, time.Now()), host.Failures))
+		}
+		if l := host.Latency; l != nil {
+			lines = append(lines, fmt.Sprintf("Latency: %s average over %d round trip(s), last %s",
+				humanfmt.Latency(l.Average), l.Samples, humanfmt.Latency(l.Last)))
+		}
+		if schedule := m.hostAvailability(host.Name); !schedule.IsZero() {
+			availLine := fmt.Sprintf("Availability: %s", schedule)
+			if note := availabilityNote(schedule, time.Now()); note != "" {
+				availLine += " — " + note
+			}
+			lines = append(lines, availLine)
+		}
 
 		// Show static info (cached) regardless of online status
-		hasStaticInfo := host.Model != "" || host.Arch != "" || host.OS != "" || host.CPUModel != "" || host.CPUs > 0 || len(host.GPUs) > 0
+		hasStaticInfo := host.Model != "" || host.Arch != "" || host.OS != "" || host.CPUModel != "" || host.CPUs > 0 || len(host.GPUs) > 0 || host.Tools != nil
 		if hasStaticInfo {
 			lines = append(lines, "───────────────────────────────────────────────────────────────")
 			if host.Model != "" {
@@ -1862,13 +2734,13 @@ func (m Model) renderHostDetail(height int) string {
 						}
 						mem := "-"
 						if gpu.MemUsed != "" && gpu.MemTotal != "" {
-							usedMiB := parseMiB(gpu.MemUsed)
-							totalMiB := parseMiB(gpu.MemTotal)
+							usedMiB := humanfmt.ParseMiB(gpu.MemUsed)
+							totalMiB := humanfmt.ParseMiB(gpu.MemTotal)
 							if totalMiB > 0 {
 								pct := (usedMiB * 100) / totalMiB
-								mem = fmt.Sprintf("%s / %s (%d%%)", formatGPUMem(gpu.MemUsed), formatGPUMem(gpu.MemTotal), pct)
+								mem = fmt.Sprintf("%s / %s (%d%%)", humanfmt.MiB(usedMiB), humanfmt.MiB(totalMiB), pct)
 							} else {
-								mem = fmt.Sprintf("%s / %s", formatGPUMem(gpu.MemUsed), formatGPUMem(gpu.MemTotal))
+								mem = fmt.Sprintf("%s / %s", gpu.MemUsed, gpu.MemTotal)
 							}
 						}
 						lines = append(lines, fmt.Sprintf("%2d   %5s   %5s   %s", gpu.Index, temp, util, mem))
@@ -1881,11 +2753,11 @@ func (m Model) renderHostDetail(height int) string {
 				memInfo := host.MemTotal
 				if host.MemUsed != "" {
 					// Calculate utilization percentage
-					usedMiB := parseMiB(host.MemUsed)
-					totalMiB := parseMiB(host.MemTotal)
+					usedMiB := humanfmt.ParseMiB(host.MemUsed)
+					totalMiB := humanfmt.ParseMiB(host.MemTotal)
 					if totalMiB > 0 {
 						pct := (usedMiB * 100) / totalMiB
-						memInfo = fmt.Sprintf("%s used / %s total (%d%%)", host.MemUsed, host.MemTotal, pct)
+						memInfo = fmt.Sprintf("%s used / %s total (%d%%)", humanfmt.MiB(usedMiB), humanfmt.MiB(totalMiB), pct)
 					} else {
 						memInfo = fmt.Sprintf("%s used / %s total", host.MemUsed, host.MemTotal)
 					}
@@ -1913,6 +2785,44 @@ func (m Model) renderHostDetail(height int) string {
 					lines = append(lines, fmt.Sprintf("Load:         %s", host.LoadAvg))
 				}
 			}
+			if host.Tools != nil {
+				lines = append(lines, fmt.Sprintf("Tools:        %s", host.Tools))
+			}
+		}
+
+		// Disk space on the filesystems jobs write to
+		if len(host.Disks) > 0 {
+			lines = append(lines, "")
+			lines = append(lines, "Disk")
+			for _, d := range host.Disks {
+				line := "  " + d.String()
+				if err := d.Check(host.Name, host.DiskLimits.MinFreeDiskGB, host.DiskLimits.MinFreeInodesPct); err != nil || d.Low() {
+					line = errorStyle.Render("⚠ " + d.String())
+				}
+				lines = append(lines, line)
+			}
+		}
+
+		// Recent incidents that may explain dead jobs. Acknowledged ones
+		// are dimmed, and only warned about if newer ones came after them.
+		if len(host.Events) > 0 {
+			lines = append(lines, "")
+			if unacked := host.UnackedEvents(); len(unacked) > 0 {
+				lines = append(lines, errorStyle.Render(fmt.Sprintf("⚠ Last 24h: %s", hostevents.Summary(unacked))))
+			} else {
+				lines = append(lines, dimStyle.Render(fmt.Sprintf("Last 24h (acknowledged): %s", hostevents.Summary(host.Events))))
+			}
+			for i, e := range host.Events {
+				if i == 3 {
+					lines = append(lines, dimStyle.Render(fmt.Sprintf("  … run 'remote-jobs host events %s' for all", host.Name)))
+					break
+				}
+				line := fmt.Sprintf("  %s  %s", time.Unix(e.Time, 0).Format("01/02 15:04"), truncate(e.Message, 60))
+				if e.Time <= host.EventsAckedAt {
+					line = dimStyle.Render(line)
+				}
+				lines = append(lines, line)
+			}
 		}
 
 		// Queue status section
@@ -1942,8 +2852,7 @@ func (m Model) renderHostDetail(height int) string {
 	if len(m.hosts) > 0 && m.selectedHostIdx < len(m.hosts) {
 		host := m.hosts[m.selectedHostIdx]
 		if !host.LastCheck.IsZero() {
-			elapsed := time.Since(host.LastCheck).Truncate(time.Second)
-			footerText = fmt.Sprintf("Last online: %s ago", elapsed)
+			footerText = "Last online: " + humanfmt.Relative(time.Since(host.LastCheck))
 		}
 	}
 
@@ -1973,8 +2882,87 @@ func (m Model) renderHostDetail(height int) string {
 	return logPanelStyle.Width(m.width - 2).Height(height).Render(panelContent)
 }
 
+// renderGPUPool renders the GPUs of all hosts, most free memory first
+func (m Model) renderGPUPool(height int) string {
+	gpus := gpuPool(m.hosts)
+
+	labels := make(map[int64]string)
+	for _, host := range m.hosts {
+		for _, job := range host.RunningJobs {
+			labels[job.ID] = strings.TrimSpace(fmt.Sprintf("#%d %s", job.ID, job.Description))
+		}
+	}
+
+	var lines []string
+	anyCached := false
+	if len(gpus) == 0 {
+		lines = append(lines, dimStyle.Render("No GPUs on known hosts"))
+	} else {
+		lines = append(lines, gpupool.Summary(gpus))
+		lines = append(lines, fmt.Sprintf(" %-13s %3s  %-8s  %5s  %-17s  %8s  %s", "HOST", "GPU", "STATE", "UTIL", "MEM", "FREE", "JOB"))
+		for _, g := range gpus {
+			host := g.Host
+			if g.Cached {
+				host += "*"
+				anyCached = true
+			}
+			util := "-"
+			if g.Utilization >= 0 {
+				util = fmt.Sprintf("%d%%", g.Utilization)
+			}
+			mem, free := "-", "-"
+			if g.MemTotalMiB > 0 {
+				mem = humanfmt.MiB(g.MemUsedMiB) + "/" + humanfmt.MiB(g.MemTotalMiB)
+				free = humanfmt.MiB(g.FreeMiB())
+			}
+			job := ""
+			if g.JobID != 0 {
+				job = labels[g.JobID]
+				if job == "" {
+					job = fmt.Sprintf("#%d", g.JobID)
+				}
+			}
+			line := fmt.Sprintf(" %-13s %3d  %-8s  %5s  %-17s  %8s  %s",
+				truncate(host, 13), g.Index, g.State, util, mem, free, truncate(job, 30))
+			switch g.State {
+			case gpupool.StateFree:
+				line = completedStyle.Render(line)
+			case gpupool.StateBusy:
+				line = dimStyle.Render(line)
+			case gpupool.StateReserved:
+				line = pendingStyle.Render(line)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	footerText := ""
+	if anyCached {
+		footerText = "* host offline; last known state"
+	}
+
+	// Calculate available lines: height - borders(2) - title(1) - footer(1 if present)
+	footerLines := 0
+	if footerText != "" {
+		footerLines = 1
+	}
+	availableLines := height - 4 - footerLines
+	if len(lines) > availableLines && availableLines > 0 {
+		lines = lines[:availableLines]
+	}
+	for len(lines) < availableLines {
+		lines = append(lines, "")
+	}
+
+	panelContent := titleStyle.Render("GPU Pool") + "\n" + strings.Join(lines, "\n")
+	if footerText != "" {
+		panelContent = panelContent + "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(footerText)
+	}
+	return logPanelStyle.Width(m.width - 2).Height(height).Render(panelContent)
+}
+
 func (m Model) renderHostsStatusBar() string {
-	help := helpStyle.Render("?:help q:quit ↑/↓:nav R:refresh j:jobs tab:switch")
+	help := m.statusHelp("?:help q:quit ↑/↓:nav R:refresh G:GPU pool F:files j:jobs tab:switch")
 
 	// Right-align the help text
 	gap := m.width - lipgloss.Width(help) - 2
@@ -1985,7 +2973,26 @@ func (m Model) renderHostsStatusBar() string {
 	return " " + strings.Repeat(" ", gap) + help
 }
 
+// applyReachability updates hosts' backoff state from the reachability cache
+func (m *Model) applyReachability(reach map[string]*db.HostReachability) {
+	if reach == nil {
+		return
+	}
+	for _, host := range m.hosts {
+		r := reach[host.Name]
+		if r == nil || r.Reachable {
+			host.Failures, host.RetryAt = 0, time.Time{}
+			continue
+		}
+		host.Failures = r.Failures
+		host.RetryAt = time.Unix(r.RetryAt, 0)
+	}
+}
+
 func (m Model) formatHostStatus(host *Host) string {
+	if host.Probing {
+		return hostSpinnerFrames[m.hostSpinnerFrame%len(hostSpinnerFrames)] + " " + host.StatusString()
+	}
 	switch host.Status {
 	case HostStatusOnline:
 		return "● online"
@@ -2014,6 +3021,9 @@ func (m Model) styleForHostStatus(status HostStatus) lipgloss.Style {
 func (m Model) formatStatus(job *db.Job) string {
 	switch job.Status {
 	case db.StatusRunning:
+		if m.jobStats[job.ID].IdleGPU() {
+			return "● running ⚠"
+		}
 		return "● running"
 	case db.StatusCompleted:
 		if job.ExitCode == nil {
@@ -2022,6 +3032,9 @@ func (m Model) formatStatus(job *db.Job) string {
 		if *job.ExitCode == 0 {
 			return "✓ done"
 		}
+		if job.Skipped() {
+			return "» skipped"
+		}
 		return fmt.Sprintf("✗ exit %d", *job.ExitCode)
 	case db.StatusDead:
 		return "✗ dead"
@@ -2033,12 +3046,14 @@ func (m Model) formatStatus(job *db.Job) string {
 		return "✗ failed"
 	case db.StatusStarting:
 		return "◐ starting"
+	case db.StatusPaused:
+		return "‖ paused"
 	default:
-		return job.Status
+		return string(job.Status)
 	}
 }
 
-func (m Model) styleForStatus(status string) lipgloss.Style {
+func (m Model) styleForStatus(status db.Status) lipgloss.Style {
 	switch status {
 	case db.StatusRunning:
 		return runningStyle
@@ -2054,6 +3069,8 @@ func (m Model) styleForStatus(status string) lipgloss.Style {
 		return failedStyle
 	case db.StatusStarting:
 		return pendingStyle
+	case db.StatusPaused:
+		return pausedStyle
 	default:
 		return lipgloss.NewStyle()
 	}
@@ -2092,10 +3109,71 @@ func (m Model) startCreateTicker() tea.Cmd {
 	})
 }
 
+// refreshJobs reads the job list the first time, and after that only the
+// jobs that changed since the last refresh
 func (m Model) refreshJobs() tea.Cmd {
+	loaded, since, listed := m.jobsLoaded, m.jobsRevision, m.allJobs
 	return func() tea.Msg {
-		jobs, err := db.ListJobs(m.database, "", "", 100)
-		return jobsRefreshedMsg{jobs: jobs, err: err}
+		if !loaded {
+			return loadJobs(m.database)
+		}
+		changes, err := db.ListJobChanges(m.database, since)
+		if err != nil {
+			return jobsRefreshedMsg{err: err}
+		}
+		// Changes can't fill the places of removed jobs in a full list with
+		// the older jobs that ListJobs would now include, so it's read again
+		if len(changes.Removed) > 0 && len(listed) >= jobListLimit {
+			return loadJobs(m.database)
+		}
+		// Stats change with each sample, so they're read for every listed job
+		ids := make([]int64, 0, len(listed)+len(changes.Changed))
+		for _, job := range listed {
+			ids = append(ids, job.ID)
+		}
+		for _, job := range changes.Changed {
+			ids = append(ids, job.ID)
+		}
+		stats, _ := db.LoadJobStats(m.database, ids)
+		pauses, _ := db.ListJobPauses(m.database)
+		experiments, _ := db.JobExperiments(m.database, changes.IDs())
+		return jobsRefreshedMsg{changes: changes, revision: changes.Revision, stats: stats, pauses: pauses, experiments: experiments}
+	}
+}
+
+// loadJobs reads the most recent jobs, with their stats, pauses and experiments
+func loadJobs(database *sql.DB) jobsRefreshedMsg {
+	// Read the revision first, so that changes made meanwhile are read again
+	revision, err := db.JobsRevision(database)
+	if err != nil {
+		return jobsRefreshedMsg{err: err}
+	}
+	jobs, err := db.ListJobs(database, "", "", jobListLimit)
+	if err != nil {
+		return jobsRefreshedMsg{err: err}
+	}
+	ids := make([]int64, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	// Stats are optional; the list still renders without them
+	stats, _ := db.LoadJobStats(database, ids)
+	pauses, _ := db.ListJobPauses(database)
+	experiments, _ := db.JobExperiments(database, ids)
+	return jobsRefreshedMsg{jobs: jobs, revision: revision, stats: stats, pauses: pauses, experiments: experiments}
+}
+
+// applyJobChanges updates the loaded jobs and their experiments with changes
+func (m *Model) applyJobChanges(changes *db.JobChanges, experiments map[int64]string) {
+	m.allJobs = changes.Apply(m.allJobs, jobListLimit)
+	if m.jobExperiments == nil {
+		m.jobExperiments = make(map[int64]string)
+	}
+	for _, id := range changes.IDs() {
+		delete(m.jobExperiments, id)
+	}
+	for id, name := range experiments {
+		m.jobExperiments[id] = name
 	}
 }
 
@@ -2104,6 +3182,8 @@ func (m *Model) applyJobFilter() {
 	if len(m.jobs) > 0 && m.selectedIndex >= 0 && m.selectedIndex < len(m.jobs) {
 		prevSelectedID = m.jobs[m.selectedIndex].ID
 	}
+	// Where the highlighted row is on the screen, to keep it there
+	screenRow := m.cursorRow(m.listRows()) - m.listOffset
 
 	var filtered []*db.Job
 	for _, job := range m.allJobs {
@@ -2111,6 +3191,11 @@ func (m *Model) applyJobFilter() {
 			filtered = append(filtered, job)
 		}
 	}
+	if m.groupMode != groupNone {
+		m.rows, filtered = layoutJobList(filtered, m.groupKey, m.collapsedGroups)
+	} else {
+		m.rows = jobRows(filtered)
+	}
 	m.jobs = filtered
 
 	if len(m.jobs) == 0 {
@@ -2133,6 +3218,25 @@ func (m *Model) applyJobFilter() {
 		}
 	}
 
+	// Keep the highlight on a group's header if the group is still listed,
+	// and move it to the first header if every group is collapsed
+	if m.headerSelected || (len(m.jobs) == 0 && len(m.rows) > 0) {
+		m.headerSelected = false
+		for _, row := range m.rows {
+			if row.group != nil && row.group.Name == m.selectedGroup {
+				m.headerSelected = true
+			}
+		}
+		if !m.headerSelected && len(m.jobs) == 0 && len(m.rows) > 0 {
+			m.headerSelected, m.selectedGroup = true, m.rows[0].group.Name
+		}
+	}
+
+	// Jobs added above the highlighted row push the list down rather than
+	// moving the highlight
+	m.listOffset = m.cursorRow(m.rows) - screenRow
+	m.scrollToCursor()
+
 	if m.selectedJob != nil && !jobMatchesFilter(m.selectedJob, m.jobFilter) {
 		m.detailTab = DetailTabDetails
 		m.selectedJob = nil
@@ -2168,8 +3272,10 @@ func (m Model) loadHosts() tea.Cmd {
 		for _, h := range jobHosts {
 			hostSet[h] = true
 		}
+		cached := make(map[string]*db.CachedHostInfo, len(cachedHosts))
 		for _, h := range cachedHosts {
 			hostSet[h.Name] = true
+			cached[h.Name] = h
 		}
 
 		// Convert to sorted slice
@@ -2179,22 +3285,105 @@ func (m Model) loadHosts() tea.Cmd {
 		}
 		sort.Strings(hosts)
 
-		return hostsLoadedMsg{hostNames: hosts, err: nil}
+		// Recorded latencies also stretch the timeouts of syncs on slow hosts
+		latency := reachability.LoadLatencies(database)
+
+		return hostsLoadedMsg{hostNames: hosts, cached: cached, latency: latency, err: nil}
+	}
+}
+
+// refreshHosts queries the hosts that need it: those not queried this
+// session, online hosts (for dynamic info like load and memory), and offline
+// hosts whose backoff has passed. Queries run concurrently, up to
+// maxHostProbes at a time, and each host's row updates as its result arrives.
+func (m *Model) refreshHosts() tea.Cmd {
+	now := time.Now()
+	var cmds []tea.Cmd
+	for _, host := range m.hosts {
+		retry := host.Status == HostStatusOffline && !host.RetryAt.IsZero() && now.After(host.RetryAt)
+		needed := !m.hostsQueriedThisSession[host.Name] || host.Status == HostStatusOnline || retry
+		if host.Probing || !needed {
+			continue
+		}
+		host.Probing = true
+		cmds = append(cmds, m.fetchHostInfo(host.Name))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	return tea.Batch(append(cmds, m.startHostSpinner())...)
+}
+
+// startHostSpinner animates the status of hosts being queried, unless the
+// animation is already running
+func (m *Model) startHostSpinner() tea.Cmd {
+	if m.hostSpinnerActive {
+		return nil
 	}
+	m.hostSpinnerActive = true
+	return tea.Tick(hostSpinnerInterval, func(time.Time) tea.Msg {
+		return hostSpinnerTickMsg{}
+	})
+}
+
+// acquireHostProbe waits for one of the maxHostProbes slots for a host query,
+// and returns a function that releases it
+func acquireHostProbe(slots chan struct{}) func() {
+	slots <- struct{}{}
+	return func() { <-slots }
 }
 
 func (m Model) fetchHostInfo(hostName string) tea.Cmd {
 	database := m.database
+	slots := m.hostProbeSlots
 	return func() tea.Msg {
+		defer acquireHostProbe(slots)()
+
 		host := &Host{
 			Name:   hostName,
 			Status: HostStatusChecking,
 		}
 
-		// Use short timeout to avoid blocking UI
-		stdout, stderr, err := ssh.RunWithTimeout(hostName, HostInfoCommand, 10*time.Second)
+		// Disk usage of remote-jobs' own files and of active jobs' working directories
+		diskPaths := []string{diskspace.CacheDir, "~"}
+		if jobs, err := db.ListActiveJobs(database, hostName); err == nil {
+			for _, job := range jobs {
+				if job.WorkingDir != "" {
+					diskPaths = append(diskPaths, job.WorkingDir)
+				}
+			}
+		}
+
+		// One SSH call gathers the host's inventory, load, queue status,
+		// running jobs, and disk usage. Use short timeout to avoid blocking UI
+		stdout, stderr, err := ssh.RunScript(hostName, "host-info", scripts.HostInfoScript,
+			hostInfoArgs("default", diskPaths...), ssh.HostTimeouts(hostName).Probe)
+
+		// A query that timed out partway still heard from the host: show
+		// what arrived rather than calling the host offline
+		if errors.Is(err, ssh.ErrTimeout) {
+			if partial, running, ok := parsePartialHostInfo(stdout, diskPaths...); ok {
+				reachability.Record(database, hostName, nil, time.Now())
+				partial.Name = hostName
+				partial.Error = err.Error() + "; showing the info received"
+				partial.Disks = diskspace.Distinct(partial.Disks)
+				partial.DiskLimits = loadHostLimits(hostName)
+				if partial.received["jobs"] {
+					partial.setRunningJobs(hostRunningJobs(database, running))
+				}
+				partial.Latency, _ = reachability.MeasureLatency(database, hostName, time.Now())
+				partial.Events, partial.EventsAckedAt = loadHostEvents(database, hostName, time.Now().Add(-hostEventWindow))
+				return hostInfoMsg{hostName: hostName, info: partial}
+			}
+		}
+
+		reachability.Record(database, hostName, err, time.Now())
 		if err != nil {
 			host.Status = HostStatusOffline
+			if r, _ := db.GetHostReachability(database, hostName); r != nil && !r.Reachable {
+				host.Failures = r.Failures
+				host.RetryAt = time.Unix(r.RetryAt, 0)
+			}
 			host.Error = strings.TrimSpace(stderr)
 			if host.Error == "" {
 				host.Error = err.Error()
@@ -2211,87 +3400,76 @@ func (m Model) fetchHostInfo(hostName string) tea.Cmd {
 				host.CPUFreq = cachedHost.CPUFreq
 				host.MemTotal = cachedHost.MemTotal
 				host.GPUs = cachedHost.GPUs
+				host.Tools = cachedHost.Tools
 				// Preserve LastCheck from cache (last successful connection)
 				host.LastCheck = cachedHost.LastCheck
 			}
+			host.Events, host.EventsAckedAt = loadHostEvents(database, hostName, time.Now().Add(-hostEventWindow))
 			return hostInfoMsg{hostName: hostName, info: host}
 		}
 
 		// Parse the output
-		host = ParseHostInfo(stdout)
+		host, running, err := parseHostInfo(stdout, diskPaths...)
+		if err != nil {
+			return hostInfoMsg{hostName: hostName, info: &Host{
+				Name:      hostName,
+				Status:    HostStatusOnline,
+				LastCheck: time.Now(),
+				Error:     err.Error(),
+			}}
+		}
 		host.Name = hostName
+		host.Disks = diskspace.Distinct(host.Disks)
+		host.DiskLimits = loadHostLimits(hostName)
+		host.setRunningJobs(hostRunningJobs(database, running))
 
 		// Save to cache (ignore errors - caching is best effort)
 		cachedInfo := cachedInfoFromHost(host)
 		db.SaveCachedHostInfo(database, cachedInfo)
 
-		return hostInfoMsg{hostName: hostName, info: host}
-	}
-}
+		// Time a round trip, for the timeouts of later commands on the host
+		host.Latency, _ = reachability.MeasureLatency(database, hostName, time.Now())
 
-func (m Model) fetchQueueStatus(hostName string) tea.Cmd {
-	return func() tea.Msg {
-		// Use short timeout to avoid blocking UI
-		stdout, _, err := ssh.RunWithTimeout(hostName, QueueStatusCommand("default"), 5*time.Second)
-		if err != nil {
-			// On error, return empty status (will show as "-")
-			return queueStatusMsg{hostName: hostName, info: &QueueStatusInfo{}}
+		// Check for recent host-level incidents (best effort)
+		now := time.Now()
+		since := now.Add(-hostEventWindow)
+		if out, _, err := ssh.RunWithTimeout(hostName, hostevents.Command(since), ssh.HostTimeouts(hostName).Probe); err == nil {
+			db.SaveHostEvents(database, hostevents.Parse(hostName, out, since, now))
 		}
+		host.Events, host.EventsAckedAt = loadHostEvents(database, hostName, since)
 
-		// Parse the output
-		info := ParseQueueStatus(stdout)
-		return queueStatusMsg{hostName: hostName, info: info}
+		return hostInfoMsg{hostName: hostName, info: host}
 	}
 }
 
-func (m Model) fetchHostJobsGPU(hostName string) tea.Cmd {
-	database := m.database
-	return func() tea.Msg {
-		// Get running jobs on this host from database
-		jobs, err := db.GetRunningJobsByHost(database, hostName)
-		if err != nil || len(jobs) == 0 {
-			return hostJobsGPUMsg{hostName: hostName, runningJobs: nil}
-		}
-
-		// Build list of job PID info
-		var jobPIDInfos []ssh.JobPIDInfo
-		for _, job := range jobs {
-			pidFile := session.JobPidFile(job.ID, job.StartTime)
-			jobPIDInfos = append(jobPIDInfos, ssh.JobPIDInfo{
-				JobID:   job.ID,
-				PIDFile: pidFile,
-			})
-		}
-
-		// Get GPU mappings via SSH script
-		mappings, err := ssh.GetJobGPUMappings(hostName, scripts.GPUJobMappingScript, jobPIDInfos)
-		if err != nil {
-			// Don't fail - just return jobs without GPU info
-			mappings = nil
-		}
-
-		// Build mapping from job ID to GPU usage
-		gpuByJob := make(map[int64][]JobGPUUsage)
-		for _, m := range mappings {
-			gpuByJob[m.JobID] = append(gpuByJob[m.JobID], JobGPUUsage{
-				GPUIndex: m.GPUIndex,
-				MemUsed:  fmt.Sprintf("%d", m.MemMiB),
-			})
-		}
-
-		// Build running jobs list with GPU info
-		var runningJobs []HostRunningJob
-		for _, job := range jobs {
-			runningJobs = append(runningJobs, HostRunningJob{
-				ID:          job.ID,
-				Description: job.Description,
-				Command:     job.Command,
-				GPUs:        gpuByJob[job.ID],
+// hostRunningJobs describes the jobs the host-info script found running,
+// from the job database; jobs it has no record of are left out
+func hostRunningJobs(database *sql.DB, running []hostInfoJob) []HostRunningJob {
+	var jobs []HostRunningJob
+	for _, r := range running {
+		job, err := db.GetJobByID(database, r.ID)
+		if err != nil || job == nil {
+			continue
+		}
+		var reserved []int
+		if envVars, err := db.GetJobEnv(database, job); err == nil {
+			reserved = gpupool.VisibleDevices(envVars)
+		}
+		hostJob := HostRunningJob{
+			ID:          job.ID,
+			Description: job.Description,
+			Command:     job.Command,
+			Reserved:    reserved,
+		}
+		for _, gpu := range r.GPUs {
+			hostJob.GPUs = append(hostJob.GPUs, JobGPUUsage{
+				GPUIndex: gpu.Index,
+				MemUsed:  fmt.Sprintf("%d", gpu.MemMiB),
 			})
 		}
-
-		return hostJobsGPUMsg{hostName: hostName, runningJobs: runningJobs}
+		jobs = append(jobs, hostJob)
 	}
+	return jobs
 }
 
 // getTargetJob returns the job to act on - either the selected job or the highlighted job
@@ -2299,7 +3477,7 @@ func (m Model) getTargetJob() *db.Job {
 	if m.detailTab == DetailTabLogs && m.selectedJob != nil {
 		return m.selectedJob
 	}
-	if len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
+	if !m.headerSelected && len(m.jobs) > 0 && m.selectedIndex < len(m.jobs) {
 		return m.jobs[m.selectedIndex]
 	}
 	return nil
@@ -2308,14 +3486,14 @@ func (m Model) getTargetJob() *db.Job {
 func jobMatchesFilter(job *db.Job, mode jobFilterMode) bool {
 	switch mode {
 	case jobFilterActive:
-		return job.Status == db.StatusRunning || job.Status == db.StatusStarting || job.Status == db.StatusQueued
+		return job.Status == db.StatusRunning || job.Status == db.StatusStarting || job.Status == db.StatusQueued || job.Status == db.StatusPaused
 	case jobFilterSucceeded:
 		return job.Status == db.StatusCompleted && job.ExitCode != nil && *job.ExitCode == 0
 	case jobFilterFailed:
 		if job.Status == db.StatusFailed || job.Status == db.StatusDead {
 			return true
 		}
-		return job.Status == db.StatusCompleted && (job.ExitCode == nil || *job.ExitCode != 0)
+		return job.Status == db.StatusCompleted && (job.ExitCode == nil || *job.ExitCode != 0) && !job.Skipped()
 	default:
 		return true
 	}
@@ -2338,10 +3516,41 @@ func (m Model) fetchSelectedJobLog() tea.Cmd {
 	if m.selectedJob == nil {
 		return nil
 	}
+	return m.fetchJobLog(m.selectedJob)
+}
 
-	job := m.selectedJob
+// logCacheFor returns the cache of the jobs' logs, or of their stderr files
+func (m *Model) logCacheFor(stderr bool) map[int64]string {
+	if !stderr {
+		return m.logCache
+	}
+	if m.stderrCache == nil {
+		m.stderrCache = make(map[int64]string)
+	}
+	return m.stderrCache
+}
+
+// fetchJobLog fetches the end of a job's log, or of its stderr file if the
+// logs tab shows only stderr
+func (m Model) fetchJobLog(job *db.Job) tea.Cmd {
+	database := m.database
+	redactor := m.redactor
+	stderrOnly := m.logStderr
 	return func() tea.Msg {
 		var logFile string
+		if stderrOnly && job.SessionName != "" {
+			return logFetchedMsg{jobID: job.ID, stderr: true, content: "This job was started by an older version, which didn't capture stderr separately"}
+		}
+		notFound := func() logFetchedMsg {
+			msg := "No log file yet"
+			switch {
+			case stderrOnly:
+				msg = "No stderr file (start the job with run --split-stderr to capture it separately)"
+			case job.Status == db.StatusCompleted || job.Status == db.StatusFailed || job.Status == db.StatusDead:
+				msg = "Log file not found (may have been cleaned up)"
+			}
+			return logFetchedMsg{jobID: job.ID, stderr: stderrOnly, content: msg}
+		}
 
 		// For jobs without a session name (queued jobs, or jobs started by queue runner),
 		// we need to find the log file by pattern since the timestamp may differ
@@ -2349,7 +3558,7 @@ func (m Model) fetchSelectedJobLog() tea.Cmd {
 			// Try to find log file by pattern
 			pattern := session.LogFilePattern(job.ID)
 			findCmd := fmt.Sprintf("ls -t %s 2>/dev/null | head -1", pattern)
-			stdout, _, err := ssh.Run(job.Host, findCmd)
+			stdout, _, err := ssh.Run(jobHost(job), findCmd)
 			if err == nil && strings.TrimSpace(stdout) != "" {
 				logFile = strings.TrimSpace(stdout)
 			} else {
@@ -2359,68 +3568,115 @@ func (m Model) fetchSelectedJobLog() tea.Cmd {
 		} else {
 			logFile = session.JobLogFile(job.ID, job.StartTime, job.SessionName)
 		}
+		if stderrOnly {
+			// The stderr file is named like the log
+			logFile = strings.TrimSuffix(logFile, ".log") + ".err"
+		}
 
 		// Fetch the log content
 		// Don't quote path - it contains ~ which needs shell expansion
-		stdout, stderr, err := ssh.Run(job.Host, fmt.Sprintf("tail -500 %s 2>&1", logFile))
+		stdout, stderr, err := ssh.Run(jobHost(job), fmt.Sprintf("tail -500 %s 2>&1", logFile))
 		if err != nil {
 			// Check if it's a connection error
 			combined := stdout + stderr
 			if ssh.IsConnectionError(combined) {
 				return logFetchedMsg{
 					jobID:     job.ID,
+					stderr:    stderrOnly,
 					content:   fmt.Sprintf("Host %s unreachable", job.Host),
 					connError: true,
 				}
 			}
 			// Check if log file doesn't exist
 			if strings.Contains(combined, "No such file") || strings.Contains(combined, "cannot open") {
-				msg := "No log file yet"
-				if job.Status == db.StatusCompleted || job.Status == db.StatusFailed || job.Status == db.StatusDead {
-					msg = "Log file not found (may have been cleaned up)"
-				}
-				return logFetchedMsg{
-					jobID:   job.ID,
-					content: msg,
-				}
+				return notFound()
 			}
 			// Other SSH error
 			return logFetchedMsg{
 				jobID:   job.ID,
+				stderr:  stderrOnly,
 				content: fmt.Sprintf("Error: %s", strings.TrimSpace(combined)),
 			}
 		}
 		// Check if output indicates file not found (for cases where tail doesn't error)
 		if strings.Contains(stdout, "No such file") || strings.Contains(stdout, "cannot open") {
-			msg := "No log file yet"
-			if job.Status == db.StatusCompleted || job.Status == db.StatusFailed || job.Status == db.StatusDead {
-				msg = "Log file not found (may have been cleaned up)"
-			}
-			return logFetchedMsg{
-				jobID:   job.ID,
-				content: msg,
-			}
+			return notFound()
+		}
+		// Hide the values of the job's secrets if it printed them, and
+		// anything the redact patterns match
+		if names, _ := db.GetJobSecrets(database, job.ID); len(names) > 0 {
+			stdout = secrets.Redact(stdout, secrets.Values(names))
 		}
+		stdout = redactor.String(stdout)
 		return logFetchedMsg{
 			jobID:   job.ID,
+			stderr:  stderrOnly,
 			content: stdout,
 		}
 	}
 }
 
+// fetchProcessStats samples the running jobs on a job's host if job is running
+// and has no sample yet; otherwise the background sync keeps its stats fresh
 func (m Model) fetchProcessStats(job *db.Job) tea.Cmd {
 	if job == nil || job.Status != db.StatusRunning {
 		return nil
 	}
+	if _, ok := m.processStats[job.ID]; ok {
+		return nil
+	}
 
+	database := m.database
 	return func() tea.Msg {
-		pidFile := session.JobPidFile(job.ID, job.StartTime)
-		stats, _ := ssh.GetProcessStats(job.Host, pidFile)
-		return processStatsMsg{
-			jobID: job.ID,
-			stats: stats,
+		stats, _ := sampleProcessStats(database, job.Host)
+		return processStatsMsg{stats: stats}
+	}
+}
+
+// sampleProcessStats samples the processes of all the running jobs on a host
+// in a single SSH call, and remembers each job's latest usage so `list` can
+// show it
+func sampleProcessStats(database *sql.DB, host string) (map[int64]*ssh.ProcessStats, error) {
+	jobs, err := db.ListRunning(database, host)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	infos := make([]ssh.JobPIDInfo, len(jobs))
+	for i, job := range jobs {
+		infos[i] = ssh.JobPIDInfo{JobID: job.ID, PIDFile: session.JobPidFile(job.ID, job.StartTime)}
+	}
+	stats, err := ssh.GetJobsProcessStats(host, infos)
+	if err != nil {
+		return nil, err
+	}
+	for id, s := range stats {
+		if s.Running {
+			db.SaveJobResources(database, id, s.MemoryRSS, s.GPUMemorySummary())
 		}
 	}
+	return stats, nil
+}
+
+// recordProcessStats adds samples to the process stats cache, computing each
+// job's CPU% from the change since its previous sample. A failed sample
+// doesn't replace a good one.
+func (m *Model) recordProcessStats(samples map[int64]*ssh.ProcessStats) {
+	for id, stats := range samples {
+		prev := m.processStats[id]
+		if prev != nil && prev.Running {
+			if !stats.Running {
+				continue
+			}
+			if deltaTime := stats.Timestamp - prev.Timestamp; deltaTime > 0 {
+				deltaTicks := (stats.CPUUserTicks + stats.CPUSysTicks) -
+					(prev.CPUUserTicks + prev.CPUSysTicks)
+				// CPU% = (ticks / (time_seconds * CLK_TCK)) * 100
+				// CLK_TCK is typically 100, so ticks/time gives rough %
+				stats.CPUPct = float64(deltaTicks) / float64(deltaTime)
+			}
+		}
+		m.processStats[id] = stats
+	}
 }
 
 func (m Model) performBackgroundSync() tea.Cmd {
@@ -2433,6 +3689,16 @@ func (m Model) performBackgroundSync() tea.Cmd {
 			return syncCompletedMsg{err: err}
 		}
 
+		// Hosts that failed recently are skipped, and a host that fails
+		// now is skipped for the rest of this sync
+		reach := newHostReachability(m.database)
+		processStats := make(map[int64]*ssh.ProcessStats)
+
+		// Jobs checked without error that looked dead before but not now
+		// are alive after all
+		started := time.Now().Unix()
+		var checked []int64
+
 		for _, host := range hosts {
 			jobs, err := db.ListRunning(m.database, host)
 			if err != nil {
@@ -2440,21 +3706,60 @@ func (m Model) performBackgroundSync() tea.Cmd {
 			}
 
 			for _, job := range jobs {
+				if reach.skip(job.Host) {
+					break
+				}
 				changed, err := syncJobQuick(m.database, job)
+				reach.record(job.Host, err)
+				if err != nil {
+					continue
+				}
+				checked = append(checked, job.ID)
+				if changed {
+					updated++
+				}
+			}
+
+			// Sample the jobs still running, all in one SSH call
+			if reach.skip(host) {
+				continue
+			}
+			stats, err := sampleProcessStats(m.database, host)
+			reach.record(host, err)
+			for id, s := range stats {
+				processStats[id] = s
+			}
+		}
+
+		// Sync paused jobs (they may have been resumed, finished, or died)
+		pausedJobs, err := db.ListPaused(m.database)
+		if err == nil {
+			for _, job := range pausedJobs {
+				if reach.skip(job.Host) {
+					continue
+				}
+				changed, err := jobstate.SyncPaused(m.database, job, "tui", ssh.HostTimeouts(job.Host).Sync)
+				reach.record(job.Host, err)
 				if err != nil {
 					continue
 				}
+				checked = append(checked, job.ID)
 				if changed {
 					updated++
 				}
 			}
 		}
+		db.ClearDeadProbes(m.database, started, checked)
 
 		// Sync queued jobs (check if they've started or completed)
 		queuedJobs, err := db.ListAllQueued(m.database)
 		if err == nil {
 			for _, job := range queuedJobs {
+				if reach.skip(job.Host) {
+					continue
+				}
 				changed, err := syncQueuedJob(m.database, job)
+				reach.record(job.Host, err)
 				if err != nil {
 					continue
 				}
@@ -2470,6 +3775,9 @@ func (m Model) performBackgroundSync() tea.Cmd {
 		deadJobs, err := db.ListRecentDeadQueueJobs(m.database, oneHourAgo)
 		if err == nil {
 			for _, job := range deadJobs {
+				if reach.skip(job.Host) {
+					continue
+				}
 				revived, err := checkAndReviveDeadJob(m.database, job)
 				if err != nil {
 					continue
@@ -2480,7 +3788,113 @@ func (m Model) performBackgroundSync() tea.Cmd {
 			}
 		}
 
-		return syncCompletedMsg{updated: updated}
+		// Record the results of jobs that just finished
+		var resultErrors []string
+		for _, host := range hosts {
+			if reach.skip(host) {
+				continue
+			}
+			if err := jobstate.ResolveResults(m.database, host); err != nil {
+				resultErrors = append(resultErrors, strings.ReplaceAll(err.Error(), "\n", "; "))
+			}
+		}
+
+		// Check for running jobs whose GPUs have gone idle
+		var idleAlerts []string
+		if m.watchdog.Enabled() {
+			idleAlerts = m.runWatchdog(hosts)
+		}
+
+		reach.save()
+
+		if cfg, err := config.Load(); err == nil {
+			database, r := m.database, m.redactor
+			background.Go("webhooks", func() { webhooks.DeliverPending(database, cfg.Webhooks, r, io.Discard) })
+			background.Go("slack", func() { notify.DeliverPending(database, cfg.SlackNotifiers(), r, io.Discard) })
+		}
+
+		hostReach, _ := db.ListHostReachability(m.database)
+
+		return syncCompletedMsg{updated: updated, idleAlerts: idleAlerts, reachability: hostReach, processStats: processStats, resultErrors: resultErrors}
+	}
+}
+
+// runHooks runs the local completion hooks of the jobs that have finished.
+// Their output would corrupt the display, so only failures are reported.
+func (m Model) runHooks() tea.Cmd {
+	database := m.database
+	return func() tea.Msg {
+		_, err := hooks.RunPending(database, io.Discard)
+		return hooksRanMsg{err: err}
+	}
+}
+
+// hostReachability tracks which hosts answered during one background sync,
+// on top of the shared reachability cache
+type hostReachability struct {
+	database *sql.DB
+	now      time.Time
+	skipped  map[string]bool // Hosts in backoff, or that failed during this sync
+	reached  map[string]bool
+}
+
+func newHostReachability(database *sql.DB) *hostReachability {
+	return &hostReachability{
+		database: database,
+		now:      time.Now(),
+		skipped:  make(map[string]bool),
+		reached:  make(map[string]bool),
+	}
+}
+
+// skip reports whether host should not be tried during this sync
+func (r *hostReachability) skip(host string) bool {
+	if _, ok := r.skipped[host]; !ok {
+		r.skipped[host] = reachability.Skip(r.database, host, r.now)
+	}
+	return r.skipped[host]
+}
+
+// record notes the result of an SSH attempt on host. A connection failure is
+// saved immediately; successes are saved once per host by save.
+func (r *hostReachability) record(host string, err error) {
+	if reachability.Failed(err) {
+		r.skipped[host] = true
+		delete(r.reached, host)
+		reachability.Record(r.database, host, err, time.Now())
+		return
+	}
+	if !r.skipped[host] {
+		r.reached[host] = true
+	}
+}
+
+// save records the hosts that answered during this sync
+func (r *hostReachability) save() {
+	for host := range r.reached {
+		reachability.Record(r.database, host, nil, r.now)
+	}
+}
+
+// openJobDir opens a job's working directory with the host's open_dir setting
+func (m Model) openJobDir(job *db.Job) tea.Cmd {
+	setting := ""
+	if m.openDir != nil {
+		setting = m.openDir(job.Host)
+	}
+	var mappings []pathmap.Mapping
+	if m.pathMappings != nil {
+		mappings = m.pathMappings(job.Host)
+	}
+	return func() tea.Msg {
+		template, err := opendir.Template(setting)
+		if err == nil {
+			var uri string
+			if uri, err = opendir.Build(template, ssh.Target(jobHost(job)), job.EffectiveWorkingDir(), mappings); err == nil {
+				err = opendir.Open(uri)
+			}
+		}
+		return dirOpenedMsg{jobID: job.ID, err: err}
 	}
 }
 
@@ -2491,12 +3905,41 @@ func (m Model) killJob(job *db.Job) tea.Cmd {
 
 	database := m.database
 	return func() tea.Msg {
-		tmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-		err := ssh.TmuxKillSession(job.Host, tmuxSession)
-		if err == nil {
-			db.MarkDeadByID(database, job.ID)
+		return jobKilledMsg{jobID: job.ID, err: jobstate.Kill(database, job)}
+	}
+}
+
+// jobHost returns the host to run commands for a job on, as the account the
+// job was started as (see ssh.WithUser)
+func jobHost(job *db.Job) string {
+	return ssh.WithUser(job.Host, job.RemoteUser)
+}
+
+// togglePause suspends a running job or resumes a paused one
+func (m Model) togglePause(job *db.Job) tea.Cmd {
+	if job == nil {
+		return nil
+	}
+
+	database := m.database
+	return func() tea.Msg {
+		signal := "STOP"
+		if job.Status == db.StatusPaused {
+			signal = "CONT"
+		}
+		msg := jobPausedMsg{jobID: job.ID, paused: signal == "STOP"}
+
+		if err := jobstate.Signal(job, signal); err != nil {
+			msg.err = err
+			return msg
 		}
-		return jobKilledMsg{jobID: job.ID, err: err}
+
+		if msg.paused {
+			msg.err = db.MarkPaused(database, job.ID, 0)
+		} else {
+			msg.err = db.MarkResumed(database, job.ID)
+		}
+		return msg
 	}
 }
 
@@ -2508,7 +3951,7 @@ func (m Model) restartJob(job *db.Job) tea.Cmd {
 	return func() tea.Msg {
 		// Read metadata from remote (for old jobs)
 		metadataFile := session.JobMetadataFile(job.ID, job.StartTime, job.SessionName)
-		content, err := ssh.ReadRemoteFile(job.Host, metadataFile)
+		content, err := ssh.ReadRemoteFile(jobHost(job), metadataFile)
 		if err != nil || content == "" {
 			// Fall back to database info
 			content = ""
@@ -2536,12 +3979,29 @@ func (m Model) restartJob(job *db.Job) tea.Cmd {
 		if workingDir == "" || command == "" {
 			return jobRestartedMsg{oldJobID: job.ID, err: fmt.Errorf("missing working directory or command")}
 		}
+		freed := 0
+		if job.Status == db.StatusRunning || job.Status == db.StatusStarting {
+			freed = 1
+		}
+		if err := checkRunLimit(database, job.Host, workingDir, freed); err != nil {
+			return jobRestartedMsg{oldJobID: job.ID, err: err}
+		}
+		// Read the job's secrets before stopping it, in case one is gone
+		secretNames, _ := db.GetJobSecrets(database, job.ID)
+		secretValues, err := secrets.LookupAll(secretNames)
+		if err != nil {
+			return jobRestartedMsg{oldJobID: job.ID, err: fmt.Errorf("job was started with secrets: %w", err)}
+		}
 
 		// Kill existing session if running
-		oldTmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-		exists, _ := ssh.TmuxSessionExistsQuick(job.Host, oldTmuxSession)
-		if exists {
-			ssh.TmuxKillSession(job.Host, oldTmuxSession)
+		if jobstate.RunsWithoutTmux(database, job.ID) {
+			ssh.Run(jobHost(job), session.SignalJobCommand(job.ID, "TERM"))
+		} else {
+			oldTmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
+			exists, _ := ssh.TmuxSessionExistsQuick(jobHost(job), oldTmuxSession)
+			if exists {
+				ssh.TmuxKillSession(jobHost(job), oldTmuxSession)
+			}
 		}
 
 		// Create new job record to get ID
@@ -2549,6 +4009,15 @@ func (m Model) restartJob(job *db.Job) tea.Cmd {
 		if err != nil {
 			return jobRestartedMsg{oldJobID: job.ID, err: fmt.Errorf("create job record: %w", err)}
 		}
+		db.SetJobRemoteUser(database, newJobID, ssh.User(jobHost(job)))
+		if len(secretNames) > 0 {
+			db.SetJobSecrets(database, newJobID, secretNames)
+		}
+		// Keep the script provenance; the uploaded script is still on the host
+		if script, err := db.GetJobScript(database, job.ID); err == nil && script != nil {
+			script.JobID = newJobID
+			db.SaveJobScript(database, *script)
+		}
 
 		// Get the new job to access start time
 		newJob, err := db.GetJobByID(database, newJobID)
@@ -2562,39 +4031,50 @@ func (m Model) restartJob(job *db.Job) tea.Cmd {
 		statusFile := session.StatusFile(newJobID, newJob.StartTime)
 		newMetadataFile := session.MetadataFile(newJobID, newJob.StartTime)
 
-		// Create log directory on remote
-		mkdirCmd := fmt.Sprintf("mkdir -p %s", session.LogDir)
-		if _, stderr, err := ssh.Run(job.Host, mkdirCmd); err != nil {
+		// Create log directory on remote, checking for tmux
+		mkdirCmd := fmt.Sprintf("mkdir -p %s && %s", session.LogDir, session.TmuxProbeCommand)
+		stdout, stderr, err := ssh.Run(jobHost(job), mkdirCmd)
+		if err != nil {
 			errMsg := ssh.FriendlyError(job.Host, stderr, err)
 			db.UpdateJobFailed(database, newJobID, errMsg)
 			return jobRestartedMsg{oldJobID: job.ID, err: fmt.Errorf("%s", errMsg)}
 		}
+		backend := launchBackend(database, newJobID, stdout)
 
 		// Save metadata
 		newMetadata := session.FormatMetadata(newJobID, workingDir, command, job.Host, description, newJob.StartTime)
-		// Don't quote path - it contains ~ which needs shell expansion
-		metadataCmd := fmt.Sprintf("cat > %s << 'METADATA_EOF'\n%s\nMETADATA_EOF", newMetadataFile, newMetadata)
-		ssh.Run(job.Host, metadataCmd)
+		metadataCmd := shellquote.WriteFile(newMetadataFile, newMetadata)
+		ssh.Run(jobHost(job), metadataCmd)
 
 		// Generate pid file path
 		pidFile := session.PidFile(newJobID, newJob.StartTime)
+		secretsFile := ""
+		if len(secretValues) > 0 {
+			secretsFile = session.SecretsFile(newJobID, newJob.StartTime)
+		}
 
 		// Create the wrapped command using the common builder (tested for tilde expansion)
 		wrappedCommand := session.BuildWrapperCommand(session.WrapperCommandParams{
-			JobID:      newJobID,
-			WorkingDir: workingDir,
-			Command:    command,
-			LogFile:    logFile,
-			StatusFile: statusFile,
-			PidFile:    pidFile,
+			JobID:       newJobID,
+			WorkingDir:  workingDir,
+			Command:     command,
+			LogFile:     logFile,
+			StatusFile:  statusFile,
+			PidFile:     pidFile,
+			SecretsFile: secretsFile,
 		})
 
-		// Escape single quotes for embedding in single-quoted string
-		escapedCommand := ssh.EscapeForSingleQuotes(wrappedCommand)
-
-		// Start tmux session - use single quotes to prevent shell expansion
-		tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", newTmuxSession, escapedCommand)
-		if _, stderr, err := ssh.Run(job.Host, tmuxCmd); err != nil {
+		// Start the job (fails if the working directory is missing). Secrets
+		// go over stdin, so their values never appear in a command line.
+		tmuxCmd := session.LaunchCommand(backend, newTmuxSession, workingDir, wrappedCommand, false)
+		launch := func() (string, string, error) { return ssh.Run(jobHost(job), tmuxCmd) }
+		if secretsFile != "" {
+			tmuxCmd = session.SecretsLaunchCommand(secretsFile, tmuxCmd)
+			launch = func() (string, string, error) {
+				return ssh.RunWithInput(jobHost(job), tmuxCmd, secrets.EnvFile(secretValues))
+			}
+		}
+		if _, stderr, err := launch(); err != nil {
 			errMsg := ssh.FriendlyError(job.Host, stderr, err)
 			db.UpdateJobFailed(database, newJobID, errMsg)
 			return jobRestartedMsg{oldJobID: job.ID, err: fmt.Errorf("%s", errMsg)}
@@ -2616,15 +4096,14 @@ func (m Model) startQueuedJobNow(job *db.Job) tea.Cmd {
 	}
 	database := m.database
 	return func() tea.Msg {
-		// Remove job from remote queue file
-		queueName := job.QueueName
-		if queueName == "" {
-			queueName = "default"
+		// Remove job from remote queue file, unless its runner has taken it
+		stdout, stderr, err := ssh.Run(jobHost(job), session.RemoveFromQueueCommand(job.ID, job.QueueName))
+		if err != nil {
+			return jobStartedNowMsg{jobID: job.ID, err: fmt.Errorf("remove from queue: %s", ssh.FriendlyError(job.Host, stderr, err))}
+		}
+		if strings.TrimSpace(stdout) == "missing" {
+			return jobStartedNowMsg{jobID: job.ID, err: fmt.Errorf("job %d has already left its queue", job.ID)}
 		}
-		queueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
-		removeCmd := fmt.Sprintf("grep -v '^%d\\t' %s > %s.tmp 2>/dev/null && mv %s.tmp %s || true",
-			job.ID, queueFile, queueFile, queueFile, queueFile)
-		ssh.Run(job.Host, removeCmd)
 
 		// Update database: transition from queued to running and set start time
 		if err := db.UpdateQueuedToRunning(database, job.ID); err != nil {
@@ -2644,18 +4123,20 @@ func (m Model) startQueuedJobNow(job *db.Job) tea.Cmd {
 		metadataFile := session.MetadataFile(job.ID, updatedJob.StartTime)
 		pidFile := session.PidFile(job.ID, updatedJob.StartTime)
 
-		// Create log directory on remote
-		mkdirCmd := fmt.Sprintf("mkdir -p %s", session.LogDir)
-		if _, stderr, err := ssh.Run(job.Host, mkdirCmd); err != nil {
+		// Create log directory on remote, checking for tmux
+		mkdirCmd := fmt.Sprintf("mkdir -p %s && %s", session.LogDir, session.TmuxProbeCommand)
+		stdout, stderr, err = ssh.Run(jobHost(job), mkdirCmd)
+		if err != nil {
 			errMsg := ssh.FriendlyError(job.Host, stderr, err)
 			db.UpdateJobFailed(database, job.ID, errMsg)
 			return jobStartedNowMsg{jobID: job.ID, err: fmt.Errorf("%s", errMsg)}
 		}
+		backend := launchBackend(database, job.ID, stdout)
 
 		// Save metadata
 		metadata := session.FormatMetadata(job.ID, job.WorkingDir, job.Command, job.Host, job.Description, updatedJob.StartTime)
-		metadataCmd := fmt.Sprintf("cat > %s << 'METADATA_EOF'\n%s\nMETADATA_EOF", metadataFile, metadata)
-		ssh.Run(job.Host, metadataCmd)
+		metadataCmd := shellquote.WriteFile(metadataFile, metadata)
+		ssh.Run(jobHost(job), metadataCmd)
 
 		// Create the wrapped command
 		wrappedCommand := session.BuildWrapperCommand(session.WrapperCommandParams{
@@ -2667,10 +4148,9 @@ func (m Model) startQueuedJobNow(job *db.Job) tea.Cmd {
 			PidFile:    pidFile,
 		})
 
-		// Start tmux session
-		escapedCommand := ssh.EscapeForSingleQuotes(wrappedCommand)
-		tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", tmuxSession, escapedCommand)
-		if _, stderr, err := ssh.Run(job.Host, tmuxCmd); err != nil {
+		// Start the job
+		tmuxCmd := session.LaunchCommand(backend, tmuxSession, job.WorkingDir, wrappedCommand, false)
+		if _, stderr, err := ssh.Run(jobHost(job), tmuxCmd); err != nil {
 			errMsg := ssh.FriendlyError(job.Host, stderr, err)
 			db.UpdateJobFailed(database, job.ID, errMsg)
 			return jobStartedNowMsg{jobID: job.ID, err: fmt.Errorf("%s", errMsg)}
@@ -2689,7 +4169,7 @@ func updateStartTimeFromMetadataTUI(database *sql.DB, job *db.Job) {
 
 	metadataPattern := session.MetadataFilePattern(job.ID)
 	cmd := fmt.Sprintf("cat %s 2>/dev/null", metadataPattern)
-	stdout, _, err := ssh.RunWithTimeout(job.Host, cmd, 5*time.Second)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), cmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil || strings.TrimSpace(stdout) == "" {
 		return // No metadata file or couldn't read it
 	}
@@ -2714,7 +4194,10 @@ func syncQueuedJob(database *sql.DB, job *db.Job) (bool, error) {
 
 	// Check if any status file exists (job completed)
 	cmd := fmt.Sprintf("cat %s 2>/dev/null | head -1", statusPattern)
-	stdout, _, err := ssh.RunWithTimeout(job.Host, cmd, 5*time.Second)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), cmd, ssh.HostTimeouts(job.Host).Sync)
+	if reachability.Failed(err) {
+		return false, err
+	}
 	if err == nil && strings.TrimSpace(stdout) != "" {
 		// Job completed - read exit code and update start time from metadata
 		exitCode, _ := strconv.Atoi(strings.TrimSpace(stdout))
@@ -2732,7 +4215,7 @@ func syncQueuedJob(database *sql.DB, job *db.Job) (bool, error) {
 	// Check if log file exists (job is running)
 	logPattern := fmt.Sprintf("~/.cache/remote-jobs/logs/%d-*.log", job.ID)
 	checkCmd := fmt.Sprintf("ls %s 2>/dev/null | head -1", logPattern)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, checkCmd, 5*time.Second)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), checkCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err == nil && strings.TrimSpace(stdout) != "" {
 		// Job has started running - update start time from metadata
 		updateStartTimeFromMetadataTUI(database, job)
@@ -2761,10 +4244,10 @@ func syncJobQuick(database *sql.DB, job *db.Job) (bool, error) {
 
 	// Regular jobs have tmux sessions
 	tmuxSession := session.JobTmuxSession(job.ID, job.SessionName)
-	exists, err := ssh.TmuxSessionExistsQuick(job.Host, tmuxSession)
+	exists, err := ssh.TmuxSessionExistsQuick(jobHost(job), tmuxSession)
 	if err != nil {
 		// Can't reach host - don't change job status
-		return false, nil
+		return false, err
 	}
 
 	if exists {
@@ -2772,15 +4255,15 @@ func syncJobQuick(database *sql.DB, job *db.Job) (bool, error) {
 	}
 
 	statusFile := session.JobStatusFile(job.ID, job.StartTime, job.SessionName)
-	content, err := ssh.ReadRemoteFileQuick(job.Host, statusFile)
+	content, mtime, err := ssh.ReadStatusFile(jobHost(job), statusFile, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		// Can't reach host - don't change job status
-		return false, nil
+		return false, err
 	}
 
 	if content != "" {
 		exitCode, _ := strconv.Atoi(content)
-		endTime := time.Now().Unix()
+		endTime := clockskew.EndTime(database, job, mtime, time.Now())
 		if err := db.RecordCompletionByID(database, job.ID, exitCode, endTime); err != nil {
 			return false, err
 		}
@@ -2788,10 +4271,57 @@ func syncJobQuick(database *sql.DB, job *db.Job) (bool, error) {
 	}
 
 	// Session doesn't exist and no status file - mark as dead
-	if err := db.MarkDeadByID(database, job.ID); err != nil {
-		return false, err
+	return jobstate.MarkDeadAfterGrace(database, job, "tui", jobstate.SessionDeadEvidence(tmuxSession, statusFile))
+}
+
+// runWatchdog samples GPU utilization on hosts with running jobs and applies
+// the configured action to newly idle jobs. It returns a message per alert.
+func (m Model) runWatchdog(hosts []string) []string {
+	action, err := m.watchdog.EffectiveAction()
+	if err != nil {
+		return []string{err.Error()}
 	}
-	return true, nil
+
+	var messages []string
+	now := time.Now()
+	for _, host := range hosts {
+		alerts, err := watchdog.Check(m.database, host, m.watchdog.IdleAfter(), now)
+		if err != nil {
+			continue
+		}
+		for _, alert := range alerts {
+			msg := alert.Message(now)
+			switch action {
+			case config.WatchdogNotify:
+				if m.watchdog.Command != "" {
+					idle := "REMOTE_JOBS_IDLE_SECONDS=" + strconv.FormatInt(int64(alert.IdleFor(now).Seconds()), 10)
+					if err := hooks.Run(m.watchdog.Command, alert.Job, io.Discard, idle); err != nil {
+						msg += fmt.Sprintf(" (watchdog command failed: %v)", err)
+					}
+				}
+			case config.WatchdogKill:
+				if m.readOnly {
+					msg += " (not killed in read-only mode)"
+				} else if err := jobstate.Kill(m.database, alert.Job); err != nil {
+					msg += fmt.Sprintf(" (kill failed: %v)", err)
+				} else {
+					msg += " (killed)"
+				}
+			}
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// launchBackend chooses the backend for a job from the output of a command
+// that included session.TmuxProbeCommand, recording it if it isn't tmux
+func launchBackend(database *sql.DB, jobID int64, probeOutput string) string {
+	backend := session.ChooseBackend(session.BackendAuto, session.HasTmux(probeOutput))
+	if backend == session.BackendNohup {
+		db.SetJobBackend(database, jobID, backend)
+	}
+	return backend
 }
 
 // syncQueueRunnerJobQuick is an optimized version for queue runner jobs that combines
@@ -2824,20 +4354,24 @@ func syncQueueRunnerJobQuick(database *sql.DB, job *db.Job) (bool, error) {
 		elif pid=$(cat %s 2>/dev/null) && [ -n "$pid" ] && ps -p $pid > /dev/null 2>&1; then
 			echo RUNNING
 		else
-			echo DEAD
+			echo DEAD $pid
 		fi
 	`, statusPattern, statusPattern,
 		currentFile, currentFile, job.ID,
 		job.ID, queueFile,
 		pidPattern)
 
-	stdout, _, err := ssh.RunWithTimeout(job.Host, combinedCmd, 5*time.Second)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), combinedCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		// Connection error - don't update status
-		return false, nil
+		return false, err
 	}
 
 	result := strings.TrimSpace(stdout)
+	if pid, dead := session.DeadState(result); dead {
+		// Job has died unexpectedly
+		return jobstate.MarkDeadAfterGrace(database, job, "tui", jobstate.QueueRunnerDeadEvidence(job, queueName, pid))
+	}
 
 	// Parse result and update database
 	switch result {
@@ -2848,12 +4382,6 @@ func syncQueueRunnerJobQuick(database *sql.DB, job *db.Job) (bool, error) {
 	case "QUEUED":
 		// Job is still waiting in queue, no change needed
 		return false, nil
-	case "DEAD":
-		// Job has died unexpectedly
-		if err := db.MarkDeadByID(database, job.ID); err != nil {
-			return false, err
-		}
-		return true, nil
 	case "":
 		// Empty result (shouldn't happen with our logic, but handle gracefully)
 		return false, nil
@@ -2881,25 +4409,30 @@ func checkAndReviveDeadJob(database *sql.DB, job *db.Job) (bool, error) {
 	// Check if log file exists but no status file (job still running)
 	logPattern := session.LogFilePattern(job.ID)
 	checkCmd := fmt.Sprintf("ls %s 2>/dev/null | head -1", logPattern)
-	stdout, _, err := ssh.RunWithTimeout(job.Host, checkCmd, 5*time.Second)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), checkCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		return false, nil // Can't reach host, don't change status
 	}
 
+	evidence := []db.Evidence{{Check: "log file " + logPattern, Result: "exists"}}
 	if strings.TrimSpace(stdout) == "" {
 		// No log file, check if job is in queue's .current file
 		currentFile := "~/.cache/remote-jobs/queue/default.current"
 		currentCmd := fmt.Sprintf("cat %s 2>/dev/null", currentFile)
-		stdout, _, err = ssh.RunWithTimeout(job.Host, currentCmd, 5*time.Second)
+		stdout, _, err = ssh.RunWithTimeout(jobHost(job), currentCmd, ssh.HostTimeouts(job.Host).Sync)
 		if err != nil || strings.TrimSpace(stdout) != fmt.Sprintf("%d", job.ID) {
 			return false, nil // Job is not current, stay dead
 		}
+		evidence = []db.Evidence{
+			{Check: "log file " + logPattern, Result: "missing"},
+			{Check: "queue default current job", Result: "this job"},
+		}
 	}
 
 	// Check if status file exists (job completed, not running)
 	statusPattern := session.StatusFilePattern(job.ID)
 	statusCmd := fmt.Sprintf("cat %s 2>/dev/null | head -1", statusPattern)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, statusCmd, 5*time.Second)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), statusCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err == nil && strings.TrimSpace(stdout) != "" {
 		// Job has completed, update to completed instead of reviving
 		exitCode, _ := strconv.Atoi(strings.TrimSpace(stdout))
@@ -2911,7 +4444,8 @@ func checkAndReviveDeadJob(database *sql.DB, job *db.Job) (bool, error) {
 	}
 
 	// Job is running (has log file or is current, but no status file) - revive it
-	if err := db.ReviveDeadJob(database, job.ID); err != nil {
+	evidence = append(evidence, db.Evidence{Check: "status file " + statusPattern, Result: "missing"})
+	if err := db.ReviveWithEvidence(database, job.ID, "tui", evidence); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -2923,7 +4457,7 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 	// Check if status file exists (job completed)
 	statusPattern := session.StatusFilePattern(job.ID)
 	cmd := fmt.Sprintf("cat %s 2>/dev/null | head -1", statusPattern)
-	stdout, _, err := ssh.RunWithTimeout(job.Host, cmd, 5*time.Second)
+	stdout, _, err := ssh.RunWithTimeout(jobHost(job), cmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		// Can't reach host - don't change job status
 		return false, nil
@@ -2945,7 +4479,7 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 	}
 	currentFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.current", queueName)
 	currentCmd := fmt.Sprintf("cat %s 2>/dev/null || true", currentFile)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, currentCmd, 5*time.Second)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), currentCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		// Can't reach host - don't change job status
 		return false, nil
@@ -2959,7 +4493,7 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 	// Check if job is still in the queue file (waiting to run)
 	queueFile := fmt.Sprintf("~/.cache/remote-jobs/queue/%s.queue", queueName)
 	grepCmd := fmt.Sprintf("grep -q '^%d	' %s 2>/dev/null && echo yes || echo no", job.ID, queueFile)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, grepCmd, 5*time.Second)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), grepCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		return false, nil
 	}
@@ -2970,22 +4504,21 @@ func syncQueueRunnerJob(database *sql.DB, job *db.Job) (bool, error) {
 
 	// Check if the job's process is still running (via PID file)
 	pidPattern := session.PidFilePattern(job.ID)
-	pidCmd := fmt.Sprintf("pid=$(cat %s 2>/dev/null); [ -n \"$pid\" ] && ps -p $pid > /dev/null 2>&1 && echo running || echo not_running", pidPattern)
-	stdout, _, err = ssh.RunWithTimeout(job.Host, pidCmd, 5*time.Second)
+	pidCmd := fmt.Sprintf("pid=$(cat %s 2>/dev/null); [ -n \"$pid\" ] && ps -p $pid > /dev/null 2>&1 && echo running || echo not_running $pid", pidPattern)
+	stdout, _, err = ssh.RunWithTimeout(jobHost(job), pidCmd, ssh.HostTimeouts(job.Host).Sync)
 	if err != nil {
 		return false, nil
 	}
-	if strings.TrimSpace(stdout) == "running" {
+	pidState := strings.TrimSpace(stdout)
+	if pidState == "running" {
 		// Process is still running, don't mark as dead
 		return false, nil
 	}
 
 	// Job is not current, not in queue, process not running, and has no status file - it's dead
 	// (Either it died mid-execution, or was removed from queue)
-	if err := db.MarkDeadByID(database, job.ID); err != nil {
-		return false, err
-	}
-	return true, nil
+	pid := strings.TrimSpace(strings.TrimPrefix(pidState, "not_running"))
+	return jobstate.MarkDeadAfterGrace(database, job, "tui", jobstate.QueueRunnerDeadEvidence(job, queueName, pid))
 }
 
 func (m Model) pruneJobs() tea.Cmd {
@@ -2995,32 +4528,52 @@ func (m Model) pruneJobs() tea.Cmd {
 	}
 }
 
+// autoPrune applies the prune policy on startup if prune.auto is set,
+// except in read-only mode. Remote files are deleted on each host's next `remote-jobs sync`.
+func (m Model) autoPrune() tea.Cmd {
+	policy := m.prunePolicy
+	if !policy.Auto || !policy.Enabled() || m.readOnly {
+		return nil
+	}
+	return func() tea.Msg {
+		finished, err := db.ListFinishedJobs(m.database)
+		if err != nil {
+			return autoPrunedMsg{err: err}
+		}
+		removals := prunepolicy.Select(policy, finished, time.Now())
+		count, err := prunepolicy.Apply(m.database, removals, true)
+		return autoPrunedMsg{count: count, summary: prunepolicy.Summary(removals[:count]), err: err}
+	}
+}
+
 func (m Model) startQueue(host string) tea.Cmd {
+	schedule := m.hostAvailability(host)
 	return func() tea.Msg {
 		queueName := "default"
 		runnerSession := fmt.Sprintf("rj-queue-%s", queueName)
 
-		// Check if queue runner is already running
-		exists, err := ssh.TmuxSessionExists(host, runnerSession)
-		if err != nil {
-			return queueStartedMsg{host: host, err: fmt.Errorf("check session: %w", err)}
-		}
-
-		if exists {
-			return queueStartedMsg{host: host, already: true}
+		// Check if queue runner (or the shared runner) is already running
+		for _, session := range []string{runnerSession, "rj-queues"} {
+			exists, err := ssh.TmuxSessionExists(host, session)
+			if err != nil {
+				return queueStartedMsg{host: host, err: fmt.Errorf("check session: %w", err)}
+			}
+			if exists {
+				return queueStartedMsg{host: host, already: true}
+			}
 		}
 
 		// Create directories on remote
 		queueDir := "~/.cache/remote-jobs/queue"
 		scriptsDir := "~/.cache/remote-jobs/scripts"
-		mkdirCmd := fmt.Sprintf("mkdir -p %s %s", queueDir, scriptsDir)
+		mkdirCmd := fmt.Sprintf("mkdir -p %s %s && %s", queueDir, scriptsDir, schedule.InstallCommand())
 		if _, stderr, err := ssh.Run(host, mkdirCmd); err != nil {
 			return queueStartedMsg{host: host, err: fmt.Errorf("create directories: %s", stderr)}
 		}
 
 		// Deploy queue runner script (embedded in binary)
 		queueRunnerPath := "~/.cache/remote-jobs/scripts/queue-runner.sh"
-		writeCmd := fmt.Sprintf("cat > %s << 'SCRIPT_EOF'\n%s\nSCRIPT_EOF", queueRunnerPath, string(scripts.QueueRunnerScript))
+		writeCmd := shellquote.WriteFile(queueRunnerPath, string(scripts.QueueRunnerScript))
 		if _, stderr, err := ssh.Run(host, writeCmd); err != nil {
 			return queueStartedMsg{host: host, err: fmt.Errorf("write queue runner: %s", stderr)}
 		}
@@ -3032,8 +4585,8 @@ func (m Model) startQueue(host string) tea.Cmd {
 		}
 
 		// Start queue runner in tmux
-		runnerCmd := fmt.Sprintf("$HOME/.cache/remote-jobs/scripts/queue-runner.sh %s", queueName)
-		tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", runnerSession, ssh.EscapeForSingleQuotes(runnerCmd))
+		runnerCmd := "$HOME/.cache/remote-jobs/scripts/queue-runner.sh " + shellquote.Quote(queueName)
+		tmuxCmd := fmt.Sprintf("tmux new-session -d -s %s bash -c %s", shellquote.Quote(runnerSession), shellquote.Quote(runnerCmd))
 
 		if _, stderr, err := ssh.Run(host, tmuxCmd); err != nil {
 			return queueStartedMsg{host: host, err: fmt.Errorf("start queue runner: %s", stderr)}
@@ -3054,37 +4607,138 @@ func (m Model) removeJob(job *db.Job) tea.Cmd {
 	}
 }
 
-func (m Model) createJob() tea.Cmd {
-	database := m.database
-	host := strings.TrimSpace(m.inputs[inputHost].Value())
-	command := strings.TrimSpace(m.inputs[inputCommand].Value())
-	description := strings.TrimSpace(m.inputs[inputDescription].Value())
-	workingDir := strings.TrimSpace(m.inputs[inputWorkingDir].Value())
-	envVarsStr := strings.TrimSpace(m.inputs[inputEnvVars].Value())
+// inputLaunchSpec reads the new-job form into a launch spec (without job ID
+// or start time), adding the host's env defaults and remote hooks from cfg,
+// as the CLI does
+func (m Model) inputLaunchSpec(cfg *config.Config) session.LaunchSpec {
+	spec := session.LaunchSpec{
+		Host:        strings.TrimSpace(m.inputs[inputHost].Value()),
+		Command:     strings.TrimSpace(m.inputs[inputCommand].Value()),
+		Description: strings.TrimSpace(m.inputs[inputDescription].Value()),
+		WorkingDir:  strings.TrimSpace(m.inputs[inputWorkingDir].Value()),
+	}
 
-	if workingDir == "" {
-		workingDir = "~"
+	if spec.WorkingDir == "" {
+		spec.WorkingDir = "~"
 	}
 
 	// Parse env vars (comma-separated VAR=value pairs)
-	var envVars []string
+	envVarsStr := strings.TrimSpace(m.inputs[inputEnvVars].Value())
 	if envVarsStr != "" {
 		for _, ev := range strings.Split(envVarsStr, ",") {
 			ev = strings.TrimSpace(ev)
 			if ev != "" {
-				envVars = append(envVars, ev)
+				spec.EnvVars = append(spec.EnvVars, ev)
+			}
+		}
+	}
+	spec.EnvVars = cfg.HostEnv(spec.Host, spec.EnvVars)
+	spec.PreStart, spec.PostFinish = cfg.RemoteHooks(spec.Host, "", "")
+	return spec
+}
+
+// loadConfig returns the config file's settings, or the defaults if it
+// can't be read
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.DefaultConfig()
+	}
+	return cfg
+}
+
+// hostAvailability returns a host's availability windows, or none if it has
+// none or they are invalid
+func (m Model) hostAvailability(host string) availability.Schedule {
+	if m.availability == nil {
+		return availability.Schedule{}
+	}
+	s, err := m.availability(host)
+	if err != nil {
+		return availability.Schedule{}
+	}
+	return s
+}
+
+// loadHostLimits returns the configured limits for a host, or none if the
+// config can't be read
+func loadHostLimits(host string) config.Limits {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.Limits{}
+	}
+	return cfg.HostLimits(host)
+}
+
+// checkRunLimit returns an error if the host is at its configured max_running
+// limit, or if the filesystem holding workingDir is below its free space
+// minimum. freed is how many of the host's running jobs are about to end, as
+// when a running job is restarted.
+func checkRunLimit(database *sql.DB, host, workingDir string, freed int) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	limits := cfg.HostLimits(host)
+	if limits.MaxRunning > 0 {
+		running, err := db.CountActiveJobs(database, host)
+		if err == nil {
+			if err := limits.CheckRunning(host, running-freed); err != nil {
+				return fmt.Errorf("%w (use 'remote-jobs run --ignore-limits' to override)", err)
 			}
 		}
 	}
+	if err := diskspace.CheckHost(host, workingDir, limits.MinFreeDiskGB, limits.MinFreeInodesPct); err != nil {
+		return fmt.Errorf("%w (use 'remote-jobs run --ignore-limits' to override)", err)
+	}
+	return nil
+}
+
+// updateLaunchPreview rebuilds the preview of what the new-job form would
+// run, if it is shown. It reads the config and the database, so it runs in
+// Update rather than View.
+func (m *Model) updateLaunchPreview() {
+	if m.inputPreview {
+		m.previewText = m.launchPreview()
+	}
+}
+
+// launchPreview shows what the new-job form would run, without running it
+func (m Model) launchPreview() string {
+	spec := m.inputLaunchSpec(loadConfig())
+	if spec.Host == "" || spec.Command == "" {
+		return "Enter a host and command to preview"
+	}
+	jobID, err := db.NextJobID(m.database)
+	if err != nil {
+		return fmt.Sprintf("Preview unavailable: %v", err)
+	}
+	spec.JobID = jobID
+	spec.StartTime = time.Now().Unix()
+	return session.BuildLaunchPlan(spec).Format()
+}
+
+func (m Model) createJob() tea.Cmd {
+	database := m.database
+	spec := m.inputLaunchSpec(loadConfig())
+	host, command, description, workingDir := spec.Host, spec.Command, spec.Description, spec.WorkingDir
 
 	return func() tea.Msg {
 		timeout := 30 * time.Second
 
+		if err := checkRunLimit(database, host, workingDir, 0); err != nil {
+			return jobCreatedMsg{err: err}
+		}
+
 		// Create job record to get ID
 		jobID, err := db.RecordJobStarting(database, host, workingDir, command, description)
 		if err != nil {
 			return jobCreatedMsg{err: fmt.Errorf("create job record: %w", err)}
 		}
+		db.SetJobRemoteUser(database, jobID, ssh.User(host))
+		if len(spec.EnvVars) > 0 {
+			db.SetJobEnv(database, jobID, spec.EnvVars)
+		}
 
 		// Get the new job to access start time
 		job, err := db.GetJobByID(database, jobID)
@@ -3092,43 +4746,32 @@ func (m Model) createJob() tea.Cmd {
 			return jobCreatedMsg{err: fmt.Errorf("get new job: %w", err)}
 		}
 
-		// Generate file paths from job ID
-		tmuxSession := session.TmuxSessionName(jobID)
-		logFile := session.LogFile(jobID, job.StartTime)
-		statusFile := session.StatusFile(jobID, job.StartTime)
-		metadataFile := session.MetadataFile(jobID, job.StartTime)
-		pidFile := session.PidFile(jobID, job.StartTime)
-
-		// Create log directory on remote
-		mkdirCmd := fmt.Sprintf("mkdir -p %s", session.LogDir)
-		if _, stderr, err := ssh.RunWithTimeout(host, mkdirCmd, timeout); err != nil {
+		spec.JobID = jobID
+		spec.StartTime = job.StartTime
+		plan := session.BuildLaunchPlan(spec)
+
+		// Create log directory on remote, reading the host's clock on the way
+		before := time.Now()
+		stdout, stderr, err := ssh.RunWithTimeout(host, plan.MkdirCommand, timeout)
+		if err != nil {
 			errMsg := ssh.FriendlyError(host, stderr, err)
 			db.UpdateJobFailed(database, jobID, errMsg)
 			return jobCreatedMsg{err: fmt.Errorf("%s", errMsg)}
 		}
+		var warning string
+		if skew, err := clockskew.Record(database, jobID, stdout, before, time.Now()); err == nil && clockskew.Exceeds(skew) {
+			warning = clockskew.Describe(host, skew)
+		}
+		if launchBackend(database, jobID, stdout) == session.BackendNohup {
+			spec.Backend = session.BackendNohup
+			plan = session.BuildLaunchPlan(spec)
+		}
 
 		// Save metadata
-		metadata := session.FormatMetadata(jobID, workingDir, command, host, description, job.StartTime)
-		// Don't quote path - it contains ~ which needs shell expansion
-		metadataCmd := fmt.Sprintf("cat > %s << 'METADATA_EOF'\n%s\nMETADATA_EOF", metadataFile, metadata)
-		ssh.RunWithTimeout(host, metadataCmd, timeout)
-
-		// Create the wrapped command using the common builder (tested for tilde expansion)
-		wrappedCommand := session.BuildWrapperCommand(session.WrapperCommandParams{
-			JobID:      jobID,
-			WorkingDir: workingDir,
-			Command:    command,
-			LogFile:    logFile,
-			StatusFile: statusFile,
-			PidFile:    pidFile,
-			EnvVars:    envVars,
-		})
+		ssh.RunWithTimeout(host, plan.MetadataCommand, timeout)
 
-		// Escape single quotes for embedding in single-quoted string
-		escapedCommand := ssh.EscapeForSingleQuotes(wrappedCommand)
-
-		// Start tmux session - use single quotes to prevent shell expansion
-		tmuxCmd := fmt.Sprintf("tmux new-session -d -s '%s' bash -c '%s'", tmuxSession, escapedCommand)
+		// Start the job (fails if the working directory is missing)
+		tmuxCmd := plan.LaunchCommand
 		if _, stderr, err := ssh.RunWithTimeout(host, tmuxCmd, timeout); err != nil {
 			errMsg := ssh.FriendlyError(host, stderr, err)
 			db.UpdateJobFailed(database, jobID, errMsg)
@@ -3140,23 +4783,26 @@ func (m Model) createJob() tea.Cmd {
 			return jobCreatedMsg{err: err}
 		}
 
-		return jobCreatedMsg{jobID: jobID}
+		return jobCreatedMsg{jobID: jobID, warning: warning}
 	}
 }
 
 // hostFromCachedInfo creates a Host from cached database info
 func hostFromCachedInfo(cached *db.CachedHostInfo) *Host {
 	host := &Host{
-		Name:      cached.Name,
-		Status:    HostStatusUnknown, // Will be updated when we query
-		Arch:      cached.Arch,
-		OS:        cached.OSVersion,
-		Model:     cached.Model,
-		CPUs:      cached.CPUCount,
-		CPUModel:  cached.CPUModel,
-		CPUFreq:   cached.CPUFreq,
-		MemTotal:  cached.MemTotal,
-		LastCheck: time.Unix(cached.LastUpdated, 0),
+		Name:     cached.Name,
+		Status:   HostStatusUnknown, // Will be updated when we query
+		Arch:     cached.Arch,
+		OS:       cached.OSVersion,
+		Model:    cached.Model,
+		CPUs:     cached.CPUCount,
+		CPUModel: cached.CPUModel,
+		CPUFreq:  cached.CPUFreq,
+		MemTotal: cached.MemTotal,
+		Tools:    hosttools.Decode(cached.ToolsJSON),
+	}
+	if cached.Fetched() {
+		host.LastCheck = time.Unix(cached.LastUpdated, 0)
 	}
 
 	// Parse GPUs from JSON
@@ -3181,9 +4827,16 @@ func cachedInfoFromHost(host *Host) *db.CachedHostInfo {
 		CPUModel:    host.CPUModel,
 		CPUFreq:     host.CPUFreq,
 		MemTotal:    host.MemTotal,
+		ToolsJSON:   host.Tools.Encode(),
 		LastUpdated: time.Now().Unix(),
 	}
 
+	for _, d := range host.Disks {
+		if d.Path == "~" {
+			cached.DiskAvailKB = d.AvailKB
+		}
+	}
+
 	// Encode GPUs to JSON
 	if len(host.GPUs) > 0 {
 		if data, err := json.Marshal(host.GPUs); err == nil {
@@ -3219,6 +4872,9 @@ func updateHostWithCachedStatic(host *Host, cached *Host) {
 	if host.MemTotal == "" {
 		host.MemTotal = cached.MemTotal
 	}
+	if host.Tools == nil {
+		host.Tools = cached.Tools
+	}
 	// GPUs are static info about what GPUs exist (not utilization)
 	// We always get fresh GPU data when online, so don't merge
 }
@@ -3230,24 +4886,23 @@ func truncate(s string, max int) string {
 	return s[:max-1] + "…"
 }
 
-// formatStartTime formats a start time as relative ("2h ago") for recent jobs
-// or as absolute ("01/02 15:04") for older jobs
-func formatStartTime(startTime int64) string {
-	// Handle queued jobs that haven't started yet
-	if startTime == 0 {
-		return "—"
-	}
+// formatTime formats one of a job's times for the job list, by default as
+// relative ("2h ago") for recent times or as absolute ("01/02 15:04") for
+// older ones. Queued jobs that haven't started yet show "—".
+func (m Model) formatTime(job *db.Job, t int64) string {
+	return m.times.Short(t, m.jobZone(job), time.Now())
+}
 
-	t := time.Unix(startTime, 0)
-	elapsed := time.Since(t)
+// formatFullTime formats one of a job's times for the details panel, with the
+// date, seconds, and time zone, followed by how long ago it was
+func (m Model) formatFullTime(job *db.Job, t int64) string {
+	return fmt.Sprintf("%s (%s)", m.times.Full(t, m.jobZone(job)), humanfmt.Relative(time.Since(time.Unix(t, 0))))
+}
 
-	if elapsed < 12*time.Hour {
-		if elapsed < time.Minute {
-			return "just now"
-		} else if elapsed < time.Hour {
-			return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
-		}
-		return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+// jobZone returns the time zone of a job's host, or nil if it wasn't recorded
+func (m Model) jobZone(job *db.Job) *time.Location {
+	if stats := m.jobStats[job.ID]; stats != nil {
+		return timefmt.HostZone(stats.HostUTCOffset)
 	}
-	return t.Format("01/02 15:04")
+	return nil
 }
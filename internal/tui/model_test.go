@@ -1,9 +1,15 @@
 package tui
 
 import (
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/config"
 	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/ssh"
 )
 
 func TestGetTargetJobPrefersHighlightedInDetailsTab(t *testing.T) {
@@ -28,3 +34,139 @@ func TestGetTargetJobPrefersHighlightedInDetailsTab(t *testing.T) {
 		t.Fatalf("expected selected log job 2 in Logs tab, got %+v", got)
 	}
 }
+
+func TestStderrToggleShowsOnlyItsStream(t *testing.T) {
+	job := &db.Job{ID: 1, Host: "host-a", Status: db.StatusRunning}
+	m := Model{jobs: []*db.Job{job}, logCache: make(map[int64]string), width: 100, height: 40}
+
+	updated, cmd := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("E")})
+	m = updated.(Model)
+	if cmd == nil || !m.logStderr || m.detailTab != DetailTabLogs {
+		t.Fatalf("E should open the logs tab showing stderr only")
+	}
+
+	// The whole log arrives late, after stderr was chosen
+	updated, _ = m.Update(logFetchedMsg{jobID: 1, content: "combined"})
+	m = updated.(Model)
+	updated, _ = m.Update(logFetchedMsg{jobID: 1, stderr: true, content: "errors"})
+	m = updated.(Model)
+	if m.logContent != "errors" {
+		t.Errorf("logContent = %q, want the stderr file's", m.logContent)
+	}
+	if m.logCache[1] != "combined" || m.stderrCache[1] != "errors" {
+		t.Errorf("caches = %q, %q; want each stream cached on its own", m.logCache[1], m.stderrCache[1])
+	}
+
+	updated, _ = m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("E")})
+	m = updated.(Model)
+	if m.logStderr || !m.logLoading {
+		t.Error("E in the logs tab should go back to the whole log and fetch it")
+	}
+}
+
+func TestActiveFilterIncludesPausedJobs(t *testing.T) {
+	job := &db.Job{ID: 1, Status: db.StatusPaused}
+	if !jobMatchesFilter(job, jobFilterActive) {
+		t.Error("expected paused job to match the active filter")
+	}
+	if jobMatchesFilter(job, jobFilterFailed) {
+		t.Error("expected paused job not to match the failed filter")
+	}
+}
+
+func TestOnlySettledSelectionFetches(t *testing.T) {
+	jobs := []*db.Job{
+		{ID: 1, Host: "host-a", Status: db.StatusRunning},
+		{ID: 2, Host: "host-b", Status: db.StatusRunning},
+	}
+	m := Model{jobs: jobs, detailTab: DetailTabDetails}
+
+	m.selectedIndex = 1
+	m.highlightChanged()
+	stale := selectionSettledMsg{seq: m.selectionSeq}
+	m.selectedIndex = 0
+	m.highlightChanged()
+
+	if _, cmd := m.Update(stale); cmd != nil {
+		t.Error("expected a superseded selection not to fetch")
+	}
+	if _, cmd := m.Update(selectionSettledMsg{seq: m.selectionSeq}); cmd == nil {
+		t.Error("expected the settled selection to fetch process stats")
+	}
+}
+
+func TestRecordProcessStatsKeepsGoodSamples(t *testing.T) {
+	m := Model{processStats: make(map[int64]*ssh.ProcessStats)}
+	m.recordProcessStats(map[int64]*ssh.ProcessStats{
+		1: {Running: true, Timestamp: 100, CPUUserTicks: 1000},
+	})
+	m.recordProcessStats(map[int64]*ssh.ProcessStats{
+		1: {Running: true, Timestamp: 110, CPUUserTicks: 1500, CPUSysTicks: 100},
+		2: {Error: "PID file not found"},
+	})
+	if got := m.processStats[1].CPUPct; got != 60 {
+		t.Errorf("CPUPct = %v, want 60", got)
+	}
+	if m.processStats[2] == nil {
+		t.Error("expected a failed sample to be kept for a job without a good one")
+	}
+
+	m.recordProcessStats(map[int64]*ssh.ProcessStats{1: {Error: "ssh: timeout"}})
+	if !m.processStats[1].Running {
+		t.Error("expected a failed sample not to replace a good one")
+	}
+}
+
+func TestInputLaunchSpecAddsHostSettings(t *testing.T) {
+	m := Model{inputs: make([]textinput.Model, 5)}
+	for i := range m.inputs {
+		m.inputs[i] = textinput.New()
+	}
+	m.inputs[inputHost].SetValue("cool30")
+	m.inputs[inputCommand].SetValue("python train.py")
+	m.inputs[inputEnvVars].SetValue("EPOCHS=3, HF_HOME=/tmp/hf")
+	cfg := config.DefaultConfig()
+	cfg.Hosts = map[string]config.HostConfig{
+		"cool30": {
+			Env:        map[string]string{"HF_HOME": "/scratch/hf", "WANDB_DIR": "/scratch/wandb"},
+			PreStart:   "module load cuda",
+			PostFinish: "rm -rf /tmp/scratch",
+		},
+	}
+
+	spec := m.inputLaunchSpec(cfg)
+	want := []string{"WANDB_DIR=/scratch/wandb", "EPOCHS=3", "HF_HOME=/tmp/hf"}
+	if !slices.Equal(spec.EnvVars, want) {
+		t.Errorf("inputLaunchSpec().EnvVars = %q, want %q", spec.EnvVars, want)
+	}
+	if spec.PreStart != "module load cuda" || spec.PostFinish != "rm -rf /tmp/scratch" {
+		t.Errorf("inputLaunchSpec() hooks = %q, %q; want the host's", spec.PreStart, spec.PostFinish)
+	}
+}
+
+func TestLaunchPreviewFollowsTheForm(t *testing.T) {
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	m := Model{database: database, inputMode: true, inputs: make([]textinput.Model, 5)}
+	for i := range m.inputs {
+		m.inputs[i] = textinput.New()
+	}
+	m.inputs[inputHost].SetValue("cool30")
+	m.inputs[inputCommand].SetValue("python train.py")
+	m.inputFocus = inputCommand
+	m.inputs[inputCommand].Focus()
+
+	model, _ := m.handleInputKeyPress(tea.KeyMsg{Type: tea.KeyCtrlP})
+	m = model.(Model)
+	if !strings.Contains(m.previewText, "python train.py") {
+		t.Fatalf("preview = %q, want the command", m.previewText)
+	}
+
+	model, _ = m.handleInputKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(" --fast")})
+	if m := model.(Model); !strings.Contains(m.previewText, "python train.py --fast") {
+		t.Errorf("preview after typing = %q, want the new command", m.previewText)
+	}
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/editor"
+)
+
+// noteEditedMsg is sent when the editor opened by editNote exits
+type noteEditedMsg struct {
+	jobID int64
+	path  string // Temporary file holding the edited note
+	old   string // The note before editing
+	err   error
+}
+
+// editNote suspends the TUI and opens a job's note in the user's editor
+func (m Model) editNote(job *db.Job) tea.Cmd {
+	old := ""
+	if note, _ := db.GetJobNote(m.database, job.ID); note != nil {
+		old = note.Text
+	}
+	path, err := editor.Prepare(old, fmt.Sprintf("remote-jobs-note-%d-*.md", job.ID))
+	if err != nil {
+		return m.setFlash(fmt.Sprintf("Edit note failed: %v", err), true)
+	}
+	return tea.ExecProcess(editor.Command(path), func(err error) tea.Msg {
+		return noteEditedMsg{jobID: job.ID, path: path, old: old, err: err}
+	})
+}
+
+// handleNoteEdited saves the note written in the editor
+func (m Model) handleNoteEdited(msg noteEditedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		os.Remove(msg.path)
+		return m, m.setFlash(fmt.Sprintf("Editor failed: %v", msg.err), true)
+	}
+	text, err := editor.Finish(msg.path)
+	if err != nil {
+		return m, m.setFlash(fmt.Sprintf("Edit note failed: %v", err), true)
+	}
+	text = strings.TrimSpace(text)
+	if text == msg.old {
+		return m, m.setFlash(fmt.Sprintf("Note for job %d unchanged", msg.jobID), false)
+	}
+	if err := db.SetJobNote(m.database, msg.jobID, text); err != nil {
+		return m, m.setFlash(fmt.Sprintf("Save note failed: %v", err), true)
+	}
+	if text == "" {
+		return m, m.setFlash(fmt.Sprintf("Removed note from job %d", msg.jobID), false)
+	}
+	return m, m.setFlash(fmt.Sprintf("Saved note for job %d", msg.jobID), false)
+}
+
+var noteHeadingStyle = lipgloss.NewStyle().Bold(true)
+
+// renderNote renders a note's Markdown for the Details tab: headings in bold
+// without their #s, list items with bullets, and other lines as written,
+// each indented by two spaces
+func renderNote(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(trimmed)]
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			heading := strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			line = noteHeadingStyle.Render(heading)
+		case strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "* "):
+			line = indent + "• " + trimmed[2:]
+		}
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
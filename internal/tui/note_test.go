@@ -0,0 +1,20 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderNote(t *testing.T) {
+	got := renderNote("## Observations\n- diverged at epoch 12\n  * lr too high\nrerun as #57")
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	want := []string{"Observations", "  • diverged at epoch 12", "    • lr too high", "  rerun as #57"}
+	if len(lines) != len(want) {
+		t.Fatalf("renderNote() = %q", got)
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
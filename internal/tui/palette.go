@@ -0,0 +1,408 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// paletteMaxRows is how many matching commands the palette shows at once
+const paletteMaxRows = 10
+
+// paletteCommand is an action offered by the command palette
+type paletteCommand struct {
+	title string // e.g., "Kill job #42 train.py"
+	key   string // Key that does the same from the list, if any
+	run   func(m Model) (tea.Model, tea.Cmd)
+}
+
+// openPalette opens the command palette with the actions available now
+func (m Model) openPalette() Model {
+	m.paletteMode = true
+	m.paletteCommands = m.readOnlyPaletteCommands(m.buildPaletteCommands())
+	m.paletteIndex = 0
+	m.paletteInput.SetValue("")
+	m.paletteInput.Focus()
+	m.flashMessage = ""
+	return m
+}
+
+// buildPaletteCommands lists the general actions, then those for each listed
+// job and each host. Job actions highlight the job and then press its key, so
+// they behave exactly like the key bindings.
+func (m Model) buildPaletteCommands() []paletteCommand {
+	commands := []paletteCommand{
+		{"New job", "n", inJobsView(pressKey(keys.NewJob))},
+		{"Sync job statuses", "s", inJobsView(pressKey(keys.Sync))},
+		{"Prune completed/dead jobs", "P", pressKey(keys.Prune)},
+		{"Show jobs", "j", func(m Model) (tea.Model, tea.Cmd) {
+			m.viewMode = ViewModeJobs
+			return m, nil
+		}},
+		{"Show hosts", "h", pressKey(keys.HostsView)},
+		{"Show leaderboard", "L", func(m Model) (tea.Model, tea.Cmd) {
+			return m.showLeaderboard(), nil
+		}},
+		{"Show GPU pool", "G", func(m Model) (tea.Model, tea.Cmd) {
+			m.showGPUPool = true
+			if m.viewMode != ViewModeHosts {
+				m.viewMode = ViewModeHosts
+				return m, m.refreshHosts()
+			}
+			return m, nil
+		}},
+	}
+	for mode := jobFilterAll; mode < jobFilterModeCount; mode++ {
+		commands = append(commands, paletteCommand{"Filter: " + jobFilterDescription(mode), "f", func(m Model) (tea.Model, tea.Cmd) {
+			m.viewMode = ViewModeJobs
+			m.jobFilter = mode
+			m.applyJobFilter()
+			return m, m.setFlash(fmt.Sprintf("Filter: %s", jobFilterDescription(mode)), false)
+		}})
+	}
+	for mode := groupNone; mode < groupModeCount; mode++ {
+		commands = append(commands, paletteCommand{"Group by: " + groupModeDescription(mode), "b", func(m Model) (tea.Model, tea.Cmd) {
+			m.viewMode = ViewModeJobs
+			m.setGroupMode(mode)
+			return m, m.setFlash(fmt.Sprintf("Group by: %s", groupModeDescription(mode)), false)
+		}})
+	}
+	commands = append(commands,
+		paletteCommand{"Collapse/expand all groups", "Z", inJobsView(pressKey(keys.CollapseAll))})
+	commands = append(commands,
+		paletteCommand{"Show help", "?", func(m Model) (tea.Model, tea.Cmd) {
+			m.showHelp = true
+			return m, nil
+		}},
+		paletteCommand{"Quit", "q", func(m Model) (tea.Model, tea.Cmd) { return m, tea.Quit }},
+	)
+
+	for _, job := range m.jobs {
+		commands = append(commands, m.jobPaletteCommands(job)...)
+	}
+
+	for _, host := range m.paletteHosts() {
+		commands = append(commands,
+			paletteCommand{"Start queue on " + host, "S", func(m Model) (tea.Model, tea.Cmd) {
+				return m, tea.Batch(m.setFlash(fmt.Sprintf("Starting queue on %s...", host), false), m.startQueue(host))
+			}},
+			paletteCommand{"Browse files on " + host, "", func(m Model) (tea.Model, tea.Cmd) {
+				return m.openFileBrowser(host, "~")
+			}},
+			paletteCommand{"Show host " + host, "", func(m Model) (tea.Model, tea.Cmd) {
+				for i, h := range m.hosts {
+					if h.Name == host {
+						m.selectedHostIdx = i
+					}
+				}
+				m.showGPUPool = false
+				if m.viewMode != ViewModeHosts {
+					m.viewMode = ViewModeHosts
+					return m, m.refreshHosts()
+				}
+				return m, nil
+			}},
+		)
+	}
+	return commands
+}
+
+// jobPaletteCommands returns the actions that apply to a job in its state
+func (m Model) jobPaletteCommands(job *db.Job) []paletteCommand {
+	label := fmt.Sprintf("#%d %s", job.ID, truncate(m.jobLabel(job), 40))
+	onJob := func(b key.Binding) func(Model) (tea.Model, tea.Cmd) {
+		return func(m Model) (tea.Model, tea.Cmd) {
+			if !m.selectJob(job.ID) {
+				return m, m.setFlash(fmt.Sprintf("Job %d is no longer listed", job.ID), true)
+			}
+			return pressKey(b)(m)
+		}
+	}
+
+	commands := []paletteCommand{
+		{"Open logs for " + label, "l", onJob(keys.Logs)},
+		{"Toggle stderr only in logs for " + label, "E", onJob(keys.Stderr)},
+	}
+	switch job.Status {
+	case db.StatusRunning:
+		commands = append(commands,
+			paletteCommand{"Kill job " + label, "k", onJob(keys.Kill)},
+			paletteCommand{"Pause job " + label, "p", onJob(keys.Pause)})
+	case db.StatusPaused:
+		commands = append(commands,
+			paletteCommand{"Kill job " + label, "k", onJob(keys.Kill)},
+			paletteCommand{"Resume job " + label, "p", onJob(keys.Pause)})
+	case db.StatusQueued:
+		commands = append(commands, paletteCommand{"Start job " + label + " now", "g", onJob(keys.StartNow)})
+	}
+	commands = append(commands,
+		paletteCommand{"Restart job " + label, "r", onJob(keys.Restart)},
+		paletteCommand{"Edit & restart job " + label, "R", onJob(keys.EditRestart)},
+		paletteCommand{"Edit description & tags of " + label, "e", onJob(keys.Edit)},
+		paletteCommand{"Mark job " + label + " to compare", "m", onJob(keys.Mark)},
+		paletteCommand{"Open directory of " + label, "o", onJob(keys.OpenDir)},
+		paletteCommand{"Edit note of " + label, "N", onJob(keys.Note)},
+		paletteCommand{"Browse files of " + label, "F", onJob(keys.Files)},
+	)
+	switch alerting, acked := m.ackState(job); {
+	case acked:
+		commands = append(commands, paletteCommand{"Unacknowledge job " + label, "a", onJob(keys.Ack)})
+	case alerting:
+		commands = append(commands, paletteCommand{"Acknowledge job " + label, "a", onJob(keys.Ack)})
+	}
+	if m.markedJobID != 0 && m.markedJobID != job.ID {
+		commands = append(commands, paletteCommand{fmt.Sprintf("Compare job %s with #%d", label, m.markedJobID), "d", onJob(keys.Diff)})
+	}
+	for _, f := range copyFields {
+		commands = append(commands, paletteCommand{"Copy " + f.name + " of " + label, keys.Copy.Keys()[0] + f.key, func(m Model) (tea.Model, tea.Cmd) {
+			return m, copyToClipboard(job, f)
+		}})
+	}
+	return append(commands, paletteCommand{"Remove job " + label, "x", onJob(keys.Remove)})
+}
+
+// paletteHosts returns the names of the known hosts and the hosts of listed
+// jobs, in alphabetical order
+func (m Model) paletteHosts() []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	add := func(host string) {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	for _, h := range m.hosts {
+		add(h.Name)
+	}
+	for _, job := range m.jobs {
+		add(job.Host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// jobLabel returns a job's description, or its command if it has none
+func (m Model) jobLabel(job *db.Job) string {
+	if job.Description != "" {
+		return job.Description
+	}
+	return m.redactor.String(job.EffectiveCommand())
+}
+
+// selectJob highlights a job in the jobs view, showing its details, and
+// reports whether it is in the list
+func (m *Model) selectJob(id int64) bool {
+	for i, job := range m.jobs {
+		if job.ID != id {
+			continue
+		}
+		m.selectedIndex = i
+		m.headerSelected = false
+		m.scrollToCursor()
+		m.viewMode = ViewModeJobs
+		m.detailTab = DetailTabDetails
+		m.selectedJob = nil
+		return true
+	}
+	return false
+}
+
+// pressKey returns an action that does what pressing a binding's key does
+func pressKey(b key.Binding) func(Model) (tea.Model, tea.Cmd) {
+	return func(m Model) (tea.Model, tea.Cmd) {
+		return m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(b.Keys()[0])})
+	}
+}
+
+// inJobsView returns an action that switches to the jobs view and then runs
+// action
+func inJobsView(action func(Model) (tea.Model, tea.Cmd)) func(Model) (tea.Model, tea.Cmd) {
+	return func(m Model) (tea.Model, tea.Cmd) {
+		m.viewMode = ViewModeJobs
+		return action(m)
+	}
+}
+
+// paletteMatches returns the commands matching the palette's query, best
+// first
+func (m Model) paletteMatches() []paletteCommand {
+	return filterPaletteCommands(m.paletteCommands, m.paletteInput.Value())
+}
+
+// filterPaletteCommands returns the commands whose titles fuzzily match
+// every word of query, best match first. Ties keep the commands' order.
+func filterPaletteCommands(commands []paletteCommand, query string) []paletteCommand {
+	words := strings.Fields(strings.ToLower(query))
+	type match struct {
+		command paletteCommand
+		score   int
+	}
+	var matches []match
+	for _, c := range commands {
+		title := strings.ToLower(c.title)
+		total := 0
+		ok := true
+		for _, w := range words {
+			score, found := fuzzyScore(title, w)
+			if !found {
+				ok = false
+				break
+			}
+			total += score
+		}
+		if ok {
+			matches = append(matches, match{c, total})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := make([]paletteCommand, len(matches))
+	for i, mt := range matches {
+		result[i] = mt.command
+	}
+	return result
+}
+
+// fuzzyScore reports whether the letters of word appear in order in text, and
+// scores the match. A substring scores higher than scattered letters, and
+// higher still at the start of a word and at the end of one, so "42" ranks
+// "#42" above "#420", and "#420" above "#142".
+func fuzzyScore(text, word string) (int, bool) {
+	best, found := 0, false
+	for start := 0; start < len(text); {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			break
+		}
+		i += start
+		score := 10 * len(word)
+		if i == 0 || isWordBoundary(text[i-1]) {
+			score += 15
+		}
+		if end := i + len(word); end == len(text) || isWordBoundary(text[end]) {
+			score += 5
+		}
+		if score > best {
+			best = score
+		}
+		found = true
+		start = i + 1
+	}
+	if found {
+		return best, true
+	}
+
+	// Letters in order: reward runs of consecutive letters and word starts
+	score, ti := 0, 0
+	prev := -2
+	for wi := 0; wi < len(word); wi++ {
+		for ti < len(text) && text[ti] != word[wi] {
+			ti++
+		}
+		if ti == len(text) {
+			return 0, false
+		}
+		score++
+		if ti == prev+1 {
+			score += 3
+		}
+		if ti == 0 || isWordBoundary(text[ti-1]) {
+			score += 5
+		}
+		prev = ti
+		ti++
+	}
+	return score, true
+}
+
+func isWordBoundary(c byte) bool {
+	return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9')
+}
+
+// handlePaletteKeyPress handles keys while the command palette is open
+func (m Model) handlePaletteKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.paletteMode = false
+		m.paletteInput.Blur()
+		return m, nil
+
+	case tea.KeyUp, tea.KeyCtrlP, tea.KeyShiftTab:
+		if m.paletteIndex > 0 {
+			m.paletteIndex--
+		}
+		return m, nil
+
+	case tea.KeyDown, tea.KeyCtrlN, tea.KeyTab:
+		if m.paletteIndex < len(m.paletteMatches())-1 {
+			m.paletteIndex++
+		}
+		return m, nil
+
+	case tea.KeyEnter:
+		matches := m.paletteMatches()
+		if m.paletteIndex >= len(matches) {
+			return m, nil
+		}
+		m.paletteMode = false
+		m.paletteInput.Blur()
+		return matches[m.paletteIndex].run(m)
+	}
+
+	var cmd tea.Cmd
+	m.paletteInput, cmd = m.paletteInput.Update(msg)
+	m.paletteIndex = 0
+	return m, cmd
+}
+
+func (m Model) renderPalette() string {
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(1, 2).
+		Width(70)
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+
+	var b strings.Builder
+	b.WriteString(m.paletteInput.View())
+	b.WriteString("\n\n")
+
+	matches := m.paletteMatches()
+	if len(matches) == 0 {
+		b.WriteString(dimStyle.Render("No matching commands"))
+		b.WriteString("\n")
+	}
+	// Scroll so the highlighted command stays visible
+	first := max(0, m.paletteIndex-paletteMaxRows+1)
+	for i := first; i < len(matches) && i < first+paletteMaxRows; i++ {
+		c := matches[i]
+		title := fmt.Sprintf("%-58s", truncate(c.title, 58))
+		if i == m.paletteIndex {
+			b.WriteString(selectedStyle.Render(title + " " + fmt.Sprintf("%4s", c.key)))
+		} else {
+			b.WriteString(title + " " + keyStyle.Render(fmt.Sprintf("%4s", c.key)))
+		}
+		b.WriteString("\n")
+	}
+	if len(matches) > paletteMaxRows {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("%d of %d commands", min(first+paletteMaxRows, len(matches)), len(matches))))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render("Type to filter • ↑/↓: select • Enter: run • Esc: cancel"))
+
+	return lipgloss.Place(
+		m.width, m.height,
+		lipgloss.Center, lipgloss.Center,
+		modalStyle.Render(b.String()),
+		lipgloss.WithWhitespaceChars(" "),
+		lipgloss.WithWhitespaceForeground(lipgloss.Color("237")),
+	)
+}
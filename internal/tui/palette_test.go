@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func paletteTitles(commands []paletteCommand) []string {
+	var titles []string
+	for _, c := range commands {
+		titles = append(titles, c.title)
+	}
+	return titles
+}
+
+func TestFilterPaletteCommands(t *testing.T) {
+	commands := []paletteCommand{
+		{title: "Kill job #142 eval"},
+		{title: "Kill job #42 train"},
+		{title: "Open logs for #42 train"},
+		{title: "Start queue on cool30"},
+		{title: "Kill job #420 sweep"},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"kill 42", []string{"Kill job #42 train", "Kill job #420 sweep", "Kill job #142 eval"}},
+		{"logs 42", []string{"Open logs for #42 train"}},
+		{"sq cool", []string{"Start queue on cool30"}},
+		{"restart", nil},
+	}
+	for _, tt := range tests {
+		if got := paletteTitles(filterPaletteCommands(commands, tt.query)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterPaletteCommands(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	if got := filterPaletteCommands(commands, " "); len(got) != len(commands) {
+		t.Errorf("empty query matched %d commands, want %d", len(got), len(commands))
+	}
+}
+
+func TestPaletteJobCommands(t *testing.T) {
+	m := Model{jobs: []*db.Job{
+		{ID: 17, Host: "cool30", Status: db.StatusCompleted, Command: "python eval.py"},
+		{ID: 42, Host: "cool31", Status: db.StatusRunning, Description: "train"},
+	}}
+	commands := m.buildPaletteCommands()
+
+	matches := paletteTitles(filterPaletteCommands(commands, "kill 42"))
+	if len(matches) == 0 || matches[0] != "Kill job #42 train" {
+		t.Errorf("\"kill 42\" matches %q", matches)
+	}
+	if got := filterPaletteCommands(commands, "kill 17"); len(got) != 0 {
+		t.Errorf("offered to kill a completed job: %q", paletteTitles(got))
+	}
+	if got := paletteTitles(filterPaletteCommands(commands, "start queue")); !reflect.DeepEqual(got, []string{"Start queue on cool30", "Start queue on cool31"}) {
+		t.Errorf("\"start queue\" matches %q", got)
+	}
+
+	// Running a job's command highlights it and switches to the jobs view
+	m.viewMode = ViewModeHosts
+	logs := filterPaletteCommands(commands, "logs 17")[0]
+	model, _ := logs.run(m)
+	m = model.(Model)
+	if m.viewMode != ViewModeJobs || m.selectedIndex != 0 || m.detailTab != DetailTabLogs {
+		t.Errorf("after %q: view %v, index %d, tab %v", logs.title, m.viewMode, m.selectedIndex, m.detailTab)
+	}
+}
+
+func TestPaletteKeys(t *testing.T) {
+	m := Model{
+		jobs:         []*db.Job{{ID: 1, Status: db.StatusRunning, Command: "make"}},
+		paletteInput: textinput.New(),
+	}
+
+	model, _ := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(":")})
+	m = model.(Model)
+	if !m.paletteMode {
+		t.Fatal("\":\" did not open the palette")
+	}
+	for _, r := range "filter fail" {
+		model, _ = m.handlePaletteKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = model.(Model)
+	}
+	model, _ = m.handlePaletteKeyPress(tea.KeyMsg{Type: tea.KeyEnter})
+	m = model.(Model)
+	if m.paletteMode || m.jobFilter != jobFilterFailed {
+		t.Errorf("after Enter: paletteMode %v, filter %v", m.paletteMode, m.jobFilter)
+	}
+}
@@ -0,0 +1,40 @@
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// readOnlyStatusHelp is the jobs view's key hints in read-only mode
+const readOnlyStatusHelp = "?:help ::commands q:quit ↑/↓:nav l:logs f:filter s:sync y:copy d:diff h:hosts"
+
+// mutatingKeys are the bindings of actions that change jobs, hosts, or the
+// job database, which read-only mode refuses. Opening a job's directory is
+// among them, since it opens an editor on the host.
+func mutatingKeys() []key.Binding {
+	return []key.Binding{
+		keys.Kill, keys.Pause, keys.Restart, keys.EditRestart, keys.Remove,
+		keys.NewJob, keys.Prune, keys.StartQueue, keys.StartNow, keys.OpenDir,
+		keys.Note, keys.Ack, keys.Edit,
+	}
+}
+
+// refusedInReadOnly reports whether read-only mode refuses a key
+func (m Model) refusedInReadOnly(msg tea.KeyMsg) bool {
+	return m.readOnly && key.Matches(msg, mutatingKeys()...)
+}
+
+// readOnlyPaletteCommands drops the palette commands that read-only mode
+// refuses, which are those that do what a refused key does
+func (m Model) readOnlyPaletteCommands(commands []paletteCommand) []paletteCommand {
+	if !m.readOnly {
+		return commands
+	}
+	var allowed []paletteCommand
+	for _, c := range commands {
+		if c.key == "" || !key.Matches(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(c.key)}, mutatingKeys()...) {
+			allowed = append(allowed, c)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestReadOnlyRefusesMutatingKeys(t *testing.T) {
+	m := Model{
+		jobs:     []*db.Job{{ID: 42, Host: "cool30", Status: db.StatusRunning, Command: "python train.py"}},
+		readOnly: true,
+	}
+	for _, k := range []string{"k", "x", "n", "r", "P", "N", "e"} {
+		model, _ := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		got := model.(Model)
+		if !got.flashIsError || !strings.Contains(got.flashMessage, "Read-only") {
+			t.Errorf("%q in read-only mode: flash %q, want a read-only error", k, got.flashMessage)
+		}
+		if got.inputMode || got.editMode {
+			t.Errorf("%q in read-only mode opened a form", k)
+		}
+	}
+
+	// Keys that only look still work
+	model, _ := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")})
+	if got := model.(Model); got.flashIsError || got.jobFilter == jobFilterAll {
+		t.Errorf("f in read-only mode: flash %q, filter %v", got.flashMessage, got.jobFilter)
+	}
+}
+
+func TestReadOnlyPaletteCommands(t *testing.T) {
+	m := Model{
+		jobs:     []*db.Job{{ID: 42, Host: "cool30", Status: db.StatusRunning, Description: "train"}},
+		readOnly: true,
+	}
+	commands := m.readOnlyPaletteCommands(m.buildPaletteCommands())
+	for _, query := range []string{"kill 42", "start queue", "new job", "remove 42", "restart 42"} {
+		if got := filterPaletteCommands(commands, query); len(got) != 0 {
+			t.Errorf("read-only palette offers %q: %q", query, paletteTitles(got))
+		}
+	}
+	if got := filterPaletteCommands(commands, "logs 42"); len(got) == 0 {
+		t.Error("read-only palette doesn't offer the job's logs")
+	}
+}
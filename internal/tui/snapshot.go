@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+// jobSnapshot is the job list as the TUI last showed it, saved so the next
+// start can draw it before the database has been read
+type jobSnapshot struct {
+	Jobs []*db.Job `json:"jobs"`
+}
+
+// DefaultJobSnapshotPath returns where the TUI saves the job list it last
+// showed from the database at dbPath, in the user's cache directory, or ""
+// if there is none. Each database, whether chosen by a profile or --db, has
+// its own snapshot, named for a hash of its path.
+func DefaultJobSnapshotPath(dbPath string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil || dbPath == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(dbPath); err == nil {
+		dbPath = abs
+	}
+	sum := sha256.Sum256([]byte(dbPath))
+	return filepath.Join(dir, "remote-jobs", fmt.Sprintf("tui-jobs-%x.json", sum[:4]))
+}
+
+// loadJobSnapshot reads a saved job list, returning nil if there is none or
+// it can't be read
+func loadJobSnapshot(path string) []*db.Job {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var snapshot jobSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot.Jobs
+}
+
+// writeJobSnapshot saves a job list, replacing the previous one atomically so
+// a TUI starting at the same time never reads a partial file
+func writeJobSnapshot(path string, jobs []*db.Job) error {
+	data, err := json.Marshal(jobSnapshot{Jobs: jobs})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tui-jobs-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveJobSnapshot saves the job list in the background. Failures are
+// ignored: the snapshot only speeds up the next start.
+func (m Model) saveJobSnapshot(jobs []*db.Job) tea.Cmd {
+	path := m.jobSnapshotPath
+	if path == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		_ = writeJobSnapshot(path, jobs)
+		return nil
+	}
+}
@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/db"
+)
+
+func TestJobSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "tui-jobs.json")
+	if got := loadJobSnapshot(path); got != nil {
+		t.Errorf("loadJobSnapshot(missing) = %v, want nil", got)
+	}
+
+	exit := 0
+	jobs := []*db.Job{
+		{ID: 42, Host: "cool30", Command: "python train.py", Status: db.StatusCompleted, StartTime: 1700000000, ExitCode: &exit},
+		{ID: 41, Host: "cool31", Command: "python eval.py", Status: db.StatusRunning},
+	}
+	if err := writeJobSnapshot(path, jobs); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadJobSnapshot(path); !reflect.DeepEqual(got, jobs) {
+		t.Errorf("loadJobSnapshot() = %v, want %v", got, jobs)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadJobSnapshot(path); got != nil {
+		t.Errorf("loadJobSnapshot(corrupt) = %v, want nil", got)
+	}
+}
+
+func TestDefaultJobSnapshotPath(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a", "jobs.db"), filepath.Join(dir, "b", "jobs.db")
+	if DefaultJobSnapshotPath(a) == DefaultJobSnapshotPath(b) {
+		t.Errorf("two databases share the snapshot %s", DefaultJobSnapshotPath(a))
+	}
+	if DefaultJobSnapshotPath(a) != DefaultJobSnapshotPath(filepath.Join(dir, "a", ".", "jobs.db")) {
+		t.Error("two paths to the same database have different snapshots")
+	}
+	if got := DefaultJobSnapshotPath(""); got != "" {
+		t.Errorf("DefaultJobSnapshotPath(\"\") = %q, want none", got)
+	}
+}
+
+func TestNewModelShowsSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tui-jobs.json")
+	jobs := []*db.Job{{ID: 7, Host: "cool30", Status: db.StatusRunning}}
+	if err := writeJobSnapshot(path, jobs); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultModelOptions()
+	opts.JobSnapshot = path
+	m := NewModelWithOptions(nil, opts)
+	if len(m.jobs) != 1 || m.jobs[0].ID != 7 || !m.jobsFromSnapshot {
+		t.Errorf("NewModelWithOptions() jobs = %v, fromSnapshot %v", m.jobs, m.jobsFromSnapshot)
+	}
+}
@@ -10,6 +10,7 @@ var (
 	deadColor      = lipgloss.Color("9")  // Red
 	pendingColor   = lipgloss.Color("11") // Yellow
 	queuedColor    = lipgloss.Color("6")  // Cyan
+	pausedColor    = lipgloss.Color("13") // Magenta
 	selectedBg     = lipgloss.Color("4")  // Blue
 	borderColor    = lipgloss.Color("8")  // Gray
 
@@ -49,6 +50,9 @@ var (
 	queuedStyle = lipgloss.NewStyle().
 			Foreground(queuedColor)
 
+	pausedStyle = lipgloss.NewStyle().
+			Foreground(pausedColor)
+
 	// Text styles
 	headerStyle = lipgloss.NewStyle().
 			Bold(true)
@@ -0,0 +1,98 @@
+// Package watchdog flags running jobs whose GPUs have sat idle for too long,
+// which usually means a hung dataloader or a deadlock.
+package watchdog
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/humanfmt"
+	"github.com/osteele/remote-jobs/internal/scripts"
+	"github.com/osteele/remote-jobs/internal/session"
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+// IdleUtilization is the GPU utilization (%) at or below which a GPU counts as idle
+const IdleUtilization = 2
+
+// Alert is a job the watchdog has just flagged
+type Alert struct {
+	Job       *db.Job
+	IdleSince int64
+}
+
+// IdleFor returns how long the job's GPUs had been idle as of now
+func (a Alert) IdleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(a.IdleSince, 0))
+}
+
+// Message describes the alert for warnings and notifications
+func (a Alert) Message(now time.Time) string {
+	return fmt.Sprintf("job %d on %s has had idle GPUs for %s",
+		a.Job.ID, a.Job.Host, humanfmt.Duration(int64(a.IdleFor(now).Seconds())))
+}
+
+// Idle reports whether every GPU in mappings is idle. A job with no GPUs, or
+// whose utilization wasn't reported, is never idle.
+func Idle(mappings []ssh.JobGPUMapping) bool {
+	if len(mappings) == 0 {
+		return false
+	}
+	for _, m := range mappings {
+		if m.Utilization < 0 || m.Utilization > IdleUtilization {
+			return false
+		}
+	}
+	return true
+}
+
+// Check samples GPU utilization for a host's running jobs and records it. It
+// returns the jobs whose GPUs have now been idle for at least idleAfter and
+// that hadn't been flagged yet; each job is returned once per idle stretch.
+// The idle stretches of jobs that have stopped running are forgotten.
+func Check(database *sql.DB, host string, idleAfter time.Duration, now time.Time) ([]Alert, error) {
+	if err := db.ClearStaleGPUIdle(database); err != nil {
+		return nil, err
+	}
+	jobs, err := db.GetRunningJobsByHost(database, host)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	var infos []ssh.JobPIDInfo
+	for _, job := range jobs {
+		infos = append(infos, ssh.JobPIDInfo{
+			JobID:   job.ID,
+			PIDFile: session.JobPidFile(job.ID, job.StartTime),
+		})
+	}
+	mappings, err := ssh.GetJobGPUMappings(host, scripts.GPUJobMappingScript, infos)
+	if err != nil {
+		return nil, err
+	}
+	byJob := make(map[int64][]ssh.JobGPUMapping)
+	for _, m := range mappings {
+		byJob[m.JobID] = append(byJob[m.JobID], m)
+	}
+
+	var alerts []Alert
+	for _, job := range jobs {
+		since, err := db.RecordGPUIdle(database, job.ID, Idle(byJob[job.ID]), now.Unix())
+		if err != nil {
+			return alerts, fmt.Errorf("record GPU idle for job %d: %w", job.ID, err)
+		}
+		if since == 0 || now.Sub(time.Unix(since, 0)) < idleAfter {
+			continue
+		}
+		flagged, err := db.FlagGPUIdle(database, job.ID, now.Unix())
+		if err != nil {
+			return alerts, fmt.Errorf("flag job %d: %w", job.ID, err)
+		}
+		if flagged {
+			alerts = append(alerts, Alert{Job: job, IdleSince: since})
+		}
+	}
+	return alerts, nil
+}
@@ -0,0 +1,28 @@
+package watchdog
+
+import (
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/ssh"
+)
+
+func TestIdle(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []ssh.JobGPUMapping
+		want     bool
+	}{
+		{"no GPUs", nil, false},
+		{"all idle", []ssh.JobGPUMapping{{GPUIndex: 0, Utilization: 0}, {GPUIndex: 1, Utilization: 1}}, true},
+		{"one busy", []ssh.JobGPUMapping{{GPUIndex: 0, Utilization: 0}, {GPUIndex: 1, Utilization: 85}}, false},
+		{"not reported", []ssh.JobGPUMapping{{GPUIndex: 0, Utilization: -1}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Idle(tt.mappings); got != tt.want {
+				t.Errorf("Idle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Package webhooks sends job status changes to configured URLs as JSON
+// POSTs, so that CI systems and chat bots can react to them without polling.
+//
+// Each payload is signed, when the webhook has a secret, with an
+// X-Remote-Jobs-Signature header of "sha256=" and the hex HMAC-SHA256 of the
+// body. X-Remote-Jobs-Event-Id identifies the event, so that receivers can
+// ignore a delivery that was retried after it had in fact arrived.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/redact"
+)
+
+// Payload is the JSON body sent for a job status change
+type Payload struct {
+	EventID     int64    `json:"event_id"`
+	Time        string   `json:"time"`
+	JobID       int64    `json:"job_id"`
+	Host        string   `json:"host"`
+	From        string   `json:"from"` // Empty when the job was created
+	To          string   `json:"to"`
+	ExitCode    *int     `json:"exit_code,omitempty"`
+	Command     string   `json:"command"`
+	WorkingDir  string   `json:"working_dir"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// NewPayload describes a job status change
+func NewPayload(e db.Event, job *db.Job, tags []string) Payload {
+	return Payload{
+		EventID:     e.ID,
+		Time:        time.Unix(e.At, 0).UTC().Format(time.RFC3339),
+		JobID:       job.ID,
+		Host:        job.Host,
+		From:        e.From,
+		To:          e.To,
+		ExitCode:    job.ExitCode,
+		Command:     job.EffectiveCommand(),
+		WorkingDir:  job.EffectiveWorkingDir(),
+		Description: job.Description,
+		Tags:        tags,
+	}
+}
+
+// Matches reports whether a webhook is sent a job status change, given the
+// job's tags
+func Matches(h config.Webhook, e db.Event, tags []string) bool {
+	if e.Kind != db.EventJob {
+		return false
+	}
+	if len(h.Statuses) > 0 && !slices.Contains(h.Statuses, e.To) {
+		return false
+	}
+	if len(h.Hosts) > 0 && !slices.Contains(h.Hosts, e.Host) {
+		return false
+	}
+	if len(h.Tags) > 0 && !slices.ContainsFunc(h.Tags, func(t string) bool { return slices.Contains(tags, t) }) {
+		return false
+	}
+	return true
+}
+
+// Sign returns the X-Remote-Jobs-Signature header value for a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay is how long the first retry waits; each later one waits twice
+// as long as the one before
+var retryDelay = time.Second
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Post sends a payload to a webhook, retrying network errors and 5xx and 429
+// responses up to the webhook's attempts
+func Post(h config.Webhook, eventID int64, body []byte) error {
+	attempts := h.Attempts
+	if attempts <= 0 {
+		attempts = config.DefaultWebhookAttempts
+	}
+	delay := retryDelay
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		var retry bool
+		retry, err = post(h, eventID, body)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return fmt.Errorf("%w (after %d attempts)", err, attempts)
+}
+
+// post sends one request, and reports whether a failure is worth retrying
+func post(h config.Webhook, eventID int64, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "remote-jobs")
+	req.Header.Set("X-Remote-Jobs-Event-Id", strconv.FormatInt(eventID, 10))
+	if h.Secret != "" {
+		req.Header.Set("X-Remote-Jobs-Signature", Sign(h.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("%s", resp.Status)
+}
+
+// DeliverPending sends the job status changes recorded since the last
+// delivery, by any process, to the webhooks they match, with commands
+// redacted by r. Each change is marked sent once it has been delivered, so
+// that one cut short is sent again the next time; a receiver can tell the
+// repeat by its event ID. Delivery stops when another process has already
+// sent a change. Failures are written to out. It returns the number of
+// payloads delivered.
+func DeliverPending(database *sql.DB, hooks []config.Webhook, r *redact.Redactor, out io.Writer) (int, error) {
+	if len(hooks) == 0 {
+		return 0, nil
+	}
+	events, cursor, err := db.PendingWebhookEvents(database)
+	if err != nil {
+		return 0, fmt.Errorf("list events for webhooks: %w", err)
+	}
+
+	var sent int
+	for _, e := range events {
+		sent += deliver(database, hooks, e, r, out)
+		advanced, err := db.AdvanceWebhookCursor(database, cursor, e.ID)
+		if err != nil {
+			return sent, fmt.Errorf("record webhook delivery: %w", err)
+		}
+		if !advanced {
+			return sent, nil
+		}
+		cursor = e.ID
+	}
+	return sent, nil
+}
+
+// deliver sends an event to the webhooks it matches, and returns the number
+// of payloads delivered
+func deliver(database *sql.DB, hooks []config.Webhook, e db.Event, r *redact.Redactor, out io.Writer) int {
+	if e.Kind != db.EventJob {
+		return 0
+	}
+	job, err := db.GetJobByID(database, e.JobID)
+	if err != nil || job == nil {
+		return 0
+	}
+	tags, _ := db.GetJobTags(database, job.ID)
+	payload := NewPayload(e, job, tags)
+	payload.Command = r.String(payload.Command)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		fmt.Fprintf(out, "Warning: webhook payload for job %d: %v\n", job.ID, err)
+		return 0
+	}
+	var sent int
+	for _, h := range hooks {
+		if !Matches(h, e, tags) {
+			continue
+		}
+		if err := Post(h, e.ID, body); err != nil {
+			fmt.Fprintf(out, "Warning: webhook %s for job %d: %v\n", h.URL, job.ID, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
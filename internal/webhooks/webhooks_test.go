@@ -0,0 +1,149 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/osteele/remote-jobs/internal/config"
+	"github.com/osteele/remote-jobs/internal/db"
+	"github.com/osteele/remote-jobs/internal/redact"
+)
+
+func TestMatches(t *testing.T) {
+	finished := db.Event{Kind: db.EventJob, JobID: 1, Host: "cool30", From: "running", To: "completed"}
+	tags := []string{"sweep", "lr"}
+
+	tests := []struct {
+		name string
+		hook config.Webhook
+		e    db.Event
+		want bool
+	}{
+		{"no filters", config.Webhook{}, finished, true},
+		{"status matches", config.Webhook{Statuses: []string{"completed", "dead"}}, finished, true},
+		{"status differs", config.Webhook{Statuses: []string{"dead"}}, finished, false},
+		{"host matches", config.Webhook{Hosts: []string{"cool30"}}, finished, true},
+		{"host differs", config.Webhook{Hosts: []string{"cool31"}}, finished, false},
+		{"one tag matches", config.Webhook{Tags: []string{"ablation", "sweep"}}, finished, true},
+		{"no tag matches", config.Webhook{Tags: []string{"ablation"}}, finished, false},
+		{"host event", config.Webhook{}, db.Event{Kind: db.EventHost, Host: "cool30", From: "online", To: "offline"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(tt.hook, tt.e, tags); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"job_id":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=6d646a5c6f1b975f638f87c9438ebef99e7bc05c831dae4c41bc4bb2a721301f"
+	if got := Sign("secret", []byte(`{"job_id":1}`)); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestPost(t *testing.T) {
+	retryDelay = 0
+
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"ok", []int{200}, 1, false},
+		{"retried server error", []int{502, 204}, 2, false},
+		{"retried rate limit", []int{429, 200}, 2, false},
+		{"gives up", []int{500, 500, 500}, 3, true},
+		{"client error not retried", []int{404}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var gotSig, gotID, gotBody string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				gotBody = string(body)
+				gotSig = r.Header.Get("X-Remote-Jobs-Signature")
+				gotID = r.Header.Get("X-Remote-Jobs-Event-Id")
+				w.WriteHeader(tt.statuses[min(calls, len(tt.statuses)-1)])
+				calls++
+			}))
+			defer srv.Close()
+
+			body := []byte(`{"job_id":1}`)
+			err := Post(config.Webhook{URL: srv.URL, Secret: "secret"}, 7, body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Post() made %d requests, want %d", calls, tt.wantCalls)
+			}
+			if gotBody != string(body) || gotID != "7" || gotSig != Sign("secret", body) {
+				t.Errorf("Post() sent body %q, event id %q, signature %q", gotBody, gotID, gotSig)
+			}
+		})
+	}
+}
+
+func TestDeliverPending(t *testing.T) {
+	retryDelay = 0
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	r, err := redact.New(nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var p Payload
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			t.Error(err)
+		}
+		got = append(got, p)
+	}))
+	defer srv.Close()
+	hooks := []config.Webhook{{URL: srv.URL}}
+	deliver := func() int {
+		t.Helper()
+		n, err := DeliverPending(database, hooks, r, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if n := deliver(); n != 0 {
+		t.Errorf("first DeliverPending() sent %d, want 0", n)
+	}
+	id, err := db.RecordJobStarting(database, "cool30", "~/code", "python train.py --api-key abc123456789", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdateJobRunning(database, id); err != nil {
+		t.Fatal(err)
+	}
+	if n := deliver(); n != 2 || len(got) != 2 {
+		t.Fatalf("DeliverPending() sent %d, %+v; want the 2 changes", n, got)
+	}
+	if got[0].To != "starting" || got[1].To != "running" {
+		t.Errorf("DeliverPending() sent %q then %q, want starting then running", got[0].To, got[1].To)
+	}
+	if want := "python train.py --api-key <redacted>"; got[0].Command != want {
+		t.Errorf("payload command = %q, want %q", got[0].Command, want)
+	}
+	if n := deliver(); n != 0 {
+		t.Errorf("DeliverPending() sent %d again, want 0", n)
+	}
+}
@@ -0,0 +1,96 @@
+// Package xdg finds the local directory of remote-jobs' config file and job
+// database, following the XDG base directory spec: $XDG_CONFIG_HOME/remote-jobs
+// if XDG_CONFIG_HOME is set, and ~/.config/remote-jobs otherwise.
+package xdg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const appName = "remote-jobs"
+
+// ConfigDir returns the directory of the config file and job database, or
+// "" if there is no home directory. Until MigrateConfigDir moves it ('db
+// migrate-xdg'), an existing ~/.config/remote-jobs is used even if
+// XDG_CONFIG_HOME is set elsewhere.
+func ConfigDir() string {
+	legacy, dir := legacyConfigDir(), xdgConfigDir()
+	if dir == "" || dir == legacy {
+		return legacy
+	}
+	if exists(dir) || legacy == "" || !exists(legacy) {
+		return dir
+	}
+	return legacy
+}
+
+// MigrateConfigDir moves ~/.config/remote-jobs to $XDG_CONFIG_HOME/remote-jobs
+// if XDG_CONFIG_HOME is set elsewhere and that directory doesn't exist yet,
+// and leaves a symlink to it in its place, so that processes that don't see
+// XDG_CONFIG_HOME, or that have the database open, still find it. It
+// returns the directory it moved, or "" if there was none to move. It
+// doesn't copy a directory across filesystems.
+func MigrateConfigDir() (string, error) {
+	legacy, dir := legacyConfigDir(), xdgConfigDir()
+	if dir == "" || legacy == "" || dir == legacy || exists(dir) || !exists(legacy) {
+		return "", nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(legacy, dir); err != nil {
+		if errors.Is(err, syscall.EXDEV) {
+			return "", fmt.Errorf("%s and %s are on different filesystems; move it yourself, and leave a symlink in its place", legacy, dir)
+		}
+		return "", err
+	}
+	if err := os.Symlink(dir, legacy); err != nil {
+		return legacy, fmt.Errorf("link %s to %s: %w", legacy, dir, err)
+	}
+	return legacy, nil
+}
+
+// RuntimeDir returns a directory, readable only by the user, for files that
+// shouldn't outlive the login session: $XDG_RUNTIME_DIR/remote-jobs if
+// XDG_RUNTIME_DIR is set, and otherwise a remote-jobs directory in the
+// system's temporary directory. It creates the directory if necessary.
+func RuntimeDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appName, os.Getuid()))
+	if base := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(base) {
+		dir = filepath.Join(base, appName)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// A directory left by someone else mustn't be used
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// xdgConfigDir returns the directory under $XDG_CONFIG_HOME, or "" if it
+// isn't set. The spec says a relative path is to be ignored.
+func xdgConfigDir() string {
+	if base := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(base) {
+		return filepath.Join(base, appName)
+	}
+	return ""
+}
+
+func legacyConfigDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", appName)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
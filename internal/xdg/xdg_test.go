@@ -0,0 +1,82 @@
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	legacy := filepath.Join(home, ".config", "remote-jobs")
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if got := ConfigDir(); got != legacy {
+		t.Errorf("ConfigDir() without XDG_CONFIG_HOME = %s, want %s", got, legacy)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "relative/config")
+	if got := ConfigDir(); got != legacy {
+		t.Errorf("ConfigDir() with a relative XDG_CONFIG_HOME = %s, want %s", got, legacy)
+	}
+
+	xdgHome := filepath.Join(home, "xdg")
+	dir := filepath.Join(xdgHome, "remote-jobs")
+	t.Setenv("XDG_CONFIG_HOME", xdgHome)
+	if got := ConfigDir(); got != dir {
+		t.Errorf("ConfigDir() for a new user = %s, want %s", got, dir)
+	}
+
+	// An existing directory is used until it is moved
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "config.yaml"), []byte("sync_interval: 5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ConfigDir(); got != legacy {
+		t.Errorf("ConfigDir() before migrating = %s, want %s", got, legacy)
+	}
+	moved, err := MigrateConfigDir()
+	if err != nil || moved != legacy {
+		t.Fatalf("MigrateConfigDir() = %q, %v; want %q", moved, err, legacy)
+	}
+	if got := ConfigDir(); got != dir {
+		t.Errorf("ConfigDir() after migrating = %s, want %s", got, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Errorf("config file wasn't moved: %v", err)
+	}
+
+	// Processes without XDG_CONFIG_HOME find it through a link
+	if _, err := os.Stat(filepath.Join(legacy, "config.yaml")); err != nil {
+		t.Errorf("config file isn't found in its old place: %v", err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if got := ConfigDir(); got != legacy {
+		t.Errorf("ConfigDir() without XDG_CONFIG_HOME after migrating = %s, want %s", got, legacy)
+	}
+	t.Setenv("XDG_CONFIG_HOME", xdgHome)
+	if moved, err := MigrateConfigDir(); moved != "" || err != nil {
+		t.Errorf("MigrateConfigDir() again = %q, %v; want nothing to move", moved, err)
+	}
+}
+
+func TestRuntimeDir(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", base)
+	dir, err := RuntimeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(base, "remote-jobs"); dir != want {
+		t.Errorf("RuntimeDir() = %s, want %s", dir, want)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("RuntimeDir() permissions = %o, want 700", perm)
+	}
+}